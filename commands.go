@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// A command is a subcommand of msgp that converts or displays data rather than generating code.
+// It reads from in and writes to out.
+type command func(out io.Writer, in io.Reader) error
+
+var commands = map[string]command{
+	"json2mp": json2mp,
+	"mp2json": mp2json,
+	"inspect": inspect,
+}
+
+// runCommand runs the command named by args[0] with the file named by args[1] (or the standard
+// input if there is no file) as input and the standard output as output.
+func runCommand(cmd command, args []string) error {
+	in := io.Reader(os.Stdin)
+	switch len(args) {
+	case 1:
+	case 2:
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	default:
+		return fmt.Errorf("usage: msgp %s [file]", args[0])
+	}
+	out := bufio.NewWriter(os.Stdout)
+	if err := cmd(out, in); err != nil {
+		out.Flush()
+		return err
+	}
+	return out.Flush()
+}
+
+// json2mp translates JSON values into MessagePack.
+func json2mp(out io.Writer, in io.Reader) error {
+	_, err := msgp.CopyFromJSON(out, in)
+	return err
+}
+
+// mp2json translates MessagePack objects into JSON values, each on its own line.
+func mp2json(out io.Writer, in io.Reader) error {
+	r := msgp.NewReader(in)
+	for {
+		if _, err := r.R.Peek(1); err == io.EOF {
+			return nil
+		}
+		// Each object is copied out before being translated so that the values are separated.
+		var obj msgp.Raw
+		if err := obj.DecodeMsg(r); err != nil {
+			return err
+		}
+		if _, err := msgp.UnmarshalAsJSON(out, obj); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, "\n"); err != nil {
+			return err
+		}
+	}
+}
+
+// inspect prints a human-readable rendering of MessagePack objects.
+func inspect(out io.Writer, in io.Reader) error {
+	b, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if err = msgp.Fprint(out, b); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "\n")
+	return err
+}
@@ -0,0 +1,152 @@
+package gen
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The values of GOOS and GOARCH recognized in file names (as in name_GOOS_GOARCH.go).
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+		"illumos": true, "ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true,
+		"openbsd": true, "plan9": true, "solaris": true, "wasip1": true, "windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true,
+		"arm64be": true, "loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+		"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true, "ppc64le": true, "riscv": true,
+		"riscv64": true, "s390": true, "s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// buildContext returns the context used to select the files of a package: the default context
+// (with GOOS and GOARCH from the environment) with the tags given with the Tags option.
+func (o *options) buildContext() build.Context {
+	ctx := build.Default
+	ctx.BuildTags = append(ctx.BuildTags[:len(ctx.BuildTags):len(ctx.BuildTags)], o.tags...)
+	return ctx
+}
+
+// fileFilter returns a filter for parser.ParseDir selecting the files in dir that ctx matches.
+func fileFilter(ctx build.Context, dir string) func(os.FileInfo) bool {
+	return func(fi os.FileInfo) bool {
+		ok, err := ctx.MatchFile(dir, fi.Name())
+		return err == nil && ok
+	}
+}
+
+// fileConstraint returns the build constraint of the file f named name, which is given by the
+// //go:build line (or the // +build lines) before the package clause and by the GOOS and GOARCH
+// suffixes of the name. The constraint is nil if the file is not constrained.
+func fileConstraint(f *ast.File, name string) (constraint.Expr, error) {
+	var goBuild, plusBuild constraint.Expr
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			switch {
+			case constraint.IsGoBuild(c.Text):
+				x, err := constraint.Parse(c.Text)
+				if err != nil {
+					return nil, err
+				}
+				goBuild = x
+			case constraint.IsPlusBuild(c.Text):
+				x, err := constraint.Parse(c.Text)
+				if err != nil {
+					return nil, err
+				}
+				plusBuild = andConstraints(plusBuild, x)
+			}
+		}
+	}
+	x := goBuild
+	if x == nil {
+		x = plusBuild
+	}
+	return andConstraints(x, nameConstraint(name)), nil
+}
+
+// nameConstraint returns the constraint given by the GOOS and GOARCH suffixes of a file name.
+// Like the go command, it looks only at the part of the name after the first underscore.
+func nameConstraint(name string) constraint.Expr {
+	name = strings.TrimSuffix(filepath.Base(name), ".go")
+	name = strings.TrimSuffix(name, "_test")
+	i := strings.IndexByte(name, '_')
+	if i < 0 {
+		return nil
+	}
+	l := strings.Split(name[i:], "_")
+	n := len(l)
+	if n >= 2 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+		return andConstraints(&constraint.TagExpr{Tag: l[n-2]}, &constraint.TagExpr{Tag: l[n-1]})
+	}
+	if knownOS[l[n-1]] || knownArch[l[n-1]] {
+		return &constraint.TagExpr{Tag: l[n-1]}
+	}
+	return nil
+}
+
+// andConstraints returns the conjunction of x and y, either of which may be nil, leaving out the
+// terms of y already in x.
+func andConstraints(x, y constraint.Expr) constraint.Expr {
+	if a, ok := y.(*constraint.AndExpr); ok {
+		return andConstraints(andConstraints(x, a.X), a.Y)
+	}
+	switch {
+	case x == nil:
+		return y
+	case y == nil || hasTerm(x, y.String()):
+		return x
+	}
+	return &constraint.AndExpr{X: x, Y: y}
+}
+
+// commonConstraint returns the conjunction of the terms of x that are also terms of y, which is nil
+// if there are none.
+func commonConstraint(x, y constraint.Expr) constraint.Expr {
+	if a, ok := x.(*constraint.AndExpr); ok {
+		return andConstraints(commonConstraint(a.X, y), commonConstraint(a.Y, y))
+	}
+	if x == nil || y == nil || !hasTerm(y, x.String()) {
+		return nil
+	}
+	return x
+}
+
+// hasTerms says if the conjunction x, which may be nil, has all of the terms of y.
+func hasTerms(x, y constraint.Expr) bool {
+	if a, ok := y.(*constraint.AndExpr); ok {
+		return hasTerms(x, a.X) && hasTerms(x, a.Y)
+	}
+	return y == nil || x != nil && hasTerm(x, y.String())
+}
+
+// hasTerm says if the conjunction x has a term that is written as term.
+func hasTerm(x constraint.Expr, term string) bool {
+	if a, ok := x.(*constraint.AndExpr); ok {
+		return hasTerm(a.X, term) || hasTerm(a.Y, term)
+	}
+	return x.String() == term
+}
+
+// writeConstraint writes the //go:build and // +build lines for x, if x is not nil.
+func writeConstraint(b *bytes.Buffer, x constraint.Expr) {
+	if x == nil {
+		return
+	}
+	b.WriteString("//go:build " + x.String() + "\n")
+	if lines, err := constraint.PlusBuildLines(x); err == nil {
+		for _, l := range lines {
+			b.WriteString(l + "\n")
+		}
+	}
+	b.WriteString("\n")
+}
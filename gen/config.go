@@ -0,0 +1,170 @@
+package gen
+
+import "bytes"
+
+// A Config holds the settings of a code generation run, for the programs (such as build systems)
+// that set many of them. The fields correspond to the parameters of Run and to the Options; their
+// zero values select the defaults. For the numeric settings whose Options accept 0, a negative
+// value in a Config has the effect of 0 (since 0 selects the default).
+type Config struct {
+	Src        string // the source file or directory (required)
+	Out        string // the output file; if empty, the file is named as described at Run
+	Mode       Method // the methods and tests to generate
+	Unexported bool   // also process unexported types
+
+	TestsFor      Method // limit the tests to those of these methods (see TestsFor)
+	NoBenchmarks  bool   // do not generate benchmarks along with tests
+	ExternalTests bool   // put the tests in the external test package (see ExternalTests)
+	OutDir        string // the directory of the test files (see OutDir)
+	OutPackage    string // the package of the test files in OutDir (see OutPackage)
+	FillSize      int    // the size of the values used by the benchmarks (see FillSize)
+
+	MaxComplexity int      // the limit on the complexity of inlined types (see MaxComplexity)
+	KeySwitch     int      // the number of fields from which keys are switched on by bytes (see KeySwitch)
+	KVFields      int      // the number of fields from which structs are written with tables (see KVFields)
+	Shims         []string // the files from which shim directives are loaded (see Shims)
+	BuildTags     []string // the build tags for selecting the files of a directory (see Tags)
+	Workers       int      // the number of types printed concurrently (see Workers)
+
+	ExactSizes    bool   // generate MsgsizeExact methods (see ExactSizes)
+	TightSizes    bool   // make Msgsize measure values (see TightSizes)
+	CompactFloats bool   // write float64s in the fewest bytes (see CompactFloats)
+	OldSpecCompat bool   // read 'str' and 'bin' objects interchangeably (see OldSpecCompat)
+	LenientFloats bool   // read integers into float64s (see LenientFloats)
+	LenientBools  bool   // read the integers 0 and 1 into bools (see LenientBools)
+	IdentPrefix   string // the prefix of the names of generated variables (see IdentPrefix)
+	Receiver      string // the name of the receivers of the methods (see Receiver)
+	StableIdents  bool   // derive the names of generated variables from their use (see StableIdents)
+	Strict        bool   // fail if any warnings are reported (see Strict)
+	Views         bool   // generate the View types of structs (see Views)
+	CrossTests    bool   // generate the tests against another library (see CrossTests)
+	Freestanding  bool   // generate methods not referring to the msgp package (see Freestanding)
+	WrapErrors    bool   // wrap the decoding errors with the paths of the values (see WrapErrors)
+	Schema        bool   // write the JSON Schema of the types (see Schema)
+	Registry      bool   // register the field tables of the types (see Registry)
+	FieldConsts   bool   // generate the constants holding the keys of fields (see FieldConsts)
+	SQL           bool   // generate the database/sql Value and Scan methods (see SQL)
+
+	// Options are applied after the settings above, so they take precedence.
+	Options []Option
+}
+
+// RunConfig works like Run with the settings in c.
+func RunConfig(c Config) error {
+	return Run(c.Src, c.Out, c.Mode, c.Unexported, c.options()...)
+}
+
+// RunConfigData works like RunData with the settings in c. The output file in c is not used.
+func RunConfigData(c Config) (mainBuf *bytes.Buffer, testsBuf *bytes.Buffer, err error) {
+	return RunData(c.Src, c.Mode, c.Unexported, c.options()...)
+}
+
+// RunConfigOutput works like RunOutput with the settings in c. The output file in c is not used.
+func RunConfigOutput(c Config) (*Output, error) {
+	return RunOutput(c.Src, c.Mode, c.Unexported, c.options()...)
+}
+
+// options returns the Options having the effects of the settings in c.
+func (c *Config) options() []Option {
+	var opts []Option
+	if c.TestsFor != 0 {
+		opts = append(opts, TestsFor(c.TestsFor))
+	}
+	if c.NoBenchmarks {
+		opts = append(opts, NoBenchmarks())
+	}
+	if c.ExternalTests {
+		opts = append(opts, ExternalTests())
+	}
+	if c.OutDir != "" {
+		opts = append(opts, OutDir(c.OutDir))
+	}
+	if c.OutPackage != "" {
+		opts = append(opts, OutPackage(c.OutPackage))
+	}
+	if n, ok := configInt(c.FillSize); ok {
+		opts = append(opts, FillSize(n))
+	}
+	if n, ok := configInt(c.MaxComplexity); ok {
+		opts = append(opts, MaxComplexity(n))
+	}
+	if n, ok := configInt(c.KeySwitch); ok {
+		opts = append(opts, KeySwitch(n))
+	}
+	if c.KVFields > 0 {
+		opts = append(opts, KVFields(c.KVFields))
+	}
+	if len(c.Shims) > 0 {
+		opts = append(opts, Shims(c.Shims...))
+	}
+	if len(c.BuildTags) > 0 {
+		opts = append(opts, Tags(c.BuildTags...))
+	}
+	if c.Workers != 0 {
+		opts = append(opts, Workers(c.Workers))
+	}
+	if c.ExactSizes {
+		opts = append(opts, ExactSizes())
+	}
+	if c.TightSizes {
+		opts = append(opts, TightSizes())
+	}
+	if c.CompactFloats {
+		opts = append(opts, CompactFloats())
+	}
+	if c.OldSpecCompat {
+		opts = append(opts, OldSpecCompat())
+	}
+	if c.LenientFloats {
+		opts = append(opts, LenientFloats())
+	}
+	if c.LenientBools {
+		opts = append(opts, LenientBools())
+	}
+	if c.IdentPrefix != "" {
+		opts = append(opts, IdentPrefix(c.IdentPrefix))
+	}
+	if c.Receiver != "" {
+		opts = append(opts, Receiver(c.Receiver))
+	}
+	if c.StableIdents {
+		opts = append(opts, StableIdents())
+	}
+	if c.Strict {
+		opts = append(opts, Strict())
+	}
+	if c.Views {
+		opts = append(opts, Views())
+	}
+	if c.CrossTests {
+		opts = append(opts, CrossTests())
+	}
+	if c.Freestanding {
+		opts = append(opts, Freestanding())
+	}
+	if c.WrapErrors {
+		opts = append(opts, WrapErrors())
+	}
+	if c.Schema {
+		opts = append(opts, Schema())
+	}
+	if c.Registry {
+		opts = append(opts, Registry())
+	}
+	if c.FieldConsts {
+		opts = append(opts, FieldConsts())
+	}
+	if c.SQL {
+		opts = append(opts, SQL())
+	}
+	return append(opts, c.Options...)
+}
+
+// configInt returns the option value for a numeric setting of a Config and whether the setting
+// is not the default: 0 selects the default, and negative values stand for 0.
+func configInt(n int) (int, bool) {
+	if n < 0 {
+		return 0, true
+	}
+	return n, n != 0
+}
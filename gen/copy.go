@@ -0,0 +1,135 @@
+package gen
+
+import (
+	"io"
+)
+
+func copies(w io.Writer, ids *identGen) *copyGen {
+	return &copyGen{
+		p: printer{w: w, ids: ids},
+	}
+}
+
+// A copyGen prints Clone methods, which make deep copies of values without reflection.
+//
+// The copy of a value starts as a shallow copy (made by assignment), and the code printed for an
+// element replaces the parts of the copy shared with the original (the contents of slices, maps,
+// and pointers) with copies of their own. Nothing is printed for the elements that share nothing.
+type copyGen struct {
+	passes
+	p printer
+}
+
+func (c *copyGen) Method() Method { return Copy }
+
+func (c *copyGen) Apply(dirs []string) error {
+	return nil
+}
+
+func (c *copyGen) Execute(p Elem) error {
+	if !c.p.ok() {
+		return c.p.err
+	}
+	p = c.applyAll(p)
+	if p == nil || !isPrintable(p) {
+		return nil
+	}
+
+	recv := imutMethodReceiver(p)
+	c.p.comment("Clone returns a deep copy of " + p.Varname())
+	c.p.printf("\nfunc (%s %s) Clone() (c %s) {", p.Varname(), recv, p.TypeName())
+	if recv[0] == '*' {
+		c.p.printf("\nc = *%s", p.Varname())
+	} else {
+		c.p.printf("\nc = %s", p.Varname())
+	}
+	c.deep("c", p.Varname(), p)
+	c.p.nakedReturn()
+	return c.p.err
+}
+
+// deep prints the code making dst, which holds a shallow copy of src, a deep copy of src.
+func (c *copyGen) deep(dst, src string, e Elem) {
+	if !c.p.ok() || !sharesMemory(e) {
+		return
+	}
+	switch e := e.(type) {
+	case *Struct:
+		for i := range e.Fields {
+			name := "." + e.Fields[i].fieldName
+			c.deep(dst+name, src+name, e.Fields[i].fieldElem)
+		}
+	case *Array:
+		c.p.printf("\nfor %s := range %s {", e.Index, src)
+		c.deep(dst+"["+e.Index+"]", src+"["+e.Index+"]", e.Els)
+		c.p.closeBlock()
+	case *Slice:
+		c.p.printf("\nif %s != nil {", src)
+		c.p.printf("\n%s = make(%s, len(%s))", dst, e.TypeName(), src)
+		c.p.printf("\ncopy(%s, %s)", dst, src)
+		if sharesMemory(e.Els) {
+			c.p.printf("\nfor %s := range %s {", e.Index, src)
+			c.deep(dst+"["+e.Index+"]", src+"["+e.Index+"]", e.Els)
+			c.p.closeBlock()
+		}
+		c.p.closeBlock()
+	case *Map:
+		c.p.printf("\nif %s != nil {", src)
+		c.p.printf("\n%s = make(%s, len(%s))", dst, e.TypeName(), src)
+		c.p.printf("\nfor %s, %s := range %s {", e.KeyIndx, e.ValIndx, src)
+		if sharesMemory(e.Value) {
+			// The value is copied into a variable of its own since map elements are not addressable.
+			tmp := c.p.newIdent(dst + " value")
+			c.p.printf("\n%s := %s", tmp, e.ValIndx)
+			c.deep(tmp, e.ValIndx, e.Value)
+			c.p.printf("\n%s[%s] = %s", dst, e.KeyIndx, tmp)
+		} else {
+			c.p.printf("\n%s[%s] = %s", dst, e.KeyIndx, e.ValIndx)
+		}
+		c.p.closeBlock()
+		c.p.closeBlock()
+	case *Ptr:
+		c.p.printf("\nif %s != nil {", src)
+		c.p.printf("\n%s = new(%s)", dst, e.Value.TypeName())
+		if be, ok := e.Value.(*BaseElem); ok && be.Value == IDENT {
+			c.p.printf("\n*%s = %s.Clone()", dst, src)
+		} else {
+			c.p.printf("\n*%s = *%s", dst, src)
+			c.deep("(*"+dst+")", "(*"+src+")", e.Value)
+		}
+		c.p.closeBlock()
+	case *BaseElem:
+		switch e.Value {
+		case IDENT:
+			c.p.printf("\n%s = %s.Clone()", dst, src)
+		case Bytes, Raw, StrBytes:
+			c.p.printf("\nif %s != nil {", src)
+			c.p.printf("\n%s = make(%s, len(%s))", dst, e.TypeName(), src)
+			c.p.printf("\ncopy(%s, %s)", dst, src)
+			c.p.closeBlock()
+		}
+	}
+}
+
+// sharesMemory says if a shallow copy of a value of e shares memory with the value that the
+// printed Clone methods do not copy. The values of interface types, extensions, types written in
+// their binary or text forms or as decimals, and shimmed types are copied by assignment since their
+// contents are not known.
+func sharesMemory(e Elem) bool {
+	switch e := e.(type) {
+	case *Struct:
+		for i := range e.Fields {
+			if sharesMemory(e.Fields[i].fieldElem) {
+				return true
+			}
+		}
+		return false
+	case *Array:
+		return sharesMemory(e.Els)
+	case *Slice, *Map, *Ptr:
+		return true
+	case *BaseElem:
+		return e.Value == IDENT || e.Value == Raw || ((e.Value == Bytes || e.Value == StrBytes) && e.ShimToBase == "")
+	}
+	return false
+}
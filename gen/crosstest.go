@@ -0,0 +1,119 @@
+package gen
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"io"
+	"text/template"
+)
+
+// crossTestTag is the build tag of the cross tests file, so that the tests are built only when
+// asked for and the package does not otherwise depend on github.com/vmihailenco/msgpack.
+const crossTestTag = "msgpcross"
+
+var crossTestTempl = template.New("CrossTest")
+
+func crosstest(w io.Writer, o *options, equal bool) *crossTestGen {
+	return &crossTestGen{w: w, o: o, equal: equal}
+}
+
+// A crossTestGen prints the tests checking that github.com/vmihailenco/msgpack decodes the messages
+// of MarshalMsg and that UnmarshalMsg decodes the messages of msgpack, for the structs having only
+// fields of primitive types. The other library reads the names of the fields from the msgp tags.
+type crossTestGen struct {
+	passes
+	w     io.Writer
+	o     *options
+	equal bool // whether the types have Equal methods
+}
+
+func (c *crossTestGen) Method() Method { return marshaltest }
+
+func (c *crossTestGen) Execute(p Elem) error {
+	p = c.applyAll(p)
+	if p == nil || !isPrintable(p) {
+		return nil
+	}
+	s, ok := p.(*Struct)
+	if !ok || !crossTestable(s, c.o.filled[s.TypeName()]) || c.o.externalTests && !ast.IsExported(s.TypeName()) {
+		return nil
+	}
+	return executeTest(crossTestTempl, c.w, p, c.o, c.equal)
+}
+
+// crossTestable says if the encoding of s can be reproduced by another library: s has fields, all
+// of which are of primitive types and have no options in their tags, and its keys are strings.
+// Unless the tested value is filled, s has no byte slices either: the library encodes a nil slice
+// as nil, which the decoders do not read into byte slices.
+func crossTestable(s *Struct, filled bool) bool {
+	if len(s.Fields) == 0 || s.Union || s.IntKeys {
+		return false
+	}
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if f.required || f.dflt != "" || f.asArray || f.omitZero {
+			return false
+		}
+		be, ok := f.fieldElem.(*BaseElem)
+		if !ok || be.ShimToBase != "" {
+			return false
+		}
+		switch be.Value {
+		case Bytes:
+			if !filled {
+				return false
+			}
+		case String, Bool, Float32, Float64, Int, Int8, Int16, Int32, Int64,
+			Uint, Uint8, Uint16, Uint32, Uint64, Byte:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// crossTestConstraint returns the build constraint of the cross tests file for a package whose
+// files have the constraint x, which may be nil.
+func crossTestConstraint(x constraint.Expr) constraint.Expr {
+	tag := &constraint.TagExpr{Tag: crossTestTag}
+	if x == nil {
+		return tag
+	}
+	return &constraint.AndExpr{X: tag, Y: x}
+}
+
+func init() {
+	template.Must(crossTestTempl.Parse(`func TestCross{{.Name}}(t *testing.T) {
+	v := {{.Value}}
+	bts, err := v.MarshalMsg(nil)
+	if err != nil {
+		t.Fatalf("MarshalMsg: %s", err)
+	}
+	var out {{.TypeName}}
+	dec := msgpack.NewDecoder(bytes.NewReader(bts))
+	dec.SetCustomStructTag("msgp")
+	if err := dec.Decode(&out); err != nil {
+		t.Fatalf("msgpack could not decode the message of MarshalMsg: %s", err)
+	}
+	if !{{.Equal}} {
+		t.Error("msgpack decoded a different value than MarshalMsg encoded")
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("msgp"){{if .Tuple}}
+	enc.UseArrayEncodedStructs(true){{end}}
+	if err := enc.Encode(&v); err != nil {
+		t.Fatalf("msgpack: %s", err)
+	}
+	out = {{.TypeName}}{}
+	if _, err := out.UnmarshalMsg(buf.Bytes()); err != nil {
+		t.Fatalf("UnmarshalMsg could not decode the message of msgpack: %s", err)
+	}
+	if !{{.Equal}} {
+		t.Error("UnmarshalMsg decoded a different value than msgpack encoded")
+	}
+}
+
+`))
+}
@@ -5,16 +5,24 @@ import (
 	"strconv"
 )
 
-func decode(w io.Writer) *decodeGen {
+func decode(w io.Writer, ids *identGen, keySwitch int, oldSpec, lenientFloats, lenientBools, wrap bool) *decodeGen {
 	return &decodeGen{
-		p: printer{w: w},
+		p:             printer{w: w, ids: ids, wrap: wrap},
+		keySwitch:     keySwitch,
+		oldSpec:       oldSpec,
+		lenientFloats: lenientFloats,
+		lenientBools:  lenientBools,
 	}
 }
 
 type decodeGen struct {
 	passes
-	p        printer
-	hasField bool
+	p             printer
+	hasField      bool
+	keySwitch     int  // the number of fields from which keys are matched by length and bytes
+	oldSpec       bool // read 'str' and 'bin' objects interchangeably
+	lenientFloats bool // read integers into float64s
+	lenientBools  bool // read the integers 0 and 1 into bools
 }
 
 func (d *decodeGen) Method() Method { return Decode }
@@ -37,8 +45,10 @@ func (d *decodeGen) Execute(p Elem) error {
 
 	d.p.printf("\nfunc (%s %s) DecodeMsg(dc *msgp.Reader) (err error) {", p.Varname(), methodReceiver(p))
 	next(d, p)
+	_, post := hooks(p)
+	d.p.callHook(p.Varname(), post)
 	d.p.nakedReturn()
-	unsetReceiver(p)
+	unsetReceiver(p, d.p.ids.recv)
 	return d.p.err
 }
 
@@ -58,53 +68,104 @@ func (d *decodeGen) assignAndCheck(name, typ string) {
 		return
 	}
 	d.p.printf("\n%s, err = dc.Read%s()", name, typ)
-	d.p.print(errCheck)
+	d.p.errCheck()
 }
 
 func (d *decodeGen) structAsTuple(s *Struct) {
-	sz := randIdent()
+	sz := d.p.newIdent(s.Varname() + " size")
 	d.p.declare(sz, u32)
 	d.assignAndCheck(sz, arrayHeader)
+	if s.TupleCompat {
+		for i := range s.Fields {
+			if !d.p.ok() {
+				return
+			}
+			// A "field" variable declared within the block is not visible after it.
+			hasField := d.hasField
+			d.p.printf("\nif %[1]s > 0 {\n%[1]s--", sz)
+			d.field(s, i)
+			d.hasField = hasField
+			d.p.print("\n} else {")
+			d.p.missingField(s, i)
+			d.p.closeBlock()
+		}
+		d.p.printf("\nfor ; %[1]s > 0; %[1]s-- {\nerr = dc.Skip()", sz)
+		d.p.errCheck()
+		d.p.closeBlock()
+		return
+	}
 	d.p.arrayCheck(strconv.Itoa(len(s.Fields)), sz)
 	for i := range s.Fields {
 		if !d.p.ok() {
 			return
 		}
-		next(d, s.Fields[i].fieldElem)
+		d.field(s, i)
 	}
 }
 
+// field prints the decoding of field i of s.
+func (d *decodeGen) field(s *Struct, i int) {
+	d.p.enter(strconv.Quote(s.Fields[i].fieldName))
+	next(d, s.Fields[i].fieldElem)
+	d.p.leave()
+}
+
 func (d *decodeGen) structAsMap(s *Struct) {
 
-	if !d.hasField {
+	if !d.hasField && !s.IntKeys {
 		d.p.declare("field", "[]byte")
 		d.hasField = true
 	}
 
 	// Declare the variable that will contain the map length.
-	sz := randIdent()
+	sz := d.p.newIdent(s.Varname() + " size")
 	d.p.declare(sz, u32)
 
 	// Assign to the sz variable the length of the map.
 	d.assignAndCheck(sz, mapHeader)
+	if s.Union {
+		d.p.unionCheck(s, sz)
+	}
 
-	d.p.printf("\nfor %s > 0 {", sz)
-	d.p.printf("\n%s--", sz)
-	d.assignAndCheck("field", mapKey)
-	d.p.print("\nswitch string(field) {")
+	seen := d.p.declareSeen(s)
+
+	var idx string
+	if s.IntKeys {
+		key := d.p.newIdent(s.Varname() + " key")
+		d.p.declare(key, "int64")
+		d.p.printf("\nfor %s > 0 {", sz)
+		d.p.printf("\n%s--", sz)
+		d.assignAndCheck(key, "Int64")
+		d.p.printf("\nswitch %s {", key)
+	} else {
+		d.p.printf("\nfor %s > 0 {", sz)
+		d.p.printf("\n%s--", sz)
+		d.assignAndCheck("field", mapKey)
+		idx = d.p.fieldSwitch(s, d.keySwitch)
+	}
 	for i := range s.Fields {
-		d.p.printf("\ncase \"%s\":", s.Fields[i].fieldTag)
-		next(d, s.Fields[i].fieldElem)
+		d.p.fieldCase(idx, s, i)
+		d.p.markSeen(seen, s, i)
+		d.field(s, i)
 		if !d.p.ok() {
 			return
 		}
 	}
-	d.p.print("\ndefault:\nerr = dc.Skip()")
-	d.p.print(errCheck)
+	d.p.reservedCase(idx, s, "\nerr = dc.Skip()")
+	if s.Union {
+		d.p.unionKey(s)
+	} else {
+		d.p.print("\ndefault:\nerr = dc.Skip()")
+		d.p.errCheck()
+	}
 
 	d.p.closeBlock() // close switch block
 	d.p.closeBlock() // close for loop
 
+	if seen != "" {
+		d.p.checkSeen(seen, s)
+	}
+
 }
 
 func (d *decodeGen) gBase(b *BaseElem) {
@@ -117,25 +178,33 @@ func (d *decodeGen) gBase(b *BaseElem) {
 	if b.Convert {
 		// Open 'tmp' block.
 		d.p.print("\n{")
-		tmp = randIdent()
+		tmp = d.p.newIdent(b.Varname() + " base")
 		d.p.declare(tmp, b.BaseType())
 	}
 
-	vname := b.Varname()  // e.g. "z.FieldOne"
-	bname := b.BaseName() // e.g. "Float64"
+	vname := b.Varname()                       // e.g. "z.FieldOne"
+	bname := readName(b.BaseName(), d.oldSpec) // e.g. "Float64"
+	switch {
+	case b.Value == StrBytes:
+		bname = readName("Bytes", true) // read from a 'str' or a 'bin' object
+	case b.Value == Float64 && d.lenientFloats, b.Value == Bool && d.lenientBools:
+		bname += "Lenient"
+	}
 
 	// Handle special cases for object type.
 	switch b.Value {
-	case Bytes:
+	case Bytes, Raw, StrBytes:
 		if b.Convert {
-			d.p.printf("\n%s, err = dc.ReadBytes([]byte(%s))", tmp, vname)
+			d.p.printf("\n%s, err = dc.Read%s([]byte(%s))", tmp, bname, vname)
 		} else {
-			d.p.printf("\n%s, err = dc.ReadBytes(%s)", vname, vname)
+			d.p.printf("\n%s, err = dc.Read%s(%s)", vname, bname, vname)
 		}
 	case IDENT:
 		d.p.printf("\nerr = %s.DecodeMsg(dc)", vname)
-	case Ext:
-		d.p.printf("\nerr = dc.ReadExtension(%s)", vname)
+	case Ext, Binary, Text, Decimal:
+		d.p.printf("\nerr = dc.Read%s(%s)", bname, vname)
+	case Padded:
+		d.p.printf("\n%s, err = dc.ReadPaddedString(%s)", vname, b.paddingExpr())
 	default:
 		if b.Convert {
 			d.p.printf("\n%s, err = dc.Read%s()", tmp, bname)
@@ -143,7 +212,7 @@ func (d *decodeGen) gBase(b *BaseElem) {
 			d.p.printf("\n%s, err = dc.Read%s()", vname, bname)
 		}
 	}
-	d.p.print(errCheck)
+	d.p.errCheck()
 
 	if b.Convert {
 		// Close 'tmp' block.
@@ -151,7 +220,7 @@ func (d *decodeGen) gBase(b *BaseElem) {
 			d.p.printf("\n%s = %s(%s)\n}", vname, b.FromBase(), tmp)
 		} else {
 			d.p.printf("\n%s, err = %s(%s)\n}", vname, b.FromBase(), tmp)
-			d.p.print(errCheck)
+			d.p.errCheck()
 		}
 	}
 
@@ -161,7 +230,13 @@ func (d *decodeGen) gMap(m *Map) {
 	if !d.p.ok() {
 		return
 	}
-	sz := randIdent()
+	// The fast paths read only 'str' keys.
+	if fast := m.fastPath(); fast != "" && !d.oldSpec {
+		d.p.printf("\n%[1]s, err = dc.Read%[2]s(%[1]s)", m.Varname(), fast)
+		d.p.errCheck()
+		return
+	}
+	sz := d.p.newIdent(m.Varname() + " size")
 
 	// resize or allocate map
 	d.p.declare(sz, u32)
@@ -173,8 +248,10 @@ func (d *decodeGen) gMap(m *Map) {
 	d.p.printf("\nfor %s > 0 {\n%s--", sz, sz)
 	d.p.declare(m.KeyIndx, "string")
 	d.p.declare(m.ValIndx, m.Value.TypeName())
-	d.assignAndCheck(m.KeyIndx, stringTyp)
+	d.assignAndCheck(m.KeyIndx, mapStrKey)
+	d.p.enter(m.KeyIndx)
 	next(d, m.Value)
+	d.p.leave()
 	d.p.mapAssign(m)
 	d.p.closeBlock()
 }
@@ -183,7 +260,7 @@ func (d *decodeGen) gSlice(s *Slice) {
 	if !d.p.ok() {
 		return
 	}
-	sz := randIdent()
+	sz := d.p.newIdent(s.Varname() + " size")
 	d.p.declare(sz, u32)
 	d.assignAndCheck(sz, arrayHeader)
 	d.p.resizeSlice(sz, s)
@@ -197,11 +274,11 @@ func (d *decodeGen) gArray(a *Array) {
 
 	// special case if we have [const]byte
 	if be, ok := a.Els.(*BaseElem); ok && (be.Value == Byte || be.Value == Uint8) {
-		d.p.printf("\nerr = dc.ReadExactBytes((%s)[:])", a.Varname())
-		d.p.print(errCheck)
+		d.p.printf("\nerr = dc.Read%s((%s)[:])", readName("ExactBytes", d.oldSpec || a.Str), a.Varname())
+		d.p.errCheck()
 		return
 	}
-	sz := randIdent()
+	sz := d.p.newIdent(a.Varname() + " size")
 	d.p.declare(sz, u32)
 	d.assignAndCheck(sz, arrayHeader)
 	d.p.arrayCheck(coerceArraySize(a.Size), sz)
@@ -215,7 +292,7 @@ func (d *decodeGen) gPtr(p *Ptr) {
 	}
 	d.p.print("\nif dc.IsNil() {")
 	d.p.print("\nerr = dc.ReadNil()")
-	d.p.print(errCheck)
+	d.p.errCheck()
 	d.p.printf("\n%s = nil\n} else {", p.Varname())
 	d.p.initPtr(p)
 	next(d, p.Value)
@@ -3,6 +3,9 @@ package gen
 import (
 	"fmt"
 	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
 	"strings"
 )
 
@@ -12,15 +15,27 @@ const linePrefix = "//msgp:"
 // a *source to work with.
 type directive func([]string, *source) error
 
-// func(passName, args, generatorSet)
-type passDirective func(Method, []string, generatorSet) error
+// func(passName, args, passList)
+type passDirective func(Method, []string, *passList) error
 
 // directives lists all recognized directives.
 // To add a directive, define a `directive` func and add it to this list.
 var directives = map[string]directive{
-	"shim":   applyShim,
-	"ignore": ignore,
-	"tuple":  astuple,
+	"shim":             applyShim,
+	"ignore":           ignore,
+	"ignorefields":     ignoreFields,
+	"tuple":            astuple,
+	"tuple-compat":     astupleCompat,
+	"inline":           setInline(true),
+	"noinline":         setInline(false),
+	"receiver":         setReceiver,
+	"pointer-receiver": setReceiverKind(pointerReceiver),
+	"value-receiver":   setReceiverKind(valueReceiver),
+	"union":            asunion,
+	"reserved":         reserve,
+	"intkeys":          intkeys,
+	"fixedints":        fixedints,
+	"hooks":            setHooks,
 }
 
 // passDirectives lists the directives that can be used with a named pass.
@@ -29,28 +44,78 @@ var passDirectives = map[string]passDirective{
 	"ignore": passIgnore,
 }
 
-func passIgnore(m Method, typeNamePatterns []string, gs generatorSet) error {
+// A passList holds the passes given by the directives for named passes. The directives are
+// applied once, and the passes are added to the generatorSet of each type being printed.
+type passList []methodPass
+
+type methodPass struct {
+	m Method
+	t TransformPass
+}
+
+// ApplyDirective records a pass for the named pass and all of its dependents.
+func (pl *passList) ApplyDirective(pass Method, t TransformPass) {
+	*pl = append(*pl, methodPass{pass, t})
+}
+
+// addTo adds the passes to gs.
+func (pl passList) addTo(gs *generatorSet) {
+	for _, mp := range pl {
+		gs.ApplyDirective(mp.m, mp.t)
+	}
+}
+
+func passIgnore(m Method, typeNamePatterns []string, pl *passList) error {
 	pushState(m.String())
 	for _, tn := range typeNamePatterns {
-		gs.ApplyDirective(m, IgnoreTypename(tn))
+		pl.ApplyDirective(m, IgnoreTypename(tn))
 		infof("ignoring %s\n", tn)
 	}
 	popState()
 	return nil
 }
 
+// A dirLine is a directive: a comment line beginning with //msgp: (without the prefix) and its
+// position, which locates the warnings about the directive.
+type dirLine struct {
+	text string
+	pos  token.Position
+}
+
 // getComments finds all comment lines that begin with //msgp:
-func getComments(c []*ast.CommentGroup) (comments []string) {
+func getComments(fset *token.FileSet, c []*ast.CommentGroup) (comments []dirLine) {
 	for _, cg := range c {
 		for _, line := range cg.List {
 			if strings.HasPrefix(line.Text, linePrefix) {
-				comments = append(comments, strings.TrimPrefix(line.Text, linePrefix))
+				comments = append(comments, dirLine{strings.TrimPrefix(line.Text, linePrefix), fset.Position(line.Pos())})
 			}
 		}
 	}
 	return
 }
 
+// loadShims returns the shim directives in the Go source file at path.
+func loadShims(path string) ([]dirLine, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	pushState(path)
+	defer popState()
+	var shims []dirLine
+	for _, d := range getComments(fset, f.Comments) {
+		if strings.HasPrefix(d.text, "shim ") {
+			shims = append(shims, d)
+		} else {
+			pushStateAt("", d.pos)
+			warnf("ignoring directive %q; only shims are loaded from shim files\n", linePrefix+d.text)
+			popState()
+		}
+	}
+	return shims, nil
+}
+
 // applyShim applies a shim of the form:
 // msgp:shim {Type} as:{Newtype} using:{toFunc/fromFunc} mode:{Mode}
 // though the mode argument is optional.
@@ -114,6 +179,45 @@ func ignore(text []string, s *source) error {
 	return nil
 }
 
+//msgp:ignorefields {Type} {fieldA} {fieldB}...
+// The fields are left out of the struct type as if they were tagged `msgp:"-"`, for the structs
+// whose tags cannot be changed (such as those of generated code). The fields are given by their
+// Go names (the type names for embedded fields), either exactly or as regexp patterns like the
+// type names of the ignore directive.
+func ignoreFields(text []string, s *source) error {
+	if len(text) < 3 {
+		return fmt.Errorf("ignorefields directive should have at least 2 arguments; found %d", len(text)-1)
+	}
+	name := strings.TrimSpace(text[1])
+	el, ok := s.identities[name]
+	if !ok {
+		warnf("%s: type not found\n", name)
+		return nil
+	}
+	st, ok := el.(*Struct)
+	if !ok {
+		warnf("%s: only structs have fields to ignore\n", name)
+		return nil
+	}
+	for _, item := range text[2:] {
+		pattern := strings.TrimSpace(item)
+		// The fields are not filtered in place, since other types may share them.
+		kept := make([]structField, 0, len(st.Fields))
+		for _, f := range st.Fields {
+			if typeNameMatches(pattern, f.fieldName) {
+				infof("ignoring %s.%s\n", name, f.fieldName)
+			} else {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) == len(st.Fields) {
+			warnf("%s: no field matches %q\n", name, pattern)
+		}
+		st.Fields = kept
+	}
+	return nil
+}
+
 //msgp:tuple {TypeA} {TypeB}...
 func astuple(text []string, s *source) error {
 	if len(text) < 2 {
@@ -132,3 +236,345 @@ func astuple(text []string, s *source) error {
 	}
 	return nil
 }
+
+//msgp:tuple-compat {TypeA} {TypeB}...
+// The types are encoded as tuples, but the decoders accept arrays with more elements than the
+// struct has fields (skipping the extra elements) and arrays with fewer elements (zeroing the
+// missing trailing fields).
+func astupleCompat(text []string, s *source) error {
+	if len(text) < 2 {
+		return nil
+	}
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		if el, ok := s.identities[name]; ok {
+			if st, ok := el.(*Struct); ok {
+				st.AsTuple = true
+				st.TupleCompat = true
+				infoln(name)
+			} else {
+				warnf("%s: only structs can be tuples\n", name)
+			}
+		}
+	}
+	return nil
+}
+
+//msgp:union {TypeA} {TypeB}...
+// The types, structs whose fields are all pointers, are unions holding at most one of the values
+// of the fields. A union is written as a map of one entry, holding the key and the value of the
+// field that is not nil (or of no entries if all of the fields are nil), and the fields left out
+// are set to nil when decoding. Encoding a union with more than one field set, or decoding one
+// from a map with more entries or with the key of none of the fields, fails with a
+// msgp.UnionError. The methods setting and getting the fields are printed with the union.
+func asunion(text []string, s *source) error {
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		el, ok := s.identities[name]
+		if !ok {
+			warnf("%s: type not found\n", name)
+			continue
+		}
+		st, ok := el.(*Struct)
+		if !ok || len(st.Fields) == 0 || st.AsTuple {
+			warnf("%s: only structs written as maps can be unions\n", name)
+			continue
+		}
+		if st.IntKeys {
+			warnf("%s: unions cannot have integer keys\n", name)
+			continue
+		}
+		for i := range st.Fields {
+			if _, ok = st.Fields[i].fieldElem.(*Ptr); !ok {
+				break
+			}
+		}
+		if !ok {
+			warnf("%s: the fields of a union must be pointers\n", name)
+			continue
+		}
+		if a, b := sameExportedNames(st.Fields); a != "" {
+			warnf("%s: the fields %s and %s would both be set with Set%s\n", name, a, b, exportedName(a))
+			continue
+		}
+		st.Union = true
+		for i := range st.Fields {
+			st.Fields[i].zero = "%[1]s == nil"
+		}
+		infoln(name)
+	}
+	return nil
+}
+
+//msgp:reserved {Type} {key1} {key2}...
+// The keys, of fields removed from the struct type, are recognized and skipped by the decoders
+// (even for unions, which otherwise fail on unknown keys). A key used by a field of the type is
+// reported, so that the keys of removed fields are not reused with different values.
+func reserve(text []string, s *source) error {
+	if len(text) < 3 {
+		return fmt.Errorf("reserved directive should have at least 2 arguments; found %d", len(text)-1)
+	}
+	name := strings.TrimSpace(text[1])
+	el, ok := s.identities[name]
+	if !ok {
+		warnf("%s: type not found\n", name)
+		return nil
+	}
+	st, ok := el.(*Struct)
+	if !ok || st.AsTuple {
+		warnf("%s: only structs written as maps have keys to reserve\n", name)
+		return nil
+	}
+	for _, item := range text[2:] {
+		key := strings.TrimSpace(item)
+		if st.IntKeys {
+			if _, err := strconv.ParseInt(key, 10, 64); err != nil {
+				warnf("%s: the key %q is not an integer\n", name, key)
+				continue
+			}
+			key = canonicalInt(key)
+		}
+		if field := fieldWithKey(st, key); field != "" {
+			warnf("%s: the key %q is reserved but used by the field %s\n", name, key, field)
+			continue
+		}
+		if !isReserved(st, key) {
+			st.Reserved = append(st.Reserved, key)
+		}
+	}
+	infof("%s: %s\n", name, strings.Join(st.Reserved, " "))
+	return nil
+}
+
+// fieldWithKey returns the name of the field of s with the key, or an empty string if there is none.
+func fieldWithKey(s *Struct, key string) string {
+	for i := range s.Fields {
+		if s.Fields[i].fieldTag == key {
+			return s.Fields[i].fieldName
+		}
+	}
+	return ""
+}
+
+// isReserved says if the key is already reserved for s.
+func isReserved(s *Struct, key string) bool {
+	for _, k := range s.Reserved {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+//msgp:intkeys {TypeA} {TypeB}...
+// The struct types are written as maps keyed by the integers given as the names in the tags of
+// the fields (as in `msgp:"1"`) rather than by strings, which makes their encodings smaller. All
+// of the keys (including the reserved ones) must be integers; otherwise, the keys are left strings.
+func intkeys(text []string, s *source) error {
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		el, ok := s.identities[name]
+		if !ok {
+			warnf("%s: type not found\n", name)
+			continue
+		}
+		st, ok := el.(*Struct)
+		if !ok || st.AsTuple || st.Union {
+			warnf("%s: only structs written as maps, other than unions, can have integer keys\n", name)
+			continue
+		}
+		if bad := nonIntKey(st); bad != "" {
+			warnf("%s: the key %q is not an integer; keeping string keys\n", name, bad)
+			continue
+		}
+		// The keys are printed in the cases of the decoders, where "010" would be octal.
+		for i := range st.Fields {
+			st.Fields[i].fieldTag = canonicalInt(st.Fields[i].fieldTag)
+		}
+		for i := range st.Reserved {
+			st.Reserved[i] = canonicalInt(st.Reserved[i])
+		}
+		st.IntKeys = true
+		infoln(name)
+	}
+	return nil
+}
+
+// nonIntKey returns a key of a field of s, or a reserved key of s, that is not an integer, or an
+// empty string if all of the keys are integers.
+func nonIntKey(s *Struct) string {
+	for i := range s.Fields {
+		if _, err := strconv.ParseInt(s.Fields[i].fieldTag, 10, 64); err != nil {
+			return s.Fields[i].fieldTag
+		}
+	}
+	for _, k := range s.Reserved {
+		if _, err := strconv.ParseInt(k, 10, 64); err != nil {
+			return k
+		}
+	}
+	return ""
+}
+
+// canonicalInt returns the decimal integer in key (which is known to be one) without a plus sign or
+// leading zeros, like the integer constants in Go.
+func canonicalInt(key string) string {
+	k, _ := strconv.ParseInt(key, 10, 64)
+	return strconv.FormatInt(k, 10)
+}
+
+//msgp:fixedints {TypeA} {TypeB}...
+// The integers of the types (including those in pointers, slices, arrays, and map values) are
+// written as int64s and uint64s of 8 bytes, whatever their values, rather than in the fewest
+// bytes, so that the size of a message does not depend on the values and the integers can be
+// overwritten in place. They are read as any other integers.
+func fixedints(text []string, s *source) error {
+	for _, item := range text[1:] {
+		name := strings.TrimSpace(item)
+		el, ok := s.identities[name]
+		if !ok {
+			warnf("%s: type not found\n", name)
+			continue
+		}
+		if !markFixedInts(el) {
+			warnf("%s: the type has no integers\n", name)
+			continue
+		}
+		infoln(name)
+	}
+	return nil
+}
+
+// markFixedInts makes the integers of e be written in 8 bytes and says if e has any. The arrays of
+// bytes, which are written as binary data, and the named types, which are written by their own
+// methods, are left alone.
+func markFixedInts(e Elem) bool {
+	switch e := e.(type) {
+	case *BaseElem:
+		if !isSigned(e.Value) && !isUnsigned(e.Value) {
+			return false
+		}
+		e.fixedInt = true
+		return true
+	case *Struct:
+		var marked bool
+		for i := range e.Fields {
+			if markFixedInts(e.Fields[i].fieldElem) {
+				marked = true
+			}
+		}
+		return marked
+	case *Ptr:
+		return markFixedInts(e.Value)
+	case *Slice:
+		return markFixedInts(e.Els)
+	case *Array:
+		if b, ok := e.Els.(*BaseElem); ok && b.Value == Byte {
+			return false
+		}
+		return markFixedInts(e.Els)
+	case *Map:
+		return markFixedInts(e.Value)
+	default:
+		return false
+	}
+}
+
+//msgp:inline {TypeA} {TypeB}...
+//msgp:noinline {TypeA} {TypeB}...
+// The inline directive makes the types always be inlined into the methods of the types referring
+// to them, whatever their complexity; noinline makes the types never be inlined. Types are never
+// inlined into themselves, even through other types.
+func setInline(inline bool) directive {
+	return func(text []string, s *source) error {
+		for _, item := range text[1:] {
+			name := strings.TrimSpace(item)
+			if _, ok := s.identities[name]; !ok {
+				warnf("%s: type not found\n", name)
+				continue
+			}
+			s.inline[name] = inline
+			infoln(name)
+		}
+		return nil
+	}
+}
+
+//msgp:receiver {Type} {name}
+// The methods of the type are printed with receivers of the given name rather than the name set
+// with the Receiver option.
+func setReceiver(text []string, s *source) error {
+	if len(text) != 3 {
+		return fmt.Errorf("receiver directive should have 2 arguments; found %d", len(text)-1)
+	}
+	name, recv := strings.TrimSpace(text[1]), strings.TrimSpace(text[2])
+	if _, ok := s.identities[name]; !ok {
+		warnf("%s: type not found\n", name)
+		return nil
+	}
+	s.receivers[name] = recv
+	infof("%s: %s\n", name, recv)
+	return nil
+}
+
+//msgp:pointer-receiver {TypeA} {TypeB}...
+//msgp:value-receiver {TypeA} {TypeB}...
+// The methods of the struct types that do not change the values (such as EncodeMsg, MarshalMsg,
+// and Msgsize) are printed with pointer or value receivers, rather than the kind picked by the
+// number and types of the fields. The methods decoding the values always have pointer receivers.
+func setReceiverKind(kind receiverKind) directive {
+	return func(text []string, s *source) error {
+		for _, item := range text[1:] {
+			name := strings.TrimSpace(item)
+			el, ok := s.identities[name]
+			if !ok {
+				warnf("%s: type not found\n", name)
+				continue
+			}
+			st, ok := el.(*Struct)
+			if !ok {
+				warnf("%s: only the receivers of structs can be chosen\n", name)
+				continue
+			}
+			st.Receiver = kind
+			infoln(name)
+		}
+		return nil
+	}
+}
+
+//msgp:hooks {Type} pre:{method} post:{method}
+// The methods of the struct type, which take no arguments and return an error, are called by
+// EncodeMsg and MarshalMsg before writing a value (such as to set derived fields) and by
+// DecodeMsg and UnmarshalMsg after reading one (such as to check invariants). Either hook can be
+// left out. The type is never inlined, so that the hooks are called for the fields of its type.
+func setHooks(text []string, s *source) error {
+	if len(text) < 3 || len(text) > 4 {
+		return fmt.Errorf("hooks directive should have 2 or 3 arguments; found %d", len(text)-1)
+	}
+	name := strings.TrimSpace(text[1])
+	el, ok := s.identities[name]
+	if !ok {
+		warnf("%s: type not found\n", name)
+		return nil
+	}
+	st, ok := el.(*Struct)
+	if !ok {
+		warnf("%s: only structs can have hooks\n", name)
+		return nil
+	}
+	for _, item := range text[2:] {
+		item = strings.TrimSpace(item)
+		switch {
+		case strings.HasPrefix(item, "pre:"):
+			st.PreHook = strings.TrimPrefix(item, "pre:")
+		case strings.HasPrefix(item, "post:"):
+			st.PostHook = strings.TrimPrefix(item, "post:")
+		default:
+			return fmt.Errorf("hooks directive: %q is neither pre:{method} nor post:{method}", item)
+		}
+	}
+	s.inline[name] = false
+	infof("%s: pre %q, post %q\n", name, st.PreHook, st.PostHook)
+	return nil
+}
@@ -2,23 +2,81 @@ package gen
 
 import (
 	"fmt"
+	"go/token"
+	"hash/fnv"
 	"strings"
-)
 
-var (
-	identNext   = 0
-	identPrefix = "za"
+	"github.com/dchenk/msgp/msgp"
 )
 
-func resetIdent(prefix string) {
-	identPrefix = prefix
-	identNext = 0
+// An identGen generates the names of the variables declared in the generated code. Types are
+// printed concurrently, so each type being printed has its own identGen.
+type identGen struct {
+	base   string         // the prefix of all of the names
+	prefix string         // the prefix of the names being generated: base and a letter
+	next   int            // the number of the last name generated with a counter
+	stable bool           // derive the names from the keys rather than a counter
+	used   map[string]int // the number of times each stable name has been generated
+	recv   string         // the name of the receivers of the methods being printed
+}
+
+// defaultIdents is used by the elements that are not being printed.
+var defaultIdents = &identGen{base: "z", prefix: "za"}
+
+// reset starts generating names with the prefix made of the base and the given letter.
+func (g *identGen) reset(letter string) {
+	g.prefix = g.base + letter
+	g.next = 0
+	g.used = nil
+}
+
+// newIdent generates a name for a variable. The key identifies what the variable is for, usually
+// with the name of the variable holding the element the variable is declared for. With stable
+// names, the name is derived from a hash of the key, so that changes to other parts of a type do
+// not change it; otherwise the names are numbered in the order in which they are generated.
+func (g *identGen) newIdent(key string) string {
+	if !g.stable {
+		g.next++
+		return fmt.Sprintf("%s%04d", g.prefix, g.next)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	name := fmt.Sprintf("%s%08x", g.prefix, h.Sum32())
+	if g.used == nil {
+		g.used = make(map[string]int)
+	}
+	// A repeated key (or a collision) is told apart by the number of times the name was used.
+	n := g.used[name]
+	g.used[name] = n + 1
+	if n > 0 {
+		name = fmt.Sprintf("%s_%d", name, n)
+	}
+	return name
 }
 
-// randIdent generates a random identifier name.
-func randIdent() string {
-	identNext++
-	return fmt.Sprintf("%s%04d", identPrefix, identNext)
+// setIdents makes e and all of its children use g to generate identifiers.
+func setIdents(e Elem, g *identGen) {
+	switch e := e.(type) {
+	case *Struct:
+		e.ids = g
+		for i := range e.Fields {
+			setIdents(e.Fields[i].fieldElem, g)
+		}
+	case *Array:
+		e.ids = g
+		setIdents(e.Els, g)
+	case *Slice:
+		e.ids = g
+		setIdents(e.Els, g)
+	case *Map:
+		e.ids = g
+		setIdents(e.Value, g)
+	case *Ptr:
+		e.ids = g
+		setIdents(e.Value, g)
+	case *BaseElem:
+		e.ids = g
+	}
 }
 
 // This code defines the type declaration tree.
@@ -89,6 +147,30 @@ const (
 	Time // time.Time
 	Ext  // extension
 
+	// Binary is a named type implementing encoding.BinaryMarshaler and BinaryUnmarshaler written
+	// as a 'bin' object (with the binary option).
+	Binary
+
+	// Text is a named type implementing encoding.TextMarshaler and TextUnmarshaler written as a
+	// 'str' object (with the text option).
+	Text
+
+	// Raw is a byte slice holding an encoded object (with the raw option), written and read as it
+	// is with the RawField functions.
+	Raw
+
+	// StrBytes is a byte slice written as a 'str' object (with the str option) and read from
+	// either a 'str' or a 'bin' object.
+	StrBytes
+
+	// Decimal is a named type implementing msgp.DecimalMarshaler and DecimalUnmarshaler written
+	// as a decimal extension (with the decimal option).
+	Decimal
+
+	// Padded is a string written as a 'bin' object laid out by a msgp.Padding (with the width,
+	// pad, and zeroterm options).
+	Padded
+
 	IDENT // IDENT means an unrecognized identifier
 )
 
@@ -137,6 +219,18 @@ func (k primitive) String() string {
 		return "time.Time"
 	case Ext:
 		return "Extension"
+	case Binary:
+		return "Binary"
+	case Text:
+		return "Text"
+	case Raw:
+		return "RawField"
+	case StrBytes:
+		return "StringFromBytes"
+	case Decimal:
+		return "Decimal"
+	case Padded:
+		return "PaddedString"
 	case IDENT:
 		return "Ident"
 	default:
@@ -174,17 +268,32 @@ var primitives = map[string]primitive{
 // builtIns are types built into the library
 // that satisfy all of the interfaces.
 var builtIns = map[string]struct{}{
-	"msgp.Raw":    {},
-	"msgp.Number": {},
+	"msgp.Raw":     {},
+	"msgp.Number":  {},
+	"msgp.Float16": {},
 }
 
 // common data/methods for every Elem
-type common struct{ vname, alias string }
+type common struct {
+	vname, alias string
+	ids          *identGen      // set by setIdents
+	pos          token.Position // the position of the type expression parsed
+}
 
 func (c *common) SetVarname(s string) { c.vname = s }
 func (c *common) Varname() string     { return c.vname }
 func (c *common) Alias(typ string)    { c.alias = typ }
 
+func (c *common) Pos() token.Position       { return c.pos }
+func (c *common) SetPos(pos token.Position) { c.pos = pos }
+
+func (c *common) newIdent(key string) string {
+	if c.ids == nil {
+		return defaultIdents.newIdent(key)
+	}
+	return c.ids.newIdent(key)
+}
+
 func isPrintable(e Elem) bool {
 	if be, ok := e.(*BaseElem); ok && !be.Printable() {
 		return false
@@ -216,6 +325,14 @@ type Elem interface {
 	// Complexity returns a measure of the complexity of the element (greater
 	// than or equal to 1).
 	Complexity() int
+
+	// Pos returns the position in the source of the type expression that the
+	// element was parsed from, such as the type of a field. The position is
+	// not valid for the elements not parsed from the source (such as shims).
+	Pos() token.Position
+
+	// SetPos sets the position returned by Pos.
+	SetPos(pos token.Position)
 }
 
 // Ident returns the *BaseElem that corresponds to the provided identity.
@@ -235,6 +352,7 @@ type Array struct {
 	Index string // index variable name
 	Size  string // array size
 	Els   Elem   // child
+	Str   bool   // whether an array of bytes is written as a 'str' object (with the str option)
 }
 
 // SetVarname sets the name of the array and its index variable.
@@ -242,7 +360,7 @@ func (a *Array) SetVarname(s string) {
 	a.common.SetVarname(s)
 	// Avoid using the same index as a parent slice.
 	for a.Index == "" || strings.Contains(a.Varname(), a.Index) {
-		a.Index = randIdent()
+		a.Index = a.newIdent(s + " index")
 	}
 	a.Els.SetVarname(a.Varname() + "[" + a.Index + "]")
 }
@@ -266,20 +384,22 @@ func (a *Array) Copy() Elem {
 // Complexity returns a measure of the complexity of the element.
 func (a *Array) Complexity() int { return 1 + a.Els.Complexity() }
 
-// Map is a map[string]Elem.
+// Map is a map[string]Elem, or a map whose keys are of a named type of the source declared as a
+// string, which are written as strings.
 type Map struct {
 	common
 	KeyIndx string // key variable name
 	ValIndx string // value variable name
+	Key     string // the named type of the keys, or "" for string
 	Value   Elem   // value element
 }
 
 // SetVarname sets the names of the map and the index variables.
 func (m *Map) SetVarname(s string) {
 	m.common.SetVarname(s)
-	m.KeyIndx = randIdent()
+	m.KeyIndx = m.newIdent(s + " key")
 	for m.ValIndx == "" || m.ValIndx == m.KeyIndx {
-		m.ValIndx = randIdent()
+		m.ValIndx = m.newIdent(s + " value")
 	}
 	m.Value.SetVarname(m.ValIndx)
 }
@@ -289,10 +409,27 @@ func (m *Map) TypeName() string {
 	if m.common.alias != "" {
 		return m.common.alias
 	}
-	m.common.Alias("map[string]" + m.Value.TypeName())
+	m.common.Alias("map[" + m.keyType() + "]" + m.Value.TypeName())
 	return m.common.alias
 }
 
+// keyType returns the Go type of the keys.
+func (m *Map) keyType() string {
+	if m.Key != "" {
+		return m.Key
+	}
+	return "string"
+}
+
+// keyString returns the expression of key k (which is m.KeyIndx when the map is ranged over) as a
+// string.
+func (m *Map) keyString(k string) string {
+	if m.Key != "" {
+		return "string(" + k + ")"
+	}
+	return k
+}
+
 // Copy returns a deep copy of the object.
 func (m *Map) Copy() Elem {
 	g := *m
@@ -303,6 +440,22 @@ func (m *Map) Copy() Elem {
 // Complexity returns a measure of the complexity of the element.
 func (m *Map) Complexity() int { return 2 + m.Value.Complexity() }
 
+// fastPath returns the suffix (such as "MapStrBytes") of the names of the msgp functions that read
+// and write the whole map, or an empty string if the map is read and written element by element.
+func (m *Map) fastPath() string {
+	b, ok := m.Value.(*BaseElem)
+	if !ok || b.Convert || b.ShimToBase != "" || m.Key != "" {
+		return ""
+	}
+	switch b.Value {
+	case Bytes:
+		return "MapStrBytes"
+	case Time:
+		return "MapStrTime"
+	}
+	return ""
+}
+
 // Slice represents a slice.
 type Slice struct {
 	common
@@ -313,7 +466,7 @@ type Slice struct {
 // SetVarname sets the name of the slice and its index variable.
 func (s *Slice) SetVarname(n string) {
 	s.common.SetVarname(n)
-	s.Index = randIdent()
+	s.Index = s.newIdent(n + " index")
 	vn := s.Varname()
 	if vn[0] == '*' {
 		// Pointer-to-slice requires parenthesis for slicing.
@@ -401,10 +554,27 @@ func (s *Ptr) NeedsInit() bool {
 // Struct represents a struct.
 type Struct struct {
 	common
-	Fields  []structField // field list
-	AsTuple bool          // write as an array instead of a map
+	Fields      []structField // field list
+	AsTuple     bool          // write as an array instead of a map
+	TupleCompat bool          // with AsTuple, decode arrays having more or fewer elements than Fields
+	Union       bool          // write the one non-nil field as a map of one entry (with the union directive)
+	Receiver    receiverKind  // the kind of receivers of the methods not changing the struct
+	Reserved    []string      // the keys of removed fields, skipped when decoding (with the reserved directive)
+	IntKeys     bool          // write the keys as the integers in the tags (with the intkeys directive)
+	PreHook     string        // the method called before encoding (with the hooks directive)
+	PostHook    string        // the method called after decoding (with the hooks directive)
 }
 
+// A receiverKind says if the methods of a struct that do not change it have pointer or value
+// receivers. By default, the kind is picked from the fields of the struct.
+type receiverKind uint8
+
+const (
+	autoReceiver    receiverKind = iota
+	pointerReceiver              // set with the pointer-receiver directive
+	valueReceiver                // set with the value-receiver directive
+)
+
 // TypeName returns the canonical Go type name.
 func (s *Struct) TypeName() string {
 	if s.common.alias != "" {
@@ -452,6 +622,11 @@ type structField struct {
 	rawTag    string // the full tag (in case there are non-msgp keys)
 	fieldName string // the name of the struct field
 	fieldElem Elem   // the field type
+	required  bool   // decoding fails if the field is missing
+	dflt      string // the value (a Go expression) given to the field if it is missing
+	asArray   bool   // the structs in the field are written as arrays (with the asarray option)
+	omitZero  bool   // the field is left out of the encoding if it is zero (with the omitzero option)
+	zero      string // with omitZero, the condition for leaving out the field, as a format of the field
 }
 
 // writeStructFields is a trampoline for writeBase for all of the fields in a struct.
@@ -482,6 +657,24 @@ type BaseElem struct {
 	Convert      bool      // should we do an explicit conversion?
 	mustinline   bool      // must inline; not printable
 	needsref     bool      // needs reference for shim
+
+	padding  msgp.Padding // the layout of a Padded string
+	fixedInt bool         // an integer written in 9 bytes (see the fixedints directive)
+}
+
+// paddingExpr returns the msgp.Padding literal of the layout of a Padded string.
+func (s *BaseElem) paddingExpr() string {
+	var fields []string
+	if s.padding.Width > 0 {
+		fields = append(fields, fmt.Sprintf("Width: %d", s.padding.Width))
+	}
+	if s.padding.Pad != 0 {
+		fields = append(fields, fmt.Sprintf("Pad: %#02x", s.padding.Pad))
+	}
+	if s.padding.ZeroTerm {
+		fields = append(fields, "ZeroTerm: true")
+	}
+	return "msgp.Padding{" + strings.Join(fields, ", ") + "}"
 }
 
 // Printable says if the element is printable.
@@ -500,9 +693,9 @@ func (s *BaseElem) Alias(typ string) {
 
 // SetVarname sets the name of the variable.
 func (s *BaseElem) SetVarname(a string) {
-	// Ext types whose parents are not pointers need
-	// to be explicitly referenced.
-	if s.Value == Ext || s.needsref {
+	// Ext, Binary, Text, and Decimal types whose parents are not pointers
+	// need to be explicitly referenced.
+	if s.Value == Ext || s.Value == Binary || s.Value == Text || s.Value == Decimal || s.needsref {
 		if strings.HasPrefix(a, "*") {
 			s.common.SetVarname(a[1:])
 			return
@@ -556,14 +749,16 @@ func (s *BaseElem) BaseName() string {
 // BaseType gives the name of the base type.
 func (s *BaseElem) BaseType() string {
 	switch s.Value {
-	case IDENT:
+	case IDENT, Binary, Text, Decimal:
 		return s.TypeName()
 
 	// Exceptions to the naming/capitalization rule:
 	case Intf:
 		return "interface{}"
-	case Bytes:
+	case Bytes, Raw, StrBytes:
 		return "[]byte"
+	case Padded:
+		return "string"
 	case Time:
 		return "time.Time"
 	case Ext:
@@ -615,3 +810,10 @@ func (s *BaseElem) Resolved() bool {
 func coerceArraySize(asz string) string {
 	return fmt.Sprintf("uint32(%s)", asz)
 }
+
+// arrayLenInt converts a constant array length to an int for the size computations, which would
+// not compile if the length were of a smaller integer type that the product of the length and the
+// size of an element overflows (or of a type other than int).
+func arrayLenInt(asz string) string {
+	return fmt.Sprintf("int(%s)", asz)
+}
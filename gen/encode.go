@@ -3,20 +3,35 @@ package gen
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/dchenk/msgp/msgp"
 )
 
-func encode(w io.Writer) *encodeGen {
+func encode(w io.Writer, ids *identGen, kvFields int, compactFloats bool) *encodeGen {
 	return &encodeGen{
-		p: printer{w: w},
+		p:             printer{w: w, ids: ids},
+		kvFields:      kvFields,
+		compactFloats: compactFloats,
 	}
 }
 
 type encodeGen struct {
 	passes
-	p    printer
-	fuse []byte
+	p             printer
+	fuse          []byte
+	run           []runItem // consecutive writes of bounded size to be coalesced
+	kvFields      int       // the number of fields from which structs are written with msgp.Writer.WriteKV
+	compactFloats bool      // write float64s with msgp.Writer.WriteFloat
+}
+
+// A runItem is a write in a run of writes coalesced into a single reservation of the
+// Writer's buffer. It is either raw bytes or a primitive value to append.
+type runItem struct {
+	raw  []byte
+	typ  string // the name of the primitive type (as in msgp.Append{{typ}})
+	expr string // the value to append
 }
 
 func (e *encodeGen) Method() Method { return Encode }
@@ -30,11 +45,56 @@ func (e *encodeGen) writeAndCheck(typ string, argfmt string, arg interface{}) {
 	e.p.print(errCheck)
 }
 
+// fuseHook writes out the fused bytes and the run of coalesced writes.
 func (e *encodeGen) fuseHook() {
+	if len(e.run) == 0 {
+		if len(e.fuse) > 0 {
+			e.appendRaw(e.fuse)
+			e.fuse = e.fuse[:0]
+		}
+		return
+	}
 	if len(e.fuse) > 0 {
-		e.appendRaw(e.fuse)
-		e.fuse = e.fuse[:0]
+		e.run = append(e.run, runItem{raw: e.fuse})
+		e.fuse = nil
+	}
+	e.writeRun()
+}
+
+// writeRun writes the values in the run with one reservation of the Writer's buffer.
+// A lone value is written with its Write method.
+func (e *encodeGen) writeRun() {
+	if len(e.run) == 1 {
+		e.writeAndCheck(e.run[0].typ, literalFmt, e.run[0].expr)
+		e.run = e.run[:0]
+		return
 	}
+	var rawSize int
+	var sizes []string
+	for _, it := range e.run {
+		if it.raw != nil {
+			rawSize += len(it.raw)
+		} else {
+			sizes = append(sizes, builtinSize(it.typ))
+		}
+	}
+	if rawSize > 0 {
+		sizes = append([]string{strconv.Itoa(rawSize)}, sizes...)
+	}
+	e.p.printf("\n{\nvar o []byte\no, err = en.Reserve(%s)\nif err != nil { return }", strings.Join(sizes, " + "))
+	for _, it := range e.run {
+		if it.raw != nil {
+			e.p.print("\no = append(o")
+			for _, b := range it.raw {
+				e.p.printf(", 0x%x", b)
+			}
+			e.p.print(")")
+		} else {
+			e.p.printf("\no = msgp.Append%s(o, %s)", it.typ, it.expr)
+		}
+	}
+	e.p.print("\nen.Commit(o)\n}")
+	e.run = e.run[:0]
 }
 
 func (e *encodeGen) Fuse(b []byte) {
@@ -61,7 +121,10 @@ func (e *encodeGen) Execute(p Elem) error {
 	e.p.comment("EncodeMsg implements msgp.Encoder")
 
 	e.p.printf("\nfunc (%s %s) EncodeMsg(en *msgp.Writer) (err error) {", p.Varname(), imutMethodReceiver(p))
+	pre, _ := hooks(p)
+	e.p.callHook(p.Varname(), pre)
 	next(e, p)
+	e.fuseHook()
 	e.p.nakedReturn()
 	return e.p.err
 
@@ -71,13 +134,98 @@ func (e *encodeGen) gStruct(s *Struct) {
 	if !e.p.ok() {
 		return
 	}
-	if s.AsTuple {
+	switch {
+	case s.AsTuple:
 		e.structAsTuple(s)
-	} else {
+	case kvTable(s, e.kvFields, e.compactFloats):
+		e.fuseHook()
+		e.p.printf("\n// map of %d fields", len(s.Fields))
+		e.p.print("\nerr = en.WriteKV(")
+		printKV(&e.p, s)
+		e.p.print(errCheck)
+	default:
 		e.structAsMap(s)
 	}
 }
 
+// kvTable says if the fields of s are written with msgp.Writer.WriteKV and msgp.AppendKV, in much
+// less code than by writing them one by one: s is written as a map with string keys, has at least
+// kvFields fields (if kvFields is positive), none of which can be omitted, and WriteIntf writes the
+// value of each field as the generated code would. It does not write float64s as compactFloats has
+// them written, with WriteFloat.
+func kvTable(s *Struct, kvFields int, compactFloats bool) bool {
+	if kvFields <= 0 || len(s.Fields) < kvFields || s.AsTuple || s.Union || s.IntKeys {
+		return false
+	}
+	for i := range s.Fields {
+		if _, ok := kvValue(s.Fields[i].fieldElem); !ok || s.Fields[i].zero != "" {
+			return false
+		}
+		if b := s.Fields[i].fieldElem.(*BaseElem); compactFloats && b.Value == Float64 {
+			return false
+		}
+	}
+	return true
+}
+
+// kvValue returns the value of e to put in a msgp.KV, if the value is written by WriteIntf and
+// AppendIntf as the generated code writes e. Named types are given by pointer so that the pointers
+// implement msgp.Encoder and msgp.Marshaler whatever the receivers of their methods.
+func kvValue(e Elem) (string, bool) {
+	b, ok := e.(*BaseElem)
+	if !ok || b.Convert && b.ShimMode != Cast || b.fixedInt {
+		return "", false
+	}
+	switch b.Value {
+	case Ext, Binary, Text, Raw, StrBytes, Decimal, Padded:
+		return "", false
+	case IDENT:
+		return "&" + b.Varname(), true
+	}
+	if b.Convert {
+		return b.toBaseConvert(), true
+	}
+	return b.Varname(), true
+}
+
+// writeName returns the name of the primitive type (as in msgp.Write{{name}}) with which b is
+// written: "Float" for a float64 written in the fewest bytes with compactFloats, "FixedInt64" or
+// "FixedUint64" for an integer of fixed width, and the base name of b otherwise.
+func writeName(b *BaseElem, compactFloats bool) string {
+	switch {
+	case b.fixedInt && isSigned(b.Value):
+		return "FixedInt64"
+	case b.fixedInt:
+		return "FixedUint64"
+	case compactFloats && b.Value == Float64:
+		return "Float"
+	}
+	return b.BaseName()
+}
+
+// writeArg returns the argument vname, holding the value of b, converted to the type taken by the
+// function named by writeName.
+func writeArg(b *BaseElem, vname string) string {
+	switch {
+	case !b.fixedInt || b.Value == Int64 || b.Value == Uint64:
+		return vname
+	case isSigned(b.Value):
+		return "int64(" + vname + ")"
+	default:
+		return "uint64(" + vname + ")"
+	}
+}
+
+// printKV prints the arguments, closing the call, of the call to WriteKV or AppendKV writing the
+// fields of s.
+func printKV(p *printer, s *Struct) {
+	for i := range s.Fields {
+		v, _ := kvValue(s.Fields[i].fieldElem)
+		p.printf("\nmsgp.KV{Key: %q, Value: %s},", s.Fields[i].fieldTag, v)
+	}
+	p.print("\n)")
+}
+
 func (e *encodeGen) structAsTuple(s *Struct) {
 	nfields := len(s.Fields)
 	data := msgp.AppendArrayHeader(nil, uint32(nfields))
@@ -107,9 +255,20 @@ func (e *encodeGen) appendRaw(bts []byte) {
 
 func (e *encodeGen) structAsMap(s *Struct) {
 	nfields := len(s.Fields)
-	data := msgp.AppendMapHeader(nil, uint32(nfields))
-	e.p.printf("\n// map header, size %d", nfields)
-	e.Fuse(data)
+	count, omit := e.p.omitted(s)
+	if s.Union {
+		e.p.unionCheck(s, count)
+	}
+	if count != "" {
+		// The size depends on the fields left out.
+		e.fuseHook()
+		e.p.printf("\n// map header, size %d less the fields omitted", nfields)
+		e.writeAndCheck(mapHeader, literalFmt, count)
+	} else {
+		data := msgp.AppendMapHeader(nil, uint32(nfields))
+		e.p.printf("\n// map header, size %d", nfields)
+		e.Fuse(data)
+	}
 	if len(s.Fields) == 0 {
 		e.fuseHook()
 	}
@@ -117,10 +276,19 @@ func (e *encodeGen) structAsMap(s *Struct) {
 		if !e.p.ok() {
 			return
 		}
-		data = msgp.AppendString(nil, s.Fields[i].fieldTag)
-		e.p.printf("\n// write %q", s.Fields[i].fieldTag)
+		omitted := count != "" && omit[i] != ""
+		if omitted {
+			e.fuseHook()
+			e.p.printf("\nif !%s {", omit[i])
+		}
+		data, key := fieldKey(s, i)
+		e.p.printf("\n// write %s", key)
 		e.Fuse(data)
 		next(e, s.Fields[i].fieldElem)
+		if omitted {
+			e.fuseHook()
+			e.p.closeBlock()
+		}
 	}
 }
 
@@ -130,11 +298,16 @@ func (e *encodeGen) gMap(m *Map) {
 	}
 	e.fuseHook()
 	vname := m.Varname()
+	if fast := m.fastPath(); fast != "" {
+		e.writeAndCheck(fast, literalFmt, vname)
+		return
+	}
 	e.writeAndCheck(mapHeader, lenAsUint32, vname)
 
 	e.p.printf("\nfor %s, %s := range %s {", m.KeyIndx, m.ValIndx, vname)
-	e.writeAndCheck(stringTyp, literalFmt, m.KeyIndx)
+	e.writeAndCheck(stringTyp, literalFmt, m.keyString(m.KeyIndx))
 	next(e, m.Value)
+	e.fuseHook()
 	e.p.closeBlock()
 }
 
@@ -145,6 +318,7 @@ func (e *encodeGen) gPtr(s *Ptr) {
 	e.fuseHook()
 	e.p.printf("\nif %s == nil { err = en.WriteNil(); if err != nil { return; } } else {", s.Varname())
 	next(e, s.Value)
+	e.fuseHook()
 	e.p.closeBlock()
 }
 
@@ -154,7 +328,7 @@ func (e *encodeGen) gSlice(s *Slice) {
 	}
 	e.fuseHook()
 	e.writeAndCheck(arrayHeader, lenAsUint32, s.Varname())
-	e.p.rangeBlock(s.Index, s.Varname(), e, s.Els)
+	e.rangeBlock(s.Index, s.Varname(), s.Els)
 }
 
 func (e *encodeGen) gArray(a *Array) {
@@ -164,26 +338,51 @@ func (e *encodeGen) gArray(a *Array) {
 	e.fuseHook()
 	// shortcut for [const]byte
 	if be, ok := a.Els.(*BaseElem); ok && (be.Value == Byte || be.Value == Uint8) {
-		e.p.printf("\nerr = en.WriteBytes((%s)[:])", a.Varname())
+		if a.Str {
+			e.p.printf("\nerr = en.WriteStringFromBytes((%s)[:])", a.Varname())
+		} else {
+			e.p.printf("\nerr = en.WriteBytes((%s)[:])", a.Varname())
+		}
 		e.p.print(errCheck)
 		return
 	}
 
 	e.writeAndCheck(arrayHeader, literalFmt, coerceArraySize(a.Size))
-	e.p.rangeBlock(a.Index, a.Varname(), e, a.Els)
+	e.rangeBlock(a.Index, a.Varname(), a.Els)
+}
+
+// rangeBlock is like printer.rangeBlock, but it writes out any coalesced writes
+// before closing the loop.
+func (e *encodeGen) rangeBlock(idx string, iter string, inner Elem) {
+	e.p.printf("\n for %s := range %s {", idx, iter)
+	next(e, inner)
+	e.fuseHook()
+	e.p.closeBlock()
 }
 
 func (e *encodeGen) gBase(b *BaseElem) {
 	if !e.p.ok() {
 		return
 	}
-	e.fuseHook()
 	vname := b.Varname()
+	if fixedSize(b.Value) && (!b.Convert || b.ShimMode == Cast) {
+		// The value has a bounded size, so it can be coalesced with the writes around it.
+		if b.Convert {
+			vname = b.toBaseConvert()
+		}
+		if len(e.fuse) > 0 {
+			e.run = append(e.run, runItem{raw: e.fuse})
+			e.fuse = nil
+		}
+		e.run = append(e.run, runItem{typ: writeName(b, e.compactFloats), expr: writeArg(b, vname)})
+		return
+	}
+	e.fuseHook()
 	if b.Convert {
 		if b.ShimMode == Cast {
 			vname = b.toBaseConvert()
 		} else {
-			vname = randIdent()
+			vname = e.p.newIdent(b.Varname() + " base")
 			e.p.declare(vname, b.BaseType())
 			e.p.printf("\n%s, err = %s", vname, b.toBaseConvert())
 			e.p.printf(errCheck)
@@ -193,7 +392,10 @@ func (e *encodeGen) gBase(b *BaseElem) {
 	if b.Value == IDENT { // unknown identity
 		e.p.printf("\nerr = %s.EncodeMsg(en)", vname)
 		e.p.print(errCheck)
+	} else if b.Value == Padded {
+		e.p.printf("\nerr = en.WritePaddedString(%s, %s)", vname, b.paddingExpr())
+		e.p.print(errCheck)
 	} else { // typical case
-		e.writeAndCheck(b.BaseName(), literalFmt, vname)
+		e.writeAndCheck(writeName(b, e.compactFloats), literalFmt, writeArg(b, vname))
 	}
 }
@@ -0,0 +1,157 @@
+package gen
+
+import (
+	"io"
+)
+
+func equals(w io.Writer, ids *identGen) *equalGen {
+	return &equalGen{
+		p: printer{w: w, ids: ids},
+	}
+}
+
+// An equalGen prints Equal methods, which say if two values have the same contents.
+//
+// The values are compared field by field in the order of the encoders, so fields that are not
+// encoded are not compared. Slices and maps are compared by their elements (a nil slice is equal
+// to an empty one, as both are encoded as empty arrays), and pointers by the values they point to.
+type equalGen struct {
+	passes
+	p printer
+}
+
+func (e *equalGen) Method() Method { return Equal }
+
+func (e *equalGen) Apply(dirs []string) error {
+	return nil
+}
+
+func (e *equalGen) Execute(p Elem) error {
+	if !e.p.ok() {
+		return e.p.err
+	}
+	p = e.applyAll(p)
+	if p == nil || !isPrintable(p) {
+		return nil
+	}
+
+	recv := imutMethodReceiver(p)
+	e.p.comment("Equal says if " + p.Varname() + " and o have the same contents")
+	e.p.printf("\nfunc (%s %s) Equal(o %s) bool {", p.Varname(), recv, p.TypeName())
+	a := p.Varname()
+	if _, ok := p.(*Struct); !ok && recv[0] == '*' {
+		a = "(*" + a + ")"
+	}
+	e.compare(a, "o", p)
+	e.p.print("\nreturn true\n}\n")
+	return e.p.err
+}
+
+// compare prints the code returning false if a and b, which are values of e, differ.
+func (e *equalGen) compare(a, b string, el Elem) {
+	if !e.p.ok() {
+		return
+	}
+	switch el := el.(type) {
+	case *Struct:
+		for i := range el.Fields {
+			name := "." + el.Fields[i].fieldName
+			e.compare(a+name, b+name, el.Fields[i].fieldElem)
+		}
+	case *Array:
+		if comparesEqual(el.Els) {
+			e.differ(a + " != " + b)
+			return
+		}
+		e.p.printf("\nfor %s := range %s {", el.Index, a)
+		e.compare(a+"["+el.Index+"]", b+"["+el.Index+"]", el.Els)
+		e.p.closeBlock()
+	case *Slice:
+		e.differ("len(" + a + ") != len(" + b + ")")
+		e.p.printf("\nfor %s := range %s {", el.Index, a)
+		e.compare(a+"["+el.Index+"]", b+"["+el.Index+"]", el.Els)
+		e.p.closeBlock()
+	case *Map:
+		e.differ("len(" + a + ") != len(" + b + ")")
+		other := e.p.newIdent(a + " other")
+		found := e.p.newIdent(a + " found")
+		e.p.printf("\nfor %s, %s := range %s {", el.KeyIndx, el.ValIndx, a)
+		e.p.printf("\n%s, %s := %s[%s]", other, found, b, el.KeyIndx)
+		e.differ("!" + found)
+		e.compare(el.ValIndx, other, el.Value)
+		e.p.closeBlock()
+	case *Ptr:
+		e.differ("(" + a + " == nil) != (" + b + " == nil)")
+		e.p.printf("\nif %s != nil {", a)
+		e.compare("(*"+a+")", "(*"+b+")", el.Value)
+		e.p.closeBlock()
+	case *BaseElem:
+		e.base(a, b, el)
+	}
+}
+
+// base prints the comparison of a and b, which are values of a primitive type or a named type.
+func (e *equalGen) base(a, b string, el *BaseElem) {
+	switch {
+	case el.Value == IDENT:
+		e.differ("!" + a + ".Equal(" + b + ")")
+		return
+	case el.Value == Intf || el.Value == Ext:
+		e.differ("!msgp.EqualIntf(" + a + ", " + b + ")")
+		return
+	case el.Value == Binary:
+		e.differ("!msgp.EqualBinary(&" + a + ", &" + b + ")")
+		return
+	case el.Value == Text:
+		e.differ("!msgp.EqualText(&" + a + ", &" + b + ")")
+		return
+	case el.Value == Decimal:
+		e.differ("!msgp.EqualDecimal(&" + a + ", &" + b + ")")
+		return
+	case el.ShimToBase != "" && el.ShimMode == Convert:
+		// The values are compared as converted to the base type; values that cannot be
+		// converted cannot be encoded, so the errors are ignored.
+		va := e.p.newIdent(a + " base")
+		vb := e.p.newIdent(b + " base")
+		e.p.printf("\n%s, _ := %s(%s)", va, el.ToBase(), a)
+		e.p.printf("\n%s, _ := %s(%s)", vb, el.ToBase(), b)
+		a, b = va, vb
+	case el.Convert:
+		a = el.ToBase() + "(" + a + ")"
+		b = el.ToBase() + "(" + b + ")"
+	}
+	switch el.Value {
+	case Bytes, StrBytes:
+		e.differ("!bytes.Equal(" + a + ", " + b + ")")
+	case Raw:
+		e.differ("!msgp.Raw(" + a + ").Equal(" + b + ")")
+	case Time:
+		e.differ("!" + a + ".Equal(" + b + ")")
+	default:
+		e.differ(a + " != " + b)
+	}
+}
+
+// differ prints the code returning false if cond is true.
+func (e *equalGen) differ(cond string) {
+	e.p.printf("\nif %s {\nreturn false\n}", cond)
+}
+
+// comparesEqual says if the values of e can be compared with the == operator as the Equal methods
+// compare them.
+func comparesEqual(e Elem) bool {
+	switch e := e.(type) {
+	case *Array:
+		return comparesEqual(e.Els)
+	case *BaseElem:
+		if e.ShimToBase != "" {
+			return false
+		}
+		switch e.Value {
+		case Bytes, Time, Intf, Ext, Binary, Text, Raw, StrBytes, Decimal, IDENT:
+			return false
+		}
+		return true
+	}
+	return false
+}
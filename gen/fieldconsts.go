@@ -0,0 +1,44 @@
+package gen
+
+import (
+	"io"
+)
+
+func fieldConsts(w io.Writer) *fieldConstsGen {
+	return &fieldConstsGen{p: printer{w: w}}
+}
+
+// A fieldConstsGen prints for each struct written as a map the constants holding the keys of its
+// fields (see FieldConsts), named for the struct and the fields, such as PointFieldX for the field
+// X of Point.
+type fieldConstsGen struct {
+	passes
+	p printer
+}
+
+func (f *fieldConstsGen) Method() Method { return Encode | Marshal }
+
+func (f *fieldConstsGen) Execute(p Elem) error {
+	if !f.p.ok() {
+		return f.p.err
+	}
+	p = f.applyAll(p)
+	if p == nil || !isPrintable(p) {
+		return nil
+	}
+	s, ok := p.(*Struct)
+	if !ok || s.AsTuple || len(s.Fields) == 0 {
+		return nil
+	}
+	f.p.printf("\n// The keys of the fields of %s.\nconst (", s.TypeName())
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		if s.IntKeys {
+			f.p.printf("\n%sField%s = %s", s.TypeName(), field.fieldName, field.fieldTag)
+		} else {
+			f.p.printf("\n%sField%s = %q", s.TypeName(), field.fieldName, field.fieldTag)
+		}
+	}
+	f.p.print("\n)\n")
+	return f.p.err
+}
@@ -0,0 +1,222 @@
+package gen
+
+import (
+	"io"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultFillSize is the default size of the values filled with random data.
+const defaultFillSize = 8
+
+func fills(w io.Writer, ids *identGen, o *options) *fillGen {
+	return &fillGen{
+		p:      printer{w: w, ids: ids},
+		size:   strconv.Itoa(o.fillSize),
+		filled: o.filled,
+	}
+}
+
+// A fillGen prints the functions returning values filled with random data, which the generated
+// tests and benchmarks encode and decode instead of zero values.
+//
+// Strings and byte slices are given size random letters, and slices and maps size elements. The
+// values of named types are filled by calling the fill functions of the types if they are printed
+// in the same file, except behind pointers, slices, and maps (which could make a fill function
+// call itself forever). Extensions, the values written in their binary or text forms, the byte
+// slices with the raw option (which must hold encoded objects), the values of other named types,
+// and the values of types shimmed with conversions (which may reject random values) are left zero.
+type fillGen struct {
+	passes
+	p      printer
+	size   string          // the length of the strings, slices, and maps
+	filled map[string]bool // the names of the types having fill functions
+}
+
+func (f *fillGen) Method() Method { return Test }
+
+func (f *fillGen) Execute(p Elem) error {
+	if !f.p.ok() {
+		return f.p.err
+	}
+	// The passes are not applied: the fill functions of other types call this one whether or
+	// not tests are generated for this type.
+	if !f.filled[p.TypeName()] {
+		return nil
+	}
+	name := fillName(p.TypeName())
+	f.p.comment(name + " returns a " + p.TypeName() + " filled with random data from r")
+	f.p.printf("\nfunc %s(r *rand.Rand) (z %s) {", name, p.TypeName())
+	f.fill("z", p, false)
+	f.p.nakedReturn()
+	f.p.print("\n")
+	return f.p.err
+}
+
+// fill prints the code filling a, which holds a zero value of e. Indirect says if a is behind a
+// pointer, slice, or map.
+func (f *fillGen) fill(a string, e Elem, indirect bool) {
+	if !f.p.ok() || !f.fills(e, indirect) {
+		return
+	}
+	switch e := e.(type) {
+	case *Struct:
+		for i := range unionFields(e) {
+			f.fill(a+"."+e.Fields[i].fieldName, e.Fields[i].fieldElem, indirect)
+		}
+	case *Array:
+		f.p.printf("\nfor %s := range %s {", e.Index, a)
+		f.fill(a+"["+e.Index+"]", e.Els, indirect)
+		f.p.closeBlock()
+	case *Slice:
+		f.p.printf("\n%s = make(%s, %s)", a, e.TypeName(), f.size)
+		if f.fills(e.Els, true) {
+			f.p.printf("\nfor %s := range %s {", e.Index, a)
+			f.fill(a+"["+e.Index+"]", e.Els, true)
+			f.p.closeBlock()
+		}
+	case *Map:
+		count := f.p.newIdent(a + " count")
+		val := f.p.newIdent(a + " value")
+		f.p.printf("\n%s = make(%s, %s)", a, e.TypeName(), f.size)
+		f.p.printf("\nfor %s := 0; %s < %s; %s++ {", count, count, f.size, count)
+		f.p.declare(val, e.Value.TypeName())
+		f.fill(val, e.Value, true)
+		key := "msgptest.RandString(r, " + f.size + ")"
+		if e.Key != "" {
+			key = e.Key + "(" + key + ")"
+		}
+		f.p.printf("\n%s[%s] = %s", a, key, val)
+		f.p.closeBlock()
+	case *Ptr:
+		if be, ok := e.Value.(*BaseElem); ok && be.needsref {
+			// The shim converts to and from the pointer.
+			f.base(a, be)
+			return
+		}
+		f.p.printf("\n%s = new(%s)", a, e.Value.TypeName())
+		f.fill("(*"+a+")", e.Value, true)
+	case *BaseElem:
+		f.base(a, e)
+	}
+}
+
+// base prints the assignment of a random value to a, which is a value of a primitive type or a
+// named type having a fill function.
+func (f *fillGen) base(a string, e *BaseElem) {
+	if e.Value == IDENT {
+		f.p.printf("\n%s = %s(r)", a, fillName(e.TypeName()))
+		return
+	}
+	var v string
+	switch e.Value {
+	case String, Intf:
+		v = "msgptest.RandString(r, " + f.size + ")"
+	case Padded:
+		size := f.size
+		if n, _ := strconv.Atoi(size); e.padding.Width > 0 && e.padding.Width < n {
+			size = strconv.Itoa(e.padding.Width)
+		}
+		v = "msgptest.RandString(r, " + size + ")"
+	case Bytes, StrBytes:
+		v = "msgptest.RandBytes(r, " + f.size + ")"
+	case Float32:
+		v = "r.Float32()"
+	case Float64:
+		v = "r.Float64()"
+	case Complex64:
+		v = "complex(r.Float32(), r.Float32())"
+	case Complex128:
+		v = "complex(r.Float64(), r.Float64())"
+	case Int, Int8, Int16, Int32:
+		v = e.BaseType() + "(msgptest.RandInt(r))"
+	case Int64:
+		v = "msgptest.RandInt(r)"
+	case Uint, Uint8, Uint16, Uint32, Byte:
+		v = e.BaseType() + "(msgptest.RandUint(r))"
+	case Uint64:
+		v = "msgptest.RandUint(r)"
+	case Bool:
+		v = "r.Intn(2) == 1"
+	case Time:
+		v = "msgptest.RandTime(r)"
+	}
+	if e.ShimToBase != "" || e.Convert {
+		v = e.FromBase() + "(" + v + ")"
+	}
+	f.p.printf("\n%s = %s", a, v)
+}
+
+// fills says if any code is printed to fill a value of e. Indirect says if the value is behind a
+// pointer, slice, or map.
+func (f *fillGen) fills(e Elem, indirect bool) bool {
+	switch e := e.(type) {
+	case *Struct:
+		for i := range unionFields(e) {
+			if f.fills(e.Fields[i].fieldElem, indirect) {
+				return true
+			}
+		}
+		return false
+	case *Array:
+		return f.fills(e.Els, indirect)
+	case *Ptr:
+		if be, ok := e.Value.(*BaseElem); ok && be.needsref {
+			return f.fills(be, indirect)
+		}
+		return true
+	case *Slice, *Map:
+		return true
+	case *BaseElem:
+		switch {
+		case e.Value == IDENT:
+			return !indirect && f.filled[e.TypeName()]
+		case e.Value == Ext || e.Value == Binary || e.Value == Text || e.Value == Raw || e.Value == Decimal:
+			return false
+		case e.Value == Padded && !e.padding.ZeroTerm && e.padding.Width > 0 && isAlnum(e.padding.Pad):
+			// The random letters and digits could end with the pad byte, which is trimmed off.
+			return false
+		case e.ShimToBase != "" && e.ShimMode == Convert:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// unionFields returns the fields of s that are filled: only the first one if s is a union, which
+// fails to encode with more than one field set.
+func unionFields(s *Struct) []structField {
+	if s.Union {
+		return s.Fields[:1]
+	}
+	return s.Fields
+}
+
+// fillName returns the name of the fill function of the named type. The function is exported
+// only if the type is.
+func fillName(typeName string) string {
+	r, n := utf8.DecodeRuneInString(typeName)
+	if unicode.IsUpper(r) {
+		return "NewFilled" + typeName
+	}
+	return "newFilled" + string(unicode.ToUpper(r)) + typeName[n:]
+}
+
+// fillable returns the names of the types in identities for which fill functions are printed.
+func fillable(identities map[string]Elem) map[string]bool {
+	names := make(map[string]bool)
+	for name, el := range identities {
+		switch el.(type) {
+		case *Struct, *Array, *Slice, *Map:
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// isAlnum says if b is an ASCII letter or digit, as the random strings are made of.
+func isAlnum(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,538 @@
+package gen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func freestanding(w io.Writer, ids *identGen) *freestandingGen {
+	return &freestandingGen{p: printer{w: w, ids: ids}}
+}
+
+// A freestandingGen prints the MarshalMsg, UnmarshalMsg, and Msgsize methods of the structs of
+// fixed-size primitives without referring to the msgp package (see Freestanding). The methods
+// call the functions in freestandingHelpers, which are printed once in the file.
+type freestandingGen struct {
+	passes
+	p printer
+}
+
+func (f *freestandingGen) Method() Method { return Marshal | Unmarshal | Size }
+
+func (f *freestandingGen) Execute(p Elem) error {
+	if !f.p.ok() {
+		return f.p.err
+	}
+	p = f.applyAll(p)
+	if p == nil || !isPrintable(p) {
+		return nil
+	}
+	s, ok := p.(*Struct)
+	if !ok {
+		return nil // dropped by dropNotFreestanding
+	}
+	name := s.TypeName()
+
+	var size int
+	if s.AsTuple {
+		size = len(msgp.AppendArrayHeader(nil, uint32(len(s.Fields))))
+	} else {
+		size = len(msgp.AppendMapHeader(nil, uint32(len(s.Fields))))
+	}
+	for i := range s.Fields {
+		if !s.AsTuple {
+			key, _ := fieldKey(s, i)
+			size += len(key)
+		}
+		size += freestandingSize(s.Fields[i].fieldElem.(*BaseElem).Value)
+	}
+	f.p.printf("\n// %[1]sMsgMaxSize is the maximum number of bytes occupied by a serialized %[1]s.", name)
+	f.p.printf("\nconst %sMsgMaxSize = %d\n", name, size)
+
+	f.p.comment("MarshalMsg implements msgp.Marshaler")
+	f.p.printf("\nfunc (%s %s) MarshalMsg(b []byte) (o []byte, err error) {", s.Varname(), imutMethodReceiver(s))
+	f.p.print("\no = b")
+	var raw []byte
+	if s.AsTuple {
+		raw = msgp.AppendArrayHeader(raw, uint32(len(s.Fields)))
+	} else {
+		raw = msgp.AppendMapHeader(raw, uint32(len(s.Fields)))
+	}
+	for i := range s.Fields {
+		if !s.AsTuple {
+			key, _ := fieldKey(s, i)
+			raw = append(raw, key...)
+		}
+		f.rawbytes(raw)
+		raw = raw[:0]
+		f.appendValue(s.Fields[i].fieldElem.(*BaseElem))
+	}
+	f.rawbytes(raw)
+	f.p.nakedReturn()
+
+	f.p.comment("UnmarshalMsg implements msgp.Unmarshaler")
+	f.p.printf("\nfunc (%s %s) UnmarshalMsg(bts []byte) (o []byte, err error) {", s.Varname(), methodReceiver(s))
+	sz := f.p.newIdent(s.Varname() + " size")
+	f.p.declare(sz, u32)
+	if s.AsTuple {
+		f.p.printf("\n%s, bts, err = msgpReadArrayHeader(bts)", sz)
+		f.p.print(errCheck)
+		f.p.printf("\nif %s != %d {\nerr = errMsgpArraySize\nreturn\n}", sz, len(s.Fields))
+		for i := range s.Fields {
+			f.readValue(s.Fields[i].fieldElem.(*BaseElem))
+		}
+	} else {
+		f.p.declare("field", "[]byte")
+		f.p.printf("\n%s, bts, err = msgpReadMapHeader(bts)", sz)
+		f.p.print(errCheck)
+		f.p.printf("\nfor %s > 0 {", sz)
+		f.p.printf("\n%s--", sz)
+		f.p.print("\nfield, bts, err = msgpReadKey(bts)")
+		f.p.print(errCheck)
+		f.p.fieldSwitch(s, 0)
+		for i := range s.Fields {
+			f.p.fieldCase("", s, i)
+			f.readValue(s.Fields[i].fieldElem.(*BaseElem))
+		}
+		f.p.print("\ndefault:\nbts, err = msgpSkip(bts)")
+		f.p.print(errCheck)
+		f.p.closeBlock() // switch
+		f.p.closeBlock() // for
+	}
+	f.p.print("\no = bts")
+	f.p.nakedReturn()
+	unsetReceiver(s, f.p.ids.recv)
+
+	f.p.comment("Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message")
+	f.p.printf("\nfunc (%s %s) Msgsize() (s int) {", s.Varname(), imutMethodReceiver(s))
+	f.p.printf("\ns = %sMsgMaxSize", name)
+	f.p.nakedReturn()
+
+	f.p.comment("IsFixedSize says if Msgsize returns a constant, the maximum size of any serialized value of the type")
+	f.p.printf("\nfunc (%s %s) IsFixedSize() bool { return true }\n", s.Varname(), imutMethodReceiver(s))
+	return f.p.err
+}
+
+// rawbytes prints the appending of the bytes of b to o, if b is not empty.
+func (f *freestandingGen) rawbytes(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	f.p.print("\no = append(o")
+	for _, c := range b {
+		f.p.printf(", 0x%x", c)
+	}
+	f.p.print(")")
+}
+
+// appendValue prints the appending of the value of b to o.
+func (f *freestandingGen) appendValue(b *BaseElem) {
+	v := b.Varname()
+	switch b.Value {
+	case Int, Int8, Int16, Int32, Int64:
+		f.p.printf("\no = msgpAppendInt(o, int64(%s))", v)
+	case Uint, Uint8, Uint16, Uint32, Uint64, Byte:
+		f.p.printf("\no = msgpAppendUint(o, uint64(%s))", v)
+	default:
+		if b.Convert {
+			v = b.toBaseConvert()
+		}
+		f.p.printf("\no = msgpAppend%s(o, %s)", b.BaseName(), v)
+	}
+}
+
+// readValue prints the reading of the value of b from bts.
+func (f *freestandingGen) readValue(b *BaseElem) {
+	tmp := f.p.newIdent(b.Varname() + " base")
+	var typ string // the type of tmp
+	switch b.Value {
+	case Int, Int8, Int16, Int32, Int64:
+		typ = "int64"
+		f.p.declare(tmp, typ)
+		f.p.printf("\n%s, bts, err = msgpReadInt(bts, %s)", tmp, freestandingBits(b.Value))
+	case Uint, Uint8, Uint16, Uint32, Uint64, Byte:
+		typ = "uint64"
+		f.p.declare(tmp, typ)
+		f.p.printf("\n%s, bts, err = msgpReadUint(bts, %s)", tmp, freestandingBits(b.Value))
+	default:
+		typ = b.BaseType()
+		f.p.declare(tmp, typ)
+		f.p.printf("\n%s, bts, err = msgpRead%s(bts)", tmp, b.BaseName())
+	}
+	f.p.print(errCheck)
+	if b.Convert || typ != b.BaseType() {
+		tmp = b.TypeName() + "(" + tmp + ")"
+	}
+	f.p.printf("\n%s = %s", b.Varname(), tmp)
+}
+
+// freestandingBits returns the expression of the size in bits of the integer type p.
+func freestandingBits(p primitive) string {
+	switch p {
+	case Int, Uint:
+		return "msgpIntBits"
+	}
+	return fmt.Sprint(fixedBits(p))
+}
+
+// freestandingSize returns the maximum encoded size of the primitive p.
+func freestandingSize(p primitive) int {
+	switch p {
+	case Int8, Uint8, Byte:
+		return msgp.Int8Size
+	case Int16, Uint16:
+		return msgp.Int16Size
+	case Int32, Uint32:
+		return msgp.Int32Size
+	case Float32:
+		return msgp.Float32Size
+	case Bool:
+		return msgp.BoolSize
+	}
+	return msgp.Int64Size
+}
+
+// freestandingElem says if e is written and read by the freestanding methods: e is a fixed-size
+// primitive (an integer, a float, or a bool) or a named type with one as its base type.
+func freestandingElem(e Elem) bool {
+	b, ok := e.(*BaseElem)
+	if !ok || b.ShimToBase != "" {
+		return false
+	}
+	switch b.Value {
+	case Int, Int8, Int16, Int32, Int64, Uint, Uint8, Uint16, Uint32, Uint64, Byte, Float32, Float64, Bool:
+		return true
+	}
+	return false
+}
+
+// dropNotFreestanding removes, with a warning, the types that cannot have freestanding methods: the
+// types other than structs of fixed-size primitives written as maps with string keys or as arrays,
+// and the structs with fields tagged with required, default values, or omitzero.
+func (s *source) dropNotFreestanding() {
+	names := make([]string, 0, len(s.identities))
+	for name := range s.identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if reason := notFreestanding(s.identities[name]); reason != "" {
+			pushType(name, s.typePos(name))
+			warnf("no freestanding methods: %s\n", reason)
+			popState()
+			delete(s.identities, name)
+		}
+	}
+}
+
+// notFreestanding returns why e cannot have freestanding methods, or an empty string if it can.
+func notFreestanding(e Elem) string {
+	st, ok := e.(*Struct)
+	switch {
+	case !ok:
+		return "the type is not a struct"
+	case st.Union:
+		return "the struct is a union"
+	case st.IntKeys:
+		return "the struct has integer keys"
+	case st.TupleCompat:
+		return "the struct is a tuple decoded from arrays of any length"
+	case st.PreHook != "" || st.PostHook != "":
+		return "the struct has hooks"
+	}
+	for i := range st.Fields {
+		f := &st.Fields[i]
+		switch {
+		case !freestandingElem(f.fieldElem):
+			return fmt.Sprintf("the field %s is not of a fixed-size primitive type", f.fieldName)
+		case f.fieldElem.(*BaseElem).fixedInt:
+			return fmt.Sprintf("the field %s is an integer of fixed width", f.fieldName)
+		case f.required || f.dflt != "" || f.omitZero:
+			return fmt.Sprintf("the field %s has a required, default, or omitzero option", f.fieldName)
+		}
+	}
+	return ""
+}
+
+// freestandingHelpers holds the functions and variables that the freestanding methods call, which
+// encode and decode the values as the msgp package does.
+const freestandingHelpers = `// The functions below encode and decode the fields of the types of this file, so that the
+// file does not depend on the msgp package.
+
+// msgpIntBits is the size in bits of int and uint.
+const msgpIntBits = 32 << (^uint(0) >> 63)
+
+var (
+	errMsgpShortBytes = errors.New("msgp: too few bytes left to read object")
+	errMsgpType       = errors.New("msgp: attempted to decode an object of the wrong type")
+	errMsgpOverflow   = errors.New("msgp: the number does not fit in the type it is decoded into")
+	errMsgpArraySize  = errors.New("msgp: the array does not have the number of elements of the struct")
+)
+
+func msgpAppendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= -32 && i <= 0x7f:
+		return append(b, byte(i))
+	case i >= -1<<7 && i < 0:
+		return append(b, 0xd0, byte(i))
+	case i >= -1<<15 && i < 1<<15:
+		return append(b, 0xd1, byte(i>>8), byte(i))
+	case i >= -1<<31 && i < 1<<31:
+		return append(b, 0xd2, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+	}
+	return append(b, 0xd3, byte(i>>56), byte(i>>48), byte(i>>40), byte(i>>32), byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+}
+
+func msgpAppendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= 0xff:
+		return append(b, 0xcc, byte(u))
+	case u <= 0xffff:
+		return append(b, 0xcd, byte(u>>8), byte(u))
+	case u <= 0xffffffff:
+		return append(b, 0xce, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+	}
+	return append(b, 0xcf, byte(u>>56), byte(u>>48), byte(u>>40), byte(u>>32), byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func msgpAppendFloat32(b []byte, f float32) []byte {
+	u := math.Float32bits(f)
+	return append(b, 0xca, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func msgpAppendFloat64(b []byte, f float64) []byte {
+	u := math.Float64bits(f)
+	return append(b, 0xcb, byte(u>>56), byte(u>>48), byte(u>>40), byte(u>>32), byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func msgpAppendBool(b []byte, t bool) []byte {
+	if t {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+// msgpReadBig reads the big-endian unsigned integer of n bytes after the first byte of b.
+func msgpReadBig(b []byte, n int) (uint64, []byte, error) {
+	if len(b) < 1+n {
+		return 0, b, errMsgpShortBytes
+	}
+	var u uint64
+	for _, c := range b[1 : 1+n] {
+		u = u<<8 | uint64(c)
+	}
+	return u, b[1+n:], nil
+}
+
+// msgpReadHeader reads the header of a map or an array, given the first bytes of the fixed,
+// 16-bit, and 32-bit headers of the type.
+func msgpReadHeader(b []byte, fix, h16, h32 byte) (uint32, []byte, error) {
+	if len(b) < 1 {
+		return 0, b, errMsgpShortBytes
+	}
+	var u uint64
+	var err error
+	switch lead := b[0]; {
+	case lead&0xf0 == fix:
+		return uint32(lead & 0x0f), b[1:], nil
+	case lead == h16:
+		u, b, err = msgpReadBig(b, 2)
+	case lead == h32:
+		u, b, err = msgpReadBig(b, 4)
+	default:
+		return 0, b, errMsgpType
+	}
+	return uint32(u), b, err
+}
+
+func msgpReadMapHeader(b []byte) (uint32, []byte, error) {
+	return msgpReadHeader(b, 0x80, 0xde, 0xdf)
+}
+
+func msgpReadArrayHeader(b []byte) (uint32, []byte, error) {
+	return msgpReadHeader(b, 0x90, 0xdc, 0xdd)
+}
+
+// msgpReadKey reads a map key written as a string or as binary data. The returned slice
+// refers to b.
+func msgpReadKey(b []byte) ([]byte, []byte, error) {
+	if len(b) < 1 {
+		return nil, b, errMsgpShortBytes
+	}
+	var n uint64
+	o := b
+	var err error
+	switch lead := b[0]; {
+	case lead&0xe0 == 0xa0:
+		n, o = uint64(lead&0x1f), b[1:]
+	case lead == 0xd9 || lead == 0xc4:
+		n, o, err = msgpReadBig(b, 1)
+	case lead == 0xda || lead == 0xc5:
+		n, o, err = msgpReadBig(b, 2)
+	case lead == 0xdb || lead == 0xc6:
+		n, o, err = msgpReadBig(b, 4)
+	default:
+		return nil, b, errMsgpType
+	}
+	if err != nil {
+		return nil, b, err
+	}
+	if uint64(len(o)) < n {
+		return nil, b, errMsgpShortBytes
+	}
+	return o[:n], o[n:], nil
+}
+
+// msgpReadInteger reads an integer written with any of the integer encodings. A negative
+// integer is returned in two's complement with neg set.
+func msgpReadInteger(b []byte) (u uint64, neg bool, o []byte, err error) {
+	if len(b) < 1 {
+		return 0, false, b, errMsgpShortBytes
+	}
+	lead := b[0]
+	switch {
+	case lead <= 0x7f:
+		return uint64(lead), false, b[1:], nil
+	case lead >= 0xe0:
+		return uint64(int64(int8(lead))), true, b[1:], nil
+	case lead >= 0xcc && lead <= 0xcf:
+		u, o, err = msgpReadBig(b, 1<<(lead-0xcc))
+		return u, false, o, err
+	case lead >= 0xd0 && lead <= 0xd3:
+		n := 1 << (lead - 0xd0)
+		u, o, err = msgpReadBig(b, n)
+		shift := 64 - 8*uint(n)
+		i := int64(u<<shift) >> shift
+		return uint64(i), i < 0, o, err
+	}
+	return 0, false, b, errMsgpType
+}
+
+// msgpReadInt reads an integer that must fit in a signed integer of the given size in bits.
+func msgpReadInt(b []byte, bits uint) (int64, []byte, error) {
+	u, neg, o, err := msgpReadInteger(b)
+	if err != nil {
+		return 0, b, err
+	}
+	i := int64(u)
+	if !neg && i < 0 || i<<(64-bits)>>(64-bits) != i {
+		return 0, b, errMsgpOverflow
+	}
+	return i, o, nil
+}
+
+// msgpReadUint reads an integer that must fit in an unsigned integer of the given size in bits.
+func msgpReadUint(b []byte, bits uint) (uint64, []byte, error) {
+	u, neg, o, err := msgpReadInteger(b)
+	if err != nil {
+		return 0, b, err
+	}
+	if neg || bits < 64 && u>>bits != 0 {
+		return 0, b, errMsgpOverflow
+	}
+	return u, o, nil
+}
+
+func msgpReadFloat32(b []byte) (float32, []byte, error) {
+	if len(b) > 0 && b[0] != 0xca {
+		return 0, b, errMsgpType
+	}
+	u, o, err := msgpReadBig(b, 4)
+	return math.Float32frombits(uint32(u)), o, err
+}
+
+// msgpReadFloat64 reads a float64, or a float32 converted to a float64.
+func msgpReadFloat64(b []byte) (float64, []byte, error) {
+	if len(b) > 0 && b[0] == 0xca {
+		f, o, err := msgpReadFloat32(b)
+		return float64(f), o, err
+	}
+	if len(b) > 0 && b[0] != 0xcb {
+		return 0, b, errMsgpType
+	}
+	u, o, err := msgpReadBig(b, 8)
+	return math.Float64frombits(u), o, err
+}
+
+func msgpReadBool(b []byte) (bool, []byte, error) {
+	if len(b) < 1 {
+		return false, b, errMsgpShortBytes
+	}
+	switch b[0] {
+	case 0xc3:
+		return true, b[1:], nil
+	case 0xc2:
+		return false, b[1:], nil
+	}
+	return false, b, errMsgpType
+}
+
+// msgpSkip skips the next object in b, which may be of any type.
+func msgpSkip(b []byte) ([]byte, error) {
+	// objs is the number of objects left to skip, counting the elements of maps and arrays.
+	for objs := uint64(1); objs > 0; objs-- {
+		if len(b) < 1 {
+			return b, errMsgpShortBytes
+		}
+		lead := b[0]
+		size, elems := uint64(1), uint64(0) // the size of the object without its elements
+		switch {
+		case lead <= 0x7f || lead >= 0xe0 || lead == 0xc0 || lead == 0xc2 || lead == 0xc3:
+		case lead <= 0x8f:
+			elems = 2 * uint64(lead&0x0f)
+		case lead <= 0x9f:
+			elems = uint64(lead & 0x0f)
+		case lead <= 0xbf:
+			size += uint64(lead & 0x1f)
+		case lead == 0xca || lead == 0xcb:
+			size += 4 << (lead - 0xca)
+		case lead >= 0xcc && lead <= 0xcf:
+			size += 1 << (lead - 0xcc)
+		case lead >= 0xd0 && lead <= 0xd3:
+			size += 1 << (lead - 0xd0)
+		case lead >= 0xd4 && lead <= 0xd8:
+			size += 1 + 1<<(lead-0xd4)
+		default:
+			// The object has a length or a number of elements of 1, 2, or 4 bytes.
+			var n int
+			switch lead {
+			case 0xc4, 0xc7, 0xd9:
+				n = 1
+			case 0xc5, 0xc8, 0xda, 0xdc, 0xde:
+				n = 2
+			case 0xc6, 0xc9, 0xdb, 0xdd, 0xdf:
+				n = 4
+			default:
+				return b, errMsgpType
+			}
+			l, _, err := msgpReadBig(b, n)
+			if err != nil {
+				return b, err
+			}
+			size += uint64(n)
+			switch lead {
+			case 0xdc, 0xdd:
+				elems = l
+			case 0xde, 0xdf:
+				elems = 2 * l
+			case 0xc7, 0xc8, 0xc9:
+				size += 1 + l // the extension type and the data
+			default:
+				size += l
+			}
+		}
+		if uint64(len(b)) < size || uint64(len(b))-size < elems {
+			// Every element takes at least one byte.
+			return b, errMsgpShortBytes
+		}
+		b = b[size:]
+		objs += elems
+	}
+	return b, nil
+}
+`
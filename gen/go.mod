@@ -9,5 +9,5 @@ require (
 	"github.com/dchenk/msgp" v0.0.0-20180420210123-e1e324a7758f
 	"github.com/philhofer/fwd" v1.0.0
 	"github.com/ttacon/chalk" v0.0.0-20160626202418-22c06c80ed31
-	"golang.org/x/tools" v0.0.0-20180416195352-94b14834a201
+	"golang.org/x/tools" v0.50.0
 )
@@ -17,15 +17,20 @@ package gen
 //
 // will not.
 
-// maxComplex is an approximate measure
-// of the number of children in a node.
-const maxComplex = 5
+import (
+	"sort"
+	"strings"
+)
+
+// defaultMaxComplex is the default limit on the complexity (an approximate
+// measure of the number of children in a node) of the types that are inlined.
+const defaultMaxComplex = 5
 
 // findShim begins recursive search for identities with the
 // given name and replaces them with be.
 func (s *source) findShim(id string, be *BaseElem) {
 	for name, el := range s.identities {
-		pushState(name)
+		pushType(name, s.typePos(name))
 		switch el := el.(type) {
 		case *Struct:
 			for i := range el.Fields {
@@ -48,9 +53,10 @@ func (s *source) findShim(id string, be *BaseElem) {
 
 func (s *source) nextShim(ref *Elem, id string, be *BaseElem) {
 	if (*ref).TypeName() == id {
-		vn := (*ref).Varname()
+		vn, pos := (*ref).Varname(), (*ref).Pos()
 		*ref = be.Copy()
 		(*ref).SetVarname(vn)
+		(*ref).SetPos(pos)
 	} else {
 		switch el := (*ref).(type) {
 		case *Struct:
@@ -71,63 +77,141 @@ func (s *source) nextShim(ref *Elem, id string, be *BaseElem) {
 
 // propInline identifies and in-lines candidates.
 func (s *source) propInline() {
+	// Go through the types in order so that the output does not depend on map iteration.
+	names := make([]string, 0, len(s.identities))
+	for name := range s.identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Types are inlined as they were declared, not with the types already inlined into them.
+	s.declared = make(map[string]Elem, len(s.identities))
 	for name, el := range s.identities {
-		pushState(name)
-		switch el := el.(type) {
+		s.declared[name] = el.Copy()
+	}
+
+	for _, name := range names {
+		pushType(name, s.typePos(name))
+		chain := []string{name}
+		switch el := s.identities[name].(type) {
 		case *Struct:
-			for i := range el.Fields {
-				s.nextInline(&el.Fields[i].fieldElem, name)
-			}
+			s.inlineFields(el, chain)
 		case *Array:
-			s.nextInline(&el.Els, name)
+			s.nextInline(&el.Els, chain)
 		case *Slice:
-			s.nextInline(&el.Els, name)
+			s.nextInline(&el.Els, chain)
 		case *Map:
-			s.nextInline(&el.Value, name)
+			s.nextInline(&el.Value, chain)
 		case *Ptr:
-			s.nextInline(&el.Value, name)
+			s.nextInline(&el.Value, chain)
 		}
 		popState()
 	}
 }
 
-func (s *source) nextInline(ref *Elem, root string) {
+// nextInline inlines the types referred to within the element at ref. The chain lists the
+// names of the types being inlined into each other at ref, starting with the type whose
+// methods are being generated.
+func (s *source) nextInline(ref *Elem, chain []string) {
 	switch el := (*ref).(type) {
 	case *BaseElem:
-		// Ensure that we're not inlining a type into itself.
 		typ := el.TypeName()
-		if el.Value == IDENT && typ != root {
-			if node, ok := s.identities[typ]; ok && node.Complexity() < maxComplex {
+		if el.Value != IDENT {
+			return
+		}
+		// A type cannot be inlined into itself, either directly or through a cycle of types
+		// referring to each other; instead, its methods are called.
+		for _, t := range chain {
+			if t == typ {
+				return
+			}
+		}
+		if node, ok := s.declared[typ]; ok && s.inlinable(typ, node) {
 
-				infof("inlining %s\n", typ)
+			infof("inlining %s\n", typ)
 
-				// This should never happen; it will cause infinite recursion.
-				if node == *ref {
-					panic("Detected infinite recursion in inlining loop! Please file a bug at github.com/dchenk/msgp/issues")
-				}
+			// This should never happen; it will cause infinite recursion.
+			if node == *ref {
+				panic("Detected infinite recursion in inlining loop! Please file a bug at github.com/dchenk/msgp/issues")
+			}
 
-				*ref = node.Copy()
-				s.nextInline(ref, node.TypeName())
+			*ref = node.Copy()
+			s.nextInline(ref, append(chain, typ))
 
-			} else if !ok && !el.Resolved() {
-				// At this point we are sure that we've got a type that is neither
-				// a primitive, a library builtin, nor a processed type.
-				warnf("Unresolved identifier: %s\n", typ)
-			}
+		} else if !ok && !el.Resolved() && !strings.Contains(typ, ".") {
+			// At this point we are sure that we've got a type that is neither
+			// a primitive, a library builtin, nor a processed type. (The types
+			// from other packages are looked up by resolveImported.)
+			warnfAt(el.Pos(), "Unresolved identifier: %s\n", typ)
 		}
 	case *Struct:
-		for i := range el.Fields {
-			s.nextInline(&el.Fields[i].fieldElem, root)
-		}
+		s.inlineFields(el, chain)
 	case *Array:
-		s.nextInline(&el.Els, root)
+		s.nextInline(&el.Els, chain)
 	case *Slice:
-		s.nextInline(&el.Els, root)
+		s.nextInline(&el.Els, chain)
 	case *Map:
-		s.nextInline(&el.Value, root)
+		s.nextInline(&el.Value, chain)
 	case *Ptr:
-		s.nextInline(&el.Value, root)
+		s.nextInline(&el.Value, chain)
 	default:
 		panic("bad elem type")
 	}
 }
+
+// inlineFields inlines the types referred to within the fields of st. The struct types of the
+// fields with the asarray option are inlined whatever their complexity and written as tuples.
+func (s *source) inlineFields(st *Struct, chain []string) {
+	for i := range st.Fields {
+		s.nextInline(&st.Fields[i].fieldElem, chain)
+		if st.Fields[i].asArray {
+			pushStateAt(st.Fields[i].fieldName, st.Fields[i].fieldElem.Pos())
+			s.inlineTuple(&st.Fields[i].fieldElem, chain)
+			popState()
+		}
+	}
+}
+
+// inlineTuple makes the structs at ref (or the structs in the slices, arrays, and pointers at
+// ref) be written as tuples. A named struct type is inlined to be written as a tuple only where
+// it is referred to from ref; its own methods are left alone.
+func (s *source) inlineTuple(ref *Elem, chain []string) {
+	switch el := (*ref).(type) {
+	case *Struct:
+		el.AsTuple = true
+	case *Array:
+		s.inlineTuple(&el.Els, chain)
+	case *Slice:
+		s.inlineTuple(&el.Els, chain)
+	case *Ptr:
+		s.inlineTuple(&el.Value, chain)
+	case *BaseElem:
+		typ := el.TypeName()
+		for _, t := range chain {
+			if t == typ {
+				warnf("asarray: %s cannot be inlined into itself\n", typ)
+				return
+			}
+		}
+		node, ok := s.declared[typ].(*Struct)
+		if el.Value != IDENT || !ok {
+			warnf("asarray: %s is not a struct type declared in the source\n", typ)
+			return
+		}
+		infof("inlining %s as a tuple\n", typ)
+		*ref = node.Copy()
+		s.nextInline(ref, append(chain, typ))
+		(*ref).(*Struct).AsTuple = true
+	default:
+		warnln("asarray: the field is not of a struct type or a slice, array, or pointer of one")
+	}
+}
+
+// inlinable says if the type named typ, declared as node, should be inlined into the
+// types referring to it.
+func (s *source) inlinable(typ string, node Elem) bool {
+	if force, ok := s.inline[typ]; ok {
+		return force
+	}
+	return node.Complexity() < s.maxComplex
+}
@@ -2,43 +2,207 @@ package gen
 
 import (
 	"fmt"
+	"go/token"
 	"strings"
+	"sync"
 
 	"github.com/ttacon/chalk"
 )
 
+// A Diagnostic is a warning reported while processing the source, such as about a field that is
+// ignored or a directive that cannot be applied. The position is that of the innermost declaration,
+// field, or directive being processed when the warning was reported; it is not known for every
+// warning.
+type Diagnostic struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Type   string `json:"type,omitempty"` // the type being processed, if any
+	Reason string `json:"reason"`
+}
+
+// String formats d like the errors of the Go compiler.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.File != "" {
+		b.WriteString(d.File)
+		if d.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", d.Line, d.Column)
+		}
+		b.WriteString(": ")
+	}
+	if d.Type != "" {
+		b.WriteString(d.Type + ": ")
+	}
+	b.WriteString(d.Reason)
+	return b.String()
+}
+
+// A WarningsError is the error returned by Run and RunData with the Strict option if any warnings
+// were reported. It holds the warnings in the order they were reported.
+type WarningsError []Diagnostic
+
+func (e WarningsError) Error() string {
+	if len(e) == 1 {
+		return "1 warning treated as an error: " + e[0].String()
+	}
+	return fmt.Sprintf("%d warnings treated as errors; the first is %s", len(e), e[0])
+}
+
 func infof(s string, v ...interface{}) {
-	pushState(s)
-	fmt.Printf(chalk.Green.Color(strings.Join(logStates, ": ")), v...)
-	popState()
+	fmt.Printf(chalk.Green.Color(logLine(s)), v...)
 }
 
 func infoln(s string) {
-	pushState(s)
-	fmt.Println(chalk.Green.Color(strings.Join(logStates, ": ")))
-	popState()
+	fmt.Println(chalk.Green.Color(logLine(s)))
 }
 
 func warnf(s string, v ...interface{}) {
-	pushState(s)
-	fmt.Printf(chalk.Yellow.Color(strings.Join(logStates, ": ")), v...)
-	popState()
+	warn(strings.TrimSuffix(fmt.Sprintf(s, v...), "\n"))
 }
 
 func warnln(s string) {
-	pushState(s)
-	fmt.Println(chalk.Yellow.Color(strings.Join(logStates, ": ")))
-	popState()
+	warn(s)
+}
+
+// warnfAt reports a warning like warnf for the source at pos. Unlike pushing a state for pos, it
+// can be called while types are printed concurrently.
+func warnfAt(pos token.Position, s string, v ...interface{}) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logStates = append(logStates, logState{pos: pos})
+	warnLocked(strings.TrimSuffix(fmt.Sprintf(s, v...), "\n"))
+	logStates = logStates[:len(logStates)-1]
+}
+
+// located prefixes s with pos if pos is valid, as in the errors naming the source of a problem.
+func located(pos token.Position, s string) string {
+	if !pos.IsValid() {
+		return s
+	}
+	return pos.String() + ": " + s
+}
+
+// A logState is an element of the context in which messages are logged: the path of a file, the
+// name of a type or field, or a directive. The name may be empty for states that only locate the
+// messages.
+type logState struct {
+	name string
+	pos  token.Position // the position of the declaration, field, or directive (if known)
+	typ  bool           // the state is the declaration of the type name
+}
+
+var (
+	logStates []logState
+	logMu     sync.Mutex // guards the variables; messages are logged while types are printed concurrently
+
+	warnings    []Diagnostic     // the warnings reported since beginWarnings
+	warnHandler func(Diagnostic) // receives the warnings instead of the standard output if not nil
+)
+
+// logLine returns s prefixed with the logging state.
+func logLine(s string) string {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return logLineLocked(s)
+}
+
+func logLineLocked(s string) string {
+	parts := make([]string, 0, len(logStates)+1)
+	for _, st := range logStates {
+		if st.name != "" {
+			parts = append(parts, st.name)
+		}
+	}
+	return strings.Join(append(parts, s), ": ")
+}
+
+// warn reports the warning message s in the logging state.
+func warn(s string) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	warnLocked(s)
+}
+
+func warnLocked(s string) {
+	d := diagnostic(s)
+	warnings = append(warnings, d)
+	if warnHandler != nil {
+		warnHandler(d)
+		return
+	}
+	fmt.Println(chalk.Yellow.Color(logLineLocked(s)))
+}
+
+// diagnostic returns the Diagnostic for the warning message s in the logging state. The reason is
+// s prefixed with the names of the states within the innermost type or, if there is no type, within
+// the outermost located state (so the paths of the files are left out).
+func diagnostic(s string) Diagnostic {
+	var d Diagnostic
+	start := 0
+	for start < len(logStates) && !logStates[start].pos.IsValid() && !logStates[start].typ {
+		start++
+	}
+	for i, st := range logStates {
+		if st.pos.IsValid() {
+			d.File, d.Line, d.Column = st.pos.Filename, st.pos.Line, st.pos.Column
+		}
+		if st.typ {
+			d.Type = st.name
+			start = i + 1
+		}
+	}
+	parts := make([]string, 0, len(logStates)-start+1)
+	for _, st := range logStates[start:] {
+		if st.name != "" {
+			parts = append(parts, st.name)
+		}
+	}
+	d.Reason = strings.Join(append(parts, s), ": ")
+	return d
 }
 
-var logStates []string
+// beginWarnings starts collecting the warnings of a run, passing them to handler if it is not nil.
+func beginWarnings(handler func(Diagnostic)) {
+	logMu.Lock()
+	warnings, warnHandler = nil, handler
+	logMu.Unlock()
+}
+
+// endWarnings stops collecting warnings and returns the warnings reported since beginWarnings.
+func endWarnings() []Diagnostic {
+	logMu.Lock()
+	defer logMu.Unlock()
+	w := warnings
+	warnings, warnHandler = nil, nil
+	return w
+}
 
 // push logging state
 func pushState(s string) {
-	logStates = append(logStates, s)
+	pushLogState(logState{name: s})
+}
+
+// pushStateAt pushes the logging state s for the source at pos. If s is empty, the state only
+// locates the messages logged.
+func pushStateAt(s string, pos token.Position) {
+	pushLogState(logState{name: s, pos: pos})
+}
+
+// pushType pushes the logging state for the declaration of the type name at pos.
+func pushType(name string, pos token.Position) {
+	pushLogState(logState{name: name, pos: pos, typ: true})
+}
+
+func pushLogState(st logState) {
+	logMu.Lock()
+	logStates = append(logStates, st)
+	logMu.Unlock()
 }
 
 // pop logging state
 func popState() {
+	logMu.Lock()
 	logStates = logStates[:len(logStates)-1]
+	logMu.Unlock()
 }
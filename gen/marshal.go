@@ -7,16 +7,20 @@ import (
 	"github.com/dchenk/msgp/msgp"
 )
 
-func marshal(w io.Writer) *marshalGen {
+func marshal(w io.Writer, ids *identGen, kvFields int, compactFloats bool) *marshalGen {
 	return &marshalGen{
-		p: printer{w: w},
+		p:             printer{w: w, ids: ids},
+		kvFields:      kvFields,
+		compactFloats: compactFloats,
 	}
 }
 
 type marshalGen struct {
 	passes
-	p    printer
-	fuse []byte
+	p             printer
+	fuse          []byte
+	kvFields      int  // the number of fields from which structs are appended with msgp.AppendKV
+	compactFloats bool // append float64s with msgp.AppendFloat
 }
 
 func (m *marshalGen) Method() Method { return Marshal }
@@ -37,20 +41,76 @@ func (m *marshalGen) Execute(p Elem) error {
 		return nil
 	}
 
-	m.p.comment("MarshalMsg implements msgp.Marshaler")
-
 	// save the vname before
 	// calling methodReceiver so
 	// that z.Msgsize() is printed correctly
 	c := p.Varname()
 
+	// The marshaling of a type that cannot fail is written as an AppendMsg method.
+	if infallible(p, m.kvFields, m.compactFloats) {
+		m.p.comment("AppendMsg implements msgp.Appender")
+		recv := imutMethodReceiver(p)
+		m.p.printf("\nfunc (%s %s) AppendMsg(b []byte) (o []byte) {", p.Varname(), recv)
+		m.p.printf("\no = msgp.Require(b, %s.Msgsize())", c)
+		next(m, p)
+		m.p.nakedReturn()
+
+		m.p.comment("MarshalMsg implements msgp.Marshaler")
+		m.p.printf("\nfunc (%s %s) MarshalMsg(b []byte) ([]byte, error) {", p.Varname(), recv)
+		m.p.printf("\nreturn %s.AppendMsg(b), nil\n}\n", p.Varname())
+		return m.p.err
+	}
+
+	m.p.comment("MarshalMsg implements msgp.Marshaler")
 	m.p.printf("\nfunc (%s %s) MarshalMsg(b []byte) (o []byte, err error) {", p.Varname(), imutMethodReceiver(p))
 	m.p.printf("\no = msgp.Require(b, %s.Msgsize())", c)
+	// The hook is called after b is kept in o, which is returned if the hook fails.
+	pre, _ := hooks(p)
+	m.p.callHook(c, pre)
 	next(m, p)
 	m.p.nakedReturn()
 	return m.p.err
 }
 
+// infallible says if the marshaling of e cannot fail, which is the case if none of the elements
+// in e are marshaled by a method or function that can return an error. The structs with at least
+// kvFields fields may be appended with msgp.AppendKV, which can.
+func infallible(e Elem, kvFields int, compactFloats bool) bool {
+	switch e := e.(type) {
+	case *Struct:
+		if e.Union || e.PreHook != "" || kvTable(e, kvFields, compactFloats) {
+			return false
+		}
+		for i := range e.Fields {
+			if !infallible(e.Fields[i].fieldElem, kvFields, compactFloats) {
+				return false
+			}
+		}
+		return true
+	case *Array:
+		return infallible(e.Els, kvFields, compactFloats)
+	case *Slice:
+		return infallible(e.Els, kvFields, compactFloats)
+	case *Map:
+		return infallible(e.Value, kvFields, compactFloats)
+	case *Ptr:
+		return infallible(e.Value, kvFields, compactFloats)
+	case *BaseElem:
+		if e.Convert && e.ShimMode == Convert {
+			return false
+		}
+		switch e.Value {
+		case IDENT, Intf, Ext, Binary, Text, Raw:
+			return false
+		case Padded:
+			// Only the strings wider than a fixed width are refused.
+			return e.padding.Width <= 0
+		}
+		return true
+	}
+	return false
+}
+
 func (m *marshalGen) rawAppend(typ string, argfmt string, arg interface{}) {
 	m.p.printf("\no = msgp.Append%s(o, %s)", typ, fmt.Sprintf(argfmt, arg))
 }
@@ -75,9 +135,16 @@ func (m *marshalGen) gStruct(s *Struct) {
 		return
 	}
 
-	if s.AsTuple {
+	switch {
+	case s.AsTuple:
 		m.tuple(s)
-	} else {
+	case kvTable(s, m.kvFields, m.compactFloats):
+		m.fuseHook()
+		m.p.printf("\n// map of %d fields", len(s.Fields))
+		m.p.print("\no, err = msgp.AppendKV(o,")
+		printKV(&m.p, s)
+		m.p.print(errCheck)
+	default:
 		m.mapstruct(s)
 	}
 }
@@ -99,10 +166,21 @@ func (m *marshalGen) tuple(s *Struct) {
 }
 
 func (m *marshalGen) mapstruct(s *Struct) {
-	data := make([]byte, 0, 64)
-	data = msgp.AppendMapHeader(data, uint32(len(s.Fields)))
-	m.p.printf("\n// map header, size %d", len(s.Fields))
-	m.Fuse(data)
+	count, omit := m.p.omitted(s)
+	if s.Union {
+		m.p.unionCheck(s, count)
+	}
+	if count != "" {
+		// The size depends on the fields left out.
+		m.fuseHook()
+		m.p.printf("\n// map header, size %d less the fields omitted", len(s.Fields))
+		m.rawAppend(mapHeader, literalFmt, count)
+	} else {
+		data := make([]byte, 0, 64)
+		data = msgp.AppendMapHeader(data, uint32(len(s.Fields)))
+		m.p.printf("\n// map header, size %d", len(s.Fields))
+		m.Fuse(data)
+	}
 	if len(s.Fields) == 0 {
 		m.fuseHook()
 	}
@@ -110,12 +188,25 @@ func (m *marshalGen) mapstruct(s *Struct) {
 		if !m.p.ok() {
 			return
 		}
-		data = msgp.AppendString(nil, s.Fields[i].fieldTag)
+		omitted := count != "" && omit[i] != ""
+		if omitted {
+			m.fuseHook()
+			m.p.printf("\nif !%s {", omit[i])
+		}
+		data, key := fieldKey(s, i)
 
-		m.p.printf("\n// string %q", s.Fields[i].fieldTag)
+		if s.IntKeys {
+			m.p.printf("\n// %s", key)
+		} else {
+			m.p.printf("\n// string %s", key)
+		}
 		m.Fuse(data)
 
 		next(m, s.Fields[i].fieldElem)
+		if omitted {
+			m.fuseHook()
+			m.p.closeBlock()
+		}
 	}
 }
 
@@ -134,9 +225,13 @@ func (m *marshalGen) gMap(s *Map) {
 	}
 	m.fuseHook()
 	vname := s.Varname()
+	if fast := s.fastPath(); fast != "" {
+		m.rawAppend(fast, literalFmt, vname)
+		return
+	}
 	m.rawAppend(mapHeader, lenAsUint32, vname)
 	m.p.printf("\nfor %s, %s := range %s {", s.KeyIndx, s.ValIndx, vname)
-	m.rawAppend(stringTyp, literalFmt, s.KeyIndx)
+	m.rawAppend(stringTyp, literalFmt, s.keyString(s.KeyIndx))
 	next(m, s.Value)
 	m.p.closeBlock()
 }
@@ -157,7 +252,11 @@ func (m *marshalGen) gArray(a *Array) {
 	}
 	m.fuseHook()
 	if be, ok := a.Els.(*BaseElem); ok && be.Value == Byte {
-		m.rawAppend("Bytes", "(%s)[:]", a.Varname())
+		if a.Str {
+			m.rawAppend("StringFromBytes", "(%s)[:]", a.Varname())
+		} else {
+			m.rawAppend("Bytes", "(%s)[:]", a.Varname())
+		}
 		return
 	}
 
@@ -186,7 +285,7 @@ func (m *marshalGen) gBase(b *BaseElem) {
 		if b.ShimMode == Cast {
 			vname = b.toBaseConvert()
 		} else {
-			vname = randIdent()
+			vname = m.p.newIdent(b.Varname() + " base")
 			m.p.declare(vname, b.BaseType())
 			m.p.printf("\n%s, err = %s", vname, b.toBaseConvert())
 			m.p.printf(errCheck)
@@ -198,11 +297,18 @@ func (m *marshalGen) gBase(b *BaseElem) {
 	case IDENT:
 		echeck = true
 		m.p.printf("\no, err = %s.MarshalMsg(o)", vname)
-	case Intf, Ext:
+	case Intf, Ext, Binary, Text, Raw:
 		echeck = true
 		m.p.printf("\no, err = msgp.Append%s(o, %s)", b.BaseName(), vname)
+	case Padded:
+		if b.padding.Width > 0 {
+			echeck = true
+			m.p.printf("\no, err = msgp.AppendPaddedString(o, %s, %s)", vname, b.paddingExpr())
+		} else {
+			m.p.printf("\no, _ = msgp.AppendPaddedString(o, %s, %s)", vname, b.paddingExpr())
+		}
 	default:
-		m.rawAppend(b.BaseName(), literalFmt, vname)
+		m.rawAppend(writeName(b, m.compactFloats), literalFmt, writeArg(b, vname))
 	}
 
 	if echeck {
@@ -0,0 +1,359 @@
+package gen
+
+import (
+	"path/filepath"
+	"runtime"
+)
+
+// An Option configures an optional behavior of the code generator. Options can be passed
+// to Run and RunData after the required arguments.
+type Option func(*options)
+
+// options holds the settings that can be changed with an Option.
+type options struct {
+	externalTests bool     // put the generated tests into the external test package
+	outDir        string   // the directory of the test files (next to the output file if empty)
+	outPkg        string   // the package of the test files in outDir (named for outDir if empty)
+	testMethods   Method   // the methods to generate tests for (all if zero)
+	noBench       bool     // do not generate benchmarks along with tests
+	maxComplex    int      // the limit on the complexity of the types that are inlined
+	shims         []string // files from which shim directives are loaded
+	workers       int      // the number of types printed concurrently (GOMAXPROCS if zero)
+	tags          []string // build tags for selecting the files of a directory
+	exactSizes    bool     // generate MsgsizeExact methods
+	tightSizes    bool     // measure the values in Msgsize
+	compactFloats bool     // write float64s in the fewest bytes
+	identPrefix   string   // the prefix of the names of the variables declared in the methods
+	receiver      string   // the name of the receivers of the methods
+	stableIdents  bool     // derive the names of the variables from what they are for
+	keySwitch     int      // the number of fields from which keys are matched by length and bytes
+	kvFields      int      // the number of fields from which structs are written with WriteKV
+	fillSize      int      // the size of the values filled with random data for the tests
+	oldSpec       bool     // read 'str' and 'bin' objects interchangeably
+	lenientFloats bool     // read integers into float64s
+	lenientBools  bool     // read the integers 0 and 1 into bools
+	strict        bool     // fail if any warnings are reported
+	views         bool     // generate the View types of structs
+	crossTests    bool     // generate the tests against github.com/vmihailenco/msgpack
+	freestanding  bool     // generate methods not referring to the msgp package
+	wrapErrors    bool     // wrap the decoding errors with the paths of the values
+	schema        bool     // write the JSON Schema of the types
+	registry      bool     // register the field tables of the types with msgp.RegisterSchema
+	fieldConsts   bool     // generate the constants holding the keys of the fields
+	sql           bool     // generate the database/sql Value and Scan methods
+
+	warn func(Diagnostic) // receives the warnings instead of the standard output (if not nil)
+
+	testQual     string          // qualifier for type names in the tests file (set by RunData)
+	filled       map[string]bool // the types having fill functions in the tests file (set by printTo)
+	registryQual string          // qualifier for the registered type names (set by run)
+	types        map[string]Elem // the types of the source by name, with Registry (set by printTo)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{maxComplex: defaultMaxComplex, identPrefix: "z", receiver: "z",
+		keySwitch: defaultKeySwitch, fillSize: defaultFillSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ExternalTests makes the generated tests belong to the external test package (named like
+// the source package with "_test" appended) so that they exercise only the exported API of
+// the source package. Tests are not generated for unexported types in this case.
+func ExternalTests() Option {
+	return func(o *options) { o.externalTests = true }
+}
+
+// OutDir makes Run write the test files (including the cross tests; see CrossTests) to the
+// directory dir, which is created if needed, so that they can be kept out of the source tree. The
+// methods are still written next to the source, since methods can be declared only in the package
+// of their types. The tests belong to a package of their own in dir (see OutPackage), which imports
+// the source package, so, as with ExternalTests, they exercise only its exported types. A relative
+// dir is taken from the working directory, which "go generate" sets to that of the source file.
+func OutDir(dir string) Option {
+	return func(o *options) { o.outDir = dir }
+}
+
+// OutPackage names the package of the test files written to the directory given with OutDir. By
+// default, the package is named for the directory.
+func OutPackage(name string) Option {
+	return func(o *options) { o.outPkg = name }
+}
+
+// testsPackage returns the name of the package of the test files written to outDir.
+func (o *options) testsPackage() string {
+	if o.outPkg != "" {
+		return o.outPkg
+	}
+	return filepath.Base(o.outDir)
+}
+
+// TestsFor limits the generated tests and benchmarks to those exercising the given methods.
+// For example, TestsFor(Marshal|Unmarshal) generates the tests for MarshalMsg and UnmarshalMsg
+// but not the tests for EncodeMsg and DecodeMsg. Generating tests limited to methods none of which
+// are generated is an error, since the test file would have no tests.
+func TestsFor(m Method) Option {
+	return func(o *options) { o.testMethods = m }
+}
+
+// NoBenchmarks turns off the generation of benchmarks along with tests.
+func NoBenchmarks() Option {
+	return func(o *options) { o.noBench = true }
+}
+
+// FillSize sets the size of the values that the generated tests and benchmarks encode and decode:
+// the strings and byte slices in them have n random letters, and their slices and maps have n
+// elements. The values are made by functions (named like NewFilledType) printed with the tests;
+// the default size is 8. Setting n to 0 makes the tests and benchmarks use zero values, as they do
+// when the tests are put in the external test package or benchmarks are not generated.
+func FillSize(n int) Option {
+	return func(o *options) { o.fillSize = n }
+}
+
+// filling says if fill functions are printed for the tests and benchmarks.
+func (o *options) filling() bool {
+	return o.fillSize > 0 && !o.noBench && !o.externalTests
+}
+
+// MaxComplexity sets the limit on the complexity of the types that are inlined into the methods
+// of the types referring to them: a type is inlined only if its complexity (roughly the number of
+// elements in its definition) is less than n. The default is 5. Setting n to 0 turns off inlining
+// except for the types listed in //msgp:inline directives.
+func MaxComplexity(n int) Option {
+	return func(o *options) { o.maxComplex = n }
+}
+
+// Shims loads the //msgp:shim directives in the given Go source files and applies them before
+// the directives in the source being processed, so that the shims for commonly used types can be
+// shared by many packages. Shims in these files for types not declared in the source being
+// processed only change how the types are handled where they are used; no methods are generated
+// for them. Other directives in the files are ignored.
+func Shims(paths ...string) Option {
+	return func(o *options) { o.shims = append(o.shims, paths...) }
+}
+
+// Workers sets the number of types whose methods are generated concurrently. The default is the
+// value of runtime.GOMAXPROCS. The output does not depend on the number of workers.
+func Workers(n int) Option {
+	return func(o *options) { o.workers = n }
+}
+
+// Tags sets the build tags that are satisfied (in addition to GOOS and GOARCH, which are taken from
+// the environment) when the files of a directory are selected. The files that would not be built
+// with these tags are not processed. The generated files have the build constraint shared by the
+// files processed; the types of a file with a further constraint are skipped with a warning, since
+// their methods would be built without them.
+func Tags(tags ...string) Option {
+	return func(o *options) { o.tags = append(o.tags, tags...) }
+}
+
+// ExactSizes adds a MsgsizeExact method to the generated Sizer methods. Unlike Msgsize, which is a
+// cheap upper bound, MsgsizeExact walks the value (measuring strings and the magnitudes of integers,
+// for example) to compute the exact encoded size. The named types referred to in the processed
+// types must implement msgp.ExactSizer as well.
+func ExactSizes() Option {
+	return func(o *options) { o.exactSizes = true }
+}
+
+// TightSizes makes the generated Msgsize methods measure strings, byte slices, map keys, integers,
+// and the headers of slices and maps as MsgsizeExact does, rather than adding the maximum encoded
+// size of each value's type. The bounds are much tighter for values with many short strings or small
+// integers (such as maps of strings), so that buffers sized with Msgsize waste less memory, but
+// computing them takes longer: the elements of slices of integers are visited rather than counted,
+// for example. The named types and interfaces referred to are still sized with Msgsize and
+// msgp.GuessSize, and the types with a constant maximum size still return it.
+func TightSizes() Option {
+	return func(o *options) { o.tightSizes = true }
+}
+
+// CompactFloats makes the generated methods write the float64 values with msgp.Writer.WriteFloat and
+// msgp.AppendFloat, as float32s whenever that loses nothing (as for small integers and fractions like
+// 0.25), which shrinks the messages holding many such values, like telemetry, by 4 bytes a value.
+// The decoders read the float64s written either way. MsgsizeExact measures the values, while Msgsize
+// still counts the full size of every float64. The structs written with the tables of KVFields and
+// the freestanding methods keep writing float64s in full.
+func CompactFloats() Option {
+	return func(o *options) { o.compactFloats = true }
+}
+
+// Views adds, for each struct type written as a map with string keys, a type named like the struct
+// with "View" appended: a []byte holding an encoded value of the struct, with a method for each
+// field returning the field's value. The methods decode only the field they are for, finding it
+// in the map each time, so that reading a few fields of a large value is cheaper than unmarshaling
+// all of it. A field missing from the map is returned as the decoders would set it: zero, with
+// its default value, or with a msgp.ErrMissingField error if it is required.
+func Views() Option {
+	return func(o *options) { o.views = true }
+}
+
+// CrossTests adds to the generated tests a file, named like the tests file with "_cross" inserted
+// before "_test.go", of tests checking that the messages of MarshalMsg are decoded by the library
+// github.com/vmihailenco/msgpack (version 5) and that UnmarshalMsg decodes the messages of the
+// library. The tests are generated for the structs whose fields all have primitive types and no
+// options in their tags. The file has the build constraint "msgpcross", so the library is needed
+// only to run the tests with "go test -tags msgpcross".
+func CrossTests() Option {
+	return func(o *options) { o.crossTests = true }
+}
+
+// Freestanding makes the generated file independent of the msgp package, for the programs (such as
+// those built with TinyGo for small devices) that can use the MessagePack format but not depend on
+// the package. Only the structs whose fields are all integers, floats, or bools (or named types of
+// them), with no options in their tags, are processed; the other types are left out with warnings.
+// The structs get MarshalMsg, UnmarshalMsg, and Msgsize methods, which append and read the fields
+// with unexported functions printed in the file and write the same messages as the methods
+// generated without this option. Encoders and decoders, which need msgp.Writer and msgp.Reader,
+// and View types are not generated. The freestanding methods ignore LenientFloats and LenientBools
+// (with a warning). Since the functions are printed in every file generated with this option, only
+// one such file can be generated for a package.
+func Freestanding() Option {
+	return func(o *options) { o.freestanding = true }
+}
+
+// WrapErrors makes the generated DecodeMsg and UnmarshalMsg methods return the errors of decoding
+// the fields of structs, the elements of slices and arrays, and the values of maps wrapped with
+// msgp.WrapError in *msgp.PathError errors holding the path to the bad value, such as the field
+// name, element index, and field name in "Items/3/Name". The callers comparing the errors with
+// the errors of the msgp package, such as msgp.ErrShortBytes, unwrap them with msgp.Cause.
+func WrapErrors() Option {
+	return func(o *options) { o.wrapErrors = true }
+}
+
+// Schema makes Run also write a JSON Schema document describing the messages of the types, so that
+// a service can publish its message contracts. The file is named like the output file with the
+// suffix _schema.json in place of .go, and it has a definition for each type under "$defs", with
+// its fields named by their keys. The MessagePack values are described as in their conversion to
+// JSON by msgp.CopyToJSON. RunOutput returns the document.
+func Schema() Option {
+	return func(o *options) { o.schema = true }
+}
+
+// Registry makes the generated code register a msgp.TypeSchema for each type, listing the names,
+// keys, and MessagePack types of the fields of the structs, with msgp.RegisterSchema in an init
+// function. Tools can then look up with msgp.LookupSchema how to interpret the messages of a type
+// by its name, which is qualified by the import path of the package (or by the package name if
+// the path cannot be found). Nothing is registered with Freestanding.
+func Registry() Option {
+	return func(o *options) { o.registry = true }
+}
+
+// FieldConsts makes the generator also print for each struct written as a map a constant holding
+// the key of each field, named for the struct and the field (such as PointFieldX for the field X of
+// Point), so that the code reading the messages as generic maps or locating fields with
+// msgp.LocateField and the like need not repeat the keys. The constants of the structs with
+// integer keys (see the intkeys directive) are untyped integers.
+func FieldConsts() Option {
+	return func(o *options) { o.fieldConsts = true }
+}
+
+// SQL makes the generator also print for each type a Value method implementing driver.Valuer and a
+// Scan method implementing sql.Scanner, so that the values can be stored in database columns (such
+// as BLOB columns) as their MessagePack encodings. Value returns the message of MarshalMsg, and
+// Scan decodes with UnmarshalMsg a []byte or a string (see msgp.ScanBytes) and sets the value to
+// zero for a NULL. The methods are printed only with both Marshal and Unmarshal, and not with
+// Freestanding.
+func SQL() Option {
+	return func(o *options) { o.sql = true }
+}
+
+// IdentPrefix sets the prefix of the names of the variables declared in the generated methods.
+// The names are the prefix followed by "a" (for the variables holding elements, such as indexes)
+// or "b" (for the other variables) and a suffix. The default prefix is "z"; the prefix must make a
+// valid identifier.
+func IdentPrefix(prefix string) Option {
+	return func(o *options) { o.identPrefix = prefix }
+}
+
+// Receiver sets the name of the receivers of the generated methods, which is "z" by default. The
+// //msgp:receiver directive sets the name for a single type. The name must not be one of the names
+// of the parameters, results, and packages in the generated methods (b, bts, bytes, c, dc, en, err,
+// field, msgp, o, and s) or begin like the names of the variables declared in them.
+func Receiver(name string) Option {
+	return func(o *options) { o.receiver = name }
+}
+
+// StableIdents makes the names of the variables declared in the generated methods derived from
+// what the variables are for (such as the path of the field they hold) instead of numbered in the
+// order they are declared in. The names are longer, but adding, removing, or changing a field
+// changes only the names declared for that field, so the changes to the generated code are easier
+// to review.
+func StableIdents() Option {
+	return func(o *options) { o.stableIdents = true }
+}
+
+// KeySwitch sets the number of fields from which the decoders of the structs written as maps
+// match the keys to the fields by switching on the length of each key and then on the bytes at
+// which the fields' keys differ, rather than by switching on the key as a string. The default is
+// 16; a number of 0 or less makes every struct use the string switch.
+func KeySwitch(fields int) Option {
+	return func(o *options) { o.keySwitch = fields }
+}
+
+// KVFields makes the encoders and marshalers of the structs with at least the given number of
+// fields, written as maps with string keys, write the fields with a single call to
+// msgp.Writer.WriteKV or msgp.AppendKV passing a table of the keys and values, rather than with
+// code for each field, so that the generated code (and the binaries) of packages with many large
+// types is much smaller. Writing the values as interfaces is slower and may allocate, so it is off
+// by default (with 0 or less). A struct is written field by field if it has a field that can be
+// omitted or whose value is not written by msgp.Writer.WriteIntf (such as a pointer, slice, map,
+// or extension) as the generated code writes it.
+func KVFields(fields int) Option {
+	return func(o *options) { o.kvFields = fields }
+}
+
+// OldSpecCompat makes the generated decoders read 'str' and 'bin' objects interchangeably into
+// strings and byte slices (and arrays), as needed to decode the data of the producers following
+// the old MessagePack spec, which wrote binary data as strings. The decoders call the OldSpec
+// variants of the functions and methods reading strings and bytes, such as
+// msgp.ReadBytesOldSpecBytes, rather than relying on Reader.SetOldSpec.
+func OldSpecCompat() Option {
+	return func(o *options) { o.oldSpec = true }
+}
+
+// LenientFloats makes the generated decoders read float64 values with msgp.Reader.ReadFloat64Lenient
+// and msgp.ReadFloat64BytesLenient, which accept integers too, as written for whole numbers (like 1.0)
+// by the producers that do not keep the types of numbers, such as many in Python. The freestanding
+// methods still read only floats.
+func LenientFloats() Option {
+	return func(o *options) { o.lenientFloats = true }
+}
+
+// LenientBools makes the generated decoders read bools with msgp.Reader.ReadBoolLenient and
+// msgp.ReadBoolBytesLenient, which accept the integers 0 and 1 too, as written for bools by many
+// producers in C. The freestanding methods still read only bools.
+func LenientBools() Option {
+	return func(o *options) { o.lenientBools = true }
+}
+
+// Strict makes Run and RunData fail with a WarningsError, without writing or returning the
+// generated code, if any warnings (such as about fields that are ignored or directives that
+// cannot be applied) are reported while processing the source.
+func Strict() Option {
+	return func(o *options) { o.strict = true }
+}
+
+// HandleWarnings passes the warnings reported while processing the source to f (with their
+// positions in the source, if known) instead of printing them to the standard output. The calls
+// to f are not concurrent.
+func HandleWarnings(f func(Diagnostic)) Option {
+	return func(o *options) { o.warn = f }
+}
+
+// testsFor says if tests should be generated for any of the methods in m.
+func (o *options) testsFor(m Method) bool {
+	return o.testMethods == 0 || o.testMethods&m != 0
+}
+
+// workerCount returns the number of goroutines to print the given number of types with.
+func (o *options) workerCount(types int) int {
+	n := o.workers
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if n > types {
+		n = types
+	}
+	return n
+}
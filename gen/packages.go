@@ -0,0 +1,260 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// methodNames gives the name of the method that each pass calls on the types that are not
+// printed with the code for the types referring to them.
+var methodNames = [...]struct {
+	m    Method
+	name string
+	intf string
+}{
+	{Decode, "DecodeMsg", "msgp.Decoder"},
+	{Encode, "EncodeMsg", "msgp.Encoder"},
+	{Marshal, "MarshalMsg", "msgp.Marshaler"},
+	{Unmarshal, "UnmarshalMsg", "msgp.Unmarshaler"},
+	{Size, "Msgsize", "msgp.Sizer"},
+	{Copy, "Clone", "Clone"},
+	{Equal, "Equal", "Equal"},
+}
+
+// An importedRef is an element referring to a named type from another package, or an array whose
+// length refers to a constant from another package.
+type importedRef struct {
+	ref   *Elem
+	where string // the type and field the element is in, for diagnostics
+	pkg   string // the package name (or the name the package is imported as)
+	name  string // the name of the type or constant in the package
+	size  bool   // the name is of a constant in the length of the array at ref
+}
+
+// resolveImported looks up the types from other packages (such as pkg.T) used in the types in s
+// by loading the packages imported by the source in srcPath, and it checks that the names from
+// other packages in the lengths of arrays are of integer constants. The types that have the methods the
+// code for mode calls on them are left alone; the types with a primitive underlying type are
+// converted to and from that type like the types declared in the source. An error describes
+// every other such type used. If the packages cannot be loaded, the types are assumed to have
+// the methods, and a warning is logged.
+func (s *source) resolveImported(srcPath string, mode Method) error {
+	names := make([]string, 0, len(s.identities))
+	for name := range s.identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var refs []importedRef
+	for _, name := range names {
+		el := s.identities[name]
+		refs = findImported(&el, name, refs)
+		s.identities[name] = el
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	pkgs, err := s.loadImports(srcPath)
+	if err != nil {
+		warnf("cannot look up the imported types: %s\n", err)
+		return nil
+	}
+
+	var problems []string
+	for _, r := range refs {
+		p, ok := pkgs[r.pkg]
+		if !ok {
+			what := "type"
+			if r.size {
+				what = "constant"
+			}
+			warnfAt((*r.ref).Pos(), "cannot look up the %s %s.%s: package %s not loaded\n", what, r.pkg, r.name, r.pkg)
+			continue
+		}
+		if r.size {
+			if problem := r.checkConst(p); problem != "" {
+				problems = append(problems, problem)
+			}
+			continue
+		}
+		typ := r.pkg + "." + r.name
+		obj, ok := p.Types.Scope().Lookup(r.name).(*types.TypeName)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: %s is not a type declared in package %q", r.located(), typ, p.PkgPath))
+			continue
+		}
+		missing := missingMethods(obj.Type(), mode)
+		if len(missing) == 0 {
+			continue
+		}
+		if be := primitiveOf(obj.Type()); be != nil {
+			be.Alias(typ)
+			*r.ref = be
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s from package %q does not implement %s; "+
+			"generate its methods with msgp or give it a shim", r.located(), typ, p.PkgPath, strings.Join(missing, ", ")))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// located returns where r is, prefixed with its position in the source if known.
+func (r *importedRef) located() string { return located((*r.ref).Pos(), r.where) }
+
+// checkConst returns the problem with the constant r refers to in the length of an array, or an
+// empty string if the constant, declared in p, is an integer.
+func (r *importedRef) checkConst(p *packages.Package) string {
+	name := r.pkg + "." + r.name
+	c, ok := p.Types.Scope().Lookup(r.name).(*types.Const)
+	if !ok {
+		return fmt.Sprintf("%s: %s, in the length of an array, is not a constant declared in package %q",
+			r.located(), name, p.PkgPath)
+	}
+	if constant.ToInt(c.Val()).Kind() != constant.Int {
+		return fmt.Sprintf("%s: %s, in the length of an array, is not an integer (it is %s)", r.located(), name, c.Val())
+	}
+	return ""
+}
+
+// findImported appends to refs the elements in the tree at ref that refer to types from other
+// packages, and the arrays whose lengths refer to constants from other packages.
+func findImported(ref *Elem, where string, refs []importedRef) []importedRef {
+	switch el := (*ref).(type) {
+	case *BaseElem:
+		if el.Value != IDENT || el.Resolved() {
+			return refs
+		}
+		if i := strings.IndexByte(el.TypeName(), '.'); i > 0 {
+			refs = append(refs, importedRef{
+				ref:   ref,
+				where: where,
+				pkg:   el.TypeName()[:i],
+				name:  el.TypeName()[i+1:],
+			})
+		}
+	case *Struct:
+		for i := range el.Fields {
+			refs = findImported(&el.Fields[i].fieldElem, where+"."+el.Fields[i].fieldName, refs)
+		}
+	case *Array:
+		refs = findImported(&el.Els, where, refs)
+		if x, err := parser.ParseExpr(el.Size); err == nil {
+			ast.Inspect(x, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok {
+					if pkg, ok := sel.X.(*ast.Ident); ok {
+						refs = append(refs, importedRef{
+							ref:   ref,
+							where: where,
+							pkg:   pkg.Name,
+							name:  sel.Sel.Name,
+							size:  true,
+						})
+					}
+				}
+				return true
+			})
+		}
+	case *Slice:
+		refs = findImported(&el.Els, where, refs)
+	case *Map:
+		refs = findImported(&el.Value, where, refs)
+	case *Ptr:
+		refs = findImported(&el.Value, where, refs)
+	}
+	return refs
+}
+
+// loadImports loads the packages imported by the source in srcPath and returns them by the names
+// they are referred to with in the source.
+func (s *source) loadImports(srcPath string) (map[string]*packages.Package, error) {
+	dir := srcPath
+	if stat, err := os.Stat(srcPath); err == nil && !stat.IsDir() {
+		dir = filepath.Dir(srcPath)
+	}
+	aliases := make(map[string]string) // import path -> name given in the import
+	var paths []string
+	for _, imp := range s.imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || path == "C" {
+			continue
+		}
+		if imp.Name != nil {
+			if imp.Name.Name == "_" || imp.Name.Name == "." {
+				continue
+			}
+			aliases[path] = imp.Name.Name
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes,
+		Dir:  dir,
+	}
+	loaded, err := packages.Load(cfg, paths...)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make(map[string]*packages.Package, len(loaded))
+	for _, p := range loaded {
+		if len(p.Errors) > 0 {
+			warnf("cannot load package %q: %s\n", p.PkgPath, p.Errors[0])
+			continue
+		}
+		name := p.Name
+		if alias, ok := aliases[p.PkgPath]; ok {
+			name = alias
+		}
+		pkgs[name] = p
+	}
+	return pkgs, nil
+}
+
+// missingMethods returns the interfaces with the methods called by the code for mode that
+// values of type t (or pointers to them) do not implement.
+func missingMethods(t types.Type, mode Method) []string {
+	mset := types.NewMethodSet(types.NewPointer(t))
+	var missing []string
+	for _, mn := range methodNames {
+		if mode.isSet(mn.m) && mset.Lookup(nil, mn.name) == nil {
+			missing = append(missing, mn.intf)
+		}
+	}
+	return missing
+}
+
+// primitiveOf returns an element for a named type whose underlying type is a primitive, or nil
+// if the underlying type is not a primitive.
+func primitiveOf(t types.Type) *BaseElem {
+	var name string
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		name = u.Name()
+	case *types.Slice:
+		if b, ok := u.Elem().(*types.Basic); ok && b.Kind() == types.Byte {
+			name = "[]byte"
+		}
+	}
+	p, ok := primitives[name]
+	if !ok || p == Intf || p == Ext {
+		return nil
+	}
+	return &BaseElem{Value: p}
+}
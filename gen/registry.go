@@ -0,0 +1,131 @@
+package gen
+
+import (
+	"io"
+	"strconv"
+)
+
+func registry(w io.Writer, o *options) *registryGen {
+	return &registryGen{p: printer{w: w}, o: o}
+}
+
+// A registryGen prints for each type an init function registering the table of the fields of the
+// type with msgp.RegisterSchema, so that tools can interpret the messages of the type by its name.
+type registryGen struct {
+	passes
+	p printer
+	o *options
+}
+
+func (r *registryGen) Method() Method { return Encode | Marshal }
+
+func (r *registryGen) Execute(p Elem) error {
+	if !r.p.ok() {
+		return r.p.err
+	}
+	p = r.applyAll(p)
+	if p == nil || !isPrintable(p) {
+		return nil
+	}
+	r.p.printf("\nfunc init() {\nmsgp.RegisterSchema(msgp.TypeSchema{Name: %q, Type: %s",
+		r.o.registryQual+p.TypeName(), r.wireType(p, 0))
+	if s, ok := p.(*Struct); ok && len(s.Fields) > 0 {
+		r.p.print(", Fields: []msgp.FieldSchema{")
+		for i := range s.Fields {
+			f := &s.Fields[i]
+			r.p.printf("\n{Name: %q", f.fieldName)
+			num := i
+			if !s.AsTuple {
+				r.p.printf(", Key: %q", f.fieldTag)
+				if s.IntKeys {
+					num, _ = strconv.Atoi(f.fieldTag)
+				}
+			}
+			r.p.printf(", Num: %d, Type: %s", num, r.wireType(f.fieldElem, 0))
+			if name := r.schemaName(f.fieldElem); name != "" {
+				r.p.printf(", Schema: %q", name)
+			}
+			r.p.print("},")
+		}
+		r.p.print("\n}")
+	}
+	r.p.print("})\n}\n")
+	return r.p.err
+}
+
+// wireType returns the msgp.Type constant of the objects that the values of e are written as. The
+// types of the source referred to by name are looked up to a limited depth, as a type can be
+// declared with the name of another one.
+func (r *registryGen) wireType(e Elem, depth int) string {
+	switch e := e.(type) {
+	case *Struct:
+		if e.AsTuple {
+			return "msgp.ArrayType"
+		}
+		return "msgp.MapType"
+	case *Array:
+		if b, ok := e.Els.(*BaseElem); ok && (b.Value == Byte || b.Value == Uint8) && b.ShimToBase == "" {
+			if e.Str {
+				return "msgp.StrType"
+			}
+			return "msgp.BinType"
+		}
+		return "msgp.ArrayType"
+	case *Slice:
+		return "msgp.ArrayType"
+	case *Map:
+		return "msgp.MapType"
+	case *Ptr:
+		return r.wireType(e.Value, depth)
+	case *BaseElem:
+		switch e.Value {
+		case String, Text, StrBytes:
+			return "msgp.StrType"
+		case Bytes, Binary, Padded:
+			return "msgp.BinType"
+		case Bool:
+			return "msgp.BoolType"
+		case Float32:
+			return "msgp.Float32Type"
+		case Float64:
+			return "msgp.Float64Type"
+		case Complex64:
+			return "msgp.Complex64Type"
+		case Complex128:
+			return "msgp.Complex128Type"
+		case Int, Int8, Int16, Int32, Int64:
+			return "msgp.IntType"
+		case Uint, Uint8, Uint16, Uint32, Uint64, Byte:
+			return "msgp.UintType"
+		case Time:
+			return "msgp.TimeType"
+		case Ext, Decimal:
+			return "msgp.ExtensionType"
+		case IDENT:
+			if t, ok := r.o.types[e.TypeName()]; ok && depth < 8 {
+				return r.wireType(t, depth+1)
+			}
+		}
+	}
+	// Interfaces, raw objects, and the types of other packages.
+	return "msgp.InvalidType"
+}
+
+// schemaName returns the registered name of the type of e, or of the elements of e if e is a slice,
+// array, map, or pointer, if it is a type of the source; otherwise it returns "".
+func (r *registryGen) schemaName(e Elem) string {
+	if _, ok := r.o.types[e.TypeName()]; ok {
+		return r.o.registryQual + e.TypeName()
+	}
+	switch e := e.(type) {
+	case *Array:
+		return r.schemaName(e.Els)
+	case *Slice:
+		return r.schemaName(e.Els)
+	case *Map:
+		return r.schemaName(e.Value)
+	case *Ptr:
+		return r.schemaName(e.Value)
+	}
+	return ""
+}
@@ -11,7 +11,15 @@
 //
 //  import "github.com/dchenk/msgp/gen"
 //
-//  err := gen.Run("path/to/my_file.go", gen.Size|gen.Marshal|gen.Unmarshal|gen.Test, false)
+//  err := gen.Run("path/to/my_file.go", "", gen.Size|gen.Marshal|gen.Unmarshal|gen.Test, false)
+//
+// Programs setting many options, such as build systems, can fill in a Config and call RunConfig:
+//
+//  err := gen.RunConfig(gen.Config{
+//      Src:       "path/to/my_file.go",
+//      Mode:      gen.Size | gen.Marshal | gen.Unmarshal,
+//      BuildTags: []string{"integration"},
+//  })
 //
 package gen
 
@@ -19,9 +27,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"go/build"
+	"go/build/constraint"
+	"go/token"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/ttacon/chalk"
@@ -32,10 +45,11 @@ import (
 // path can be left blank to have a file created at old_name_gen.go (_gen appended to the old name; the
 // test file, if you opt to create one, will be at old_name_gen_test.go). The mode is the set of Method
 // types and tests you would like. Set unexported to true if you want code to be generated for unexported
-// as well as for exported types.
-func Run(srcPath string, outputPath string, mode Method, unexported bool) error {
+// as well as for exported types. Any options given change the default behavior of the generator; with
+// OutDir, the test files are written to another directory.
+func Run(srcPath string, outputPath string, mode Method, unexported bool, opts ...Option) error {
 
-	mainBuf, testsBuf, err := RunData(srcPath, mode, unexported)
+	mainBuf, testsBuf, crossBuf, schema, err := run(srcPath, mode, unexported, opts)
 	if err != nil {
 		return err
 	}
@@ -59,27 +73,112 @@ func Run(srcPath string, outputPath string, mode Method, unexported bool) error
 		doneErr <- formatWrite(outputPath, mainBuf.Bytes())
 	}()
 
+	// The test files are named for the output file, in the directory set with OutDir if any.
+	testsPrefix := strings.TrimSuffix(outputPath, ".go")
+	if dir := newOptions(opts).outDir; dir != "" && testsBuf != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		testsPrefix = filepath.Join(dir, filepath.Base(testsPrefix))
+	}
+
 	if testsBuf != nil {
-		testFileName := strings.TrimSuffix(outputPath, ".go") + "_test.go"
+		testFileName := testsPrefix + "_test.go"
 		if err := formatWrite(testFileName, testsBuf.Bytes()); err != nil {
 			return err
 		}
 	}
 
+	if crossBuf != nil {
+		crossFileName := testsPrefix + "_cross_test.go"
+		if err := formatWrite(crossFileName, crossBuf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if schema != nil {
+		schemaFileName := strings.TrimSuffix(outputPath, ".go") + "_schema.json"
+		if err := ioutil.WriteFile(schemaFileName, schema, 0600); err != nil {
+			return err
+		}
+	}
+
 	return <-doneErr
 
 }
 
 // RunData works just like Run except that, instead of writing out a file, it outputs the generated file's contents,
 // the corresponding generated test file (nil if mode does not include gen.Test), and a possibly nil error.
-func RunData(srcPath string, mode Method, unexported bool) (mainBuf *bytes.Buffer, testsBuf *bytes.Buffer, err error) {
+func RunData(srcPath string, mode Method, unexported bool, opts ...Option) (mainBuf *bytes.Buffer, testsBuf *bytes.Buffer, err error) {
+	mainBuf, testsBuf, _, _, err = run(srcPath, mode, unexported, opts)
+	return
+}
+
+// An Output holds the contents of the files generated by RunOutput.
+type Output struct {
+	Main       *bytes.Buffer // the methods
+	Tests      *bytes.Buffer // the tests (nil if mode does not include gen.Test)
+	CrossTests *bytes.Buffer // the cross tests (nil unless they are generated; see CrossTests)
+	Schema     []byte        // the JSON Schema document (nil without the Schema option)
+}
+
+// RunOutput works like RunData but returns the contents of all of the files that Run would write,
+// including the cross tests file and the JSON Schema document, which RunData leaves out.
+func RunOutput(srcPath string, mode Method, unexported bool, opts ...Option) (*Output, error) {
+	mainBuf, testsBuf, crossBuf, schema, err := run(srcPath, mode, unexported, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Output{Main: mainBuf, Tests: testsBuf, CrossTests: crossBuf, Schema: schema}, nil
+}
+
+// run generates the files for Run and RunData. The cross tests file (see CrossTests) is nil unless
+// tests are generated with the CrossTests option for types that can be cross tested, and the JSON
+// Schema document is nil unless the Schema option is given.
+func run(srcPath string, mode Method, unexported bool, opts []Option) (mainBuf, testsBuf, crossBuf *bytes.Buffer, schema []byte, err error) {
+
+	o := newOptions(opts)
+
+	beginWarnings(o.warn)
+	defer func() {
+		warnings := endWarnings()
+		if err == nil && o.strict && len(warnings) > 0 {
+			mainBuf, testsBuf, crossBuf, schema, err = nil, nil, nil, nil, WarningsError(warnings)
+		}
+	}()
 
 	if mode&^Test == 0 {
 		err = errors.New("no methods to generate; -io=false and -marshal=false")
 		return
 	}
 
-	s, err := newSource(srcPath, unexported)
+	if mode.isSet(Test) && o.testMethods != 0 && mode&o.testMethods == 0 {
+		err = errors.New("no tests to generate; -tests=marshal-only with -marshal=false or -tests=encode-only with -io=false")
+		return
+	}
+
+	if !token.IsIdentifier(o.identPrefix + "a") {
+		err = fmt.Errorf("the identifier prefix %q does not make valid identifiers", o.identPrefix)
+		return
+	}
+
+	if err = checkReceiver(o.receiver, o.identPrefix); err != nil {
+		return
+	}
+
+	if o.outDir != "" {
+		if !token.IsIdentifier(o.testsPackage()) {
+			err = fmt.Errorf("the package name %q of the tests in %s is not an identifier; set it with -outpkg", o.testsPackage(), o.outDir)
+			return
+		}
+		// The tests in another package use only the exported API, as external tests do.
+		o.externalTests = true
+	} else if o.outPkg != "" {
+		err = errors.New("the package of the tests is set with -outpkg but their directory is not set with -outdir")
+		return
+	}
+
+	s, err := newSource(srcPath, unexported, o)
 	if err != nil {
 		return
 	}
@@ -89,13 +188,47 @@ func RunData(srcPath string, mode Method, unexported bool) (mainBuf *bytes.Buffe
 		return
 	}
 
+	if err = s.resolveImported(srcPath, mode); err != nil {
+		return
+	}
+
+	if o.freestanding {
+		if o.lenientFloats || o.lenientBools {
+			warnln("the freestanding methods read floats and bools strictly; the lenient options are ignored")
+		}
+		s.dropNotFreestanding()
+		if len(s.identities) == 0 {
+			err = errors.New("no types can have freestanding methods")
+			return
+		}
+		mode = mode&^(Encode|Decode) | Marshal | Unmarshal | Size
+	}
+
+	if o.registry && !o.freestanding {
+		if path, err := importPath(srcPath); err == nil {
+			o.registryQual = path + "."
+		} else {
+			o.registryQual = s.pkg + "."
+		}
+	}
+
 	fmt.Println(chalk.Magenta.Color("======= MessagePack Code Generating ======="))
 	fmt.Printf(chalk.Magenta.Color("   Input: %s\n"), srcPath)
 
 	mainBuf = bytes.NewBuffer(make([]byte, 0, 4096))
-	writePkgHeader(mainBuf, s.pkg)
+	writePkgHeader(mainBuf, s.pkg, s.constraint)
 
 	mainImports := []string{"github.com/dchenk/msgp/msgp"}
+	if o.freestanding {
+		mainImports = []string{"errors", "math"}
+	}
+	if mode.isSet(Equal) {
+		// The Equal methods compare byte slices with bytes.Equal.
+		mainImports = append(mainImports, `"bytes"`)
+	}
+	if o.sql && mode.isSet(Marshal|Unmarshal) && !o.freestanding {
+		mainImports = append(mainImports, `"database/sql/driver"`)
+	}
 	for _, imp := range s.imports {
 		if imp.Name != nil {
 			// If the import has an alias, include it (imp.Path.Value is a quoted string).
@@ -122,19 +255,50 @@ func RunData(srcPath string, mode Method, unexported bool) (mainBuf *bytes.Buffe
 	}
 
 	writeImportHeader(mainBuf, mainImports)
+	if o.freestanding {
+		mainBuf.WriteString(freestandingHelpers)
+	}
 
 	// Write the test file if it's desired.
 	if mode&Test == Test {
 		testsBuf = bytes.NewBuffer(make([]byte, 0, 4096))
-		writePkgHeader(testsBuf, s.pkg)
-		neededImports := []string{"github.com/dchenk/msgp/msgp", "testing"}
-		if mode&(Encode|Decode) != 0 {
-			neededImports = append(neededImports, "bytes")
+		neededImports := []string{"github.com/dchenk/msgp/msgp/msgptest", "math/rand", "testing"}
+		testsPkg := s.pkg
+		if o.externalTests {
+			var pkgPath string
+			pkgPath, err = importPath(srcPath)
+			if err != nil {
+				return
+			}
+			testsPkg += "_test"
+			if o.outDir != "" {
+				testsPkg = o.testsPackage()
+			}
+			neededImports = append(neededImports, pkgPath)
+			o.testQual = s.pkg + "."
 		}
+		writePkgHeader(testsBuf, testsPkg, s.constraint)
 		writeImportHeader(testsBuf, neededImports)
+
+		if o.crossTests {
+			crossBuf = bytes.NewBuffer(make([]byte, 0, 4096))
+			writePkgHeader(crossBuf, testsPkg, crossTestConstraint(s.constraint))
+			writeImportHeader(crossBuf, append(neededImports, "bytes", "github.com/vmihailenco/msgpack/v5"))
+		}
 	}
 
-	err = s.printTo(newGeneratorSet(mode, mainBuf, testsBuf))
+	var crossHeader int
+	if crossBuf != nil {
+		crossHeader = crossBuf.Len()
+	}
+	err = s.printTo(mode, o, mainBuf, testsBuf, crossBuf)
+	if crossBuf != nil && crossBuf.Len() == crossHeader {
+		// None of the types can be cross tested.
+		crossBuf = nil
+	}
+	if err == nil && o.schema {
+		schema, err = s.schema()
+	}
 
 	return
 
@@ -151,7 +315,65 @@ func formatWrite(fileName string, data []byte) error {
 	return ioutil.WriteFile(fileName, out, 0600)
 }
 
-func writePkgHeader(b *bytes.Buffer, name string) {
+// importPath finds the import path of the package at srcPath (a file or a directory). The path is
+// derived from the module path declared in the nearest go.mod file in or above the package directory
+// or, if there is no such file, from the location of the package within GOPATH.
+func importPath(srcPath string) (string, error) {
+	dir, err := filepath.Abs(srcPath)
+	if err != nil {
+		return "", err
+	}
+	if stat, err := os.Stat(dir); err != nil {
+		return "", err
+	} else if !stat.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	for d := dir; ; {
+		if data, err := ioutil.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			mod := modulePath(data)
+			if mod == "" {
+				return "", fmt.Errorf("no module path found in %s", filepath.Join(d, "go.mod"))
+			}
+			rel, err := filepath.Rel(d, dir)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(mod, filepath.ToSlash(rel)), nil
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+
+	pkg, err := build.ImportDir(dir, build.FindOnly)
+	if err != nil {
+		return "", err
+	}
+	if pkg.ImportPath == "" || pkg.ImportPath == "." || strings.HasPrefix(pkg.ImportPath, "_") {
+		return "", fmt.Errorf("cannot determine the import path of the package in %s", dir)
+	}
+	return pkg.ImportPath, nil
+}
+
+// modulePath returns the module path declared in the contents of a go.mod file.
+func modulePath(mod []byte) string {
+	for _, line := range strings.Split(string(mod), "\n") {
+		f := strings.Fields(line)
+		if len(f) >= 2 && f[0] == "module" {
+			if p, err := strconv.Unquote(f[1]); err == nil {
+				return p
+			}
+			return f[1]
+		}
+	}
+	return ""
+}
+
+func writePkgHeader(b *bytes.Buffer, name string, x constraint.Expr) {
+	writeConstraint(b, x)
 	b.WriteString("package " + name)
 	b.WriteString("\n// THIS FILE WAS PRODUCED BY THE MSGP CODE GENERATION TOOL (github.com/dchenk/msgp).\n// DO NOT EDIT.\n\n")
 }
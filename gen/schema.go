@@ -0,0 +1,150 @@
+package gen
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// schemaDialect is the version of JSON Schema of the schemas written with the Schema option.
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// A schemaObj is a JSON Schema object.
+type schemaObj map[string]interface{}
+
+// schema returns the JSON Schema document describing the encodings of the types of s, with a
+// definition for each type under "$defs". The MessagePack types are described by the JSON types
+// of their conversions to JSON (see msgp.CopyToJSON): binary data is a base64 string and times
+// are strings of the RFC 3339 format. The keys of the maps are sorted.
+func (s *source) schema() ([]byte, error) {
+	names := make([]string, 0, len(s.identities))
+	for name := range s.identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	defs := make(map[string]schemaObj, len(names))
+	for _, name := range names {
+		d := s.defSchema(s.identities[name])
+		d["title"] = name
+		defs[name] = d
+	}
+	return json.MarshalIndent(schemaObj{"$schema": schemaDialect, "$defs": defs}, "", "  ")
+}
+
+// elemSchema returns the schema of the encoding of e, which refers to the definition of the type
+// of e if it is a type of the source, even if it is inlined.
+func (s *source) elemSchema(e Elem) schemaObj {
+	if _, ok := s.identities[e.TypeName()]; ok {
+		return schemaObj{"$ref": "#/$defs/" + e.TypeName()}
+	}
+	return s.defSchema(e)
+}
+
+// defSchema returns the schema of the encoding of e, described by the type of e.
+func (s *source) defSchema(e Elem) schemaObj {
+	switch e := e.(type) {
+	case *Struct:
+		return s.structSchema(e)
+	case *Slice:
+		return schemaObj{"type": "array", "items": s.elemSchema(e.Els)}
+	case *Array:
+		if b, ok := e.Els.(*BaseElem); ok && (b.Value == Byte || b.Value == Uint8) && b.ShimToBase == "" {
+			if e.Str {
+				return schemaObj{"type": "string"}
+			}
+			return schemaObj{"type": "string", "contentEncoding": "base64"}
+		}
+		a := schemaObj{"type": "array", "items": s.elemSchema(e.Els)}
+		if n, err := strconv.Atoi(e.Size); err == nil {
+			// The length can be a named constant, which is not resolved.
+			a["minItems"], a["maxItems"] = n, n
+		}
+		return a
+	case *Map:
+		return schemaObj{"type": "object", "additionalProperties": s.elemSchema(e.Value)}
+	case *Ptr:
+		return schemaObj{"anyOf": []schemaObj{{"type": "null"}, s.elemSchema(e.Value)}}
+	case *BaseElem:
+		return s.baseSchema(e)
+	}
+	return schemaObj{}
+}
+
+// structSchema returns the schema of a struct written as a map (an object) or as an array. Only the
+// fields tagged required are required, as the decoders accept messages missing the other fields.
+func (s *source) structSchema(st *Struct) schemaObj {
+	if st.AsTuple {
+		items := make([]schemaObj, len(st.Fields))
+		for i := range st.Fields {
+			items[i] = s.elemSchema(st.Fields[i].fieldElem)
+		}
+		o := schemaObj{"type": "array", "prefixItems": items, "maxItems": len(items)}
+		if !st.TupleCompat {
+			o["minItems"] = len(items)
+		}
+		return o
+	}
+	props := make(map[string]schemaObj, len(st.Fields))
+	var required []string
+	for i := range st.Fields {
+		f := &st.Fields[i]
+		props[f.fieldTag] = s.elemSchema(f.fieldElem)
+		if f.required {
+			required = append(required, f.fieldTag)
+		}
+	}
+	o := schemaObj{"type": "object", "properties": props}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	if st.Union {
+		o["maxProperties"] = 1
+	}
+	if st.IntKeys {
+		o["$comment"] = "The keys are written as integers."
+	}
+	return o
+}
+
+// baseSchema returns the schema of the encoding of a primitive or of a named type, which may be
+// encoded as anything.
+func (s *source) baseSchema(b *BaseElem) schemaObj {
+	switch b.Value {
+	case String, Text, StrBytes:
+		return schemaObj{"type": "string"}
+	case Bytes, Binary, Padded:
+		return schemaObj{"type": "string", "contentEncoding": "base64"}
+	case Bool:
+		return schemaObj{"type": "boolean"}
+	case Float32, Float64:
+		return schemaObj{"type": "number"}
+	case Time:
+		return schemaObj{"type": "string", "format": "date-time"}
+	case Int8:
+		return intSchema(math.MinInt8, math.MaxInt8)
+	case Int16:
+		return intSchema(math.MinInt16, math.MaxInt16)
+	case Int32:
+		return intSchema(math.MinInt32, math.MaxInt32)
+	case Int, Int64:
+		return intSchema(math.MinInt64, math.MaxInt64)
+	case Uint8, Byte:
+		return intSchema(0, math.MaxUint8)
+	case Uint16:
+		return intSchema(0, math.MaxUint16)
+	case Uint32:
+		return intSchema(0, math.MaxUint32)
+	case Uint, Uint64:
+		return schemaObj{"type": "integer", "minimum": 0, "maximum": uint64(math.MaxUint64)}
+	case IDENT:
+		// The types of the source are referred to by elemSchema.
+		return schemaObj{"$comment": "Encoded by the methods of " + b.TypeName() + "."}
+	}
+	// Complex numbers, extensions, interfaces, and raw objects.
+	return schemaObj{}
+}
+
+func intSchema(min, max int64) schemaObj {
+	return schemaObj{"type": "integer", "minimum": min, "maximum": max}
+}
@@ -21,17 +21,26 @@ const (
 	expr
 )
 
-func sizes(w io.Writer) *sizeGen {
+func sizes(w io.Writer, ids *identGen, exact, tight, compactFloats bool) *sizeGen {
 	return &sizeGen{
-		p:     printer{w: w},
-		state: assign,
+		p:             printer{w: w, ids: ids},
+		state:         assign,
+		exact:         exact,
+		tight:         tight,
+		compactFloats: compactFloats,
 	}
 }
 
 type sizeGen struct {
 	passes
-	p     printer
-	state sizeState
+	p       printer
+	state   sizeState
+	exact   bool // also print MsgsizeExact
+	tight   bool // measure the values in Msgsize too
+	precise bool // printing MsgsizeExact rather than Msgsize
+	measure bool // measuring the values rather than adding the maximum sizes of their types
+
+	compactFloats bool // float64s are written with msgp.Writer.WriteFloat
 }
 
 func (s *sizeGen) Method() Method { return Size }
@@ -77,12 +86,38 @@ func (s *sizeGen) Execute(p Elem) error {
 		return nil
 	}
 
+	// Types whose maximum size on the wire is known get a constant with the size.
+	fixed, isFixed := fixedSizeExpr(p)
+	if isFixed {
+		s.p.printf("\n// %[1]sMsgMaxSize is the maximum number of bytes occupied by a serialized %[1]s.", p.TypeName())
+		s.p.printf("\nconst %sMsgMaxSize = %s\n", p.TypeName(), fixed)
+	}
+
 	s.p.comment("Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message")
 
 	s.p.printf("\nfunc (%s %s) Msgsize() (s int) {", p.Varname(), imutMethodReceiver(p))
-	s.state = assign
-	next(s, p)
+	if isFixed {
+		s.p.printf("\ns = %sMsgMaxSize", p.TypeName())
+	} else {
+		s.state = assign
+		s.measure = s.tight
+		next(s, p)
+		s.measure = false
+	}
 	s.p.nakedReturn()
+
+	s.p.comment("IsFixedSize says if Msgsize returns a constant, the maximum size of any serialized value of the type")
+	s.p.printf("\nfunc (%s %s) IsFixedSize() bool { return %t }\n", p.Varname(), imutMethodReceiver(p), isFixed)
+
+	if s.exact {
+		s.p.comment("MsgsizeExact returns the exact number of bytes occupied by the serialized message")
+		s.p.printf("\nfunc (%s %s) MsgsizeExact() (s int) {", p.Varname(), imutMethodReceiver(p))
+		s.state = assign
+		s.precise, s.measure = true, true
+		next(s, p)
+		s.precise, s.measure = false, false
+		s.p.nakedReturn()
+	}
 	return s.p.err
 }
 
@@ -103,13 +138,32 @@ func (s *sizeGen) gStruct(st *Struct) {
 			next(s, st.Fields[i].fieldElem)
 		}
 	} else {
-		data := msgp.AppendMapHeader(nil, nfields)
-		s.addConstant(strconv.Itoa(len(data)))
+		var count string
+		var omit []string
+		if s.measure {
+			// Msgsize may count the fields omitted, as the size is an upper bound.
+			if count, omit = s.p.omitted(st); count != "" {
+				s.state = add
+				s.addConstant("msgp.SizeOfMapHeader(" + count + ")")
+			}
+		}
+		if count == "" {
+			data := msgp.AppendMapHeader(nil, nfields)
+			s.addConstant(strconv.Itoa(len(data)))
+		}
 		for i := range st.Fields {
-			data = data[:0]
-			data = msgp.AppendString(data, st.Fields[i].fieldTag)
+			omitted := count != "" && omit[i] != ""
+			if omitted {
+				s.p.printf("\nif !%s {", omit[i])
+				s.state = add
+			}
+			data, _ := fieldKey(st, i)
 			s.addConstant(strconv.Itoa(len(data)))
 			next(s, st.Fields[i].fieldElem)
+			if omitted {
+				s.p.closeBlock()
+				s.state = add
+			}
 		}
 	}
 }
@@ -127,6 +181,18 @@ func (s *sizeGen) gSlice(sl *Slice) {
 		return
 	}
 
+	if s.measure {
+		s.addConstant(fmt.Sprintf("msgp.SizeOfArrayHeader(uint32(%s))", lenExpr(sl)))
+		if str, ok := exactSizeExpr(sl.Els, s.compactFloats); ok {
+			s.addConstant(fmt.Sprintf("(%s * (%s))", lenExpr(sl), str))
+			return
+		}
+		s.state = add
+		s.p.rangeBlock(sl.Index, sl.Varname(), s, sl.Els)
+		s.state = add
+		return
+	}
+
 	s.addConstant(builtinSize(arrayHeader))
 
 	// if the slice's element is a fixed size
@@ -148,7 +214,25 @@ func (s *sizeGen) gArray(a *Array) {
 		return
 	}
 
-	s.addConstant(builtinSize(arrayHeader))
+	if s.measure {
+		if be, ok := a.Els.(*BaseElem); ok && be.Value == Byte {
+			if a.Str {
+				s.addConstant(fmt.Sprintf("msgp.SizeOfStringFromBytes((%s)[:])", a.Varname()))
+			} else {
+				s.addConstant(fmt.Sprintf("msgp.SizeOfBytes((%s)[:])", a.Varname()))
+			}
+			return
+		}
+		s.addConstant(fmt.Sprintf("msgp.SizeOfArrayHeader(%s)", coerceArraySize(a.Size)))
+		if str, ok := exactSizeExpr(a.Els, s.compactFloats); ok {
+			s.addConstant(fmt.Sprintf("(%s * (%s))", arrayLenInt(a.Size), str))
+			return
+		}
+		s.state = add
+		s.p.rangeBlock(a.Index, a.Varname(), s, a.Els)
+		s.state = add
+		return
+	}
 
 	// If the array's children are a fixed size, we can compile
 	// an expression that always represents the array's wire size.
@@ -157,17 +241,26 @@ func (s *sizeGen) gArray(a *Array) {
 		return
 	}
 
+	s.addConstant(builtinSize(arrayHeader))
 	s.state = add
 	s.p.rangeBlock(a.Index, a.Varname(), s, a.Els)
 	s.state = add
 }
 
 func (s *sizeGen) gMap(m *Map) {
-	s.addConstant(builtinSize(mapHeader))
+	if s.measure {
+		s.addConstant(fmt.Sprintf("msgp.SizeOfMapHeader(uint32(len(%s)))", m.Varname()))
+	} else {
+		s.addConstant(builtinSize(mapHeader))
+	}
 	s.p.printf("\nif %s != nil {", m.Varname())
 	s.p.printf("\nfor %s, %s := range %s {", m.KeyIndx, m.ValIndx, m.Varname())
 	s.p.printf("\n_ = %s", m.ValIndx) // we may not use the value
-	s.p.printf("\ns += msgp.StringPrefixSize + len(%s)", m.KeyIndx)
+	if s.measure {
+		s.p.printf("\ns += msgp.SizeOfString(%s)", m.keyString(m.KeyIndx))
+	} else {
+		s.p.printf("\ns += msgp.StringPrefixSize + len(%s)", m.KeyIndx)
+	}
 	s.state = expr
 	next(s, m.Value)
 	s.p.closeBlock()
@@ -179,15 +272,37 @@ func (s *sizeGen) gBase(b *BaseElem) {
 	if !s.p.ok() {
 		return
 	}
+	if b.Value == Padded {
+		s.addConstant("msgp.SizeOfPaddedString(" + b.Varname() + ", " + b.paddingExpr() + ")")
+		return
+	}
+	if b.fixedInt {
+		s.addConstant(builtinSize(writeName(b, false)))
+		return
+	}
+	sizeExpr := baseSizeExpr
+	if s.measure && (s.precise || b.Value != IDENT && b.Value != Intf) {
+		// Msgsize calls the Msgsize methods of named types and guesses the sizes of
+		// interfaces even if the other values are measured.
+		sizeExpr = baseExactSizeExpr
+		if s.compactFloats && b.Value == Float64 {
+			sizeExpr = floatSizeExpr
+		}
+	}
 	if b.Convert && b.ShimMode == Convert {
 		s.state = add
-		vname := randIdent()
+		vname := s.p.newIdent(b.Varname() + " base")
 		s.p.declare(vname, b.BaseType())
 
-		// Ensure we don't get "unused variable" errors from outer slice iterations.
-		s.p.print("\n_ = " + b.Varname())
+		if s.measure {
+			// The size depends on the value. If the conversion fails, so does the marshaling.
+			s.p.printf("\n%s, _ = %s", vname, b.toBaseConvert())
+		} else {
+			// Ensure we don't get "unused variable" errors from outer slice iterations.
+			s.p.print("\n_ = " + b.Varname())
+		}
 
-		s.p.printf("\ns += %s", baseSizeExpr(b.Value, vname, b.BaseName()))
+		s.p.printf("\ns += %s", sizeExpr(b.Value, vname, b.BaseName()))
 		s.state = expr
 
 	} else {
@@ -195,7 +310,7 @@ func (s *sizeGen) gBase(b *BaseElem) {
 		if b.Convert {
 			vname = b.toBaseConvert()
 		}
-		s.addConstant(baseSizeExpr(b.Value, vname, b.BaseName()))
+		s.addConstant(sizeExpr(b.Value, vname, b.BaseName()))
 	}
 }
 
@@ -206,7 +321,7 @@ func lenExpr(sl *Slice) string {
 
 // fixedSize says if a given primitive is always the same (max) size on the wire.
 func fixedSize(p primitive) bool {
-	return p != Intf && p != Ext && p != IDENT && p != Bytes && p != String
+	return p != Intf && p != Ext && p != Binary && p != Text && p != IDENT && p != Bytes && p != String && p != Raw && p != StrBytes && p != Decimal && p != Padded
 }
 
 // stripRef strips the address operator "&" from s.
@@ -224,11 +339,11 @@ func fixedSizeExpr(e Elem) (string, bool) {
 	switch e := e.(type) {
 	case *Array:
 		if str, ok := fixedSizeExpr(e.Els); ok {
-			return fmt.Sprintf("(%s * (%s))", e.Size, str), true
+			return fmt.Sprintf("%s + (%s * (%s))", builtinSize(arrayHeader), arrayLenInt(e.Size), str), true
 		}
 	case *BaseElem:
 		if fixedSize(e.Value) {
-			return builtinSize(e.BaseName()), true
+			return builtinSize(writeName(e, false)), true
 		}
 	case *Struct:
 		var str string
@@ -243,31 +358,124 @@ func fixedSizeExpr(e Elem) (string, bool) {
 				return "", false
 			}
 		}
-		var hdrlen int
-		mhdr := msgp.AppendMapHeader(nil, uint32(len(e.Fields)))
-		hdrlen += len(mhdr)
-		var strbody []byte
-		for _, f := range e.Fields {
-			strbody = msgp.AppendString(strbody[:0], f.fieldTag)
-			hdrlen += len(strbody)
+		hdrlen := structOverhead(e)
+		if str == "" {
+			return strconv.Itoa(hdrlen), true
 		}
 		return fmt.Sprintf("%d + %s", hdrlen, str), true
 	}
 	return "", false
 }
 
+// structOverhead returns the number of bytes in the encoding of s that are not in the encoding
+// of the fields' values: the header and, unless s is a tuple, the field names.
+func structOverhead(s *Struct) int {
+	if s.AsTuple {
+		return len(msgp.AppendArrayHeader(nil, uint32(len(s.Fields))))
+	}
+	hdrlen := len(msgp.AppendMapHeader(nil, uint32(len(s.Fields))))
+	for i := range s.Fields {
+		key, _ := fieldKey(s, i)
+		hdrlen += len(key)
+	}
+	return hdrlen
+}
+
+// exactSizeExpr returns an expression for the encoded size of every value of e, if the values
+// of e all have the same encoded size (which float64s written with compactFloats do not).
+func exactSizeExpr(e Elem, compactFloats bool) (string, bool) {
+	switch e := e.(type) {
+	case *Array:
+		if be, ok := e.Els.(*BaseElem); ok && be.Value == Byte {
+			return "", false // written as bin data or a string, sized with msgp.SizeOfBytes or SizeOfStringFromBytes
+		}
+		if str, ok := exactSizeExpr(e.Els, compactFloats); ok {
+			return fmt.Sprintf("msgp.SizeOfArrayHeader(%s) + (%s * (%s))", coerceArraySize(e.Size), arrayLenInt(e.Size), str), true
+		}
+	case *BaseElem:
+		if e.fixedInt {
+			return builtinSize(writeName(e, false)), true
+		}
+		switch e.Value {
+		case Float64:
+			if !compactFloats {
+				return builtinSize(e.BaseName()), true
+			}
+		case Float32, Complex64, Complex128, Bool, Time:
+			return builtinSize(e.BaseName()), true
+		}
+	case *Struct:
+		str := strconv.Itoa(structOverhead(e))
+		for _, f := range e.Fields {
+			fs, ok := exactSizeExpr(f.fieldElem, compactFloats)
+			if !ok || f.zero != "" {
+				return "", false
+			}
+			str += " + " + fs
+		}
+		return str, true
+	}
+	return "", false
+}
+
+// baseExactSizeExpr returns an expression for the exact encoded size of the variable vname.
+func baseExactSizeExpr(value primitive, vname, basename string) string {
+	switch value {
+	case Ext:
+		return "msgp.SizeOfExtension(" + vname + ")"
+	case Binary:
+		return "msgp.SizeOfBinary(" + vname + ")"
+	case Text:
+		return "msgp.SizeOfText(" + vname + ")"
+	case Decimal:
+		return "msgp.SizeOfDecimal(" + vname + ")"
+	case Intf:
+		return "msgp.SizeOfIntf(" + vname + ")"
+	case IDENT:
+		return vname + ".MsgsizeExact()"
+	case Raw:
+		return "msgp.Raw(" + vname + ").Msgsize()"
+	case Bytes:
+		return "msgp.SizeOfBytes(" + vname + ")"
+	case StrBytes:
+		return "msgp.SizeOfStringFromBytes(" + vname + ")"
+	case String:
+		return "msgp.SizeOfString(" + vname + ")"
+	case Int, Int8, Int16, Int32, Int64:
+		return "msgp.SizeOfInt64(int64(" + vname + "))"
+	case Uint, Uint8, Uint16, Uint32, Uint64, Byte:
+		return "msgp.SizeOfUint64(uint64(" + vname + "))"
+	default:
+		return builtinSize(basename)
+	}
+}
+
+// floatSizeExpr returns an expression for the exact encoded size of the float64 variable vname
+// written with msgp.Writer.WriteFloat.
+func floatSizeExpr(value primitive, vname, basename string) string {
+	return "msgp.SizeOfFloat(" + vname + ")"
+}
+
 // print size expression of a variable name
 func baseSizeExpr(value primitive, vname, basename string) string {
 	switch value {
 	case Ext:
 		return "msgp.ExtensionPrefixSize + " + stripRef(vname) + ".Len()"
+	case Binary:
+		return "msgp.SizeOfBinary(" + vname + ")"
+	case Text:
+		return "msgp.SizeOfText(" + vname + ")"
+	case Decimal:
+		return "msgp.SizeOfDecimal(" + vname + ")"
 	case Intf:
 		return "msgp.GuessSize(" + vname + ")"
 	case IDENT:
 		return vname + ".Msgsize()"
+	case Raw:
+		return "msgp.Raw(" + vname + ").Msgsize()"
 	case Bytes:
 		return "msgp.BytesPrefixSize + len(" + vname + ")"
-	case String:
+	case String, StrBytes:
 		return "msgp.StringPrefixSize + len(" + vname + ")"
 	default:
 		return builtinSize(basename)
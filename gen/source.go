@@ -1,36 +1,62 @@
 package gen
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
+	"go/build/constraint"
+	"go/format"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
+	"math"
 	"os"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/dchenk/msgp/msgp"
 )
 
 // A source represents either a single parsed source code file or a concatenation of files.
 type source struct {
-	pkg        string              // package name
-	specs      map[string]ast.Expr // type specs found in the code
-	identities map[string]Elem     // identities processed from specs
-	directives []string            // raw preprocessor directives (lines of comments)
-	imports    []*ast.ImportSpec   // imports
+	pkg        string                     // package name
+	specs      map[string]ast.Expr        // type specs found in the code
+	aliases    map[string]ast.Expr        // the types aliased by the aliases (type A = B) found in the code
+	anonTypes  map[*ast.StructType]string // the anonymous struct types as declared, before filtering
+	identities map[string]Elem            // identities processed from specs
+	declared   map[string]Elem            // copies of the identities as they are before inlining
+	inline     map[string]bool            // types set to be always (true) or never (false) inlined
+	maxComplex int                        // the types less complex than this are inlined
+	receivers  map[string]string          // the receiver names set for types by directives
+	zeroers    map[string]bool            // the types declared with an IsZero() bool method
+	directives []dirLine                  // raw preprocessor directives (lines of comments)
+	shims      []dirLine                  // shim directives loaded from the files given with Shims
+	imports    []*ast.ImportSpec          // imports
+	constraint constraint.Expr            // the build constraint of the files (nil if none)
+	fset       *token.FileSet             // the positions in the parsed files
 }
 
 // newSource parses a file at the path provided and produces a new *source.
 // If srcPath is the path to a directory, the entire directory will be parsed.
 // If unexported is true, the unexported identifiers in source will be included.
 // If the resulting source would be empty, an error is returned.
-func newSource(srcPath string, unexported bool) (*source, error) {
+func newSource(srcPath string, unexported bool, o *options) (*source, error) {
 
 	pushState(srcPath)
 	defer popState()
 	s := &source{
 		specs:      make(map[string]ast.Expr),
+		aliases:    make(map[string]ast.Expr),
+		anonTypes:  make(map[*ast.StructType]string),
 		identities: make(map[string]Elem),
+		inline:     make(map[string]bool),
+		maxComplex: o.maxComplex,
+		receivers:  make(map[string]string),
+		zeroers:    make(map[string]bool),
 	}
 
 	stat, err := os.Stat(srcPath)
@@ -38,8 +64,10 @@ func newSource(srcPath string, unexported bool) (*source, error) {
 		return nil, err
 	}
 	fset := token.NewFileSet()
+	s.fset = fset
 	if stat.IsDir() {
-		pkgs, err := parser.ParseDir(fset, srcPath, nil, parser.ParseComments)
+		// Only the files that would be built (for the GOOS, GOARCH, and tags) are parsed.
+		pkgs, err := parser.ParseDir(fset, srcPath, fileFilter(o.buildContext(), srcPath), parser.ParseComments)
 		if err != nil {
 			return nil, err
 		}
@@ -53,13 +81,40 @@ func newSource(srcPath string, unexported bool) (*source, error) {
 			pkg = pkgs[n]
 			break
 		}
-		for _, fl := range pkg.Files {
+		fileNames := make([]string, 0, len(pkg.Files))
+		for name := range pkg.Files {
+			fileNames = append(fileNames, name)
+		}
+		sort.Strings(fileNames)
+		// The generated code has the build constraint shared by all of the files.
+		constraints := make([]constraint.Expr, len(fileNames))
+		for i, name := range fileNames {
+			x, err := fileConstraint(pkg.Files[name], name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			constraints[i] = x
+			if i == 0 {
+				s.constraint = x
+			} else {
+				s.constraint = commonConstraint(s.constraint, x)
+			}
+		}
+		for i, name := range fileNames {
+			fl := pkg.Files[name]
 			pushState(fl.Name.Name)
-			s.directives = append(s.directives, getComments(fl.Comments)...)
+			s.directives = append(s.directives, getComments(fset, fl.Comments)...)
 			if !unexported {
+				s.recordAnonTypes(fl)
 				ast.FileExports(fl)
 			}
-			s.getTypeSpecs(fl)
+			if hasTerms(s.constraint, constraints[i]) {
+				s.getTypeSpecs(fl)
+			} else {
+				// The methods of these types would be built without them.
+				warnfAt(fset.Position(fl.Package), "skipping the types of the file: its build constraint %s is "+
+					"not shared by the other files; generate the code for the file by itself\n", constraints[i])
+			}
 			popState()
 		}
 	} else {
@@ -68,8 +123,12 @@ func newSource(srcPath string, unexported bool) (*source, error) {
 			return nil, err
 		}
 		s.pkg = f.Name.Name
-		s.directives = getComments(f.Comments)
+		if s.constraint, err = fileConstraint(f, srcPath); err != nil {
+			return nil, fmt.Errorf("%s: %v", srcPath, err)
+		}
+		s.directives = getComments(fset, f.Comments)
 		if !unexported {
+			s.recordAnonTypes(f)
 			ast.FileExports(f)
 		}
 		s.getTypeSpecs(f)
@@ -79,43 +138,122 @@ func newSource(srcPath string, unexported bool) (*source, error) {
 		return nil, fmt.Errorf("no definitions in %s", srcPath)
 	}
 
+	for _, path := range o.shims {
+		shims, err := loadShims(path)
+		if err != nil {
+			return nil, err
+		}
+		s.shims = append(s.shims, shims...)
+	}
+
 	s.process()
+	s.applyShims()
 	s.applyDirectives()
 	s.propInline()
+	s.setDefaults()
+	s.setZeroTests()
 
 	return s, nil
 
 }
 
-func (s *source) printTo(gs generatorSet) error {
-	s.applyDirs(gs)
+// printTo prints the methods (and the tests and the cross tests, if tests and cross are not nil)
+// of the types in s to out, tests, and cross. The types are printed concurrently into buffers of their own, and the buffers are written
+// out in the order of the type names.
+func (s *source) printTo(mode Method, o *options, out, tests, cross *bytes.Buffer) error {
+	var pl passList
+	s.applyDirs(&pl)
 	names := make([]string, 0, len(s.identities))
 	for name := range s.identities {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 	for _, name := range names {
-		el := s.identities[name]
-		el.SetVarname("z")
-		pushState(el.TypeName())
-		err := gs.Print(el)
-		popState()
-		if err != nil {
-			return err
+		if recv, ok := s.receivers[name]; ok {
+			if err := checkReceiver(recv, o.identPrefix); err != nil {
+				return fmt.Errorf("%s: %v", located(s.identities[name].Pos(), name), err)
+			}
+		}
+	}
+	if mode.isSet(Test) && o.filling() {
+		o.filled = fillable(s.identities)
+	}
+	if o.registry {
+		o.types = s.identities
+	}
+
+	type printed struct {
+		out, tests, cross bytes.Buffer
+		err               error
+	}
+	results := make([]printed, len(names))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := o.workerCount(len(names)); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := &results[i]
+				var crossOut io.Writer
+				if cross != nil {
+					crossOut = &r.cross
+				}
+				gs := newGeneratorSet(mode, &r.out, &r.tests, crossOut, o)
+				pl.addTo(gs)
+				recv, ok := s.receivers[names[i]]
+				if !ok {
+					recv = o.receiver
+				}
+				r.err = gs.Print(s.identities[names[i]], recv)
+			}
+		}()
+	}
+	for i := range names {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i := range results {
+		if err := results[i].err; err != nil {
+			return fmt.Errorf("%s: %v", located(s.identities[names[i]].Pos(), names[i]), err)
+		}
+		out.Write(results[i].out.Bytes())
+		if tests != nil {
+			tests.Write(results[i].tests.Bytes())
+		}
+		if cross != nil {
+			cross.Write(results[i].cross.Bytes())
 		}
 	}
 	return nil
 }
 
+// applyShims applies the shared shim directives. The types not declared in s are not kept as
+// identities, so methods are not generated for them.
+func (s *source) applyShims() {
+	for _, d := range s.shims {
+		chunks := strings.Split(d.text, " ")
+		pushStateAt("shim", d.pos)
+		if err := applyShim(chunks, s); err != nil {
+			warnln(err.Error())
+		} else if name := strings.TrimPrefix(chunks[1], "*"); s.specs[name] == nil {
+			delete(s.identities, name)
+		}
+		popState()
+	}
+}
+
 // applyDirectives applies all of the directives that are known to the parser.
 // Additional method-specific directives remain in s.directives.
 func (s *source) applyDirectives() {
-	newdirs := make([]string, 0, len(s.directives))
+	newdirs := make([]dirLine, 0, len(s.directives))
 	for _, d := range s.directives {
-		chunks := strings.Split(d, " ")
+		chunks := strings.Split(d.text, " ")
 		if len(chunks) > 0 {
 			if fn, ok := directives[chunks[0]]; ok {
-				pushState(chunks[0])
+				pushStateAt(chunks[0], d.pos)
 				if err := fn(chunks, s); err != nil {
 					warnln(err.Error())
 				}
@@ -158,6 +296,7 @@ func (s *source) resolve(ls linkset) {
 				progress = true
 				nt := n.Copy()
 				nt.Alias(name)
+				nt.SetPos(elem.Pos())
 				s.identities[name] = nt
 				delete(ls, name)
 			}
@@ -166,7 +305,9 @@ func (s *source) resolve(ls linkset) {
 
 	// Whatever is left can't be resolved.
 	for name, elem := range ls {
+		pushType(name, s.typePos(name))
 		warnf("couldn't resolve type %s (%s)\n", name, elem.TypeName())
+		popState()
 	}
 
 }
@@ -177,7 +318,7 @@ func (s *source) process() {
 	deferred := make(linkset)
 
 	for name, def := range s.specs {
-		pushState(name)
+		pushType(name, s.typePos(name))
 		el := s.parseExpr(def)
 		if el == nil {
 			warnln("failed to parse")
@@ -215,39 +356,50 @@ func strToMethod(s string) Method {
 		return Marshal
 	case "unmarshal":
 		return Unmarshal
+	case "copy":
+		return Copy
+	case "equal":
+		return Equal
 	default:
 		return 0
 	}
 }
 
 // applyDirs applies directives of the form: //msgp:encode ignore {{TypeName}}
-func (s *source) applyDirs(p generatorSet) {
+func (s *source) applyDirs(pl *passList) {
 	for _, d := range s.directives {
-		chunks := strings.Split(d, " ")
-		if len(chunks) > 1 {
-			for i := range chunks {
-				// Remove spacing around each word (type name) in
-				// case there is any spacing.
-				chunks[i] = strings.TrimSpace(chunks[i])
-			}
-			m := strToMethod(chunks[0]) // m is the directive's Method
-			if m == 0 {
-				warnf("unknown pass name: %q\n", chunks[0])
-				continue
-			}
-			if fn, ok := passDirectives[chunks[1]]; ok {
-				pushState(chunks[1])
-				err := fn(m, chunks[2:], p)
-				if err != nil {
-					warnf("error applying directive: %s\n", err)
-				}
-				popState()
-			} else {
-				warnf("unrecognized directive %q\n", chunks[1])
-			}
-		} else {
-			warnf("empty directive: %q\n", d)
+		pushStateAt("", d.pos)
+		s.applyDir(d.text, pl)
+		popState()
+	}
+}
+
+// applyDir applies the directive d for a named pass.
+func (s *source) applyDir(d string, pl *passList) {
+	chunks := strings.Split(d, " ")
+	if len(chunks) <= 1 {
+		warnf("empty directive: %q\n", d)
+		return
+	}
+	for i := range chunks {
+		// Remove spacing around each word (type name) in
+		// case there is any spacing.
+		chunks[i] = strings.TrimSpace(chunks[i])
+	}
+	m := strToMethod(chunks[0]) // m is the directive's Method
+	if m == 0 {
+		warnf("unknown pass name: %q\n", chunks[0])
+		return
+	}
+	if fn, ok := passDirectives[chunks[1]]; ok {
+		pushState(chunks[1])
+		err := fn(m, chunks[2:], pl)
+		if err != nil {
+			warnf("error applying directive: %s\n", err)
 		}
+		popState()
+	} else {
+		warnf("unrecognized directive %q\n", chunks[1])
 	}
 }
 
@@ -261,12 +413,26 @@ func (s *source) getTypeSpecs(f *ast.File) {
 	// Check all declarations.
 	for i := range f.Decls {
 
+		if fd, ok := f.Decls[i].(*ast.FuncDecl); ok {
+			if name := zeroerType(fd); name != "" {
+				s.zeroers[name] = true
+			}
+			continue
+		}
+
 		if g, ok := f.Decls[i].(*ast.GenDecl); ok {
 
 			// Check the specs.
 			for _, spec := range g.Specs {
 
 				if ts, ok := spec.(*ast.TypeSpec); ok {
+					if ts.Assign.IsValid() {
+						// An alias has the methods of the aliased type, so it gets none
+						// of its own; the aliased type is written in its place.
+						s.aliases[ts.Name.Name] = ts.Type
+						infof("skipping alias %s\n", ts.Name.Name)
+						continue
+					}
 					switch ts.Type.(type) { // These are the parse-able type specs.
 					case *ast.StructType,
 						*ast.ArrayType,
@@ -282,6 +448,37 @@ func (s *source) getTypeSpecs(f *ast.File) {
 	}
 }
 
+// zeroerType returns the name of the receiver type of fd if fd declares an IsZero method with no
+// parameters and a bool result, as fields tagged omitzero are tested with; otherwise, it returns
+// the empty string.
+func zeroerType(fd *ast.FuncDecl) string {
+	if fd.Name.Name != "IsZero" || fd.Recv == nil || len(fd.Recv.List) != 1 {
+		return ""
+	}
+	if fd.Type.Params.NumFields() != 0 || fd.Type.Results.NumFields() != 1 {
+		return ""
+	}
+	if res, ok := fd.Type.Results.List[0].Type.(*ast.Ident); !ok || res.Name != "bool" {
+		return ""
+	}
+	recv := fd.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if id, ok := recv.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// typePos returns the position of the declaration of the type name, if it is declared in s.
+func (s *source) typePos(name string) token.Position {
+	if spec, ok := s.specs[name]; ok {
+		return s.fset.Position(spec.Pos())
+	}
+	return token.Position{}
+}
+
 func fieldName(f *ast.Field) string {
 	l := len(f.Names)
 	if l == 0 {
@@ -298,7 +495,7 @@ func (s *source) parseFieldList(fl *ast.FieldList) []structField {
 	}
 	out := make([]structField, 0, fl.NumFields())
 	for _, field := range fl.List {
-		pushState(fieldName(field))
+		pushStateAt(fieldName(field), s.fset.Position(field.Pos()))
 		fds := s.getField(field)
 		if len(fds) > 0 {
 			out = append(out, fds...)
@@ -314,13 +511,57 @@ func (s *source) parseFieldList(fl *ast.FieldList) []structField {
 func (s *source) getField(f *ast.Field) []structField {
 
 	fields := make([]structField, 1)
-	var extension bool
+	var extension, binary, text, raw, str, decimal, padded bool
+	var padding msgp.Padding
 	// Parse the tag; otherwise the field name is field tag.
 	if f.Tag != nil {
 		body := reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("msgp")
 		tags := strings.Split(body, ",")
-		if len(tags) == 2 && tags[1] == "extension" {
-			extension = true
+		for _, opt := range tags[1:] {
+			switch opt {
+			case "extension":
+				extension = true
+			case "binary":
+				binary = true
+			case "text":
+				text = true
+			case "raw":
+				raw = true
+			case "str":
+				str = true
+			case "decimal":
+				decimal = true
+			case "required":
+				fields[0].required = true
+			case "asarray":
+				fields[0].asArray = true
+			case "omitzero":
+				fields[0].omitZero = true
+			case "zeroterm":
+				padded = true
+				padding.ZeroTerm = true
+			default:
+				switch {
+				case strings.HasPrefix(opt, "default="):
+					fields[0].dflt = strings.TrimPrefix(opt, "default=")
+				case strings.HasPrefix(opt, "width="):
+					padded = true
+					w, err := strconv.Atoi(strings.TrimPrefix(opt, "width="))
+					if err != nil || w <= 0 {
+						warnf("Invalid padded width %q.\n", opt)
+						return nil
+					}
+					padding.Width = w
+				case strings.HasPrefix(opt, "pad="):
+					padded = true
+					b, err := strconv.ParseUint(strings.TrimPrefix(opt, "pad="), 0, 8)
+					if err != nil {
+						warnf("Invalid pad byte %q.\n", opt)
+						return nil
+					}
+					padding.Pad = byte(b)
+				}
+			}
 		}
 		// Ignore "-" fields.
 		if tags[0] == "-" {
@@ -335,6 +576,35 @@ func (s *source) getField(f *ast.Field) []structField {
 		return nil
 	}
 
+	if extension && !markIdent(ex, Ext) {
+		warnln("Couldn't cast to extension.")
+		return nil
+	}
+	if binary && (extension || !markIdent(ex, Binary)) {
+		warnln("Couldn't cast to a type written in binary form.")
+		return nil
+	}
+	if text && (extension || binary || !markIdent(ex, Text)) {
+		warnln("Couldn't cast to a type written in text form.")
+		return nil
+	}
+	if raw && (extension || binary || text || !markRaw(ex)) {
+		warnln("Couldn't read a field that is not a byte slice as raw MessagePack.")
+		return nil
+	}
+	if str && (extension || binary || text || raw || !markStr(ex)) {
+		warnln("Couldn't write a field that is not a byte slice or array as a string.")
+		return nil
+	}
+	if decimal && (extension || binary || text || raw || str || !markIdent(ex, Decimal)) {
+		warnln("Couldn't cast to a decimal.")
+		return nil
+	}
+	if padded && (extension || binary || text || raw || str || decimal || !markPadded(ex, padding)) {
+		warnln("Couldn't pad a field that is not a string.")
+		return nil
+	}
+
 	// Parse the field name.
 	switch len(f.Names) {
 	case 0:
@@ -344,12 +614,17 @@ func (s *source) getField(f *ast.Field) []structField {
 	default:
 		// this is for a multiple in-line declaration,
 		// e.g. type A struct { One, Two int }
+		first := fields[0]
 		fields = fields[0:0]
 		for _, nm := range f.Names {
 			fields = append(fields, structField{
 				fieldTag:  nm.Name,
 				fieldName: nm.Name,
 				fieldElem: ex.Copy(),
+				required:  first.required,
+				dflt:      first.dflt,
+				asArray:   first.asArray,
+				omitZero:  first.omitZero,
 			})
 		}
 		return fields
@@ -359,26 +634,341 @@ func (s *source) getField(f *ast.Field) []structField {
 		fields[0].fieldTag = fields[0].fieldName
 	}
 
-	// Validate the extension.
-	if extension {
-		switch ex := ex.(type) {
-		case *Ptr:
-			if b, ok := ex.Value.(*BaseElem); ok {
-				b.Value = Ext
-			} else {
-				warnln("Couldn't cast to extension.")
-				return nil
+	return fields
+
+}
+
+// setDefaults replaces the default values given in the tags of struct fields with Go expressions
+// of the fields' types. A default value that is invalid for its field's type is dropped with a
+// warning; only fields of numeric, string, and bool types can have default values.
+func (s *source) setDefaults() {
+	names := make([]string, 0, len(s.identities))
+	for name := range s.identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pushType(name, s.typePos(name))
+		s.nextDefaults(s.identities[name])
+		popState()
+	}
+}
+
+func (s *source) nextDefaults(e Elem) {
+	switch e := e.(type) {
+	case *Struct:
+		for i := range e.Fields {
+			f := &e.Fields[i]
+			if f.dflt != "" {
+				pushStateAt(f.fieldName, f.fieldElem.Pos())
+				expr, err := s.defaultExpr(f.fieldElem, f.dflt)
+				if err != nil {
+					warnf("ignoring default value: %s\n", err)
+					expr = ""
+				}
+				f.dflt = expr
+				popState()
 			}
-		case *BaseElem:
-			ex.Value = Ext
-		default:
-			warnln("Couldn't cast to extension.")
-			return nil
+			s.nextDefaults(f.fieldElem)
+		}
+	case *Array:
+		s.nextDefaults(e.Els)
+	case *Slice:
+		s.nextDefaults(e.Els)
+	case *Map:
+		s.nextDefaults(e.Value)
+	case *Ptr:
+		s.nextDefaults(e.Value)
+	}
+}
+
+// defaultExpr returns the Go expression for the default value v of a field of type e.
+func (s *source) defaultExpr(e Elem, v string) (string, error) {
+	be, ok := e.(*BaseElem)
+	if ok && be.Value == IDENT {
+		// The named type was not inlined, but it may be declared as a primitive type.
+		be, ok = s.identities[be.TypeName()].(*BaseElem)
+	}
+	if !ok || be.ShimToBase != "" {
+		return "", fmt.Errorf("type %s cannot have a default value", e.TypeName())
+	}
+	switch be.Value {
+	case String, Padded:
+		return strconv.Quote(v), nil
+	case Bool:
+		b, err := strconv.ParseBool(v)
+		return strconv.FormatBool(b), err
+	case Int, Int64:
+		i, err := strconv.ParseInt(v, 0, 64)
+		return strconv.FormatInt(i, 10), err
+	case Int8, Int16, Int32:
+		i, err := strconv.ParseInt(v, 0, fixedBits(be.Value))
+		return strconv.FormatInt(i, 10), err
+	case Uint, Uint64:
+		u, err := strconv.ParseUint(v, 0, 64)
+		return strconv.FormatUint(u, 10), err
+	case Uint8, Byte, Uint16, Uint32:
+		u, err := strconv.ParseUint(v, 0, fixedBits(be.Value))
+		return strconv.FormatUint(u, 10), err
+	case Float32, Float64:
+		f, err := strconv.ParseFloat(v, fixedBits(be.Value))
+		if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			err = fmt.Errorf("%s is not a finite number", v)
 		}
+		return strconv.FormatFloat(f, 'g', -1, fixedBits(be.Value)), err
 	}
+	return "", fmt.Errorf("type %s cannot have a default value", e.TypeName())
+}
 
-	return fields
+// setZeroTests sets the conditions under which the struct fields tagged omitzero are left out of
+// the encoding. The option is dropped with a warning from the fields of tuples, required fields,
+// and fields whose zero values cannot be tested for.
+func (s *source) setZeroTests() {
+	names := make([]string, 0, len(s.identities))
+	for name := range s.identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pushType(name, s.typePos(name))
+		s.nextZeroTests(s.identities[name])
+		popState()
+	}
+}
+
+func (s *source) nextZeroTests(e Elem) {
+	switch e := e.(type) {
+	case *Struct:
+		for i := range e.Fields {
+			f := &e.Fields[i]
+			if f.omitZero {
+				pushStateAt(f.fieldName, f.fieldElem.Pos())
+				test, ok := s.zeroTest("%[1]s", f.fieldElem)
+				switch {
+				case e.AsTuple:
+					warnln("ignoring omitzero: the fields of tuples are always written")
+				case f.required:
+					warnln("ignoring omitzero: the field is required")
+				case !ok:
+					warnf("ignoring omitzero: cannot tell if a %s is zero\n", f.fieldElem.TypeName())
+				default:
+					f.zero = test
+				}
+				f.omitZero = f.zero != ""
+				popState()
+			}
+			s.nextZeroTests(f.fieldElem)
+		}
+	case *Array:
+		s.nextZeroTests(e.Els)
+	case *Slice:
+		s.nextZeroTests(e.Els)
+	case *Map:
+		s.nextZeroTests(e.Value)
+	case *Ptr:
+		s.nextZeroTests(e.Value)
+	}
+}
 
+// zeroTest returns the Go expression saying if a, a variable of type e, holds the zero value. If the
+// type is declared with an IsZero method, the method decides. Otherwise, pointers, slices, maps, and
+// interfaces are zero if they are nil, structs if all of their encoded fields are zero, and arrays
+// and primitive values if they equal the zero value. The methods of types from other packages are
+// not known, so their values are zero only if they have an IsZero method returning true, which
+// msgp.IsZero looks for. The returned bool is false if there is no test for the values of e.
+func (s *source) zeroTest(a string, e Elem) (string, bool) {
+	if s.zeroers[e.TypeName()] {
+		return a + ".IsZero()", true
+	}
+	switch e := e.(type) {
+	case *Ptr, *Slice, *Map:
+		return a + " == nil", true
+	case *Array:
+		if comparesEqual(e.Els) {
+			return a + " == (" + e.TypeName() + "{})", true
+		}
+	case *Struct:
+		if len(e.Fields) == 0 {
+			return "true", true
+		}
+		tests := make([]string, len(e.Fields))
+		for i := range e.Fields {
+			test, ok := s.zeroTest(a+"."+e.Fields[i].fieldName, e.Fields[i].fieldElem)
+			if !ok {
+				return "", false
+			}
+			tests[i] = test
+		}
+		return strings.Join(tests, " && "), true
+	case *BaseElem:
+		return s.baseZeroTest(a, e)
+	}
+	return "", false
+}
+
+func (s *source) baseZeroTest(a string, e *BaseElem) (string, bool) {
+	name := e.TypeName()
+	if e.ShimToBase != "" || e.Value == IDENT || e.Value == Ext || e.Value == Binary || e.Value == Text || e.Value == Decimal {
+		if name == "msgp.Extension" {
+			return a + " == nil", true
+		}
+		if el, ok := s.identities[name]; ok && e.ShimToBase == "" {
+			return s.zeroTest(a, el)
+		}
+		if strings.Contains(name, ".") {
+			return "msgp.IsZero(&" + a + ")", true
+		}
+		return "", false
+	}
+	switch e.Value {
+	case Bytes, Intf, Raw, StrBytes:
+		return a + " == nil", true
+	case String, Padded:
+		return a + ` == ""`, true
+	case Bool:
+		return "!" + a, true
+	case Time:
+		if e.Convert {
+			return e.BaseType() + "(" + a + ").IsZero()", true
+		}
+		return a + ".IsZero()", true
+	}
+	return a + " == 0", true
+}
+
+// fixedBits returns the size in bits of a sized numeric type.
+func fixedBits(p primitive) int {
+	switch p {
+	case Int8, Uint8, Byte:
+		return 8
+	case Int16, Uint16:
+		return 16
+	case Int32, Uint32, Float32:
+		return 32
+	}
+	return 64
+}
+
+// isSigned says if p is a signed integer type.
+func isSigned(p primitive) bool {
+	switch p {
+	case Int, Int8, Int16, Int32, Int64:
+		return true
+	}
+	return false
+}
+
+// isUnsigned says if p is an unsigned integer type.
+func isUnsigned(p primitive) bool {
+	switch p {
+	case Uint, Uint8, Uint16, Uint32, Uint64, Byte:
+		return true
+	}
+	return false
+}
+
+// markIdent makes the named type within e (which may be behind pointers or be the element type of
+// slices, arrays, or map values) be encoded as the primitive p: as an extension (Ext), for which the
+// type must implement msgp.Extension with a pointer receiver, in its binary form (Binary), for which
+// the type must implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler (either with a
+// pointer receiver), or in its text form (Text), for which the type must likewise implement
+// encoding.TextMarshaler and encoding.TextUnmarshaler, or as a decimal extension (Decimal), for
+// which the type must implement msgp.DecimalMarshaler and msgp.DecimalUnmarshaler (the latter with
+// a pointer receiver). The returned bool says if e has such a named type.
+func markIdent(e Elem, p primitive) bool {
+	switch e := e.(type) {
+	case *BaseElem:
+		if e.Value != IDENT && e.Value != p {
+			return false
+		}
+		e.Value = p
+		return true
+	case *Ptr:
+		return markIdent(e.Value, p)
+	case *Slice:
+		return markIdent(e.Els, p)
+	case *Array:
+		return markIdent(e.Els, p)
+	case *Map:
+		return markIdent(e.Value, p)
+	default:
+		return false
+	}
+}
+
+// markRaw marks the byte slices of e, which must be byte slices or slices, arrays, or maps of them,
+// as holding encoded objects. It says if e has such byte slices. Pointers to them are refused since
+// an empty slice is written as nil, which is read as a nil pointer.
+func markRaw(e Elem) bool {
+	switch e := e.(type) {
+	case *BaseElem:
+		if e.Value != Bytes || e.Convert {
+			return false
+		}
+		e.Value = Raw
+		return true
+	case *Slice:
+		return markRaw(e.Els)
+	case *Array:
+		return markRaw(e.Els)
+	case *Map:
+		return markRaw(e.Value)
+	default:
+		return false
+	}
+}
+
+// markStr makes the byte slices and arrays of e, which must be byte slices or arrays or pointers,
+// slices, arrays, or maps of them, be written as 'str' objects. It says if e has such byte slices
+// or arrays.
+func markStr(e Elem) bool {
+	switch e := e.(type) {
+	case *BaseElem:
+		if e.Value != Bytes || e.ShimToBase != "" {
+			return false
+		}
+		e.Value = StrBytes
+		return true
+	case *Array:
+		if be, ok := e.Els.(*BaseElem); ok && be.Value == Byte {
+			e.Str = true
+			return true
+		}
+		return markStr(e.Els)
+	case *Ptr:
+		return markStr(e.Value)
+	case *Slice:
+		return markStr(e.Els)
+	case *Map:
+		return markStr(e.Value)
+	default:
+		return false
+	}
+}
+
+// markPadded makes the strings of e, which must be strings or pointers, slices, arrays, or maps of
+// them, be written as 'bin' objects laid out by p. It says if e has such strings. Named string
+// types are refused.
+func markPadded(e Elem, p msgp.Padding) bool {
+	switch e := e.(type) {
+	case *BaseElem:
+		if e.Value != String || e.ShimToBase != "" || e.Convert {
+			return false
+		}
+		e.Value = Padded
+		e.padding = p
+		return true
+	case *Ptr:
+		return markPadded(e.Value, p)
+	case *Slice:
+		return markPadded(e.Els, p)
+	case *Array:
+		return markPadded(e.Els, p)
+	case *Map:
+		return markPadded(e.Value, p)
+	default:
+		return false
+	}
 }
 
 // Extract embedded field names.
@@ -425,6 +1015,67 @@ func stringify(e ast.Expr) string {
 	return "<BAD>"
 }
 
+// anonStructType returns the type name of an anonymous struct, written on one line with all of the
+// fields (including the embedded fields and the fields that are not serialized) and their tags.
+func anonStructType(st *ast.StructType) string {
+	var b bytes.Buffer
+	b.WriteString("struct{")
+	for i, f := range st.Fields.List {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		for j, n := range f.Names {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(n.Name)
+		}
+		if len(f.Names) > 0 {
+			b.WriteByte(' ')
+		}
+		format.Node(&b, token.NewFileSet(), f.Type)
+		if f.Tag != nil {
+			b.WriteString(" " + f.Tag.Value)
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// recordAnonTypes records the names of the anonymous struct types in f before ast.FileExports
+// removes their unexported fields, which the types must still be named with.
+func (s *source) recordAnonTypes(f *ast.File) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		if st, ok := n.(*ast.StructType); ok {
+			s.anonTypes[st] = anonStructType(st)
+		}
+		return true
+	})
+}
+
+// stringKind says if name is string or a type of the source declared as string (directly or as
+// another such type, or as an alias of one), which can be the type of the keys of a map. The
+// declarations are followed to a limited depth to stop at cyclic ones.
+func (s *source) stringKind(name string, depth int) bool {
+	if name == "string" {
+		return true
+	}
+	id, ok := s.specs[name].(*ast.Ident)
+	if !ok {
+		id, ok = s.aliases[name].(*ast.Ident)
+	}
+	return ok && depth < 8 && s.stringKind(id.Name, depth+1)
+}
+
+// parseExpr translates e into an Elem with the position of e; nil means the type is not supported.
+func (s *source) parseExpr(e ast.Expr) Elem {
+	el := s.translateExpr(e)
+	if el != nil {
+		el.SetPos(s.fset.Position(e.Pos()))
+	}
+	return el
+}
+
 // recursively translate ast.Expr to Elem; nil means type not supported.
 // Expected input types:
 // - *ast.MapType (map[T]J)
@@ -434,18 +1085,32 @@ func stringify(e ast.Expr) string {
 // - *ast.StructType (struct {})
 // - *ast.SelectorExpr (a.B)
 // - *ast.InterfaceType (interface {})
-func (s *source) parseExpr(e ast.Expr) Elem {
+func (s *source) translateExpr(e ast.Expr) Elem {
 	switch e := e.(type) {
 
 	case *ast.MapType:
-		if k, ok := e.Key.(*ast.Ident); ok && k.Name == "string" {
-			if in := s.parseExpr(e.Value); in != nil {
-				return &Map{Value: in}
+		k, ok := e.Key.(*ast.Ident)
+		if !ok || !s.stringKind(k.Name, 0) {
+			return nil
+		}
+		if in := s.parseExpr(e.Value); in != nil {
+			m := &Map{Value: in}
+			if k.Name != "string" {
+				m.Key = k.Name
 			}
+			return m
 		}
 		return nil
 
 	case *ast.Ident:
+		if t, ok := s.aliases[e.Name]; ok {
+			// The alias is removed while the aliased type is translated so that a cyclic
+			// alias is not followed forever.
+			delete(s.aliases, e.Name)
+			el := s.translateExpr(t)
+			s.aliases[e.Name] = t
+			return el
+		}
 		b := Ident(e.Name)
 
 		// Work to resolve this expression can be done later,
@@ -494,7 +1159,11 @@ func (s *source) parseExpr(e ast.Expr) Elem {
 				}
 
 			default:
-				return nil
+				// A constant expression, such as 2*pkg.Size.
+				return &Array{
+					Size: types.ExprString(lt),
+					Els:  els,
+				}
 			}
 		}
 		return &Slice{Els: els}
@@ -506,7 +1175,14 @@ func (s *source) parseExpr(e ast.Expr) Elem {
 		return nil
 
 	case *ast.StructType:
-		return &Struct{Fields: s.parseFieldList(e.Fields)}
+		st := &Struct{Fields: s.parseFieldList(e.Fields)}
+		// The type is named as declared, which may differ from the fields serialized.
+		name, ok := s.anonTypes[e]
+		if !ok {
+			name = anonStructType(e)
+		}
+		st.Alias(name)
+		return st
 
 	case *ast.SelectorExpr:
 		return Ident(stringify(e))
@@ -2,8 +2,14 @@ package gen
 
 import (
 	"fmt"
+	"go/token"
 	"io"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dchenk/msgp/msgp"
 )
 
 const (
@@ -13,13 +19,14 @@ const (
 	mapHeader   = "MapHeader"
 	arrayHeader = "ArrayHeader"
 	mapKey      = "MapKeyPtr"
+	mapStrKey   = "MapKeyStringInterned"
 	stringTyp   = "String"
 	u32         = "uint32"
 )
 
 // A Method is a bitfield representing something that the
 // generator knows how to print.
-type Method uint8
+type Method uint16
 
 // isSet says if the bits in 'f' are set in 'm'
 func (m Method) isSet(f Method) bool { return m&f == f }
@@ -41,9 +48,13 @@ func (m Method) String() string {
 		return "size"
 	case Test:
 		return "test"
+	case Copy:
+		return "copy"
+	case Equal:
+		return "equal"
 	default:
 		// return something like "decode+encode+test"
-		modes := [...]Method{Decode, Encode, Marshal, Unmarshal, Size, Test}
+		modes := [...]Method{Decode, Encode, Marshal, Unmarshal, Size, Test, Copy, Equal}
 		any := false
 		nm := ""
 		for _, mm := range modes {
@@ -68,6 +79,8 @@ const (
 	Unmarshal                                            // Unmarshal using msgp.Unmarshaler
 	Size                                                 // Size using msgp.Sizer
 	Test                                                 // Test functions should be generated
+	Copy                                                 // Clone methods making deep copies
+	Equal                                                // Equal methods comparing values
 	invalidMeth                                          // this isn't a method
 	encodetest  = Encode | Decode | Test                 // tests for Encoder and Decoder
 	marshaltest = Marshal | Unmarshal | Test             // tests for Marshaler and Unmarshaler
@@ -80,59 +93,100 @@ type generator interface {
 	Execute(Elem) error
 }
 
-type generatorSet []generator
+// A generatorSet prints the methods of a type with each of its generators. The identifiers in
+// the code are generated by ids, which must not be shared with any other generatorSet.
+type generatorSet struct {
+	gens []generator
+	ids  *identGen
+}
 
-func newGeneratorSet(m Method, out io.Writer, tests io.Writer) generatorSet {
+func newGeneratorSet(m Method, out, tests, cross io.Writer, o *options) *generatorSet {
 	if m.isSet(Test) && tests == nil {
 		panic("cannot print tests with 'nil' tests argument")
 	}
-	gens := make(generatorSet, 0, 7)
-	if m.isSet(Decode) {
-		gens = append(gens, decode(out))
+	ids := &identGen{base: o.identPrefix, stable: o.stableIdents}
+	ids.reset("a")
+	gens := make([]generator, 0, 10)
+	if o.freestanding {
+		// run leaves only the Marshal, Unmarshal, and Size methods of the msgp methods.
+		gens = append(gens, freestanding(out, ids))
+	} else {
+		if m.isSet(Decode) {
+			gens = append(gens, decode(out, ids, o.keySwitch, o.oldSpec, o.lenientFloats, o.lenientBools, o.wrapErrors))
+		}
+		if m.isSet(Encode) {
+			gens = append(gens, encode(out, ids, o.kvFields, o.compactFloats))
+		}
+		if m.isSet(Marshal) {
+			gens = append(gens, marshal(out, ids, o.kvFields, o.compactFloats))
+		}
+		if m.isSet(Unmarshal) {
+			gens = append(gens, unmarshal(out, ids, o.keySwitch, o.oldSpec, o.lenientFloats, o.lenientBools, o.wrapErrors))
+		}
+		if m.isSet(Size) {
+			gens = append(gens, sizes(out, ids, o.exactSizes, o.tightSizes, o.compactFloats))
+		}
+	}
+	if m.isSet(Copy) {
+		gens = append(gens, copies(out, ids))
 	}
-	if m.isSet(Encode) {
-		gens = append(gens, encode(out))
+	if m.isSet(Equal) {
+		gens = append(gens, equals(out, ids))
 	}
-	if m.isSet(Marshal) {
-		gens = append(gens, marshal(out))
+	if m&(Encode|Decode|Marshal|Unmarshal) != 0 && !o.freestanding {
+		gens = append(gens, unions(out, ids))
 	}
-	if m.isSet(Unmarshal) {
-		gens = append(gens, unmarshal(out))
+	if m.isSet(Unmarshal) && o.views && !o.freestanding {
+		gens = append(gens, views(out, ids, o.keySwitch, o.oldSpec, o.lenientFloats, o.lenientBools))
 	}
-	if m.isSet(Size) {
-		gens = append(gens, sizes(out))
+	if m&(Encode|Marshal) != 0 && o.registry && !o.freestanding {
+		gens = append(gens, registry(out, o))
 	}
-	if m.isSet(marshaltest) {
-		gens = append(gens, mtest(tests))
+	if m&(Encode|Marshal) != 0 && o.fieldConsts {
+		gens = append(gens, fieldConsts(out))
 	}
-	if m.isSet(encodetest) {
-		gens = append(gens, etest(tests))
+	if m.isSet(Marshal|Unmarshal) && o.sql && !o.freestanding {
+		gens = append(gens, sqlMethods(out))
+	}
+	if m.isSet(Test) && o.filling() {
+		gens = append(gens, fills(tests, ids, o))
+	}
+	if m.isSet(marshaltest) && o.testsFor(Marshal|Unmarshal) {
+		gens = append(gens, mtest(tests, o, m.isSet(Equal)))
+	}
+	if m.isSet(encodetest) && o.testsFor(Encode|Decode) {
+		gens = append(gens, etest(tests, o, m.isSet(Equal)))
+	}
+	if m.isSet(marshaltest) && cross != nil {
+		gens = append(gens, crosstest(cross, o, m.isSet(Equal)))
 	}
 	if len(gens) == 0 {
 		panic("newGeneratorSet called with invalid method flags")
 	}
-	return gens
+	return &generatorSet{gens: gens, ids: ids}
 }
 
 // ApplyDirective applies a directive to a named pass and all of its dependents.
-func (gs generatorSet) ApplyDirective(pass Method, t TransformPass) {
-	for _, g := range gs {
+func (gs *generatorSet) ApplyDirective(pass Method, t TransformPass) {
+	for _, g := range gs.gens {
 		if g.Method().isSet(pass) {
 			g.Add(t)
 		}
 	}
 }
 
-// Print prints an Elem.
-func (gs generatorSet) Print(e Elem) error {
-	for _, g := range gs {
-		// Elem.SetVarname() is called before the Print() step in parse.FileSet.PrintTo().
+// Print prints an Elem with the methods' receivers named recv.
+func (gs *generatorSet) Print(e Elem, recv string) error {
+	setIdents(e, gs.ids)
+	gs.ids.recv = recv
+	e.SetVarname(recv)
+	for _, g := range gs.gens {
 		// Elem.SetVarname() generates identifiers as it walks the Elem. This can cause
 		// collisions between idents created during SetVarname and idents created during Print,
 		// hence the separate prefixes.
-		resetIdent("zb")
+		gs.ids.reset("b")
 		err := g.Execute(e)
-		resetIdent("za")
+		gs.ids.reset("a")
 		if err != nil {
 			return err
 		}
@@ -227,10 +281,16 @@ func next(t traversal, e Elem) {
 func imutMethodReceiver(p Elem) string {
 	switch e := p.(type) {
 	case *Struct:
+		switch e.Receiver {
+		case pointerReceiver:
+			return "*" + p.TypeName()
+		case valueReceiver:
+			return p.TypeName()
+		}
 		// TODO(HACK): actually do real math here.
 		if len(e.Fields) <= 3 {
 			for i := range e.Fields {
-				if be, ok := e.Fields[i].fieldElem.(*BaseElem); !ok || (be.Value == IDENT || be.Value == Bytes) {
+				if be, ok := e.Fields[i].fieldElem.(*BaseElem); !ok || (be.Value == IDENT || be.Value == Bytes || be.Value == Raw || be.Value == StrBytes) {
 					goto nope
 				}
 			}
@@ -264,21 +324,65 @@ func methodReceiver(p Elem) string {
 	}
 }
 
-// unsetReceiver sets Varname to "z" for the Elem if its not a *Struct or *Array.
-func unsetReceiver(e Elem) {
+// unsetReceiver sets Varname to the receiver name recv for the Elem if its not a *Struct or *Array.
+func unsetReceiver(e Elem, recv string) {
 	switch e.(type) {
 	case *Struct, *Array:
 	default:
-		e.SetVarname("z")
+		e.SetVarname(recv)
 	}
 }
 
+// reservedNames are the names of the parameters, results, and variables declared by name in the
+// generated methods and of the packages that the methods refer to.
+var reservedNames = map[string]bool{
+	"b": true, "bts": true, "bytes": true, "c": true, "dc": true, "en": true, "err": true,
+	"field": true, "msgp": true, "o": true, "s": true, "src": true,
+}
+
+// checkReceiver returns an error if name cannot be the name of the receivers of the generated
+// methods, whose variables are named with identPrefix.
+func checkReceiver(name, identPrefix string) error {
+	switch {
+	case !token.IsIdentifier(name) || name == "_":
+		return fmt.Errorf("the receiver name %q is not a valid identifier", name)
+	case reservedNames[name]:
+		return fmt.Errorf("the receiver name %q is used in the generated methods", name)
+	case strings.HasPrefix(name, identPrefix+"a") || strings.HasPrefix(name, identPrefix+"b"):
+		return fmt.Errorf("the receiver name %q may collide with the variables prefixed with %q", name, identPrefix)
+	}
+	return nil
+}
+
+// readName returns the name of what reads the values of the base type named base (as in "Read" +
+// base), which for strings and bytes reads 'str' and 'bin' objects interchangeably if oldSpec is
+// set (see OldSpecCompat). Times are read as either of the extensions msgp.TimeExtension and
+// msgp.TimestampExtension, so that the times written by other implementations are decoded.
+func readName(base string, oldSpec bool) string {
+	if base == "Time" {
+		return "TimeExt"
+	}
+	if oldSpec {
+		switch base {
+		case "String", "Bytes", "ExactBytes":
+			return base + "OldSpec"
+		}
+	}
+	return base
+}
+
 // The printer type is a shared utility for generators.
 type printer struct {
-	w   io.Writer
-	err error
+	w    io.Writer
+	ids  *identGen
+	err  error
+	wrap bool     // whether the decoding errors are wrapped with their paths (see WrapErrors)
+	path []string // the expressions of the path to the value being decoded
 }
 
+// newIdent generates a name for a variable declared for what key identifies (see identGen.newIdent).
+func (p *printer) newIdent(key string) string { return p.ids.newIdent(key) }
+
 // declare writes on a new line "var {{name}} {{typ}}"
 func (p *printer) declare(name, typ string) {
 	p.printf("\nvar %s %s", name, typ)
@@ -305,7 +409,11 @@ func (p *printer) resizeMap(size string, m *Map) {
 // assign key to value based on varnames
 func (p *printer) mapAssign(m *Map) {
 	if p.ok() {
-		p.printf("\n%s[%s] = %s", m.Varname(), m.KeyIndx, m.ValIndx)
+		if m.Key != "" {
+			p.printf("\n%s[%s(%s)] = %s", m.Varname(), m.Key, m.KeyIndx, m.ValIndx)
+		} else {
+			p.printf("\n%s[%s] = %s", m.Varname(), m.KeyIndx, m.ValIndx)
+		}
 	}
 }
 
@@ -318,8 +426,306 @@ func (p *printer) resizeSlice(size string, s *Slice) {
 	p.printf("\nif cap(%[1]s) >= int(%[2]s) { %[1]s = (%[1]s)[:%[2]s] } else { %[1]s = make(%[3]s, %[2]s) }", s.Varname(), size, s.TypeName())
 }
 
+// zeroField assigns to field i of struct s the zero value of the field's type.
+func (p *printer) zeroField(s *Struct, i int) {
+	vn := s.Varname() + "." + s.Fields[i].fieldName
+	switch e := s.Fields[i].fieldElem.(type) {
+	case *Ptr, *Slice, *Map:
+		p.printf("\n%s = nil", vn)
+		return
+	case *Struct, *Array:
+		p.printf("\n%s = %s{}", vn, e.TypeName())
+		return
+	case *BaseElem:
+		if e.ShimToBase == "" {
+			switch e.Value {
+			case Bytes, Intf, Raw, StrBytes:
+				p.printf("\n%s = nil", vn)
+				return
+			case String, Padded:
+				p.printf("\n%s = \"\"", vn)
+				return
+			case Bool:
+				p.printf("\n%s = false", vn)
+				return
+			case Time, Ext, Binary, Text, Decimal, IDENT:
+			default:
+				p.printf("\n%s = 0", vn)
+				return
+			}
+		}
+	}
+	tmp := p.newIdent(vn + " zero")
+	p.printf("\n{\nvar %s %s\n%s = %s\n}", tmp, s.Fields[i].fieldElem.TypeName(), vn, tmp)
+}
+
+// defaultKeySwitch is the default number of fields from which the keys of a struct written as a
+// map are matched by fieldSwitch with a switch on their lengths and bytes.
+const defaultKeySwitch = 16
+
+// fieldSwitch prints the beginning of a switch selecting the field of s whose key is in the
+// variable "field". With at least keySwitch fields (if keySwitch is positive), the index of the
+// field is found by switching on the length of the key and on the bytes of the key at which the
+// keys of the fields differ, and the switch is on the index; otherwise, the switch is on the key
+// as a string. The cases are printed with fieldCase and reservedCase, given the returned index
+// variable (which is empty for a switch on the key). The reserved keys are indexed after the fields.
+func (p *printer) fieldSwitch(s *Struct, keySwitch int) string {
+	if keySwitch <= 0 || len(s.Fields) < keySwitch {
+		p.print("\nswitch string(field) {")
+		return ""
+	}
+	idx := p.newIdent(s.Varname() + " field")
+	p.printf("\n%s := -1", idx)
+	keys := make([]string, 0, len(s.Fields)+len(s.Reserved))
+	for i := range s.Fields {
+		keys = append(keys, s.Fields[i].fieldTag)
+	}
+	keys = append(keys, s.Reserved...)
+	var lengths []int
+	byLen := make(map[int][]int)
+	for i := range keys {
+		l := len(keys[i])
+		if byLen[l] == nil {
+			lengths = append(lengths, l)
+		}
+		byLen[l] = append(byLen[l], i)
+	}
+	sort.Ints(lengths)
+	p.print("\nswitch len(field) {")
+	for _, l := range lengths {
+		p.printf("\ncase %d:", l)
+		p.keyBytes(idx, keys, byLen[l])
+	}
+	p.closeBlock()
+	p.printf("\nswitch %s {", idx)
+	return idx
+}
+
+// keyBytes prints the code setting idx to the index of the key among the keys at indexes (which
+// have the same length) that is in the variable "field".
+func (p *printer) keyBytes(idx string, keys []string, indexes []int) {
+	// The switch is on the byte at which the keys take the most values.
+	tag := keys[indexes[0]]
+	pos, most := 0, 1
+	for i := 0; i < len(tag); i++ {
+		seen := make(map[byte]bool)
+		for _, k := range indexes {
+			seen[keys[k][i]] = true
+		}
+		if len(seen) > most {
+			pos, most = i, len(seen)
+		}
+	}
+	if len(indexes) == 1 || most == 1 {
+		p.printf("\nif string(field) == %q {\n%s = %d\n}", tag, idx, indexes[0])
+		return
+	}
+	var values []byte
+	byByte := make(map[byte][]int)
+	for _, k := range indexes {
+		b := keys[k][pos]
+		if byByte[b] == nil {
+			values = append(values, b)
+		}
+		byByte[b] = append(byByte[b], k)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	p.printf("\nswitch field[%d] {", pos)
+	for _, b := range values {
+		p.printf("\ncase %q:", b)
+		p.keyBytes(idx, keys, byByte[b])
+	}
+	p.closeBlock()
+}
+
+// fieldCase prints the case for field i of s in a switch printed by fieldSwitch.
+func (p *printer) fieldCase(idx string, s *Struct, i int) {
+	if s.IntKeys {
+		p.printf("\ncase %s:", s.Fields[i].fieldTag)
+	} else if idx == "" {
+		p.printf("\ncase \"%s\":", s.Fields[i].fieldTag)
+	} else {
+		p.printf("\ncase %d:", i)
+	}
+}
+
+// reservedCase prints the case for the reserved keys of s, if it has any, in a switch printed by
+// fieldSwitch. The values of the keys are skipped with the code in skip.
+func (p *printer) reservedCase(idx string, s *Struct, skip string) {
+	if len(s.Reserved) == 0 {
+		return
+	}
+	cases := make([]string, len(s.Reserved))
+	for i, key := range s.Reserved {
+		if s.IntKeys {
+			cases[i] = key
+		} else if idx == "" {
+			cases[i] = strconv.Quote(key)
+		} else {
+			cases[i] = strconv.Itoa(len(s.Fields) + i)
+		}
+	}
+	p.printf("\ncase %s:", strings.Join(cases, ", "))
+	p.comment("reserved for removed fields")
+	p.print(skip)
+	p.errCheck()
+}
+
+// tracked says if decoding a struct needs to track whether its field f has been decoded. The fields
+// tagged omitzero are tracked so that the fields left out are zeroed rather than keeping the values
+// they had before decoding.
+func (f *structField) tracked() bool { return f.required || f.dflt != "" || f.zero != "" }
+
+// declareSeen declares a bitset for tracking which of the required fields, fields with default
+// values, and fields tagged omitzero of s have been decoded and returns its name. The bits are
+// ordered like the fields. If s has no such fields, nothing is declared and the returned name is
+// empty.
+func (p *printer) declareSeen(s *Struct) string {
+	var n int
+	for i := range s.Fields {
+		if s.Fields[i].tracked() {
+			n++
+		}
+	}
+	if n == 0 {
+		return ""
+	}
+	seen := p.newIdent(s.Varname() + " seen")
+	p.declare(seen, fmt.Sprintf("[%d]uint64", (n+63)/64))
+	return seen
+}
+
+// seenBit returns the index of the element of a bitset declared by declareSeen and the mask
+// of the bit for tracked field i of s.
+func seenBit(s *Struct, i int) (int, uint64) {
+	var n int
+	for j := 0; j < i; j++ {
+		if s.Fields[j].tracked() {
+			n++
+		}
+	}
+	return n / 64, 1 << uint(n%64)
+}
+
+// markSeen sets the bit in seen for field i of s if the field is tracked.
+func (p *printer) markSeen(seen string, s *Struct, i int) {
+	if s.Fields[i].tracked() {
+		w, m := seenBit(s, i)
+		p.printf("\n%s[%d] |= 0x%x", seen, w, m)
+	}
+}
+
+// checkSeen handles the tracked fields of s not marked in seen: an error is returned for a
+// missing required field, and the other fields are set to their default or zero values.
+func (p *printer) checkSeen(seen string, s *Struct) {
+	for i := range s.Fields {
+		if s.Fields[i].tracked() {
+			w, m := seenBit(s, i)
+			p.printf("\nif %s[%d]&0x%x == 0 {", seen, w, m)
+			p.missingField(s, i)
+			p.closeBlock()
+		}
+	}
+}
+
+// missingField handles field i of s being missing from the encoding. If the field is required,
+// an error is returned; otherwise, the field is set to its default value or, if it has none,
+// to the zero value of its type.
+func (p *printer) missingField(s *Struct, i int) {
+	switch f := &s.Fields[i]; {
+	case f.required:
+		p.printf("\nerr = %s\nreturn", p.wrapped(fmt.Sprintf("msgp.ErrMissingField{Type: %q, Field: %q}", structName(s), f.fieldTag)))
+	case f.dflt != "":
+		p.printf("\n%s.%s = %s", s.Varname(), f.fieldName, f.dflt)
+	default:
+		p.zeroField(s, i)
+	}
+}
+
+// fieldKey returns the encoding of the key of field i of s and a description of the key for the
+// comments in the generated code.
+func fieldKey(s *Struct, i int) (data []byte, desc string) {
+	tag := s.Fields[i].fieldTag
+	if s.IntKeys {
+		k, _ := strconv.ParseInt(tag, 10, 64) // checked by the intkeys directive
+		if k >= 0 {
+			// The unsigned encodings of 128 to 255 are shorter.
+			return msgp.AppendUint64(nil, uint64(k)), "key " + tag
+		}
+		return msgp.AppendInt64(nil, k), "key " + tag
+	}
+	return msgp.AppendString(nil, tag), strconv.Quote(tag)
+}
+
+// structName returns the name of the struct type, or "struct" if it is not a named type.
+func structName(s *Struct) string {
+	if name := s.TypeName(); !strings.HasPrefix(name, "struct{") {
+		return name
+	}
+	return "struct"
+}
+
+// omitted prints the code testing which of the fields of s tagged omitzero are left out of the
+// encoding and counting the fields written. It returns the name of the count (a uint32) and, by
+// field, the names of the bools saying if the fields are left out (empty for the fields always
+// written). If s has no such fields, nothing is printed and the count is empty.
+func (p *printer) omitted(s *Struct) (count string, omit []string) {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if f.zero == "" {
+			continue
+		}
+		if count == "" {
+			count = p.newIdent(s.Varname() + " len")
+			omit = make([]string, len(s.Fields))
+			p.printf("\n%s := uint32(%d)", count, len(s.Fields))
+		}
+		omit[i] = p.newIdent(s.Varname() + "." + f.fieldName + " omit")
+		p.printf("\n%s := %s", omit[i], fmt.Sprintf(f.zero, s.Varname()+"."+f.fieldName))
+		p.printf("\nif %s {\n%s--\n}", omit[i], count)
+	}
+	return count, omit
+}
+
+// unionCheck prints, for a union, the check that count (the number of fields set when encoding
+// or of the entries in the map when decoding) is at most one.
+func (p *printer) unionCheck(s *Struct, count string) {
+	p.printf("\nif %s > 1 { err = %s; return }", count, p.wrapped(fmt.Sprintf("msgp.UnionError{Type: %q, Fields: int(%s)}", structName(s), count)))
+}
+
+// unionKey prints the default case of the switch on the keys of a union, failing on the unknown
+// key in field.
+func (p *printer) unionKey(s *Struct) {
+	p.printf("\ndefault:\nerr = %s\nreturn", p.wrapped(fmt.Sprintf("msgp.UnionError{Type: %q, Key: string(field)}", structName(s))))
+}
+
 func (p *printer) arrayCheck(want, got string) {
-	p.printf("\nif %[1]s != %[2]s { err = msgp.ArrayError{Wanted: %[2]s, Got: %[1]s}; return }", got, want)
+	p.printf("\nif %s != %s { err = %s; return }", got, want, p.wrapped(fmt.Sprintf("msgp.ArrayError{Wanted: %s, Got: %s}", want, got)))
+}
+
+// enter appends to the path of the value being decoded elem, the expression of a field name, an
+// index, or a map key. Each call is paired with a call to leave.
+func (p *printer) enter(elem string) { p.path = append(p.path, elem) }
+
+// leave removes the last element of the path of the value being decoded.
+func (p *printer) leave() { p.path = p.path[:len(p.path)-1] }
+
+// wrapped returns the expression err, an error, wrapped with the path of the value being decoded
+// if the errors are wrapped.
+func (p *printer) wrapped(err string) string {
+	if !p.wrap || len(p.path) == 0 {
+		return err
+	}
+	return fmt.Sprintf("msgp.WrapError(%s, %s)", err, strings.Join(p.path, ", "))
+}
+
+// errCheck prints the check returning err if it is not nil, wrapped if the errors are wrapped.
+func (p *printer) errCheck() {
+	if e := p.wrapped("err"); e != "err" {
+		p.printf("\nif err != nil {\nerr = %s\nreturn\n}", e)
+		return
+	}
+	p.print(errCheck)
 }
 
 // rangeBlock prints:
@@ -328,7 +734,9 @@ func (p *printer) arrayCheck(want, got string) {
 //  }
 func (p *printer) rangeBlock(idx string, iter string, t traversal, inner Elem) {
 	p.printf("\n for %s := range %s {", idx, iter)
+	p.enter(idx)
 	next(t, inner)
+	p.leave()
 	p.closeBlock()
 }
 
@@ -342,6 +750,22 @@ func (p *printer) nakedReturn() {
 	}
 }
 
+// callHook prints a call of the hook method on the receiver recv, if the method is named.
+func (p *printer) callHook(recv, method string) {
+	if method != "" {
+		p.printf("\nerr = %s.%s()", recv, method)
+		p.print(errCheck)
+	}
+}
+
+// hooks returns the methods given to the hooks directive for e.
+func hooks(e Elem) (pre, post string) {
+	if st, ok := e.(*Struct); ok {
+		return st.PreHook, st.PostHook
+	}
+	return "", ""
+}
+
 func (p *printer) comment(s string) {
 	p.print("\n// " + s)
 }
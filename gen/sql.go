@@ -0,0 +1,38 @@
+package gen
+
+import "io"
+
+func sqlMethods(w io.Writer) *sqlGen {
+	return &sqlGen{p: printer{w: w}}
+}
+
+// A sqlGen prints the Value and Scan methods making the types database/sql/driver.Valuers and
+// database/sql.Scanners stored as their MessagePack encodings, as in BLOB columns.
+type sqlGen struct {
+	passes
+	p printer
+}
+
+func (s *sqlGen) Method() Method { return Marshal | Unmarshal }
+
+func (s *sqlGen) Execute(p Elem) error {
+	if !s.p.ok() {
+		return s.p.err
+	}
+	p = s.applyAll(p)
+	if p == nil || !isPrintable(p) {
+		return nil
+	}
+	// The receivers are named as for the other methods of the type (see the receiver directive).
+	name, recv := p.TypeName(), p.Varname()
+	s.p.comment("Value implements driver.Valuer, returning the MessagePack encoding of " + recv)
+	s.p.printf("\nfunc (%s %s) Value() (driver.Value, error) {", recv, name)
+	s.p.printf("\nreturn %s.MarshalMsg(nil)\n}\n", recv)
+	s.p.comment("Scan implements sql.Scanner, decoding the MessagePack in src; a NULL sets " + recv + " to zero")
+	s.p.printf("\nfunc (%s *%s) Scan(src interface{}) error {", recv, name)
+	s.p.print("\nbts, err := msgp.ScanBytes(src)")
+	s.p.print("\nif err != nil {\nreturn err\n}")
+	s.p.printf("\nif bts == nil {\n*%s = *new(%s)\nreturn nil\n}", recv, name)
+	s.p.printf("\n_, err = %s.UnmarshalMsg(bts)\nreturn err\n}\n", recv)
+	return s.p.err
+}
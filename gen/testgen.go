@@ -1,6 +1,7 @@
 package gen
 
 import (
+	"go/ast"
 	"io"
 	"text/template"
 )
@@ -14,13 +15,15 @@ var (
 // For simplicity's sake, right now we can only generate tests for types that
 // can be initialized with the "Type{}" syntax. We should support all the types.
 
-func mtest(w io.Writer) *mtestGen {
-	return &mtestGen{w: w}
+func mtest(w io.Writer, o *options, equal bool) *mtestGen {
+	return &mtestGen{w: w, o: o, equal: equal}
 }
 
 type mtestGen struct {
 	passes
-	w io.Writer
+	w     io.Writer
+	o     *options
+	equal bool // whether the types have Equal methods
 }
 
 func (m *mtestGen) Execute(p Elem) error {
@@ -28,7 +31,7 @@ func (m *mtestGen) Execute(p Elem) error {
 	if p != nil && isPrintable(p) {
 		switch p.(type) {
 		case *Struct, *Array, *Slice, *Map:
-			return marshalTestTempl.Execute(m.w, p)
+			return executeTest(marshalTestTempl, m.w, p, m.o, m.equal)
 		}
 	}
 	return nil
@@ -38,11 +41,13 @@ func (m *mtestGen) Method() Method { return marshaltest }
 
 type etestGen struct {
 	passes
-	w io.Writer
+	w     io.Writer
+	o     *options
+	equal bool // whether the types have Equal methods
 }
 
-func etest(w io.Writer) *etestGen {
-	return &etestGen{w: w}
+func etest(w io.Writer, o *options, equal bool) *etestGen {
+	return &etestGen{w: w, o: o, equal: equal}
 }
 
 func (e *etestGen) Execute(p Elem) error {
@@ -50,7 +55,7 @@ func (e *etestGen) Execute(p Elem) error {
 	if p != nil && isPrintable(p) {
 		switch p.(type) {
 		case *Struct, *Array, *Slice, *Map:
-			return encodeTestTempl.Execute(e.w, p)
+			return executeTest(encodeTestTempl, e.w, p, e.o, e.equal)
 		}
 	}
 	return nil
@@ -58,122 +63,89 @@ func (e *etestGen) Execute(p Elem) error {
 
 func (e *etestGen) Method() Method { return encodetest }
 
-func init() {
-	template.Must(marshalTestTempl.Parse(`func TestMarshalUnmarshal{{.TypeName}}(t *testing.T) {
-	v := {{.TypeName}}{}
-	bts, err := v.MarshalMsg(nil)
-	if err != nil {
-		t.Fatal(err)
+// testData is what the test templates are executed with.
+type testData struct {
+	Name     string // the name of the type, used in the names of the test functions
+	TypeName string // the type name, qualified with the package name in external tests
+	Bench    bool   // whether to print benchmarks
+	Value    string // the value that the tests and benchmarks use
+	Equal    string // the function or method saying if the decoded value equals v
+	Tuple    bool   // whether the value is a struct written as an array
+}
+
+// executeTest prints the tests for p using the template t. The decoded values are compared with
+// the Equal methods if equal is true and with msgptest.Equal otherwise.
+func executeTest(t *template.Template, w io.Writer, p Elem, o *options, equal bool) error {
+	name := p.TypeName()
+	if o.externalTests && !ast.IsExported(name) {
+		warnfAt(p.Pos(), "not generating tests for unexported type %s in the external test package\n", name)
+		return nil
 	}
-	left, err := v.UnmarshalMsg(bts)
-	if err != nil {
-		t.Fatal(err)
+	data := &testData{
+		Name:     name,
+		TypeName: o.testQual + name,
+		Bench:    !o.noBench,
+		Value:    o.testQual + name + "{}",
+		Equal:    "msgptest.Equal(v, out)",
 	}
-	if len(left) > 0 {
-		t.Errorf("%d bytes left over after UnmarshalMsg(): %q", len(left), left)
+	if o.filled[name] {
+		data.Value = fillName(name) + "(rand.New(rand.NewSource(1)))"
 	}
-
-	left, err = msgp.Skip(bts)
-	if err != nil {
-		t.Fatal(err)
+	if equal {
+		data.Equal = "v.Equal(out)"
 	}
-	if len(left) > 0 {
-		t.Errorf("%d bytes left over after Skip(): %q", len(left), left)
+	if s, ok := p.(*Struct); ok {
+		data.Tuple = s.AsTuple
 	}
+	return t.Execute(w, data)
 }
 
-func BenchmarkMarshalMsg{{.TypeName}}(b *testing.B) {
-	v := {{.TypeName}}{}
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i:=0; i<b.N; i++ {
-		v.MarshalMsg(nil)
+func init() {
+	template.Must(marshalTestTempl.Parse(`func TestMarshalUnmarshal{{.Name}}(t *testing.T) {
+	v := {{.Value}}
+	var out {{.TypeName}}
+	msgptest.RoundTrip(t, &v, &out)
+	if !{{.Equal}} {
+		t.Error("the unmarshaled value differs from the marshaled one")
 	}
 }
-
-func BenchmarkAppendMsg{{.TypeName}}(b *testing.B) {
-	v := {{.TypeName}}{}
-	bts := make([]byte, 0, v.Msgsize())
-	bts, _ = v.MarshalMsg(bts[0:0])
-	b.SetBytes(int64(len(bts)))
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i:=0; i<b.N; i++ {
-		bts, _ = v.MarshalMsg(bts[0:0])
-	}
+{{if .Bench}}
+func BenchmarkMarshalMsg{{.Name}}(b *testing.B) {
+	v := {{.Value}}
+	msgptest.BenchmarkMarshal(b, &v)
 }
 
-func BenchmarkUnmarshal{{.TypeName}}(b *testing.B) {
-	v := {{.TypeName}}{}
-	bts, _ := v.MarshalMsg(nil)
-	b.ReportAllocs()
-	b.SetBytes(int64(len(bts)))
-	b.ResetTimer()
-	for i:=0; i<b.N; i++ {
-		_, err := v.UnmarshalMsg(bts)
-		if err != nil {
-			b.Fatal(err)
-		}
-	}
+func BenchmarkAppendMsg{{.Name}}(b *testing.B) {
+	v := {{.Value}}
+	msgptest.BenchmarkAppend(b, &v)
 }
 
+func BenchmarkUnmarshal{{.Name}}(b *testing.B) {
+	v := {{.Value}}
+	msgptest.BenchmarkUnmarshal(b, &v, &{{.TypeName}}{})
+}
+{{end}}
 `))
 
-	template.Must(encodeTestTempl.Parse(`func TestEncodeDecode{{.TypeName}}(t *testing.T) {
-	v := {{.TypeName}}{}
-	var buf bytes.Buffer
-	msgp.Encode(&buf, &v)
-
-	m := v.Msgsize()
-	if buf.Len() > m {
-		t.Logf("WARNING: Msgsize() for %v is inaccurate", v)
-	}
-
-	vn := {{.TypeName}}{}
-	err := msgp.Decode(&buf, &vn)
-	if err != nil {
-		t.Error(err)
-	}
-
-	buf.Reset()
-	msgp.Encode(&buf, &v)
-	err = msgp.NewReader(&buf).Skip()
-	if err != nil {
-		t.Error(err)
+	template.Must(encodeTestTempl.Parse(`func TestEncodeDecode{{.Name}}(t *testing.T) {
+	v := {{.Value}}
+	var out {{.TypeName}}
+	msgptest.EncodeDecode(t, &v, &out)
+	if !{{.Equal}} {
+		t.Error("the decoded value differs from the encoded one")
 	}
 }
-
-func BenchmarkEncode{{.TypeName}}(b *testing.B) {
-	v := {{.TypeName}}{}
-	var buf bytes.Buffer 
-	msgp.Encode(&buf, &v)
-	b.SetBytes(int64(buf.Len()))
-	en := msgp.NewWriter(msgp.Nowhere)
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i:=0; i<b.N; i++ {
-		v.EncodeMsg(en)
-	}
-	en.Flush()
-}
-
-func BenchmarkDecode{{.TypeName}}(b *testing.B) {
-	v := {{.TypeName}}{}
-	var buf bytes.Buffer
-	msgp.Encode(&buf, &v)
-	b.SetBytes(int64(buf.Len()))
-	rd := msgp.NewEndlessReader(buf.Bytes(), b)
-	dc := msgp.NewReader(rd)
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i:=0; i<b.N; i++ {
-		err := v.DecodeMsg(dc)
-		if  err != nil {
-			b.Fatal(err)
-		}
-	}
+{{if .Bench}}
+func BenchmarkEncode{{.Name}}(b *testing.B) {
+	v := {{.Value}}
+	msgptest.BenchmarkEncode(b, &v)
 }
 
+func BenchmarkDecode{{.Name}}(b *testing.B) {
+	v := {{.Value}}
+	msgptest.BenchmarkDecode(b, &v, &{{.TypeName}}{})
+}
+{{end}}
 `))
 
 }
@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+func unions(w io.Writer, ids *identGen) *unionGen {
+	return &unionGen{
+		p: printer{w: w, ids: ids},
+	}
+}
+
+// A unionGen prints the methods setting and getting the fields of unions, the structs given to the
+// union directive. Setting a field clears the others, so a union set only through the methods is
+// always encoded.
+type unionGen struct {
+	passes
+	p printer
+}
+
+func (u *unionGen) Method() Method { return Encode | Decode }
+
+func (u *unionGen) Execute(p Elem) error {
+	if !u.p.ok() {
+		return u.p.err
+	}
+	p = u.applyAll(p)
+	if p == nil {
+		return nil
+	}
+	s, ok := p.(*Struct)
+	if !ok || !s.Union {
+		return nil
+	}
+	recv := s.Varname()
+	arg := "v" // the parameter of the Set methods, which must not be named as the receiver
+	if arg == recv {
+		arg = "x"
+	}
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		name := exportedName(f.fieldName)
+		typ := f.fieldElem.TypeName()
+		u.p.comment("Set" + name + " sets " + f.fieldName + " to " + arg + " and the other fields of " + recv + " to nil")
+		u.p.printf("\nfunc (%s *%s) Set%s(%s %s) {", recv, s.TypeName(), name, arg, typ)
+		u.p.printf("\n*%s = %s{%s: %s}", recv, s.TypeName(), f.fieldName, arg)
+		u.p.print("\n}\n")
+		u.p.comment("Get" + name + " returns " + f.fieldName + ", which is nil unless it is the field set")
+		u.p.printf("\nfunc (%s *%s) Get%s() %s {", recv, s.TypeName(), name, typ)
+		u.p.printf("\nreturn %s.%s", recv, f.fieldName)
+		u.p.print("\n}\n")
+	}
+	return u.p.err
+}
+
+// sameExportedNames returns the names of two of the fields whose exported names (which name the
+// methods of unions) are the same, or empty strings if there are none.
+func sameExportedNames(fields []structField) (string, string) {
+	seen := make(map[string]string, len(fields))
+	for i := range fields {
+		name := fields[i].fieldName
+		if prev, ok := seen[exportedName(name)]; ok {
+			return prev, name
+		}
+		seen[exportedName(name)] = name
+	}
+	return "", ""
+}
+
+// exportedName returns name with its first letter in upper case.
+func exportedName(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
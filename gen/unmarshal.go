@@ -5,16 +5,24 @@ import (
 	"strconv"
 )
 
-func unmarshal(w io.Writer) *unmarshalGen {
+func unmarshal(w io.Writer, ids *identGen, keySwitch int, oldSpec, lenientFloats, lenientBools, wrap bool) *unmarshalGen {
 	return &unmarshalGen{
-		p: printer{w: w},
+		p:             printer{w: w, ids: ids, wrap: wrap},
+		keySwitch:     keySwitch,
+		oldSpec:       oldSpec,
+		lenientFloats: lenientFloats,
+		lenientBools:  lenientBools,
 	}
 }
 
 type unmarshalGen struct {
 	passes
-	p        printer
-	hasField bool
+	p             printer
+	hasField      bool
+	keySwitch     int  // the number of fields from which keys are matched by length and bytes
+	oldSpec       bool // read 'str' and 'bin' objects interchangeably
+	lenientFloats bool // read integers into float64s
+	lenientBools  bool // read the integers 0 and 1 into bools
 }
 
 func (u *unmarshalGen) Method() Method { return Unmarshal }
@@ -38,8 +46,10 @@ func (u *unmarshalGen) Execute(p Elem) error {
 	u.p.printf("\nfunc (%s %s) UnmarshalMsg(bts []byte) (o []byte, err error) {", p.Varname(), methodReceiver(p))
 	next(u, p)
 	u.p.print("\no = bts")
+	_, post := hooks(p)
+	u.p.callHook(p.Varname(), post)
 	u.p.nakedReturn()
-	unsetReceiver(p)
+	unsetReceiver(p, u.p.ids.recv)
 	return u.p.err
 
 }
@@ -50,7 +60,7 @@ func (u *unmarshalGen) assignAndCheck(name, base string) {
 		return
 	}
 	u.p.printf("\n%s, bts, err = msgp.Read%sBytes(bts)", name, base)
-	u.p.print(errCheck)
+	u.p.errCheck()
 }
 
 func (u *unmarshalGen) gStruct(s *Struct) {
@@ -65,51 +75,104 @@ func (u *unmarshalGen) gStruct(s *Struct) {
 }
 
 func (u *unmarshalGen) tuple(s *Struct) {
-	sz := randIdent()
-	u.p.declare(sz, u32)
-	u.assignAndCheck(sz, arrayHeader)
-	u.p.arrayCheck(strconv.Itoa(len(s.Fields)), sz)
+	if s.TupleCompat {
+		sz := u.p.newIdent(s.Varname() + " size")
+		u.p.declare(sz, u32)
+		u.assignAndCheck(sz, arrayHeader)
+		for i := range s.Fields {
+			if !u.p.ok() {
+				return
+			}
+			// A "field" variable declared within the block is not visible after it.
+			hasField := u.hasField
+			u.p.printf("\nif %[1]s > 0 {\n%[1]s--", sz)
+			u.field(s, i)
+			u.hasField = hasField
+			u.p.print("\n} else {")
+			u.p.missingField(s, i)
+			u.p.closeBlock()
+		}
+		u.p.printf("\nfor ; %[1]s > 0; %[1]s-- {\nbts, err = msgp.Skip(bts)", sz)
+		u.p.errCheck()
+		u.p.closeBlock()
+		return
+	}
+	u.p.printf("\nbts, err = msgp.ReadExpectedArrayHeader(bts, %d)", len(s.Fields))
+	u.p.errCheck()
 	for i := range s.Fields {
 		if !u.p.ok() {
 			return
 		}
-		next(u, s.Fields[i].fieldElem)
+		u.field(s, i)
 	}
 }
 
+// field prints the unmarshaling of field i of s.
+func (u *unmarshalGen) field(s *Struct, i int) {
+	u.p.enter(strconv.Quote(s.Fields[i].fieldName))
+	next(u, s.Fields[i].fieldElem)
+	u.p.leave()
+}
+
 func (u *unmarshalGen) structAsMap(s *Struct) {
 
-	if !u.hasField {
+	if !u.hasField && !s.IntKeys {
 		u.p.declare("field", "[]byte")
 		u.hasField = true
 	}
 
 	// Declare the variable that will contain the map length.
-	sz := randIdent()
+	sz := u.p.newIdent(s.Varname() + " size")
 	u.p.declare(sz, u32)
 
 	// Assign to the sz variable the length of the map, and get remaining bytes
 	// in a variable named "bts".
 	u.assignAndCheck(sz, mapHeader)
+	if s.Union {
+		u.p.unionCheck(s, sz)
+	}
 
-	u.p.printf("\nfor %s > 0 {", sz)
-	u.p.printf("\n%s--", sz)
-	u.p.print("\nfield, bts, err = msgp.ReadMapKeyZC(bts)")
-	u.p.print(errCheck)
-	u.p.print("\nswitch string(field) {")
+	seen := u.p.declareSeen(s)
+
+	var idx string
+	if s.IntKeys {
+		key := u.p.newIdent(s.Varname() + " key")
+		u.p.declare(key, "int64")
+		u.p.printf("\nfor %s > 0 {", sz)
+		u.p.printf("\n%s--", sz)
+		u.p.printf("\n%s, bts, err = msgp.ReadInt64Bytes(bts)", key)
+		u.p.errCheck()
+		u.p.printf("\nswitch %s {", key)
+	} else {
+		u.p.printf("\nfor %s > 0 {", sz)
+		u.p.printf("\n%s--", sz)
+		u.p.print("\nfield, bts, err = msgp.ReadMapKeyZC(bts)")
+		u.p.errCheck()
+		idx = u.p.fieldSwitch(s, u.keySwitch)
+	}
 	for i := range s.Fields {
 		if !u.p.ok() {
 			return
 		}
-		u.p.printf("\ncase \"%s\":", s.Fields[i].fieldTag)
-		next(u, s.Fields[i].fieldElem)
+		u.p.fieldCase(idx, s, i)
+		u.p.markSeen(seen, s, i)
+		u.field(s, i)
+	}
+	u.p.reservedCase(idx, s, "\nbts, err = msgp.Skip(bts)")
+	if s.Union {
+		u.p.unionKey(s)
+	} else {
+		u.p.print("\ndefault:\nbts, err = msgp.Skip(bts)")
+		u.p.errCheck()
 	}
-	u.p.print("\ndefault:\nbts, err = msgp.Skip(bts)")
-	u.p.print(errCheck)
 
 	u.p.closeBlock() // close switch block
 	u.p.closeBlock() // close for loop
 
+	if seen != "" {
+		u.p.checkSeen(seen, s)
+	}
+
 }
 
 func (u *unmarshalGen) gBase(b *BaseElem) {
@@ -125,21 +188,31 @@ func (u *unmarshalGen) gBase(b *BaseElem) {
 		// Open 'tmp' block.
 		lowered = b.ToBase() + "(" + lowered + ")"
 		u.p.print("\n{") // inner scope
-		refname = randIdent()
+		refname = u.p.newIdent(b.Varname() + " base")
 		u.p.declare(refname, b.BaseType())
 	}
 
 	switch b.Value {
-	case Bytes:
-		u.p.printf("\n%s, bts, err = msgp.ReadBytesBytes(bts, %s)", refname, lowered)
-	case Ext:
-		u.p.printf("\nbts, err = msgp.ReadExtensionBytes(bts, %s)", lowered)
+	case Bytes, StrBytes:
+		u.p.printf("\n%s, bts, err = msgp.Read%sBytes(bts, %s)", refname, readName("Bytes", u.oldSpec || b.Value == StrBytes), lowered)
+	case Raw:
+		u.p.printf("\n%s, bts, err = msgp.ReadRawFieldBytes(bts, %s)", refname, lowered)
+	case Ext, Binary, Text, Decimal:
+		u.p.printf("\nbts, err = msgp.Read%sBytes(bts, %s)", b.BaseName(), lowered)
 	case IDENT:
 		u.p.printf("\nbts, err = %s.UnmarshalMsg(bts)", lowered)
+	case Padded:
+		u.p.printf("\n%s, bts, err = msgp.ReadPaddedStringBytes(bts, %s)", refname, b.paddingExpr())
+	case Float64, Bool:
+		read := "Read" + b.BaseName() + "Bytes"
+		if (b.Value == Float64 && u.lenientFloats) || (b.Value == Bool && u.lenientBools) {
+			read += "Lenient"
+		}
+		u.p.printf("\n%s, bts, err = msgp.%s(bts)", refname, read)
 	default:
-		u.p.printf("\n%s, bts, err = msgp.Read%sBytes(bts)", refname, b.BaseName())
+		u.p.printf("\n%s, bts, err = msgp.Read%sBytes(bts)", refname, readName(b.BaseName(), u.oldSpec))
 	}
-	u.p.print(errCheck)
+	u.p.errCheck()
 
 	if b.Convert {
 		// Close 'tmp' block.
@@ -147,7 +220,7 @@ func (u *unmarshalGen) gBase(b *BaseElem) {
 			u.p.printf("\n%s = %s(%s)\n", b.Varname(), b.FromBase(), refname)
 		} else {
 			u.p.printf("\n%s, err = %s(%s)", b.Varname(), b.FromBase(), refname)
-			u.p.print(errCheck)
+			u.p.errCheck()
 		}
 		u.p.printf("}")
 	}
@@ -162,15 +235,13 @@ func (u *unmarshalGen) gArray(a *Array) {
 	// special case for [const]byte objects
 	// see decode.go for symmetry
 	if be, ok := a.Els.(*BaseElem); ok && be.Value == Byte {
-		u.p.printf("\nbts, err = msgp.ReadExactBytes(bts, (%s)[:])", a.Varname())
-		u.p.print(errCheck)
+		u.p.printf("\nbts, err = msgp.Read%s(bts, (%s)[:])", readName("ExactBytes", u.oldSpec || a.Str), a.Varname())
+		u.p.errCheck()
 		return
 	}
 
-	sz := randIdent()
-	u.p.declare(sz, u32)
-	u.assignAndCheck(sz, arrayHeader)
-	u.p.arrayCheck(coerceArraySize(a.Size), sz)
+	u.p.printf("\nbts, err = msgp.ReadExpectedArrayHeader(bts, %s)", coerceArraySize(a.Size))
+	u.p.errCheck()
 	u.p.rangeBlock(a.Index, a.Varname(), u, a.Els)
 }
 
@@ -178,7 +249,7 @@ func (u *unmarshalGen) gSlice(s *Slice) {
 	if !u.p.ok() {
 		return
 	}
-	sz := randIdent()
+	sz := u.p.newIdent(s.Varname() + " size")
 	u.p.declare(sz, u32)
 	u.assignAndCheck(sz, arrayHeader)
 	u.p.resizeSlice(sz, s)
@@ -189,7 +260,13 @@ func (u *unmarshalGen) gMap(m *Map) {
 	if !u.p.ok() {
 		return
 	}
-	sz := randIdent()
+	// The fast paths read only 'str' keys.
+	if fast := m.fastPath(); fast != "" && !u.oldSpec {
+		u.p.printf("\n%[1]s, bts, err = msgp.Read%[2]sBytes(bts, %[1]s)", m.Varname(), fast)
+		u.p.errCheck()
+		return
+	}
+	sz := u.p.newIdent(m.Varname() + " size")
 	u.p.declare(sz, u32)
 	u.assignAndCheck(sz, mapHeader)
 
@@ -201,14 +278,18 @@ func (u *unmarshalGen) gMap(m *Map) {
 	u.p.declare(m.KeyIndx, "string")
 	u.p.declare(m.ValIndx, m.Value.TypeName())
 	u.p.printf("\n%s--", sz)
-	u.assignAndCheck(m.KeyIndx, stringTyp)
+	u.assignAndCheck(m.KeyIndx, readName(stringTyp, u.oldSpec))
+	u.p.enter(m.KeyIndx)
 	next(u, m.Value)
+	u.p.leave()
 	u.p.mapAssign(m)
 	u.p.closeBlock()
 }
 
 func (u *unmarshalGen) gPtr(p *Ptr) {
-	u.p.printf("\nif msgp.IsNil(bts) { bts, err = msgp.ReadNilBytes(bts); if err != nil { return }; %s = nil; } else { ", p.Varname())
+	u.p.print("\nif msgp.IsNil(bts) {\nbts, err = msgp.ReadNilBytes(bts)")
+	u.p.errCheck()
+	u.p.printf("\n%s = nil\n} else {", p.Varname())
 	u.p.initPtr(p)
 	next(u, p.Value)
 	u.p.closeBlock()
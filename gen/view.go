@@ -0,0 +1,60 @@
+package gen
+
+import (
+	"io"
+)
+
+func views(w io.Writer, ids *identGen, keySwitch int, oldSpec, lenientFloats, lenientBools bool) *viewGen {
+	return &viewGen{u: unmarshal(w, ids, keySwitch, oldSpec, lenientFloats, lenientBools, false)}
+}
+
+// A viewGen prints the View types of structs (see Views). The accessors of the fields locate the
+// fields with msgp.LocateField and decode them with the code printed by an unmarshalGen. Views are
+// not printed for tuples and structs with integer keys.
+type viewGen struct {
+	passes
+	u *unmarshalGen
+}
+
+func (v *viewGen) Method() Method { return Unmarshal }
+
+func (v *viewGen) Execute(p Elem) error {
+	u := v.u
+	if !u.p.ok() {
+		return u.p.err
+	}
+	p = v.applyAll(p)
+	if p == nil {
+		return nil
+	}
+	s, ok := p.(*Struct)
+	if !ok || s.AsTuple || s.IntKeys || len(s.Fields) == 0 {
+		return nil
+	}
+
+	view := s.TypeName() + "View"
+	recv := s.Varname()
+	u.p.comment(view + " is an encoded " + s.TypeName() + " whose fields are decoded by its methods on demand")
+	u.p.printf("\ntype %s []byte\n", view)
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		el := f.fieldElem.Copy()
+		el.SetVarname("value")
+		u.hasField = false
+		u.p.comment(f.fieldName + " decodes the " + f.fieldName + " field of " + recv)
+		u.p.printf("\nfunc (%s %s) %s() (value %s, err error) {", recv, view, f.fieldName, el.TypeName())
+		u.p.printf("\nbts, err := msgp.LocateField(%s, %q)", recv, f.fieldTag)
+		u.p.print("\nif err != nil {\nreturn\n}")
+		u.p.print("\nif bts == nil {")
+		switch {
+		case f.required:
+			u.p.printf("\nerr = msgp.ErrMissingField{Type: %q, Field: %q}", structName(s), f.fieldTag)
+		case f.dflt != "":
+			u.p.printf("\nvalue = %s", f.dflt)
+		}
+		u.p.print("\nreturn\n}")
+		next(u, el)
+		u.p.print("\nreturn\n}\n")
+	}
+	return u.p.err
+}
@@ -13,7 +13,81 @@
 //  -src = input file name or directory (default is $GOFILE set by the `go generate` command)
 //  -io = satisfy the `msgp.Decoder` and `msgp.Encoder` interfaces (default is true)
 //  -marshal = satisfy the `msgp.Marshaler` and `msgp.Unmarshaler` interfaces (default is true)
-//  -tests = generate tests and benchmarks (default is true)
+//  -copy = create Clone methods making deep copies of values without reflection (default is false);
+//          named types referred to must have Clone methods as well
+//  -equal = create Equal methods comparing the contents of values (default is false); named types
+//           referred to must have Equal methods as well
+//  -tests = generate tests and benchmarks (default is true); set to "marshal-only" or "encode-only"
+//           to generate only the tests for the Marshal/Unmarshal or the Encode/Decode methods
+//  -bench = generate benchmarks along with the tests (default is true)
+//  -fillsize = size of the values that the generated tests and benchmarks use (default is 8): the
+//              number of random letters in strings and the number of elements in slices and maps;
+//              0 makes the tests and benchmarks use zero values
+//  -xtests = put the generated tests in the external test package {package}_test (default is false)
+//  -outdir = write the generated test files to this directory, in a package importing the source
+//            package (the methods stay next to the source, in its package)
+//  -outpkg = name of the package of the test files written to -outdir (default is the directory name)
+//  -unexported = also process unexported types (default is false)
+//  -maxcomplexity = inline the types with a complexity below this limit (default is 5); use the
+//                   //msgp:inline and //msgp:noinline directives to control the inlining of single types
+//  -keyswitch = match the keys of structs with at least this many fields (default is 16) by switching on
+//               the lengths and bytes of the keys rather than on the keys as strings; 0 turns this off
+//  -kvfields = write the structs with at least this many fields by passing tables of their keys and values
+//              to msgp.Writer.WriteKV and msgp.AppendKV, which makes much less code but is slower (default
+//              is 0, which turns this off)
+//  -shims = comma-separated list of Go files from which to load //msgp:shim directives shared by
+//           many packages
+//  -exactsize = also generate a MsgsizeExact method computing the exact encoded size of a value
+//               (default is false); named types referred to must implement msgp.ExactSizer
+//  -tightsize = make Msgsize measure strings, map keys, and integers rather than add the maximum sizes
+//               of their types (default is false): the bounds are tighter but slower to compute
+//  -compactfloats = write each float64 as a float32 if that loses nothing, and in full otherwise, for
+//                   smaller messages of mostly small or round floats (default is false)
+//  -identprefix = prefix of the names of the variables declared in the generated methods (default is "z")
+//  -receiver = name of the receivers of the generated methods (default is "z"); use the
+//              //msgp:receiver {Type} {name} directive to name the receivers of a single type
+//  -stableidents = derive the names of the variables from the fields they are for rather than numbering
+//                  them, so that changing a field does not rename the variables for other fields
+//                  (default is false)
+//  -compat = set to "old-spec" to make the generated decoders read 'str' and 'bin' objects interchangeably
+//            into strings and byte slices, as written by producers following the old MessagePack spec
+//  -lenientfloats = make the generated decoders read integers into float64s, as written for whole numbers by
+//                   producers that do not keep the types of numbers (default is false)
+//  -lenientbools = make the generated decoders read the integers 0 and 1 into bools, as written by many producers
+//                  in C (default is false)
+//  -strict = treat warnings (such as about ignored fields or unresolved identifiers) as errors: if any are
+//            reported, no files are written and msgp exits with status 2
+//  -diag = set to "json" to print the warnings to the standard error as JSON objects, one per line, with
+//          the fields file, line, column, type, and reason, instead of printing them to the standard output
+//  -views = also generate for each struct a View type, a []byte holding an encoded value, with methods
+//           decoding the fields one at a time without unmarshaling the whole value (default is false)
+//  -crosstests = also generate, in a file built with the msgpcross tag, tests checking that the
+//                structs with fields of primitive types are encoded and decoded like by the library
+//                github.com/vmihailenco/msgpack/v5 (default is false)
+//  -freestanding = generate, for the structs of fixed-size integers, floats, and bools, MarshalMsg,
+//                  UnmarshalMsg, and Msgsize methods that do not import the msgp package, for programs
+//                  built with few dependencies; other types are skipped with a warning (default is false)
+//  -wraperrors = wrap the errors of decoding fields, elements, and map values in *msgp.PathError errors
+//                giving the path to the value that could not be decoded (default is false)
+//  -schema = also write a JSON Schema document describing the messages of the types to a file named like
+//            the output file with the suffix _schema.json in place of .go (default is false)
+//  -registry = register the names, keys, and types of the fields of each type with msgp.RegisterSchema
+//              in init functions, for tools interpreting messages by type name (default is false)
+//  -fieldconsts = also generate for each struct written as a map constants holding the keys of its fields,
+//                 such as PointFieldX for the field X of Point (default is false)
+//  -sql = also generate Value and Scan methods storing the values in database columns as their
+//         MessagePack encodings, with MarshalMsg and UnmarshalMsg (default is false)
+//  -tags = comma-separated list of build tags for selecting the files of a directory given with -src
+//          (GOOS and GOARCH are taken from the environment); the generated files get the build
+//          constraint shared by the processed files, and the types of files with further
+//          constraints are skipped
+//
+// The msgp command also has subcommands for converting and inspecting data in shell pipelines.
+// Each reads the named file, or the standard input if no file is named, and writes to the standard output:
+//
+//  msgp json2mp [file] = translate JSON values into MessagePack
+//  msgp mp2json [file] = translate MessagePack objects into JSON values, one per line
+//  msgp inspect [file] = print an indented, human-readable rendering of MessagePack objects
 //
 // You can also import github.com/dchenk/msgp/gen and use the code generator from any of your Go programs.
 //
@@ -22,9 +96,12 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/dchenk/msgp/gen"
 	"github.com/ttacon/chalk"
@@ -35,12 +112,80 @@ var (
 	out        = flag.String("o", "", "output file")
 	encode     = flag.Bool("io", true, "create Encode and Decode methods")
 	marshal    = flag.Bool("marshal", true, "create Marshal and Unmarshal methods")
-	tests      = flag.Bool("tests", true, "create tests and benchmarks")
+	clone      = flag.Bool("copy", false, "create Clone methods making deep copies")
+	equal      = flag.Bool("equal", false, "create Equal methods comparing values")
+	tests      = testsFlag("true")
+	bench      = flag.Bool("bench", true, "create benchmarks along with tests")
+	fillSize   = flag.Int("fillsize", 8, "size of the random values used by the tests and benchmarks")
+	xtests     = flag.Bool("xtests", false, "put tests in the external test package")
+	outDir     = flag.String("outdir", "", "directory to write the test files to, in a package importing the source package")
+	outPkg     = flag.String("outpkg", "", "package of the test files written to -outdir (default is the directory name)")
 	unexported = flag.Bool("unexported", false, "also process unexported types")
+	maxComplex = flag.Int("maxcomplexity", 5, "inline types with a complexity below this limit")
+	keySwitch  = flag.Int("keyswitch", 16, "match keys by length and bytes for structs with this many fields")
+	kvFields   = flag.Int("kvfields", 0, "write structs with this many fields with tables of keys and values")
+	shims      = flag.String("shims", "", "comma-separated list of files with shared shim directives")
+	tags       = flag.String("tags", "", "comma-separated list of build tags for selecting files")
+	compat     = flag.String("compat", "", "set to old-spec to read 'str' and 'bin' interchangeably")
+	lenientFlt = flag.Bool("lenientfloats", false, "read integers into float64s")
+	lenientBl  = flag.Bool("lenientbools", false, "read the integers 0 and 1 into bools")
+	exactSize  = flag.Bool("exactsize", false, "create MsgsizeExact methods computing exact sizes")
+	tightSize  = flag.Bool("tightsize", false, "make Msgsize measure values for tighter bounds")
+	compactFlt = flag.Bool("compactfloats", false, "write float64s as float32s when that loses nothing")
+	prefix     = flag.String("identprefix", "z", "prefix of the names of generated variables")
+	receiver   = flag.String("receiver", "z", "name of the receivers of generated methods")
+	stable     = flag.Bool("stableidents", false, "derive the names of generated variables from the fields")
+	strict     = flag.Bool("strict", false, "treat warnings as errors")
+	diag       = flag.String("diag", "", "set to json to print warnings as JSON objects to the standard error")
+	views      = flag.Bool("views", false, "create View types decoding the fields of encoded structs on demand")
+	crossTests = flag.Bool("crosstests", false, "create tests against github.com/vmihailenco/msgpack (with the msgpcross tag)")
+	freeStand  = flag.Bool("freestanding", false, "create methods not importing msgp for structs of fixed-size primitives")
+	wrapErrs   = flag.Bool("wraperrors", false, "wrap decoding errors with the paths of the values that could not be decoded")
+	schema     = flag.Bool("schema", false, "write a JSON Schema document describing the messages of the types")
+	registry   = flag.Bool("registry", false, "register the field tables of the types with msgp.RegisterSchema")
+	fieldCnsts = flag.Bool("fieldconsts", false, "create constants holding the keys of the fields of structs")
+	sqlMethods = flag.Bool("sql", false, "create database/sql Value and Scan methods storing the values as MessagePack")
 )
 
+func init() {
+	flag.Var(&tests, "tests", "create tests and benchmarks (true, false, marshal-only, or encode-only)")
+}
+
+// testsFlag is the value of the -tests flag. It may be given as a boolean or as the
+// name of the kind of tests to generate.
+type testsFlag string
+
+func (t *testsFlag) String() string { return string(*t) }
+
+func (t *testsFlag) Set(s string) error {
+	switch s {
+	case "marshal-only", "encode-only":
+		*t = testsFlag(s)
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid value %q (want true, false, marshal-only, or encode-only)", s)
+	}
+	*t = testsFlag(strconv.FormatBool(b))
+	return nil
+}
+
+// IsBoolFlag allows the -tests flag to be given without a value.
+func (t *testsFlag) IsBoolFlag() bool { return true }
+
 func main() {
 
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := runCommand(cmd, os.Args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, chalk.Red.Color(err.Error()))
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
 	if *src == "" {
@@ -59,12 +204,119 @@ func main() {
 	if *marshal {
 		mode |= (gen.Marshal | gen.Unmarshal | gen.Size)
 	}
-	if *tests {
+	if *clone {
+		mode |= gen.Copy
+	}
+	if *equal {
+		mode |= gen.Equal
+	}
+
+	var opts []gen.Option
+	switch tests {
+	case "false":
+	case "marshal-only":
+		mode |= gen.Test
+		opts = append(opts, gen.TestsFor(gen.Marshal|gen.Unmarshal))
+	case "encode-only":
 		mode |= gen.Test
+		opts = append(opts, gen.TestsFor(gen.Encode|gen.Decode))
+	default:
+		mode |= gen.Test
+	}
+	if !*bench {
+		opts = append(opts, gen.NoBenchmarks())
+	}
+	opts = append(opts, gen.FillSize(*fillSize))
+	if *xtests {
+		opts = append(opts, gen.ExternalTests())
+	}
+	if *outDir != "" {
+		opts = append(opts, gen.OutDir(*outDir))
+	}
+	if *outPkg != "" {
+		opts = append(opts, gen.OutPackage(*outPkg))
+	}
+	opts = append(opts, gen.MaxComplexity(*maxComplex))
+	opts = append(opts, gen.KeySwitch(*keySwitch))
+	if *kvFields > 0 {
+		opts = append(opts, gen.KVFields(*kvFields))
+	}
+	if *shims != "" {
+		opts = append(opts, gen.Shims(strings.Split(*shims, ",")...))
+	}
+	if *tags != "" {
+		opts = append(opts, gen.Tags(strings.Split(*tags, ",")...))
+	}
+	if *exactSize {
+		opts = append(opts, gen.ExactSizes())
+	}
+	if *tightSize {
+		opts = append(opts, gen.TightSizes())
+	}
+	if *compactFlt {
+		opts = append(opts, gen.CompactFloats())
+	}
+	switch *compat {
+	case "":
+	case "old-spec":
+		opts = append(opts, gen.OldSpecCompat())
+	default:
+		fmt.Println(chalk.Red.Color(fmt.Sprintf("Invalid -compat value %q (want old-spec).", *compat)))
+		os.Exit(1)
+	}
+	if *lenientFlt {
+		opts = append(opts, gen.LenientFloats())
+	}
+	if *lenientBl {
+		opts = append(opts, gen.LenientBools())
+	}
+	opts = append(opts, gen.IdentPrefix(*prefix))
+	opts = append(opts, gen.Receiver(*receiver))
+	if *stable {
+		opts = append(opts, gen.StableIdents())
+	}
+	if *strict {
+		opts = append(opts, gen.Strict())
+	}
+	if *views {
+		opts = append(opts, gen.Views())
+	}
+	if *crossTests {
+		opts = append(opts, gen.CrossTests())
+	}
+	if *freeStand {
+		opts = append(opts, gen.Freestanding())
+	}
+	if *wrapErrs {
+		opts = append(opts, gen.WrapErrors())
+	}
+	if *schema {
+		opts = append(opts, gen.Schema())
+	}
+	if *registry {
+		opts = append(opts, gen.Registry())
+	}
+	if *fieldCnsts {
+		opts = append(opts, gen.FieldConsts())
+	}
+	if *sqlMethods {
+		opts = append(opts, gen.SQL())
+	}
+	switch *diag {
+	case "":
+	case "json":
+		enc := json.NewEncoder(os.Stderr)
+		opts = append(opts, gen.HandleWarnings(func(d gen.Diagnostic) { enc.Encode(d) }))
+	default:
+		fmt.Println(chalk.Red.Color(fmt.Sprintf("Invalid -diag value %q (want json).", *diag)))
+		os.Exit(1)
 	}
 
-	if err := gen.Run(*src, *out, mode, *unexported); err != nil {
+	if err := gen.Run(*src, *out, mode, *unexported, opts...); err != nil {
 		fmt.Println(chalk.Red.Color(err.Error()))
+		if _, ok := err.(gen.WarningsError); ok {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 
@@ -0,0 +1,67 @@
+package msgp
+
+import (
+	"bytes"
+	"encoding"
+)
+
+// WriteBinary writes the binary form of v (the data returned by its MarshalBinary method) as a
+// 'bin' object. The generated methods write the fields tagged with the "binary" option this way.
+func (mw *Writer) WriteBinary(v encoding.BinaryMarshaler) error {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return mw.WriteBytes(data)
+}
+
+// AppendBinary appends the binary form of v to b as a 'bin' object.
+func AppendBinary(b []byte, v encoding.BinaryMarshaler) ([]byte, error) {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return b, err
+	}
+	return AppendBytes(b, data), nil
+}
+
+// ReadBinary reads a 'bin' object and unmarshals its data into v. The data passed to the
+// UnmarshalBinary method of v is valid only until the method returns.
+func (m *Reader) ReadBinary(v encoding.BinaryUnmarshaler) error {
+	var err error
+	m.scratch, err = m.ReadBytes(m.scratch[:0])
+	if err != nil {
+		return err
+	}
+	return v.UnmarshalBinary(m.scratch)
+}
+
+// ReadBinaryBytes reads a 'bin' object from b, unmarshals its data into v, and returns any
+// remaining bytes. The data passed to the UnmarshalBinary method of v points into b.
+func ReadBinaryBytes(b []byte, v encoding.BinaryUnmarshaler) ([]byte, error) {
+	data, o, err := ReadBytesZC(b)
+	if err != nil {
+		return b, err
+	}
+	if err = v.UnmarshalBinary(data); err != nil {
+		return b, err
+	}
+	return o, nil
+}
+
+// SizeOfBinary returns the encoded size of the binary form of v, which it marshals to measure. If
+// v fails to marshal, the size of an empty 'bin' object is returned.
+func SizeOfBinary(v encoding.BinaryMarshaler) int {
+	data, _ := v.MarshalBinary()
+	return SizeOfBytes(data)
+}
+
+// EqualBinary says if the binary forms of a and b are the same, as generated Equal methods compare
+// the values written in their binary forms. Values that fail to marshal are not equal to anything.
+func EqualBinary(a, b encoding.BinaryMarshaler) bool {
+	da, err := a.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	db, err := b.MarshalBinary()
+	return err == nil && bytes.Equal(da, db)
+}
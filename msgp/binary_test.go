@@ -0,0 +1,61 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBinary(t *testing.T) {
+	// time.Time implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+	in := time.Date(2020, 2, 3, 4, 5, 6, 7, time.FixedZone("", 3600))
+	data, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := AppendBinary(nil, &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := AppendBytes(nil, data); !bytes.Equal(b, want) {
+		t.Errorf("AppendBinary wrote %x; want %x", b, want)
+	}
+	if n := SizeOfBinary(&in); n != len(b) {
+		t.Errorf("SizeOfBinary() = %d; want %d", n, len(b))
+	}
+
+	var out time.Time
+	left, err := ReadBinaryBytes(b, &out)
+	if err != nil || len(left) != 0 || !out.Equal(in) {
+		t.Errorf("ReadBinaryBytes read %v with %d bytes left and error %v", out, len(left), err)
+	}
+	if !EqualBinary(&in, &out) {
+		t.Errorf("EqualBinary says %v and %v differ", in, out)
+	}
+	if EqualBinary(&in, &time.Time{}) {
+		t.Errorf("EqualBinary says %v and the zero time are equal", in)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteBinary(&in); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("WriteBinary wrote %x; want %x", buf.Bytes(), b)
+	}
+	out = time.Time{}
+	if err := NewReader(&buf).ReadBinary(&out); err != nil || !out.Equal(in) {
+		t.Errorf("ReadBinary read %v with error %v", out, err)
+	}
+
+	// The data is checked by UnmarshalBinary.
+	if _, err := ReadBinaryBytes(AppendBytes(nil, []byte{1}), &out); err == nil {
+		t.Error("ReadBinaryBytes read an invalid time")
+	}
+	if _, err := ReadBinaryBytes(AppendString(nil, "x"), &out); err == nil {
+		t.Error("ReadBinaryBytes read a 'str' object")
+	}
+}
@@ -0,0 +1,158 @@
+package msgp
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// A Codec compresses and decompresses streams of MessagePack. A stream written through a Codec by
+// a CompressedWriter starts with the Codec's ID byte, so that NewCompressedReader can pick the
+// Codec to read it with from those it is given.
+//
+// This package implements flate compression (FlateCodec) and no compression (NoCompression).
+// Other compressions, such as Snappy or zstd, are used by implementing Codec with their packages,
+// whose compressors and decompressors are made with functions taking the io.Writer or io.Reader.
+type Codec interface {
+	// CodecID returns the byte identifying the compression at the start of a stream. The IDs
+	// below 16 are reserved for the compressions with ID constants in this package.
+	CodecID() byte
+
+	// NewWriter returns a compressor writing the compressed data to w. If the compressor has a
+	// Flush() error method, it is flushed when the CompressedWriter is.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a decompressor of the data read from r.
+	NewReader(r io.Reader) (io.Reader, error)
+}
+
+// The IDs of the compressions, which the Codecs implementing them should return as their CodecIDs
+// so that streams can be read by other programs.
+const (
+	NoCompressionID byte = iota
+	FlateID
+	SnappyID
+	ZstdID
+)
+
+// NoCompression is a Codec leaving the data uncompressed, as a sender can choose for a stream.
+var NoCompression Codec = noCompression{}
+
+type noCompression struct{}
+
+func (noCompression) CodecID() byte                                 { return NoCompressionID }
+func (noCompression) NewWriter(w io.Writer) (io.WriteCloser, error) { return nopCloser{w}, nil }
+func (noCompression) NewReader(r io.Reader) (io.Reader, error)      { return r, nil }
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// A FlateCodec is a Codec compressing with the compress/flate package at the given level. The
+// zero value compresses at level 0, flate.NoCompression; use flate.DefaultCompression for the
+// default level.
+type FlateCodec struct {
+	Level int
+}
+
+// CodecID implements Codec.
+func (c FlateCodec) CodecID() byte { return FlateID }
+
+// NewWriter implements Codec.
+func (c FlateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, c.Level)
+}
+
+// NewReader implements Codec.
+func (c FlateCodec) NewReader(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil }
+
+// An UnknownCodecError is returned by NewCompressedReader when a stream starts with the ID of
+// none of the Codecs given.
+type UnknownCodecError byte
+
+// Error implements the error interface.
+func (e UnknownCodecError) Error() string {
+	return fmt.Sprintf("msgp: stream compressed with an unknown codec (ID %d)", byte(e))
+}
+
+// Resumable is always false for UnknownCodecError errors.
+func (e UnknownCodecError) Resumable() bool { return false }
+
+// A CompressedWriter is a Writer whose data is compressed by a Codec. Its Flush method flushes the
+// compressor too, and Close must be called after the last object is written to finish the stream.
+type CompressedWriter struct {
+	*Writer
+	z io.WriteCloser
+}
+
+// NewCompressedWriter returns a CompressedWriter writing to w the ID of c followed by the data
+// compressed by c. The ID is written along with the first compressed data.
+func NewCompressedWriter(w io.Writer, c Codec) (*CompressedWriter, error) {
+	z, err := c.NewWriter(&idWriter{w: w, id: c.CodecID()})
+	if err != nil {
+		return nil, err
+	}
+	return &CompressedWriter{Writer: NewWriter(z), z: z}, nil
+}
+
+// Flush writes the buffered data to the compressor and flushes the compressor if it can be
+// flushed, so that a reader can decode all of the objects written.
+func (cw *CompressedWriter) Flush() error {
+	if err := cw.Writer.Flush(); err != nil {
+		return err
+	}
+	if f, ok := cw.z.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close writes the buffered data to the compressor and closes the compressor, which finishes the
+// compressed stream. The underlying io.Writer is not closed.
+func (cw *CompressedWriter) Close() error {
+	if err := cw.Writer.Flush(); err != nil {
+		return err
+	}
+	return cw.z.Close()
+}
+
+// An idWriter writes the ID of a Codec before the first data written to w.
+type idWriter struct {
+	w       io.Writer
+	id      byte
+	written bool
+}
+
+func (w *idWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		if _, err := w.w.Write([]byte{w.id}); err != nil {
+			return 0, err
+		}
+		w.written = true
+	}
+	return w.w.Write(p)
+}
+
+// NewCompressedReader reads the ID of the Codec at the start of the stream r and returns a Reader
+// of the data decompressed by the Codec in codecs with the ID. If codecs is empty, NoCompression
+// and FlateCodec are looked through. Possible errors include those reading r and making the
+// decompressor and UnknownCodecError.
+func NewCompressedReader(r io.Reader, codecs ...Codec) (*Reader, error) {
+	var id [1]byte
+	if _, err := io.ReadFull(r, id[:]); err != nil {
+		return nil, err
+	}
+	if len(codecs) == 0 {
+		codecs = []Codec{NoCompression, FlateCodec{}}
+	}
+	for _, c := range codecs {
+		if c.CodecID() == id[0] {
+			z, err := c.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return NewReader(z), nil
+		}
+	}
+	return nil, UnknownCodecError(id[0])
+}
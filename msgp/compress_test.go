@@ -0,0 +1,60 @@
+package msgp
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+)
+
+func TestCompressedStream(t *testing.T) {
+	var plain []byte
+	for i := 0; i < 100; i++ {
+		plain = AppendString(plain, "a string repeated to be compressed")
+		plain = AppendInt(plain, i)
+	}
+	for _, c := range []Codec{NoCompression, FlateCodec{Level: flate.BestSpeed}} {
+		var buf bytes.Buffer
+		w, err := NewCompressedWriter(&buf, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			w.WriteString("a string repeated to be compressed")
+			w.WriteInt(i)
+		}
+		if err = w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		flushed := buf.Len()
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Bytes()[0] != c.CodecID() {
+			t.Errorf("codec %d: the stream starts with %d", c.CodecID(), buf.Bytes()[0])
+		}
+		if c == NoCompression && !bytes.Equal(buf.Bytes()[1:], plain) {
+			t.Errorf("wrote %x uncompressed; want %x", buf.Bytes()[1:], plain)
+		}
+		if c != NoCompression && flushed >= len(plain) {
+			t.Errorf("flushed %d bytes compressed; the data has %d", flushed, len(plain))
+		}
+
+		r, err := NewCompressedReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			s, err := r.ReadString()
+			if err != nil || s != "a string repeated to be compressed" {
+				t.Fatalf("codec %d: read %q, %v", c.CodecID(), s, err)
+			}
+			if n, err := r.ReadInt(); err != nil || n != i {
+				t.Fatalf("codec %d: read %d, %v; want %d", c.CodecID(), n, err, i)
+			}
+		}
+	}
+
+	if _, err := NewCompressedReader(bytes.NewReader([]byte{ZstdID}), NoCompression); err != UnknownCodecError(ZstdID) {
+		t.Errorf("got the error %v for a stream with an unknown codec", err)
+	}
+}
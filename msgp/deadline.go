@@ -0,0 +1,100 @@
+package msgp
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrTimeout is matched (with errors.Is) by the errors of Reader.DecodeTimeout and
+// Writer.EncodeTimeout when the deadline passes before the message is read or written. The
+// errors wrap the error of the connection, and they are not resumable: the connection is left
+// in the middle of a message.
+var ErrTimeout error = errTimeout{}
+
+// ErrNoDeadline is returned by Reader.DecodeTimeout and Writer.EncodeTimeout, before anything is
+// read or written, if the connection cannot be given a deadline.
+var ErrNoDeadline error = errNoDeadline{}
+
+type errTimeout struct{}
+
+func (e errTimeout) Error() string   { return "msgp: timed out" }
+func (e errTimeout) Resumable() bool { return false }
+
+type errNoDeadline struct{}
+
+func (e errNoDeadline) Error() string   { return "msgp: the connection has no deadline methods" }
+func (e errNoDeadline) Resumable() bool { return true }
+
+// A timeoutError is the ErrTimeout error of a connection: err is the error returned by the
+// connection, which may be wrapped in a *PathError.
+type timeoutError struct {
+	err error
+}
+
+func (e *timeoutError) Error() string        { return "msgp: timed out: " + e.err.Error() }
+func (e *timeoutError) Resumable() bool      { return false }
+func (e *timeoutError) Timeout() bool        { return true }
+func (e *timeoutError) Unwrap() error        { return e.err }
+func (e *timeoutError) Is(target error) bool { return target == ErrTimeout }
+
+// timedOut returns err as an ErrTimeout error if it is the error of a connection whose deadline
+// passed, which is either os.ErrDeadlineExceeded or has a Timeout method returning true (as the
+// errors of the net package do).
+func timedOut(err error) error {
+	var t interface{ Timeout() bool }
+	if err != nil && (errors.Is(err, os.ErrDeadlineExceeded) || errors.As(err, &t) && t.Timeout()) {
+		return &timeoutError{err: err}
+	}
+	return err
+}
+
+// DecodeTimeout decodes d from m giving the connection a deadline of timeout from now, so that a
+// peer sending a message slowly or not at all cannot block the decoding for longer. The Reader
+// must have been made by NewReader or NewReaderSize (or reset) with a connection having a method
+// SetReadDeadline(time.Time) error, such as a net.Conn; otherwise ErrNoDeadline is returned. The
+// deadline is cleared after the decoding. If the deadline passes, the error matches ErrTimeout.
+//
+// The data already buffered is decoded without waiting for the connection, so the deadline only
+// limits the time spent waiting for the rest of the message.
+func (m *Reader) DecodeTimeout(d Decoder, timeout time.Duration) error {
+	var conn interface{ SetReadDeadline(time.Time) error }
+	if m.src != nil {
+		conn, _ = m.src.r.(interface{ SetReadDeadline(time.Time) error })
+	}
+	if conn == nil {
+		return ErrNoDeadline
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	err := timedOut(d.DecodeMsg(m))
+	if cerr := conn.SetReadDeadline(time.Time{}); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// EncodeTimeout encodes e to mw and flushes mw giving the connection a deadline of timeout from
+// now, so that a peer not reading the messages cannot block the writing for longer. The io.Writer
+// of mw must have a method SetWriteDeadline(time.Time) error, such as a net.Conn; otherwise
+// ErrNoDeadline is returned. The deadline is cleared after the flush. If the deadline passes,
+// the error matches ErrTimeout.
+func (mw *Writer) EncodeTimeout(e Encoder, timeout time.Duration) error {
+	conn, ok := mw.w.(interface{ SetWriteDeadline(time.Time) error })
+	if !ok {
+		return ErrNoDeadline
+	}
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	err := e.EncodeMsg(mw)
+	if err == nil {
+		err = mw.Flush()
+	}
+	err = timedOut(err)
+	if cerr := conn.SetWriteDeadline(time.Time{}); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package msgp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDecodeTimeout(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	msg := AppendString(AppendArrayHeader(nil, 2), "first")
+	go c2.Write(msg) // The second element never comes.
+	var raw Raw
+	err := NewReader(c1).DecodeTimeout(&raw, 20*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got the error %v; want ErrTimeout", err)
+	}
+	if Resumable(err) {
+		t.Error("a timeout is resumable")
+	}
+
+	// The deadline is cleared after the decoding.
+	r := NewReader(c1)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		c2.Write(AppendInt(nil, 5))
+	}()
+	if err := r.DecodeTimeout(&raw, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, AppendInt(nil, 5)) {
+		t.Errorf("decoded %x", []byte(raw))
+	}
+
+	if err := NewReader(bytes.NewReader(msg)).DecodeTimeout(&raw, time.Second); err != ErrNoDeadline {
+		t.Errorf("got the error %v for a bytes.Reader; want ErrNoDeadline", err)
+	}
+}
+
+func TestEncodeTimeout(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	// Nothing reads from c2.
+	raw := Raw(AppendString(nil, "hello"))
+	err := NewWriter(c1).EncodeTimeout(raw, 20*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) || Resumable(err) {
+		t.Fatalf("got the error %v; want ErrTimeout", err)
+	}
+
+	if err := NewWriter(&bytes.Buffer{}).EncodeTimeout(raw, time.Second); err != ErrNoDeadline {
+		t.Errorf("got the error %v for a bytes.Buffer; want ErrNoDeadline", err)
+	}
+}
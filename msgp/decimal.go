@@ -0,0 +1,153 @@
+package msgp
+
+import mathbig "math/big"
+
+// DecimalExtension represents an extension for arbitrary-precision decimals, whose value is a
+// coefficient times ten to the power of an exponent. The data is the exponent as a big-endian
+// int32 followed by the coefficient as in BigIntExtension: a sign byte (0 for positive numbers
+// and 1 for negative numbers) and the big-endian bytes of the absolute value. For example, 12.34
+// (1234 × 10^-2) is the data ff ff ff fe 00 04 d2.
+const DecimalExtension = 8
+
+// A DecimalMarshaler is a decimal that can be written as a DecimalExtension extension. The Decimal
+// type of github.com/shopspring/decimal implements it.
+type DecimalMarshaler interface {
+	Coefficient() *mathbig.Int
+	Exponent() int32
+}
+
+// A DecimalUnmarshaler is a decimal that can be read from a DecimalExtension extension. The coef
+// passed to SetDecimal is not used by the caller after the call.
+//
+// A decimal type of another package is adapted with a small wrapper type, like this one for
+// github.com/shopspring/decimal, which gets its DecimalMarshaler methods from the embedded type:
+//
+//  type Decimal struct{ decimal.Decimal }
+//
+//  func (d *Decimal) SetDecimal(coef *big.Int, exp int32) error {
+//  	d.Decimal = decimal.NewFromBigInt(coef, exp)
+//  	return nil
+//  }
+type DecimalUnmarshaler interface {
+	SetDecimal(coef *mathbig.Int, exp int32) error
+}
+
+// A BigDecimal is a decimal of any precision: Coef × 10^Exp. A nil Coef stands for 0.
+type BigDecimal struct {
+	Coef *mathbig.Int
+	Exp  int32
+}
+
+// Coefficient implements DecimalMarshaler.
+func (d BigDecimal) Coefficient() *mathbig.Int {
+	if d.Coef == nil {
+		return new(mathbig.Int)
+	}
+	return d.Coef
+}
+
+// Exponent implements DecimalMarshaler.
+func (d BigDecimal) Exponent() int32 { return d.Exp }
+
+// SetDecimal implements DecimalUnmarshaler.
+func (d *BigDecimal) SetDecimal(coef *mathbig.Int, exp int32) error {
+	d.Coef = coef
+	d.Exp = exp
+	return nil
+}
+
+// Rat returns the value of d as a big.Rat.
+func (d BigDecimal) Rat() *mathbig.Rat {
+	r := new(mathbig.Rat).SetInt(d.Coefficient())
+	if d.Exp == 0 {
+		return r
+	}
+	exp := int64(d.Exp)
+	if exp < 0 {
+		exp = -exp
+	}
+	pow := new(mathbig.Int).Exp(mathbig.NewInt(10), mathbig.NewInt(exp), nil)
+	if d.Exp > 0 {
+		return r.Mul(r, new(mathbig.Rat).SetInt(pow))
+	}
+	return r.Quo(r, new(mathbig.Rat).SetInt(pow))
+}
+
+// decimalExt is the Extension used to encode decimals.
+type decimalExt struct {
+	coef *mathbig.Int
+	exp  int32
+}
+
+func (e *decimalExt) ExtensionType() int8 { return DecimalExtension }
+
+func (e *decimalExt) Len() int { return 5 + (e.coef.BitLen()+7)/8 }
+
+func (e *decimalExt) MarshalBinaryTo(b []byte) error {
+	big.PutUint32(b, uint32(e.exp))
+	b[4] = 0
+	if e.coef.Sign() < 0 {
+		b[4] = 1
+	}
+	copy(b[5:], e.coef.Bytes())
+	return nil
+}
+
+func (e *decimalExt) UnmarshalBinary(b []byte) error {
+	if len(b) < 5 {
+		return ErrShortBytes
+	}
+	e.exp = int32(big.Uint32(b))
+	e.coef = new(mathbig.Int).SetBytes(b[5:])
+	if b[4] == 1 {
+		e.coef.Neg(e.coef)
+	}
+	return nil
+}
+
+// WriteDecimal writes d as a DecimalExtension extension. The generated methods write the fields
+// tagged with the "decimal" option this way.
+func (mw *Writer) WriteDecimal(d DecimalMarshaler) error {
+	return mw.WriteExtension(&decimalExt{coef: d.Coefficient(), exp: d.Exponent()})
+}
+
+// AppendDecimal appends d to b as a DecimalExtension extension.
+func AppendDecimal(b []byte, d DecimalMarshaler) []byte {
+	b, _ = AppendExtension(b, &decimalExt{coef: d.Coefficient(), exp: d.Exponent()}) // This cannot fail.
+	return b
+}
+
+// ReadDecimal reads a DecimalExtension extension and sets d to its value.
+func (m *Reader) ReadDecimal(d DecimalUnmarshaler) error {
+	var e decimalExt
+	if err := m.ReadExtension(&e); err != nil {
+		return err
+	}
+	return d.SetDecimal(e.coef, e.exp)
+}
+
+// ReadDecimalBytes reads a DecimalExtension extension from b, sets d to its value, and returns
+// any remaining bytes.
+func ReadDecimalBytes(b []byte, d DecimalUnmarshaler) ([]byte, error) {
+	var e decimalExt
+	o, err := ReadExtensionBytes(b, &e)
+	if err != nil {
+		return b, err
+	}
+	if err = d.SetDecimal(e.coef, e.exp); err != nil {
+		return b, err
+	}
+	return o, nil
+}
+
+// SizeOfDecimal returns the encoded size of d.
+func SizeOfDecimal(d DecimalMarshaler) int {
+	return SizeOfExtension(&decimalExt{coef: d.Coefficient(), exp: d.Exponent()})
+}
+
+// EqualDecimal says if a and b have the same coefficients and exponents, and so the same encodings,
+// as generated Equal methods compare decimals. Decimals of the same value written with different
+// exponents, such as 1.5 and 1.50, are not equal.
+func EqualDecimal(a, b DecimalMarshaler) bool {
+	return a.Exponent() == b.Exponent() && a.Coefficient().Cmp(b.Coefficient()) == 0
+}
@@ -0,0 +1,75 @@
+package msgp
+
+import (
+	"bytes"
+	mathbig "math/big"
+	"testing"
+)
+
+func TestDecimalReadWrite(t *testing.T) {
+	d := BigDecimal{Coef: mathbig.NewInt(1234), Exp: -2}
+	b := AppendDecimal(nil, d)
+	want := []byte{mext8, 7, DecimalExtension, 0xff, 0xff, 0xff, 0xfe, 0x00, 0x04, 0xd2}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("AppendDecimal wrote %x; want %x", b, want)
+	}
+	if n := SizeOfDecimal(d); n != len(b) {
+		t.Errorf("SizeOfDecimal = %d; want %d", n, len(b))
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteDecimal(d); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("WriteDecimal wrote %x; want %x", buf.Bytes(), b)
+	}
+
+	cases := []BigDecimal{
+		d,
+		{},
+		{Coef: mathbig.NewInt(-5), Exp: 3},
+		{Coef: new(mathbig.Int).Lsh(mathbig.NewInt(-1), 100), Exp: -30},
+	}
+	for _, c := range cases {
+		b := AppendDecimal(nil, c)
+		var got BigDecimal
+		o, err := ReadDecimalBytes(append(b, 0xc0), &got)
+		if err != nil || len(o) != 1 {
+			t.Errorf("ReadDecimalBytes(%x): %v (%d bytes left)", b, err, len(o))
+		}
+		if !EqualDecimal(got, c) {
+			t.Errorf("read %v×10^%d; want %v×10^%d", got.Coef, got.Exp, c.Coefficient(), c.Exp)
+		}
+		got = BigDecimal{}
+		if err := NewReader(bytes.NewReader(b)).ReadDecimal(&got); err != nil || !EqualDecimal(got, c) {
+			t.Errorf("Reader.ReadDecimal(%x) read %v×10^%d: %v", b, got.Coef, got.Exp, err)
+		}
+	}
+
+	if _, err := ReadDecimalBytes(AppendFloat16(nil, 1), new(BigDecimal)); err == nil {
+		t.Error("read a decimal from a half-precision float")
+	}
+}
+
+func TestBigDecimalRat(t *testing.T) {
+	cases := []struct {
+		d    BigDecimal
+		want string
+	}{
+		{BigDecimal{Coef: mathbig.NewInt(1234), Exp: -2}, "617/50"},
+		{BigDecimal{Coef: mathbig.NewInt(-3), Exp: 2}, "-300/1"},
+		{BigDecimal{Coef: mathbig.NewInt(7)}, "7/1"},
+		{BigDecimal{}, "0/1"},
+	}
+	for _, c := range cases {
+		if got := c.d.Rat().String(); got != c.want {
+			t.Errorf("Rat of %v×10^%d = %s; want %s", c.d.Coef, c.d.Exp, got, c.want)
+		}
+	}
+	if EqualDecimal(BigDecimal{Coef: mathbig.NewInt(15), Exp: -1}, BigDecimal{Coef: mathbig.NewInt(150), Exp: -2}) {
+		t.Error("decimals with different exponents are equal")
+	}
+}
@@ -18,6 +18,11 @@
 //
 // There are also methods for converting MessagePack to JSON without an explicit de-serialization step.
 //
+// The package does not import unsafe, so it builds unchanged where unsafe is not available or not
+// wanted: with TinyGo, on App Engine, and with the purego build tag. (The size of int is found with
+// a constant expression rather than with unsafe.Sizeof.) Any code using unsafe added later must be
+// left out by those tags, with a fallback file built in its place; TestNoUnsafe checks this.
+//
 // For more tips and tricks please visit the wiki at http://github.com/dchenk/msgp
 //
 package msgp
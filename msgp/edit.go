@@ -13,6 +13,32 @@ func Locate(key string, raw []byte) []byte {
 	return raw[s:n]
 }
 
+// LocateField returns the value of the field with the key in the MessagePack map at the start of
+// raw, as a sub-slice of raw, or nil if the map has no field with the key. Unlike Locate, it returns
+// an error if raw does not start with a map or the map is malformed before the field.
+func LocateField(raw []byte, key string) ([]byte, error) {
+	sz, bts, err := ReadMapHeaderBytes(raw)
+	if err != nil {
+		return nil, err
+	}
+	var field []byte
+	for i := uint32(0); i < sz; i++ {
+		field, bts, err = ReadMapKeyZC(bts)
+		if err != nil {
+			return nil, err
+		}
+		rest, err := Skip(bts)
+		if err != nil {
+			return nil, err
+		}
+		if string(field) == key {
+			return bts[:len(bts)-len(rest)], nil
+		}
+		bts = rest
+	}
+	return nil, nil
+}
+
 // Replace takes a key ("key") in a MessagePack map ("raw") and replaces its value
 // with the one provided and returns the new []byte. The returned []byte may point
 // to the same memory as "raw". Replace makes no effort to evaluate the validity
@@ -205,3 +205,26 @@ func BenchmarkLocate(b *testing.B) {
 		Locate("thing_three", raw)
 	}
 }
+
+func TestLocateField(t *testing.T) {
+	raw := AppendMapHeader(nil, 2)
+	raw = AppendString(raw, "a")
+	raw = AppendArrayHeader(raw, 1)
+	raw = AppendInt(raw, 1)
+	raw = AppendString(raw, "b")
+	raw = AppendBool(raw, true)
+
+	val, err := LocateField(raw, "b")
+	if err != nil || !bytes.Equal(val, AppendBool(nil, true)) {
+		t.Errorf("located %x, %v", val, err)
+	}
+	if val, err = LocateField(raw, "c"); err != nil || val != nil {
+		t.Errorf("located %x, %v for a missing key", val, err)
+	}
+	if _, err = LocateField(raw[:len(raw)-3], "b"); err == nil {
+		t.Error("no error for a truncated map")
+	}
+	if _, err = LocateField(AppendNil(nil), "b"); err == nil {
+		t.Error("no error for a nil")
+	}
+}
@@ -91,3 +91,86 @@ const (
 func getType(v byte) Type {
 	return sizes[v].typ
 }
+
+// PrefixInfo classifies the object beginning with the lead byte, for tools (such as debuggers,
+// fuzzers, and proxies) stepping through encoded data. It returns the type of the object, which
+// is ExtensionType for all extensions and InvalidType for the unused lead byte 0xc1; the number
+// of bytes of the object that do not depend on its contents; and the number of bytes following
+// the lead byte that give the length of the object, if it has one.
+//
+// The fixed size is the size of the whole object for nil, bools, numbers, fixstr strings, and the
+// fixext extensions; for the other strings, binary data, and extensions, it is the size of the
+// header (which includes the extension type), followed by the number of bytes of data given by
+// the length; for maps and arrays, it is the size of the header, followed by the number of
+// key-value pairs or elements given by the length (or, for fixmap and fixarray objects, by the
+// lead byte itself).
+func PrefixInfo(lead byte) (t Type, fixedSize int, lengthBytes int) {
+	spec := sizes[lead]
+	switch spec.extra {
+	case extra8:
+		lengthBytes = 1
+	case extra16, map16v, array16v:
+		lengthBytes = 2
+	case extra32, map32v, array32v:
+		lengthBytes = 4
+	}
+	return spec.typ, int(spec.size), lengthBytes
+}
+
+// headerSize returns the number of bytes that the header of an object with the given (valid) lead
+// byte takes: the bytes giving the type, the length, and the extension type of the object.
+func headerSize(lead byte) int {
+	spec := sizes[lead]
+	switch {
+	case spec.typ == ExtensionType && spec.extra == constsize:
+		return 2
+	case spec.extra < 0:
+		return int(spec.size)
+	default:
+		return 1
+	}
+}
+
+// parseHeader returns the type, the length, and the extension type (if the object is an extension)
+// given by the header h of an object; h is headerSize(h[0]) bytes long. The length of a map is its
+// number of key-value pairs, and the length of an array its number of elements; the length of a
+// string, a bin, or an extension is the number of bytes of its data. Other objects have length 0.
+func parseHeader(h []byte) (t Type, length uint32, extType int8) {
+	spec := sizes[h[0]]
+	t = spec.typ
+	switch spec.extra {
+	case constsize:
+		switch t {
+		case StrType:
+			length = uint32(spec.size) - 1
+		case ExtensionType:
+			length = uint32(spec.size) - 2
+		}
+	case extra8:
+		length = uint32(h[1])
+	case extra16, map16v, array16v:
+		length = uint32(big.Uint16(h[1:]))
+	case extra32, map32v, array32v:
+		length = big.Uint32(h[1:])
+	default:
+		// fixmap and fixarray
+		length = uint32(spec.extra)
+		if t == MapType {
+			length /= 2
+		}
+	}
+	if t == ExtensionType {
+		extType = int8(h[len(h)-1])
+		switch extType {
+		case Complex64Extension:
+			t = Complex64Type
+		case Complex128Extension:
+			t = Complex128Type
+		case TimeExtension:
+			t = TimeType
+		case Float16Extension:
+			t = Float16Type
+		}
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package msgp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrefixInfo(t *testing.T) {
+	objs := [][]byte{
+		AppendNil(nil),
+		AppendBool(nil, true),
+		AppendInt64(nil, -5),
+		AppendInt64(nil, -1000),
+		AppendUint64(nil, 1<<40),
+		AppendFloat32(nil, 1.5),
+		AppendFloat64(nil, 0.1),
+		AppendString(nil, "short"),
+		AppendString(nil, string(make([]byte, 40))),
+		AppendString(nil, string(make([]byte, 300))),
+		AppendBytes(nil, []byte("bin")),
+		AppendBytes(nil, make([]byte, 70000)),
+		AppendTime(nil, time.Now()),
+		AppendComplex64(nil, 1),
+		AppendArrayHeader(nil, 3),
+		AppendArrayHeader(nil, 20),
+		AppendMapHeader(nil, 2),
+		AppendMapHeader(nil, 1<<17),
+	}
+	for _, b := range objs {
+		typ, fixed, lengthBytes := PrefixInfo(b[0])
+		hdr := headerSize(b[0])
+		wantType, length, _ := parseHeader(b[:hdr])
+		if wantType == TimeType || wantType == Complex64Type {
+			wantType = ExtensionType
+		}
+		if typ != wantType {
+			t.Errorf("%x: type %s; want %s", b[0], typ, wantType)
+		}
+		if lengthBytes > 0 {
+			var n uint32
+			for _, c := range b[1 : 1+lengthBytes] {
+				n = n<<8 | uint32(c)
+			}
+			if n != length {
+				t.Errorf("%x: length %d in the %d length bytes; want %d", b[0], n, lengthBytes, length)
+			}
+		}
+		switch typ {
+		case MapType, ArrayType:
+			if fixed != len(b) {
+				t.Errorf("%x: fixed size %d for a header of %d bytes", b[0], fixed, len(b))
+			}
+		case StrType, BinType, ExtensionType:
+			if lengthBytes == 0 {
+				length = 0 // the data is counted in the fixed size
+			}
+			if fixed+int(length) != len(b) {
+				t.Errorf("%x: fixed size %d and length %d for an object of %d bytes", b[0], fixed, length, len(b))
+			}
+		default:
+			if fixed != len(b) {
+				t.Errorf("%x: fixed size %d for an object of %d bytes", b[0], fixed, len(b))
+			}
+		}
+	}
+	if typ, fixed, n := PrefixInfo(0xc1); typ != InvalidType || fixed != 0 || n != 0 {
+		t.Errorf("0xc1 classified as %s, %d, %d", typ, fixed, n)
+	}
+}
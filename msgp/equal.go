@@ -0,0 +1,55 @@
+package msgp
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+)
+
+// EqualIntf says if the values of a and b are equal, as generated Equal methods compare the values
+// held in interfaces and extensions. Byte slices are compared by their contents, times with
+// time.Time.Equal, and maps and slices of interfaces element by element. Values of other types
+// are compared with reflect.DeepEqual, so floats are compared as with the == operator.
+func EqualIntf(a, b interface{}) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case []byte:
+		bb, ok := b.([]byte)
+		return ok && bytes.Equal(a, bb)
+	case Raw:
+		bb, ok := b.(Raw)
+		return ok && a.Equal(bb)
+	case Number:
+		bb, ok := b.(Number)
+		return ok && a.Equal(bb)
+	case time.Time:
+		bb, ok := b.(time.Time)
+		return ok && a.Equal(bb)
+	case map[string]interface{}:
+		bb, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(bb) {
+			return false
+		}
+		for key, val := range a {
+			other, found := bb[key]
+			if !found || !EqualIntf(val, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bb, ok := b.([]interface{})
+		if !ok || len(a) != len(bb) {
+			return false
+		}
+		for i := range a {
+			if !EqualIntf(a[i], bb[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
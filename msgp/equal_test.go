@@ -0,0 +1,40 @@
+package msgp
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEqualIntf(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{nil, nil, true},
+		{nil, 0, false},
+		{int64(3), int64(3), true},
+		{int64(3), int32(3), false},
+		{"msgp", "msgp", true},
+		{[]byte("msgp"), []byte("msgp"), true},
+		{[]byte{}, []byte(nil), true},
+		{[]byte("msgp"), "msgp", false},
+		{Raw(AppendString(nil, "raw")), Raw(AppendString(nil, "raw")), true},
+		{now, now.In(time.UTC), true},
+		{now, now.Add(time.Second), false},
+		{math.NaN(), math.NaN(), false},
+		{map[string]interface{}{"a": []byte("b")}, map[string]interface{}{"a": []byte("b")}, true},
+		{map[string]interface{}{"a": 1.5}, map[string]interface{}{"b": 1.5}, false},
+		{map[string]interface{}{"a": nil}, map[string]interface{}{}, false},
+		{[]interface{}{"a", now}, []interface{}{"a", now.In(time.UTC)}, true},
+		{[]interface{}{"a"}, []interface{}{"a", "b"}, false},
+		{&RawExtension{Type: 3, Data: []byte("x")}, &RawExtension{Type: 3, Data: []byte("x")}, true},
+		{&RawExtension{Type: 3, Data: []byte("x")}, &RawExtension{Type: 4, Data: []byte("x")}, false},
+	}
+	for i, c := range cases {
+		if got := EqualIntf(c.a, c.b); got != c.equal {
+			t.Errorf("case %d: EqualIntf(%#v, %#v) = %t", i, c.a, c.b, got)
+		}
+	}
+}
@@ -1,18 +1,41 @@
 package msgp
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 // ErrShortBytes is returned when the slice being decoded is too short to contain
 // the contents of the message.
 var ErrShortBytes error = errShort{}
 
+// ErrMaxDepthExceeded is returned when the maps and arrays in an object are nested more deeply
+// than the limit set by MaxDepth or Reader.SetMaxDepth.
+var ErrMaxDepthExceeded error = errMaxDepth{}
+
+// ErrTrailingBytes is returned when a slice that should hold exactly one object has bytes left
+// after the object.
+var ErrTrailingBytes error = errTrailing{}
+
+// ErrMessageTooLarge is returned by a Writer when a message exceeds the limit set by
+// Writer.SetMaxMessageSize.
+var ErrMessageTooLarge error = errMessageTooLarge{}
+
+// ErrPathNotFound is returned by PatchInt64 and PatchUint64 when the object has no value at the
+// path: a map has no key, or an array no index, given in the path.
+var ErrPathNotFound error = errPathNotFound{}
+
 // A fatal error is only returned if we reach code that should be unreachable.
 var fatal error = errFatal{}
 
 // Error is the interface satisfied by all of the errors that originate from this package.
+//
+// The errors are either sentinel values (such as ErrShortBytes) or values of the exported error
+// types (such as TypeError and IntOverflow, and *PathError and *ErrUnsupportedType as pointers),
+// so they can be told apart with errors.Is and errors.As even when wrapped in other errors: a
+// *PathError has an Unwrap method. The function Resumable looks through the wrapping too.
 type Error interface {
 	error
 	// Resumable returns whether or not the error means that the stream
@@ -20,11 +43,58 @@ type Error interface {
 	Resumable() bool
 }
 
+// The error types implementing Error.
+var (
+	_ Error = ArrayError{}
+	_ Error = IntOverflow{}
+	_ Error = UintOverflow{}
+	_ Error = UnionError{}
+	_ Error = ErrMissingField{}
+	_ Error = TypeError{}
+	_ Error = InvalidPrefixError(0)
+	_ Error = (*ErrUnsupportedType)(nil)
+	_ Error = (*PathError)(nil)
+	_ Error = ExtensionTypeError{}
+	_ Error = ExtensionLengthError{}
+	_ Error = FrameSizeError{}
+	_ Error = UnknownCodecError(0)
+	_ Error = StringWidthError{}
+)
+
+// Resumable says if err is resumable, meaning that the data after the object that could not be
+// read can still be read. The first Error in the chain of errors wrapped by err (through their
+// Unwrap methods) is asked; the errors that are not Errors, such as those of an io.Reader, are
+// not resumable.
+func Resumable(err error) bool {
+	var e Error
+	return errors.As(err, &e) && e.Resumable()
+}
+
 type errShort struct{}
 
 func (e errShort) Error() string   { return "msgp: too few bytes left to read object" }
 func (e errShort) Resumable() bool { return false }
 
+type errMaxDepth struct{}
+
+func (e errMaxDepth) Error() string   { return "msgp: maximum nesting depth of maps and arrays exceeded" }
+func (e errMaxDepth) Resumable() bool { return false }
+
+type errTrailing struct{}
+
+func (e errTrailing) Error() string   { return "msgp: bytes left over after the object" }
+func (e errTrailing) Resumable() bool { return true }
+
+type errMessageTooLarge struct{}
+
+func (e errMessageTooLarge) Error() string   { return "msgp: message exceeds the size limit" }
+func (e errMessageTooLarge) Resumable() bool { return false }
+
+type errPathNotFound struct{}
+
+func (e errPathNotFound) Error() string   { return "msgp: no value at the path" }
+func (e errPathNotFound) Resumable() bool { return true }
+
 type errFatal struct{}
 
 func (f errFatal) Error() string   { return "msgp: fatal decoding error (unreachable code)" }
@@ -73,6 +143,41 @@ func (u UintOverflow) Error() string {
 // Resumable is always true for overflows.
 func (u UintOverflow) Resumable() bool { return true }
 
+// A UnionError is returned when encoding a union (a struct given to the union directive of the code
+// generator) with more than one field set, and when decoding one from a map with more than one
+// entry or with a key of none of the union's fields.
+type UnionError struct {
+	Type   string // the name of the union type
+	Fields int    // the number of fields set, or of entries in the map
+	Key    string // the unknown key, if the error is about one
+}
+
+// Error implements the error interface.
+func (e UnionError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("msgp: union %s has no field %q", e.Type, e.Key)
+	}
+	return fmt.Sprintf("msgp: union %s with %d fields set; at most one may be", e.Type, e.Fields)
+}
+
+// Resumable is always true for UnionError errors.
+func (e UnionError) Resumable() bool { return true }
+
+// An ErrMissingField error is returned when decoding a struct whose encoding lacks a field
+// tagged as required with `msgp:",required"`.
+type ErrMissingField struct {
+	Type  string // the name of the struct type
+	Field string // the name of the field in the encoding
+}
+
+// Error implements the error interface.
+func (e ErrMissingField) Error() string {
+	return fmt.Sprintf("msgp: required field %q of %s is missing", e.Field, e.Type)
+}
+
+// Resumable is always true for ErrMissingField errors.
+func (e ErrMissingField) Resumable() bool { return true }
+
 // A TypeError is returned when a particular
 // decoding method is unsuitable for decoding
 // a particular MessagePack value.
@@ -122,3 +227,59 @@ func (e *ErrUnsupportedType) Error() string { return fmt.Sprintf("msgp: type %q
 
 // Resumable returns true for ErrUnsupportedType.
 func (e *ErrUnsupportedType) Resumable() bool { return true }
+
+// A PathError is an error encountered decoding the value at Path within an object. The code
+// generated with the -wraperrors flag returns the errors of decoding fields, elements, and map
+// values wrapped in PathErrors, so that the error points to the part of a message that is bad.
+type PathError struct {
+	// Path holds the names of the struct fields (strings), the indexes of the slice and array
+	// elements (ints), and the keys of the map values (strings) leading from the decoded object
+	// to the value that could not be decoded.
+	Path []interface{}
+
+	// Err is the error decoding the value.
+	Err error
+}
+
+// WrapError returns err with path prepended to its path: a PathError with the given path is
+// returned if err is not a PathError, and err is returned if it is nil. The elements of path
+// are as in PathError.Path.
+func WrapError(err error, path ...interface{}) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *PathError:
+		e.Path = append(path[:len(path):len(path)], e.Path...)
+		return e
+	}
+	return &PathError{Path: path, Err: err}
+}
+
+// Error implements the error interface, giving the path with its elements separated by slashes.
+func (e *PathError) Error() string {
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s (at %s)", e.Err, strings.Join(path, "/"))
+}
+
+// Resumable returns the Resumable value of the wrapped error if it is an Error and otherwise false.
+func (e *PathError) Resumable() bool {
+	if me, ok := e.Err.(Error); ok {
+		return me.Resumable()
+	}
+	return false
+}
+
+// Unwrap returns the wrapped error.
+func (e *PathError) Unwrap() error { return e.Err }
+
+// Cause returns the error wrapped by err if err is a PathError and otherwise err, so that errors
+// such as ErrShortBytes can be compared with the errors of code generated with -wraperrors.
+func Cause(err error) error {
+	if e, ok := err.(*PathError); ok {
+		return e.Err
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package msgp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestWrapError(t *testing.T) {
+	if WrapError(nil, "A") != nil {
+		t.Error("wrapped a nil error")
+	}
+	err := WrapError(WrapError(ErrShortBytes, "B", 2), "A")
+	pe, ok := err.(*PathError)
+	if !ok {
+		t.Fatalf("got a %T; want a *PathError", err)
+	}
+	if want := []interface{}{"A", "B", 2}; !reflect.DeepEqual(pe.Path, want) {
+		t.Errorf("got the path %v; want %v", pe.Path, want)
+	}
+	if Cause(err) != ErrShortBytes || pe.Unwrap() != ErrShortBytes {
+		t.Errorf("got the cause %v; want ErrShortBytes", Cause(err))
+	}
+	if got, want := err.Error(), "msgp: too few bytes left to read object (at A/B/2)"; got != want {
+		t.Errorf("got the message %q; want %q", got, want)
+	}
+	if pe.Resumable() || !WrapError(ArrayError{}, "A").(Error).Resumable() {
+		t.Error("the wrapped errors are not resumable like the errors they wrap")
+	}
+	if Cause(ErrShortBytes) != ErrShortBytes {
+		t.Error("the cause of an unwrapped error is not the error")
+	}
+}
+
+func TestErrorsIsAs(t *testing.T) {
+	_, _, err := ReadInt8Bytes(AppendInt(nil, 300))
+	wrapped := fmt.Errorf("reading: %w", WrapError(err, "A", 1))
+	var overflow IntOverflow
+	if !errors.As(wrapped, &overflow) || overflow.Value != 300 {
+		t.Errorf("errors.As found %+v in %v", overflow, wrapped)
+	}
+	var pe *PathError
+	if !errors.As(wrapped, &pe) || len(pe.Path) != 2 {
+		t.Errorf("errors.As did not find the PathError in %v", wrapped)
+	}
+	if !Resumable(wrapped) {
+		t.Error("the wrapped overflow is not resumable")
+	}
+
+	_, _, err = ReadInt8Bytes(nil)
+	wrapped = fmt.Errorf("reading: %w", WrapError(err, "A"))
+	if !errors.Is(wrapped, ErrShortBytes) {
+		t.Errorf("errors.Is did not find ErrShortBytes in %v", wrapped)
+	}
+	if Resumable(wrapped) || Resumable(errors.New("other")) || Resumable(nil) {
+		t.Error("an error that is not resumable is")
+	}
+
+	var ut *ErrUnsupportedType
+	err = NewWriter(ioutil.Discard).WriteIntf(map[int]int{1: 2})
+	if !errors.As(err, &ut) || ut.T != reflect.TypeOf(map[int]int{}) {
+		t.Errorf("writing a map with int keys returned %v", err)
+	}
+}
@@ -13,8 +13,13 @@ const (
 	Complex128Extension = 4
 
 	// TimeExtension represents an extension for timestamps. This is not the timestamp format
-	// defined in the MessagePack specification.
+	// defined in the MessagePack specification (see TimestampExtension).
 	TimeExtension = 5
+
+	// BigIntExtension represents an extension for integers (held by a Number) that do not fit
+	// in an int64 or a uint64. The data is a sign byte (0 for positive numbers and 1 for negative
+	// numbers) followed by the big-endian bytes of the absolute value of the integer.
+	BigIntExtension = 6
 )
 
 // extensionReg contains registered extensions.
@@ -23,17 +28,19 @@ var extensionReg = make(map[int8]func() Extension)
 // RegisterExtension registers extensions so that they can be initialized and returned
 // by methods that decode `interface{}` values. This should only be called during
 // initialization. Func f should return a newly-initialized zero value of the extension.
-// Keep in mind that extensions 3, 4, and 5 are reserved for complex64, complex128, and
-// time.Time, respectively, and that MessagePack reserves extension types from -127 to -1.
+// Keep in mind that extensions 3, 4, 5, 6, 7, and 8 are reserved for complex64, complex128,
+// time.Time, big integers, half-precision floats, and decimals, respectively, and that MessagePack
+// reserves extension types from -127 to -1.
 //
 // For example, if you wanted to register a user-defined struct:
 //
 //  msgp.RegisterExtension(10, func() msgp.Extension { &MyExtension{} })
 //
 // RegisterExtension will panic if you call it multiple times with the same 'typ' argument
-// or if you use a reserved type (3, 4, or 5).
+// or if you use a reserved type (3 through 8).
 func RegisterExtension(typ int8, f func() Extension) {
-	if typ == Complex64Extension || typ == Complex128Extension || typ == TimeExtension {
+	switch typ {
+	case Complex64Extension, Complex128Extension, TimeExtension, BigIntExtension, Float16Extension, DecimalExtension:
 		panic(fmt.Sprint("msgp: forbidden extension type:", typ))
 	}
 	if _, ok := extensionReg[typ]; ok {
@@ -77,6 +84,33 @@ type Extension interface {
 	UnmarshalBinary([]byte) error
 }
 
+// ExtensionMarshaler is an optional interface for an Extension that can append its data to a
+// slice. AppendExtension and Writer.WriteExtension use AppendExtensionTo in place of
+// MarshalBinaryTo when an Extension implements it, avoiding the copy of the data from an
+// intermediate buffer that MarshalBinaryTo often requires.
+type ExtensionMarshaler interface {
+	Extension
+
+	// AppendExtensionTo appends the data (exactly Len() bytes of it) to the slice
+	// and returns the extended slice.
+	AppendExtensionTo([]byte) ([]byte, error)
+}
+
+// ExtensionLengthError is returned when an ExtensionMarshaler appends a different number
+// of bytes than its Len method reports.
+type ExtensionLengthError struct {
+	Type     int8
+	Len, Got int
+}
+
+// Error implements the error interface.
+func (e ExtensionLengthError) Error() string {
+	return fmt.Sprintf("msgp: extension type %d appended %d bytes of data; its length is %d", e.Type, e.Got, e.Len)
+}
+
+// Resumable returns false for ExtensionLengthError errors.
+func (e ExtensionLengthError) Resumable() bool { return false }
+
 // RawExtension implements the Extension interface.
 type RawExtension struct {
 	Data []byte
@@ -95,6 +129,11 @@ func (r *RawExtension) MarshalBinaryTo(d []byte) error {
 	return nil
 }
 
+// AppendExtensionTo implements ExtensionMarshaler.AppendExtensionTo and appends r.Data to b.
+func (r *RawExtension) AppendExtensionTo(b []byte) ([]byte, error) {
+	return append(b, r.Data...), nil
+}
+
 // UnmarshalBinary implements Extension.UnmarshalBinary and sets r.Data to the contents
 // of the provided slice.
 func (r *RawExtension) UnmarshalBinary(b []byte) error {
@@ -107,88 +146,29 @@ func (r *RawExtension) UnmarshalBinary(b []byte) error {
 	return nil
 }
 
-// WriteExtension writes an extension type to the writer.
+// WriteExtension writes an extension type to the writer. If e implements ExtensionMarshaler,
+// its data is appended directly to the Writer's buffer.
 func (mw *Writer) WriteExtension(e Extension) error {
 	l := e.Len()
-	switch l {
-	case 0:
-		i, err := mw.require(3)
-		if err != nil {
-			return err
-		}
-		mw.buf[i] = mext8
-		mw.buf[i+1] = 0
-		mw.buf[i+2] = byte(e.ExtensionType())
-	case 1:
-		i, err := mw.require(2)
-		if err != nil {
-			return err
-		}
-		mw.buf[i] = mfixext1
-		mw.buf[i+1] = byte(e.ExtensionType())
-	case 2:
-		i, err := mw.require(2)
-		if err != nil {
-			return err
-		}
-		mw.buf[i] = mfixext2
-		mw.buf[i+1] = byte(e.ExtensionType())
-	case 4:
-		i, err := mw.require(2)
-		if err != nil {
-			return err
-		}
-		mw.buf[i] = mfixext4
-		mw.buf[i+1] = byte(e.ExtensionType())
-	case 8:
-		i, err := mw.require(2)
-		if err != nil {
-			return err
-		}
-		mw.buf[i] = mfixext8
-		mw.buf[i+1] = byte(e.ExtensionType())
-	case 16:
-		i, err := mw.require(2)
-		if err != nil {
-			return err
-		}
-		mw.buf[i] = mfixext16
-		mw.buf[i+1] = byte(e.ExtensionType())
-	default:
-		switch {
-		case l < math.MaxUint8:
-			i, err := mw.require(3)
-			if err != nil {
-				return err
-			}
-			mw.buf[i] = mext8
-			mw.buf[i+1] = byte(uint8(l))
-			mw.buf[i+2] = byte(e.ExtensionType())
-		case l < math.MaxUint16:
-			i, err := mw.require(4)
-			if err != nil {
-				return err
-			}
-			mw.buf[i] = mext16
-			big.PutUint16(mw.buf[i+1:], uint16(l))
-			mw.buf[i+3] = byte(e.ExtensionType())
-		default:
-			i, err := mw.require(6)
-			if err != nil {
-				return err
-			}
-			mw.buf[i] = mext32
-			big.PutUint32(mw.buf[i+1:], uint32(l))
-			mw.buf[i+5] = byte(e.ExtensionType())
-		}
+	var hdr [6]byte
+	if err := mw.Append(appendExtensionHeader(hdr[:0], e.ExtensionType(), l)...); err != nil {
+		return err
 	}
+	if l == 0 {
+		return nil
+	}
+	em, isMarshaler := e.(ExtensionMarshaler)
 	// We can only write directly to the buffer if we're sure that it
-	// fits the object.
-	if l <= len(mw.buf) {
+	// fits the object (or grows to fit it).
+	if l <= len(mw.buf) || mw.manual || l <= mw.growMax {
 		i, err := mw.require(l)
 		if err != nil {
 			return err
 		}
+		if isMarshaler {
+			_, err = appendExtensionData(mw.buf[i:i], em, l)
+			return err
+		}
 		return e.MarshalBinaryTo(mw.buf[i:])
 	}
 	// Here we create a new buffer just large enough for the body
@@ -197,8 +177,13 @@ func (mw *Writer) WriteExtension(e Extension) error {
 	if err != nil {
 		return err
 	}
-	buf := make([]byte, l)
-	err = e.MarshalBinaryTo(buf)
+	var buf []byte
+	if isMarshaler {
+		buf, err = appendExtensionData(mw.buf[:0], em, l)
+	} else {
+		buf = make([]byte, l)
+		err = e.MarshalBinaryTo(buf)
+	}
 	if err != nil {
 		return err
 	}
@@ -378,64 +363,68 @@ func (m *Reader) ReadExtension(e Extension) error {
 
 }
 
-// AppendExtension appends a MessagePack extension to slice b.
+// AppendExtension appends a MessagePack extension to slice b. If e implements ExtensionMarshaler,
+// its data is appended directly to b.
 func AppendExtension(b []byte, e Extension) ([]byte, error) {
 	l := e.Len()
-	var n int
+	b = appendExtensionHeader(b, e.ExtensionType(), l)
+	if l == 0 {
+		return b, nil
+	}
+	if em, ok := e.(ExtensionMarshaler); ok {
+		return appendExtensionData(b, em, l)
+	}
+	b, n := ensure(b, l)
+	return b, e.MarshalBinaryTo(b[n:])
+}
+
+// appendExtensionHeader appends to b the header of an extension of type typ with l bytes of data.
+func appendExtensionHeader(b []byte, typ int8, l int) []byte {
 	switch l {
 	case 0:
-		b, n = ensure(b, 3)
-		b[n] = mext8
-		b[n+1] = 0
-		b[n+2] = byte(e.ExtensionType())
-		return b[:n+3], nil
+		return append(b, mext8, 0, byte(typ))
 	case 1:
-		b, n = ensure(b, 3)
-		b[n] = mfixext1
-		b[n+1] = byte(e.ExtensionType())
-		n += 2
+		return append(b, mfixext1, byte(typ))
 	case 2:
-		b, n = ensure(b, 4)
-		b[n] = mfixext2
-		b[n+1] = byte(e.ExtensionType())
-		n += 2
+		return append(b, mfixext2, byte(typ))
 	case 4:
-		b, n = ensure(b, 6)
-		b[n] = mfixext4
-		b[n+1] = byte(e.ExtensionType())
-		n += 2
+		return append(b, mfixext4, byte(typ))
 	case 8:
-		b, n = ensure(b, 10)
-		b[n] = mfixext8
-		b[n+1] = byte(e.ExtensionType())
-		n += 2
+		return append(b, mfixext8, byte(typ))
 	case 16:
-		b, n = ensure(b, 18)
-		b[n] = mfixext16
-		b[n+1] = byte(e.ExtensionType())
-		n += 2
+		return append(b, mfixext16, byte(typ))
 	}
+	var n int
 	switch {
 	case l < math.MaxUint8:
-		b, n = ensure(b, l+3)
+		b, n = ensure(b, 3)
 		b[n] = mext8
 		b[n+1] = byte(uint8(l))
-		b[n+2] = byte(e.ExtensionType())
-		n += 3
+		b[n+2] = byte(typ)
 	case l < math.MaxUint16:
-		b, n = ensure(b, l+4)
+		b, n = ensure(b, 4)
 		b[n] = mext16
 		big.PutUint16(b[n+1:], uint16(l))
-		b[n+3] = byte(e.ExtensionType())
-		n += 4
+		b[n+3] = byte(typ)
 	default:
-		b, n = ensure(b, l+6)
+		b, n = ensure(b, 6)
 		b[n] = mext32
 		big.PutUint32(b[n+1:], uint32(l))
-		b[n+5] = byte(e.ExtensionType())
-		n += 6
+		b[n+5] = byte(typ)
 	}
-	return b, e.MarshalBinaryTo(b[n:])
+	return b
+}
+
+// appendExtensionData appends the data of e, which must be l bytes long, to b.
+func appendExtensionData(b []byte, e ExtensionMarshaler, l int) ([]byte, error) {
+	o, err := e.AppendExtensionTo(b)
+	if err != nil {
+		return b, err
+	}
+	if len(o)-len(b) != l {
+		return b, ExtensionLengthError{Type: e.ExtensionType(), Len: l, Got: len(o) - len(b)}
+	}
+	return o, nil
 }
 
 // ReadExtensionBytes reads an extension from b into e and returns any remaining bytes.
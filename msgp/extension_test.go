@@ -2,6 +2,7 @@ package msgp
 
 import (
 	"bytes"
+	"io/ioutil"
 	"math/rand"
 	"testing"
 	"time"
@@ -47,3 +48,65 @@ func TestReadWriteExtensionBytes(t *testing.T) {
 		}
 	}
 }
+
+// plainExtension hides the AppendExtensionTo method of a RawExtension.
+type plainExtension struct {
+	Extension
+}
+
+// badLenExtension appends one more byte than its Len method reports.
+type badLenExtension struct {
+	RawExtension
+}
+
+func (e *badLenExtension) AppendExtensionTo(b []byte) ([]byte, error) {
+	return append(append(b, e.Data...), 0), nil
+}
+
+func TestExtensionMarshaler(t *testing.T) {
+	for _, size := range extSizes {
+		e := RawExtension{Type: 12, Data: RandBytes(size)}
+
+		// The encoding must not depend on which of the two methods is used to get the data.
+		appended, err := AppendExtension(nil, &e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		marshaled, err := AppendExtension(nil, plainExtension{&e})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(appended, marshaled) {
+			t.Errorf("size %d: AppendExtensionTo and MarshalBinaryTo give different encodings", size)
+		}
+
+		for _, ext := range []Extension{&e, plainExtension{&e}} {
+			var buf bytes.Buffer
+			wr := NewWriter(&buf)
+			if err = wr.WriteExtension(ext); err != nil {
+				t.Fatal(err)
+			}
+			wr.Flush()
+			if !bytes.Equal(buf.Bytes(), appended) {
+				t.Errorf("size %d: WriteExtension and AppendExtension give different encodings", size)
+			}
+		}
+
+		out := RawExtension{Type: e.Type}
+		rest, err := ReadExtensionBytes(appended, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rest) != 0 || out.Type != e.Type || !bytes.Equal(out.Data, e.Data) {
+			t.Errorf("size %d: extension not decoded as encoded (%d bytes left over)", size, len(rest))
+		}
+	}
+
+	bad := &badLenExtension{RawExtension{Type: 12, Data: []byte{1, 2, 3}}}
+	if _, err := AppendExtension(nil, bad); err == nil {
+		t.Error("expected an error appending an extension with the wrong length")
+	}
+	if err := NewWriter(ioutil.Discard).WriteExtension(bad); err == nil {
+		t.Error("expected an error writing an extension with the wrong length")
+	}
+}
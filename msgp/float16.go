@@ -0,0 +1,179 @@
+package msgp
+
+import "math"
+
+// Float16Extension represents an extension for half-precision (IEEE 754 binary16) floats, which
+// some producers write to save space. The data is the two big-endian bytes of the float.
+const Float16Extension = 7
+
+// Float16Size is the encoded size of a half-precision float.
+const Float16Size = 4
+
+// A Float16 is a float32 written as a half-precision float extension. It can be used as the type
+// of a field in the types processed by the code generator. Writing a Float16 rounds its value to
+// the nearest half-precision float; values too large for half precision become infinities.
+type Float16 float32
+
+// ExtensionType implements Extension.
+func (f Float16) ExtensionType() int8 { return Float16Extension }
+
+// Len implements Extension.
+func (f Float16) Len() int { return 2 }
+
+// MarshalBinaryTo implements Extension.
+func (f Float16) MarshalBinaryTo(b []byte) error {
+	big.PutUint16(b, float16Bits(float32(f)))
+	return nil
+}
+
+// UnmarshalBinary implements Extension.
+func (f *Float16) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return ErrShortBytes
+	}
+	*f = Float16(float16Value(big.Uint16(b)))
+	return nil
+}
+
+// EncodeMsg implements Encoder.
+func (f Float16) EncodeMsg(w *Writer) error { return w.WriteFloat16(float32(f)) }
+
+// DecodeMsg implements Decoder.
+func (f *Float16) DecodeMsg(r *Reader) error {
+	v, err := r.ReadFloat16()
+	*f = Float16(v)
+	return err
+}
+
+// MarshalMsg implements Marshaler.
+func (f Float16) MarshalMsg(b []byte) ([]byte, error) { return AppendFloat16(b, float32(f)), nil }
+
+// UnmarshalMsg implements Unmarshaler.
+func (f *Float16) UnmarshalMsg(b []byte) ([]byte, error) {
+	v, o, err := ReadFloat16Bytes(b)
+	*f = Float16(v)
+	return o, err
+}
+
+// Msgsize implements Sizer.
+func (f Float16) Msgsize() int { return Float16Size }
+
+// MsgsizeExact implements ExactSizer.
+func (f Float16) MsgsizeExact() int { return Float16Size }
+
+// Clone returns f, as generated Clone methods call it.
+func (f Float16) Clone() Float16 { return f }
+
+// Equal says if f and o are equal when written, comparing them with the == operator.
+func (f Float16) Equal(o Float16) bool {
+	return float16Value(float16Bits(float32(f))) == float16Value(float16Bits(float32(o)))
+}
+
+// WriteFloat16 writes f as a half-precision float extension, rounding it to the nearest
+// half-precision float.
+func (mw *Writer) WriteFloat16(f float32) error {
+	i, err := mw.require(Float16Size)
+	if err != nil {
+		return err
+	}
+	mw.buf[i] = mfixext2
+	mw.buf[i+1] = Float16Extension
+	big.PutUint16(mw.buf[i+2:], float16Bits(f))
+	return nil
+}
+
+// AppendFloat16 appends f to b as a half-precision float extension, rounding it to the nearest
+// half-precision float.
+func AppendFloat16(b []byte, f float32) []byte {
+	o, n := ensure(b, Float16Size)
+	o[n] = mfixext2
+	o[n+1] = Float16Extension
+	big.PutUint16(o[n+2:], float16Bits(f))
+	return o
+}
+
+// ReadFloat16 reads a half-precision float extension from the reader.
+func (m *Reader) ReadFloat16() (float32, error) {
+	p, err := m.R.Peek(Float16Size)
+	if err != nil {
+		return 0, err
+	}
+	if p[0] != mfixext2 {
+		return 0, badPrefix(Float16Type, p[0])
+	}
+	if int8(p[1]) != Float16Extension {
+		return 0, errExt(int8(p[1]), Float16Extension)
+	}
+	f := float16Value(big.Uint16(p[2:]))
+	_, err = m.R.Skip(Float16Size)
+	return f, err
+}
+
+// ReadFloat16Bytes reads a half-precision float extension from b and returns any remaining bytes.
+// Possible errors include ErrShortBytes (not enough bytes in b), TypeError{} (object not a float16),
+// and ExtensionTypeError{} (object an extension of the correct size, but not a float16).
+func ReadFloat16Bytes(b []byte) (float32, []byte, error) {
+	if len(b) < Float16Size {
+		return 0, b, ErrShortBytes
+	}
+	if b[0] != mfixext2 {
+		return 0, b, badPrefix(Float16Type, b[0])
+	}
+	if int8(b[1]) != Float16Extension {
+		return 0, b, errExt(int8(b[1]), Float16Extension)
+	}
+	return float16Value(big.Uint16(b[2:])), b[Float16Size:], nil
+}
+
+// float16Bits returns the bits of the half-precision float nearest to f, rounding ties to even.
+func float16Bits(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23&0xff) - 127
+	mant := b & 0x7fffff
+	switch {
+	case exp == 128:
+		// infinity or NaN
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp > 15:
+		return sign | 0x7c00
+	case exp >= -14:
+		h := uint32(exp+15)<<10 | mant>>13
+		// A carry out of the mantissa correctly increments the exponent.
+		if rem := mant & 0x1fff; rem > 0x1000 || rem == 0x1000 && h&1 == 1 {
+			h++
+		}
+		return sign | uint16(h)
+	case exp >= -25:
+		// The result is subnormal: the significand (with its implicit bit) in units of 2^-24.
+		mant |= 0x800000
+		shift := uint(-(exp + 1))
+		h := mant >> shift
+		half := uint32(1) << (shift - 1)
+		if rem := mant & (1<<shift - 1); rem > half || rem == half && h&1 == 1 {
+			h++
+		}
+		return sign | uint16(h)
+	default:
+		return sign
+	}
+}
+
+// float16Value returns the value of the half-precision float with the bits h.
+func float16Value(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch exp {
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case 0:
+		// zero or subnormal
+		return math.Float32frombits(sign | math.Float32bits(float32(mant)/(1<<24)))
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
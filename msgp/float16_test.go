@@ -0,0 +1,131 @@
+package msgp
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestFloat16Bits(t *testing.T) {
+	cases := []struct {
+		f    float32
+		bits uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{0.1, 0x2e66},
+		{65504, 0x7bff},                         // the largest half-precision float
+		{65519, 0x7bff},                         // rounds down to the largest
+		{65520, 0x7c00},                         // rounds up to infinity
+		{1e6, 0x7c00},                           // overflows
+		{float32(math.Inf(-1)), 0xfc00},         // infinity
+		{float32(math.Pow(2, -14)), 0x0400},     // the smallest normal
+		{float32(math.Pow(2, -24)), 0x0001},     // the smallest subnormal
+		{float32(math.Pow(2, -25)), 0x0000},     // a tie rounding to even (zero)
+		{float32(math.Pow(2, -25) * 3), 0x0002}, // a tie rounding to even
+		{1 + 1.0/2048, 0x3c00},                  // a tie rounding down to even
+		{1 + 3.0/2048, 0x3c02},                  // a tie rounding up to even
+		{1e-10, 0x0000},                         // underflows
+	}
+	for _, c := range cases {
+		if got := float16Bits(c.f); got != c.bits {
+			t.Errorf("float16Bits(%g) = %#04x; want %#04x", c.f, got, c.bits)
+		}
+	}
+
+	if v := float16Value(float16Bits(float32(math.NaN()))); v == v {
+		t.Errorf("NaN became %g", v)
+	}
+
+	// Every half-precision float other than a NaN survives the conversions.
+	for h := 0; h < 1<<16; h++ {
+		v := float16Value(uint16(h))
+		if v != v {
+			continue
+		}
+		if got := float16Bits(v); got != uint16(h) {
+			t.Fatalf("%#04x became %g and then %#04x", h, v, got)
+		}
+	}
+}
+
+func TestFloat16ReadWrite(t *testing.T) {
+	b := AppendFloat16(nil, 1.5)
+	if !bytes.Equal(b, []byte{mfixext2, Float16Extension, 0x3e, 0x00}) {
+		t.Fatalf("AppendFloat16 wrote %x", b)
+	}
+
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteFloat16(1.5); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("WriteFloat16 wrote %x; want %x", buf.Bytes(), b)
+	}
+
+	if typ := NextType(b); typ != Float16Type {
+		t.Errorf("NextType = %s", typ)
+	}
+	f, left, err := ReadFloat16Bytes(b)
+	if err != nil || f != 1.5 || len(left) != 0 {
+		t.Errorf("ReadFloat16Bytes = %g, %d bytes left, %v", f, len(left), err)
+	}
+	f, err = NewReader(bytes.NewReader(b)).ReadFloat16()
+	if err != nil || f != 1.5 {
+		t.Errorf("ReadFloat16 = %g, %v", f, err)
+	}
+
+	if _, _, err = ReadFloat16Bytes(AppendFloat32(nil, 1.5)); err == nil {
+		t.Error("no error reading a float32 as a float16")
+	}
+	if _, _, err = ReadFloat16Bytes(extensionOf(t, 2, 9)); err == nil {
+		t.Error("no error reading another extension as a float16")
+	}
+
+	// Interfaces read a Float16, which is written back the same way.
+	v, _, err := ReadIntfBytes(b)
+	if err != nil || v != Float16(1.5) {
+		t.Fatalf("ReadIntfBytes = %#v, %v", v, err)
+	}
+	again, err := AppendIntf(nil, v)
+	if err != nil || !bytes.Equal(again, b) {
+		t.Errorf("AppendIntf wrote %x, %v; want %x", again, err, b)
+	}
+}
+
+// extensionOf returns the encoding of an extension of the given type with n zero bytes.
+func extensionOf(t *testing.T, n int, typ int8) []byte {
+	b, err := AppendExtension(nil, &RawExtension{Type: typ, Data: make([]byte, n)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFloat16Methods(t *testing.T) {
+	in := Float16(-0.25)
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Float16
+	if _, err = out.UnmarshalMsg(b); err != nil || out != in {
+		t.Errorf("UnmarshalMsg: got %g, %v", out, err)
+	}
+	if len(b) != in.Msgsize() {
+		t.Errorf("Msgsize() = %d for %d bytes", in.Msgsize(), len(b))
+	}
+	if !Float16(0.1).Equal(Float16(0.09997)) {
+		t.Error("floats rounding to the same half-precision float are not equal")
+	}
+
+	var js bytes.Buffer
+	if _, err = UnmarshalAsJSON(&js, b); err != nil || js.String() != "-0.25" {
+		t.Errorf("UnmarshalAsJSON wrote %q, %v", js.String(), err)
+	}
+}
@@ -0,0 +1,210 @@
+package msgp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A FramePrefix is the format of the length that prefixes each frame written by WriteFramePrefix.
+// Framing lets a stream of messages be split into messages without decoding them.
+type FramePrefix uint8
+
+const (
+	// UvarintPrefix prefixes each frame with its length as an unsigned varint (as encoded
+	// by the encoding/binary package).
+	UvarintPrefix FramePrefix = iota
+
+	// Fixed32Prefix prefixes each frame with its length as a big-endian 32-bit unsigned integer.
+	Fixed32Prefix
+)
+
+// maxFrameSize is the limit on the length of the frames written.
+const maxFrameSize = math.MaxInt32
+
+// MaxFrameSize is the limit on the length of the frames read by ReadFramePrefix and FrameScanners.
+// Reading a frame whose length prefix exceeds the limit fails with a FrameSizeError, before the
+// frame is allocated, so that a corrupt or malicious prefix cannot exhaust the memory. Readers and
+// FrameScanners can be given their own limits with SetMaxFrameSize and SetMaxSize.
+var MaxFrameSize = 64 << 20
+
+// A FrameSizeError is returned when reading a frame whose length prefix is too large.
+type FrameSizeError struct {
+	Size, Max uint64
+}
+
+// Error implements the error interface.
+func (e FrameSizeError) Error() string {
+	return fmt.Sprintf("msgp: frame of %d bytes is larger than the limit of %d bytes", e.Size, e.Max)
+}
+
+// Resumable is always false for FrameSizeError errors.
+func (e FrameSizeError) Resumable() bool { return false }
+
+// WriteFrame writes m to w as a frame with a UvarintPrefix.
+func WriteFrame(w *Writer, m Marshaler) error { return WriteFramePrefix(w, m, UvarintPrefix) }
+
+// ReadFrame reads a frame with a UvarintPrefix from r and unmarshals it into u.
+func ReadFrame(r *Reader, u Unmarshaler) error { return ReadFramePrefix(r, u, UvarintPrefix) }
+
+// WriteFramePrefix writes m to w prefixed by the length of its encoding in the format p.
+func WriteFramePrefix(w *Writer, m Marshaler, p FramePrefix) error {
+	var b []byte
+	if s, ok := m.(Sizer); ok {
+		b = make([]byte, 0, s.Msgsize())
+	}
+	b, err := m.MarshalMsg(b)
+	if err != nil {
+		return err
+	}
+	if uint64(len(b)) > maxFrameSize {
+		return FrameSizeError{Size: uint64(len(b)), Max: maxFrameSize}
+	}
+	var pre [binary.MaxVarintLen64]byte
+	if err = w.Append(appendFramePrefix(pre[:0], len(b), p)...); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadFramePrefix reads from r a frame prefixed by its length in the format p and unmarshals it
+// into u. Any bytes in the frame after the object unmarshaled by u are discarded. A frame longer
+// than the limit of r (see SetMaxFrameSize) is not read; a FrameSizeError is returned.
+func ReadFramePrefix(r *Reader, u Unmarshaler, p FramePrefix) error {
+	size, err := readFramePrefix(r.R, p)
+	if err != nil {
+		return err
+	}
+	if max := uint64(r.maxFrameSize()); size > max {
+		return FrameSizeError{Size: size, Max: max}
+	}
+	n := int(size)
+	var b []byte
+	if n <= r.R.BufferSize() {
+		// The frame is unmarshaled straight from the read buffer.
+		b, err = r.R.Next(n)
+	} else {
+		if cap(r.scratch) < n {
+			r.scratch = make([]byte, n)
+		}
+		b = r.scratch[:n]
+		_, err = r.R.ReadFull(b)
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	_, err = u.UnmarshalMsg(b)
+	return err
+}
+
+func appendFramePrefix(b []byte, size int, p FramePrefix) []byte {
+	if p == Fixed32Prefix {
+		var pre [4]byte
+		binary.BigEndian.PutUint32(pre[:], uint32(size))
+		return append(b, pre[:]...)
+	}
+	var pre [binary.MaxVarintLen64]byte
+	return append(b, pre[:binary.PutUvarint(pre[:], uint64(size))]...)
+}
+
+// readFramePrefix reads a frame length in the format p. It returns io.EOF only if there
+// are no bytes left to read.
+func readFramePrefix(r io.ByteReader, p FramePrefix) (uint64, error) {
+	if p == Fixed32Prefix {
+		var pre [4]byte
+		for i := range pre {
+			c, err := r.ReadByte()
+			if err != nil {
+				if err == io.EOF && i > 0 {
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+			pre[i] = c
+		}
+		return uint64(binary.BigEndian.Uint32(pre[:])), nil
+	}
+	return binary.ReadUvarint(r)
+}
+
+// A FrameScanner splits the frames in a stream into the encoded messages they contain without
+// decoding the messages. Its use is like that of a bufio.Scanner:
+//
+//  s := msgp.NewFrameScanner(conn, msgp.UvarintPrefix)
+//  for s.Scan() {
+//      if _, err := v.UnmarshalMsg(s.Frame()); err != nil {
+//          // ...
+//      }
+//  }
+//  if err := s.Err(); err != nil {
+//      // ...
+//  }
+type FrameScanner struct {
+	r      *bufio.Reader
+	prefix FramePrefix
+	max    uint64
+	frame  []byte
+	err    error
+}
+
+// NewFrameScanner returns a FrameScanner reading frames prefixed in the format p from r.
+func NewFrameScanner(r io.Reader, p FramePrefix) *FrameScanner {
+	return &FrameScanner{r: bufio.NewReader(r), prefix: p, max: uint64(MaxFrameSize)}
+}
+
+// SetMaxSize sets the limit on the length of the frames read by s. Scanning stops with a
+// FrameSizeError at a frame whose length exceeds the limit. The default limit is MaxFrameSize,
+// which a limit of zero or less selects.
+func (s *FrameScanner) SetMaxSize(max int) {
+	if max <= 0 {
+		max = MaxFrameSize
+	}
+	s.max = uint64(max)
+}
+
+// Scan advances s to the next frame, which is then available through the Frame method.
+// Scan returns false when there are no more frames or when an error occurs.
+func (s *FrameScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	size, err := readFramePrefix(s.r, s.prefix)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	if size > s.max {
+		s.err = FrameSizeError{Size: size, Max: s.max}
+		return false
+	}
+	if uint64(cap(s.frame)) < size {
+		s.frame = make([]byte, size)
+	}
+	s.frame = s.frame[:size]
+	if _, err = io.ReadFull(s.r, s.frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		s.err = err
+		return false
+	}
+	return true
+}
+
+// Frame returns the contents of the frame read by the last call to Scan. The slice is valid only
+// until the next call to Scan.
+func (s *FrameScanner) Frame() []byte { return s.frame }
+
+// Err returns the error that stopped s, or nil if s reached the end of the stream between frames.
+func (s *FrameScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
@@ -0,0 +1,114 @@
+package msgp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	big := Raw(AppendString(nil, strings.Repeat("x", 10000))) // larger than the read buffer
+	values := []Raw{Raw(AppendInt(nil, 1)), big, Raw(AppendString(nil, "three"))}
+
+	for _, p := range []FramePrefix{UvarintPrefix, Fixed32Prefix} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		for _, v := range values {
+			if err := WriteFramePrefix(w, v, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w.Flush()
+		stream := buf.Bytes()
+
+		r := NewReaderSize(bytes.NewReader(stream), 64)
+		for i, v := range values {
+			var got Raw
+			if err := ReadFramePrefix(r, &got, p); err != nil {
+				t.Fatalf("prefix %d, frame %d: %v", p, i, err)
+			}
+			if !bytes.Equal(got, v) {
+				t.Errorf("prefix %d, frame %d: got %x; want %x", p, i, []byte(got), []byte(v))
+			}
+		}
+		if err := ReadFramePrefix(r, new(Raw), p); err != io.EOF {
+			t.Errorf("prefix %d: expected io.EOF after the last frame; got %v", p, err)
+		}
+
+		r = NewReader(bytes.NewReader(stream))
+		r.SetMaxFrameSize(100)
+		if err := ReadFramePrefix(r, new(Raw), p); err != nil {
+			t.Errorf("prefix %d: reading a frame within the limit: %v", p, err)
+		}
+		err := ReadFramePrefix(r, new(Raw), p)
+		if fe, ok := err.(FrameSizeError); !ok || fe.Max != 100 {
+			t.Errorf("prefix %d: expected a FrameSizeError with the limit 100; got %v", p, err)
+		}
+
+		s := NewFrameScanner(bytes.NewReader(stream), p)
+		var i int
+		for ; s.Scan(); i++ {
+			if !bytes.Equal(s.Frame(), values[i]) {
+				t.Errorf("prefix %d, scanned frame %d: got %x; want %x", p, i, s.Frame(), []byte(values[i]))
+			}
+		}
+		if s.Err() != nil || i != len(values) {
+			t.Errorf("prefix %d: scanned %d frames with error %v", p, i, s.Err())
+		}
+
+		// The stream ends in the middle of a frame.
+		s = NewFrameScanner(bytes.NewReader(stream[:len(stream)-1]), p)
+		for s.Scan() {
+		}
+		if s.Err() != io.ErrUnexpectedEOF {
+			t.Errorf("prefix %d: expected %v; got %v", p, io.ErrUnexpectedEOF, s.Err())
+		}
+
+		s = NewFrameScanner(bytes.NewReader(stream), p)
+		s.SetMaxSize(100)
+		for s.Scan() {
+		}
+		if _, ok := s.Err().(FrameSizeError); !ok {
+			t.Errorf("prefix %d: expected a FrameSizeError; got %v", p, s.Err())
+		}
+
+		// A limit of zero or less selects MaxFrameSize.
+		huge := appendFramePrefix(nil, MaxFrameSize+1, p)
+		for _, max := range []int{0, -1} {
+			s = NewFrameScanner(bytes.NewReader(huge), p)
+			s.SetMaxSize(max)
+			if s.Scan() {
+				t.Errorf("prefix %d: scanned a frame beyond MaxFrameSize with the limit %d", p, max)
+			}
+			if fe, ok := s.Err().(FrameSizeError); !ok || fe.Max != uint64(MaxFrameSize) {
+				t.Errorf("prefix %d: expected a FrameSizeError with the limit MaxFrameSize for %d; got %v", p, max, s.Err())
+			}
+		}
+	}
+
+	// A prefix beyond MaxFrameSize is rejected without allocating the frame.
+	huge := appendFramePrefix(nil, MaxFrameSize+1, Fixed32Prefix)
+	err := ReadFramePrefix(NewReader(bytes.NewReader(huge)), new(Raw), Fixed32Prefix)
+	if fe, ok := err.(FrameSizeError); !ok || fe.Max != uint64(MaxFrameSize) {
+		t.Errorf("expected a FrameSizeError with the limit MaxFrameSize; got %v", err)
+	}
+
+	// WriteFrame and ReadFrame use a uvarint prefix.
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := WriteFrame(w, values[2]); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if buf.Bytes()[0] != byte(len(values[2])) {
+		t.Errorf("expected a uvarint prefix of %d; got %d", len(values[2]), buf.Bytes()[0])
+	}
+	var got Raw
+	if err := ReadFrame(NewReader(&buf), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, values[2]) {
+		t.Errorf("got %x; want %x", []byte(got), []byte(values[2]))
+	}
+}
@@ -0,0 +1,96 @@
+package msgp
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// CopyFromJSON reads JSON values from src and writes them to dst as MessagePack until src
+// reaches io.EOF. It returns the number of bytes written.
+//
+// JSON objects become maps with their keys in the same order, arrays become arrays, strings
+// become strings, and null becomes nil. Numbers that are integers become ints (or uints if they
+// are too large for an int64); other numbers become float64 values.
+func CopyFromJSON(dst io.Writer, src io.Reader) (int64, error) {
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+	var n int64
+	var b []byte
+	for {
+		var err error
+		if b, err = appendJSONValue(b[:0], dec, 0); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return n, err
+		}
+		nn, err := dst.Write(b)
+		n += int64(nn)
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// appendJSONValue appends the next JSON value read by dec, which is nested at the given depth.
+// It returns io.EOF only if dec is at the end of its input before the value.
+func appendJSONValue(b []byte, dec *json.Decoder, depth int) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF && depth > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return b, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		if depth >= MaxDepth {
+			return b, ErrMaxDepthExceeded
+		}
+		// The elements are counted before the header is written.
+		var body []byte
+		var sz uint32
+		for ; dec.More(); sz++ {
+			if v == '{' {
+				if tok, err = dec.Token(); err != nil {
+					return b, err
+				}
+				body = AppendString(body, tok.(string))
+			}
+			if body, err = appendJSONValue(body, dec, depth+1); err != nil {
+				return b, err
+			}
+		}
+		if _, err = dec.Token(); err != nil { // the closing delimiter
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return b, err
+		}
+		if v == '{' {
+			b = AppendMapHeader(b, sz)
+		} else {
+			b = AppendArrayHeader(b, sz)
+		}
+		return append(b, body...), nil
+	case string:
+		return AppendString(b, v), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return AppendInt64(b, i), nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return AppendUint64(b, u), nil
+		}
+		f, err := v.Float64()
+		if err != nil { // such as a number out of the range of float64
+			return b, err
+		}
+		return AppendFloat64(b, f), nil
+	case bool:
+		return AppendBool(b, v), nil
+	default: // nil
+		return AppendNil(b), nil
+	}
+}
@@ -0,0 +1,51 @@
+package msgp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCopyFromJSON(t *testing.T) {
+	js := `{"b": 1, "a": [true, null, 1.5, "x", 18446744073709551615, -3, {}]} [] 7`
+
+	want := AppendMapHeader(nil, 2)
+	want = AppendString(want, "b")
+	want = AppendInt64(want, 1)
+	want = AppendString(want, "a")
+	want = AppendArrayHeader(want, 7)
+	want = AppendBool(want, true)
+	want = AppendNil(want)
+	want = AppendFloat64(want, 1.5)
+	want = AppendString(want, "x")
+	want = AppendUint64(want, 18446744073709551615)
+	want = AppendInt64(want, -3)
+	want = AppendMapHeader(want, 0)
+	want = AppendArrayHeader(want, 0)
+	want = AppendInt64(want, 7)
+
+	var buf bytes.Buffer
+	n, err := CopyFromJSON(&buf, strings.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrote %d bytes %x; want %x", n, buf.Bytes(), want)
+	}
+
+	// The translation back to JSON gives the same values.
+	var back bytes.Buffer
+	if _, err = UnmarshalAsJSON(&back, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if got := back.String(); got != `{"b":1,"a":[true,null,1.5,"x",18446744073709551615,-3,{}]}[]7` {
+		t.Errorf("translated back to %s", got)
+	}
+
+	for _, js := range []string{`{"a": [1`, `[1, 2`, `{"a"`, `{"a": ]`, `[1e400]`, `-1e400`} {
+		if _, err = CopyFromJSON(&buf, strings.NewReader(js)); err == nil || err == io.EOF {
+			t.Errorf("%s: expected an error; got %v", js, err)
+		}
+	}
+}
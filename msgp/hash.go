@@ -0,0 +1,150 @@
+package msgp
+
+import (
+	"bytes"
+	"hash"
+	"sort"
+)
+
+// Hash writes the canonical form of each of the objects in b (see AppendCanonical) to h, so that
+// the hash of the data is the same whichever of the equivalent encodings the producer chose, as
+// the content hashes used to deduplicate or cache messages need to be. Nothing is written to h
+// if b is malformed.
+func Hash(b []byte, h hash.Hash) error {
+	var c []byte
+	for len(b) > 0 {
+		var err error
+		c, b, err = appendCanonical(c, b, 0)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := h.Write(c)
+	return err
+}
+
+// AppendCanonical appends to dst the canonical form of the first object in b and returns the
+// remaining bytes of b. In the canonical form of an object:
+//
+//   - integers are written in the fewest bytes, as unsigned integers if they are not negative;
+//   - the headers of strings, binary data, arrays, maps, and extensions are written in the fewest
+//     bytes;
+//   - the entries of maps are sorted by the canonical forms of their keys (and then values).
+//
+// Floats are left as they are, so a float32 and a float64 holding the same number differ, and
+// strings and binary data are not mixed. The maps and arrays may be nested at most MaxDepth deep.
+func AppendCanonical(dst []byte, b []byte) ([]byte, []byte, error) {
+	return appendCanonical(dst, b, 0)
+}
+
+// appendCanonical appends the canonical form of the object in b nested at the given depth.
+func appendCanonical(dst []byte, b []byte, depth int) ([]byte, []byte, error) {
+	if len(b) == 0 {
+		return dst, b, ErrShortBytes
+	}
+	lead := b[0]
+	switch getType(lead) {
+	case InvalidType:
+		return dst, b, InvalidPrefixError(lead)
+	case IntType:
+		i, o, err := ReadInt64Bytes(b)
+		if err != nil {
+			return dst, b, err
+		}
+		if i >= 0 {
+			return AppendUint64(dst, uint64(i)), o, nil
+		}
+		return AppendInt64(dst, i), o, nil
+	case UintType:
+		u, o, err := ReadUint64Bytes(b)
+		if err != nil {
+			return dst, b, err
+		}
+		return AppendUint64(dst, u), o, nil
+	case StrType, BinType, ExtensionType:
+		hs := headerSize(lead)
+		if len(b) < hs {
+			return dst, b, ErrShortBytes
+		}
+		t, l, et := parseHeader(b[:hs])
+		if uint64(len(b)-hs) < uint64(l) {
+			return dst, b, ErrShortBytes
+		}
+		data := b[hs : hs+int(l)]
+		switch t {
+		case StrType:
+			dst = AppendStringFromBytes(dst, data)
+		case BinType:
+			dst = AppendBytes(dst, data)
+		default:
+			dst = append(appendExtensionHeader(dst, et, len(data)), data...)
+		}
+		return dst, b[hs+int(l):], nil
+	case ArrayType, MapType:
+		if depth >= MaxDepth {
+			return dst, b, ErrMaxDepthExceeded
+		}
+		hs := headerSize(lead)
+		if len(b) < hs {
+			return dst, b, ErrShortBytes
+		}
+		t, n, _ := parseHeader(b[:hs])
+		o := b[hs:]
+		if t == ArrayType {
+			dst = AppendArrayHeader(dst, n)
+			var err error
+			for i := uint32(0); i < n; i++ {
+				if dst, o, err = appendCanonical(dst, o, depth+1); err != nil {
+					return dst, b, err
+				}
+			}
+			return dst, o, nil
+		}
+		return appendCanonicalMap(dst, o, n, depth)
+	}
+	// Nil, bools, and floats have one encoding each.
+	o, err := Skip(b)
+	if err != nil {
+		return dst, b, err
+	}
+	return append(dst, b[:len(b)-len(o)]...), o, nil
+}
+
+// appendCanonicalMap appends the canonical form of the map nested at the given depth of which b
+// holds the n entries following the header.
+func appendCanonicalMap(dst []byte, b []byte, n uint32, depth int) ([]byte, []byte, error) {
+	if uint64(len(b)) < 2*uint64(n) {
+		// Every key and value takes at least one byte.
+		return dst, b, ErrShortBytes
+	}
+	// The entries are written in canonical form to buf and then sorted.
+	type entry struct{ start, key, end int }
+	var buf []byte
+	entries := make([]entry, n)
+	o := b
+	for i := range entries {
+		e := &entries[i]
+		e.start = len(buf)
+		var err error
+		if buf, o, err = appendCanonical(buf, o, depth+1); err != nil {
+			return dst, b, err
+		}
+		e.key = len(buf)
+		if buf, o, err = appendCanonical(buf, o, depth+1); err != nil {
+			return dst, b, err
+		}
+		e.end = len(buf)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if c := bytes.Compare(buf[a.start:a.key], buf[b.start:b.key]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(buf[a.key:a.end], buf[b.key:b.end]) < 0
+	})
+	dst = AppendMapHeader(dst, n)
+	for _, e := range entries {
+		dst = append(dst, buf[e.start:e.end]...)
+	}
+	return dst, o, nil
+}
@@ -0,0 +1,91 @@
+package msgp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func hashOf(t *testing.T, b []byte) []byte {
+	h := sha256.New()
+	if err := Hash(b, h); err != nil {
+		t.Fatalf("Hash(%x): %v", b, err)
+	}
+	return h.Sum(nil)
+}
+
+func TestHashCanonical(t *testing.T) {
+	// Each group holds equivalent encodings of the same object.
+	groups := [][][]byte{
+		{
+			{0x05},             // positive fixint
+			{0xcc, 0x05},       // uint8
+			{0xd1, 0x00, 0x05}, // int16
+			{0xcf, 0, 0, 0, 0, 0, 0, 0, 0x05},
+		},
+		{
+			{0xff},       // negative fixint
+			{0xd0, 0xff}, // int8
+			{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			{0xa2, 'h', 'i'},
+			{0xd9, 0x02, 'h', 'i'},
+			{0xdb, 0, 0, 0, 0x02, 'h', 'i'},
+		},
+		{
+			{0xc4, 0x01, 0xaa},
+			{0xc6, 0, 0, 0, 0x01, 0xaa},
+		},
+		{
+			{0xd4, 0x07, 0x01},
+			{0xc7, 0x01, 0x07, 0x01},
+		},
+		{
+			// {"a": 1, "b": [2]} and {"b": [2], "a": 1} with wide headers
+			{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x91, 0x02},
+			{0xde, 0, 0x02, 0xa1, 'b', 0xdc, 0, 0x01, 0xcd, 0, 0x02, 0xd9, 0x01, 'a', 0xd2, 0, 0, 0, 0x01},
+		},
+	}
+	var sums [][]byte
+	for _, g := range groups {
+		want := hashOf(t, g[0])
+		for _, b := range g[1:] {
+			if got := hashOf(t, b); !bytes.Equal(got, want) {
+				t.Errorf("the hashes of %x and %x differ", g[0], b)
+			}
+		}
+		sums = append(sums, want)
+	}
+	for i := range sums {
+		for j := i + 1; j < len(sums); j++ {
+			if bytes.Equal(sums[i], sums[j]) {
+				t.Errorf("the hashes of %x and %x are equal", groups[i][0], groups[j][0])
+			}
+		}
+	}
+
+	c, o, err := AppendCanonical(nil, groups[5][1])
+	if err != nil || len(o) != 0 || !bytes.Equal(c, groups[5][0]) {
+		t.Errorf("AppendCanonical = %x, %x, %v; want %x", c, o, err, groups[5][0])
+	}
+}
+
+func TestHashMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		{0xc1},                  // invalid prefix
+		{0xa3, 'a'},             // short string
+		{0x82, 0x01, 0x02},      // short map
+		{0x92, 0x01},            // short array
+		{0xcd, 0x01},            // short uint16
+		{0xc7, 0x05, 0x07, 0x1}, // short extension
+	} {
+		if err := Hash(b, sha256.New()); err == nil {
+			t.Errorf("no error hashing %x", b)
+		}
+	}
+	deep := bytes.Repeat([]byte{0x91}, MaxDepth+1)
+	if err := Hash(append(deep, 0x01), sha256.New()); err != ErrMaxDepthExceeded {
+		t.Errorf("hashing nested arrays returned %v", err)
+	}
+}
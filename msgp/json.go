@@ -41,7 +41,7 @@ func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
 	}
 	var nn int
 	for err == nil {
-		nn, err = rwNext(j, r)
+		nn, err = rwNext(j, r, 0)
 		n += int64(nn)
 	}
 	if err != io.EOF {
@@ -57,7 +57,8 @@ func (r *Reader) WriteToJSON(w io.Writer) (n int64, err error) {
 	return
 }
 
-func rwNext(w jsWriter, src *Reader) (int, error) {
+// rwNext translates an object nested at the given depth.
+func rwNext(w jsWriter, src *Reader, depth int) (int, error) {
 	t, err := src.NextType()
 	if err != nil {
 		return 0, err
@@ -68,9 +69,9 @@ func rwNext(w jsWriter, src *Reader) (int, error) {
 	case BinType:
 		return rwBytes(w, src)
 	case MapType:
-		return rwMap(w, src)
+		return rwMap(w, src, depth)
 	case ArrayType:
-		return rwArray(w, src)
+		return rwArray(w, src, depth)
 	case Float64Type:
 		return rwFloat64(w, src)
 	case Float32Type:
@@ -91,12 +92,18 @@ func rwNext(w jsWriter, src *Reader) (int, error) {
 		return rwExtension(w, src)
 	case TimeType:
 		return rwTime(w, src)
+	case Float16Type:
+		return rwFloat16(w, src)
 	default:
 		return 0, err
 	}
 }
 
-func rwMap(dst jsWriter, src *Reader) (int, error) {
+func rwMap(dst jsWriter, src *Reader, depth int) (int, error) {
+
+	if depth >= src.maxDepth() {
+		return 0, ErrMaxDepthExceeded
+	}
 
 	sz, err := src.ReadMapHeader()
 	if err != nil {
@@ -136,7 +143,7 @@ func rwMap(dst jsWriter, src *Reader) (int, error) {
 			return n, err
 		}
 		n++
-		nn, err = rwNext(dst, src)
+		nn, err = rwNext(dst, src, depth+1)
 		n += nn
 		if err != nil {
 			return n, err
@@ -154,7 +161,10 @@ func rwMap(dst jsWriter, src *Reader) (int, error) {
 
 }
 
-func rwArray(dst jsWriter, src *Reader) (n int, err error) {
+func rwArray(dst jsWriter, src *Reader, depth int) (n int, err error) {
+	if depth >= src.maxDepth() {
+		return 0, ErrMaxDepthExceeded
+	}
 	err = dst.WriteByte('[')
 	if err != nil {
 		return
@@ -174,7 +184,7 @@ func rwArray(dst jsWriter, src *Reader) (n int, err error) {
 			}
 			n++
 		}
-		nn, err = rwNext(dst, src)
+		nn, err = rwNext(dst, src, depth+1)
 		n += nn
 		if err != nil {
 			return
@@ -203,7 +213,7 @@ func rwFloat32(dst jsWriter, src *Reader) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendFloat(src.scratch[:0], float64(f), 'f', -1, 64)
+	src.scratch = strconv.AppendFloat(src.scratch[:0], float64(f), 'f', -1, 32)
 	return dst.Write(src.scratch)
 }
 
@@ -212,7 +222,16 @@ func rwFloat64(dst jsWriter, src *Reader) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	src.scratch = strconv.AppendFloat(src.scratch[:0], f, 'f', -1, 32)
+	src.scratch = strconv.AppendFloat(src.scratch[:0], f, 'f', -1, 64)
+	return dst.Write(src.scratch)
+}
+
+func rwFloat16(dst jsWriter, src *Reader) (int, error) {
+	f, err := src.ReadFloat16()
+	if err != nil {
+		return 0, err
+	}
+	src.scratch = strconv.AppendFloat(src.scratch[:0], float64(f), 'f', -1, 32)
 	return dst.Write(src.scratch)
 }
 
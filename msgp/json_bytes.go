@@ -21,8 +21,8 @@ func UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
 		dst = bufio.NewWriterSize(w, 512)
 	}
 	var err error
-	for len(msg) > 0 {
-		msg, _, err = writeNext(dst, msg, nil)
+	for len(msg) > 0 && err == nil {
+		msg, _, err = writeNext(dst, msg, nil, 0)
 	}
 	if !cast && err == nil {
 		err = dst.(*bufio.Writer).Flush()
@@ -30,19 +30,24 @@ func UnmarshalAsJSON(w io.Writer, msg []byte) ([]byte, error) {
 	return msg, err
 }
 
-func writeNext(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+// writeNext translates an object nested at the given depth.
+func writeNext(w jsWriter, msg []byte, scratch []byte, depth int) ([]byte, []byte, error) {
 	if len(msg) == 0 {
 		return msg, scratch, ErrShortBytes
 	}
 	t := getType(msg[0])
 	if t == ExtensionType {
-		// The TimeExtension type is encoded the time.MarshalJSON way.
+		// The TimeExtension type is encoded the time.MarshalJSON way, and the Float16Extension
+		// type as a number.
 		et, err := peekExtension(msg)
 		if err != nil {
 			return nil, scratch, err
 		}
-		if et == TimeExtension {
+		switch et {
+		case TimeExtension:
 			t = TimeType
+		case Float16Extension:
+			t = Float16Type
 		}
 	}
 	switch t {
@@ -53,9 +58,9 @@ func writeNext(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
 	case BinType:
 		return rwBytesBytes(w, msg, scratch)
 	case MapType:
-		return rwMapBytes(w, msg, scratch)
+		return rwMapBytes(w, msg, scratch, depth)
 	case ArrayType:
-		return rwArrayBytes(w, msg, scratch)
+		return rwArrayBytes(w, msg, scratch, depth)
 	case Float64Type:
 		return rwFloat64Bytes(w, msg, scratch)
 	case Float32Type:
@@ -72,12 +77,17 @@ func writeNext(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
 		return rwExtensionBytes(w, msg, scratch)
 	case TimeType:
 		return rwTimeBytes(w, msg, scratch)
+	case Float16Type:
+		return rwFloat16Bytes(w, msg, scratch)
 	default:
 		return nil, msg, InvalidPrefixError(msg[0])
 	}
 }
 
-func rwArrayBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwArrayBytes(w jsWriter, msg []byte, scratch []byte, depth int) ([]byte, []byte, error) {
+	if depth >= MaxDepth {
+		return msg, scratch, ErrMaxDepthExceeded
+	}
 	sz, msg, err := ReadArrayHeaderBytes(msg)
 	if err != nil {
 		return msg, scratch, err
@@ -93,7 +103,7 @@ func rwArrayBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error
 				return msg, scratch, err
 			}
 		}
-		msg, scratch, err = writeNext(w, msg, scratch)
+		msg, scratch, err = writeNext(w, msg, scratch, depth+1)
 		if err != nil {
 			return msg, scratch, err
 		}
@@ -102,7 +112,10 @@ func rwArrayBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error
 	return msg, scratch, err
 }
 
-func rwMapBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+func rwMapBytes(w jsWriter, msg []byte, scratch []byte, depth int) ([]byte, []byte, error) {
+	if depth >= MaxDepth {
+		return msg, scratch, ErrMaxDepthExceeded
+	}
 	sz, msg, err := ReadMapHeaderBytes(msg)
 	if err != nil {
 		return msg, scratch, err
@@ -126,7 +139,7 @@ func rwMapBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error)
 		if err != nil {
 			return msg, scratch, err
 		}
-		msg, scratch, err = writeNext(w, msg, scratch)
+		msg, scratch, err = writeNext(w, msg, scratch, depth+1)
 		if err != nil {
 			return msg, scratch, err
 		}
@@ -240,6 +253,16 @@ func rwFloat64Bytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, err
 	return msg, scratch, err
 }
 
+func rwFloat16Bytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
+	f, msg, err := ReadFloat16Bytes(msg)
+	if err != nil {
+		return msg, scratch, err
+	}
+	scratch = strconv.AppendFloat(scratch[:0], float64(f), 'f', -1, 32)
+	_, err = w.Write(scratch)
+	return msg, scratch, err
+}
+
 func rwTimeBytes(w jsWriter, msg []byte, scratch []byte) ([]byte, []byte, error) {
 	t, msg, err := ReadTimeBytes(msg)
 	if err != nil {
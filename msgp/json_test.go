@@ -69,6 +69,31 @@ func TestCopyJSON(t *testing.T) {
 	}
 }
 
+func TestCopyJSONFloats(t *testing.T) {
+	msg := AppendArrayHeader(nil, 4)
+	msg = AppendFloat32(msg, 0.1)
+	msg = AppendFloat64(msg, 0.1)
+	msg = AppendFloat64(msg, 1.0000000001)
+	msg = AppendFloat16(msg, 0.5)
+	const want = "[0.1,0.1,1.0000000001,0.5]"
+
+	var js bytes.Buffer
+	if _, err := CopyToJSON(&js, bytes.NewReader(msg)); err != nil {
+		t.Fatal(err)
+	}
+	if js.String() != want {
+		t.Errorf("CopyToJSON wrote %s; want %s", js.String(), want)
+	}
+
+	js.Reset()
+	if _, err := UnmarshalAsJSON(&js, msg); err != nil {
+		t.Fatal(err)
+	}
+	if js.String() != want {
+		t.Errorf("UnmarshalAsJSON wrote %s; want %s", js.String(), want)
+	}
+}
+
 func BenchmarkCopyToJSON(b *testing.B) {
 	var buf bytes.Buffer
 	enc := NewWriter(&buf)
@@ -0,0 +1,211 @@
+package msgprpc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// A Client makes calls to the methods of a server over a connection. A Client can be used
+// by multiple goroutines at once.
+type Client struct {
+	conn io.ReadWriteCloser
+
+	wmu sync.Mutex // guards w
+	w   *msgp.Writer
+
+	mu      sync.Mutex // guards the following
+	seq     uint32
+	pending map[uint32]*call
+	err     error // the error that shut the Client down
+}
+
+// A call is a call waiting for its response.
+type call struct {
+	result msgp.Decoder
+	err    error
+	done   chan struct{}
+}
+
+// NewClient returns a Client making calls over conn. The Client reads the responses to its calls
+// in a separate goroutine until the Client is closed or reading from the connection fails.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		conn:    conn,
+		w:       msgp.NewWriter(conn),
+		pending: make(map[uint32]*call),
+	}
+	go c.readLoop(msgp.NewReader(conn))
+	return c
+}
+
+// Call calls the named method with the given parameters (which may be nil) and waits for the
+// response. The result of the call is decoded into result, unless result is nil or the method
+// returns a nil result. An error returned by the method is returned as a ServerError.
+func (c *Client) Call(method string, params msgp.Encoder, result msgp.Decoder) error {
+	cl := &call{result: result, done: make(chan struct{})}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	id := c.seq
+	c.seq++
+	c.pending[id] = cl
+	c.mu.Unlock()
+
+	c.wmu.Lock()
+	err := writeRequest(c.w, id, method, params)
+	c.wmu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		if c.err != nil {
+			err = c.err // The write failed because the Client was shut down.
+		}
+		c.mu.Unlock()
+		return err
+	}
+
+	<-cl.done
+	return cl.err
+}
+
+// Notify sends a notification calling the named method with the given parameters (which may be nil).
+// Notifications get no response.
+func (c *Client) Notify(method string, params msgp.Encoder) error {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if err := c.w.WriteArrayHeader(3); err != nil {
+		return err
+	}
+	if err := c.w.WriteInt(typeNotify); err != nil {
+		return err
+	}
+	if err := c.w.WriteString(method); err != nil {
+		return err
+	}
+	if err := writeParams(c.w, params); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// Close closes the connection. The calls waiting for responses return ErrShutdown.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return ErrShutdown
+	}
+	c.err = ErrShutdown
+	c.mu.Unlock()
+	return c.conn.Close()
+}
+
+func writeRequest(w *msgp.Writer, id uint32, method string, params msgp.Encoder) error {
+	if err := w.WriteArrayHeader(4); err != nil {
+		return err
+	}
+	if err := w.WriteInt(typeRequest); err != nil {
+		return err
+	}
+	if err := w.WriteUint32(id); err != nil {
+		return err
+	}
+	if err := w.WriteString(method); err != nil {
+		return err
+	}
+	if err := writeParams(w, params); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// readLoop reads responses from r until reading fails, and then it makes all of the pending calls
+// return an error.
+func (c *Client) readLoop(r *msgp.Reader) {
+	var err error
+	for err == nil {
+		err = c.readResponse(r)
+	}
+
+	c.mu.Lock()
+	if c.err == nil {
+		if err == io.EOF {
+			c.err = ErrShutdown
+		} else {
+			c.err = err
+		}
+	}
+	for id, cl := range c.pending {
+		cl.err = c.err
+		close(cl.done)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// readResponse reads a response and completes the call it is for.
+func (c *Client) readResponse(r *msgp.Reader) error {
+	typ, err := readHeader(r)
+	if err != nil {
+		return err
+	}
+	if typ != typeResponse {
+		return fmt.Errorf("msgprpc: client received message of type %d", typ)
+	}
+	id, err := readID(r)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	cl := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if cl == nil {
+		return fmt.Errorf("msgprpc: received response to unknown request %d", id)
+	}
+	defer close(cl.done)
+
+	if r.IsNil() {
+		err = r.ReadNil()
+	} else {
+		var e interface{}
+		if e, err = r.ReadIntf(); err == nil {
+			if s, ok := e.(string); ok {
+				cl.err = ServerError(s)
+			} else {
+				cl.err = ServerError(fmt.Sprint(e))
+			}
+		}
+	}
+	if err != nil {
+		cl.err = err
+		return err
+	}
+
+	// The result is read before being decoded so that a result of the wrong type does not
+	// leave the reader within the response.
+	var result msgp.Raw
+	if err = result.DecodeMsg(r); err != nil {
+		cl.err = err
+		return err
+	}
+	if cl.err == nil && cl.result != nil && !msgp.IsNil(result) {
+		cl.err = cl.result.DecodeMsg(msgp.NewReaderSize(bytes.NewReader(result), len(result)))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package msgprpc implements the MessagePack-RPC protocol (https://github.com/msgpack-rpc/msgpack-rpc)
+// on top of the Reader and Writer types of package msgp.
+//
+// The parameters and results of calls are values implementing msgp.Encoder and msgp.Decoder, such as
+// the types for which methods are generated by the msgp tool. A call sends its parameters as the only
+// element of the params array of a request, and the result is whatever the method returns.
+//
+// A Server dispatches the requests and notifications it receives on a connection to the methods
+// registered with it, handling each request concurrently. A Client sends requests and notifications
+// on a connection and can have many calls waiting for responses at once.
+package msgprpc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// The message types defined by the protocol.
+const (
+	typeRequest  = 0
+	typeResponse = 1
+	typeNotify   = 2
+)
+
+// ErrShutdown is returned by the calls made on a Client that is closed or whose connection failed.
+var ErrShutdown = errors.New("msgprpc: connection is shut down")
+
+// ServerError is an error returned by the remote end of a call.
+type ServerError string
+
+// Error implements the error interface.
+func (e ServerError) Error() string { return string(e) }
+
+// writeParams writes the params array holding params, which may be nil.
+func writeParams(w *msgp.Writer, params msgp.Encoder) error {
+	if params == nil {
+		return w.WriteArrayHeader(0)
+	}
+	if err := w.WriteArrayHeader(1); err != nil {
+		return err
+	}
+	return params.EncodeMsg(w)
+}
+
+// readParams reads a params array, decoding its first element into params (if it's not nil)
+// and skipping the others.
+func readParams(r *msgp.Reader, params msgp.Decoder) error {
+	sz, err := r.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < sz; i++ {
+		if i == 0 && params != nil {
+			err = params.DecodeMsg(r)
+		} else {
+			err = r.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readID reads the ID of a request or a response.
+func readID(r *msgp.Reader) (uint32, error) {
+	id, err := r.ReadInt64()
+	if err != nil {
+		return 0, err
+	}
+	if id < 0 || id > math.MaxUint32 {
+		return 0, fmt.Errorf("msgprpc: invalid message ID %d", id)
+	}
+	return uint32(id), nil
+}
+
+// readHeader reads the array header and the type of a message.
+func readHeader(r *msgp.Reader) (typ int, err error) {
+	sz, err := r.ReadArrayHeader()
+	if err != nil {
+		return 0, err
+	}
+	typ, err = r.ReadInt()
+	if err != nil {
+		return 0, err
+	}
+	want := uint32(4)
+	if typ == typeNotify {
+		want = 3
+	}
+	if sz != want {
+		return 0, fmt.Errorf("msgprpc: message of type %d has %d elements; expected %d", typ, sz, want)
+	}
+	return typ, nil
+}
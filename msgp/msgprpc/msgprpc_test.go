@@ -0,0 +1,160 @@
+package msgprpc
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func newNumber() msgp.Decoder { return new(msgp.Number) }
+
+// testServer returns a Server with a few methods taking and returning Numbers.
+func testServer(notified chan<- int64, block <-chan struct{}) *Server {
+	s := NewServer()
+	s.Register("double", newNumber, func(params msgp.Decoder) (msgp.Encoder, error) {
+		i, _ := params.(*msgp.Number).Int()
+		var out msgp.Number
+		out.AsInt(i * 2)
+		return &out, nil
+	})
+	s.Register("fail", nil, func(msgp.Decoder) (msgp.Encoder, error) {
+		return nil, errors.New("failed on purpose")
+	})
+	s.Register("nothing", nil, func(msgp.Decoder) (msgp.Encoder, error) {
+		return nil, nil
+	})
+	s.Register("record", newNumber, func(params msgp.Decoder) (msgp.Encoder, error) {
+		i, _ := params.(*msgp.Number).Int()
+		notified <- i
+		return nil, nil
+	})
+	s.Register("block", nil, func(msgp.Decoder) (msgp.Encoder, error) {
+		<-block
+		return nil, nil
+	})
+	return s
+}
+
+func number(i int64) *msgp.Number {
+	var n msgp.Number
+	n.AsInt(i)
+	return &n
+}
+
+func TestCalls(t *testing.T) {
+	notified := make(chan int64, 1)
+	srvConn, cliConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- testServer(notified, nil).ServeConn(srvConn) }()
+	c := NewClient(cliConn)
+
+	var out msgp.Number
+	if err := c.Call("double", number(21), &out); err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := out.Int(); i != 42 {
+		t.Errorf("double: got %d", i)
+	}
+
+	if err := c.Call("nothing", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.Call("fail", nil, nil)
+	if se, ok := err.(ServerError); !ok || se != "failed on purpose" {
+		t.Errorf("fail: got error %v", err)
+	}
+
+	if err = c.Call("missing", nil, nil); err == nil {
+		t.Error("expected an error calling a method that is not registered")
+	} else if _, ok := err.(ServerError); !ok {
+		t.Errorf("expected a ServerError; got %v", err)
+	}
+
+	// Parameters of the wrong type.
+	if err = c.Call("double", msgp.Raw(msgp.AppendString(nil, "x")), &out); err == nil {
+		t.Error("expected an error calling a method with invalid parameters")
+	}
+
+	if err = c.Notify("record", number(7)); err != nil {
+		t.Fatal(err)
+	}
+	if i := <-notified; i != 7 {
+		t.Errorf("notify: got %d", i)
+	}
+
+	var wg sync.WaitGroup
+	for i := int64(0); i < 20; i++ {
+		wg.Add(1)
+		go func(i int64) {
+			defer wg.Done()
+			var out msgp.Number
+			if err := c.Call("double", number(i), &out); err != nil {
+				t.Error(err)
+				return
+			}
+			if got, _ := out.Int(); got != 2*i {
+				t.Errorf("double(%d): got %d", i, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Call("double", number(1), &out); err != ErrShutdown {
+		t.Errorf("expected %v after Close; got %v", ErrShutdown, err)
+	}
+	if err = <-done; err != nil {
+		t.Errorf("ServeConn: %v", err)
+	}
+}
+
+func TestCloseWithPendingCall(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	srvConn, cliConn := net.Pipe()
+	go testServer(nil, block).ServeConn(srvConn)
+	c := NewClient(cliConn)
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.Call("block", nil, nil) }()
+	c.Close()
+	if err := <-errc; err != ErrShutdown {
+		t.Errorf("expected %v; got %v", ErrShutdown, err)
+	}
+}
+
+// TestWireFormat checks the messages against the MessagePack-RPC specification.
+func TestWireFormat(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	go testServer(nil, nil).ServeConn(srvConn)
+	defer cliConn.Close()
+
+	req := msgp.AppendArrayHeader(nil, 4)
+	req = msgp.AppendInt(req, 0)
+	req = msgp.AppendUint32(req, 7)
+	req = msgp.AppendString(req, "double")
+	req = msgp.AppendArrayHeader(req, 1)
+	req = msgp.AppendInt(req, 5)
+	go cliConn.Write(req)
+
+	want := msgp.AppendArrayHeader(nil, 4)
+	want = msgp.AppendInt(want, 1)
+	want = msgp.AppendUint32(want, 7)
+	want = msgp.AppendNil(want)
+	want = msgp.AppendInt(want, 10)
+
+	var got msgp.Raw
+	if err := got.DecodeMsg(msgp.NewReader(cliConn)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected response %x; got %x", want, []byte(got))
+	}
+}
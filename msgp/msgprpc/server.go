@@ -0,0 +1,157 @@
+package msgprpc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// A HandlerFunc serves calls of a method. It is given the decoded parameters of a call and returns
+// the result to send back to the caller (nil sends a nil result) or an error, whose message is sent
+// to the caller. The results of notifications are discarded.
+type HandlerFunc func(params msgp.Decoder) (result msgp.Encoder, err error)
+
+// A method is a registered method.
+type method struct {
+	newParams func() msgp.Decoder
+	fn        HandlerFunc
+}
+
+// A Server dispatches the requests and notifications it receives to the registered methods.
+type Server struct {
+	mu      sync.RWMutex
+	methods map[string]method
+}
+
+// NewServer returns a new Server with no registered methods.
+func NewServer() *Server {
+	return &Server{methods: make(map[string]method)}
+}
+
+// Register sets fn to handle calls of the named method. For each call, the parameters are decoded
+// into the value returned by newParams, which is passed to fn. If newParams is nil, the parameters
+// are ignored and fn is given nil.
+func (s *Server) Register(name string, newParams func() msgp.Decoder, fn HandlerFunc) {
+	s.mu.Lock()
+	s.methods[name] = method{newParams, fn}
+	s.mu.Unlock()
+}
+
+// ServeConn serves the requests and notifications received on conn until reading from it fails,
+// handling each message in its own goroutine. The connection is closed when ServeConn returns.
+// ServeConn returns nil if the connection is closed by the client between messages.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) error {
+	defer conn.Close()
+
+	r := msgp.NewReader(conn)
+	sc := &serverConn{w: msgp.NewWriter(conn)}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		if _, err := r.R.Peek(1); err == io.EOF {
+			return nil
+		}
+		typ, err := readHeader(r)
+		if err != nil {
+			return err
+		}
+		var id uint32
+		if typ == typeRequest {
+			if id, err = readID(r); err != nil {
+				return err
+			}
+		} else if typ != typeNotify {
+			return fmt.Errorf("msgprpc: server received message of type %d", typ)
+		}
+		name, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		// The parameters are decoded by the goroutine handling the message.
+		var params msgp.Raw
+		if err = params.DecodeMsg(r); err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func(typ int, id uint32) {
+			defer wg.Done()
+			result, err := s.call(name, params)
+			if typ == typeRequest {
+				sc.respond(id, result, err)
+			}
+		}(typ, id)
+	}
+}
+
+// call calls the named method with the parameters in the params array encoded in raw.
+func (s *Server) call(name string, raw msgp.Raw) (msgp.Encoder, error) {
+	s.mu.RLock()
+	m, ok := s.methods[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("msgprpc: method %s not found", name)
+	}
+	var params msgp.Decoder
+	if m.newParams != nil {
+		params = m.newParams()
+	}
+	if err := readParams(msgp.NewReaderSize(bytes.NewReader(raw), len(raw)), params); err != nil {
+		return nil, fmt.Errorf("msgprpc: invalid parameters for method %s: %w", name, err)
+	}
+	return m.fn(params)
+}
+
+// A serverConn writes the responses to the requests received on a connection.
+type serverConn struct {
+	mu      sync.Mutex
+	w       *msgp.Writer
+	lastErr error // the error of the last failed write
+}
+
+// respond writes the response to the request with the given ID.
+func (sc *serverConn) respond(id uint32, result msgp.Encoder, err error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.lastErr != nil {
+		return
+	}
+	sc.lastErr = writeResponse(sc.w, id, result, err)
+}
+
+func writeResponse(w *msgp.Writer, id uint32, result msgp.Encoder, callErr error) error {
+	if err := w.WriteArrayHeader(4); err != nil {
+		return err
+	}
+	if err := w.WriteInt(typeResponse); err != nil {
+		return err
+	}
+	if err := w.WriteUint32(id); err != nil {
+		return err
+	}
+	if callErr != nil {
+		if err := w.WriteString(callErr.Error()); err != nil {
+			return err
+		}
+		if err := w.WriteNil(); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	if err := w.WriteNil(); err != nil {
+		return err
+	}
+	if result == nil {
+		if err := w.WriteNil(); err != nil {
+			return err
+		}
+	} else if err := result.EncodeMsg(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
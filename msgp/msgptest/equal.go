@@ -0,0 +1,101 @@
+package msgptest
+
+import "reflect"
+
+var boolType = reflect.TypeOf(true)
+
+// Equal says if a and b have the same contents, like reflect.DeepEqual, except that a nil slice or
+// map is equal to an empty one and the values of types having Equal methods, such as time.Time and
+// msgp.Raw, are compared with the methods. A value decoded from the message of another value of
+// its type is then equal to the other value, as nil slices and maps are encoded as empty arrays
+// and maps and times are decoded in the local zone.
+func Equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return equal(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// equal compares a and b, which have the same type.
+func equal(a, b reflect.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	if m, ok := equalMethod(a); ok {
+		return m.Call([]reflect.Value{b})[0].Bool()
+	}
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, k := range a.MapKeys() {
+			v := b.MapIndex(k)
+			if !v.IsValid() || !equal(a.MapIndex(k), v) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equal(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equal(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		// Functions, channels, and unsafe pointers are not encoded.
+		return a.IsNil() == b.IsNil()
+	}
+}
+
+// equalMethod returns the method Equal(o T) bool of a, which is a T, if T or *T has it. The values
+// of unexported fields are compared without their methods, which cannot be called.
+func equalMethod(a reflect.Value) (reflect.Value, bool) {
+	if !a.CanInterface() {
+		return reflect.Value{}, false
+	}
+	t := a.Type()
+	mt, ok := reflect.PtrTo(t).MethodByName("Equal")
+	if !ok || mt.Type.NumIn() != 2 || mt.Type.In(1) != t ||
+		mt.Type.NumOut() != 1 || mt.Type.Out(0) != boolType {
+		return reflect.Value{}, false
+	}
+	p := reflect.New(t)
+	p.Elem().Set(a)
+	return p.Method(mt.Index), true
+}
@@ -0,0 +1,153 @@
+// Package msgptest provides helpers for testing and benchmarking the types implementing the
+// interfaces of package msgp, such as the types for which methods are generated by the msgp tool.
+//
+// The tests and benchmarks generated by the msgp tool call these helpers, but they can just as
+// well be used in table tests:
+//
+//	for _, v := range []Person{{Name: "a"}, {Name: "b", Age: 20}} {
+//		msgptest.RoundTrip(t, &v, new(Person))
+//		msgptest.EncodeDecode(t, &v, new(Person))
+//	}
+package msgptest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// RoundTrip marshals in and unmarshals the message into out, which should be a pointer to a value
+// of the type of in. It reports an error if either step fails, if UnmarshalMsg or msgp.Skip does
+// not consume the whole message, or if in has a MsgsizeExact method that does not return the size
+// of the message. RoundTrip returns the message.
+func RoundTrip(t testing.TB, in msgp.Marshaler, out msgp.Unmarshaler) []byte {
+	t.Helper()
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatalf("MarshalMsg: %s", err)
+		return nil
+	}
+	left, err := out.UnmarshalMsg(bts)
+	if err != nil {
+		t.Fatalf("UnmarshalMsg: %s", err)
+		return bts
+	}
+	if len(left) > 0 {
+		t.Errorf("%d bytes left over after UnmarshalMsg(): %q", len(left), left)
+	}
+	if s, ok := in.(msgp.ExactSizer); ok {
+		if n := s.MsgsizeExact(); n != len(bts) {
+			t.Errorf("MsgsizeExact() = %d, but the message is %d bytes", n, len(bts))
+		}
+	}
+	left, err = msgp.Skip(bts)
+	if err != nil {
+		t.Fatalf("Skip: %s", err)
+		return bts
+	}
+	if len(left) > 0 {
+		t.Errorf("%d bytes left over after Skip(): %q", len(left), left)
+	}
+	return bts
+}
+
+// EncodeDecode encodes in to a Writer and decodes the message into out, which should be a pointer
+// to a value of the type of in. It reports an error if either step fails or if a Reader cannot
+// skip the message. If in has a Msgsize method that returns less than the size of the message, the
+// inaccuracy is logged. EncodeDecode returns the message.
+func EncodeDecode(t testing.TB, in msgp.Encoder, out msgp.Decoder) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, in); err != nil {
+		t.Fatalf("EncodeMsg: %s", err)
+		return nil
+	}
+	bts := buf.Bytes()
+	if s, ok := in.(msgp.Sizer); ok && len(bts) > s.Msgsize() {
+		t.Logf("WARNING: Msgsize() for %v is inaccurate", in)
+	}
+	if err := msgp.Decode(bytes.NewReader(bts), out); err != nil {
+		t.Errorf("DecodeMsg: %s", err)
+	}
+	if err := msgp.NewReader(bytes.NewReader(bts)).Skip(); err != nil {
+		t.Errorf("Skip: %s", err)
+	}
+	return bts
+}
+
+// BenchmarkMarshal measures marshaling v into a new slice each time.
+func BenchmarkMarshal(b *testing.B, v msgp.Marshaler) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v.MarshalMsg(nil)
+	}
+}
+
+// BenchmarkAppend measures marshaling v into a reused slice.
+func BenchmarkAppend(b *testing.B, v msgp.Marshaler) {
+	var bts []byte
+	if s, ok := v.(msgp.Sizer); ok {
+		bts = make([]byte, 0, s.Msgsize())
+	}
+	bts, err := v.MarshalMsg(bts[0:0])
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(bts)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bts, _ = v.MarshalMsg(bts[0:0])
+	}
+}
+
+// BenchmarkUnmarshal measures unmarshaling the message of in into out.
+func BenchmarkUnmarshal(b *testing.B, in msgp.Marshaler, out msgp.Unmarshaler) {
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(bts)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := out.UnmarshalMsg(bts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncode measures encoding v to a Writer that discards its output.
+func BenchmarkEncode(b *testing.B, v msgp.Encoder) {
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, v); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(buf.Len()))
+	en := msgp.NewWriter(msgp.Nowhere)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v.EncodeMsg(en)
+	}
+	en.Flush()
+}
+
+// BenchmarkDecode measures decoding the message of in into out from a Reader that repeats it.
+func BenchmarkDecode(b *testing.B, in msgp.Encoder, out msgp.Decoder) {
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, in); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(buf.Len()))
+	dc := msgp.NewReader(msgp.NewEndlessReader(buf.Bytes(), b))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := out.DecodeMsg(dc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
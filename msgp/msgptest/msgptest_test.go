@@ -0,0 +1,89 @@
+package msgptest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// recorder records the failures reported to it instead of failing the test.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) { r.Errorf(format, args...) }
+
+func (r *recorder) Logf(format string, args ...interface{}) {}
+
+// badSize is a Raw whose MsgsizeExact is off by one.
+type badSize struct{ msgp.Raw }
+
+func (s badSize) MsgsizeExact() int { return len(s.Raw) + 1 }
+
+func TestRoundTrip(t *testing.T) {
+	in := msgp.Raw(msgp.AppendString(nil, "hello"))
+	var out msgp.Raw
+	bts := RoundTrip(t, in, &out)
+	if !in.Equal(out) || !in.Equal(bts) {
+		t.Errorf("got %x and %x; want %x", out, bts, in)
+	}
+
+	// Two objects leave bytes over after UnmarshalMsg and Skip.
+	var r recorder
+	RoundTrip(&r, badSize{msgp.AppendNil(in)}, new(msgp.Raw))
+	if len(r.errs) != 3 {
+		t.Errorf("got the errors %q; want 3 errors", r.errs)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	var in, out msgp.Number
+	in.AsFloat64(2.5)
+	EncodeDecode(t, &in, &out)
+	if !in.Equal(out) {
+		t.Errorf("got %v; want %v", out, in)
+	}
+
+	var r recorder
+	EncodeDecode(&r, msgp.Raw{0xc1}, new(msgp.Raw))
+	if len(r.errs) == 0 {
+		t.Error("got no errors for an invalid message")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	type item struct {
+		Names []string
+		Attrs map[string]int
+		When  time.Time
+		Raw   msgp.Raw
+		Any   interface{}
+		Next  *item
+	}
+	now := time.Now()
+	a := item{When: now.UTC(), Any: "a", Next: &item{Names: []string{}, Attrs: map[string]int{}}}
+	b := item{When: now, Raw: msgp.AppendNil(nil), Any: "a", Next: &item{}}
+	if !Equal(a, b) {
+		t.Errorf("%v is not equal to %v", a, b)
+	}
+	b.Next.Names = []string{"x"}
+	if Equal(a, b) {
+		t.Errorf("%v is equal to %v", a, b)
+	}
+	b.Next = nil
+	if Equal(a, b) {
+		t.Error("a nil pointer is equal to a non-nil one")
+	}
+	if Equal(a, &a) || Equal(1, int64(1)) || !Equal(nil, nil) {
+		t.Error("values of different types are equal")
+	}
+}
@@ -0,0 +1,40 @@
+package msgptest
+
+import (
+	"math/rand"
+	"time"
+)
+
+// The random values are made of these letters so that they look like text.
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// RandString returns a string of n random letters and digits.
+func RandString(r *rand.Rand, n int) string { return string(RandBytes(r, n)) }
+
+// RandBytes returns a slice of n random letters and digits.
+func RandBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return b
+}
+
+// RandInt returns a random integer of a random magnitude, so that the integers returned are
+// spread across the sizes in which MessagePack encodes integers. Converting the integer to a
+// narrower type keeps its low bits.
+func RandInt(r *rand.Rand) int64 {
+	i := r.Int63() >> uint(r.Intn(63))
+	if r.Intn(2) == 0 {
+		return -i
+	}
+	return i
+}
+
+// RandUint returns a random unsigned integer of a random magnitude, like RandInt.
+func RandUint(r *rand.Rand) uint64 { return r.Uint64() >> uint(r.Intn(64)) }
+
+// RandTime returns a random time between the years 1970 and 2106 in UTC.
+func RandTime(r *rand.Rand) time.Time {
+	return time.Unix(r.Int63n(1<<32), r.Int63n(int64(time.Second))).UTC()
+}
@@ -2,23 +2,29 @@ package msgp
 
 import (
 	"math"
+	mathbig "math/big"
 	"strconv"
 )
 
-// A Number can be an int64, uint64, float32, or float64 internally.
-// It can decode itself from any of the native MessagePack number types.
-// The zero-value of Number is Int(0).
+// A Number can be an int64, uint64, float32, or float64 internally, or an integer of any size
+// that does not fit in an int64 or a uint64. It can decode itself from any of the native
+// MessagePack number types as well as from the BigIntExtension extension and from strings
+// holding base-10 integers. The zero-value of Number is Int(0).
+//
+// Numbers can be compared with the == operator unless they hold big integers.
 type Number struct {
 	// Internally, this is just a tagged union. The raw bits of
 	// the number are stored the same way regardless.
-	bits uint64
-	typ  Type
+	bits   uint64
+	typ    Type
+	bigInt *mathbig.Int // set (with typ ExtensionType) only for integers too big for bits
 }
 
 // AsInt sets the number to an int64.
 func (n *Number) AsInt(i int64) {
 	// Always store int(0) as {0, InvalidType} to
 	// preserve the behavior of the == operator.
+	n.bigInt = nil
 	if i == 0 {
 		n.typ = InvalidType
 		n.bits = 0
@@ -33,18 +39,36 @@ func (n *Number) AsInt(i int64) {
 func (n *Number) AsUint(u uint64) {
 	n.typ = UintType
 	n.bits = u
+	n.bigInt = nil
 }
 
 // AsFloat32 sets the number to a float32.
 func (n *Number) AsFloat32(f float32) {
 	n.typ = Float32Type
 	n.bits = uint64(math.Float32bits(f))
+	n.bigInt = nil
 }
 
 // AsFloat64 sets the number to a float64.
 func (n *Number) AsFloat64(f float64) {
 	n.typ = Float64Type
 	n.bits = math.Float64bits(f)
+	n.bigInt = nil
+}
+
+// AsBigInt sets the number to a copy of x. If x fits in an int64 or a uint64, the number
+// is stored as such.
+func (n *Number) AsBigInt(x *mathbig.Int) {
+	switch {
+	case x.IsInt64():
+		n.AsInt(x.Int64())
+	case x.IsUint64():
+		n.AsUint(x.Uint64())
+	default:
+		n.typ = ExtensionType
+		n.bits = 0
+		n.bigInt = new(mathbig.Int).Set(x)
+	}
 }
 
 // Int returns the number converted to a int64 and says whether or not
@@ -72,7 +96,23 @@ func (n *Number) Float() (float64, bool) {
 	}
 }
 
-// Type returns the number's type as one of Float64Type, Float32Type, UintType, or IntType.
+// BigInt returns the number converted to a new *big.Int and says whether or not the number
+// is an integer. If the number is a float, BigInt returns nil and false.
+func (n *Number) BigInt() (*mathbig.Int, bool) {
+	switch n.typ {
+	case InvalidType, IntType:
+		return new(mathbig.Int).SetInt64(int64(n.bits)), true
+	case UintType:
+		return new(mathbig.Int).SetUint64(n.bits), true
+	case ExtensionType:
+		return new(mathbig.Int).Set(n.bigInt), true
+	default:
+		return nil, false
+	}
+}
+
+// Type returns the number's type as one of Float64Type, Float32Type, UintType, or IntType,
+// or as ExtensionType if the number is an integer too big for an int64 and a uint64.
 func (n *Number) Type() Type {
 	if n.typ == InvalidType {
 		return IntType
@@ -115,6 +155,19 @@ func (n *Number) DecodeMsg(r *Reader) error {
 		}
 		n.AsUint(u)
 		return nil
+	case ExtensionType:
+		e := bigIntExt{new(mathbig.Int)}
+		if err = r.ReadExtension(&e); err != nil {
+			return err
+		}
+		n.AsBigInt(e.x)
+		return nil
+	case StrType:
+		s, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+		return n.setString(s)
 	default:
 		return TypeError{Encoded: typ, Method: IntType}
 	}
@@ -152,11 +205,38 @@ func (n *Number) UnmarshalMsg(b []byte) ([]byte, error) {
 		}
 		n.AsFloat32(f)
 		return o, nil
+	case ExtensionType:
+		e := bigIntExt{new(mathbig.Int)}
+		o, err := ReadExtensionBytes(b, &e)
+		if err != nil {
+			return b, err
+		}
+		n.AsBigInt(e.x)
+		return o, nil
+	case StrType:
+		s, o, err := ReadStringBytes(b)
+		if err != nil {
+			return b, err
+		}
+		if err = n.setString(s); err != nil {
+			return b, err
+		}
+		return o, nil
 	default:
 		return b, TypeError{Method: IntType, Encoded: typ}
 	}
 }
 
+// setString sets the number to the base-10 integer in s.
+func (n *Number) setString(s string) error {
+	x, ok := new(mathbig.Int).SetString(s, 10)
+	if !ok {
+		return TypeError{Method: IntType, Encoded: StrType}
+	}
+	n.AsBigInt(x)
+	return nil
+}
+
 // MarshalMsg implements msgp.Marshaler.
 func (n *Number) MarshalMsg(b []byte) ([]byte, error) {
 	switch n.typ {
@@ -168,6 +248,8 @@ func (n *Number) MarshalMsg(b []byte) ([]byte, error) {
 		return AppendFloat64(b, math.Float64frombits(n.bits)), nil
 	case Float32Type:
 		return AppendFloat32(b, math.Float32frombits(uint32(n.bits))), nil
+	case ExtensionType:
+		return AppendExtension(b, &bigIntExt{n.bigInt})
 	default:
 		return AppendInt64(b, 0), nil
 	}
@@ -184,6 +266,8 @@ func (n *Number) EncodeMsg(w *Writer) error {
 		return w.WriteFloat64(math.Float64frombits(n.bits))
 	case Float32Type:
 		return w.WriteFloat32(math.Float32frombits(uint32(n.bits)))
+	case ExtensionType:
+		return w.WriteExtension(&bigIntExt{n.bigInt})
 	default:
 		return w.WriteInt64(0)
 	}
@@ -200,11 +284,55 @@ func (n *Number) Msgsize() int {
 		return Int64Size
 	case UintType:
 		return Uint64Size
+	case ExtensionType:
+		return ExtensionPrefixSize + (&bigIntExt{n.bigInt}).Len()
 	default:
 		return 1 // fixint(0)
 	}
 }
 
+// Clone returns a copy of n that does not share memory with n, as generated Clone methods do.
+func (n *Number) Clone() Number {
+	c := *n
+	if n.bigInt != nil {
+		c.bigInt = new(mathbig.Int).Set(n.bigInt)
+	}
+	return c
+}
+
+// Equal says if n and o hold the same type of number with the same value. Floats are compared
+// with the == operator, so a NaN is not equal to itself. The zero Number is equal to an int 0.
+func (n *Number) Equal(o Number) bool {
+	if n.Type() != o.Type() {
+		return false
+	}
+	switch n.typ {
+	case Float32Type:
+		return math.Float32frombits(uint32(n.bits)) == math.Float32frombits(uint32(o.bits))
+	case Float64Type:
+		return math.Float64frombits(n.bits) == math.Float64frombits(o.bits)
+	case ExtensionType:
+		if n.bigInt != nil && o.bigInt != nil {
+			return n.bigInt.Cmp(o.bigInt) == 0
+		}
+	}
+	return n.bits == o.bits && (n.bigInt == nil) == (o.bigInt == nil)
+}
+
+// MsgsizeExact implements msgp.ExactSizer.
+func (n *Number) MsgsizeExact() int {
+	switch n.typ {
+	case IntType:
+		return SizeOfInt64(int64(n.bits))
+	case UintType:
+		return SizeOfUint64(n.bits)
+	case ExtensionType:
+		return SizeOfExtension(&bigIntExt{n.bigInt})
+	default:
+		return n.Msgsize()
+	}
+}
+
 // MarshalJSON implements json.Marshaler.
 func (n *Number) MarshalJSON() ([]byte, error) {
 	t := n.Type()
@@ -222,6 +350,8 @@ func (n *Number) MarshalJSON() ([]byte, error) {
 	case UintType:
 		u, _ := n.Uint()
 		return strconv.AppendUint(out, u, 10), nil
+	case ExtensionType:
+		return n.bigInt.Append(out, 10), nil
 	default:
 		panic("(*Number).typ is invalid")
 	}
@@ -241,7 +371,63 @@ func (n *Number) String() string {
 	case UintType:
 		u, _ := n.Uint()
 		return strconv.FormatUint(u, 10)
+	case ExtensionType:
+		return n.bigInt.String()
 	default:
 		panic("(*Number).typ is invalid")
 	}
 }
+
+// AppendNumber appends n to b as a MessagePack number or, if n holds a big integer, as a
+// BigIntExtension extension.
+func AppendNumber(b []byte, n Number) []byte {
+	b, _ = n.MarshalMsg(b) // A Number never fails to marshal.
+	return b
+}
+
+// ReadNumberBytes reads a Number from b and returns the remaining bytes.
+func ReadNumberBytes(b []byte) (Number, []byte, error) {
+	var n Number
+	o, err := n.UnmarshalMsg(b)
+	return n, o, err
+}
+
+// WriteNumber writes n to the writer as a MessagePack number or, if n holds a big integer,
+// as a BigIntExtension extension.
+func (mw *Writer) WriteNumber(n Number) error { return n.EncodeMsg(mw) }
+
+// ReadNumber reads a Number from the reader.
+func (m *Reader) ReadNumber() (Number, error) {
+	var n Number
+	err := n.DecodeMsg(m)
+	return n, err
+}
+
+// bigIntExt is the Extension used to encode the integers that a Number cannot hold in 64 bits.
+type bigIntExt struct {
+	x *mathbig.Int
+}
+
+func (e *bigIntExt) ExtensionType() int8 { return BigIntExtension }
+
+func (e *bigIntExt) Len() int { return 1 + (e.x.BitLen()+7)/8 }
+
+func (e *bigIntExt) MarshalBinaryTo(b []byte) error {
+	b[0] = 0
+	if e.x.Sign() < 0 {
+		b[0] = 1
+	}
+	copy(b[1:], e.x.Bytes())
+	return nil
+}
+
+func (e *bigIntExt) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return ErrShortBytes
+	}
+	e.x.SetBytes(b[1:])
+	if b[0] == 1 {
+		e.x.Neg(e.x)
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package msgp
 
 import (
 	"bytes"
+	"math"
+	mathbig "math/big"
 	"testing"
 )
 
@@ -96,3 +98,127 @@ func TestNumber(t *testing.T) {
 	}
 
 }
+
+func TestNumberBig(t *testing.T) {
+
+	pos := new(mathbig.Int).Lsh(mathbig.NewInt(1), 100)
+	neg := new(mathbig.Int).Neg(new(mathbig.Int).Lsh(mathbig.NewInt(1), 70))
+
+	for _, x := range []*mathbig.Int{pos, neg} {
+		var n Number
+		n.AsBigInt(x)
+		if n.Type() != ExtensionType || n.String() != x.String() {
+			t.Errorf("for %s got type %s and string %q", x, n.Type(), n.String())
+		}
+
+		b := AppendNumber(nil, n)
+		if len(b) > n.Msgsize() {
+			t.Errorf("for %s: Msgsize is %d but encoded %d bytes", x, n.Msgsize(), len(b))
+		}
+		out, rest, err := ReadNumberBytes(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rest) != 0 {
+			t.Errorf("for %s: %d bytes left over", x, len(rest))
+		}
+		if got, ok := out.BigInt(); !ok || got.Cmp(x) != 0 {
+			t.Errorf("unmarshal: %s in; %s out", x, got)
+		}
+
+		var buf bytes.Buffer
+		wr := NewWriter(&buf)
+		if err = wr.WriteNumber(n); err != nil {
+			t.Fatal(err)
+		}
+		wr.Flush()
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Errorf("encode: expected output %#v; got %#v", b, buf.Bytes())
+		}
+		out, err = NewReader(&buf).ReadNumber()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := out.BigInt(); !ok || got.Cmp(x) != 0 {
+			t.Errorf("decode: %s in; %s out", x, got)
+		}
+	}
+
+	// Numbers may be given as strings.
+	const digits = "123456789012345678901234567890"
+	n, _, err := ReadNumberBytes(AppendString(nil, digits))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Type() != ExtensionType || n.String() != digits {
+		t.Errorf("got type %s and string %q from string %q", n.Type(), n.String(), digits)
+	}
+	n, err = NewReader(bytes.NewReader(AppendString(nil, "-42"))).ReadNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, ok := n.Int(); !ok || i != -42 || n.Type() != IntType {
+		t.Errorf("got %s of type %s from string \"-42\"", n.String(), n.Type())
+	}
+	if _, _, err = ReadNumberBytes(AppendString(nil, "1.5")); err == nil {
+		t.Error("expected an error decoding a number from string \"1.5\"")
+	}
+
+	n.AsFloat64(1.5)
+	if _, ok := n.BigInt(); ok {
+		t.Error("expected BigInt to fail for a float")
+	}
+
+}
+
+func TestNumberClone(t *testing.T) {
+	var n Number
+	n.AsBigInt(new(mathbig.Int).Lsh(mathbig.NewInt(3), 80))
+	c := n.Clone()
+	if c.bigInt == n.bigInt {
+		t.Error("the clone shares the big integer")
+	}
+	if c.Type() != ExtensionType || c.String() != n.String() {
+		t.Errorf("cloned %s as %s", n.String(), c.String())
+	}
+
+	n.AsFloat64(2.5)
+	if c = n.Clone(); c != n {
+		t.Errorf("cloned %s as %s", n.String(), c.String())
+	}
+}
+
+func TestNumberEqual(t *testing.T) {
+	var a, b Number
+	a.AsBigInt(new(mathbig.Int).Lsh(mathbig.NewInt(3), 80))
+	b.AsBigInt(new(mathbig.Int).Lsh(mathbig.NewInt(3), 80))
+	if !a.Equal(b) {
+		t.Errorf("%s is not equal to itself", a.String())
+	}
+	b.AsBigInt(new(mathbig.Int).Lsh(mathbig.NewInt(5), 80))
+	if a.Equal(b) {
+		t.Errorf("%s is equal to %s", a.String(), b.String())
+	}
+
+	a.AsInt(7)
+	b.AsUint(7)
+	if a.Equal(b) {
+		t.Error("an int is equal to a uint with the same value")
+	}
+	b.AsInt(7)
+	if !a.Equal(b) {
+		t.Error("7 is not equal to 7")
+	}
+
+	var zero Number
+	b.AsInt(0)
+	if !zero.Equal(b) {
+		t.Error("the zero Number is not equal to 0")
+	}
+
+	a.AsFloat64(math.NaN())
+	b.AsFloat64(math.NaN())
+	if a.Equal(b) {
+		t.Error("NaN is equal to NaN")
+	}
+}
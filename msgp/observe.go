@@ -0,0 +1,224 @@
+package msgp
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// An Observer can be set on a Reader or a Writer to be told about the composition of the
+// MessagePack objects that go through it.
+//
+// A Writer tells its Observer about objects as their bytes are flushed to the underlying
+// io.Writer. A Reader tells its Observer about objects as their bytes are read from the
+// underlying io.Reader, which may happen before the objects are decoded.
+type Observer interface {
+	// Value is called for every object in the stream, including each key and value in a map
+	// and each element of an array, with the object's type.
+	Value(t Type)
+
+	// Object is called for each complete top-level object with the object's type and the total
+	// number of bytes in its encoding.
+	Object(t Type, size int)
+}
+
+// SetObserver makes the Writer report the objects it writes to o. A nil o turns off reporting.
+// The Observer should be set between top-level objects; any data already buffered by the Writer
+// is not reported.
+func (mw *Writer) SetObserver(o Observer) {
+	mw.track = newTracker(o)
+	mw.scanned = mw.wLoc // Buffered data was written before the Observer was set.
+}
+
+// SetObserver makes the Reader report the objects it reads to o. A nil o turns off reporting.
+// The Reader must have been created by NewReader or NewReaderSize, and the Observer should be set
+// before anything is read; any data already buffered by the Reader is not reported.
+func (m *Reader) SetObserver(o Observer) {
+	if m.src != nil {
+		m.src.track = newTracker(o)
+	}
+}
+
+// A tracker follows a stream of MessagePack bytes, which it is given in order and in pieces of any
+// length, to report to an Observer the objects in the stream.
+type tracker struct {
+	obs   Observer
+	stack []uint64 // the number of objects not yet begun in each open map or array
+	top   Type     // the type of the current top-level object
+	size  int      // the number of bytes of the current top-level object seen so far
+	rest  uint64   // the number of bytes remaining in the current object
+	head  [6]byte  // the bytes of the current object's header seen so far
+	hn    int      // the number of bytes in head
+	bad   bool     // whether the stream has an invalid prefix
+}
+
+func newTracker(o Observer) *tracker {
+	if o == nil {
+		return nil
+	}
+	return &tracker{obs: o}
+}
+
+// Write implements io.Writer; it never returns an error.
+func (t *tracker) Write(p []byte) (int, error) {
+	l := len(p)
+	for len(p) > 0 && !t.bad {
+		if t.rest > 0 {
+			n := t.rest
+			if uint64(len(p)) < n {
+				n = uint64(len(p))
+			}
+			t.rest -= n
+			t.size += int(n)
+			p = p[n:]
+			if t.rest == 0 {
+				t.end()
+			}
+			continue
+		}
+		need := t.headerSize(p[0])
+		if need == 0 {
+			t.bad = true
+			break
+		}
+		n := copy(t.head[t.hn:need], p)
+		t.hn += n
+		t.size += n
+		p = p[n:]
+		if t.hn < need {
+			break
+		}
+		t.header()
+	}
+	return l, nil
+}
+
+// headerSize returns the number of bytes needed to know the type and size of the object starting
+// with the current header (or with b if the header is empty).
+func (t *tracker) headerSize(b byte) int {
+	if t.hn > 0 {
+		b = t.head[0]
+	}
+	spec := &sizes[b]
+	switch {
+	case spec.size == 0:
+		return 0
+	case spec.extra < 0:
+		return int(spec.size)
+	case spec.typ == ExtensionType:
+		return 2 // Include the extension type.
+	default:
+		return 1
+	}
+}
+
+// header handles a complete header in t.head.
+func (t *tracker) header() {
+	spec := &sizes[t.head[0]]
+	var size, objects uintptr
+	if spec.extra >= 0 {
+		size, objects = uintptr(spec.size), uintptr(spec.extra)
+	} else {
+		size, objects, _ = getSize(t.head[:t.hn])
+	}
+	typ := spec.typ
+	if typ == ExtensionType {
+		switch int8(t.head[t.hn-1]) {
+		case Complex64Extension:
+			typ = Complex64Type
+		case Complex128Extension:
+			typ = Complex128Type
+		case TimeExtension:
+			typ = TimeType
+		case Float16Extension:
+			typ = Float16Type
+		}
+	}
+	t.rest = uint64(size) - uint64(t.hn)
+	t.hn = 0
+
+	if len(t.stack) == 0 {
+		t.top = typ
+	} else {
+		t.stack[len(t.stack)-1]--
+	}
+	t.obs.Value(typ)
+	if objects > 0 {
+		t.stack = append(t.stack, uint64(objects))
+	} else if t.rest == 0 {
+		t.end()
+	}
+}
+
+// end is called when all of the bytes of an object that is not a map or an array have been seen.
+func (t *tracker) end() {
+	for len(t.stack) > 0 && t.stack[len(t.stack)-1] == 0 {
+		t.stack = t.stack[:len(t.stack)-1]
+	}
+	if len(t.stack) == 0 {
+		t.obs.Object(t.top, t.size)
+		t.size = 0
+	}
+}
+
+// countReader counts the bytes read from an io.Reader and passes them to a tracker if one is set.
+// The first error of the reader other than io.EOF is returned by every later read.
+type countReader struct {
+	r     io.Reader
+	n     int64
+	track *tracker
+	err   error
+}
+
+// newCountReader wraps r in a countReader, returning the reader to use in place of r
+// along with its countReader.
+func newCountReader(r io.Reader) (io.Reader, *countReader) {
+	if s, ok := r.(io.Seeker); ok {
+		crs := &countReadSeeker{countReader: countReader{r: r}, s: s}
+		return crs, &crs.countReader
+	}
+	c := &countReader{r: r}
+	return c, c
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.track != nil {
+		c.track.Write(p[:n])
+	}
+	if err != nil && err != io.EOF {
+		c.err = err
+	}
+	return n, err
+}
+
+// countReadSeeker is a countReader for an io.ReadSeeker so that the Reader using it can still
+// seek forward to skip objects.
+type countReadSeeker struct {
+	countReader
+	s io.Seeker
+}
+
+func (c *countReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if whence != io.SeekCurrent || offset < 0 {
+		return c.s.Seek(offset, whence)
+	}
+	if c.track != nil {
+		// The tracker must see every byte, so read instead of seeking.
+		if _, err := io.CopyN(ioutil.Discard, &c.countReader, offset); err != nil {
+			return 0, err
+		}
+		return c.s.Seek(0, io.SeekCurrent)
+	}
+	pos, err := c.s.Seek(offset, whence)
+	if err == nil {
+		c.n += offset
+	}
+	return pos, err
+}
@@ -0,0 +1,138 @@
+package msgp
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	typ  Type
+	size int
+}
+
+type testObserver struct {
+	values  []Type
+	objects []observation
+}
+
+func (o *testObserver) Value(t Type) { o.values = append(o.values, t) }
+
+func (o *testObserver) Object(t Type, size int) {
+	o.objects = append(o.objects, observation{t, size})
+}
+
+// observedStream returns a stream of a few objects along with what an Observer should be told.
+func observedStream() ([]byte, *testObserver) {
+	var b []byte
+	want := new(testObserver)
+	add := func(next []byte, t Type, values ...Type) {
+		want.objects = append(want.objects, observation{t, len(next) - len(b)})
+		want.values = append(want.values, values...)
+		b = next
+	}
+
+	next := AppendMapHeader(b, 2)
+	next = AppendString(next, "a")
+	next = AppendInt(next, 1)
+	next = AppendString(next, "b")
+	next = AppendArrayHeader(next, 3)
+	next = AppendFloat64(next, 1.5)
+	next = AppendMapHeader(next, 0)
+	next = AppendBytes(next, RandBytes(300))
+	add(next, MapType, MapType, StrType, IntType, StrType, ArrayType, Float64Type, MapType, BinType)
+
+	add(AppendUint64(b, tuint64), UintType, UintType)
+	add(AppendString(b, strings.Repeat("long", 100)), StrType, StrType)
+	add(AppendTime(b, time.Now()), TimeType, TimeType)
+	add(AppendComplex64(b, 2+3i), Complex64Type, Complex64Type)
+	add(AppendArrayHeader(b, 0), ArrayType, ArrayType)
+	add(AppendNil(b), NilType, NilType)
+
+	return b, want
+}
+
+func TestWriterObserver(t *testing.T) {
+	stream, want := observedStream()
+
+	var buf bytes.Buffer
+	wr := NewWriterSize(&buf, 32)
+	obs := new(testObserver)
+	wr.SetObserver(obs)
+
+	// Write the stream in chunks of varying sizes.
+	for i, p := 0, stream; len(p) > 0; i++ {
+		n := i%7 + 1
+		if i%5 == 0 {
+			n = 50
+		}
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := wr.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := wr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), stream) {
+		t.Fatal("wrong bytes written")
+	}
+	if !reflect.DeepEqual(obs.values, want.values) {
+		t.Errorf("got values %v; want %v", obs.values, want.values)
+	}
+	if !reflect.DeepEqual(obs.objects, want.objects) {
+		t.Errorf("got objects %v; want %v", obs.objects, want.objects)
+	}
+}
+
+func TestWriterObserverMethods(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	obs := new(testObserver)
+	wr.SetObserver(obs)
+
+	wr.WriteMapStrStr(map[string]string{"key": "value"})
+	wr.WriteString(strings.Repeat("x", 3000))
+	wr.WriteInt8(-5)
+	if err := wr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []observation{{MapType, 1 + 4 + 6}, {StrType, 3 + 3000}, {IntType, 1}}
+	if !reflect.DeepEqual(obs.objects, want) {
+		t.Errorf("got objects %v; want %v", obs.objects, want)
+	}
+}
+
+func TestReaderObserver(t *testing.T) {
+	stream, want := observedStream()
+
+	readers := map[string]func() *Reader{
+		"seeker": func() *Reader { return NewReaderSize(bytes.NewReader(stream), 16) },
+		"reader": func() *Reader { return NewReaderSize(bytes.NewBuffer(stream), 16) },
+	}
+	for name, newReader := range readers {
+		rd := newReader()
+		obs := new(testObserver)
+		rd.SetObserver(obs)
+
+		for range want.objects {
+			if err := rd.Skip(); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+		}
+
+		if !reflect.DeepEqual(obs.values, want.values) {
+			t.Errorf("%s: got values %v; want %v", name, obs.values, want.values)
+		}
+		if !reflect.DeepEqual(obs.objects, want.objects) {
+			t.Errorf("%s: got objects %v; want %v", name, obs.objects, want.objects)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package msgp
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A Padding is the layout of a string written as the data of a 'bin' object in the fixed-width or
+// zero-terminated form of the strings of C programs and embedded devices. The generated methods
+// write the string fields tagged with the width, pad, and zeroterm options this way.
+//
+// For example, "ok" is written with Padding{Width: 4, Pad: ' '} as the data "ok  ", with
+// Padding{ZeroTerm: true} as "ok\x00", and with Padding{Width: 4, ZeroTerm: true} as "ok\x00\x00".
+type Padding struct {
+	// Width, if positive, is the length of the data: the string is followed by Pad bytes up to
+	// Width bytes. A string longer than Width bytes is not written (see StringWidthError).
+	Width int
+
+	// Pad is the byte the string is padded with. Without ZeroTerm, the Pad bytes ending the data
+	// are trimmed off when it is read.
+	Pad byte
+
+	// ZeroTerm says if the string is followed by a zero byte, unless it takes all Width bytes
+	// (like a string copied with strncpy), and is read up to the first zero byte of the data. A
+	// string holding a zero byte is therefore read cut short.
+	ZeroTerm bool
+}
+
+// A StringWidthError is returned when writing a string longer than the Width of its Padding.
+type StringWidthError struct {
+	Width int // the Width of the Padding
+	Len   int // the length of the string
+}
+
+// Error implements the error interface.
+func (e StringWidthError) Error() string {
+	return fmt.Sprintf("msgp: string of %d bytes exceeds the padded width of %d bytes", e.Len, e.Width)
+}
+
+// Resumable is always true for StringWidthErrors: nothing is written.
+func (e StringWidthError) Resumable() bool { return true }
+
+// dataLen returns the length of the data of s written with p.
+func (p Padding) dataLen(s string) (int, error) {
+	if p.Width > 0 {
+		if len(s) > p.Width {
+			return 0, StringWidthError{Width: p.Width, Len: len(s)}
+		}
+		return p.Width, nil
+	}
+	if p.ZeroTerm {
+		return len(s) + 1, nil
+	}
+	return len(s), nil
+}
+
+// fill writes the padding following s to data, which is the length returned by dataLen.
+func (p Padding) fill(data []byte, s string) {
+	n := copy(data, s)
+	if p.ZeroTerm && n < len(data) {
+		data[n] = 0
+		n++
+	}
+	for i := n; i < len(data); i++ {
+		data[i] = p.Pad
+	}
+}
+
+// trim returns the string written with p as data.
+func (p Padding) trim(data []byte) string {
+	if p.ZeroTerm {
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			data = data[:i]
+		}
+		return string(data)
+	}
+	if p.Width > 0 {
+		n := len(data)
+		for n > 0 && data[n-1] == p.Pad {
+			n--
+		}
+		data = data[:n]
+	}
+	return string(data)
+}
+
+// WritePaddedString writes s as a 'bin' object laid out by p.
+func (mw *Writer) WritePaddedString(s string, p Padding) error {
+	n, err := p.dataLen(s)
+	if err != nil {
+		return err
+	}
+	if err = mw.WriteBytesHeader(uint32(n)); err != nil {
+		return err
+	}
+	if err = mw.writeString(s); err != nil {
+		return err
+	}
+	for i := len(s); i < n; i++ {
+		pad := p.Pad
+		if p.ZeroTerm && i == len(s) {
+			pad = 0
+		}
+		if err = mw.push(pad); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendPaddedString appends s to b as a 'bin' object laid out by p.
+func AppendPaddedString(b []byte, s string, p Padding) ([]byte, error) {
+	n, err := p.dataLen(s)
+	if err != nil {
+		return b, err
+	}
+	b, o := ensure(b, sizeOfBin(n))
+	switch h := sizeOfBin(n) - n; h {
+	case 2:
+		prefixu8(b[o:], mbin8, uint8(n))
+	case 3:
+		prefixu16(b[o:], mbin16, uint16(n))
+	default:
+		prefixu32(b[o:], mbin32, uint32(n))
+	}
+	p.fill(b[len(b)-n:], s)
+	return b, nil
+}
+
+// ReadPaddedString reads a 'bin' (or 'str') object laid out by p and returns the string in it.
+// The data may be of any length, whatever the Width of p.
+func (m *Reader) ReadPaddedString(p Padding) (string, error) {
+	n, skip, err := m.peekRawHeader(BinType)
+	if err != nil {
+		return "", err
+	}
+	m.R.Skip(skip)
+	data, err := m.R.Next(int(n))
+	if err != nil {
+		return "", err
+	}
+	return p.trim(data), nil
+}
+
+// ReadPaddedStringBytes reads a 'bin' (or 'str') object laid out by p from b and returns the
+// string in it and any remaining bytes. Possible errors are ErrShortBytes and TypeError.
+func ReadPaddedStringBytes(b []byte, p Padding) (string, []byte, error) {
+	data, o, err := readRawZC(b, BinType)
+	if err != nil {
+		return "", b, err
+	}
+	return p.trim(data), o, nil
+}
+
+// SizeOfPaddedString returns the encoded size of s laid out by p. The size of a string longer
+// than the Width of p is that of a string fitting it.
+func SizeOfPaddedString(s string, p Padding) int {
+	n, err := p.dataLen(s)
+	if err != nil {
+		n = p.Width
+	}
+	return sizeOfBin(n)
+}
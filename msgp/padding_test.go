@@ -0,0 +1,62 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPaddedString(t *testing.T) {
+	cases := []struct {
+		s    string
+		p    Padding
+		data string // the data of the 'bin' object
+		read string // the string read back, if not s
+	}{
+		{s: "ok", p: Padding{}, data: "ok"},
+		{s: "ok", p: Padding{Width: 4, Pad: ' '}, data: "ok  "},
+		{s: "ok", p: Padding{ZeroTerm: true}, data: "ok\x00"},
+		{s: "ok", p: Padding{Width: 4, ZeroTerm: true}, data: "ok\x00\x00"},
+		{s: "ok", p: Padding{Width: 5, Pad: '.', ZeroTerm: true}, data: "ok\x00.."},
+		{s: "full", p: Padding{Width: 4, ZeroTerm: true}, data: "full"},
+		{s: "a b ", p: Padding{Width: 6, Pad: ' '}, data: "a b   ", read: "a b"},
+		{s: "", p: Padding{Width: 300, Pad: '-'}, data: string(bytes.Repeat([]byte{'-'}, 300))},
+	}
+	for i, c := range cases {
+		want := AppendBytes(nil, []byte(c.data))
+		b, err := AppendPaddedString(nil, c.s, c.p)
+		if err != nil || !bytes.Equal(b, want) {
+			t.Errorf("case %d: AppendPaddedString = %x, %v; want %x", i, b, err, want)
+			continue
+		}
+		if n := SizeOfPaddedString(c.s, c.p); n != len(want) {
+			t.Errorf("case %d: SizeOfPaddedString = %d; want %d", i, n, len(want))
+		}
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if err = w.WritePaddedString(c.s, c.p); err != nil || w.Flush() != nil || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("case %d: WritePaddedString wrote %x, %v; want %x", i, buf.Bytes(), err, want)
+		}
+
+		read := c.s
+		if c.read != "" {
+			read = c.read
+		}
+		s, o, err := ReadPaddedStringBytes(b, c.p)
+		if err != nil || s != read || len(o) != 0 {
+			t.Errorf("case %d: ReadPaddedStringBytes = %q, %x, %v; want %q", i, s, o, err, read)
+		}
+		if s, err = NewReader(&buf).ReadPaddedString(c.p); err != nil || s != read {
+			t.Errorf("case %d: ReadPaddedString = %q, %v; want %q", i, s, err, read)
+		}
+	}
+
+	// Strings are read from 'str' objects too.
+	if s, _, err := ReadPaddedStringBytes(AppendString(nil, "id\x00xx"), Padding{ZeroTerm: true}); err != nil || s != "id" {
+		t.Errorf("read %q, %v from a 'str' object", s, err)
+	}
+
+	_, err := AppendPaddedString(nil, "toolong", Padding{Width: 4})
+	if e, ok := err.(StringWidthError); !ok || e.Width != 4 || e.Len != 7 || !e.Resumable() {
+		t.Errorf("writing a string wider than the padding returned %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package msgp
+
+import "math"
+
+// PatchInt64 overwrites in place the integer at path within the object at the start of b with v,
+// without encoding the object again, which suits counters kept in stored messages. The elements
+// of path are as in PathError.Path: the keys of map values (strings, or ints for maps with integer
+// keys) and the indexes of array elements (ints). An empty path patches the object itself.
+//
+// The integer keeps its encoded size, so v must fit in it: an IntOverflow is returned otherwise.
+// The integers written with WriteFixedInt64 and WriteFixedUint64 (as with the fixedints directive)
+// hold any value. A value not found is reported with ErrPathNotFound and a value that is not an
+// integer with a TypeError, wrapped in a PathError giving the path to the value. Nothing is
+// written to b if an error is returned.
+func PatchInt64(b []byte, v int64, path ...interface{}) error {
+	p, err := locatePath(b, path)
+	if err != nil {
+		return err
+	}
+	bits, err := patchInt(p, uint64(v), v < 0)
+	if err == nil && bits != 0 {
+		err = IntOverflow{Value: v, FailedBitsize: bits}
+	}
+	return wrapPath(err, path)
+}
+
+// PatchUint64 overwrites in place the integer at path within the object at the start of b with u,
+// like PatchInt64, returning a UintOverflow if u does not fit in the encoded size of the integer.
+func PatchUint64(b []byte, u uint64, path ...interface{}) error {
+	p, err := locatePath(b, path)
+	if err != nil {
+		return err
+	}
+	bits, err := patchInt(p, u, false)
+	if err == nil && bits != 0 {
+		err = UintOverflow{Value: u, FailedBitsize: bits}
+	}
+	return wrapPath(err, path)
+}
+
+// wrapPath wraps err in a PathError with the path, if the path is not empty.
+func wrapPath(err error, path []interface{}) error {
+	if len(path) == 0 {
+		return err
+	}
+	return WrapError(err, path...)
+}
+
+// locatePath returns the part of b beginning with the value at path within the object at the
+// start of b.
+func locatePath(b []byte, path []interface{}) ([]byte, error) {
+	for i, elem := range path {
+		var err error
+		if b, err = locateElem(b, elem); err != nil {
+			return nil, WrapError(err, path[:i+1]...)
+		}
+	}
+	return b, nil
+}
+
+// locateElem returns the part of b beginning with the value of the key or index elem within the
+// map or array at the start of b.
+func locateElem(b []byte, elem interface{}) ([]byte, error) {
+	switch t := NextType(b); {
+	case t == ArrayType:
+		idx, ok := elem.(int)
+		if !ok {
+			return nil, ErrPathNotFound
+		}
+		n, o, err := ReadArrayHeaderBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		if idx < 0 || uint64(idx) >= uint64(n) {
+			return nil, ErrPathNotFound
+		}
+		for ; idx > 0; idx-- {
+			if o, err = Skip(o); err != nil {
+				return nil, err
+			}
+		}
+		return o, nil
+	case t == MapType:
+		n, o, err := ReadMapHeaderBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < n; i++ {
+			match := keyIs(o, elem)
+			if o, err = Skip(o); err != nil {
+				return nil, err
+			}
+			if match {
+				return o, nil
+			}
+			if o, err = Skip(o); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrPathNotFound
+	case t == InvalidType && len(b) == 0:
+		return nil, ErrShortBytes
+	default:
+		if _, ok := elem.(int); ok {
+			return nil, TypeError{Method: ArrayType, Encoded: t}
+		}
+		return nil, TypeError{Method: MapType, Encoded: t}
+	}
+}
+
+// keyIs says if the map key at the start of b is elem: a string (encoded as a 'str' or 'bin'
+// object) or an int.
+func keyIs(b []byte, elem interface{}) bool {
+	switch elem := elem.(type) {
+	case string:
+		k, _, err := ReadMapKeyZC(b)
+		return err == nil && string(k) == elem
+	case int:
+		switch NextType(b) {
+		case IntType, UintType:
+			i, _, err := ReadInt64Bytes(b)
+			return err == nil && i == int64(elem)
+		}
+	}
+	return false
+}
+
+// patchInt overwrites the integer at the start of p with the integer whose bits are u (a negative
+// one if neg), encoded in the same number of bytes. If no encoding of that size holds the integer,
+// nothing is written and the number of bits of the largest integer that it holds is returned.
+func patchInt(p []byte, u uint64, neg bool) (int, error) {
+	if len(p) == 0 {
+		return 0, ErrShortBytes
+	}
+	lead := p[0]
+	if isfixint(lead) || isnfixint(lead) {
+		switch {
+		case !neg && u <= math.MaxInt8:
+			p[0] = wfixint(uint8(u))
+		case neg && int64(u) >= -32:
+			p[0] = wnfixint(int8(u))
+		default:
+			return 7, nil
+		}
+		return 0, nil
+	}
+	var size int
+	switch lead {
+	case mint8, muint8:
+		size = 1
+	case mint16, muint16:
+		size = 2
+	case mint32, muint32:
+		size = 4
+	case mint64, muint64:
+		size = 8
+	default:
+		return 0, TypeError{Method: IntType, Encoded: getType(lead)}
+	}
+	signed := lead == mint8 || lead == mint16 || lead == mint32 || lead == mint64
+	if len(p) < 1+size {
+		return 0, ErrShortBytes
+	}
+	bits := uint(8 * size)
+	fitsSigned := neg && int64(u) >= -1<<(bits-1) || !neg && u < 1<<(bits-1)
+	fitsUnsigned := !neg && (bits == 64 || u < 1<<bits)
+	switch {
+	case fitsSigned && (signed || !fitsUnsigned):
+		signed = true
+	case fitsUnsigned:
+		signed = false
+	default:
+		return int(bits), nil
+	}
+	switch size {
+	case 1:
+		p[0], p[1] = muint8, byte(u)
+		if signed {
+			p[0] = mint8
+		}
+	case 2:
+		p[0] = muint16
+		if signed {
+			p[0] = mint16
+		}
+		big.PutUint16(p[1:], uint16(u))
+	case 4:
+		p[0] = muint32
+		if signed {
+			p[0] = mint32
+		}
+		big.PutUint32(p[1:], uint32(u))
+	default:
+		p[0] = muint64
+		if signed {
+			p[0] = mint64
+		}
+		big.PutUint64(p[1:], u)
+	}
+	return 0, nil
+}
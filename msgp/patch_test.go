@@ -0,0 +1,97 @@
+package msgp
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPatchInt64(t *testing.T) {
+	var b []byte
+	b = AppendMapHeader(b, 3)
+	b = AppendString(b, "name")
+	b = AppendString(b, "counter")
+	b = AppendString(b, "hits")
+	b = AppendFixedInt64(b, 0)
+	b = AppendString(b, "stats")
+	b = AppendArrayHeader(b, 3)
+	b = AppendInt64(b, 5)     // a fixint
+	b = AppendInt64(b, -1000) // an int16
+	b = AppendMapHeader(b, 1) // a map with an integer key
+	b = AppendInt64(b, 7)
+	b = AppendFixedUint64(b, 10)
+	size := len(b)
+
+	cases := []struct {
+		v    int64
+		path []interface{}
+	}{
+		{math.MinInt64, []interface{}{"hits"}},
+		{math.MaxInt64, []interface{}{"hits"}},
+		{-32, []interface{}{"stats", 0}},
+		{127, []interface{}{"stats", 0}},
+		{65535, []interface{}{"stats", 1}},
+		{-32768, []interface{}{"stats", 1}},
+		{-1, []interface{}{"stats", 2, 7}},
+	}
+	for i, c := range cases {
+		if err := PatchInt64(b, c.v, c.path...); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if len(b) != size {
+			t.Fatalf("case %d: the message changed size", i)
+		}
+		var got []byte
+		var err error
+		if got, err = locatePath(b, c.path); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if v, _, err := ReadInt64Bytes(got); v != c.v || err != nil {
+			t.Errorf("case %d: read %d (%v) after patching %d", i, v, err, c.v)
+		}
+	}
+	if err := PatchUint64(b, math.MaxUint64, "hits"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _, err := ReadUint64Bytes(Locate("hits", b)); u != math.MaxUint64 || err != nil {
+		t.Errorf("read %d (%v) after patching the maximum uint64", u, err)
+	}
+
+	orig := append([]byte(nil), b...)
+	errCases := []struct {
+		v    int64
+		path []interface{}
+		want error
+	}{
+		{128, []interface{}{"stats", 0}, IntOverflow{Value: 128, FailedBitsize: 7}},
+		{-33, []interface{}{"stats", 0}, IntOverflow{Value: -33, FailedBitsize: 7}},
+		{1 << 16, []interface{}{"stats", 1}, IntOverflow{Value: 1 << 16, FailedBitsize: 16}},
+		{1, []interface{}{"misses"}, ErrPathNotFound},
+		{1, []interface{}{"stats", 3}, ErrPathNotFound},
+		{1, []interface{}{"stats", "x"}, ErrPathNotFound},
+		{1, []interface{}{"stats", 2, 8}, ErrPathNotFound},
+		{1, []interface{}{"name"}, TypeError{Method: IntType, Encoded: StrType}},
+		{1, []interface{}{"name", 0}, TypeError{Method: ArrayType, Encoded: StrType}},
+	}
+	for i, c := range errCases {
+		err := PatchInt64(b, c.v, c.path...)
+		var pe *PathError
+		if !errors.As(err, &pe) || pe.Err != c.want {
+			t.Errorf("case %d: returned %v; want %v", i, err, c.want)
+		}
+	}
+	if !bytes.Equal(b, orig) {
+		t.Error("the message was changed by a failed patch")
+	}
+
+	if err := PatchUint64(AppendInt8(nil, -100), 200); err != nil {
+		t.Errorf("patching an int8 with 200: %v", err)
+	}
+	if err := PatchUint64(AppendUint8(nil, 200), 1<<8); err != (UintOverflow{Value: 1 << 8, FailedBitsize: 8}) {
+		t.Errorf("patching a uint8 with 256 returned %v", err)
+	}
+	if err := PatchInt64(AppendUint16(nil, 1000), -1); err != nil {
+		t.Errorf("patching a uint16 with -1: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package msgp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sprint returns an indented, human-readable rendering of the MessagePack objects in b, one
+// after another on separate lines. Maps and arrays are rendered like JSON objects and arrays
+// (though map keys need not be strings), strings are quoted, and the other types are rendered
+// as follows:
+//
+//  nil          nil
+//  bin          bin(68656c6c6f)     (the bytes in hexadecimal)
+//  float        1.0                 (always with a decimal point or an exponent)
+//  complex      (1+2i)
+//  time         time(2018-05-17T20:32:04.5Z)
+//  extension    ext(42, 0a0b0c)     (the extension type and data)
+//
+// Unlike a translation to JSON, the rendering preserves the distinctions between the types.
+// If b is not valid MessagePack, the rendering of the part of b that could be read is followed
+// by the error.
+func Sprint(b []byte) string {
+	out, err := appendPrint(nil, b)
+	if err != nil {
+		out = append(out, "<error: "...)
+		out = append(out, err.Error()...)
+		out = append(out, '>')
+	}
+	return string(out)
+}
+
+// Fprint writes to w the rendering of the MessagePack objects in b that Sprint returns. If b is
+// not valid MessagePack, the rendering of the part of b that could be read is written, and the
+// error is returned.
+func Fprint(w io.Writer, b []byte) error {
+	out, err := appendPrint(nil, b)
+	if _, werr := w.Write(out); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// String returns the rendering of r produced by Sprint.
+func (r Raw) String() string { return Sprint(r) }
+
+// appendPrint appends the rendering of all of the objects in b to dst.
+func appendPrint(dst []byte, b []byte) ([]byte, error) {
+	var err error
+	for i := 0; len(b) > 0; i++ {
+		if i > 0 {
+			dst = append(dst, '\n')
+		}
+		if dst, b, err = appendPrintNext(dst, b, 0); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+// appendPrintNext appends the rendering of the next object in b, which is nested at the given
+// depth, and returns the remaining bytes.
+func appendPrintNext(dst []byte, b []byte, depth int) ([]byte, []byte, error) {
+	var err error
+	switch NextType(b) {
+	case InvalidType:
+		if len(b) == 0 {
+			return dst, b, ErrShortBytes
+		}
+		return dst, b, InvalidPrefixError(b[0])
+	case StrType:
+		var s []byte
+		if s, b, err = ReadStringZC(b); err == nil {
+			dst = strconv.AppendQuote(dst, string(s))
+		}
+	case BinType:
+		var v []byte
+		if v, b, err = ReadBytesZC(b); err == nil {
+			dst = append(dst, "bin("...)
+			dst = appendHex(dst, v)
+			dst = append(dst, ')')
+		}
+	case MapType:
+		return appendPrintMap(dst, b, depth)
+	case ArrayType:
+		return appendPrintArray(dst, b, depth)
+	case Float32Type:
+		var f float32
+		if f, b, err = ReadFloat32Bytes(b); err == nil {
+			dst = appendPrintFloat(dst, float64(f), 32)
+		}
+	case Float64Type:
+		var f float64
+		if f, b, err = ReadFloat64Bytes(b); err == nil {
+			dst = appendPrintFloat(dst, f, 64)
+		}
+	case Float16Type:
+		var f float32
+		if f, b, err = ReadFloat16Bytes(b); err == nil {
+			dst = appendPrintFloat(dst, float64(f), 32)
+		}
+	case BoolType:
+		var v bool
+		if v, b, err = ReadBoolBytes(b); err == nil {
+			dst = strconv.AppendBool(dst, v)
+		}
+	case IntType:
+		var i int64
+		if i, b, err = ReadInt64Bytes(b); err == nil {
+			dst = strconv.AppendInt(dst, i, 10)
+		}
+	case UintType:
+		var u uint64
+		if u, b, err = ReadUint64Bytes(b); err == nil {
+			dst = strconv.AppendUint(dst, u, 10)
+		}
+	case NilType:
+		if b, err = ReadNilBytes(b); err == nil {
+			dst = append(dst, "nil"...)
+		}
+	case Complex64Type:
+		var c complex64
+		if c, b, err = ReadComplex64Bytes(b); err == nil {
+			dst = append(dst, fmt.Sprint(c)...)
+		}
+	case Complex128Type:
+		var c complex128
+		if c, b, err = ReadComplex128Bytes(b); err == nil {
+			dst = append(dst, fmt.Sprint(c)...)
+		}
+	case TimeType:
+		var t time.Time
+		if t, b, err = ReadTimeBytes(b); err == nil {
+			dst = append(dst, "time("...)
+			dst = t.UTC().AppendFormat(dst, time.RFC3339Nano)
+			dst = append(dst, ')')
+		}
+	case ExtensionType:
+		var e RawExtension
+		if e.Type, err = peekExtension(b); err == nil {
+			if b, err = ReadExtensionBytes(b, &e); err == nil {
+				dst = append(dst, "ext("...)
+				dst = strconv.AppendInt(dst, int64(e.Type), 10)
+				dst = append(dst, ", "...)
+				dst = appendHex(dst, e.Data)
+				dst = append(dst, ')')
+			}
+		}
+	}
+	return dst, b, err
+}
+
+func appendPrintMap(dst []byte, b []byte, depth int) ([]byte, []byte, error) {
+	if depth >= MaxDepth {
+		return dst, b, ErrMaxDepthExceeded
+	}
+	sz, b, err := ReadMapHeaderBytes(b)
+	if err != nil {
+		return dst, b, err
+	}
+	dst = append(dst, '{')
+	for i := uint32(0); i < sz; i++ {
+		dst = appendPrintLine(dst, i > 0, depth+1)
+		if dst, b, err = appendPrintNext(dst, b, depth+1); err != nil {
+			return dst, b, err
+		}
+		dst = append(dst, ": "...)
+		if dst, b, err = appendPrintNext(dst, b, depth+1); err != nil {
+			return dst, b, err
+		}
+	}
+	if sz > 0 {
+		dst = appendPrintLine(dst, false, depth)
+	}
+	return append(dst, '}'), b, nil
+}
+
+func appendPrintArray(dst []byte, b []byte, depth int) ([]byte, []byte, error) {
+	if depth >= MaxDepth {
+		return dst, b, ErrMaxDepthExceeded
+	}
+	sz, b, err := ReadArrayHeaderBytes(b)
+	if err != nil {
+		return dst, b, err
+	}
+	dst = append(dst, '[')
+	for i := uint32(0); i < sz; i++ {
+		dst = appendPrintLine(dst, i > 0, depth+1)
+		if dst, b, err = appendPrintNext(dst, b, depth+1); err != nil {
+			return dst, b, err
+		}
+	}
+	if sz > 0 {
+		dst = appendPrintLine(dst, false, depth)
+	}
+	return append(dst, ']'), b, nil
+}
+
+// appendPrintLine ends the line (after a comma if comma is true) and indents the next line
+// to the given depth.
+func appendPrintLine(dst []byte, comma bool, depth int) []byte {
+	if comma {
+		dst = append(dst, ',')
+	}
+	dst = append(dst, '\n')
+	for i := 0; i < depth; i++ {
+		dst = append(dst, "  "...)
+	}
+	return dst
+}
+
+// appendPrintFloat appends f so that it cannot be mistaken for an integer.
+func appendPrintFloat(dst []byte, f float64, bits int) []byte {
+	n := len(dst)
+	dst = strconv.AppendFloat(dst, f, 'g', -1, bits)
+	if !strings.ContainsAny(string(dst[n:]), ".eIN") {
+		dst = append(dst, ".0"...)
+	}
+	return dst
+}
+
+func appendHex(dst []byte, b []byte) []byte {
+	for _, c := range b {
+		dst = append(dst, hex[c>>4], hex[c&0xf])
+	}
+	return dst
+}
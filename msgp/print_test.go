@@ -0,0 +1,79 @@
+package msgp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSprint(t *testing.T) {
+	b := AppendMapHeader(nil, 4)
+	b = AppendString(b, "name")
+	b = AppendString(b, "a \"b\"")
+	b = AppendString(b, "list")
+	b = AppendArrayHeader(b, 6)
+	b = AppendInt(b, -1)
+	b = AppendUint(b, 2)
+	b = AppendFloat64(b, 3)
+	b = AppendFloat32(b, 0.5)
+	b = AppendBool(b, true)
+	b = AppendNil(b)
+	b = AppendInt(b, 7) // an integer key
+	b = AppendMapHeader(b, 0)
+	b = AppendString(b, "other")
+	b = AppendArrayHeader(b, 4)
+	b = AppendBytes(b, []byte{0xca, 0xfe})
+	b = AppendComplex64(b, complex(1, 2))
+	b = AppendTime(b, time.Date(2018, 5, 17, 20, 32, 4, 500000000, time.UTC))
+	b, _ = AppendExtension(b, &RawExtension{Type: 42, Data: []byte{10, 11, 12}})
+	b = AppendArrayHeader(b, 0) // a second object
+
+	want := `{
+  "name": "a \"b\"",
+  "list": [
+    -1,
+    2,
+    3.0,
+    0.5,
+    true,
+    nil
+  ],
+  7: {},
+  "other": [
+    bin(cafe),
+    (1+2i),
+    time(2018-05-17T20:32:04.5Z),
+    ext(42, 0a0b0c)
+  ]
+}
+[]`
+	if got := Sprint(b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Raw(b).String(); got != want {
+		t.Errorf("Raw.String: got:\n%s\nwant:\n%s", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("Fprint: got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// The part before an error is rendered.
+	b = AppendArrayHeader(nil, 2)
+	b = AppendInt(b, 1)
+	buf.Reset()
+	if err := Fprint(&buf, b); err != ErrShortBytes {
+		t.Errorf("expected error %v; got %v", ErrShortBytes, err)
+	}
+	want = "[\n  1,\n  "
+	if buf.String() != want {
+		t.Errorf("Fprint: got %q; want %q", buf.String(), want)
+	}
+	if got := Sprint(b); got != want+"<error: "+ErrShortBytes.Error()+">" {
+		t.Errorf("Sprint: got %q", got)
+	}
+}
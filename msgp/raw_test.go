@@ -83,3 +83,76 @@ func TestRaw(t *testing.T) {
 		t.Fatal("value of output and input of MarshalMsg are not equal.")
 	}
 }
+
+func TestRawClone(t *testing.T) {
+	if Raw(nil).Clone() != nil {
+		t.Error("the clone of a nil Raw is not nil")
+	}
+	r := Raw(AppendString(nil, "raw"))
+	c := r.Clone()
+	if !bytes.Equal(c, r) {
+		t.Errorf("cloned %q as %q", r, c)
+	}
+	c[1] = 'R'
+	if r[1] != 'r' {
+		t.Error("the clone shares memory with the original")
+	}
+}
+
+func TestRawEqual(t *testing.T) {
+	r := Raw(AppendString(nil, "raw"))
+	if !r.Equal(r.Clone()) {
+		t.Error("a Raw is not equal to its clone")
+	}
+	if r.Equal(Raw(AppendString(nil, "Raw"))) {
+		t.Error("Raws with different bytes are equal")
+	}
+	if !Raw(nil).Equal(AppendNil(nil)) || Raw(nil).Equal(r) {
+		t.Error("an empty Raw is not equal to only a Raw holding nil")
+	}
+}
+
+func TestRawField(t *testing.T) {
+	obj := AppendMapStrStr(nil, map[string]string{"a": "b"})
+	for _, raw := range [][]byte{obj, nil} {
+		var buf bytes.Buffer
+		wr := NewWriter(&buf)
+		if err := wr.WriteRawField(raw); err != nil {
+			t.Fatal(err)
+		}
+		wr.Flush()
+		bts, err := AppendRawField(nil, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bts, buf.Bytes()) {
+			t.Errorf("AppendRawField gave %x; WriteRawField wrote %x", bts, buf.Bytes())
+		}
+		if len(raw) == 0 && !IsNil(bts) {
+			t.Errorf("an empty field was written as %x", bts)
+		}
+
+		scratch := make([]byte, 0, 64)
+		got, rest, err := ReadRawFieldBytes(AppendNil(bts), scratch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, raw) || !IsNil(rest) || len(rest) != 1 {
+			t.Errorf("ReadRawFieldBytes gave %x with %x left; want %x", got, rest, raw)
+		}
+		if len(raw) > 0 && &got[0] != &scratch[:1][0] {
+			t.Error("ReadRawFieldBytes did not reuse the memory of raw")
+		}
+		got, err = NewReader(bytes.NewReader(bts)).ReadRawField(nil)
+		if err != nil || !bytes.Equal(got, raw) {
+			t.Errorf("ReadRawField gave %x, %v; want %x", got, err, raw)
+		}
+	}
+
+	if _, err := AppendRawField(nil, append(obj, obj...)); err != ErrTrailingBytes {
+		t.Errorf("appending two objects gave the error %v", err)
+	}
+	if err := NewWriter(Nowhere).WriteRawField(obj[:2]); err != ErrShortBytes {
+		t.Errorf("writing a cut object gave the error %v", err)
+	}
+}
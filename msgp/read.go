@@ -1,8 +1,13 @@
 package msgp
 
 import (
+	"bufio"
+	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/philhofer/fwd"
@@ -36,6 +41,7 @@ const (
 	Complex64Type
 	Complex128Type
 	TimeType
+	Float16Type
 )
 
 // String implements fmt.Stringer
@@ -86,15 +92,78 @@ func Decode(r io.Reader, d Decoder) error {
 	return d.DecodeMsg(rd)
 }
 
+// DecodeContext is like Decode except that decoding is aborted (with the error returned by
+// ctx.Err()) at the next map or array if ctx is done. See Reader.SetContext.
+func DecodeContext(ctx context.Context, r io.Reader, d Decoder) error {
+	rd := NewReader(r)
+	rd.SetContext(ctx)
+	return d.DecodeMsg(rd)
+}
+
+// DecodeEach reads a MessagePack array from r and decodes its elements one at a time, each into a
+// new Decoder returned by factory, calling fn with each decoded element. Only one element is held
+// in memory at a time, so DecodeEach can process arrays too big to decode into a single slice.
+// If fn returns an error, DecodeEach stops and returns that error.
+func DecodeEach(r *Reader, fn func(Decoder) error, factory func() Decoder) error {
+	sz, err := r.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < sz; i++ {
+		if err = r.ctxErr(); err != nil {
+			return err
+		}
+		d := factory()
+		if err = d.DecodeMsg(r); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if err = fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeStream is like DecodeEach except that it reads a stream of concatenated MessagePack objects
+// (not wrapped in an array) until r reaches io.EOF. Reaching io.EOF between objects is not an error,
+// but reaching it within an object causes io.ErrUnexpectedEOF to be returned.
+func DecodeStream(r *Reader, fn func(Decoder) error, factory func() Decoder) error {
+	for {
+		if _, err := r.R.Peek(1); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := r.ctxErr(); err != nil {
+			return err
+		}
+		d := factory()
+		if err := d.DecodeMsg(r); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+}
+
 // NewReader returns a *Reader that reads from the provided reader. The reader will be buffered.
 func NewReader(r io.Reader) *Reader {
-	return &Reader{R: fwd.NewReader(r)}
+	r, src := newCountReader(r)
+	return &Reader{R: fwd.NewReader(r), src: src}
 }
 
 // NewReaderSize returns a *Reader with a buffer of the given size. (This is vastly preferable
 // to passing the decoder a reader that is already buffered.)
 func NewReaderSize(r io.Reader, sz int) *Reader {
-	return &Reader{R: fwd.NewReaderSize(r, sz)}
+	r, src := newCountReader(r)
+	return &Reader{R: fwd.NewReaderSize(r, sz), src: src}
 }
 
 // Reader wraps an io.Reader and provides methods to read MessagePack-encoded values from it.
@@ -103,6 +172,82 @@ type Reader struct {
 	// R is the buffered reader used to decode MessagePack. Don't use it directly.
 	R       *fwd.Reader
 	scratch []byte
+	src     *countReader      // wraps the reader underlying R if the Reader was made by NewReader(Size)
+	base    int64             // the number of bytes consumed as of the last ResetCount
+	depth   int               // the maximum nesting depth (MaxDepth if zero)
+	frame   int               // the maximum length of frames (MaxFrameSize if zero)
+	ctx     context.Context   // checked at each map and array (nil if none)
+	strs    map[string]string // the interned strings (nil if interning is off)
+	keys    map[string]string // the map keys interned by ReadMapKeyStringInterned
+	exact   bool              // ReadIntf returns integers as the types of their encoded widths
+	oldSpec bool              // 'str' and 'bin' objects are read interchangeably
+	intBool bool              // ReadBool accepts the integers 0 and 1
+}
+
+// MaxDepth is the limit on the depth to which maps and arrays may be nested in the objects read
+// by the functions and methods that read objects of any type (such as ReadIntf, Skip, and the
+// JSON translation functions). Reading a map or array nested more deeply (at depth MaxDepth,
+// counting from zero for the top-level object) fails with ErrMaxDepthExceeded. The limit prevents
+// maliciously deep input from exhausting the stack. Readers can be given their own limit with
+// SetMaxDepth.
+var MaxDepth = 512
+
+// SetMaxDepth sets the limit on the nesting depth of the maps and arrays read by m (see MaxDepth).
+// A depth of zero or less makes m use MaxDepth.
+func (m *Reader) SetMaxDepth(depth int) {
+	if depth < 0 {
+		depth = 0
+	}
+	m.depth = depth
+}
+
+// maxDepth returns the limit on the nesting depth of maps and arrays read by m.
+func (m *Reader) maxDepth() int {
+	if m.depth > 0 {
+		return m.depth
+	}
+	return MaxDepth
+}
+
+// SetMaxFrameSize sets the limit on the length of the frames read from m by ReadFramePrefix (see
+// MaxFrameSize). A size of zero or less makes m use MaxFrameSize.
+func (m *Reader) SetMaxFrameSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	m.frame = size
+}
+
+// maxFrameSize returns the limit on the length of the frames read from m.
+func (m *Reader) maxFrameSize() int {
+	if m.frame > 0 {
+		return m.frame
+	}
+	return MaxFrameSize
+}
+
+// SetContext makes m check ctx whenever it reads a map or array header and before each element
+// of the maps and arrays it skips or reads as a whole (as with ReadIntf and DecodeEach). Once ctx
+// is done, reading a map or array fails with the error returned by ctx.Err(). Because the generated
+// DecodeMsg methods read the headers of the maps and arrays they decode, a long-running decode
+// can be aborted by canceling ctx. The cancellation takes effect only at those points: the
+// generated methods do not check ctx between the elements of the maps, arrays, and structs they
+// decode, and no other reads (of strings or numbers, for example) check ctx, so a decode still
+// runs until it reaches the next map or array header, and one blocked reading the underlying
+// io.Reader is not interrupted. A nil ctx turns the checks off.
+func (m *Reader) SetContext(ctx context.Context) { m.ctx = ctx }
+
+// ctxErr returns the error of the context set with SetContext if the context is done.
+func (m *Reader) ctxErr() error {
+	if m.ctx == nil {
+		return nil
+	}
+	select {
+	case <-m.ctx.Done():
+		return m.ctx.Err()
+	default:
+		return nil
+	}
 }
 
 // Read implements io.Reader.
@@ -110,6 +255,12 @@ func (m *Reader) Read(p []byte) (int, error) {
 	return m.R.Read(p)
 }
 
+// WriteTo implements io.WriterTo. It writes all of the remaining data from m (the buffered data
+// and then the rest of the underlying reader, until io.EOF) to w as is.
+func (m *Reader) WriteTo(w io.Writer) (int64, error) {
+	return m.R.WriteTo(w)
+}
+
 // CopyNext reads the next object from m without decoding it and writes it to w.
 // It avoids unnecessary copies internally.
 func (m *Reader) CopyNext(w io.Writer) (int64, error) {
@@ -158,13 +309,183 @@ func (m *Reader) CopyNext(w io.Writer) (int64, error) {
 	return n, nil
 }
 
+// CopyNextFiltered reads the next object from m and writes it to w like CopyNext, except that the
+// entries of the maps within the object (at any depth) for which filter returns false are dropped,
+// as a proxy redacting fields does. The filter is called for each entry with the path of keys to
+// the entry's value from the object, ending with the entry's key, and the type of the value; the
+// indexes of array elements are in the path too, but array elements are not filtered. Keys that
+// are not strings are put in the path formatted as fmt.Sprint formats their values. The path is
+// reused between calls, so filter must not keep it.
+//
+// The object is written after it is read, since the headers of the maps must have the numbers of
+// entries kept, so it is held in memory.
+func (m *Reader) CopyNextFiltered(w io.Writer, filter func(path []string, typ Type) bool) (int64, error) {
+	o, err := m.appendFiltered(nil, nil, filter, 0)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(o)
+	return int64(n), err
+}
+
+// appendFiltered appends to o the next object, which is nested at the given depth under the path,
+// without the map entries that filter rejects.
+func (m *Reader) appendFiltered(o []byte, path []string, filter func([]string, Type) bool, depth int) ([]byte, error) {
+	typ, err := m.NextType()
+	if err != nil {
+		return o, err
+	}
+	switch typ {
+	case MapType:
+		if depth >= m.maxDepth() {
+			return o, ErrMaxDepthExceeded
+		}
+		sz, err := m.ReadMapHeader()
+		if err != nil {
+			return o, err
+		}
+		start := len(o)
+		var kept uint32
+		for i := uint32(0); i < sz; i++ {
+			if err = m.ctxErr(); err != nil {
+				return o, err
+			}
+			kt, err := m.NextType()
+			if err != nil {
+				return o, err
+			}
+			if kt == MapType || kt == ArrayType {
+				return o, TypeError{Method: StrType, Encoded: kt}
+			}
+			keyStart := len(o)
+			if o, err = m.appendRaw(o); err != nil {
+				return o, err
+			}
+			key, err := keyName(o[keyStart:])
+			if err != nil {
+				return o, err
+			}
+			vt, err := m.NextType()
+			if err != nil {
+				return o, err
+			}
+			p := append(path, key)
+			if !filter(p, vt) {
+				o = o[:keyStart]
+				if err = m.skip(depth + 1); err != nil {
+					return o, err
+				}
+				continue
+			}
+			kept++
+			if o, err = m.appendFiltered(o, p, filter, depth+1); err != nil {
+				return o, err
+			}
+		}
+		// Put the header with the number of entries kept before the entries.
+		hdr := AppendMapHeader(nil, kept)
+		end := len(o)
+		o = append(o, hdr...)
+		copy(o[start+len(hdr):], o[start:end])
+		copy(o[start:], hdr)
+		return o, nil
+	case ArrayType:
+		if depth >= m.maxDepth() {
+			return o, ErrMaxDepthExceeded
+		}
+		sz, err := m.ReadArrayHeader()
+		if err != nil {
+			return o, err
+		}
+		o = AppendArrayHeader(o, sz)
+		for i := uint32(0); i < sz; i++ {
+			if err = m.ctxErr(); err != nil {
+				return o, err
+			}
+			if o, err = m.appendFiltered(o, append(path, strconv.FormatUint(uint64(i), 10)), filter, depth+1); err != nil {
+				return o, err
+			}
+		}
+		return o, nil
+	default:
+		return m.appendRaw(o)
+	}
+}
+
+// appendRaw appends to o the next object, which is not a map or an array, as it is.
+func (m *Reader) appendRaw(o []byte) ([]byte, error) {
+	sz, _, err := getNextSize(m.R)
+	if err != nil {
+		return o, err
+	}
+	o, n := ensure(o, int(sz))
+	if _, err = m.R.ReadFull(o[n:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = ErrShortBytes
+		}
+		return o[:n], err
+	}
+	return o, nil
+}
+
+// keyName returns the name in a path of the encoded map key b.
+func keyName(b []byte) (string, error) {
+	switch NextType(b) {
+	case StrType, BinType:
+		k, _, err := ReadMapKeyZC(b)
+		return string(k), err
+	}
+	v, _, err := ReadIntfBytes(b)
+	return fmt.Sprint(v), err
+}
+
 // ReadFull implements io.ReadFull.
 func (m *Reader) ReadFull(p []byte) (int, error) {
 	return m.R.ReadFull(p)
 }
 
 // Reset resets the underlying reader.
-func (m *Reader) Reset(r io.Reader) { m.R.Reset(r) }
+func (m *Reader) Reset(r io.Reader) {
+	if m.src == nil {
+		m.R.Reset(r)
+		return
+	}
+	var track *tracker
+	if m.src.track != nil {
+		track = newTracker(m.src.track.obs)
+	}
+	r, m.src = newCountReader(r)
+	m.src.track = track
+	m.base = 0
+	m.R.Reset(r)
+}
+
+// Count returns the number of bytes consumed from the Reader (not including the bytes in the
+// read buffer that have not been consumed) since the Reader was created or last reset. Count
+// always returns zero if the Reader was not created by NewReader or NewReaderSize.
+func (m *Reader) Count() int64 {
+	if m.src == nil {
+		return 0
+	}
+	return m.src.n - int64(m.R.Buffered()) - m.base
+}
+
+// ResetCount sets the count returned by Count to zero.
+func (m *Reader) ResetCount() {
+	if m.src != nil {
+		m.base = m.src.n - int64(m.R.Buffered())
+	}
+}
+
+// Err returns the first error other than io.EOF of the underlying reader, which every read needing
+// more than the data buffered at the time of the error has returned since, or nil if there has been
+// none (or the Reader was not made by NewReader or NewReaderSize). Reset clears it.
+func (m *Reader) Err() error {
+	if m.src == nil {
+		return nil
+	}
+	return m.src.err
+}
 
 // Buffered returns the number of bytes currently in the read buffer.
 func (m *Reader) Buffered() int { return m.R.Buffered() }
@@ -194,17 +515,64 @@ func (m *Reader) NextType() (Type, error) {
 			return Complex128Type, nil
 		case TimeExtension:
 			return TimeType, nil
+		case Float16Extension:
+			return Float16Type, nil
 		}
 	}
 	return t, nil
 }
 
+// NextHeader returns the type of the next object along with its length and, if the object is an
+// extension, its extension type, without consuming anything. The length of a map is its number of
+// key-value pairs, and the length of an array its number of elements; the length of a string, a
+// bin, or an extension is the number of bytes of its data. Other objects have length 0. The type
+// is reported as by NextType.
+func (m *Reader) NextHeader() (t Type, length uint32, extType int8, err error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return InvalidType, 0, 0, err
+	}
+	if getType(p[0]) == InvalidType {
+		return InvalidType, 0, 0, InvalidPrefixError(p[0])
+	}
+	p, err = m.R.Peek(headerSize(p[0]))
+	if err != nil {
+		return InvalidType, 0, 0, err
+	}
+	t, length, extType = parseHeader(p)
+	return t, length, extType, nil
+}
+
+// More says if another object can be read from m. It returns false at the end of the stream and
+// when reading from the underlying reader fails, in which case the next read returns the error.
+// With More, a stream of concatenated objects can be read like a stream of JSON values with a
+// json.Decoder (see also Stream).
+func (m *Reader) More() bool {
+	_, err := m.R.Peek(1)
+	return err == nil
+}
+
 // IsNil says whether or not the next byte is a nil MessagePack byte (0xc0).
 func (m *Reader) IsNil() bool {
 	p, err := m.R.Peek(1)
 	return err == nil && p[0] == mnil
 }
 
+// PeekN returns the next n bytes without consuming them, reading from the underlying reader as
+// needed, so that the start of a stream can be inspected before it is decoded: for example, to
+// tell MessagePack from JSON or one version of a protocol from another. The buffer is grown if n
+// is larger than BufferSize. The bytes are only valid until the next call to a method of m.
+//
+// If the stream ends or reading fails before n bytes are buffered, PeekN returns the bytes
+// buffered with the error (io.EOF at the end of the stream). Nothing is ever unread, so peeking
+// is the way to look ahead.
+func (m *Reader) PeekN(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, bufio.ErrNegativeCount
+	}
+	return m.R.Peek(n)
+}
+
 // getNextSize returns the size of the next object on the wire.
 // returns (obj size, obj elements, error) only maps and arrays have non-zero obj elements.
 // For maps and arrays, obj size does not include elements.
@@ -250,7 +618,28 @@ func getNextSize(r *fwd.Reader) (uintptr, uintptr, error) {
 
 // Skip skips over the next object, regardless of its type. If it is an array
 // or map, the whole array or map will be skipped.
-func (m *Reader) Skip() error {
+func (m *Reader) Skip() error { return m.skip(0) }
+
+// SkipN skips over the next n objects, as n calls to Skip would.
+func (m *Reader) SkipN(n int) error {
+	for ; n > 0; n-- {
+		if err := m.skip(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip skips the next object, which is nested at the given depth.
+func (m *Reader) skip(depth int) error {
+	_, err := m.skipSize(depth, false)
+	return err
+}
+
+// skipSize skips the next object, which is nested at the given depth, and returns its size. With
+// read set, the data not buffered is read rather than skipped by seeking the underlying reader,
+// so that an object cut short is detected.
+func (m *Reader) skipSize(depth int, read bool) (int64, error) {
 
 	var v, o uintptr // v is number of bytes, o is number of objects
 
@@ -258,41 +647,84 @@ func (m *Reader) Skip() error {
 	if m.R.Buffered() >= 5 {
 		p, err := m.R.Peek(5)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		v, o, err = getSize(p)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	} else {
 		var err error
 		v, o, err = getNextSize(m.R)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 
+	if o > 0 && depth >= m.maxDepth() {
+		return 0, ErrMaxDepthExceeded
+	}
+
 	// v is always non-zero if err == nil
-	_, err := m.R.Skip(int(v))
+	var err error
+	if read && int(v) > m.R.Buffered() {
+		_, err = io.CopyN(ioutil.Discard, m.R, int64(v))
+	} else {
+		_, err = m.R.Skip(int(v))
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
+	size := int64(v)
 
 	// for maps and slices, skip elements
 	for x := uintptr(0); x < o; x++ {
-		err = m.Skip()
+		if err = m.ctxErr(); err != nil {
+			return size, err
+		}
+		n, err := m.skipSize(depth+1, read)
+		size += n
 		if err != nil {
-			return err
+			return size, err
 		}
 	}
 
-	return nil
+	return size, nil
 
 }
 
+// Validate reads the objects left in m up to the end of its data, checking their structure like
+// the function Validate does, and returns the number of objects and the number of bytes they
+// take. The values are skipped without being decoded, so a malformed stream (such as the frame
+// of a proxy or queue) is rejected cheaply. On an error, the counts are those of the objects
+// before the malformed one; an object cut short by the end of the data is reported with
+// io.ErrUnexpectedEOF.
+func (m *Reader) Validate() (objects int, size int64, err error) {
+	for {
+		if _, err = m.R.Peek(1); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		n, err := m.skipSize(0, true)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return objects, size, err
+		}
+		objects++
+		size += n
+	}
+}
+
 // ReadMapHeader reads the next object as a map header and returns the size of the map.
 // A TypeError{} is returned if the next object is not a map.
 func (m *Reader) ReadMapHeader() (uint32, error) {
+	if err := m.ctxErr(); err != nil {
+		return 0, err
+	}
 	p, err := m.R.Peek(1)
 	if err != nil {
 		return 0, err
@@ -377,8 +809,42 @@ func (m *Reader) ReadMapKeyPtr() ([]byte, error) {
 	return m.R.Next(read)
 }
 
+// ReadMapKeyStringInterned reads a map key, a 'str' or 'bin' object, as a string. The short keys
+// are kept in a table of m (separate from the strings of SetStringInterning, which need not be on),
+// so that a key read before is returned without being allocated again. The generated DecodeMsg
+// methods read the keys of maps of strings with it, as the same keys tend to recur in every
+// message. Like the interned strings, the keys are kept even if m is Reset.
+func (m *Reader) ReadMapKeyStringInterned() (string, error) {
+	n, skip, err := m.peekRawHeader(StrType)
+	if err != nil {
+		return "", err
+	}
+	m.R.Skip(skip)
+	if n > internMaxLen || int(n) > m.R.BufferSize() {
+		return m.readStringData(n)
+	}
+	p, err := m.R.Next(int(n))
+	if err != nil {
+		return "", err
+	}
+	if s, ok := m.keys[string(p)]; ok {
+		return s, nil
+	}
+	s := string(p)
+	if m.keys == nil {
+		m.keys = make(map[string]string)
+	}
+	if len(m.keys) < internMaxKeys {
+		m.keys[s] = s
+	}
+	return s, nil
+}
+
 // ReadArrayHeader reads the next object as an array header and returns the size of the array.
 func (m *Reader) ReadArrayHeader() (uint32, error) {
+	if err := m.ctxErr(); err != nil {
+		return 0, err
+	}
 	p, err := m.R.Peek(1)
 	if err != nil {
 		return 0, err
@@ -406,6 +872,27 @@ func (m *Reader) ReadArrayHeader() (uint32, error) {
 	}
 }
 
+// ReadArrayBody reads an array header and then calls fn once for each element of the array, which
+// fn must read (or skip) from m. If fn returns an error, ReadArrayBody stops and returns that error.
+func (m *Reader) ReadArrayBody(fn func(*Reader) error) error {
+	sz, err := m.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < sz; i++ {
+		if err = m.ctxErr(); err != nil {
+			return err
+		}
+		if err = fn(m); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 // ReadNil reads a 'nil' MessagePack byte from the reader.
 func (m *Reader) ReadNil() error {
 	p, err := m.R.Peek(1)
@@ -441,6 +928,25 @@ func (m *Reader) ReadFloat64() (float64, error) {
 	return math.Float64frombits(getMuint64(p)), err
 }
 
+// ReadFloat64Lenient reads a float64 like ReadFloat64 but also accepts an integer, converted to the
+// nearest float64, as written for whole numbers by the producers (such as many in Python) that do
+// not keep the types of numbers.
+func (m *Reader) ReadFloat64Lenient() (float64, error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	switch getType(p[0]) {
+	case IntType:
+		i, err := m.ReadInt64()
+		return float64(i), err
+	case UintType:
+		u, err := m.ReadUint64()
+		return float64(u), err
+	}
+	return m.ReadFloat64()
+}
+
 // ReadFloat32 reads a float32 from the reader.
 func (m *Reader) ReadFloat32() (float32, error) {
 	p, err := m.R.Peek(5)
@@ -461,12 +967,48 @@ func (m *Reader) ReadBool() (bool, error) {
 		return false, err
 	}
 	if p[0] != mtrue && p[0] != mfalse {
+		if m.intBool {
+			return m.ReadBoolLenient()
+		}
 		return false, badPrefix(BoolType, p[0])
 	}
 	_, err = m.R.Skip(1)
 	return p[0] == mtrue, err
 }
 
+// ReadBoolLenient reads a bool like ReadBool but also accepts the integers 0 and 1 (of any width),
+// as written for bools by many producers in C. Other integers are reported with a TypeError.
+func (m *Reader) ReadBoolLenient() (bool, error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return false, err
+	}
+	lead := p[0]
+	switch lead {
+	case mtrue, mfalse:
+		_, err = m.R.Skip(1)
+		return lead == mtrue, err
+	}
+	if t := getType(lead); t != IntType && t != UintType {
+		return false, badPrefix(BoolType, lead)
+	}
+	p, err = m.R.Peek(int(sizes[lead].size))
+	if err != nil {
+		return false, err
+	}
+	v, n, err := intBool(p)
+	if err != nil {
+		return false, err
+	}
+	_, err = m.R.Skip(n)
+	return v, err
+}
+
+// SetLenientBools sets whether ReadBool (and so the generated DecodeMsg methods) accepts the
+// integers 0 and 1 as bools, as ReadBoolLenient does. The code generated with -lenientbools reads
+// them whether or not this is set.
+func (m *Reader) SetLenientBools(on bool) { m.intBool = on }
+
 // ReadInt64 reads an int64 from the reader. If an int64 is not available, this function tries to read
 // an unsigned integer and convert it to an int64 if possible. Errors that can be returned include
 // UintOverflow and TypeError.
@@ -680,10 +1222,22 @@ func (m *Reader) ReadByte() (byte, error) {
 	return byte(in), err
 }
 
+// ReadRawField reads the next object as it is encoded, for a field with the raw option. The object
+// is appended to raw[:0]; a nil object is read as raw[:0], so that the field is encoded as nil again.
+func (m *Reader) ReadRawField(raw []byte) ([]byte, error) {
+	if m.IsNil() {
+		return raw[:0], m.ReadNil()
+	}
+	r := Raw(raw)
+	err := r.DecodeMsg(m)
+	return r, err
+}
+
 // ReadBytes reads a MessagePack 'bin' object from the reader and returns its value.
 // The scratch slice will be used for storage if it is not nil and large enough.
+// With SetOldSpec(true), ReadBytes reads 'str' objects as well.
 func (m *Reader) ReadBytes(scratch []byte) ([]byte, error) {
-	p, err := m.R.Peek(2)
+	p, err := m.R.Peek(1)
 	if err != nil {
 		return nil, err
 	}
@@ -691,8 +1245,11 @@ func (m *Reader) ReadBytes(scratch []byte) ([]byte, error) {
 	var dataLen int64
 	switch lead {
 	case mbin8:
+		p, err = m.R.Next(2)
+		if err != nil {
+			return nil, err
+		}
 		dataLen = int64(p[1])
-		m.R.Skip(2)
 	case mbin16:
 		p, err = m.R.Next(3)
 		if err != nil {
@@ -706,6 +1263,9 @@ func (m *Reader) ReadBytes(scratch []byte) ([]byte, error) {
 		}
 		dataLen = int64(big.Uint32(p[1:]))
 	default:
+		if m.oldSpec {
+			return m.ReadBytesOldSpec(scratch)
+		}
 		return nil, badPrefix(BinType, lead)
 	}
 	var b []byte
@@ -751,8 +1311,9 @@ func (m *Reader) ReadBytesHeader() (uint32, error) {
 
 // ReadExactBytes reads a MessagePack 'bin'-encoded object off of the wire into the provided slice.
 // An ArrayError will be returned if the object is not exactly the length of the input slice.
+// With SetOldSpec(true), ReadExactBytes reads 'str' objects as well.
 func (m *Reader) ReadExactBytes(into []byte) error {
-	p, err := m.R.Peek(2)
+	p, err := m.R.Peek(1)
 	if err != nil {
 		return err
 	}
@@ -760,6 +1321,10 @@ func (m *Reader) ReadExactBytes(into []byte) error {
 	var skip int   // prefix size to skip
 	switch lead := p[0]; lead {
 	case mbin8:
+		p, err = m.R.Peek(2)
+		if err != nil {
+			return err
+		}
 		read = int64(p[1])
 		skip = 2
 	case mbin16:
@@ -777,6 +1342,9 @@ func (m *Reader) ReadExactBytes(into []byte) error {
 		read = int64(big.Uint32(p[1:]))
 		skip = 5
 	default:
+		if m.oldSpec {
+			return m.ReadExactBytesOldSpec(into)
+		}
 		return badPrefix(BinType, lead)
 	}
 	if read != int64(len(into)) {
@@ -789,6 +1357,7 @@ func (m *Reader) ReadExactBytes(into []byte) error {
 
 // ReadStringAsBytes reads a MessagePack 'str' (UTF-8) string and returns its value as bytes.
 // The scratch slice will be used for storage if it is not nil and large enough.
+// With SetOldSpec(true), ReadStringAsBytes reads 'bin' objects as well.
 func (m *Reader) ReadStringAsBytes(scratch []byte) ([]byte, error) {
 
 	p, err := m.R.Peek(1)
@@ -826,6 +1395,9 @@ func (m *Reader) ReadStringAsBytes(scratch []byte) ([]byte, error) {
 			}
 			read = int64(big.Uint32(p[1:]))
 		default:
+			if m.oldSpec {
+				return m.readRaw(scratch, StrType)
+			}
 			return scratch, badPrefix(StrType, lead)
 		}
 	}
@@ -883,7 +1455,8 @@ func (m *Reader) ReadStringHeader() (sz uint32, err error) {
 	}
 }
 
-// ReadString reads a UTF-8 string from the reader.
+// ReadString reads a UTF-8 string from the reader. With SetOldSpec(true), ReadString reads 'bin'
+// objects as well.
 func (m *Reader) ReadString() (string, error) {
 
 	p, err := m.R.Peek(1)
@@ -920,14 +1493,142 @@ func (m *Reader) ReadString() (string, error) {
 			}
 			read = big.Uint32(p[1:])
 		default:
+			if m.oldSpec {
+				return m.ReadStringOldSpec()
+			}
 			return "", badPrefix(StrType, lead)
 		}
 	}
+	return m.readStringData(read)
+
+}
 
-	out := make([]byte, read)
-	_, err = m.R.ReadFull(out)
+// readStringData reads the data of a string, which is n bytes long.
+func (m *Reader) readStringData(n uint32) (string, error) {
+	if int(n) <= m.R.BufferSize() {
+		// The string is copied directly out of the read buffer.
+		p, err := m.R.Next(int(n))
+		if err != nil {
+			return "", err
+		}
+		if m.strs != nil && n <= internMaxLen {
+			return m.intern(p), nil
+		}
+		return string(p), nil
+	}
+	out := make([]byte, n)
+	_, err := m.R.ReadFull(out)
 	return string(out), err
+}
 
+// SetOldSpec sets whether m reads 'str' and 'bin' objects interchangeably, as needed to read the
+// data of the producers following the old MessagePack spec, which wrote binary data as strings (in
+// the 'raw' type that 'str' replaced). With this set, the methods reading either type (ReadBytes,
+// ReadExactBytes, ReadString, and ReadStringAsBytes) accept the other, as do the generated
+// DecodeMsg methods, which call them. The code generated with -compat=old-spec reads both types
+// whether or not this is set.
+func (m *Reader) SetOldSpec(on bool) { m.oldSpec = on }
+
+// ReadBytesOldSpec reads a 'bin' or 'str' object and returns its data, like ReadBytes with
+// SetOldSpec(true). The scratch slice will be used for storage if it is not nil and large enough.
+func (m *Reader) ReadBytesOldSpec(scratch []byte) ([]byte, error) { return m.readRaw(scratch, BinType) }
+
+// readRaw reads the data of a 'str' or 'bin' object into scratch (if it is large enough). An
+// object of another type is reported as not of the type want.
+func (m *Reader) readRaw(scratch []byte, want Type) ([]byte, error) {
+	n, skip, err := m.peekRawHeader(want)
+	if err != nil {
+		return nil, err
+	}
+	m.R.Skip(skip)
+	var b []byte
+	if uint32(cap(scratch)) < n {
+		b = make([]byte, n)
+	} else {
+		b = scratch[0:n]
+	}
+	_, err = m.R.ReadFull(b)
+	return b, err
+}
+
+// ReadExactBytesOldSpec reads a 'bin' or 'str' object into the provided slice, like ReadExactBytes
+// with SetOldSpec(true). An ArrayError will be returned if the object is not exactly the length
+// of the input slice.
+func (m *Reader) ReadExactBytesOldSpec(into []byte) error {
+	n, skip, err := m.peekRawHeader(BinType)
+	if err != nil {
+		return err
+	}
+	if n != uint32(len(into)) {
+		return ArrayError{Wanted: uint32(len(into)), Got: n}
+	}
+	m.R.Skip(skip)
+	_, err = m.R.ReadFull(into)
+	return err
+}
+
+// ReadStringOldSpec reads a 'str' or 'bin' object as a string, like ReadString with
+// SetOldSpec(true).
+func (m *Reader) ReadStringOldSpec() (string, error) {
+	n, skip, err := m.peekRawHeader(StrType)
+	if err != nil {
+		return "", err
+	}
+	m.R.Skip(skip)
+	return m.readStringData(n)
+}
+
+// peekRawHeader returns the length of the data and the size of the header of the next object,
+// which must be a 'str' or 'bin' object; an object of another type is reported as not of the
+// type want. Nothing is consumed.
+func (m *Reader) peekRawHeader(want Type) (length uint32, size int, err error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if t := getType(p[0]); t != StrType && t != BinType {
+		return 0, 0, badPrefix(want, p[0])
+	}
+	size = headerSize(p[0])
+	p, err = m.R.Peek(size)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, length, _ = parseHeader(p)
+	return length, size, nil
+}
+
+// The limits on the strings interned by a Reader.
+const (
+	internMaxLen     = 64   // the length of the longest string interned
+	internMaxStrings = 4096 // the number of strings interned by a Reader
+	internMaxKeys    = 256  // the number of map keys interned by a Reader
+)
+
+// SetStringInterning turns on or off the interning of the strings read by ReadString (and so by the
+// generated DecodeMsg methods). With interning on, a string that has been read before is returned
+// without being allocated again, which greatly reduces the allocations made decoding many objects
+// with the same map keys or string values. Only short strings are interned, and the number of
+// strings interned by m is limited, so m keeps at most a few hundred KB of strings. The interned
+// strings are kept until interning is turned off, even if m is Reset.
+func (m *Reader) SetStringInterning(on bool) {
+	if !on {
+		m.strs = nil
+	} else if m.strs == nil {
+		m.strs = make(map[string]string)
+	}
+}
+
+// intern returns the string of the bytes in b, which is interned if there is room in the table.
+func (m *Reader) intern(b []byte) string {
+	if s, ok := m.strs[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if len(m.strs) < internMaxStrings {
+		m.strs[s] = s
+	}
+	return s
 }
 
 // ReadComplex64 reads a complex64 from the reader.
@@ -968,7 +1669,13 @@ func (m *Reader) ReadComplex128() (complex128, error) {
 
 // ReadMapStrIntf reads a MessagePack map into a map[string]interface{}.
 // You must pass a non-nil map into the function.
-func (m *Reader) ReadMapStrIntf(mp map[string]interface{}) error {
+func (m *Reader) ReadMapStrIntf(mp map[string]interface{}) error { return m.readMapStrIntf(mp, 0) }
+
+// readMapStrIntf reads a map nested at the given depth.
+func (m *Reader) readMapStrIntf(mp map[string]interface{}, depth int) error {
+	if depth >= m.maxDepth() {
+		return ErrMaxDepthExceeded
+	}
 	sz, err := m.ReadMapHeader()
 	if err != nil {
 		return err
@@ -979,11 +1686,14 @@ func (m *Reader) ReadMapStrIntf(mp map[string]interface{}) error {
 	for i := uint32(0); i < sz; i++ {
 		var key string
 		var val interface{}
+		if err = m.ctxErr(); err != nil {
+			return err
+		}
 		key, err = m.ReadString()
 		if err != nil {
 			return err
 		}
-		val, err = m.ReadIntf()
+		val, err = m.readIntf(depth + 1)
 		if err != nil {
 			return err
 		}
@@ -992,6 +1702,63 @@ func (m *Reader) ReadMapStrIntf(mp map[string]interface{}) error {
 	return nil
 }
 
+// ReadMapStrBytes reads a MessagePack map into a map[string][]byte. If mp is not nil, it is cleared
+// and returned holding the map read. Otherwise, a new map is returned unless the map read is empty.
+func (m *Reader) ReadMapStrBytes(mp map[string][]byte) (map[string][]byte, error) {
+	sz, err := m.ReadMapHeader()
+	if err != nil {
+		return mp, err
+	}
+	if mp == nil && sz > 0 {
+		mp = make(map[string][]byte, sz)
+	} else {
+		for key := range mp {
+			delete(mp, key)
+		}
+	}
+	for i := uint32(0); i < sz; i++ {
+		var key string
+		var val []byte
+		if key, err = m.ReadString(); err != nil {
+			return mp, err
+		}
+		if val, err = m.ReadBytes(nil); err != nil {
+			return mp, err
+		}
+		mp[key] = val
+	}
+	return mp, nil
+}
+
+// ReadMapStrTime reads a MessagePack map into a map[string]time.Time. If mp is not nil, it is
+// cleared and returned holding the map read. Otherwise, a new map is returned unless the map
+// read is empty. The values are read as by ReadTimeExt, so either time extension is accepted.
+func (m *Reader) ReadMapStrTime(mp map[string]time.Time) (map[string]time.Time, error) {
+	sz, err := m.ReadMapHeader()
+	if err != nil {
+		return mp, err
+	}
+	if mp == nil && sz > 0 {
+		mp = make(map[string]time.Time, sz)
+	} else {
+		for key := range mp {
+			delete(mp, key)
+		}
+	}
+	for i := uint32(0); i < sz; i++ {
+		var key string
+		var val time.Time
+		if key, err = m.ReadString(); err != nil {
+			return mp, err
+		}
+		if val, err = m.ReadTimeExt(); err != nil {
+			return mp, err
+		}
+		mp[key] = val
+	}
+	return mp, nil
+}
+
 // ReadTime reads a time.Time object from the reader.
 // The returned time's location will be set to time.Local.
 func (m *Reader) ReadTime() (time.Time, error) {
@@ -1013,8 +1780,20 @@ func (m *Reader) ReadTime() (time.Time, error) {
 
 // ReadIntf reads out the next object as a raw interface{}. Arrays are decoded as []interface{},
 // and maps are decoded as map[string]interface{}. Integers are decoded as int64, and unsigned
-// integers are decoded as uint64.
-func (m *Reader) ReadIntf() (interface{}, error) {
+// integers are decoded as uint64, unless SetExactIntf has been called.
+func (m *Reader) ReadIntf() (interface{}, error) { return m.readIntf(0) }
+
+// SetExactIntf sets whether ReadIntf and ReadMapStrIntf return the integers they read as the Go
+// types of the widths the integers are encoded with (int8, int16, int32, int64, uint8, uint16,
+// uint32, or uint64, with the 1-byte fixints as int8) rather than as int64 and uint64. Floats are
+// always read as float32 or float64 as they are encoded. With exact integers, writing a value read
+// by ReadIntf with WriteIntf reproduces its encoding, provided that the integers are encoded in
+// their smallest forms (as msgp encodes them) and that the maps have at most one element (since
+// map[string]interface{} does not keep the order of the elements) with 'str' keys.
+func (m *Reader) SetExactIntf(exact bool) { m.exact = exact }
+
+// readIntf reads an object nested at the given depth.
+func (m *Reader) readIntf(depth int) (interface{}, error) {
 	t, err := m.NextType()
 	if err != nil {
 		return nil, err
@@ -1023,8 +1802,14 @@ func (m *Reader) ReadIntf() (interface{}, error) {
 	case BoolType:
 		return m.ReadBool()
 	case IntType:
+		if m.exact {
+			return m.readExactInt()
+		}
 		return m.ReadInt64()
 	case UintType:
+		if m.exact {
+			return m.readExactUint()
+		}
 		return m.ReadUint64()
 	case BinType:
 		return m.ReadBytes(nil)
@@ -1036,6 +1821,9 @@ func (m *Reader) ReadIntf() (interface{}, error) {
 		return m.ReadComplex128()
 	case TimeType:
 		return m.ReadTime()
+	case Float16Type:
+		f, err := m.ReadFloat16()
+		return Float16(f), err
 	case ExtensionType:
 		tt, err := m.peekExtensionType()
 		if err != nil {
@@ -1052,7 +1840,7 @@ func (m *Reader) ReadIntf() (interface{}, error) {
 		return e, err
 	case MapType:
 		mp := make(map[string]interface{})
-		err = m.ReadMapStrIntf(mp)
+		err = m.readMapStrIntf(mp, depth)
 		return mp, err
 	case NilType:
 		return nil, m.ReadNil()
@@ -1061,13 +1849,19 @@ func (m *Reader) ReadIntf() (interface{}, error) {
 	case Float64Type:
 		return m.ReadFloat64()
 	case ArrayType:
+		if depth >= m.maxDepth() {
+			return nil, ErrMaxDepthExceeded
+		}
 		sz, err := m.ReadArrayHeader()
 		if err != nil {
 			return nil, err
 		}
 		out := make([]interface{}, int(sz))
 		for j := range out {
-			out[j], err = m.ReadIntf()
+			if err = m.ctxErr(); err != nil {
+				return nil, err
+			}
+			out[j], err = m.readIntf(depth + 1)
 			if err != nil {
 				return nil, err
 			}
@@ -1077,3 +1871,25 @@ func (m *Reader) ReadIntf() (interface{}, error) {
 		return nil, fatal // unreachable
 	}
 }
+
+// readExactInt reads a signed integer as the type of the width it is encoded with.
+func (m *Reader) readExactInt() (interface{}, error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	lead := p[0]
+	i, err := m.ReadInt64()
+	return exactInt(lead, i), err
+}
+
+// readExactUint reads an unsigned integer as the type of the width it is encoded with.
+func (m *Reader) readExactUint() (interface{}, error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	lead := p[0]
+	u, err := m.ReadUint64()
+	return exactUint(lead, u), err
+}
@@ -17,7 +17,7 @@ func NextType(b []byte) Type {
 	}
 	spec := sizes[b[0]]
 	t := spec.typ
-	if t == ExtensionType && len(b) > int(spec.size) {
+	if t == ExtensionType && len(b) >= int(spec.size) {
 		var tp int8
 		if spec.extra == constsize {
 			tp = int8(b[1])
@@ -31,6 +31,8 @@ func NextType(b []byte) Type {
 			return Complex128Type
 		case Complex64Extension:
 			return Complex64Type
+		case Float16Extension:
+			return Float16Type
 		default:
 			return ExtensionType
 		}
@@ -38,6 +40,23 @@ func NextType(b []byte) Type {
 	return t
 }
 
+// NextHeaderBytes returns the type, the length, and the extension type of the next object in b
+// as Reader.NextHeader does, without reading the object.
+func NextHeaderBytes(b []byte) (t Type, length uint32, extType int8, err error) {
+	if len(b) < 1 {
+		return InvalidType, 0, 0, ErrShortBytes
+	}
+	if getType(b[0]) == InvalidType {
+		return InvalidType, 0, 0, InvalidPrefixError(b[0])
+	}
+	n := headerSize(b[0])
+	if len(b) < n {
+		return InvalidType, 0, 0, ErrShortBytes
+	}
+	t, length, extType = parseHeader(b[:n])
+	return t, length, extType, nil
+}
+
 // IsNil returns true if len(b)>0 and the leading byte is a "nil" MessagePack byte (0xc0).
 func IsNil(b []byte) bool {
 	return len(b) > 0 && b[0] == mnil
@@ -102,6 +121,30 @@ func (r Raw) Msgsize() int {
 	return l
 }
 
+// MsgsizeExact implements msgp.ExactSizer. It is the same as Msgsize since r holds the encoded object.
+func (r Raw) MsgsizeExact() int { return r.Msgsize() }
+
+// Clone returns a copy of r that does not share memory with r, as generated Clone methods do.
+func (r Raw) Clone() Raw {
+	if r == nil {
+		return nil
+	}
+	c := make(Raw, len(r))
+	copy(c, r)
+	return c
+}
+
+// Equal says if r and o hold the same bytes, as generated Equal methods compare values. An empty
+// Raw is equal to one holding nil, as it is encoded as nil.
+func (r Raw) Equal(o Raw) bool {
+	if len(r) == 0 || len(o) == 0 {
+		return isNilRaw(r) && isNilRaw(o)
+	}
+	return bytes.Equal(r, o)
+}
+
+func isNilRaw(r Raw) bool { return len(r) == 0 || len(r) == 1 && r[0] == mnil }
+
 func appendNext(f *Reader, d *[]byte) error {
 	amt, o, err := getNextSize(f.R)
 	if err != nil {
@@ -197,6 +240,31 @@ func ReadArrayHeaderBytes(b []byte) (uint32, []byte, error) {
 	}
 }
 
+// ReadExpectedArrayHeader reads an array header off of b and returns any remaining bytes. It
+// returns an ArrayError if the array does not have n elements. Other possible errors are
+// ErrShortBytes, TypeError, and InvalidPrefixError.
+func ReadExpectedArrayHeader(b []byte, n uint32) ([]byte, error) {
+	sz, o, err := ReadArrayHeaderBytes(b)
+	if err != nil {
+		return b, err
+	}
+	if sz != n {
+		return b, ArrayError{Wanted: n, Got: sz}
+	}
+	return o, nil
+}
+
+// ReadExpectedMapKey reads a map key (a 'str' or 'bin' object) off of b if it is equal to key, in
+// which case it returns the remaining bytes and true. Otherwise, including if b does not start with
+// a valid key, it returns b and false. The key is compared without being copied out of b.
+func ReadExpectedMapKey(b []byte, key string) ([]byte, bool) {
+	k, o, err := ReadMapKeyZC(b)
+	if err != nil || string(k) != key {
+		return b, false
+	}
+	return o, true
+}
+
 // ReadNilBytes reads a "nil" byte off of b and returns any remaining bytes.
 // Possible errors are ErrShortBytes, TypeError, and InvalidPrefixError.
 func ReadNilBytes(b []byte) ([]byte, error) {
@@ -225,6 +293,23 @@ func ReadFloat64Bytes(b []byte) (float64, []byte, error) {
 	return math.Float64frombits(getMuint64(b)), b[9:], nil
 }
 
+// ReadFloat64BytesLenient reads a float64 from b like ReadFloat64Bytes but also accepts an integer,
+// converted to the nearest float64.
+func ReadFloat64BytesLenient(b []byte) (float64, []byte, error) {
+	if len(b) < 1 {
+		return 0, b, ErrShortBytes
+	}
+	switch getType(b[0]) {
+	case IntType:
+		i, o, err := ReadInt64Bytes(b)
+		return float64(i), o, err
+	case UintType:
+		u, o, err := ReadUint64Bytes(b)
+		return float64(u), o, err
+	}
+	return ReadFloat64Bytes(b)
+}
+
 // ReadFloat32Bytes reads a float32 from b and returns the value and any remaining bytes.
 // Possible errors are ErrShortBytes and TypeError.
 func ReadFloat32Bytes(b []byte) (float32, []byte, error) {
@@ -253,6 +338,46 @@ func ReadBoolBytes(b []byte) (bool, []byte, error) {
 	}
 }
 
+// ReadBoolBytesLenient reads a bool from b like ReadBoolBytes but also accepts the integers 0 and 1
+// (of any width). Other integers are reported with a TypeError.
+func ReadBoolBytesLenient(b []byte) (bool, []byte, error) {
+	if len(b) < 1 {
+		return false, b, ErrShortBytes
+	}
+	switch b[0] {
+	case mtrue, mfalse:
+		return b[0] == mtrue, b[1:], nil
+	}
+	v, n, err := intBool(b)
+	if err != nil {
+		return false, b, err
+	}
+	return v, b[n:], nil
+}
+
+// intBool reads the integer 0 or 1 at the start of b as a bool and returns its size in bytes.
+func intBool(b []byte) (v bool, n int, err error) {
+	var u uint64
+	var o []byte
+	switch getType(b[0]) {
+	case IntType:
+		var i int64
+		i, o, err = ReadInt64Bytes(b)
+		u = uint64(i) // negative integers are too large to be bools
+	case UintType:
+		u, o, err = ReadUint64Bytes(b)
+	default:
+		return false, 0, badPrefix(BoolType, b[0])
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if u > 1 {
+		return false, 0, TypeError{Method: BoolType, Encoded: getType(b[0])}
+	}
+	return u == 1, len(b) - len(o), nil
+}
+
 // ReadInt64Bytes reads an int64 from b and return the value and the remaining bytes.
 // Errors that can be returned are ErrShortBytes, UintOverflow, InvalidPrefixError, and TypeError.
 func ReadInt64Bytes(b []byte) (int64, []byte, error) {
@@ -448,6 +573,20 @@ func ReadByteBytes(b []byte) (byte, []byte, error) {
 	return ReadUint8Bytes(b)
 }
 
+// ReadRawFieldBytes reads the next object in b as it is encoded, for a field with the raw option,
+// and returns it and the remaining bytes. The object is copied to raw[:0], which is grown if it is
+// too small; a nil object is read as raw[:0], so that the field is encoded as nil again.
+func ReadRawFieldBytes(b []byte, raw []byte) ([]byte, []byte, error) {
+	if IsNil(b) {
+		return raw[:0], b[1:], nil
+	}
+	rest, err := Skip(b)
+	if err != nil {
+		return raw, b, err
+	}
+	return append(raw[:0], b[:len(b)-len(rest)]...), rest, nil
+}
+
 // ReadBytesBytes reads a 'bin' object from b and returns its value and any remaining bytes.
 // The data is copied to the scratch slice if it's big enough, otherwise a slice is allocated.
 // Possible errors are ErrShortBytes and TypeError.
@@ -617,6 +756,66 @@ func ReadStringAsBytes(b []byte, scratch []byte) ([]byte, []byte, error) {
 	return append(scratch[:0], tmp...), o, err
 }
 
+// ReadBytesOldSpecBytes reads a 'bin' or 'str' object from b and returns its data and any
+// remaining bytes, reading binary data written by the producers following the old MessagePack
+// spec (see Reader.SetOldSpec). The data is copied to the scratch slice if it's big enough,
+// otherwise a slice is allocated. Possible errors are ErrShortBytes and TypeError.
+func ReadBytesOldSpecBytes(b []byte, scratch []byte) ([]byte, []byte, error) {
+	data, o, err := readRawZC(b, BinType)
+	if err != nil {
+		return nil, b, err
+	}
+	if cap(scratch) >= len(data) {
+		scratch = scratch[0:len(data)]
+	} else {
+		scratch = make([]byte, len(data))
+	}
+	copy(scratch, data)
+	return scratch, o, nil
+}
+
+// ReadExactBytesOldSpec reads into dst the data of a 'bin' or 'str' object in b, like
+// ReadExactBytes, and returns any remaining bytes. An ArrayError is returned if the data is not
+// exactly the length of dst.
+func ReadExactBytesOldSpec(b []byte, dst []byte) ([]byte, error) {
+	data, o, err := readRawZC(b, BinType)
+	if err != nil {
+		return b, err
+	}
+	if len(data) != len(dst) {
+		return b, ArrayError{Wanted: uint32(len(dst)), Got: uint32(len(data))}
+	}
+	copy(dst, data)
+	return o, nil
+}
+
+// ReadStringOldSpecBytes reads a 'str' or 'bin' object from b as a string and returns any
+// remaining bytes. Possible errors are ErrShortBytes and TypeError.
+func ReadStringOldSpecBytes(b []byte) (string, []byte, error) {
+	v, o, err := readRawZC(b, StrType)
+	return string(v), o, err
+}
+
+// readRawZC extracts the data of a 'str' or 'bin' object from b without copying. An object of
+// another type is reported as not of the type want.
+func readRawZC(b []byte, want Type) ([]byte, []byte, error) {
+	if len(b) < 1 {
+		return nil, b, ErrShortBytes
+	}
+	if t := getType(b[0]); t != StrType && t != BinType {
+		return nil, b, badPrefix(want, b[0])
+	}
+	size := headerSize(b[0])
+	if len(b) < size {
+		return nil, b, ErrShortBytes
+	}
+	_, n, _ := parseHeader(b)
+	if uint64(len(b)-size) < uint64(n) {
+		return nil, b, ErrShortBytes
+	}
+	return b[size : size+int(n)], b[size+int(n):], nil
+}
+
 // ReadComplex128Bytes reads a complex128 extension object from 'b' and returns any remaining bytes.
 // Possible errors are ErrShortBytes, TypeError, InvalidPrefixError, and ExtensionTypeError.
 func ReadComplex128Bytes(b []byte) (c complex128, o []byte, err error) {
@@ -677,9 +876,78 @@ func ReadTimeBytes(b []byte) (time.Time, []byte, error) {
 	return time.Unix(sec, int64(nsec)).Local(), b[15:], nil
 }
 
+// ReadMapStrBytesBytes reads a map[string][]byte out of b and returns the map and any remaining
+// bytes. If map old is not nil, it is cleared and used to hold the map read. Otherwise, a new map
+// is returned unless the map read is empty. The []byte values do not point into b.
+func ReadMapStrBytesBytes(b []byte, old map[string][]byte) (map[string][]byte, []byte, error) {
+	sz, o, err := ReadMapHeaderBytes(b)
+	if err != nil {
+		return old, o, err
+	}
+	if old == nil && sz > 0 {
+		old = make(map[string][]byte, sz)
+	} else {
+		for key := range old {
+			delete(old, key)
+		}
+	}
+	for z := uint32(0); z < sz; z++ {
+		var key string
+		var val []byte
+		if key, o, err = ReadStringBytes(o); err != nil {
+			return old, o, err
+		}
+		if val, o, err = ReadBytesBytes(o, nil); err != nil {
+			return old, o, err
+		}
+		old[key] = val
+	}
+	return old, o, nil
+}
+
+// ReadMapStrTimeBytes reads a map[string]time.Time out of b and returns the map and any remaining
+// bytes. If map old is not nil, it is cleared and used to hold the map read. Otherwise, a new map
+// is returned unless the map read is empty. The values are read as by ReadTimeExtBytes, so either
+// time extension is accepted.
+func ReadMapStrTimeBytes(b []byte, old map[string]time.Time) (map[string]time.Time, []byte, error) {
+	sz, o, err := ReadMapHeaderBytes(b)
+	if err != nil {
+		return old, o, err
+	}
+	if old == nil && sz > 0 {
+		old = make(map[string]time.Time, sz)
+	} else {
+		for key := range old {
+			delete(old, key)
+		}
+	}
+	for z := uint32(0); z < sz; z++ {
+		var key string
+		var val time.Time
+		if key, o, err = ReadStringBytes(o); err != nil {
+			return old, o, err
+		}
+		if val, o, err = ReadTimeExtBytes(o); err != nil {
+			return old, o, err
+		}
+		old[key] = val
+	}
+	return old, o, nil
+}
+
 // ReadMapStrIntfBytes reads a map[string]interface{} out of b and returns the map and any remaining bytes.
 // If map old is not nil, it will be cleared and used so that a map does not need to be created.
 func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (map[string]interface{}, []byte, error) {
+	return readMapStrIntfBytes(b, old, 0, false)
+}
+
+// readMapStrIntfBytes reads a map nested at the given depth, reading integers as the types of
+// their encoded widths if exact is true.
+func readMapStrIntfBytes(b []byte, old map[string]interface{}, depth int, exact bool) (map[string]interface{}, []byte, error) {
+
+	if depth >= MaxDepth {
+		return old, b, ErrMaxDepthExceeded
+	}
 
 	sz, o, err := ReadMapHeaderBytes(b)
 	if err != nil {
@@ -704,7 +972,7 @@ func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (map[string]inter
 			return old, o, err
 		}
 		var val interface{}
-		val, o, err = ReadIntfBytes(o)
+		val, o, err = readIntfBytes(o, depth+1, exact)
 		if err != nil {
 			return old, o, err
 		}
@@ -716,7 +984,16 @@ func ReadMapStrIntfBytes(b []byte, old map[string]interface{}) (map[string]inter
 }
 
 // ReadIntfBytes reads the next object out of b as a raw interface{} and returns any remaining bytes.
-func ReadIntfBytes(b []byte) (interface{}, []byte, error) {
+func ReadIntfBytes(b []byte) (interface{}, []byte, error) { return readIntfBytes(b, 0, false) }
+
+// ReadIntfBytesExact is like ReadIntfBytes, but it reads integers as the Go types of the widths
+// they are encoded with, like a Reader after SetExactIntf(true). Appending a value it reads with
+// AppendIntf reproduces the value's encoding under the conditions given for SetExactIntf.
+func ReadIntfBytesExact(b []byte) (interface{}, []byte, error) { return readIntfBytes(b, 0, true) }
+
+// readIntfBytes reads an object nested at the given depth, reading integers as the types of their
+// encoded widths if exact is true.
+func readIntfBytes(b []byte, depth int, exact bool) (interface{}, []byte, error) {
 
 	if len(b) < 1 {
 		return nil, b, ErrShortBytes
@@ -726,15 +1003,18 @@ func ReadIntfBytes(b []byte) (interface{}, []byte, error) {
 
 	switch k {
 	case MapType:
-		return ReadMapStrIntfBytes(b, nil)
+		return readMapStrIntfBytes(b, nil, depth, exact)
 	case ArrayType:
+		if depth >= MaxDepth {
+			return nil, b, ErrMaxDepthExceeded
+		}
 		sz, o, err := ReadArrayHeaderBytes(b)
 		if err != nil {
 			return nil, o, err
 		}
 		i := make([]interface{}, int(sz))
 		for d := range i {
-			i[d], o, err = ReadIntfBytes(o)
+			i[d], o, err = readIntfBytes(o, depth+1, exact)
 			if err != nil {
 				return i, o, err
 			}
@@ -745,8 +1025,16 @@ func ReadIntfBytes(b []byte) (interface{}, []byte, error) {
 	case Float64Type:
 		return ReadFloat64Bytes(b)
 	case IntType:
+		if exact {
+			i, o, err := ReadInt64Bytes(b)
+			return exactInt(b[0], i), o, err
+		}
 		return ReadInt64Bytes(b)
 	case UintType:
+		if exact {
+			u, o, err := ReadUint64Bytes(b)
+			return exactUint(b[0], u), o, err
+		}
 		return ReadUint64Bytes(b)
 	case BoolType:
 		return ReadBoolBytes(b)
@@ -756,6 +1044,9 @@ func ReadIntfBytes(b []byte) (interface{}, []byte, error) {
 		return ReadComplex64Bytes(b)
 	case Complex128Type:
 		return ReadComplex128Bytes(b)
+	case Float16Type:
+		f, o, err := ReadFloat16Bytes(b)
+		return Float16(f), o, err
 	case ExtensionType:
 		t, err := peekExtension(b)
 		if err != nil {
@@ -786,20 +1077,58 @@ func ReadIntfBytes(b []byte) (interface{}, []byte, error) {
 
 }
 
+// exactInt returns i, read from an object with the given lead byte, as the type of the width
+// the object has. The fixints are returned as int8.
+func exactInt(lead byte, i int64) interface{} {
+	switch lead {
+	case mint16:
+		return int16(i)
+	case mint32:
+		return int32(i)
+	case mint64:
+		return i
+	default:
+		return int8(i)
+	}
+}
+
+// exactUint returns u, read from an object with the given lead byte, as the type of the width
+// the object has.
+func exactUint(lead byte, u uint64) interface{} {
+	switch lead {
+	case muint8:
+		return uint8(u)
+	case muint16:
+		return uint16(u)
+	case muint32:
+		return uint32(u)
+	default:
+		return u
+	}
+}
+
 // Skip skips the next object in slice b and returns the remaining bytes. If the object
 // is a map or array, all of its elements will be skipped. Possible errors are
-// ErrShortBytes (not enough bytes in b) and InvalidPrefixError (bad encoding).
-func Skip(b []byte) ([]byte, error) {
+// ErrShortBytes (not enough bytes in b), InvalidPrefixError (bad encoding), and
+// ErrMaxDepthExceeded (maps and arrays nested too deeply).
+func Skip(b []byte) ([]byte, error) { return skip(b, 0) }
+
+// skip skips an object nested at the given depth.
+func skip(b []byte, depth int) ([]byte, error) {
 	sz, asz, err := getSize(b)
 	if err != nil {
 		return b, err
 	}
-	if uintptr(len(b)) < sz {
+	// Every element takes at least one byte.
+	if uintptr(len(b)) < sz || uintptr(len(b))-sz < asz {
 		return b, ErrShortBytes
 	}
+	if asz > 0 && depth >= MaxDepth {
+		return b, ErrMaxDepthExceeded
+	}
 	b = b[sz:]
 	for asz > 0 {
-		b, err = Skip(b)
+		b, err = skip(b, depth+1)
 		if err != nil {
 			return b, err
 		}
@@ -808,6 +1137,23 @@ func Skip(b []byte) ([]byte, error) {
 	return b, nil
 }
 
+// Validate checks that b holds a sequence of complete objects with valid prefixes and lengths and
+// with maps and arrays nested no deeper than MaxDepth, and returns the number of objects and the
+// number of bytes they take, which is len(b) if b is valid. The values are skipped, as by Skip,
+// without being decoded, so that malformed data is rejected cheaply. On an error, the counts
+// are those of the objects before the malformed one, which starts at b[size:].
+func Validate(b []byte) (objects int, size int, err error) {
+	for size < len(b) {
+		rest, err := skip(b[size:], 0)
+		if err != nil {
+			return objects, size, err
+		}
+		objects++
+		size = len(b) - len(rest)
+	}
+	return objects, size, nil
+}
+
 // getSize returns (skip N bytes, skip M objects, error)
 func getSize(b []byte) (uintptr, uintptr, error) {
 	l := len(b)
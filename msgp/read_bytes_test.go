@@ -4,10 +4,75 @@ import (
 	"bytes"
 	"math"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
 
+// headerCase is an object with the header that NextHeader and NextHeaderBytes should report.
+type headerCase struct {
+	obj     []byte
+	typ     Type
+	length  uint32
+	extType int8
+}
+
+func headerCases(t *testing.T) []headerCase {
+	ext := func(n int, typ int8) []byte {
+		b, err := AppendExtension(nil, &RawExtension{Type: typ, Data: make([]byte, n)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	return []headerCase{
+		{AppendNil(nil), NilType, 0, 0},
+		{AppendInt64(nil, -1<<40), IntType, 0, 0},
+		{AppendUint64(nil, 1<<40), UintType, 0, 0},
+		{AppendFloat32(nil, 1), Float32Type, 0, 0},
+		{AppendMapHeader(nil, 3), MapType, 3, 0},
+		{AppendMapHeader(nil, 300), MapType, 300, 0},
+		{AppendMapHeader(nil, 70000), MapType, 70000, 0},
+		{AppendArrayHeader(nil, 15), ArrayType, 15, 0},
+		{AppendArrayHeader(nil, 16), ArrayType, 16, 0},
+		{AppendArrayHeader(nil, 1<<20), ArrayType, 1 << 20, 0},
+		{AppendString(nil, "hello"), StrType, 5, 0},
+		{AppendString(nil, string(make([]byte, 200))), StrType, 200, 0},
+		{AppendString(nil, string(make([]byte, 70000))), StrType, 70000, 0},
+		{AppendBytes(nil, make([]byte, 3)), BinType, 3, 0},
+		{AppendBytes(nil, make([]byte, 300)), BinType, 300, 0},
+		{ext(1, 9), ExtensionType, 1, 9},
+		{ext(16, -9), ExtensionType, 16, -9},
+		{ext(3, 9), ExtensionType, 3, 9},
+		{ext(300, 9), ExtensionType, 300, 9},
+		{ext(70000, 9), ExtensionType, 70000, 9},
+		{AppendTime(nil, time.Now()), TimeType, 12, TimeExtension},
+		{AppendComplex64(nil, 1), Complex64Type, 8, Complex64Extension},
+	}
+}
+
+func TestNextHeaderBytes(t *testing.T) {
+	for i, c := range headerCases(t) {
+		typ, length, extType, err := NextHeaderBytes(c.obj)
+		if err != nil {
+			t.Errorf("case %d: %s", i, err)
+			continue
+		}
+		if typ != c.typ || length != c.length || extType != c.extType {
+			t.Errorf("case %d: got (%s, %d, %d); want (%s, %d, %d)", i, typ, length, extType, c.typ, c.length, c.extType)
+		}
+	}
+	if _, _, _, err := NextHeaderBytes(AppendMapHeader(nil, 300)[:2]); err != ErrShortBytes {
+		t.Errorf("got %v for a short header", err)
+	}
+	if _, _, _, err := NextHeaderBytes([]byte{0xc1}); err == nil {
+		t.Error("no error for an invalid prefix")
+	}
+	if _, _, _, err := NextHeaderBytes(nil); err != ErrShortBytes {
+		t.Errorf("got %v for no bytes", err)
+	}
+}
+
 func TestReadMapHeaderBytes(t *testing.T) {
 
 	var buf bytes.Buffer
@@ -84,6 +149,54 @@ func TestReadArrayHeaderBytes(t *testing.T) {
 
 }
 
+func TestReadExpectedArrayHeader(t *testing.T) {
+	for _, n := range []uint32{0, 5, tuint16, tuint32} {
+		b := append(AppendArrayHeader(nil, n), mnil)
+		left, err := ReadExpectedArrayHeader(b, n)
+		if err != nil {
+			t.Fatalf("reading %d: %s", n, err)
+		}
+		if len(left) != 1 {
+			t.Errorf("%d bytes left after a header of %d", len(left), n)
+		}
+
+		left, err = ReadExpectedArrayHeader(b, n+1)
+		if err != (ArrayError{Wanted: n + 1, Got: n}) {
+			t.Errorf("reading %d as %d: got error %v", n, n+1, err)
+		}
+		if len(left) != len(b) {
+			t.Error("bytes were consumed reading a header of the wrong size")
+		}
+	}
+	if _, err := ReadExpectedArrayHeader(AppendMapHeader(nil, 1), 1); err == nil {
+		t.Error("read a map header as an array header")
+	}
+}
+
+func TestReadExpectedMapKey(t *testing.T) {
+	b := AppendString(nil, "key")
+	b = AppendBytes(b, []byte("bin"))
+	b = AppendInt(b, 1)
+
+	left, ok := ReadExpectedMapKey(b, "other")
+	if ok || len(left) != len(b) {
+		t.Errorf("matched the key %q", "other")
+	}
+	left, ok = ReadExpectedMapKey(b, "key")
+	if !ok {
+		t.Fatal("did not match the key")
+	}
+	if left, ok = ReadExpectedMapKey(left, "bin"); !ok {
+		t.Fatal("did not match the key written as bin")
+	}
+	if _, ok = ReadExpectedMapKey(left, "1"); ok {
+		t.Error("matched an integer as a key")
+	}
+	if _, ok = ReadExpectedMapKey(nil, ""); ok {
+		t.Error("matched a key in no bytes")
+	}
+}
+
 func BenchmarkReadArrayHeaderBytes(b *testing.B) {
 	sizes := []uint32{1, 100, tuint16, tuint32}
 	buf := make([]byte, 0, 5*len(sizes))
@@ -145,6 +258,33 @@ func TestReadFloat64Bytes(t *testing.T) {
 	}
 }
 
+func TestReadFloat64BytesLenient(t *testing.T) {
+	var b []byte
+	b = AppendInt(b, 7)
+	b = AppendInt64(b, math.MinInt64)
+	b = AppendUint(b, 1<<40)
+	b = AppendFloat64(b, -1.25)
+	b = AppendBool(b, true)
+
+	for _, want := range []float64{7, math.MinInt64, 1 << 40, -1.25} {
+		var f float64
+		var err error
+		f, b, err = ReadFloat64BytesLenient(b)
+		if err != nil {
+			t.Fatalf("reading %g: %s", want, err)
+		}
+		if f != want {
+			t.Errorf("read %g; want %g", f, want)
+		}
+	}
+	if _, _, err := ReadFloat64BytesLenient(b); err == nil {
+		t.Error("read a bool as a float64")
+	}
+	if _, _, err := ReadFloat64BytesLenient(nil); err != ErrShortBytes {
+		t.Errorf("read no bytes with the error %v; want ErrShortBytes", err)
+	}
+}
+
 func BenchmarkReadFloat64Bytes(b *testing.B) {
 	f := float64(3.14159)
 	buf := make([]byte, 0, 9)
@@ -213,6 +353,33 @@ func TestReadBoolBytes(t *testing.T) {
 	}
 }
 
+func TestReadBoolBytesLenient(t *testing.T) {
+	var b []byte
+	b = AppendUint64(b, 1)
+	b = AppendInt16(b, 0)
+	b = AppendBool(b, false)
+	for _, want := range []bool{true, false, false} {
+		var v bool
+		var err error
+		v, b, err = ReadBoolBytesLenient(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != want {
+			t.Errorf("read %t; want %t", v, want)
+		}
+	}
+
+	for _, bad := range [][]byte{AppendInt(nil, -1), AppendUint(nil, 256), AppendString(nil, "1")} {
+		if _, o, err := ReadBoolBytesLenient(bad); err == nil || len(o) != len(bad) {
+			t.Errorf("read %x as a bool, leaving %d bytes, with the error %v", bad, len(o), err)
+		}
+	}
+	if _, _, err := ReadBoolBytesLenient(AppendUint(nil, 300)); err != (TypeError{Method: BoolType, Encoded: UintType}) {
+		t.Errorf("read 300 with the error %v; want a TypeError", err)
+	}
+}
+
 func BenchmarkReadBoolBytes(b *testing.B) {
 	buf := []byte{mtrue, mfalse, mtrue, mfalse}
 	b.SetBytes(1)
@@ -362,6 +529,36 @@ func TestReadBytesBytes(t *testing.T) {
 
 }
 
+func TestReadOldSpecBytes(t *testing.T) {
+	for _, v := range []string{"", "abcd", strings.Repeat("x", 300)} {
+		for _, b := range [][]byte{AppendString(nil, v), AppendBytes(nil, []byte(v))} {
+			if got, left, err := ReadBytesOldSpecBytes(b, nil); err != nil || string(got) != v || len(left) != 0 {
+				t.Errorf("ReadBytesOldSpecBytes(%x) = %q, %x, %v", b, got, left, err)
+			}
+			if got, left, err := ReadStringOldSpecBytes(b); err != nil || got != v || len(left) != 0 {
+				t.Errorf("ReadStringOldSpecBytes(%x) = %q, %x, %v", b, got, left, err)
+			}
+			dst := make([]byte, len(v))
+			if left, err := ReadExactBytesOldSpec(b, dst); err != nil || string(dst) != v || len(left) != 0 {
+				t.Errorf("ReadExactBytesOldSpec(%x) = %q, %x, %v", b, dst, left, err)
+			}
+			if _, err := ReadExactBytesOldSpec(b, make([]byte, len(v)+1)); err == nil {
+				t.Errorf("ReadExactBytesOldSpec(%x) read %d bytes into a longer slice", b, len(v))
+			}
+			if len(b) > 1 {
+				if _, _, err := ReadBytesOldSpecBytes(b[:len(b)-1], nil); err != ErrShortBytes {
+					t.Errorf("got %v for a short object; want ErrShortBytes", err)
+				}
+			}
+		}
+	}
+	if _, _, err := ReadBytesOldSpecBytes(AppendInt(nil, 1), nil); err == nil {
+		t.Error("ReadBytesOldSpecBytes read an integer")
+	} else if tperr, ok := err.(TypeError); !ok || tperr.Method != BinType {
+		t.Errorf("got %v; want a TypeError for a bin", err)
+	}
+}
+
 func TestReadZCBytes(t *testing.T) {
 
 	var buf bytes.Buffer
@@ -549,6 +746,55 @@ func TestReadIntfBytes(t *testing.T) {
 
 }
 
+func TestReadIntfBytesExact(t *testing.T) {
+	tests := []interface{}{
+		int8(-128),
+		int16(math.MaxInt16),
+		int32(math.MinInt32),
+		int64(math.MaxInt64),
+		uint8(math.MaxUint8),
+		uint16(math.MaxUint16),
+		uint32(math.MaxUint32),
+		uint64(math.MaxUint64),
+		float32(-0.25),
+		[]interface{}{int8(5), []interface{}{int16(-300), uint32(1 << 20)}},
+	}
+
+	for i, v := range tests {
+		encoded, err := AppendIntf(nil, v)
+		if err != nil {
+			t.Fatalf("test case %d: %s", i, err)
+		}
+		out, left, err := ReadIntfBytesExact(encoded)
+		if err != nil {
+			t.Fatalf("test case %d: %s", i, err)
+		}
+		if len(left) != 0 {
+			t.Errorf("expected 0 bytes left; found %d", len(left))
+		}
+		if !reflect.DeepEqual(v, out) {
+			t.Errorf("test case %d: %#v in; %#v out", i, v, out)
+		}
+		again, err := AppendIntf(nil, out)
+		if err != nil {
+			t.Fatalf("test case %d: %s", i, err)
+		}
+		if !bytes.Equal(again, encoded) {
+			t.Errorf("test case %d: re-encoded as %x; want %x", i, again, encoded)
+		}
+	}
+
+	// Without the exact mode, the widths are lost.
+	b := AppendInt16(nil, -300)
+	out, _, err := ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != int64(-300) {
+		t.Errorf("got %#v; want int64(-300)", out)
+	}
+}
+
 func BenchmarkSkipBytes(b *testing.B) {
 	var buf bytes.Buffer
 	en := NewWriter(&buf)
@@ -2,10 +2,16 @@ package msgp
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"io/ioutil"
 	"math"
 	"math/rand"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,7 +25,8 @@ func TestSanity(t *testing.T) {
 func TestReadIntf(t *testing.T) {
 
 	// NOTE: If you include cases with, say, int32s, the test will fail because
-	// integers are always read out as int64 and unsigned integers as uint64.
+	// integers are read out as int64 and unsigned integers as uint64 (unless SetExactIntf
+	// is called, as in TestReadIntfExact).
 
 	var testCases = []interface{}{
 		float64(128.032),
@@ -74,6 +81,132 @@ func TestReadIntf(t *testing.T) {
 
 }
 
+func TestNextHeader(t *testing.T) {
+	for i, c := range headerCases(t) {
+		rd := NewReader(bytes.NewReader(c.obj))
+		typ, length, extType, err := rd.NextHeader()
+		if err != nil {
+			t.Errorf("case %d: %s", i, err)
+			continue
+		}
+		if typ != c.typ || length != c.length || extType != c.extType {
+			t.Errorf("case %d: got (%s, %d, %d); want (%s, %d, %d)", i, typ, length, extType, c.typ, c.length, c.extType)
+		}
+		// Nothing is consumed.
+		if rest, _ := ioutil.ReadAll(rd); !bytes.Equal(rest, c.obj) {
+			t.Errorf("case %d: %d bytes left after NextHeader; want %d", i, len(rest), len(c.obj))
+		}
+	}
+
+	rd := NewReader(bytes.NewReader(AppendArrayHeader(nil, 300)[:2]))
+	if _, _, _, err := rd.NextHeader(); err != io.ErrUnexpectedEOF && err != io.EOF {
+		t.Errorf("got %v for a short header", err)
+	}
+}
+
+func TestReaderMore(t *testing.T) {
+	rd := NewReader(bytes.NewReader(AppendString(AppendInt(nil, 5), "five")))
+	var n int
+	for rd.More() {
+		if err := rd.Skip(); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("read %d objects; want 2", n)
+	}
+	if rd.More() {
+		t.Error("More returned true at the end of the stream")
+	}
+}
+
+func TestReaderPeekN(t *testing.T) {
+	msg := AppendString(AppendMapHeader(nil, 1), "protocol")
+	rd := NewReaderSize(bytes.NewReader(msg), 16)
+	p, err := rd.PeekN(1)
+	if err != nil || len(p) != 1 || getType(p[0]) != MapType {
+		t.Fatalf("PeekN(1) = %x, %v", p, err)
+	}
+	// Peeking past the buffer size grows the buffer.
+	if p, err = rd.PeekN(len(msg)); err != nil || !bytes.Equal(p, msg) {
+		t.Fatalf("PeekN(%d) = %x, %v", len(msg), p, err)
+	}
+	if p, err = rd.PeekN(len(msg) + 1); err != io.EOF || !bytes.Equal(p, msg) {
+		t.Errorf("PeekN past the end = %x, %v", p, err)
+	}
+	if _, err = rd.PeekN(-1); err == nil {
+		t.Error("no error peeking a negative count")
+	}
+	// Nothing is consumed.
+	if rest, _ := ioutil.ReadAll(rd); !bytes.Equal(rest, msg) {
+		t.Errorf("%x left after peeking; want %x", rest, msg)
+	}
+}
+
+func TestReadIntfExact(t *testing.T) {
+	testCases := []interface{}{
+		int8(-3),
+		int8(-100),
+		int16(-1000),
+		int32(70000),
+		int64(-1 << 40),
+		uint8(200),
+		uint16(60000),
+		uint32(1 << 31),
+		uint64(1 << 40),
+		float32(2.5),
+		float64(2.5),
+		[]interface{}{int8(1), uint16(300), float32(-1)},
+		map[string]interface{}{"a": int32(-1 << 20)},
+	}
+
+	var buf bytes.Buffer
+	dec := NewReader(&buf)
+	dec.SetExactIntf(true)
+	enc := NewWriter(&buf)
+
+	for i, tc := range testCases {
+		buf.Reset()
+		if err := enc.WriteIntf(tc); err != nil {
+			t.Fatalf("(case %d) %s", i, err)
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		encoded := append([]byte(nil), buf.Bytes()...)
+
+		v, err := dec.ReadIntf()
+		if err != nil {
+			t.Fatalf("(case %d) %s", i, err)
+		}
+		if !reflect.DeepEqual(v, tc) {
+			t.Errorf("(case %d) %#v in; %#v out", i, tc, v)
+		}
+		if err = enc.WriteIntf(v); err != nil {
+			t.Fatalf("(case %d) %s", i, err)
+		}
+		if err = enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), encoded) {
+			t.Errorf("(case %d) re-encoded as %x; want %x", i, buf.Bytes(), encoded)
+		}
+	}
+
+	// Integers in the fixint ranges are read as int8, whatever their types were.
+	buf.Reset()
+	enc.WriteUint32(7)
+	enc.Flush()
+	v, err := dec.ReadIntf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != int8(7) {
+		t.Errorf("got %#v; want int8(7)", v)
+	}
+}
+
 func TestReadMapHeader(t *testing.T) {
 
 	cases := []uint32{0, 1, tuint16, tuint32}
@@ -218,6 +351,35 @@ func TestReadFloat64(t *testing.T) {
 	}
 }
 
+func TestReadFloat64Lenient(t *testing.T) {
+	var b []byte
+	b = AppendInt(b, 1)
+	b = AppendInt(b, -300)
+	b = AppendUint64(b, math.MaxUint64)
+	b = AppendFloat32(b, 0.5)
+	b = AppendFloat64(b, math.Pi)
+	b = AppendString(b, "1")
+
+	rd := NewReader(bytes.NewReader(b))
+	for _, want := range []float64{1, -300, math.MaxUint64, 0.5, math.Pi} {
+		f, err := rd.ReadFloat64Lenient()
+		if err != nil {
+			t.Fatalf("reading %g: %s", want, err)
+		}
+		if f != want {
+			t.Errorf("read %g; want %g", f, want)
+		}
+	}
+	if _, err := rd.ReadFloat64Lenient(); err == nil {
+		t.Error("read a string as a float64")
+	}
+
+	// ReadFloat64 still refuses integers.
+	if _, err := NewReader(bytes.NewReader(b)).ReadFloat64(); err == nil {
+		t.Error("ReadFloat64 read an integer")
+	}
+}
+
 func BenchmarkReadFloat64(b *testing.B) {
 	fs := []float64{rand.Float64(), rand.Float64(), rand.Float64(), rand.Float64()}
 	data := make([]byte, 0, 9*len(fs))
@@ -583,6 +745,270 @@ func BenchmarkRead256String(b *testing.B) {
 	benchString(256, b)
 }
 
+func BenchmarkRead16StringInterned(b *testing.B) {
+	str := string(RandBytes(16))
+	rd := NewReader(NewEndlessReader(AppendString(nil, str), b))
+	rd.SetStringInterning(true)
+	b.SetBytes(int64(len(str) + 1))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rd.ReadString(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStringInterning(t *testing.T) {
+	long := strings.Repeat("x", internMaxLen+1)
+	var b []byte
+	for i := 0; i < 3; i++ {
+		b = AppendString(b, "key")
+		b = AppendString(b, long)
+	}
+	rd := NewReader(bytes.NewReader(b))
+	rd.SetStringInterning(true)
+	for i := 0; i < 3; i++ {
+		if s, err := rd.ReadString(); err != nil || s != "key" {
+			t.Fatalf("read %q with error %v", s, err)
+		}
+		if s, err := rd.ReadString(); err != nil || s != long {
+			t.Fatalf("read %q with error %v", s, err)
+		}
+	}
+	if len(rd.strs) != 1 {
+		t.Errorf("expected only the short string to be interned; got %d strings", len(rd.strs))
+	}
+
+	// A repeated string is not allocated again.
+	b = b[:0]
+	for i := 0; i < 20; i++ {
+		b = AppendString(b, "key")
+	}
+	rd.Reset(bytes.NewReader(b))
+	allocs := testing.AllocsPerRun(10, func() { rd.ReadString() })
+	if allocs != 0 {
+		t.Errorf("expected an interned string not to be allocated; got %v allocations", allocs)
+	}
+
+	// The table of strings does not grow without limit.
+	b = b[:0]
+	for i := 0; i < internMaxStrings+10; i++ {
+		b = AppendString(b, strconv.Itoa(i))
+	}
+	rd.Reset(bytes.NewReader(b))
+	for i := 0; i < internMaxStrings+10; i++ {
+		if s, err := rd.ReadString(); err != nil || s != strconv.Itoa(i) {
+			t.Fatalf("read %q with error %v", s, err)
+		}
+	}
+	if len(rd.strs) != internMaxStrings {
+		t.Errorf("expected %d strings interned; got %d", internMaxStrings, len(rd.strs))
+	}
+
+	rd.SetStringInterning(false)
+	if rd.strs != nil {
+		t.Error("expected the interned strings to be dropped")
+	}
+}
+
+func TestReadMapKeyStringInterned(t *testing.T) {
+	long := strings.Repeat("x", internMaxLen+1)
+	var b []byte
+	for i := 0; i < 2; i++ {
+		b = AppendString(b, "key")
+		b = AppendBytes(b, []byte("bin"))
+		b = AppendString(b, "")
+		b = AppendString(b, long)
+	}
+	b = AppendInt(b, 1)
+	rd := NewReader(bytes.NewReader(b))
+	for i := 0; i < 2; i++ {
+		for _, want := range []string{"key", "bin", "", long} {
+			if s, err := rd.ReadMapKeyStringInterned(); err != nil || s != want {
+				t.Fatalf("read %q with error %v; want %q", s, err, want)
+			}
+		}
+	}
+	if _, err := rd.ReadMapKeyStringInterned(); err == nil {
+		t.Error("read an integer as a map key")
+	}
+	if len(rd.keys) != 3 || rd.strs != nil {
+		t.Errorf("expected the 3 short keys to be interned; got %d keys", len(rd.keys))
+	}
+
+	// A repeated key is not allocated again.
+	b = b[:0]
+	for i := 0; i < 20; i++ {
+		b = AppendString(b, "key")
+	}
+	rd.Reset(bytes.NewReader(b))
+	allocs := testing.AllocsPerRun(10, func() { rd.ReadMapKeyStringInterned() })
+	if allocs != 0 {
+		t.Errorf("expected an interned key not to be allocated; got %v allocations", allocs)
+	}
+
+	b = b[:0]
+	for i := 0; i < internMaxKeys+10; i++ {
+		b = AppendString(b, strconv.Itoa(i))
+	}
+	rd.Reset(bytes.NewReader(b))
+	for i := 0; i < internMaxKeys+10; i++ {
+		if s, err := rd.ReadMapKeyStringInterned(); err != nil || s != strconv.Itoa(i) {
+			t.Fatalf("read %q with error %v", s, err)
+		}
+	}
+	if len(rd.keys) != internMaxKeys {
+		t.Errorf("expected %d keys interned; got %d", internMaxKeys, len(rd.keys))
+	}
+}
+
+func TestReadOldSpec(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	var b []byte
+	for _, v := range []string{"", "abcd", long} {
+		b = AppendString(b, v)
+		b = AppendBytes(b, []byte(v))
+	}
+	b = AppendInt(b, 1)
+
+	// Without SetOldSpec, the types are not interchangeable.
+	rd := NewReader(bytes.NewReader(b))
+	if _, err := rd.ReadBytes(nil); err == nil {
+		t.Error("ReadBytes read a 'str' object")
+	}
+
+	rd = NewReader(bytes.NewReader(b))
+	rd.SetOldSpec(true)
+	for _, v := range []string{"", "abcd", long} {
+		if got, err := rd.ReadBytes(nil); err != nil || string(got) != v {
+			t.Errorf("ReadBytes read %q with error %v; want %q", got, err, v)
+		}
+		if got, err := rd.ReadString(); err != nil || got != v {
+			t.Errorf("ReadString read %q with error %v; want %q", got, err, v)
+		}
+	}
+	if _, err := rd.ReadStringOldSpec(); err == nil {
+		t.Error("ReadStringOldSpec read an integer")
+	} else if tperr, ok := err.(TypeError); !ok || tperr.Method != StrType {
+		t.Errorf("got %v; want a TypeError for a string", err)
+	}
+
+	// ReadExactBytes and ReadStringAsBytes.
+	b = AppendBytes(AppendString(nil, "abcd"), []byte("efgh"))
+	rd = NewReader(bytes.NewReader(b))
+	rd.SetOldSpec(true)
+	var into [4]byte
+	if err := rd.ReadExactBytes(into[:1]); err == nil {
+		t.Error("ReadExactBytes read 4 bytes into a slice of length 1")
+	}
+	if err := rd.ReadExactBytes(into[:]); err != nil || string(into[:]) != "abcd" {
+		t.Errorf("ReadExactBytes read %q with error %v", into, err)
+	}
+	if got, err := rd.ReadStringAsBytes(nil); err != nil || string(got) != "efgh" {
+		t.Errorf("ReadStringAsBytes read %q with error %v", got, err)
+	}
+}
+
+func TestReadBoolLenient(t *testing.T) {
+	var b []byte
+	b = AppendInt(b, 1)
+	b = AppendUint8(b, 0)
+	b = AppendInt64(b, 1)
+	b = AppendBool(b, true)
+	b = AppendInt(b, 2)
+
+	// Without SetLenientBools, ReadBool refuses integers.
+	if _, err := NewReader(bytes.NewReader(b)).ReadBool(); err == nil {
+		t.Error("ReadBool read an integer")
+	}
+
+	for _, lenient := range []bool{false, true} {
+		rd := NewReader(bytes.NewReader(b))
+		read := rd.ReadBoolLenient
+		if lenient {
+			rd.SetLenientBools(true)
+			read = rd.ReadBool
+		}
+		for _, want := range []bool{true, false, true, true} {
+			v, err := read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != want {
+				t.Errorf("read %t; want %t", v, want)
+			}
+		}
+		_, err := read()
+		if te, ok := err.(TypeError); !ok || te.Method != BoolType || te.Encoded != IntType {
+			t.Errorf("read 2 with the error %v; want a TypeError", err)
+		}
+		// The refused integer is left to be read.
+		if i, err := rd.ReadInt(); err != nil || i != 2 {
+			t.Errorf("read %d, %v after the refused integer; want 2", i, err)
+		}
+	}
+}
+
+// failingReader returns the data of b and then err.
+type failingReader struct {
+	b     []byte
+	err   error
+	reads int
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	r.reads++
+	if len(r.b) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.b)
+	r.b = r.b[n:]
+	return n, nil
+}
+
+func TestReaderStickyError(t *testing.T) {
+	b := AppendInt(nil, 1)
+	b = AppendString(b, strings.Repeat("x", 100))
+	src := &failingReader{b: b[:40], err: io.ErrClosedPipe}
+	rd := NewReaderSize(src, 16)
+	if i, err := rd.ReadInt(); err != nil || i != 1 {
+		t.Fatalf("read %d, %v; want 1", i, err)
+	}
+	if _, err := rd.ReadString(); err != io.ErrClosedPipe {
+		t.Fatalf("reading the cut string failed with %v; want io.ErrClosedPipe", err)
+	}
+	if rd.Err() != io.ErrClosedPipe {
+		t.Errorf("Err returns %v; want io.ErrClosedPipe", rd.Err())
+	}
+
+	// The later reads fail with the same error without reading from src.
+	reads := src.reads
+	src.b = AppendInt(nil, 2)
+	for i := 0; i < 3; i++ {
+		if err := rd.Skip(); err != io.ErrClosedPipe {
+			t.Errorf("Skip returned %v; want io.ErrClosedPipe", err)
+		}
+	}
+	if src.reads != reads {
+		t.Errorf("read %d more times from the failed reader", src.reads-reads)
+	}
+
+	// io.EOF is not kept, so that a stream can be read as it grows.
+	src = &failingReader{err: io.EOF}
+	rd.Reset(src)
+	if rd.Err() != nil {
+		t.Errorf("Err returns %v after Reset", rd.Err())
+	}
+	if _, err := rd.ReadInt(); err != io.EOF {
+		t.Errorf("reading at the end failed with %v; want io.EOF", err)
+	}
+	src.b = AppendInt(nil, 3)
+	if i, err := rd.ReadInt(); err != nil || i != 3 {
+		t.Errorf("read %d, %v after more data came; want 3", i, err)
+	}
+}
+
 func TestReadComplex64(t *testing.T) {
 	var buf bytes.Buffer
 	wr := NewWriter(&buf)
@@ -739,6 +1165,204 @@ func TestSkip(t *testing.T) {
 
 }
 
+func TestValidate(t *testing.T) {
+	var b []byte
+	b = AppendString(b, "a")
+	b = AppendMapHeader(b, 1)
+	b = AppendString(b, "list")
+	b = AppendArrayHeader(b, 2)
+	b = AppendInt(b, 1)
+	b = AppendBytes(b, make([]byte, 300))
+	b = AppendTime(b, time.Unix(1, 2))
+	valid := len(b)
+
+	if n, size, err := Validate(b); err != nil || n != 3 || size != valid {
+		t.Errorf("Validate returned %d objects, %d bytes, and %v; want 3, %d, and nil", n, size, err, valid)
+	}
+	if n, size, err := NewReader(bytes.NewReader(b)).Validate(); err != nil || n != 3 || size != int64(valid) {
+		t.Errorf("Reader.Validate returned %d objects, %d bytes, and %v; want 3, %d, and nil", n, size, err, valid)
+	}
+	if n, size, err := Validate(nil); err != nil || n != 0 || size != 0 {
+		t.Errorf("Validate(nil) returned %d objects, %d bytes, and %v", n, size, err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		bad   []byte
+		berr  error // the error of Validate
+		rderr error // the error of Reader.Validate
+	}{
+		{"invalid prefix", []byte{0xc1}, InvalidPrefixError(0xc1), InvalidPrefixError(0xc1)},
+		{"cut short", AppendString(nil, "abc")[:3], ErrShortBytes, io.ErrUnexpectedEOF},
+		{"missing elements", []byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x01}, ErrShortBytes, io.ErrUnexpectedEOF},
+		{"nested too deeply", append(bytes.Repeat([]byte{0x91}, MaxDepth+1), 0xc0), ErrMaxDepthExceeded, ErrMaxDepthExceeded},
+	} {
+		msg := append(b[:valid:valid], c.bad...)
+		if n, size, err := Validate(msg); err != c.berr || n != 3 || size != valid {
+			t.Errorf("%s: Validate returned %d objects, %d bytes, and %v; want 3, %d, and %v", c.name, n, size, err, valid, c.berr)
+		}
+		if n, size, err := NewReader(bytes.NewReader(msg)).Validate(); err != c.rderr || n != 3 || size != int64(valid) {
+			t.Errorf("%s: Reader.Validate returned %d objects, %d bytes, and %v; want 3, %d, and %v", c.name, n, size, err, valid, c.rderr)
+		}
+	}
+}
+
+func TestSkipN(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	wr.WriteString("a")
+	wr.WriteArrayHeader(2)
+	wr.WriteInt(1)
+	wr.WriteMapHeader(1)
+	wr.WriteString("b")
+	wr.WriteBool(true)
+	wr.WriteInt(3)
+	wr.Flush()
+
+	rd := NewReader(&buf)
+	if err := rd.SkipN(2); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := rd.ReadInt(); err != nil || i != 3 {
+		t.Errorf("read %d, %v after skipping; want 3", i, err)
+	}
+	if err := rd.SkipN(0); err != nil {
+		t.Errorf("skipping no objects: %v", err)
+	}
+	if err := rd.SkipN(1); err != io.EOF {
+		t.Errorf("skipping past the end: got %v; want %v", err, io.EOF)
+	}
+}
+
+func TestReadArrayBody(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	wr.WriteArrayHeader(3)
+	wr.WriteInt(1)
+	wr.WriteString("skipped")
+	wr.WriteInt(3)
+	wr.Flush()
+	encoded := buf.Bytes()
+
+	var got []int
+	rd := NewReader(bytes.NewReader(encoded))
+	err := rd.ReadArrayBody(func(r *Reader) error {
+		if len(got) == 1 {
+			got = append(got, 0)
+			return r.Skip()
+		}
+		i, err := r.ReadInt()
+		got = append(got, i)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []int{1, 0, 3}) {
+		t.Errorf("read %v", got)
+	}
+
+	// The first error stops the reading.
+	calls := 0
+	rd = NewReader(bytes.NewReader(encoded))
+	err = rd.ReadArrayBody(func(r *Reader) error {
+		calls++
+		_, err := r.ReadInt()
+		return err
+	})
+	if _, ok := err.(TypeError); !ok || calls != 2 {
+		t.Errorf("got the error %v after %d calls; want a TypeError after 2", err, calls)
+	}
+
+	// An array cut short is an unexpected EOF.
+	rd = NewReader(bytes.NewReader(encoded[:2]))
+	err = rd.ReadArrayBody(func(r *Reader) error {
+		_, err := r.ReadInt()
+		return err
+	})
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got the error %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	if err = NewReader(bytes.NewReader(encoded[1:])).ReadArrayBody(nil); err == nil {
+		t.Error("no error for a value that is not an array")
+	}
+}
+
+// nested returns depth arrays and maps nested within each other around a nil.
+func nested(depth int) []byte {
+	var b []byte
+	for i := 0; i < depth; i++ {
+		if i%2 == 0 {
+			b = AppendArrayHeader(b, 1)
+		} else {
+			b = AppendMapHeader(b, 1)
+			b = AppendString(b, "k")
+		}
+	}
+	return AppendNil(b)
+}
+
+func TestMaxDepth(t *testing.T) {
+	readers := map[string]func(b []byte, max int) error{
+		"ReadIntf": func(b []byte, max int) error {
+			rd := NewReader(bytes.NewReader(b))
+			rd.SetMaxDepth(max)
+			_, err := rd.ReadIntf()
+			return err
+		},
+		"Skip": func(b []byte, max int) error {
+			rd := NewReader(bytes.NewReader(b))
+			rd.SetMaxDepth(max)
+			return rd.Skip()
+		},
+		"WriteToJSON": func(b []byte, max int) error {
+			rd := NewReader(bytes.NewReader(b))
+			rd.SetMaxDepth(max)
+			_, err := rd.WriteToJSON(ioutil.Discard)
+			return err
+		},
+		"ReadIntfBytes": func(b []byte, _ int) error {
+			_, _, err := ReadIntfBytes(b)
+			return err
+		},
+		"SkipBytes": func(b []byte, _ int) error {
+			_, err := Skip(b)
+			return err
+		},
+		"UnmarshalAsJSON": func(b []byte, _ int) error {
+			_, err := UnmarshalAsJSON(ioutil.Discard, b)
+			return err
+		},
+	}
+
+	for name, read := range readers {
+		if err := read(nested(MaxDepth), 0); err != nil {
+			t.Errorf("%s: %d levels: %v", name, MaxDepth, err)
+		}
+		if err := read(nested(MaxDepth+1), 0); err != ErrMaxDepthExceeded {
+			t.Errorf("%s: %d levels: expected %v; got %v", name, MaxDepth+1, ErrMaxDepthExceeded, err)
+		}
+		if err := read(nested(100000), 0); err != ErrMaxDepthExceeded {
+			t.Errorf("%s: 100000 levels: expected %v; got %v", name, ErrMaxDepthExceeded, err)
+		}
+	}
+
+	// Readers can have their own limit.
+	for _, name := range []string{"ReadIntf", "Skip", "WriteToJSON"} {
+		if err := readers[name](nested(10), 10); err != nil {
+			t.Errorf("%s: 10 levels with a limit of 10: %v", name, err)
+		}
+		if err := readers[name](nested(11), 10); err != ErrMaxDepthExceeded {
+			t.Errorf("%s: 11 levels with a limit of 10: expected %v; got %v", name, ErrMaxDepthExceeded, err)
+		}
+	}
+
+	if ErrMaxDepthExceeded.(Error).Resumable() {
+		t.Error("ErrMaxDepthExceeded should not be resumable")
+	}
+}
+
 func BenchmarkSkip(b *testing.B) {
 
 	var buf bytes.Buffer
@@ -825,3 +1449,259 @@ func TestCopyNext(t *testing.T) {
 	}
 
 }
+
+func TestCopyNextFiltered(t *testing.T) {
+	in := map[string]interface{}{
+		"name":     "x",
+		"password": "secret",
+		"user": map[string]interface{}{
+			"email": "a@b.c",
+			"age":   int64(30),
+		},
+		"sessions": []interface{}{
+			map[string]interface{}{"token": "t", "id": int64(1)},
+		},
+	}
+	b, err := AppendIntf(nil, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = AppendInt(b, 7) // the object after
+
+	var paths []string
+	filter := func(path []string, typ Type) bool {
+		paths = append(paths, strings.Join(path, ".")+":"+typ.String())
+		last := path[len(path)-1]
+		return last != "password" && last != "email" && last != "token"
+	}
+	rd := NewReader(bytes.NewReader(b))
+	var w bytes.Buffer
+	n, err := rd.CopyNextFiltered(&w, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(w.Len()) {
+		t.Errorf("CopyNextFiltered returned %d; wrote %d bytes", n, w.Len())
+	}
+	got, rest, err := ReadIntfBytes(w.Bytes())
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("reading the copy: %v (%d bytes left)", err, len(rest))
+	}
+	want := map[string]interface{}{
+		"name":     "x",
+		"user":     map[string]interface{}{"age": int64(30)},
+		"sessions": []interface{}{map[string]interface{}{"id": int64(1)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("copied %v; want %v", got, want)
+	}
+	sort.Strings(paths)
+	wantPaths := []string{
+		"name:str", "password:str", "sessions.0.id:int", "sessions.0.token:str",
+		"sessions:array", "user.age:int", "user.email:str", "user:map",
+	}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("filtered the paths %q; want %q", paths, wantPaths)
+	}
+	if v, err := rd.ReadInt(); err != nil || v != 7 {
+		t.Errorf("read %d (%v) after the copied object", v, err)
+	}
+
+	// Non-string keys are named by their values.
+	b = AppendMapHeader(nil, 2)
+	b = AppendInt(b, 1)
+	b = AppendString(b, "one")
+	b = AppendInt(b, 2)
+	b = AppendString(b, "two")
+	w.Reset()
+	_, err = NewReader(bytes.NewReader(b)).CopyNextFiltered(&w, func(path []string, _ Type) bool { return path[0] != "2" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := AppendString(AppendInt(AppendMapHeader(nil, 1), 1), "one"); !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("copied %x; want %x", w.Bytes(), want)
+	}
+
+	if _, err := NewReader(bytes.NewReader(b[:len(b)-2])).CopyNextFiltered(&w, filter); err != ErrShortBytes {
+		t.Errorf("copying a cut object gave the error %v", err)
+	}
+}
+
+func TestReaderCount(t *testing.T) {
+	b := AppendString(nil, "hello")
+	b = AppendBytes(b, RandBytes(100))
+	b = AppendFloat64(b, 1)
+
+	for _, src := range []io.Reader{bytes.NewReader(b), bytes.NewBuffer(b)} {
+		rd := NewReaderSize(src, 16)
+		if _, err := rd.ReadString(); err != nil {
+			t.Fatal(err)
+		}
+		if c := rd.Count(); c != 6 {
+			t.Errorf("%T: count is %d after a string; want 6", src, c)
+		}
+		if err := rd.Skip(); err != nil {
+			t.Fatal(err)
+		}
+		if c := rd.Count(); c != 6+102 {
+			t.Errorf("%T: count is %d after skipping bytes; want %d", src, c, 6+102)
+		}
+		rd.ResetCount()
+		if _, err := rd.ReadFloat64(); err != nil {
+			t.Fatal(err)
+		}
+		if c := rd.Count(); c != 9 {
+			t.Errorf("%T: count is %d after reset and a float; want 9", src, c)
+		}
+		rd.Reset(bytes.NewReader(b))
+		if c := rd.Count(); c != 0 {
+			t.Errorf("%T: count is %d after Reset; want 0", src, c)
+		}
+	}
+}
+
+func TestDecodeEach(t *testing.T) {
+	var collected []int64
+	collect := func(d Decoder) error {
+		i, _ := d.(*Number).Int()
+		collected = append(collected, i)
+		return nil
+	}
+	factory := func() Decoder { return new(Number) }
+
+	b := AppendArrayHeader(nil, 3)
+	for i := int64(1); i <= 3; i++ {
+		b = AppendInt64(b, i*100)
+	}
+	if err := DecodeEach(NewReader(bytes.NewReader(b)), collect, factory); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(collected, []int64{100, 200, 300}) {
+		t.Errorf("DecodeEach: got %v", collected)
+	}
+
+	// Remove the array header to get a raw stream.
+	collected = nil
+	if err := DecodeStream(NewReader(bytes.NewReader(b[1:])), collect, factory); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(collected, []int64{100, 200, 300}) {
+		t.Errorf("DecodeStream: got %v", collected)
+	}
+
+	// The stream ends in the middle of an object.
+	collected = nil
+	err := DecodeStream(NewReader(bytes.NewReader(b[1:len(b)-1])), collect, factory)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v; got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// The array is shorter than its header says.
+	if err = DecodeEach(NewReader(bytes.NewReader(b[:len(b)-3])), collect, factory); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v; got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// The callback stops the iteration.
+	stop := errors.New("stop")
+	calls := 0
+	err = DecodeEach(NewReader(bytes.NewReader(b)), func(Decoder) error {
+		calls++
+		return stop
+	}, factory)
+	if err != stop || calls != 1 {
+		t.Errorf("expected the callback's error after 1 call; got %v after %d calls", err, calls)
+	}
+}
+
+func TestReaderContext(t *testing.T) {
+	var b []byte
+	b = AppendArrayHeader(b, 3)
+	for i := 0; i < 3; i++ {
+		b = AppendMapHeader(b, 1)
+		b = AppendString(b, "i")
+		b = AppendInt(b, i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rd := NewReader(bytes.NewReader(b))
+	rd.SetContext(ctx)
+	if _, err := rd.ReadIntf(); err != nil {
+		t.Fatalf("reading with a live context: %v", err)
+	}
+	cancel()
+
+	reads := map[string]func(*Reader) error{
+		"ReadArrayHeader": func(r *Reader) error { _, err := r.ReadArrayHeader(); return err },
+		"Skip":            func(r *Reader) error { return r.Skip() },
+		"ReadIntf":        func(r *Reader) error { _, err := r.ReadIntf(); return err },
+		"WriteToJSON":     func(r *Reader) error { _, err := r.WriteToJSON(ioutil.Discard); return err },
+	}
+	for name, read := range reads {
+		rd := NewReader(bytes.NewReader(b))
+		rd.SetContext(ctx)
+		if err := read(rd); err != context.Canceled {
+			t.Errorf("%s: expected %v; got %v", name, context.Canceled, err)
+		}
+	}
+
+	// Skipping a map nested in the array fails once the context is canceled.
+	ctx, cancel = context.WithCancel(context.Background())
+	rd = NewReader(bytes.NewReader(b))
+	rd.SetContext(ctx)
+	if _, err := rd.ReadArrayHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rd.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := rd.Skip(); err != context.Canceled {
+		t.Errorf("expected %v skipping after canceling; got %v", context.Canceled, err)
+	}
+
+	var hdr arrayHeaderDecoder
+	if err := DecodeContext(ctx, bytes.NewReader(b), &hdr); err != context.Canceled {
+		t.Errorf("DecodeContext: expected %v; got %v", context.Canceled, err)
+	}
+	if err := DecodeContext(context.Background(), bytes.NewReader(b), &hdr); err != nil || hdr != 3 {
+		t.Errorf("DecodeContext: got %d with error %v", hdr, err)
+	}
+
+	// The checks are turned off with a nil context.
+	rd = NewReader(bytes.NewReader(b))
+	rd.SetContext(ctx)
+	rd.SetContext(nil)
+	if err := rd.Skip(); err != nil {
+		t.Errorf("skipping with no context: %v", err)
+	}
+}
+
+// arrayHeaderDecoder decodes the size of an array.
+type arrayHeaderDecoder uint32
+
+func (a *arrayHeaderDecoder) DecodeMsg(r *Reader) error {
+	sz, err := r.ReadArrayHeader()
+	*a = arrayHeaderDecoder(sz)
+	return err
+}
+
+func TestReaderWriteTo(t *testing.T) {
+	obj := AppendString(nil, "first")
+	rest := bytes.Repeat(AppendString(nil, "rest"), 100) // larger than the read buffer
+	rd := NewReaderSize(bytes.NewReader(append(obj, rest...)), 64)
+	if s, err := rd.ReadString(); err != nil || s != "first" {
+		t.Fatalf("read %q with error %v", s, err)
+	}
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(rest)) || !bytes.Equal(buf.Bytes(), rest) {
+		t.Errorf("copied %d bytes (%x); want %x", n, buf.Bytes(), rest)
+	}
+	if rd.Count() != int64(len(obj)+len(rest)) {
+		t.Errorf("Count is %d; want %d", rd.Count(), len(obj)+len(rest))
+	}
+}
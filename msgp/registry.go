@@ -0,0 +1,81 @@
+package msgp
+
+import (
+	"sort"
+	"sync"
+)
+
+// A TypeSchema describes the encoding of a type whose methods were generated by msgp. The code
+// generated with the -registry flag registers a TypeSchema for each type with RegisterSchema, so
+// that generic tools (such as pretty-printers and metric extractors) can interpret the messages
+// of the types by their names without depending on the types.
+type TypeSchema struct {
+	// Name is the name of the type qualified by the import path of its package (or by the name
+	// of the package if the path could not be found), such as "example.com/shop.Order".
+	Name string
+
+	// Type is the type of the encoding: MapType for a struct written as a map and ArrayType
+	// for a struct written as an array (a tuple).
+	Type Type
+
+	// Fields describes the fields of a struct in the order in which they are written.
+	Fields []FieldSchema
+}
+
+// A FieldSchema describes a field of a struct in a TypeSchema.
+type FieldSchema struct {
+	Name string // the name of the field in Go
+	Key  string // the key of the field (a decimal integer for integer keys); empty for tuples
+
+	// Num is the integer key of the field if the keys are integers, and otherwise the position
+	// of the field in the struct (and so in the array of a tuple).
+	Num int
+
+	// Type is the type of the value as it is written (UintType for unsigned integers, although
+	// NextType reports the small ones as IntType), or InvalidType if the value can be of any
+	// type. A pointer field may also be nil.
+	Type Type
+
+	// Schema is the name of the TypeSchema of the value or, for a slice, array, or map, of the
+	// elements if it is registered by the same package, and otherwise empty.
+	Schema string
+}
+
+var registry struct {
+	sync.RWMutex
+	schemas map[string]TypeSchema
+}
+
+// RegisterSchema adds s to the schemas returned by Schemas and LookupSchema. It panics if a
+// schema of the same name is registered.
+func RegisterSchema(s TypeSchema) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.schemas[s.Name]; ok {
+		panic("msgp: RegisterSchema called twice for type " + s.Name)
+	}
+	if registry.schemas == nil {
+		registry.schemas = make(map[string]TypeSchema)
+	}
+	registry.schemas[s.Name] = s
+}
+
+// Schemas returns the registered schemas sorted by name.
+func Schemas() []TypeSchema {
+	registry.RLock()
+	defer registry.RUnlock()
+	out := make([]TypeSchema, 0, len(registry.schemas))
+	for _, s := range registry.schemas {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// LookupSchema returns the registered schema with the given name, if there is one.
+func LookupSchema(name string) (TypeSchema, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	s, ok := registry.schemas[name]
+	return s, ok
+}
@@ -0,0 +1,38 @@
+package msgp
+
+import "testing"
+
+func TestRegisterSchema(t *testing.T) {
+	point := TypeSchema{Name: "example.com/geo.Point", Type: ArrayType, Fields: []FieldSchema{
+		{Name: "X", Num: 0, Type: Float64Type},
+		{Name: "Y", Num: 1, Type: Float64Type},
+	}}
+	area := TypeSchema{Name: "example.com/geo.Area", Type: MapType, Fields: []FieldSchema{
+		{Name: "Corners", Key: "corners", Type: ArrayType, Schema: point.Name},
+	}}
+	RegisterSchema(point)
+	RegisterSchema(area)
+
+	if s, ok := LookupSchema(point.Name); !ok || len(s.Fields) != 2 || s.Type != ArrayType {
+		t.Errorf("looked up %+v, %v", s, ok)
+	}
+	if _, ok := LookupSchema("example.com/geo.Line"); ok {
+		t.Error("looked up a schema that was not registered")
+	}
+	var names []string
+	for _, s := range Schemas() {
+		if s.Name == point.Name || s.Name == area.Name {
+			names = append(names, s.Name)
+		}
+	}
+	if len(names) != 2 || names[0] != area.Name {
+		t.Errorf("got the schemas %v; want those of Area and Point in order", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a schema twice did not panic")
+		}
+	}()
+	RegisterSchema(point)
+}
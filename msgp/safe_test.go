@@ -0,0 +1,24 @@
+package msgp
+
+import (
+	"go/build"
+	"testing"
+)
+
+// TestNoUnsafe checks that the package does not import unsafe under any of the build tags of the
+// platforms that forbid it, so that code using unsafe must come with a fallback file for them.
+func TestNoUnsafe(t *testing.T) {
+	for _, tag := range []string{"purego", "tinygo", "appengine"} {
+		ctx := build.Default
+		ctx.BuildTags = []string{tag}
+		pkg, err := ctx.ImportDir(".", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range pkg.Imports {
+			if imp == "unsafe" {
+				t.Errorf("the package imports unsafe with the %s tag", tag)
+			}
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package msgp
 
+import (
+	"math"
+	"time"
+)
+
 // The sizes here are the worst-case (biggest) encoded sizes for each type, including the
 // prefix with the type information. For variable-length types like slices and strings,
 // the total encoded size is the prefix size plus the length of the actual object.
@@ -16,9 +21,13 @@ const (
 	UintSize       = Uint64Size
 	Float64Size    = 9
 	Float32Size    = 5
+	FloatSize      = Float64Size // the largest size of a float written by WriteFloat
 	Complex64Size  = 10
 	Complex128Size = 18
 
+	FixedInt64Size  = Int64Size  // the size of an integer written by WriteFixedInt64
+	FixedUint64Size = Uint64Size // the size of an integer written by WriteFixedUint64
+
 	ByteSize = 2
 	BoolSize = 1
 	NilSize  = 1
@@ -31,3 +40,200 @@ const (
 	StringPrefixSize    = 5
 	ExtensionPrefixSize = 6
 )
+
+// ExactSizer is implemented by types that can compute the exact number of bytes they occupy
+// when encoded to MessagePack. Unlike Msgsize, which returns a cheap upper bound, MsgsizeExact
+// looks at the actual values (such as the lengths of strings and the magnitudes of integers),
+// so a buffer of this size fits the encoded value with nothing to spare.
+type ExactSizer interface {
+	MsgsizeExact() int
+}
+
+// SizeOfMapHeader returns the encoded size of a map header for a map of sz elements.
+func SizeOfMapHeader(sz uint32) int {
+	switch {
+	case sz <= 15:
+		return 1
+	case sz <= math.MaxUint16:
+		return 3
+	default:
+		return 5
+	}
+}
+
+// SizeOfArrayHeader returns the encoded size of an array header for an array of sz elements.
+func SizeOfArrayHeader(sz uint32) int { return SizeOfMapHeader(sz) }
+
+// SizeOfInt64 returns the encoded size of i, which is also the encoded size of the smaller
+// signed integer types holding the same value.
+func SizeOfInt64(i int64) int {
+	switch {
+	case i >= -32 && i <= math.MaxInt8:
+		return 1
+	case i >= math.MinInt8 && i < -32:
+		return 2
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return 3
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// SizeOfUint64 returns the encoded size of u, which is also the encoded size of the smaller
+// unsigned integer types holding the same value.
+func SizeOfUint64(u uint64) int {
+	switch {
+	case u <= math.MaxInt8:
+		return 1
+	case u <= math.MaxUint8:
+		return 2
+	case u <= math.MaxUint16:
+		return 3
+	case u <= math.MaxUint32:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// fitsFloat32 says if f converts to a float32 and back with every bit of it (the sign of a zero
+// and the payload of a NaN included) unchanged.
+func fitsFloat32(f float64) bool {
+	return math.Float64bits(float64(float32(f))) == math.Float64bits(f)
+}
+
+// SizeOfFloat returns the encoded size of f written by WriteFloat or AppendFloat.
+func SizeOfFloat(f float64) int {
+	if fitsFloat32(f) {
+		return Float32Size
+	}
+	return Float64Size
+}
+
+// SizeOfString returns the encoded size of s.
+func SizeOfString(s string) int { return sizeOfStr(len(s)) }
+
+// SizeOfStringFromBytes returns the encoded size of b as a 'str' object.
+func SizeOfStringFromBytes(b []byte) int { return sizeOfStr(len(b)) }
+
+// sizeOfStr returns the encoded size of a 'str' object of sz bytes.
+func sizeOfStr(sz int) int {
+	switch {
+	case sz <= 31:
+		return 1 + sz
+	case sz <= math.MaxUint8:
+		return 2 + sz
+	case sz <= math.MaxUint16:
+		return 3 + sz
+	default:
+		return 5 + sz
+	}
+}
+
+// SizeOfBytes returns the encoded size of b as 'bin' data.
+func SizeOfBytes(b []byte) int { return sizeOfBin(len(b)) }
+
+// sizeOfBin returns the encoded size of a 'bin' object of sz bytes.
+func sizeOfBin(sz int) int {
+	switch {
+	case sz <= math.MaxUint8:
+		return 2 + sz
+	case sz <= math.MaxUint16:
+		return 3 + sz
+	default:
+		return 5 + sz
+	}
+}
+
+// SizeOfExtension returns the encoded size of e, including the header.
+func SizeOfExtension(e Extension) int {
+	l := e.Len()
+	switch l {
+	case 1, 2, 4, 8, 16:
+		return 2 + l
+	}
+	switch {
+	case l < math.MaxUint8:
+		return 3 + l
+	case l < math.MaxUint16:
+		return 4 + l
+	default:
+		return 6 + l
+	}
+}
+
+// SizeOfIntf returns the encoded size of the value of i as written by AppendIntf. Values that are
+// neither ExactSizers nor of the basic types are encoded to be measured, and values that cannot be
+// encoded have the size of what would be appended before the error.
+func SizeOfIntf(i interface{}) int {
+	switch i := i.(type) {
+	case nil:
+		return NilSize
+	case ExactSizer:
+		return i.MsgsizeExact()
+	case Marshaler:
+		b, _ := i.MarshalMsg(nil)
+		return len(b)
+	case Extension:
+		return SizeOfExtension(i)
+	case bool:
+		return BoolSize
+	case float32:
+		return Float32Size
+	case float64:
+		return Float64Size
+	case complex64:
+		return Complex64Size
+	case complex128:
+		return Complex128Size
+	case string:
+		return SizeOfString(i)
+	case []byte:
+		return SizeOfBytes(i)
+	case int8:
+		return SizeOfInt64(int64(i))
+	case int16:
+		return SizeOfInt64(int64(i))
+	case int32:
+		return SizeOfInt64(int64(i))
+	case int64:
+		return SizeOfInt64(i)
+	case int:
+		return SizeOfInt64(int64(i))
+	case uint:
+		return SizeOfUint64(uint64(i))
+	case uint8:
+		return SizeOfUint64(uint64(i))
+	case uint16:
+		return SizeOfUint64(uint64(i))
+	case uint32:
+		return SizeOfUint64(uint64(i))
+	case uint64:
+		return SizeOfUint64(i)
+	case time.Time:
+		return TimeSize
+	case map[string]interface{}:
+		s := SizeOfMapHeader(uint32(len(i)))
+		for key, val := range i {
+			s += SizeOfString(key) + SizeOfIntf(val)
+		}
+		return s
+	case map[string]string:
+		s := SizeOfMapHeader(uint32(len(i)))
+		for key, val := range i {
+			s += SizeOfString(key) + SizeOfString(val)
+		}
+		return s
+	case []interface{}:
+		s := SizeOfArrayHeader(uint32(len(i)))
+		for _, v := range i {
+			s += SizeOfIntf(v)
+		}
+		return s
+	default:
+		b, _ := AppendIntf(nil, i)
+		return len(b)
+	}
+}
@@ -0,0 +1,82 @@
+package msgp
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSizeOfIntegers(t *testing.T) {
+	ints := []int64{0, 1, -1, -32, -33, math.MaxInt8, math.MaxInt8 + 1, math.MinInt8, math.MinInt8 - 1,
+		math.MaxInt16, math.MaxInt16 + 1, math.MinInt16, math.MinInt16 - 1, math.MaxInt32, math.MaxInt32 + 1,
+		math.MinInt32, math.MinInt32 - 1, math.MaxInt64, math.MinInt64}
+	for _, i := range ints {
+		if got, want := SizeOfInt64(i), len(AppendInt64(nil, i)); got != want {
+			t.Errorf("SizeOfInt64(%d) = %d; want %d", i, got, want)
+		}
+	}
+	uints := []uint64{0, math.MaxInt8, math.MaxInt8 + 1, math.MaxUint8, math.MaxUint8 + 1, math.MaxUint16,
+		math.MaxUint16 + 1, math.MaxUint32, math.MaxUint32 + 1, math.MaxUint64}
+	for _, u := range uints {
+		if got, want := SizeOfUint64(u), len(AppendUint64(nil, u)); got != want {
+			t.Errorf("SizeOfUint64(%d) = %d; want %d", u, got, want)
+		}
+	}
+}
+
+func TestSizeOfLengths(t *testing.T) {
+	for _, n := range []int{0, 1, 31, 32, math.MaxUint8, math.MaxUint8 + 1, math.MaxUint16, math.MaxUint16 + 1} {
+		s := strings.Repeat("a", n)
+		if got, want := SizeOfString(s), len(AppendString(nil, s)); got != want {
+			t.Errorf("SizeOfString of length %d = %d; want %d", n, got, want)
+		}
+		if got, want := SizeOfStringFromBytes([]byte(s)), len(AppendStringFromBytes(nil, []byte(s))); got != want {
+			t.Errorf("SizeOfStringFromBytes of length %d = %d; want %d", n, got, want)
+		}
+		if got, want := SizeOfBytes([]byte(s)), len(AppendBytes(nil, []byte(s))); got != want {
+			t.Errorf("SizeOfBytes of length %d = %d; want %d", n, got, want)
+		}
+		if got, want := SizeOfMapHeader(uint32(n)), len(AppendMapHeader(nil, uint32(n))); got != want {
+			t.Errorf("SizeOfMapHeader(%d) = %d; want %d", n, got, want)
+		}
+		if got, want := SizeOfArrayHeader(uint32(n)), len(AppendArrayHeader(nil, uint32(n))); got != want {
+			t.Errorf("SizeOfArrayHeader(%d) = %d; want %d", n, got, want)
+		}
+	}
+	for _, n := range []int{0, 1, 2, 3, 4, 8, 16, 17, math.MaxUint8 - 1, math.MaxUint8, math.MaxUint16} {
+		e := &RawExtension{Type: 12, Data: make([]byte, n)}
+		b, err := AppendExtension(nil, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := SizeOfExtension(e); got != len(b) {
+			t.Errorf("SizeOfExtension with %d bytes of data = %d; want %d", n, got, len(b))
+		}
+	}
+}
+
+func TestSizeOfIntf(t *testing.T) {
+	vals := []interface{}{
+		nil, true, float32(1), 2.5, complex64(1), complex128(2), "hello", []byte("world"),
+		int8(-100), int16(300), int32(-70000), int64(math.MaxInt64), 5, uint(300), uint8(200),
+		uint16(60000), uint32(70000), uint64(math.MaxUint64), time.Now(),
+		map[string]interface{}{"a": 1, "b": "two", "c": []interface{}{1.5, nil}},
+		map[string]string{"x": strings.Repeat("y", 40)},
+		[]int{1, 1000, -1000000}, Raw(AppendString(nil, "raw")), &RawExtension{Type: 5, Data: []byte{1, 2, 3}},
+	}
+	var n1, n2, n3 Number
+	n1.AsInt(-5000)
+	n2.AsUint(200)
+	n3.AsFloat32(1.5)
+	vals = append(vals, &n1, &n2, &n3, &Number{})
+	for _, v := range vals {
+		b, err := AppendIntf(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := SizeOfIntf(v); got != len(b) {
+			t.Errorf("SizeOfIntf(%#v) = %d; want %d", v, got, len(b))
+		}
+	}
+}
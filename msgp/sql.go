@@ -0,0 +1,19 @@
+package msgp
+
+import "reflect"
+
+// ScanBytes returns the data of a value scanned from a database column holding MessagePack, as
+// the Scan methods generated with the -sql flag read it: src must be a []byte or a string. A nil
+// src (a NULL) gives nil data; other types give an *ErrUnsupportedType error. The returned slice
+// is src itself if src is a []byte, so it must not be retained after the scan.
+func ScanBytes(src interface{}) ([]byte, error) {
+	switch src := src.(type) {
+	case []byte:
+		return src, nil
+	case string:
+		return []byte(src), nil
+	case nil:
+		return nil, nil
+	}
+	return nil, &ErrUnsupportedType{T: reflect.TypeOf(src)}
+}
@@ -0,0 +1,24 @@
+package msgp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestScanBytes(t *testing.T) {
+	msg := AppendString(nil, "row")
+	for _, src := range []interface{}{msg, string(msg)} {
+		b, err := ScanBytes(src)
+		if err != nil || !bytes.Equal(b, msg) {
+			t.Errorf("ScanBytes(%T) = %x, %v", src, b, err)
+		}
+	}
+	if b, err := ScanBytes(nil); b != nil || err != nil {
+		t.Errorf("ScanBytes(nil) = %x, %v", b, err)
+	}
+	var unsupported *ErrUnsupportedType
+	if _, err := ScanBytes(1.5); !errors.As(err, &unsupported) {
+		t.Errorf("ScanBytes(1.5) returned %v", err)
+	}
+}
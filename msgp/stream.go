@@ -0,0 +1,84 @@
+package msgp
+
+import (
+	"errors"
+	"io"
+)
+
+var errNoDocument = errors.New("msgp: Stream.Value called without a document from Stream.Next")
+
+// A Stream reads a stream of concatenated objects (documents), such as a log to which records are
+// appended one after another. Its use is like that of a FrameScanner:
+//
+//  s := msgp.NewStream(msgp.NewReader(f))
+//  for s.Next() {
+//      var rec Record
+//      if err := s.Value(&rec); err != nil {
+//          // ...
+//      }
+//  }
+//  if err := s.Err(); err != nil {
+//      // ...
+//  }
+//
+// The documents for which Value is not called are skipped.
+type Stream struct {
+	r       *Reader
+	pending bool // a document is available and has not been read
+	err     error
+}
+
+// NewStream returns a Stream reading documents from r.
+func NewStream(r *Reader) *Stream { return &Stream{r: r} }
+
+// Next advances s to the next document, skipping the current document if Value was not called for
+// it. Next returns false when there are no more documents or when an error occurs.
+func (s *Stream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.pending {
+		s.pending = false
+		if err := s.r.Skip(); err != nil {
+			s.fail(err)
+			return false
+		}
+	}
+	if _, err := s.r.R.Peek(1); err != nil {
+		s.err = err
+		return false
+	}
+	s.pending = true
+	return true
+}
+
+// Value decodes the current document into d. Value may be called once for each call to Next that
+// returned true. The position of the next document is not known after d fails to decode the
+// document, so an error from d also stops s: Next then returns false, and Err returns the error.
+func (s *Stream) Value(d Decoder) error {
+	if !s.pending {
+		return errNoDocument
+	}
+	s.pending = false
+	if err := d.DecodeMsg(s.r); err != nil {
+		s.fail(err)
+		return s.err
+	}
+	return nil
+}
+
+// fail stops s with err, which occurred within a document.
+func (s *Stream) fail(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	s.err = err
+}
+
+// Err returns the error that stopped s, or nil if s reached the end of the stream between documents.
+func (s *Stream) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
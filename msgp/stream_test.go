@@ -0,0 +1,77 @@
+package msgp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	big := AppendString(nil, strings.Repeat("x", 10000)) // larger than the read buffer
+	docs := [][]byte{AppendInt(nil, 1), big, AppendMapHeader(AppendString(AppendMapHeader(nil, 1), "k"), 0), AppendNil(nil)}
+	stream := bytes.Join(docs, nil)
+
+	s := NewStream(NewReaderSize(bytes.NewReader(stream), 64))
+	var n int
+	for s.Next() {
+		// Every other document is skipped.
+		if n%2 == 1 {
+			n++
+			continue
+		}
+		var got Raw
+		if err := s.Value(&got); err != nil {
+			t.Fatalf("document %d: %v", n, err)
+		}
+		if !bytes.Equal(got, docs[n]) {
+			t.Errorf("document %d: got %x; want %x", n, []byte(got), docs[n])
+		}
+		if err := s.Value(&got); err != errNoDocument {
+			t.Errorf("document %d: expected an error from a second call to Value; got %v", n, err)
+		}
+		n++
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(docs) {
+		t.Errorf("read %d documents; want %d", n, len(docs))
+	}
+
+	// A stream ending within a document.
+	for _, value := range []bool{true, false} {
+		s = NewStream(NewReader(bytes.NewReader(stream[:len(stream)-2])))
+		for s.Next() {
+			if value {
+				s.Value(new(Raw))
+			}
+		}
+		if err := s.Err(); err != io.ErrUnexpectedEOF {
+			t.Errorf("expected io.ErrUnexpectedEOF at a truncated document; got %v", err)
+		}
+	}
+
+	// A decoding error stops the stream.
+	s = NewStream(NewReader(bytes.NewReader(stream)))
+	if !s.Next() {
+		t.Fatal(s.Err())
+	}
+	var text textDoc
+	err := s.Value(&text)
+	if _, ok := err.(TypeError); !ok {
+		t.Fatalf("expected a TypeError; got %v", err)
+	}
+	if s.Next() || s.Err() != err {
+		t.Errorf("the stream did not stop at %v; Err() returns %v", err, s.Err())
+	}
+}
+
+// A textDoc is a document holding a string.
+type textDoc string
+
+func (d *textDoc) DecodeMsg(r *Reader) error {
+	s, err := r.ReadString()
+	*d = textDoc(s)
+	return err
+}
@@ -0,0 +1,66 @@
+package msgp
+
+import (
+	"encoding"
+)
+
+// WriteText writes the text form of v (the data returned by its MarshalText method) as a 'str'
+// object. The generated methods write the fields tagged with the "text" option this way.
+func (mw *Writer) WriteText(v encoding.TextMarshaler) error {
+	data, err := v.MarshalText()
+	if err != nil {
+		return err
+	}
+	return mw.WriteStringFromBytes(data)
+}
+
+// AppendText appends the text form of v to b as a 'str' object.
+func AppendText(b []byte, v encoding.TextMarshaler) ([]byte, error) {
+	data, err := v.MarshalText()
+	if err != nil {
+		return b, err
+	}
+	return AppendString(b, string(data)), nil
+}
+
+// ReadText reads a 'str' object and unmarshals its data into v. The data passed to the
+// UnmarshalText method of v is valid only until the method returns.
+func (m *Reader) ReadText(v encoding.TextUnmarshaler) error {
+	var err error
+	m.scratch, err = m.ReadStringAsBytes(m.scratch[:0])
+	if err != nil {
+		return err
+	}
+	return v.UnmarshalText(m.scratch)
+}
+
+// ReadTextBytes reads a 'str' object from b, unmarshals its data into v, and returns any
+// remaining bytes. The data passed to the UnmarshalText method of v points into b.
+func ReadTextBytes(b []byte, v encoding.TextUnmarshaler) ([]byte, error) {
+	data, o, err := ReadStringZC(b)
+	if err != nil {
+		return b, err
+	}
+	if err = v.UnmarshalText(data); err != nil {
+		return b, err
+	}
+	return o, nil
+}
+
+// SizeOfText returns the encoded size of the text form of v, which it marshals to measure. If v
+// fails to marshal, the size of an empty 'str' object is returned.
+func SizeOfText(v encoding.TextMarshaler) int {
+	data, _ := v.MarshalText()
+	return SizeOfString(string(data))
+}
+
+// EqualText says if the text forms of a and b are the same. Values that fail to marshal are not
+// equal to anything.
+func EqualText(a, b encoding.TextMarshaler) bool {
+	da, err := a.MarshalText()
+	if err != nil {
+		return false
+	}
+	db, err := b.MarshalText()
+	return err == nil && string(da) == string(db)
+}
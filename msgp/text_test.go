@@ -0,0 +1,57 @@
+package msgp
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	// netip.Addr implements encoding.TextMarshaler and encoding.TextUnmarshaler.
+	in := netip.MustParseAddr("2001:db8::68")
+
+	b, err := AppendText(nil, &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := AppendString(nil, in.String()); !bytes.Equal(b, want) {
+		t.Errorf("AppendText wrote %x; want %x", b, want)
+	}
+	if n := SizeOfText(&in); n != len(b) {
+		t.Errorf("SizeOfText() = %d; want %d", n, len(b))
+	}
+
+	var out netip.Addr
+	left, err := ReadTextBytes(b, &out)
+	if err != nil || len(left) != 0 || out != in {
+		t.Errorf("ReadTextBytes read %v with %d bytes left and error %v", out, len(left), err)
+	}
+	if !EqualText(&in, &out) {
+		t.Errorf("EqualText says %v and %v differ", in, out)
+	}
+	if EqualText(&in, &netip.Addr{}) {
+		t.Errorf("EqualText says %v and the zero address are equal", in)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteText(&in); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("WriteText wrote %x; want %x", buf.Bytes(), b)
+	}
+	out = netip.Addr{}
+	if err := NewReader(&buf).ReadText(&out); err != nil || out != in {
+		t.Errorf("ReadText read %v with error %v", out, err)
+	}
+
+	// The data is checked by UnmarshalText.
+	if _, err := ReadTextBytes(AppendString(nil, "not an address"), &out); err == nil {
+		t.Error("ReadTextBytes read an invalid address")
+	}
+	if _, err := ReadTextBytes(AppendBytes(nil, []byte(in.String())), &out); err == nil {
+		t.Error("ReadTextBytes read a 'bin' object")
+	}
+}
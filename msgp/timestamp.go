@@ -0,0 +1,192 @@
+package msgp
+
+import "time"
+
+// TimestampExtension is the timestamp extension type defined by the MessagePack specification,
+// which other implementations read and write times as. Unlike TimeExtension, its data takes one
+// of three sizes: 4 bytes holding the seconds since the Unix epoch as a uint32, 8 bytes holding
+// the nanoseconds in the upper 30 bits and the seconds in the lower 34 bits, or 12 bytes holding
+// the nanoseconds as a uint32 followed by the seconds as an int64 (all big-endian).
+const TimestampExtension = -1
+
+// A TimeFormat selects the extension written by WriteTimeExt and AppendTimeExt, so that the
+// producers writing times can move from one extension to the other once all of their consumers
+// read both (as ReadTimeExt and ReadTimeExtBytes do).
+type TimeFormat uint8
+
+const (
+	// TimeFormatMsgp writes times as TimeExtension extensions, as WriteTime and AppendTime do.
+	TimeFormatMsgp TimeFormat = iota
+
+	// TimeFormatSpec writes times as TimestampExtension extensions of the smallest size holding
+	// them.
+	TimeFormatSpec
+)
+
+// timestampSize returns the encoded size of t as a TimestampExtension.
+func timestampSize(t time.Time) int {
+	sec := t.Unix()
+	switch {
+	case sec>>34 != 0:
+		return 15 // ext8 with 12 bytes
+	case t.Nanosecond() != 0 || sec>>32 != 0:
+		return 10 // fixext8
+	default:
+		return 6 // fixext4
+	}
+}
+
+// appendTimestamp appends t to b as a TimestampExtension.
+func appendTimestamp(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), t.Nanosecond()
+	o, n := ensure(b, timestampSize(t))
+	switch len(o) - n {
+	case 6:
+		o[n] = mfixext4
+		o[n+1] = byte(TimestampExtension & 0xff)
+		big.PutUint32(o[n+2:], uint32(sec))
+	case 10:
+		o[n] = mfixext8
+		o[n+1] = byte(TimestampExtension & 0xff)
+		big.PutUint64(o[n+2:], uint64(nsec)<<34|uint64(sec))
+	default:
+		o[n] = mext8
+		o[n+1] = 12
+		o[n+2] = byte(TimestampExtension & 0xff)
+		big.PutUint32(o[n+3:], uint32(nsec))
+		big.PutUint64(o[n+7:], uint64(sec))
+	}
+	return o
+}
+
+// AppendTimeExt appends t to b as the extension selected by f.
+func AppendTimeExt(b []byte, t time.Time, f TimeFormat) []byte {
+	if f == TimeFormatSpec {
+		return appendTimestamp(b, t)
+	}
+	return AppendTime(b, t)
+}
+
+// WriteTimeExt writes t as the extension selected by f.
+func (mw *Writer) WriteTimeExt(t time.Time, f TimeFormat) error {
+	if f != TimeFormatSpec {
+		return mw.WriteTime(t)
+	}
+	i, err := mw.require(timestampSize(t))
+	if err != nil {
+		return err
+	}
+	appendTimestamp(mw.buf[i:i], t) // The room is taken in place.
+	return nil
+}
+
+// SizeOfTimeExt returns the encoded size of t written as the extension selected by f.
+func SizeOfTimeExt(t time.Time, f TimeFormat) int {
+	if f == TimeFormatSpec {
+		return timestampSize(t)
+	}
+	return TimeSize
+}
+
+// timeExtSize returns the encoded size of the extension beginning p, whose first bytes (two for
+// an ext8 object) must be in p, or an error if the object is not an extension of a size that a
+// TimeExtension or TimestampExtension can have.
+func timeExtSize(p []byte) (int, error) {
+	switch p[0] {
+	case mfixext4:
+		return 6, nil
+	case mfixext8:
+		return 10, nil
+	case mext8:
+		if len(p) < 2 {
+			return 0, ErrShortBytes
+		}
+		if p[1] == 12 {
+			return 15, nil
+		}
+	}
+	return 0, badPrefix(TimeType, p[0])
+}
+
+// readTimeExt reads the time in p, which holds exactly the extension whose size is returned by
+// timeExtSize.
+func readTimeExt(p []byte) (time.Time, error) {
+	var typ int8
+	if p[0] == mext8 {
+		typ = int8(p[2])
+	} else {
+		typ = int8(p[1])
+	}
+	if typ == TimeExtension && p[0] == mext8 {
+		sec, nsec := getUnix(p[3:])
+		return time.Unix(sec, int64(nsec)).Local(), nil
+	}
+	if typ != TimestampExtension {
+		return time.Time{}, errExt(typ, TimestampExtension)
+	}
+	var sec int64
+	var nsec uint32
+	switch p[0] {
+	case mfixext4:
+		sec = int64(big.Uint32(p[2:]))
+	case mfixext8:
+		v := big.Uint64(p[2:])
+		sec, nsec = int64(v&(1<<34-1)), uint32(v>>34)
+	default:
+		nsec, sec = big.Uint32(p[3:]), int64(big.Uint64(p[7:]))
+	}
+	if nsec >= 1e9 {
+		return time.Time{}, badPrefix(TimeType, p[0])
+	}
+	return time.Unix(sec, int64(nsec)).Local(), nil
+}
+
+// ReadTimeExt reads a time written as either a TimeExtension (as by WriteTime) or a
+// TimestampExtension, telling them apart by their extension types. The returned time's location
+// is set to time.Local. An extension of another type is reported with an ExtensionTypeError
+// wanting a TimestampExtension. The generated DecodeMsg methods read times with ReadTimeExt.
+func (m *Reader) ReadTimeExt() (time.Time, error) {
+	p, err := m.R.Peek(1)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if p[0] == mext8 {
+		if p, err = m.R.Peek(2); err != nil {
+			return time.Time{}, err
+		}
+	}
+	size, err := timeExtSize(p)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if p, err = m.R.Peek(size); err != nil {
+		return time.Time{}, err
+	}
+	t, err := readTimeExt(p)
+	if err != nil {
+		return t, err
+	}
+	_, err = m.R.Skip(size)
+	return t, err
+}
+
+// ReadTimeExtBytes reads a time written as either a TimeExtension or a TimestampExtension from b,
+// like Reader.ReadTimeExt, and returns any remaining bytes. The generated UnmarshalMsg methods read
+// times with ReadTimeExtBytes.
+func ReadTimeExtBytes(b []byte) (time.Time, []byte, error) {
+	if len(b) < 1 {
+		return time.Time{}, b, ErrShortBytes
+	}
+	size, err := timeExtSize(b)
+	if err != nil {
+		return time.Time{}, b, err
+	}
+	if len(b) < size {
+		return time.Time{}, b, ErrShortBytes
+	}
+	t, err := readTimeExt(b[:size])
+	if err != nil {
+		return t, b, err
+	}
+	return t, b[size:], nil
+}
@@ -0,0 +1,65 @@
+package msgp
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimeExt(t *testing.T) {
+	cases := []struct {
+		t    time.Time
+		spec string // the hex encoding with TimeFormatSpec
+	}{
+		{time.Unix(0, 0), "d6ff00000000"},
+		{time.Unix(1500000000, 0), "d6ff59682f00"},
+		{time.Unix(1500000000, 5), "d7ff0000001459682f00"},
+		{time.Unix(1<<32, 0), "d7ff0000000100000000"},
+		{time.Unix(1<<34, 7), "c70cff000000070000000400000000"},
+		{time.Unix(-1, 999999999), "c70cff3b9ac9ffffffffffffffffff"},
+	}
+	for i, c := range cases {
+		for _, f := range []TimeFormat{TimeFormatMsgp, TimeFormatSpec} {
+			b := AppendTimeExt(nil, c.t, f)
+			if f == TimeFormatSpec && fmt.Sprintf("%x", b) != c.spec {
+				t.Errorf("case %d: appended %x; want %s", i, b, c.spec)
+			}
+			if f == TimeFormatMsgp && !bytes.Equal(b, AppendTime(nil, c.t)) {
+				t.Errorf("case %d: appended %x with TimeFormatMsgp", i, b)
+			}
+			if n := SizeOfTimeExt(c.t, f); n != len(b) {
+				t.Errorf("case %d: SizeOfTimeExt = %d; want %d", i, n, len(b))
+			}
+
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			if err := w.WriteTimeExt(c.t, f); err != nil || w.Flush() != nil || !bytes.Equal(buf.Bytes(), b) {
+				t.Errorf("case %d: WriteTimeExt wrote %x (%v); want %x", i, buf.Bytes(), err, b)
+			}
+
+			got, o, err := ReadTimeExtBytes(b)
+			if err != nil || !got.Equal(c.t) || len(o) != 0 {
+				t.Errorf("case %d: ReadTimeExtBytes = %v, %x, %v; want %v", i, got, o, err, c.t)
+			}
+			if got, err = NewReader(&buf).ReadTimeExt(); err != nil || !got.Equal(c.t) {
+				t.Errorf("case %d: ReadTimeExt = %v, %v; want %v", i, got, err, c.t)
+			}
+		}
+	}
+
+	b, _ := AppendExtension(nil, &RawExtension{Type: 9, Data: make([]byte, 4)})
+	if _, _, err := ReadTimeExtBytes(b); err != (ExtensionTypeError{Got: 9, Want: TimestampExtension}) {
+		t.Errorf("reading an extension of type 9 returned %v", err)
+	}
+	if _, _, err := ReadTimeExtBytes(AppendString(nil, "now")); err == nil {
+		t.Error("no error reading a string as a time")
+	}
+	// The nanoseconds must be less than a second.
+	if _, _, err := ReadTimeExtBytes([]byte{mfixext8, 0xff, 0xff, 0xff, 0xff, 0xfc, 0, 0, 0, 0}); err == nil {
+		t.Error("no error reading a timestamp of too many nanoseconds")
+	}
+	if _, err := NewReader(bytes.NewReader(AppendTime(nil, time.Now())[:9])).ReadTimeExt(); err == nil {
+		t.Error("no error reading a short time")
+	}
+}
@@ -1,10 +1,9 @@
 package msgp
 
 import (
-	"errors"
-	"fmt"
 	"io"
 	"math"
+	"net"
 	"reflect"
 	"time"
 )
@@ -32,6 +31,15 @@ type Marshaler interface {
 	MarshalMsg([]byte) ([]byte, error)
 }
 
+// Appender is the interface implemented by types that marshal themselves as MessagePack without
+// the possibility of failing. AppendMsg appends the marshalled form of the object to the provided
+// byte slice and returns the extended slice. The code generator writes AppendMsg methods for the
+// types whose MarshalMsg methods could not return an error (such as types with no interface{}
+// or extension fields), and their MarshalMsg methods call AppendMsg.
+type Appender interface {
+	AppendMsg([]byte) []byte
+}
+
 // Encoder is the interface implemented by types that know how to write themselves
 // as MessagePack using a *msgp.Writer.
 type Encoder interface {
@@ -46,10 +54,34 @@ type MarshalSizer interface {
 
 // Writer is a buffered writer that can be used to write MessagePack objects to an io.Writer.
 // You must call *Writer.Flush() to flush all of the buffered data to the underlying writer.
+// Once the underlying writer fails, every write and Flush return its error (see Err).
 type Writer struct {
 	w    io.Writer
 	buf  []byte
 	wLoc int // The index at which to write.
+
+	written int64 // the number of bytes written to w (less the bytes buffered at the last ResetCount)
+
+	track   *tracker // set if an Observer is set
+	scanned int      // the index in buf up to which data has been given to track
+
+	vecMin int // the length from which slices are written with the buffered data in one vectored write
+
+	manual  bool      // automatic flushing is off, so the buffer grows instead
+	growMax int       // the size up to which the buffer grows rather than being flushed
+	onFlush func(int) // called with the number of bytes after every write to w
+	flushes int64     // the number of writes to w
+	grows   int64     // the number of times the buffer has grown
+
+	maxMsg   int64 // the limit on the size of a message (none if zero)
+	inMsg    bool  // a message begun by BeginMessage is being written
+	msgStart int64 // the Count at which the message being written began
+
+	marshaled []byte // the buffer into which WriteIntf marshals the Marshalers that are not Encoders
+
+	// err is the first error of w, returned by every later write. The buffer is then cut to the
+	// buffered data, so that the writes needing room (every write of some bytes) find none.
+	err error
 }
 
 // NewWriter creates a new Writer.
@@ -88,23 +120,199 @@ func Encode(w io.Writer, e Encoder) error {
 
 // Flush flushes all of the buffered data to the underlying writer.
 func (mw *Writer) Flush() error {
+	if mw.err != nil {
+		return mw.err
+	}
 	if mw.wLoc == 0 {
 		return nil
 	}
+	if err := mw.checkMessage(0); err != nil {
+		return err
+	}
+	if mw.track != nil {
+		mw.track.Write(mw.buf[mw.scanned:mw.wLoc])
+		mw.scanned = mw.wLoc
+	}
 	n, err := mw.w.Write(mw.buf[:mw.wLoc])
+	mw.flushed(n)
 	if err != nil {
 		if n > 0 {
 			mw.wLoc = copy(mw.buf, mw.buf[n:mw.wLoc])
+			mw.scanned = mw.wLoc
 		}
-		return err
+		return mw.fail(err)
 	}
 	if n < mw.wLoc {
-		return io.ErrShortWrite
+		return mw.fail(io.ErrShortWrite)
 	}
 	mw.wLoc = 0
+	mw.scanned = 0
+	return nil
+}
+
+// flushed counts the n bytes just written to the underlying writer and calls the flush hook.
+func (mw *Writer) flushed(n int) {
+	mw.written += int64(n)
+	mw.flushes++
+	if mw.onFlush != nil && n > 0 {
+		mw.onFlush(n)
+	}
+}
+
+// fail records err, an error of the underlying writer, as the error of every later write, and
+// returns it. The data still buffered is kept but never written.
+func (mw *Writer) fail(err error) error {
+	mw.err = err
+	mw.buf = mw.buf[:mw.wLoc]
+	return err
+}
+
+// Err returns the first error of the underlying writer, which every write and Flush have
+// returned since, or nil if there has been none. Reset clears it.
+func (mw *Writer) Err() error { return mw.err }
+
+// makeRoom makes room in the buffer for n more bytes when it is too full for a write. It flushes
+// the buffer, after which the buffer may still be too small for n bytes, or it grows the buffer to
+// hold them: always with automatic flushing off, and otherwise up to the size set with
+// SetMaxBufferSize, flushing the buffer first if it cannot grow enough for the buffered data too.
+func (mw *Writer) makeRoom(n int) error {
+	if mw.err != nil {
+		return mw.err
+	}
+	if !mw.manual && mw.wLoc+n > mw.growMax {
+		if err := mw.Flush(); err != nil {
+			return err
+		}
+		if mw.OpenSpace() >= n || n > mw.growMax {
+			return nil
+		}
+	}
+	if err := mw.checkMessage(n); err != nil {
+		return err
+	}
+	if mw.OpenSpace() < n {
+		size := 2 * len(mw.buf)
+		if size < mw.wLoc+n {
+			size = mw.wLoc + n
+		}
+		if !mw.manual && size > mw.growMax {
+			size = mw.growMax
+		}
+		buf := make([]byte, size)
+		copy(buf, mw.buf[:mw.wLoc])
+		mw.buf = buf
+		mw.grows++
+	}
 	return nil
 }
 
+// SetAutoFlush turns automatic flushing on or off. It is on by default: the Writer writes its
+// buffered data to the underlying writer whenever the buffer fills, which may be in the middle of
+// an object. With it off, the buffer grows to hold everything written until Flush is called, so
+// the data reaches the underlying writer only in the pieces given to Flush, as callers that wrap
+// the output in compressors or block ciphers may need to align the pieces with the messages.
+// Vectored writes (see SetVectored) are not made while automatic flushing is off.
+func (mw *Writer) SetAutoFlush(on bool) { mw.manual = !on }
+
+// SetFlushThreshold sets the number of buffered bytes from which the Writer flushes automatically,
+// which is the size of its buffer: at least 18 bytes, as with NewWriterSize, and never less than
+// the data buffered, which is kept.
+func (mw *Writer) SetFlushThreshold(n int) {
+	if mw.err != nil {
+		return // the buffer stays without room
+	}
+	if n < 18 {
+		n = 18
+	}
+	if n < mw.wLoc {
+		n = mw.wLoc
+	}
+	buf := make([]byte, n)
+	copy(buf, mw.buf[:mw.wLoc])
+	mw.buf = buf
+}
+
+// SetMaxBufferSize lets the buffer grow, by doubling, up to n bytes when a write does not fit in it
+// rather than being flushed, with automatic flushing on. The buffer is flushed only once it cannot
+// grow enough, so a Writer writing large objects (such as long strings) settles on a buffer fitting
+// them and flushes in fewer and larger writes, as Stats shows. A limit no larger than the buffer,
+// such as 0 (the default), keeps the buffer at its size. The buffer never shrinks.
+func (mw *Writer) SetMaxBufferSize(n int) { mw.growMax = n }
+
+// WriterStats holds the counters of a Writer, as returned by Writer.Stats.
+type WriterStats struct {
+	Flushes    int64 // the writes of data to the underlying writer, by Flush or automatically
+	Grows      int64 // the times the buffer has grown
+	BufferSize int   // the size of the buffer
+}
+
+// Stats returns the counters of mw, which are set to zero by Reset.
+func (mw *Writer) Stats() WriterStats {
+	return WriterStats{Flushes: mw.flushes, Grows: mw.grows, BufferSize: len(mw.buf)}
+}
+
+// SetFlushHook sets a function called after every write of data to the underlying writer, by Flush
+// or automatically, with the number of bytes written. A nil f removes the hook.
+func (mw *Writer) SetFlushHook(f func(n int)) { mw.onFlush = f }
+
+// Count returns the number of bytes written to the Writer, including both the bytes flushed to
+// the underlying writer and the bytes buffered, since the Writer was created or last reset.
+func (mw *Writer) Count() int64 { return mw.written + int64(mw.wLoc) }
+
+// ResetCount sets the count returned by Count to zero.
+func (mw *Writer) ResetCount() {
+	mw.msgStart -= mw.Count()
+	mw.written = -int64(mw.wLoc)
+}
+
+// SetMaxMessageSize sets the limit on the size of each message written between calls to
+// BeginMessage and EndMessage, so that a producer writing runaway messages does not exceed the
+// limits of a broker or peer. A message exceeding n bytes fails with ErrMessageTooLarge as soon as
+// the excess would be written to the underlying writer (or, with automatic flushing off, would
+// grow the buffer) and at the latest at EndMessage. A limit of 0 or less removes the limit.
+func (mw *Writer) SetMaxMessageSize(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	mw.maxMsg = n
+}
+
+// BeginMessage marks the start of a message whose size is limited by SetMaxMessageSize. A message
+// is typically one call of an EncodeMsg method.
+func (mw *Writer) BeginMessage() {
+	mw.inMsg = true
+	mw.msgStart = mw.Count()
+}
+
+// EndMessage marks the end of the message started by BeginMessage. It returns ErrMessageTooLarge
+// if the message exceeds the size limit, after dropping the data of the message still buffered.
+// If the failure was already returned by a write, EndMessage returns nil.
+func (mw *Writer) EndMessage() error {
+	err := mw.checkMessage(0)
+	mw.inMsg = false
+	return err
+}
+
+// checkMessage returns ErrMessageTooLarge if the message being written exceeds its size limit with
+// extra more bytes. The buffered data of the message is then dropped and the message ended, so that
+// none of the excess is written: if the message is all still buffered, the stream is left just as
+// it was before the message.
+func (mw *Writer) checkMessage(extra int) error {
+	if mw.maxMsg == 0 || !mw.inMsg || mw.Count()+int64(extra)-mw.msgStart <= mw.maxMsg {
+		return nil
+	}
+	keep := mw.msgStart - mw.written
+	if keep < 0 {
+		keep = 0
+	}
+	mw.wLoc = int(keep)
+	if mw.scanned > mw.wLoc {
+		mw.scanned = mw.wLoc
+	}
+	mw.inMsg = false
+	return ErrMessageTooLarge
+}
+
 // OpenSpace returns the number of bytes currently free for writing to the write buffer.
 func (mw *Writer) OpenSpace() int { return len(mw.buf) - mw.wLoc }
 
@@ -116,7 +324,7 @@ func (mw *Writer) OpenSpace() int { return len(mw.buf) - mw.wLoc }
 func (mw *Writer) require(n int) (int, error) {
 	wl := mw.wLoc
 	if mw.OpenSpace() < n {
-		if err := mw.Flush(); err != nil {
+		if err := mw.makeRoom(n); err != nil {
 			return 0, err
 		}
 		wl = mw.wLoc
@@ -125,11 +333,30 @@ func (mw *Writer) require(n int) (int, error) {
 	return wl, nil
 }
 
+// Reserve returns an empty slice of the write buffer with a capacity of at least n bytes, flushing
+// the buffer (or growing it, if it is smaller than n bytes) as needed. The bytes appended to the
+// slice, without exceeding its capacity, are added to the buffered data by passing the slice to
+// Commit. Nothing else may be written to the Writer between the calls to Reserve and Commit.
+func (mw *Writer) Reserve(n int) ([]byte, error) {
+	if mw.OpenSpace() < n {
+		if err := mw.makeRoom(n); err != nil {
+			return nil, err
+		}
+		if len(mw.buf) < n {
+			mw.buf = make([]byte, n)
+		}
+	}
+	return mw.buf[mw.wLoc:mw.wLoc], nil
+}
+
+// Commit adds to the buffered data the bytes appended to a slice returned by Reserve.
+func (mw *Writer) Commit(b []byte) { mw.wLoc += len(b) }
+
 // Append can be used to append a few (no more than the total buffer length) single
 // bytes to the buffer.
 func (mw *Writer) Append(bts ...byte) error {
 	if mw.OpenSpace() < len(bts) {
-		if err := mw.Flush(); err != nil {
+		if err := mw.makeRoom(len(bts)); err != nil {
 			return err
 		}
 	}
@@ -140,7 +367,7 @@ func (mw *Writer) Append(bts ...byte) error {
 // push pushes one byte onto the buffer.
 func (mw *Writer) push(b byte) error {
 	if mw.wLoc == len(mw.buf) {
-		if err := mw.Flush(); err != nil {
+		if err := mw.makeRoom(1); err != nil {
 			return err
 		}
 	}
@@ -152,7 +379,7 @@ func (mw *Writer) push(b byte) error {
 func (mw *Writer) prefix8(b byte, u uint8) error {
 	const need = 2
 	if mw.OpenSpace() < need {
-		if err := mw.Flush(); err != nil {
+		if err := mw.makeRoom(need); err != nil {
 			return err
 		}
 	}
@@ -164,7 +391,7 @@ func (mw *Writer) prefix8(b byte, u uint8) error {
 func (mw *Writer) prefix16(b byte, u uint16) error {
 	const need = 3
 	if mw.OpenSpace() < need {
-		if err := mw.Flush(); err != nil {
+		if err := mw.makeRoom(need); err != nil {
 			return err
 		}
 	}
@@ -176,7 +403,7 @@ func (mw *Writer) prefix16(b byte, u uint16) error {
 func (mw *Writer) prefix32(b byte, u uint32) error {
 	const need = 5
 	if mw.OpenSpace() < need {
-		if err := mw.Flush(); err != nil {
+		if err := mw.makeRoom(need); err != nil {
 			return err
 		}
 	}
@@ -188,7 +415,7 @@ func (mw *Writer) prefix32(b byte, u uint32) error {
 func (mw *Writer) prefix64(b byte, u uint64) error {
 	const need = 9
 	if mw.OpenSpace() < need {
-		if err := mw.Flush(); err != nil {
+		if err := mw.makeRoom(need); err != nil {
 			return err
 		}
 	}
@@ -197,35 +424,129 @@ func (mw *Writer) prefix64(b byte, u uint64) error {
 	return nil
 }
 
+// SetVectored makes the Writer write the slices of at least min bytes given to Write (and so to
+// WriteBytes and WriteStringFromBytes) without copying them into the buffer: the buffered data and
+// the slice are written to the underlying writer together as net.Buffers, which is a single writev
+// system call for the connections of the net package. A min of 0 turns vectored writes off.
+func (mw *Writer) SetVectored(min int) { mw.vecMin = min }
+
 // Write implements io.Writer to write directly to the buffer.
 func (mw *Writer) Write(p []byte) (int, error) {
 	l := len(p)
+	if mw.vecMin > 0 && l >= mw.vecMin && !mw.manual {
+		return mw.writeVectored(p)
+	}
 	if mw.OpenSpace() < l {
-		if err := mw.Flush(); err != nil {
+		if err := mw.checkMessage(l); err != nil {
+			return 0, err
+		}
+		if err := mw.makeRoom(l); err != nil {
 			return 0, err
 		}
-		if l > len(mw.buf) {
-			return mw.w.Write(p)
+		if l > mw.OpenSpace() {
+			if mw.track != nil {
+				mw.track.Write(p)
+			}
+			n, err := mw.w.Write(p)
+			mw.flushed(n)
+			if err != nil {
+				err = mw.fail(err)
+			}
+			return n, err
 		}
 	}
 	mw.wLoc += copy(mw.buf[mw.wLoc:], p)
 	return l, nil
 }
 
+// writeVectored writes the buffered data followed by p to the underlying writer. It returns the
+// number of bytes of p written. If not all of the buffered data is written, the rest of it stays
+// buffered, and no bytes of p are written.
+func (mw *Writer) writeVectored(p []byte) (int, error) {
+	if mw.err != nil {
+		return 0, mw.err
+	}
+	if err := mw.checkMessage(len(p)); err != nil {
+		return 0, err
+	}
+	if mw.track != nil {
+		mw.track.Write(mw.buf[mw.scanned:mw.wLoc])
+		mw.track.Write(p)
+	}
+	buffered := mw.wLoc
+	bufs := net.Buffers{mw.buf[:buffered], p}
+	nw, err := bufs.WriteTo(mw.w)
+	n := int(nw)
+	mw.flushed(n)
+	if n < buffered {
+		mw.wLoc = copy(mw.buf, mw.buf[n:buffered])
+		mw.scanned = mw.wLoc
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return 0, mw.fail(err)
+	}
+	mw.wLoc = 0
+	mw.scanned = 0
+	n -= buffered
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		err = mw.fail(err)
+	}
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom. It reads from r until io.EOF directly into the buffer,
+// flushing the buffer whenever it fills. The data read is written as is, so it should be
+// encoded MessagePack (such as a pre-encoded part of an object being written).
+func (mw *Writer) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	for empty := 0; ; {
+		if mw.OpenSpace() == 0 {
+			if err := mw.makeRoom(1); err != nil {
+				return n, err
+			}
+		}
+		nr, err := r.Read(mw.buf[mw.wLoc:])
+		mw.wLoc += nr
+		n += int64(nr)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if nr > 0 {
+			empty = 0
+		} else if empty++; empty == 100 {
+			return n, io.ErrNoProgress
+		}
+	}
+}
+
 // writeString writes s to the buffer.
 func (mw *Writer) writeString(s string) error {
 	l := len(s)
 	if mw.OpenSpace() < l {
-		if err := mw.Flush(); err != nil {
+		if err := mw.checkMessage(l); err != nil {
+			return err
+		}
+		if err := mw.makeRoom(l); err != nil {
 			return err
 		}
-		if l > len(mw.buf) {
+		if l > mw.OpenSpace() {
+			if mw.track != nil {
+				mw.track.Write([]byte(s))
+			}
 			n, err := io.WriteString(mw.w, s)
+			mw.flushed(n)
 			if err != nil {
-				return err
+				return mw.fail(err)
 			}
 			if n < l {
-				return io.ErrShortWrite
+				return mw.fail(io.ErrShortWrite)
 			}
 			return nil
 		}
@@ -239,6 +560,15 @@ func (mw *Writer) Reset(w io.Writer) {
 	mw.buf = mw.buf[:cap(mw.buf)]
 	mw.w = w
 	mw.wLoc = 0
+	mw.written = 0
+	mw.scanned = 0
+	mw.inMsg = false
+	mw.flushes = 0
+	mw.grows = 0
+	mw.err = nil
+	if mw.track != nil {
+		mw.track = newTracker(mw.track.obs)
+	}
 }
 
 // WriteMapHeader writes a map header of the given size to the buffer.
@@ -270,6 +600,39 @@ func (mw *Writer) WriteNil() error {
 	return mw.push(mnil)
 }
 
+// WriteRaw writes b, which must hold exactly one encoded object, as it is. It lets objects encoded
+// earlier (such as by a cache, or by a proxy passing them on) be spliced into the stream. If b does
+// not hold exactly one well-formed object, nothing is written and the error says why: possible
+// errors include ErrShortBytes (b is empty or the object is cut short), InvalidPrefixError,
+// ErrMaxDepthExceeded, and ErrTrailingBytes (b holds more than one object).
+func (mw *Writer) WriteRaw(b []byte) error {
+	rest, err := Skip(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return ErrTrailingBytes
+	}
+	return mw.WriteRawUnsafe(b)
+}
+
+// WriteRawUnsafe writes b as it is, like WriteRaw but without checking that b holds exactly one
+// object. Writing anything else corrupts the stream, so it is for bytes known to be well formed,
+// such as the encodings of the generated methods.
+func (mw *Writer) WriteRawUnsafe(b []byte) error {
+	_, err := mw.Write(b)
+	return err
+}
+
+// WriteRawField writes b, the encoded object held by a field with the raw option, like WriteRaw,
+// except that an empty b is written as nil.
+func (mw *Writer) WriteRawField(b []byte) error {
+	if len(b) == 0 {
+		return mw.WriteNil()
+	}
+	return mw.WriteRaw(b)
+}
+
 // WriteFloat64 writes a float64 to the writer
 func (mw *Writer) WriteFloat64(f float64) error {
 	return mw.prefix64(mfloat64, math.Float64bits(f))
@@ -280,6 +643,16 @@ func (mw *Writer) WriteFloat32(f float32) error {
 	return mw.prefix32(mfloat32, math.Float32bits(f))
 }
 
+// WriteFloat writes f as a float32 if it converts to a float32 and back unchanged (as small
+// integers and the binary fractions like 0.5 do), and as a float64 otherwise. Either is read
+// back as the same float64 by ReadFloat64.
+func (mw *Writer) WriteFloat(f float64) error {
+	if fitsFloat32(f) {
+		return mw.WriteFloat32(float32(f))
+	}
+	return mw.WriteFloat64(f)
+}
+
 // WriteInt64 writes an int64 to the writer.
 func (mw *Writer) WriteInt64(i int64) error {
 	if i >= 0 {
@@ -361,6 +734,14 @@ func (mw *Writer) WriteUint32(u uint32) error { return mw.WriteUint64(uint64(u))
 // WriteUint writes a uint to the writer.
 func (mw *Writer) WriteUint(u uint) error { return mw.WriteUint64(uint64(u)) }
 
+// WriteFixedInt64 writes i as an int64 of 8 bytes, whatever its value, rather than in the fewest
+// bytes as WriteInt64 does. The messages whose integers are all written this way have the same
+// size for any values, and their integers can be overwritten in place (as in memory-mapped files).
+func (mw *Writer) WriteFixedInt64(i int64) error { return mw.prefix64(mint64, uint64(i)) }
+
+// WriteFixedUint64 writes u as a uint64 of 8 bytes, whatever its value (see WriteFixedInt64).
+func (mw *Writer) WriteFixedUint64(u uint64) error { return mw.prefix64(muint64, u) }
+
 // WriteByte does the same thing as WriteUint8.
 func (mw *Writer) WriteByte(u byte) error { return mw.WriteUint8(u) }
 
@@ -494,6 +875,65 @@ func (mw *Writer) WriteMapStrIntf(mp map[string]interface{}) (err error) {
 	return
 }
 
+// A KV is an entry of a map written by WriteKV or AppendKV: a key and a value of one of the types
+// supported by WriteIntf and AppendIntf.
+type KV struct {
+	Key   string
+	Value interface{}
+}
+
+// WriteKV writes a map of the entries in pairs, in their order, writing the values with WriteIntf.
+// The generated encoders of structs with many fields can write the fields with it, which takes much
+// less code than writing the fields one by one, at the cost of converting the values to interfaces.
+func (mw *Writer) WriteKV(pairs ...KV) error {
+	if err := mw.WriteMapHeader(uint32(len(pairs))); err != nil {
+		return err
+	}
+	for i := range pairs {
+		if err := mw.WriteString(pairs[i].Key); err != nil {
+			return err
+		}
+		if err := mw.WriteIntf(pairs[i].Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMapStrBytes writes a map[string][]byte to the writer.
+func (mw *Writer) WriteMapStrBytes(mp map[string][]byte) error {
+	err := mw.WriteMapHeader(uint32(len(mp)))
+	if err != nil {
+		return err
+	}
+	for key, val := range mp {
+		if err = mw.WriteString(key); err != nil {
+			return err
+		}
+		if err = mw.WriteBytes(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMapStrTime writes a map[string]time.Time to the writer.
+func (mw *Writer) WriteMapStrTime(mp map[string]time.Time) error {
+	err := mw.WriteMapHeader(uint32(len(mp)))
+	if err != nil {
+		return err
+	}
+	for key, val := range mp {
+		if err = mw.WriteString(key); err != nil {
+			return err
+		}
+		if err = mw.WriteTime(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // WriteTime writes a time.Time object to the wire.
 //
 // Time is encoded as Unix time, which means that location (time zone) data is removed from the object.
@@ -532,6 +972,15 @@ func (mw *Writer) WriteIntf(v interface{}) error {
 	// preferred interfaces
 	case Encoder:
 		return v.EncodeMsg(mw)
+	case Marshaler:
+		// The buffer is kept for the next value marshaled.
+		var err error
+		mw.marshaled, err = v.MarshalMsg(mw.marshaled[:0])
+		if err != nil {
+			return err
+		}
+		_, err = mw.Write(mw.marshaled)
+		return err
 	case Extension:
 		return mw.WriteExtension(v)
 
@@ -574,13 +1023,37 @@ func (mw *Writer) WriteIntf(v interface{}) error {
 		return mw.WriteMapStrStr(v)
 	case map[string]interface{}:
 		return mw.WriteMapStrIntf(v)
+	case map[string]int:
+		return mw.writeMapStrInt(v)
+	case map[string]int64:
+		return mw.writeMapStrInt64(v)
+	case map[string][]byte:
+		return mw.WriteMapStrBytes(v)
+	case map[string]time.Time:
+		return mw.WriteMapStrTime(v)
+	case []interface{}:
+		if err := mw.WriteArrayHeader(uint32(len(v))); err != nil {
+			return err
+		}
+		for _, e := range v {
+			if err := mw.WriteIntf(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []string:
+		return mw.writeStrings(v)
+	case []int:
+		return mw.writeInts(v)
+	case []float64:
+		return mw.writeFloat64s(v)
 	case time.Time:
 		return mw.WriteTime(v)
 	}
 
 	val := reflect.ValueOf(v)
 	if !isSupported(val.Kind()) || !val.IsValid() {
-		return fmt.Errorf("msgp: type %s not supported", val)
+		return &ErrUnsupportedType{T: val.Type()}
 	}
 
 	switch val.Kind() {
@@ -597,9 +1070,80 @@ func (mw *Writer) WriteIntf(v interface{}) error {
 	return &ErrUnsupportedType{val.Type()}
 }
 
+func (mw *Writer) writeMapStrInt(mp map[string]int) error {
+	err := mw.WriteMapHeader(uint32(len(mp)))
+	if err != nil {
+		return err
+	}
+	for key, val := range mp {
+		if err = mw.WriteString(key); err != nil {
+			return err
+		}
+		if err = mw.WriteInt(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mw *Writer) writeMapStrInt64(mp map[string]int64) error {
+	err := mw.WriteMapHeader(uint32(len(mp)))
+	if err != nil {
+		return err
+	}
+	for key, val := range mp {
+		if err = mw.WriteString(key); err != nil {
+			return err
+		}
+		if err = mw.WriteInt64(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mw *Writer) writeStrings(s []string) error {
+	err := mw.WriteArrayHeader(uint32(len(s)))
+	if err != nil {
+		return err
+	}
+	for i := range s {
+		if err = mw.WriteString(s[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mw *Writer) writeInts(s []int) error {
+	err := mw.WriteArrayHeader(uint32(len(s)))
+	if err != nil {
+		return err
+	}
+	for i := range s {
+		if err = mw.WriteInt(s[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mw *Writer) writeFloat64s(s []float64) error {
+	err := mw.WriteArrayHeader(uint32(len(s)))
+	if err != nil {
+		return err
+	}
+	for i := range s {
+		if err = mw.WriteFloat64(s[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (mw *Writer) writeMap(v reflect.Value) error {
 	if v.Type().Key().Kind() != reflect.String {
-		return errors.New("msgp: map keys must be strings")
+		return &ErrUnsupportedType{T: v.Type()}
 	}
 	ks := v.MapKeys()
 	err := mw.WriteMapHeader(uint32(len(ks)))
@@ -642,7 +1186,7 @@ func (mw *Writer) writeStruct(v reflect.Value) error {
 	if enc, ok := v.Interface().(Encoder); ok {
 		return enc.EncodeMsg(mw)
 	}
-	return fmt.Errorf("msgp: unsupported type: %s", v.Type())
+	return &ErrUnsupportedType{T: v.Type()}
 }
 
 // isSupported says if k is encodable.
@@ -650,8 +1194,10 @@ func isSupported(k reflect.Kind) bool {
 	return k != reflect.Func && k != reflect.Chan && k != reflect.Invalid && k != reflect.UnsafePointer
 }
 
-// GuessSize guesses the size of the underlying value of 'i'. If the underlying value is not
-// a simple builtin (or []byte), GuessSize defaults to 512.
+// GuessSize guesses the size of the underlying value of 'i'. Slices and maps of interfaces are
+// sized by their elements, recursively. If the underlying value is not a simple builtin, a Sizer,
+// an Extension, or one of the slices and maps that WriteIntf writes without reflection, GuessSize
+// defaults to 512.
 func GuessSize(i interface{}) int {
 	if i == nil {
 		return NilSize
@@ -691,7 +1237,80 @@ func GuessSize(i interface{}) int {
 			s += 2*StringPrefixSize + len(key) + len(val)
 		}
 		return s
+	case map[string]int:
+		s := MapHeaderSize + len(i)*(StringPrefixSize+IntSize)
+		for key := range i {
+			s += len(key)
+		}
+		return s
+	case map[string]int64:
+		s := MapHeaderSize + len(i)*(StringPrefixSize+Int64Size)
+		for key := range i {
+			s += len(key)
+		}
+		return s
+	case map[string][]byte:
+		s := MapHeaderSize + len(i)*(StringPrefixSize+BytesPrefixSize)
+		for key, val := range i {
+			s += len(key) + len(val)
+		}
+		return s
+	case map[string]time.Time:
+		s := MapHeaderSize + len(i)*(StringPrefixSize+TimeSize)
+		for key := range i {
+			s += len(key)
+		}
+		return s
+	case map[string][]interface{}:
+		s := MapHeaderSize
+		for key, val := range i {
+			s += StringPrefixSize + len(key) + guessSliceSize(val)
+		}
+		return s
+	case map[string][]string:
+		s := MapHeaderSize
+		for key, val := range i {
+			s += StringPrefixSize + len(key) + stringsSize(val)
+		}
+		return s
+	case []interface{}:
+		return guessSliceSize(i)
+	case []map[string]interface{}:
+		s := ArrayHeaderSize
+		for _, m := range i {
+			s += GuessSize(m)
+		}
+		return s
+	case []string:
+		return stringsSize(i)
+	case []int:
+		return ArrayHeaderSize + len(i)*IntSize
+	case []int64:
+		return ArrayHeaderSize + len(i)*Int64Size
+	case []float64:
+		return ArrayHeaderSize + len(i)*Float64Size
+	case time.Time:
+		return TimeSize
 	default:
 		return 512
 	}
 }
+
+// guessSliceSize returns the sum of the sizes GuessSize guesses for the elements of s and the
+// size of the array header.
+func guessSliceSize(s []interface{}) int {
+	n := ArrayHeaderSize
+	for _, v := range s {
+		n += GuessSize(v)
+	}
+	return n
+}
+
+// stringsSize returns the maximum encoded size of s.
+func stringsSize(s []string) int {
+	n := ArrayHeaderSize + len(s)*StringPrefixSize
+	for _, str := range s {
+		n += len(str)
+	}
+	return n
+}
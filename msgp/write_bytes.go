@@ -68,6 +68,23 @@ func AppendArrayHeader(b []byte, size uint32) []byte {
 // AppendNil appends a MessagePack nil byte to b.
 func AppendNil(b []byte) []byte { return append(b, mnil) }
 
+// AppendRawField appends raw, the encoded object held by a field with the raw option, to b as it
+// is, or nil if raw is empty. If raw does not hold exactly one well-formed object, b is returned
+// unchanged with the error saying why, as from Writer.WriteRaw.
+func AppendRawField(b []byte, raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return AppendNil(b), nil
+	}
+	rest, err := Skip(raw)
+	if err != nil {
+		return b, err
+	}
+	if len(rest) > 0 {
+		return b, ErrTrailingBytes
+	}
+	return append(b, raw...), nil
+}
+
 // AppendFloat64 appends a float64 to b.
 func AppendFloat64(b []byte, f float64) []byte {
 	o, n := ensure(b, Float64Size)
@@ -82,6 +99,15 @@ func AppendFloat32(b []byte, f float32) []byte {
 	return o
 }
 
+// AppendFloat appends f to b as a float32 if that loses nothing, and as a float64 otherwise,
+// like Writer.WriteFloat.
+func AppendFloat(b []byte, f float64) []byte {
+	if fitsFloat32(f) {
+		return AppendFloat32(b, float32(f))
+	}
+	return AppendFloat64(b, f)
+}
+
 // AppendInt64 appends an int64 to b.
 func AppendInt64(b []byte, i int64) []byte {
 	if i >= 0 {
@@ -170,6 +196,22 @@ func AppendUint64(b []byte, u uint64) []byte {
 // AppendUint appends a uint to b.
 func AppendUint(b []byte, u uint) []byte { return AppendUint64(b, uint64(u)) }
 
+// AppendFixedInt64 appends i to b as an int64 of 8 bytes, whatever its value, like
+// Writer.WriteFixedInt64.
+func AppendFixedInt64(b []byte, i int64) []byte {
+	o, n := ensure(b, FixedInt64Size)
+	putMint64(o[n:], i)
+	return o
+}
+
+// AppendFixedUint64 appends u to b as a uint64 of 8 bytes, whatever its value, like
+// Writer.WriteFixedUint64.
+func AppendFixedUint64(b []byte, u uint64) []byte {
+	o, n := ensure(b, FixedUint64Size)
+	putMuint64(o[n:], u)
+	return o
+}
+
 // AppendUint8 appends a uint8 to b.
 func AppendUint8(b []byte, u uint8) []byte {
 	if u <= math.MaxInt8 {
@@ -222,7 +264,19 @@ func AppendBool(b []byte, t bool) []byte {
 
 // AppendString appends a string as a MessagePack 'str' to b.
 func AppendString(b []byte, s string) []byte {
-	sz := len(s)
+	b, n := appendStrHeader(b, len(s))
+	return b[:n+copy(b[n:], s)]
+}
+
+// AppendStringFromBytes appends a []byte representing a string as a MessagePack 'str' to b.
+func AppendStringFromBytes(b []byte, str []byte) []byte {
+	b, n := appendStrHeader(b, len(str))
+	return b[:n+copy(b[n:], str)]
+}
+
+// appendStrHeader grows b by the header of a 'str' object of sz bytes and the data, and writes
+// the header. It returns b and the index at which the data goes.
+func appendStrHeader(b []byte, sz int) ([]byte, int) {
 	var n int
 	switch {
 	case sz <= 31:
@@ -242,7 +296,7 @@ func AppendString(b []byte, s string) []byte {
 		prefixu32(b[n:], mstr32, uint32(sz))
 		n += 5
 	}
-	return b[:n+copy(b[n:], s)]
+	return b, n
 }
 
 // AppendComplex64 appends a complex64 to b as a MessagePack extension.
@@ -289,11 +343,15 @@ func AppendMapStrStr(b []byte, m map[string]string) []byte {
 
 // AppendMapStrIntf appends a map[string]interface{} to b as a MessagePack map.
 func AppendMapStrIntf(b []byte, m map[string]interface{}) ([]byte, error) {
+	return AppendIntf(b, m)
+}
+
+func appendMapStrIntf(b []byte, m map[string]interface{}) ([]byte, error) {
 	b = AppendMapHeader(b, uint32(len(m)))
 	var err error
 	for key, val := range m {
 		b = AppendString(b, key)
-		b, err = AppendIntf(b, val)
+		b, err = appendIntf(b, val)
 		if err != nil {
 			return b, err
 		}
@@ -301,15 +359,107 @@ func AppendMapStrIntf(b []byte, m map[string]interface{}) ([]byte, error) {
 	return b, nil
 }
 
+// AppendKV appends a map of the entries in pairs, in their order, to b, appending the values with
+// AppendIntf. Unlike AppendIntf, it does not grow b for the nested maps and slices first.
+func AppendKV(b []byte, pairs ...KV) ([]byte, error) {
+	b = AppendMapHeader(b, uint32(len(pairs)))
+	var err error
+	for i := range pairs {
+		b = AppendString(b, pairs[i].Key)
+		b, err = appendIntf(b, pairs[i].Value)
+		if err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// AppendMapStrBytes appends a map[string][]byte to b as a MessagePack map.
+func AppendMapStrBytes(b []byte, m map[string][]byte) []byte {
+	b = AppendMapHeader(b, uint32(len(m)))
+	for key, val := range m {
+		b = AppendString(b, key)
+		b = AppendBytes(b, val)
+	}
+	return b
+}
+
+// AppendMapStrTime appends a map[string]time.Time to b as a MessagePack map.
+func AppendMapStrTime(b []byte, m map[string]time.Time) []byte {
+	b = AppendMapHeader(b, uint32(len(m)))
+	for key, val := range m {
+		b = AppendString(b, key)
+		b = AppendTime(b, val)
+	}
+	return b
+}
+
+// appendMapStrInt appends a map[string]int to b as a MessagePack map.
+func appendMapStrInt(b []byte, m map[string]int) []byte {
+	b = AppendMapHeader(b, uint32(len(m)))
+	for key, val := range m {
+		b = AppendString(b, key)
+		b = AppendInt(b, val)
+	}
+	return b
+}
+
+// appendMapStrInt64 appends a map[string]int64 to b as a MessagePack map.
+func appendMapStrInt64(b []byte, m map[string]int64) []byte {
+	b = AppendMapHeader(b, uint32(len(m)))
+	for key, val := range m {
+		b = AppendString(b, key)
+		b = AppendInt64(b, val)
+	}
+	return b
+}
+
+// appendStrings appends a []string to b as a MessagePack array.
+func appendStrings(b []byte, s []string) []byte {
+	b = AppendArrayHeader(b, uint32(len(s)))
+	for i := range s {
+		b = AppendString(b, s[i])
+	}
+	return b
+}
+
+// appendInts appends a []int to b as a MessagePack array.
+func appendInts(b []byte, s []int) []byte {
+	b = AppendArrayHeader(b, uint32(len(s)))
+	for i := range s {
+		b = AppendInt(b, s[i])
+	}
+	return b
+}
+
+// appendFloat64s appends a []float64 to b as a MessagePack array.
+func appendFloat64s(b []byte, s []float64) []byte {
+	b = AppendArrayHeader(b, uint32(len(s)))
+	for i := range s {
+		b = AppendFloat64(b, s[i])
+	}
+	return b
+}
+
 // AppendIntf appends to b the value of i with its concrete type. The type of i must be
 // one of the following:
 //  - bool, float, string, []byte, int, uint, complex, time.Time, or nil
-//  - map[string]interface{} or map[string]string
+//  - map[string]interface{}, map[string]string, map[string][]interface{}, or map[string][]string
 //  - []T, where T is another supported type
 //  - *T, where T is another supported type
 //  - type that implements the msgp.Marshaler interface
 //  - type that implements the msgp.Extension interface
 func AppendIntf(b []byte, i interface{}) ([]byte, error) {
+	switch i.(type) {
+	case []interface{}, map[string]interface{}, []map[string]interface{}, map[string][]interface{}:
+		// Growing b once for the whole value saves growing it many times for the elements.
+		b = Require(b, GuessSize(i))
+	}
+	return appendIntf(b, i)
+}
+
+// appendIntf appends i to b like AppendIntf, without first growing b for the whole value.
+func appendIntf(b []byte, i interface{}) ([]byte, error) {
 
 	if i == nil {
 		return AppendNil(b), nil
@@ -358,14 +508,56 @@ func AppendIntf(b []byte, i interface{}) ([]byte, error) {
 	case time.Time:
 		return AppendTime(b, i), nil
 	case map[string]interface{}:
-		return AppendMapStrIntf(b, i)
+		return appendMapStrIntf(b, i)
 	case map[string]string:
 		return AppendMapStrStr(b, i), nil
+	case map[string]int:
+		return appendMapStrInt(b, i), nil
+	case map[string]int64:
+		return appendMapStrInt64(b, i), nil
+	case map[string][]byte:
+		return AppendMapStrBytes(b, i), nil
+	case map[string]time.Time:
+		return AppendMapStrTime(b, i), nil
+	case map[string][]interface{}:
+		b = AppendMapHeader(b, uint32(len(i)))
+		var err error
+		for key, val := range i {
+			b = AppendString(b, key)
+			b, err = appendIntf(b, val)
+			if err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case map[string][]string:
+		b = AppendMapHeader(b, uint32(len(i)))
+		for key, val := range i {
+			b = AppendString(b, key)
+			b = appendStrings(b, val)
+		}
+		return b, nil
+	case []string:
+		return appendStrings(b, i), nil
+	case []int:
+		return appendInts(b, i), nil
+	case []float64:
+		return appendFloat64s(b, i), nil
 	case []interface{}:
 		b = AppendArrayHeader(b, uint32(len(i)))
 		var err error
 		for _, k := range i {
-			b, err = AppendIntf(b, k)
+			b, err = appendIntf(b, k)
+			if err != nil {
+				return b, err
+			}
+		}
+		return b, nil
+	case []map[string]interface{}:
+		b = AppendArrayHeader(b, uint32(len(i)))
+		var err error
+		for _, m := range i {
+			b, err = appendMapStrIntf(b, m)
 			if err != nil {
 				return b, err
 			}
@@ -196,6 +196,39 @@ func TestAppendUint64(t *testing.T) {
 	}
 }
 
+func TestAppendFixedInt64(t *testing.T) {
+	for _, i := range []int64{0, 1, -1, -33, int64(tint16), math.MinInt64, math.MaxInt64} {
+		var buf bytes.Buffer
+		en := NewWriter(&buf)
+		en.WriteFixedInt64(i)
+		en.Flush()
+		bts := AppendFixedInt64(nil, i)
+		if !bytes.Equal(buf.Bytes(), bts) || len(bts) != FixedInt64Size || bts[0] != mint64 {
+			t.Errorf("for int64 %d, encoder wrote %x; append wrote %x", i, buf.Bytes(), bts)
+		}
+		if got, o, err := ReadInt64Bytes(bts); got != i || len(o) != 0 || err != nil {
+			t.Errorf("for int64 %d, read %d (%v)", i, got, err)
+		}
+	}
+	for _, u := range []uint64{0, 1, 255, math.MaxUint64} {
+		var buf bytes.Buffer
+		en := NewWriter(&buf)
+		en.WriteFixedUint64(u)
+		en.Flush()
+		bts := AppendFixedUint64(nil, u)
+		if !bytes.Equal(buf.Bytes(), bts) || len(bts) != FixedUint64Size || bts[0] != muint64 {
+			t.Errorf("for uint64 %d, encoder wrote %x; append wrote %x", u, buf.Bytes(), bts)
+		}
+		if got, o, err := ReadUint64Bytes(bts); got != u || len(o) != 0 || err != nil {
+			t.Errorf("for uint64 %d, read %d (%v)", u, got, err)
+		}
+	}
+	// The smaller integer types are read from fixed-width integers.
+	if got, _, err := ReadInt8Bytes(AppendFixedInt64(nil, -100)); got != -100 || err != nil {
+		t.Errorf("read int8 %d (%v); want -100", got, err)
+	}
+}
+
 func BenchmarkAppendUint64(b *testing.B) {
 	us := []uint64{0, 1, 15, uint64(tuint16), uint64(tuint32), tuint64}
 	buf := make([]byte, 0, 9)
@@ -262,6 +295,27 @@ func TestAppendString(t *testing.T) {
 	}
 }
 
+func TestAppendStringFromBytes(t *testing.T) {
+	sizes := []int{0, 1, 225, int(tuint32)}
+	var buf bytes.Buffer
+	en := NewWriter(&buf)
+	var bts []byte
+
+	for _, sz := range sizes {
+		buf.Reset()
+		s := RandBytes(sz)
+		en.WriteStringFromBytes(s)
+		en.Flush()
+		bts = AppendStringFromBytes(bts[0:0], s)
+		if !bytes.Equal(buf.Bytes(), bts) {
+			t.Errorf("for string of length %d, encoder wrote %d bytes and append wrote %d bytes", sz, buf.Len(), len(bts))
+		}
+		if !bytes.Equal(bts, AppendString(nil, string(s))) {
+			t.Errorf("for string of length %d, AppendStringFromBytes and AppendString wrote different bytes", sz)
+		}
+	}
+}
+
 func benchappendString(size uint32, b *testing.B) {
 	str := string(RandBytes(int(size)))
 	buf := make([]byte, 0, len(str)+5)
@@ -317,3 +371,40 @@ func BenchmarkAppendTime(b *testing.B) {
 		AppendTime(buf[0:0], t)
 	}
 }
+
+// nestedIntf returns a slice of maps like those decoded from JSON documents.
+func nestedIntf() []interface{} {
+	v := make([]interface{}, 20)
+	for i := range v {
+		v[i] = map[string]interface{}{
+			"id":    int64(i),
+			"name":  "some name",
+			"tags":  []interface{}{"a", "b", "c"},
+			"score": 1.5,
+		}
+	}
+	return v
+}
+
+func TestAppendIntfAllocs(t *testing.T) {
+	v := interface{}(nestedIntf())
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := AppendIntf(nil, v); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// The buffer is allocated once, with the size GuessSize guesses.
+	if allocs != 1 {
+		t.Errorf("AppendIntf made %v allocations", allocs)
+	}
+}
+
+func BenchmarkAppendIntfNested(b *testing.B) {
+	v := interface{}(nestedIntf())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := AppendIntf(nil, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package msgp
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 )
@@ -217,6 +220,54 @@ func TestWriteNil(t *testing.T) {
 	}
 }
 
+func TestWriteRaw(t *testing.T) {
+	obj := AppendMapHeader(nil, 1)
+	obj = AppendString(obj, "a")
+	obj = AppendArrayHeader(obj, 2)
+	obj = AppendInt(obj, 1)
+	obj = AppendNil(obj)
+
+	cases := []struct {
+		b   []byte
+		err error
+	}{
+		{obj, nil},
+		{AppendNil(nil), nil},
+		{nil, ErrShortBytes},
+		{obj[:len(obj)-1], ErrShortBytes},
+		{append(obj[:len(obj):len(obj)], mnil), ErrTrailingBytes},
+		{[]byte{0xc1}, InvalidPrefixError(0xc1)},
+	}
+	for i, tc := range cases {
+		var buf bytes.Buffer
+		wr := NewWriter(&buf)
+		if err := wr.WriteRaw(tc.b); err != tc.err {
+			t.Errorf("case %d: expected error %v; got %v", i, tc.err, err)
+		}
+		if err := wr.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		want := tc.b
+		if tc.err != nil {
+			want = nil
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("case %d: wrote %x; want %x", i, buf.Bytes(), want)
+		}
+	}
+
+	// The bytes are not checked by WriteRawUnsafe.
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteRawUnsafe(obj[:3]); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	if !bytes.Equal(buf.Bytes(), obj[:3]) {
+		t.Errorf("WriteRawUnsafe wrote %x; want %x", buf.Bytes(), obj[:3])
+	}
+}
+
 func TestWriteFloat64(t *testing.T) {
 	var buf bytes.Buffer
 	wr := NewWriter(&buf)
@@ -287,6 +338,48 @@ func BenchmarkWriteFloat32(b *testing.B) {
 	}
 }
 
+func TestWriteFloat(t *testing.T) {
+	cases := []struct {
+		f    float64
+		lead byte
+	}{
+		{0, mfloat32},
+		{math.Copysign(0, -1), mfloat32},
+		{-12, mfloat32},
+		{0.375, mfloat32},
+		{float64(float32(0.1)), mfloat32},
+		{math.Inf(1), mfloat32},
+		{math.NaN(), mfloat64}, // its payload has a low bit set
+		{0.1, mfloat64},
+		{1 << 60, mfloat32},
+		{1<<60 + 1<<20, mfloat64},
+		{math.MaxFloat64, mfloat64},
+		{math.SmallestNonzeroFloat64, mfloat64},
+	}
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	for i, c := range cases {
+		buf.Reset()
+		if err := wr.WriteFloat(c.f); err != nil || wr.Flush() != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		b := AppendFloat(nil, c.f)
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("case %d: WriteFloat wrote %x; AppendFloat appended %x", i, buf.Bytes(), b)
+		}
+		if b[0] != c.lead {
+			t.Errorf("case %d: %v written with the lead byte %x; want %x", i, c.f, b[0], c.lead)
+		}
+		if n := SizeOfFloat(c.f); n != len(b) {
+			t.Errorf("case %d: SizeOfFloat = %d; want %d", i, n, len(b))
+		}
+		f, o, err := ReadFloat64Bytes(b)
+		if err != nil || len(o) != 0 || math.Float64bits(f) != math.Float64bits(c.f) {
+			t.Errorf("case %d: read back %v (%v); want %v", i, f, err, c.f)
+		}
+	}
+}
+
 func TestWriteInt64(t *testing.T) {
 	var buf bytes.Buffer
 	wr := NewWriter(&buf)
@@ -453,3 +546,582 @@ func BenchmarkWriteTime(b *testing.B) {
 		wr.WriteTime(t)
 	}
 }
+
+func TestWriterCount(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriterSize(&buf, 32)
+
+	wr.WriteString("hello")
+	if c := wr.Count(); c != 6 {
+		t.Errorf("count is %d after a short string; want 6", c)
+	}
+	wr.WriteBytes(RandBytes(100)) // larger than the buffer
+	if c := wr.Count(); c != 6+102 {
+		t.Errorf("count is %d after bytes; want %d", c, 6+102)
+	}
+
+	wr.ResetCount()
+	wr.WriteFloat64(1)
+	if c := wr.Count(); c != 9 {
+		t.Errorf("count is %d after reset and a float; want 9", c)
+	}
+	if err := wr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if c := wr.Count(); c != 9 {
+		t.Errorf("count is %d after flushing; want 9", c)
+	}
+	if buf.Len() != 6+102+9 {
+		t.Errorf("wrote %d bytes; want %d", buf.Len(), 6+102+9)
+	}
+
+	wr.Reset(&buf)
+	if c := wr.Count(); c != 0 {
+		t.Errorf("count is %d after Reset; want 0", c)
+	}
+}
+
+func TestWriteIntfCommonTypes(t *testing.T) {
+	// The named types are encoded using reflection, which should give the same results as the
+	// fast paths for the unnamed types.
+	type (
+		strs     []string
+		ints     []int
+		floats   []float64
+		strInt   map[string]int
+		strInt64 map[string]int64
+		intfs    []interface{}
+		maps     []map[string]interface{}
+	)
+	vals := []struct{ fast, slow interface{} }{
+		{[]string{"a", "bcd", ""}, strs{"a", "bcd", ""}},
+		{[]int{0, -1, 300, math.MaxInt32 + 1}, ints{0, -1, 300, math.MaxInt32 + 1}},
+		{[]float64{0, 1.5, math.Inf(-1)}, floats{0, 1.5, math.Inf(-1)}},
+		{map[string]int{"key": -4000}, strInt{"key": -4000}},
+		{map[string]int64{"key": math.MaxInt64}, strInt64{"key": math.MaxInt64}},
+		{[]string{}, strs{}},
+		{[]interface{}{"a", 1.5, []interface{}{true}}, intfs{"a", 1.5, []interface{}{true}}},
+		{[]map[string]interface{}{{"a": "b"}, {}}, maps{{"a": "b"}, {}}},
+	}
+
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	write := func(v interface{}) []byte {
+		buf.Reset()
+		if err := wr.WriteIntf(v); err != nil {
+			t.Fatal(err)
+		}
+		wr.Flush()
+		return append([]byte(nil), buf.Bytes()...)
+	}
+
+	for _, v := range vals {
+		written, slow := write(v.fast), write(v.slow)
+		appended, err := AppendIntf(nil, v.fast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, slow) || !bytes.Equal(appended, slow) {
+			t.Errorf("%T: WriteIntf gave %x and AppendIntf gave %x; want %x", v.fast, written, appended, slow)
+		}
+		if sz := GuessSize(v.fast); sz < len(slow) {
+			t.Errorf("%T: GuessSize gave %d for %d bytes", v.fast, sz, len(slow))
+		}
+	}
+}
+
+// rawMarshaler is a Marshaler that is not an Encoder.
+type rawMarshaler []byte
+
+func (r rawMarshaler) MarshalMsg(b []byte) ([]byte, error) { return append(b, r...), nil }
+
+func TestWriteIntfMarshaler(t *testing.T) {
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	var v interface{} = rawMarshaler(AppendString(nil, "marshaled"))
+	if err := wr.WriteIntf(v); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	if !bytes.Equal(buf.Bytes(), v.(rawMarshaler)) {
+		t.Errorf("wrote %x; want %x", buf.Bytes(), v)
+	}
+
+	// The buffer the values are marshaled into is reused.
+	wr.Reset(ioutil.Discard)
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := wr.WriteIntf(v); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("WriteIntf made %v allocations", allocs)
+	}
+}
+
+func TestWriteKV(t *testing.T) {
+	pairs := []KV{
+		{Key: "name", Value: "x"},
+		{Key: "n", Value: int8(-3)},
+		{Key: "at", Value: time.Unix(5, 6)},
+		{Key: "raw", Value: rawMarshaler(AppendBool(nil, true))},
+		{Key: "none", Value: nil},
+	}
+	want := AppendMapHeader(nil, 5)
+	want = AppendString(AppendString(want, "name"), "x")
+	want = AppendInt8(AppendString(want, "n"), -3)
+	want = AppendTime(AppendString(want, "at"), time.Unix(5, 6))
+	want = AppendBool(AppendString(want, "raw"), true)
+	want = AppendNil(AppendString(want, "none"))
+
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	if err := wr.WriteKV(pairs...); err != nil {
+		t.Fatal(err)
+	}
+	wr.Flush()
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteKV wrote %x; want %x", buf.Bytes(), want)
+	}
+	b, err := AppendKV([]byte{0xc0}, pairs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b[1:], want) {
+		t.Errorf("AppendKV appended %x; want %x", b[1:], want)
+	}
+
+	if err := wr.WriteKV(KV{Key: "c", Value: make(chan int)}); err == nil {
+		t.Error("WriteKV wrote a channel")
+	}
+	if _, err := AppendKV(nil, KV{Key: "c", Value: make(chan int)}); err == nil {
+		t.Error("AppendKV appended a channel")
+	}
+}
+
+func TestGuessSizeNested(t *testing.T) {
+	vals := []interface{}{
+		[]interface{}{"abc", int64(1), []interface{}{map[string]interface{}{"k": "v"}}},
+		[]map[string]interface{}{{"a": []string{"x", "yz"}}, {"b": nil}},
+		map[string][]interface{}{"list": {1.5, "s"}},
+		map[string][]string{"a": {"b", "c"}},
+		map[string][]byte{"a": {1, 2}},
+		map[string]time.Time{"t": time.Unix(1, 0)},
+		[]int64{1, -1 << 40},
+		time.Unix(1, 2),
+	}
+	for _, v := range vals {
+		b, err := AppendIntf(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sz := GuessSize(v); sz < len(b) || sz == 512 {
+			t.Errorf("%T: GuessSize gave %d for %d bytes", v, sz, len(b))
+		}
+	}
+}
+
+func TestWriterReserve(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, 18)
+
+	if err := w.WriteString("abcdefghij"); err != nil {
+		t.Fatal(err)
+	}
+	o, err := w.Reserve(BoolSize + Int64Size) // more than the remaining space
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 0 || cap(o) < BoolSize+Int64Size {
+		t.Fatalf("got a slice with length %d and capacity %d", len(o), cap(o))
+	}
+	o = AppendBool(o, true)
+	o = AppendInt64(o, -300)
+	w.Commit(o)
+
+	o, err = w.Reserve(40) // more than the buffer size
+	if err != nil {
+		t.Fatal(err)
+	}
+	o = AppendString(o, strings.Repeat("z", 30))
+	w.Commit(o)
+	w.Flush()
+
+	want := AppendString(nil, "abcdefghij")
+	want = AppendBool(want, true)
+	want = AppendInt64(want, -300)
+	want = AppendString(want, strings.Repeat("z", 30))
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrote %x; want %x", buf.Bytes(), want)
+	}
+}
+
+func TestWriterReadFrom(t *testing.T) {
+	// The pre-encoded segment is an array of strings larger than the write buffer.
+	var segment []byte
+	segment = AppendArrayHeader(segment, 100)
+	for i := 0; i < 100; i++ {
+		segment = AppendString(segment, "pre-encoded")
+	}
+
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, 64)
+	w.WriteMapHeader(1)
+	w.WriteString("segment")
+	n, err := io.Copy(w, bytes.NewReader(segment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(segment)) {
+		t.Errorf("copied %d bytes; want %d", n, len(segment))
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := AppendString(AppendMapHeader(nil, 1), "segment")
+	want = append(want, segment...)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrote %x; want %x", buf.Bytes(), want)
+	}
+	if w.Count() != int64(len(want)) {
+		t.Errorf("Count is %d; want %d", w.Count(), len(want))
+	}
+}
+
+// writeRecorder records the slices written to it (without copying them) as well as the bytes
+// written, accepting at most limit bytes if limit is not negative.
+type writeRecorder struct {
+	writes [][]byte
+	data   bytes.Buffer
+	limit  int
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	if w.limit >= 0 && len(p) > w.limit {
+		p = p[:w.limit]
+	}
+	w.writes = append(w.writes, p)
+	w.data.Write(p)
+	if w.limit >= 0 {
+		if w.limit -= len(p); w.limit == 0 {
+			return len(p), io.ErrClosedPipe
+		}
+	}
+	return len(p), nil
+}
+
+func TestWriterStickyError(t *testing.T) {
+	rec := &writeRecorder{limit: 30}
+	w := NewWriterSize(rec, 18)
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = w.WriteString("twelve bytes")
+	}
+	if err != io.ErrClosedPipe || w.Err() != io.ErrClosedPipe {
+		t.Fatalf("the writes failed with %v (Err returns %v); want io.ErrClosedPipe", err, w.Err())
+	}
+	written, count := rec.data.Len(), w.Count()
+
+	// Every later write fails at once, without buffering or writing anything.
+	writes := []func() error{
+		func() error { return w.WriteInt64(tint64) },
+		func() error { return w.WriteBool(true) },
+		func() error { return w.WriteString(strings.Repeat("x", 100)) },
+		func() error { return w.WriteBytes(RandBytes(10)) },
+		func() error { return w.WriteExtension(&RawExtension{Type: 5, Data: RandBytes(40)}) },
+		func() error { return w.Append(0xc0) },
+		func() error { _, err := w.Reserve(4); return err },
+		func() error { _, err := w.ReadFrom(bytes.NewReader(RandBytes(10))); return err },
+		w.Flush,
+	}
+	for i, write := range writes {
+		if err := write(); err != io.ErrClosedPipe {
+			t.Errorf("write %d returned %v; want io.ErrClosedPipe", i, err)
+		}
+	}
+	w.SetVectored(4)
+	if _, err := w.Write(RandBytes(8)); err != io.ErrClosedPipe {
+		t.Errorf("the vectored write returned %v; want io.ErrClosedPipe", err)
+	}
+	if rec.data.Len() != written || w.Count() != count {
+		t.Errorf("wrote %d bytes and counted %d after the error; want %d and %d", rec.data.Len(), w.Count(), written, count)
+	}
+
+	// Reset clears the error.
+	var buf bytes.Buffer
+	w.Reset(&buf)
+	if err := w.WriteString("ok"); err != nil || w.Flush() != nil || w.Err() != nil {
+		t.Errorf("writing after Reset failed with %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), AppendString(nil, "ok")) {
+		t.Errorf("wrote %x after Reset", buf.Bytes())
+	}
+}
+
+func TestWriterVectored(t *testing.T) {
+	payload := RandBytes(1000)
+	small := RandBytes(10)
+	write := func(w *Writer) {
+		w.WriteString("key")
+		w.WriteBytes(small)
+		w.WriteBytes(payload)
+		w.WriteString("tail")
+	}
+
+	var want bytes.Buffer
+	plain := NewWriterSize(&want, 64)
+	write(plain)
+	if err := plain.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &writeRecorder{limit: -1}
+	w := NewWriterSize(rec, 64)
+	w.SetVectored(512)
+	write(w)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rec.data.Bytes(), want.Bytes()) {
+		t.Fatalf("wrote %x; want %x", rec.data.Bytes(), want.Bytes())
+	}
+	if w.Count() != int64(want.Len()) {
+		t.Errorf("Count is %d; want %d", w.Count(), want.Len())
+	}
+	var copied bool
+	for _, p := range rec.writes {
+		if len(p) == len(small) && &p[0] == &small[0] {
+			t.Error("a small slice was written without being buffered")
+		}
+		copied = copied || (len(p) > 0 && &p[0] == &payload[0])
+	}
+	if !copied {
+		t.Error("the payload was copied")
+	}
+}
+
+func TestWriterVectoredShortWrite(t *testing.T) {
+	rec := &writeRecorder{limit: 2}
+	w := NewWriterSize(rec, 64)
+	w.SetVectored(16)
+	w.WriteString("hello")
+	n, err := w.Write(RandBytes(32))
+	if n != 0 || err != io.ErrClosedPipe {
+		t.Fatalf("Write returned %d, %v; want 0, %v", n, err, io.ErrClosedPipe)
+	}
+	// The buffered bytes not written are kept, but the error sticks, so they are not written
+	// even once the underlying writer would take them.
+	if w.Count() != 6 {
+		t.Errorf("Count is %d; want 6", w.Count())
+	}
+	rec.limit = -1
+	if err = w.Flush(); err != io.ErrClosedPipe {
+		t.Errorf("Flush returned %v; want %v", err, io.ErrClosedPipe)
+	}
+	if want := AppendString(nil, "hello")[:2]; !bytes.Equal(rec.data.Bytes(), want) {
+		t.Errorf("wrote %x; want %x", rec.data.Bytes(), want)
+	}
+}
+
+func TestWriterAutoFlushOff(t *testing.T) {
+	payload := RandBytes(100)
+	write := func(w *Writer) {
+		w.WriteString(strings.Repeat("s", 40))
+		w.WriteBytes(payload)
+		w.WriteExtension(&RawExtension{Type: 5, Data: payload})
+		w.WriteComplex128(1 + 2i)
+		o, _ := w.Reserve(50)
+		w.Commit(AppendString(o, strings.Repeat("r", 40)))
+		w.ReadFrom(bytes.NewReader(payload))
+	}
+	var want bytes.Buffer
+	wr := NewWriter(&want)
+	write(wr)
+	wr.Flush()
+
+	rec := &writeRecorder{limit: -1}
+	var flushes []int
+	w := NewWriterSize(rec, 18)
+	w.SetAutoFlush(false)
+	w.SetVectored(16)
+	w.SetFlushHook(func(n int) { flushes = append(flushes, n) })
+	write(w)
+	if len(rec.writes) != 0 {
+		t.Fatalf("made %d writes before Flush", len(rec.writes))
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.writes) != 1 || !bytes.Equal(rec.data.Bytes(), want.Bytes()) {
+		t.Errorf("wrote %d pieces %x; want %x in one piece", len(rec.writes), rec.data.Bytes(), want.Bytes())
+	}
+	if len(flushes) != 1 || flushes[0] != want.Len() {
+		t.Errorf("the flush hook got %v; want [%d]", flushes, want.Len())
+	}
+
+	// With automatic flushing on, the hook is called on every write (a vectored write being one).
+	rec = &writeRecorder{limit: -1}
+	flushes = nil
+	w.Reset(rec)
+	w.SetAutoFlush(true)
+	write(w)
+	w.Flush()
+	var sum int
+	for _, n := range flushes {
+		sum += n
+	}
+	if len(flushes) < 2 || sum != want.Len() {
+		t.Errorf("the flush hook got %v for the writes of %d bytes", flushes, want.Len())
+	}
+}
+
+func TestWriterFlushThreshold(t *testing.T) {
+	rec := &writeRecorder{limit: -1}
+	w := NewWriter(rec)
+	w.WriteString("kept")
+	w.SetFlushThreshold(32)
+	for i := 0; i < 20; i++ {
+		w.WriteInt64(tint64)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range rec.writes {
+		if len(p) > 32 {
+			t.Errorf("flushed %d bytes; want at most 32", len(p))
+		}
+	}
+	want := AppendString(nil, "kept")
+	for i := 0; i < 20; i++ {
+		want = AppendInt64(want, tint64)
+	}
+	if !bytes.Equal(rec.data.Bytes(), want) {
+		t.Errorf("wrote %x; want %x", rec.data.Bytes(), want)
+	}
+}
+
+func TestWriterMaxBufferSize(t *testing.T) {
+	payload := RandBytes(300)
+	write := func(w *Writer) {
+		for i := 0; i < 20; i++ {
+			w.WriteString(strings.Repeat("s", 200))
+			w.WriteBytes(payload)
+			w.WriteExtension(&RawExtension{Type: 5, Data: payload})
+		}
+		w.WriteString(strings.Repeat("L", 5000)) // written directly, being larger than the limit
+		w.WriteInt64(tint64)
+	}
+	var want bytes.Buffer
+	wr := NewWriter(&want)
+	write(wr)
+	wr.Flush()
+
+	run := func(max int) WriterStats {
+		rec := &writeRecorder{limit: -1}
+		w := NewWriterSize(rec, 64)
+		w.SetMaxBufferSize(max)
+		write(w)
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rec.data.Bytes(), want.Bytes()) {
+			t.Errorf("with a limit of %d, wrote %d bytes differing from the %d expected", max, rec.data.Len(), want.Len())
+		}
+		for _, p := range rec.writes {
+			if max > 0 && len(p) > max && len(p) != 5000 {
+				t.Errorf("with a limit of %d, flushed %d bytes", max, len(p))
+			}
+		}
+		if st := w.Stats(); st.Flushes != int64(len(rec.writes)) {
+			t.Errorf("with a limit of %d, counted %d flushes of %d writes", max, st.Flushes, len(rec.writes))
+		}
+		return w.Stats()
+	}
+
+	fixed := run(0)
+	if fixed.Grows != 0 {
+		t.Errorf("without growth, the stats are %+v", fixed)
+	}
+	grown := run(4096)
+	if grown.BufferSize != 4096 || grown.Grows == 0 || grown.Flushes*4 > fixed.Flushes {
+		t.Errorf("with growth, the stats are %+v; without, %+v", grown, fixed)
+	}
+
+	w := NewWriterSize(&bytes.Buffer{}, 64)
+	w.SetMaxBufferSize(1024)
+	w.WriteString(strings.Repeat("s", 500))
+	w.Flush()
+	w.Reset(&bytes.Buffer{})
+	// The buffer grew to fit the string and is kept.
+	if st := w.Stats(); st.Flushes != 0 || st.Grows != 0 || st.BufferSize != 503 {
+		t.Errorf("after Reset, the stats are %+v", st)
+	}
+}
+
+func TestWriterMaxMessageSize(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, 64)
+	w.SetMaxMessageSize(20)
+	ten := strings.Repeat("x", 10)
+
+	// A message within the limit.
+	w.BeginMessage()
+	w.WriteString("short")
+	if err := w.EndMessage(); err != nil {
+		t.Fatal(err)
+	}
+	want := AppendString(nil, "short")
+
+	// A message exceeding the limit while it is buffered is dropped at EndMessage.
+	w.BeginMessage()
+	w.WriteString(ten)
+	w.WriteString(ten)
+	if err := w.EndMessage(); err != ErrMessageTooLarge {
+		t.Errorf("EndMessage returned %v; want ErrMessageTooLarge", err)
+	}
+
+	// A message exceeding the limit as the buffer fills fails the write that would flush it.
+	w.BeginMessage()
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = w.WriteString(ten)
+	}
+	if err != ErrMessageTooLarge {
+		t.Errorf("writing a large message returned %v; want ErrMessageTooLarge", err)
+	}
+	if err := w.EndMessage(); err != nil {
+		t.Errorf("EndMessage returned %v after the failed write", err)
+	}
+
+	// A large slice written directly to the underlying writer is refused.
+	w.BeginMessage()
+	if err := w.WriteBytes(make([]byte, 100)); err != ErrMessageTooLarge {
+		t.Errorf("writing a large slice returned %v; want ErrMessageTooLarge", err)
+	}
+	w.EndMessage()
+
+	// The data written outside of messages is not limited.
+	w.WriteString(ten + ten)
+	want = AppendString(want, ten+ten)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrote %x; want %x", buf.Bytes(), want)
+	}
+
+	// With the start of the message flushed, the rest of it is not written.
+	buf.Reset()
+	w = NewWriterSize(&buf, 18)
+	w.SetMaxMessageSize(40)
+	w.BeginMessage()
+	err = nil
+	for i := 0; i < 10 && err == nil; i++ {
+		err = w.WriteString(ten)
+	}
+	w.Flush()
+	if err != ErrMessageTooLarge || buf.Len() > 40 {
+		t.Errorf("wrote %d bytes of a large message (%v)", buf.Len(), err)
+	}
+}
@@ -0,0 +1,9 @@
+package msgp
+
+// IsZero says if the value v points to is zero according to its IsZero method, as generated code
+// tests the fields tagged omitzero whose types are from other packages. It returns false if the
+// value has no IsZero method returning a bool.
+func IsZero(v interface{}) bool {
+	z, ok := v.(interface{ IsZero() bool })
+	return ok && z.IsZero()
+}
@@ -0,0 +1,21 @@
+package msgp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsZero(t *testing.T) {
+	var tm time.Time
+	var n Number
+	if !IsZero(&tm) {
+		t.Error("expected the zero time to be zero")
+	}
+	tm = time.Now()
+	if IsZero(&tm) {
+		t.Error("expected the current time not to be zero")
+	}
+	if IsZero(&n) {
+		t.Error("expected a value without an IsZero method not to be zero")
+	}
+}
@@ -0,0 +1,30 @@
+package tests
+
+//go:generate msgp -equal
+
+// A Beacon is referred to through aliases.
+type Beacon struct {
+	Name  string `msgp:"name"`
+	Power int32  `msgp:"power"`
+}
+
+// The aliases get no methods: the aliased types are written in their place.
+type (
+	BeaconRef   = Beacon
+	BeaconChain = BeaconRef
+	Strength    = int8
+	Callsign    = string
+	Position    = struct {
+		Lat, Lon float64
+	}
+)
+
+// An Outpost has fields of the types of the aliases.
+type Outpost struct {
+	Main     BeaconChain         `msgp:"main"`
+	Backup   *BeaconRef          `msgp:"backup"`
+	Levels   []Strength          `msgp:"levels"`
+	Contacts map[Callsign]Beacon `msgp:"contacts"`
+	Ranks    map[string]Strength `msgp:"ranks"`
+	Where    Position            `msgp:"where"`
+}
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestAliases(t *testing.T) {
+	b := BeaconChain{Name: "north", Power: 40}
+	o := Outpost{
+		Main:     b,
+		Backup:   &b,
+		Levels:   []Strength{-3, 100},
+		Contacts: map[Callsign]Beacon{"K1": b},
+		Where:    Position{Lat: 1.5, Lon: -2},
+	}
+	bts, err := o.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fields of the aliased types are written as those types.
+	main, err := msgp.LocateField(bts, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := Beacon(b).MarshalMsg(nil); !bytes.Equal(main, want) {
+		t.Errorf("main written as %x; want %x", main, want)
+	}
+	levels, _ := msgp.LocateField(bts, "levels")
+	if want := msgp.AppendInt8(msgp.AppendInt8(msgp.AppendArrayHeader(nil, 2), -3), 100); !bytes.Equal(levels, want) {
+		t.Errorf("levels written as %x; want %x", levels, want)
+	}
+
+	var out Outpost
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !o.Equal(out) {
+		t.Errorf("unmarshaled %+v; want %+v", out, o)
+	}
+}
@@ -0,0 +1,30 @@
+package tests
+
+import "time"
+
+//go:generate msgp
+
+// ArrayPoint is written as a map except in the fields with the asarray option.
+type ArrayPoint struct {
+	X, Y float64
+}
+
+// ArrayRow is too complex to be inlined into the types referring to it.
+type ArrayRow struct {
+	ID     int64
+	Name   string
+	When   time.Time
+	Tags   []string
+	Points []ArrayPoint
+}
+
+// ArrayTable has fields whose structs are written as arrays.
+type ArrayTable struct {
+	Name   string
+	Rows   []ArrayRow        `msgp:"rows,asarray"`
+	Ptrs   []*ArrayRow       `msgp:"ptrs,asarray"`
+	Fixed  [2]ArrayPoint     `msgp:",asarray"`
+	Nested [][]ArrayPoint    `msgp:"nested,asarray"`
+	Inline []struct{ A int } `msgp:"inline,asarray"`
+	Keyed  []ArrayRow        `msgp:"keyed"`
+}
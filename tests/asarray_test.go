@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func newArrayTable() ArrayTable {
+	row := ArrayRow{
+		ID:     1,
+		Name:   "row",
+		When:   time.Unix(1500000000, 0),
+		Tags:   []string{"a"},
+		Points: []ArrayPoint{{X: 1, Y: 2}},
+	}
+	t := ArrayTable{
+		Name:   "table",
+		Rows:   []ArrayRow{row, {ID: 2, When: time.Unix(0, 0)}},
+		Ptrs:   []*ArrayRow{&row, nil},
+		Fixed:  [2]ArrayPoint{{X: 3}, {Y: 4}},
+		Nested: [][]ArrayPoint{{{X: 5}}, nil},
+		Keyed:  []ArrayRow{row},
+	}
+	t.Inline = append(t.Inline, struct{ A int }{A: 6})
+	return t
+}
+
+func TestAsArrayEncoding(t *testing.T) {
+	in := newArrayTable()
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, err := msgp.ReadIntfBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := v.(map[string]interface{})
+
+	isArray := func(v interface{}) bool {
+		_, ok := v.([]interface{})
+		return ok
+	}
+	for _, key := range []string{"rows", "ptrs", "Fixed", "inline"} {
+		if el := m[key].([]interface{})[0]; !isArray(el) {
+			t.Errorf("the elements of %s are written as %T", key, el)
+		}
+	}
+	if el := m["nested"].([]interface{})[0].([]interface{})[0]; !isArray(el) {
+		t.Errorf("the elements of nested are written as %T", el)
+	}
+	if el := m["keyed"].([]interface{})[0]; isArray(el) {
+		t.Error("the elements of keyed are written as arrays")
+	}
+	// Only the structs directly in the field are written as arrays.
+	row := m["rows"].([]interface{})[0].([]interface{})
+	if len(row) != 5 {
+		t.Fatalf("a row is written with %d elements", len(row))
+	}
+	if pt := row[4].([]interface{})[0]; isArray(pt) {
+		t.Error("the points of a row are written as arrays")
+	}
+
+	// The type's own methods still write maps.
+	b, err = in.Rows[0].MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := msgp.NextType(b); typ != msgp.MapType {
+		t.Errorf("ArrayRow is written as %s", typ)
+	}
+}
+
+func TestAsArrayRoundTrip(t *testing.T) {
+	in := newArrayTable()
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ArrayTable
+	if _, err = out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v as %+v", in, out)
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Error("EncodeMsg and MarshalMsg wrote different bytes")
+	}
+	out = ArrayTable{}
+	if err = msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decoded %+v as %+v", in, out)
+	}
+	if s := in.Msgsize(); s < len(b) {
+		t.Errorf("Msgsize returned %d for %d bytes", s, len(b))
+	}
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/netip"
+)
+
+//go:generate msgp -copy -equal -exactsize
+
+//msgp:ignore Token
+
+// A Token is written in its binary form, which is its bytes in reverse order.
+type Token struct {
+	b [4]byte
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (t Token) MarshalBinary() ([]byte, error) {
+	return []byte{t.b[3], t.b[2], t.b[1], t.b[0]}, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (t *Token) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return errors.New("a token must have 4 bytes")
+	}
+	t.b = [4]byte{data[3], data[2], data[1], data[0]}
+	return nil
+}
+
+// String returns the hex form of t.
+func (t Token) String() string { return hex.EncodeToString(t.b[:]) }
+
+// Endpoint has fields of types written in their binary forms.
+type Endpoint struct {
+	Addr     netip.Addr       `msgp:"addr,binary"`
+	Prefixes []netip.Prefix   `msgp:"prefixes,binary"`
+	Token    Token            `msgp:"token,binary"`
+	Backup   *Token           `msgp:"backup,binary"`
+	Tokens   map[string]Token `msgp:"tokens,binary"`
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestBinaryFields(t *testing.T) {
+	in := Endpoint{
+		Addr:     netip.MustParseAddr("192.0.2.1"),
+		Prefixes: []netip.Prefix{netip.MustParsePrefix("2001:db8::/32")},
+		Token:    Token{[4]byte{1, 2, 3, 4}},
+		Backup:   &Token{[4]byte{5, 6, 7, 8}},
+		Tokens:   map[string]Token{"a": {[4]byte{9, 10, 11, 12}}},
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The token is written in its binary form.
+	if want := msgp.AppendBytes(msgp.AppendString(nil, "token"), []byte{4, 3, 2, 1}); !bytes.Contains(bts, want) {
+		t.Errorf("the message %x does not hold the token as %x", bts, want)
+	}
+	if n := in.MsgsizeExact(); n != len(bts) {
+		t.Errorf("MsgsizeExact() = %d; the message has %d bytes", n, len(bts))
+	}
+
+	var out Endpoint
+	if _, err := out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) || !in.Equal(out) {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+	out = Endpoint{}
+	if err := msgp.Decode(bytes.NewReader(bts), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, in)
+	}
+
+	c := in.Clone()
+	if !in.Equal(c) {
+		t.Errorf("the clone %+v is not equal to %+v", c, in)
+	}
+	c.Tokens["a"] = Token{}
+	if in.Equal(c) {
+		t.Error("Equal does not compare the tokens")
+	}
+
+	// An invalid binary form fails to decode.
+	bad := msgp.AppendBytes(msgp.AppendString(msgp.AppendMapHeader(nil, 1), "token"), []byte{1})
+	if _, err := out.UnmarshalMsg(bad); err == nil {
+		t.Error("a token of 1 byte was decoded")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build go1.9 && !msgpnobuildtags
+// +build go1.9,!msgpnobuildtags
+
+package tests
+
+//go:generate msgp
+
+// BuildTagged is declared in a file with a build constraint, which the generated files must have.
+type BuildTagged struct {
+	Name string
+}
@@ -0,0 +1,9 @@
+package tests
+
+//go:generate msgp
+
+// LinuxOnly is declared in a file built only on Linux, so the generated files (whose names do not
+// end in _linux.go) must have a build constraint.
+type LinuxOnly struct {
+	Name string
+}
@@ -0,0 +1,27 @@
+package tests
+
+import (
+	"bufio"
+	"os"
+	"testing"
+)
+
+func TestBuildConstraints(t *testing.T) {
+	for file, want := range map[string]string{
+		"build_tags_gen.go":            "//go:build go1.9 && !msgpnobuildtags",
+		"build_tags_gen_test.go":       "//go:build go1.9 && !msgpnobuildtags",
+		"build_tags_linux_gen.go":      "//go:build linux",
+		"build_tags_linux_gen_test.go": "//go:build linux",
+	} {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := bufio.NewScanner(f)
+		s.Scan()
+		f.Close()
+		if s.Text() != want {
+			t.Errorf("%s: got the first line %q; want %q", file, s.Text(), want)
+		}
+	}
+}
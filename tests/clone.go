@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+//go:generate msgp -copy
+
+// Cloned has elements of every kind that a shallow copy would share.
+type Cloned struct {
+	Name     string
+	When     time.Time
+	Bin      []byte
+	Strs     []string
+	Nums     [3]int
+	Matrix   [][]float64
+	ByName   map[string][]byte
+	Ptr      *int
+	PtrPtr   **string
+	Child    *Cloned
+	Children []Cloned
+	Items    map[string]*ClonedItem
+	Inner    struct {
+		Tags []string
+		Vals map[string]int
+	}
+	Raw     msgp.Raw
+	Number  msgp.Number
+	Blob    ClonedBlob
+	Buckets ClonedBuckets
+	Any     interface{}
+}
+
+// ClonedItem is referred to from Cloned.
+type ClonedItem struct {
+	ID   int
+	Data []byte
+}
+
+// ClonedBlob is a named byte slice.
+type ClonedBlob []byte
+
+// ClonedBuckets is a named map.
+type ClonedBuckets map[string][]ClonedItem
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestClone(t *testing.T) {
+	n, s := 5, "s"
+	ps := &s
+	var num msgp.Number
+	num.AsFloat64(1.5)
+	in := Cloned{
+		Name:     "in",
+		When:     time.Unix(1500000000, 0),
+		Bin:      []byte{1, 2},
+		Strs:     []string{"a", "b"},
+		Nums:     [3]int{1, 2, 3},
+		Matrix:   [][]float64{{1, 2}, nil, {}},
+		ByName:   map[string][]byte{"x": {9}},
+		Ptr:      &n,
+		PtrPtr:   &ps,
+		Child:    &Cloned{Name: "child", Bin: []byte{3}},
+		Children: []Cloned{{Name: "c0", Strs: []string{"z"}}},
+		Items:    map[string]*ClonedItem{"i": {ID: 1, Data: []byte{4}}, "nil": nil},
+		Raw:      msgp.Raw{0xc0},
+		Number:   num,
+		Blob:     ClonedBlob{7},
+		Buckets:  ClonedBuckets{"b": {{ID: 2, Data: []byte{5}}}},
+		Any:      "any",
+	}
+	in.Inner.Tags = []string{"t"}
+	in.Inner.Vals = map[string]int{"v": 1}
+
+	out := in.Clone()
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("cloned %+v as %+v", in, out)
+	}
+
+	// Changing the clone must not change the original.
+	out.Bin[0] = 0
+	out.Strs[0] = ""
+	out.Matrix[0][0] = 0
+	out.ByName["x"][0] = 0
+	*out.Ptr = 0
+	**out.PtrPtr = ""
+	out.Child.Bin[0] = 0
+	out.Children[0].Strs[0] = ""
+	out.Items["i"].Data[0] = 0
+	out.Inner.Tags[0] = ""
+	out.Inner.Vals["v"] = 0
+	out.Raw[0] = 0
+	out.Blob[0] = 0
+	out.Buckets["b"][0].Data[0] = 0
+	if in.Bin[0] != 1 || in.Strs[0] != "a" || in.Matrix[0][0] != 1 || in.ByName["x"][0] != 9 ||
+		n != 5 || s != "s" || in.Child.Bin[0] != 3 || in.Children[0].Strs[0] != "z" ||
+		in.Items["i"].Data[0] != 4 || in.Inner.Tags[0] != "t" || in.Inner.Vals["v"] != 1 ||
+		in.Raw[0] != 0xc0 || in.Blob[0] != 7 || in.Buckets["b"][0].Data[0] != 5 {
+		t.Errorf("changing the clone changed the original: %+v", in)
+	}
+
+	// Nil and empty values keep their nil-ness.
+	empty := Cloned{Strs: []string{}}
+	if c := empty.Clone(); c.Strs == nil || c.Bin != nil || c.ByName != nil {
+		t.Errorf("cloned an empty slice and nil values as %+v", c)
+	}
+}
@@ -0,0 +1,24 @@
+package tests
+
+//go:generate msgp -compactfloats -exactsize -equal
+
+// Celsius is written like a float64.
+type Celsius float64
+
+// Gauges has float64s of every kind, written in the fewest bytes with -compactfloats.
+type Gauges struct {
+	Uptime   float64            `msgp:"uptime"`
+	Load     float64            `msgp:"load"`
+	Temp     Celsius            `msgp:"temp"`
+	Readings []float64          `msgp:"readings"`
+	Peaks    [3]float64         `msgp:"peaks"`
+	Ratio    *float64           `msgp:"ratio"`
+	Limits   map[string]float64 `msgp:"limits"`
+	Gain     float32            `msgp:"gain"`
+}
+
+// A Coord is written as an array of float64s.
+//msgp:tuple Coord
+type Coord struct {
+	X, Y float64
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestCompactFloats(t *testing.T) {
+	ratio := 0.1
+	full := Gauges{
+		Uptime:   86400,
+		Load:     0.75,
+		Temp:     21.3,
+		Readings: []float64{0, 1.5, math.Pi, -2, math.Inf(1)},
+		Peaks:    [3]float64{1e300, 0.125, 7},
+		Ratio:    &ratio,
+		Limits:   map[string]float64{"low": -40, "high": 85.1},
+		Gain:     2.5,
+	}
+	for _, v := range []Gauges{{}, full} {
+		bts, err := v.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := v.MsgsizeExact(); n != len(bts) {
+			t.Errorf("MsgsizeExact() = %d for a message of %d bytes", n, len(bts))
+		}
+		if n := v.Msgsize(); n < len(bts) {
+			t.Errorf("Msgsize() = %d for a message of %d bytes", n, len(bts))
+		}
+		var buf bytes.Buffer
+		if err = msgp.Encode(&buf, &v); err != nil {
+			t.Fatal(err)
+		}
+		// The entries of Limits may be written in either order.
+		if buf.Len() != len(bts) {
+			t.Errorf("EncodeMsg wrote %x; MarshalMsg appended %x", buf.Bytes(), bts)
+		}
+		var out, decoded Gauges
+		if _, err = out.UnmarshalMsg(bts); err != nil {
+			t.Fatal(err)
+		}
+		if !v.Equal(out) {
+			t.Errorf("unmarshaled %+v; want %+v", out, v)
+		}
+		if err = msgp.Decode(&buf, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !v.Equal(decoded) {
+			t.Errorf("decoded %+v; want %+v", decoded, v)
+		}
+	}
+
+	// Of the float64s, only 21.3, pi, 1e300, 0.1, and 85.1 need 8 bytes.
+	bts, _ := full.MarshalMsg(nil)
+	var count int
+	for o := bts; len(o) > 0; {
+		var err error
+		switch msgp.NextType(o) {
+		case msgp.MapType:
+			_, o, err = msgp.ReadMapHeaderBytes(o)
+		case msgp.ArrayType:
+			_, o, err = msgp.ReadArrayHeaderBytes(o)
+		case msgp.Float64Type:
+			count++
+			fallthrough
+		default:
+			o, err = msgp.Skip(o)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count != 5 {
+		t.Errorf("%d float64s written in full; want 5", count)
+	}
+
+	p := Coord{X: 3, Y: 0.1}
+	bts, _ = p.MarshalMsg(nil)
+	if want := msgp.AppendFloat64(msgp.AppendFloat32(msgp.AppendArrayHeader(nil, 2), 3), 0.1); !bytes.Equal(bts, want) {
+		t.Errorf("marshaled %x; want %x", bts, want)
+	}
+}
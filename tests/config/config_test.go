@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dchenk/msgp/gen"
+)
+
+func TestConfigFieldConsts(t *testing.T) {
+	main, _, err := gen.RunConfigData(gen.Config{
+		Src:         "./point.gosrc",
+		Mode:        gen.Encode | gen.Decode,
+		FieldConsts: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`PointFieldX = "X"`, `PointFieldY = "y"`} {
+		if !strings.Contains(main.String(), want) {
+			t.Errorf("the output does not contain %q", want)
+		}
+	}
+}
+
+func TestConfigNoTests(t *testing.T) {
+	_, _, err := gen.RunConfigData(gen.Config{
+		Src:      "./point.gosrc",
+		Mode:     gen.Encode | gen.Decode | gen.Test,
+		TestsFor: gen.Marshal | gen.Unmarshal,
+	})
+	if err == nil {
+		t.Error("no error for tests limited to the methods not generated")
+	}
+}
+
+func TestConfigOutput(t *testing.T) {
+	c := gen.Config{Src: "./point.gosrc", Mode: gen.Marshal | gen.Unmarshal | gen.Test, CrossTests: true}
+	out, err := gen.RunConfigOutput(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Main == nil || out.Tests == nil {
+		t.Fatal("the methods or the tests are missing")
+	}
+	if out.CrossTests == nil || !strings.Contains(out.CrossTests.String(), "Point") {
+		t.Error("the cross tests of Point are missing")
+	}
+
+	// Without the option, there are no cross tests.
+	c.CrossTests = false
+	if out, err = gen.RunConfigOutput(c); err != nil {
+		t.Fatal(err)
+	}
+	if out.CrossTests != nil {
+		t.Error("cross tests were generated without the option")
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	out, err := gen.RunConfigOutput(gen.Config{Src: "./point.gosrc", Mode: gen.Encode | gen.Decode, Schema: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err = json.Unmarshal(out.Schema, &doc); err != nil {
+		t.Fatalf("the schema %q is not JSON: %v", out.Schema, err)
+	}
+	if !strings.Contains(string(out.Schema), `"Point"`) {
+		t.Errorf("the schema does not describe Point:\n%s", out.Schema)
+	}
+}
+
+func TestConfigOutDir(t *testing.T) {
+	c := gen.Config{Src: "./point.gosrc", Mode: gen.Marshal | gen.Unmarshal | gen.Test, OutDir: "internal/codec"}
+	out, err := gen.RunConfigOutput(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.Main.String(), "package config\n") {
+		t.Error("the methods are not in the source package")
+	}
+	tests := out.Tests.String()
+	for _, want := range []string{"package codec\n", `"github.com/dchenk/msgp/tests/config"`, "config.Point{}"} {
+		if !strings.Contains(tests, want) {
+			t.Errorf("the tests do not contain %q", want)
+		}
+	}
+
+	c.OutPackage = "codec_test"
+	if out, err = gen.RunConfigOutput(c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.Tests.String(), "package codec_test\n") {
+		t.Error("the tests are not in the package set with OutPackage")
+	}
+
+	// The package cannot be set without the directory.
+	c.OutDir = ""
+	if _, err = gen.RunConfigOutput(c); err == nil {
+		t.Error("no error for OutPackage without OutDir")
+	}
+}
@@ -0,0 +1,32 @@
+package constraints
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dchenk/msgp/gen"
+)
+
+func TestSharedConstraint(t *testing.T) {
+	var warnings []gen.Diagnostic
+	main, _, err := gen.RunData("./testdata", gen.Encode|gen.Decode, false,
+		gen.Tags("msgpextra", "msgpmore"),
+		gen.HandleWarnings(func(d gen.Diagnostic) { warnings = append(warnings, d) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := main.String()
+	if !strings.HasPrefix(out, "//go:build msgpextra\n") {
+		t.Errorf("the output does not begin with the shared constraint:\n%s", out)
+	}
+	if !strings.Contains(out, "func (z *Common) DecodeMsg") {
+		t.Error("the output does not have the methods of Common")
+	}
+	if strings.Contains(out, "More)") {
+		t.Error("the output has the methods of More, whose file has a further constraint")
+	}
+	if len(warnings) != 1 || !strings.HasSuffix(warnings[0].File, "more.go") ||
+		!strings.Contains(warnings[0].Reason, "msgpextra && msgpmore") {
+		t.Errorf("got the warnings %v", warnings)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build msgpextra
+
+package platform
+
+type Common struct {
+	Name string
+}
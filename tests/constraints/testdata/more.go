@@ -0,0 +1,7 @@
+//go:build msgpextra && msgpmore
+
+package platform
+
+type More struct {
+	Name string
+}
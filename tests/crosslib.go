@@ -0,0 +1,36 @@
+package tests
+
+import "time"
+
+//go:generate msgp -crosstests
+
+// CrossPoint has only fields of primitive types, so it is tested against another library.
+type CrossPoint struct {
+	Name   string `msgp:"name"`
+	X, Y   float64
+	Scale  float32 `msgp:"scale"`
+	Count  int64   `msgp:"count"`
+	Flags  uint16  `msgp:"flags"`
+	Tiny   int8
+	Active bool   `msgp:"active"`
+	Data   []byte `msgp:"data"`
+}
+
+//msgp:tuple CrossPair
+
+// CrossPair is written as an array, as the other library does when told to.
+type CrossPair struct {
+	Key   string
+	Value uint64
+}
+
+// CrossEvent is not tested against another library, which encodes times differently.
+type CrossEvent struct {
+	Name string
+	At   time.Time
+}
+
+// CrossOptional is not tested against another library because of the options in its tags.
+type CrossOptional struct {
+	Name string `msgp:"name,omitzero"`
+}
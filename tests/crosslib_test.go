@@ -0,0 +1,28 @@
+package tests
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestCrossTestsFile(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "crosslib_gen_cross_test.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := f.Comments[0].List[0].Text; c != "//go:build msgpcross" {
+		t.Errorf("got the build constraint %q; want %q", c, "//go:build msgpcross")
+	}
+	var funcs []string
+	for _, d := range f.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok {
+			funcs = append(funcs, fn.Name.Name)
+		}
+	}
+	if got := strings.Join(funcs, " "); got != "TestCrossCrossPair TestCrossCrossPoint" {
+		t.Errorf("got the functions %s; want TestCrossCrossPair and TestCrossCrossPoint", got)
+	}
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"math/big"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+//go:generate msgp -copy -equal -exactsize
+
+//msgp:ignore Amount
+
+// An Amount adapts a decimal type the way a wrapper of another package's decimals would.
+type Amount struct {
+	msgp.BigDecimal
+}
+
+// SetDecimal implements msgp.DecimalUnmarshaler.
+func (a *Amount) SetDecimal(coef *big.Int, exp int32) error {
+	a.BigDecimal = msgp.BigDecimal{Coef: coef, Exp: exp}
+	return nil
+}
+
+// Invoice has fields written as decimal extensions.
+type Invoice struct {
+	Total   Amount            `msgp:"total,decimal"`
+	Tax     *Amount           `msgp:"tax,decimal"`
+	Lines   []msgp.BigDecimal `msgp:"lines,decimal"`
+	ByRate  map[string]Amount `msgp:"by_rate,decimal"`
+	Comment string            `msgp:"comment"`
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func amount(coef int64, exp int32) Amount {
+	return Amount{msgp.BigDecimal{Coef: big.NewInt(coef), Exp: exp}}
+}
+
+func TestDecimalFields(t *testing.T) {
+	tax := amount(-125, -2)
+	in := Invoice{
+		Total:  amount(1999, -2),
+		Tax:    &tax,
+		Lines:  []msgp.BigDecimal{{Coef: big.NewInt(7), Exp: 3}, {}},
+		ByRate: map[string]Amount{"std": amount(20, 0)},
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := msgp.AppendDecimal(msgp.AppendString(nil, "total"), in.Total); !bytes.Contains(bts, want) {
+		t.Errorf("the message %x does not hold %x", bts, want)
+	}
+	if n := in.MsgsizeExact(); n != len(bts) {
+		t.Errorf("MsgsizeExact() = %d; the message has %d bytes", n, len(bts))
+	}
+
+	var out Invoice
+	if _, err := out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(in) {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+	if out.Tax.Rat().Cmp(big.NewRat(-5, 4)) != 0 {
+		t.Errorf("the tax is %s; want -5/4", out.Tax.Rat())
+	}
+
+	var buf bytes.Buffer
+	w := msgp.NewWriter(&buf)
+	if err := in.EncodeMsg(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	out = Invoice{}
+	if err := out.DecodeMsg(msgp.NewReader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(in) {
+		t.Errorf("decoded %+v; want %+v", out, in)
+	}
+
+	if c := in.Clone(); !c.Equal(in) {
+		t.Errorf("cloned %+v; want %+v", c, in)
+	}
+	out.Lines[0].Exp = 2
+	if out.Equal(in) {
+		t.Error("Equal did not compare the exponents of the decimals")
+	}
+}
@@ -31,6 +31,15 @@ type Fixed struct {
 	B bool
 }
 
+// FixedTuple is a tuple with a constant maximum size.
+//
+//msgp:tuple FixedTuple
+type FixedTuple struct {
+	A int64
+	B [4]uint16
+	C bool
+}
+
 // TestType tests various kinds of types.
 type TestType struct {
 	F   *float64          `msgp:"float"`
@@ -267,3 +276,60 @@ type NonMsgpTags struct {
 		}
 	}
 }
+
+// NestedAnon has anonymous structs nested within maps, pointers, slices, and arrays.
+type NestedAnon struct {
+	ByName map[string]struct {
+		ID    int
+		Inner map[string]struct {
+			Vals []string
+		}
+	}
+	Ptrs []*struct {
+		Name string
+		Next *struct {
+			Depth int
+			Leaf  []*struct{ Data []byte }
+		}
+	}
+	Grid  [2][]struct{ X, Y float64 }
+	Table map[string][]map[string]*struct {
+		Note string
+	}
+}
+
+// AnonMap is a map of anonymous structs.
+type AnonMap map[string]struct {
+	Count int
+	Sub   map[string]*struct{ Flag bool }
+}
+
+// AnonPtrs is a slice of pointers to anonymous structs.
+type AnonPtrs []*struct {
+	Name  string
+	Items []struct{ K, V string }
+}
+
+// AnonHolder refers to types made of anonymous structs.
+type AnonHolder struct {
+	Map  AnonMap
+	Ptrs AnonPtrs
+	Arr  [2]*struct{ N int }
+}
+
+// AnonFields has anonymous structs with fields that are not serialized and embedded fields.
+type AnonFields struct {
+	Skips map[string]*struct {
+		Kept    string
+		Skipped int `msgp:"-"`
+		Func    func()
+	}
+	Embeds []*struct {
+		Embedded
+		N int
+	}
+	Private []struct {
+		A     int
+		local int
+	}
+}
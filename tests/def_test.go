@@ -2,9 +2,11 @@ package tests
 
 import (
 	"bytes"
+	"encoding/json"
 	"math"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dchenk/msgp/msgp"
 )
@@ -109,3 +111,182 @@ func TestFixed(t *testing.T) {
 	}
 
 }
+
+func TestFixedSize(t *testing.T) {
+	if !(Fixed{}).IsFixedSize() || !(&FixedTuple{}).IsFixedSize() || !(&Block{}).IsFixedSize() {
+		t.Error("fixed-size types not reported as fixed-size")
+	}
+	if (&TestType{}).IsFixedSize() || (&X{}).IsFixedSize() {
+		t.Error("variable-size types reported as fixed-size")
+	}
+
+	f := Fixed{A: -1.5, B: true}
+	bts, err := f.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bts) != FixedMsgMaxSize || f.Msgsize() != FixedMsgMaxSize {
+		t.Errorf("Fixed: encoded %d bytes, Msgsize is %d; want %d", len(bts), f.Msgsize(), FixedMsgMaxSize)
+	}
+
+	ft := FixedTuple{A: -1 << 40, B: [4]uint16{1, 500, 65000, 0}, C: true}
+	bts, err = ft.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bts) > FixedTupleMsgMaxSize || ft.Msgsize() != FixedTupleMsgMaxSize {
+		t.Errorf("FixedTuple: encoded %d bytes, Msgsize is %d; want at most %d", len(bts), ft.Msgsize(), FixedTupleMsgMaxSize)
+	}
+}
+
+func TestTupleSizeMismatch(t *testing.T) {
+	var ft FixedTuple
+	// A tuple with a field too few.
+	b := msgp.AppendArrayHeader(nil, 2)
+	b = msgp.AppendInt64(b, 1)
+	b = msgp.AppendArrayHeader(b, 4)
+	if _, err := ft.UnmarshalMsg(b); err != (msgp.ArrayError{Wanted: 3, Got: 2}) {
+		t.Errorf("unmarshaling a short tuple: got error %v", err)
+	}
+	// The array field with an element too many.
+	b = msgp.AppendArrayHeader(nil, 3)
+	b = msgp.AppendInt64(b, 1)
+	b = msgp.AppendArrayHeader(b, 5)
+	if _, err := ft.UnmarshalMsg(b); err != (msgp.ArrayError{Wanted: 4, Got: 5}) {
+		t.Errorf("unmarshaling a long array: got error %v", err)
+	}
+}
+
+func TestAppendMsg(t *testing.T) {
+	// The types that cannot fail to be marshaled have AppendMsg methods.
+	var _ msgp.Appender = Fixed{}
+	var _ msgp.Appender = &Insane{}
+	if _, ok := interface{}(&TestType{}).(msgp.Appender); ok {
+		t.Error("expected TestType, which has an interface{} field, not to have an AppendMsg method")
+	}
+
+	in := Fixed{A: 1.5, B: true}
+	bts := in.AppendMsg([]byte{0xc0})
+	if bts[0] != 0xc0 {
+		t.Fatal("expected AppendMsg to append to the slice")
+	}
+	var out Fixed
+	if _, err := out.UnmarshalMsg(bts[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+}
+
+func TestNestedAnon(t *testing.T) {
+	// The anonymous types are verbose to write out, so the value is filled in from JSON.
+	const doc = `{
+		"ByName": {"a": {"ID": 1, "Inner": {"x": {"Vals": ["one", "two"]}, "y": {}}}, "b": {"ID": 2}},
+		"Ptrs": [{"Name": "first", "Next": {"Depth": 3, "Leaf": [{"Data": "AQID"}, null]}}, null, {"Name": "last"}],
+		"Grid": [[{"X": 1.5, "Y": -2}], null],
+		"Table": {"t": [{"k": {"Note": "n"}, "nil": null}, null]}
+	}`
+	var in NestedAnon
+	if err := json.Unmarshal([]byte(doc), &in); err != nil {
+		t.Fatal(err)
+	}
+
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out NestedAnon
+	left, err := out.UnmarshalMsg(bts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) > 0 {
+		t.Errorf("%d bytes left over after UnmarshalMsg(): %q", len(left), left)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v from %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	out = NestedAnon{}
+	if err = msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decoded %+v from %+v", out, in)
+	}
+}
+
+func TestAnonFields(t *testing.T) {
+	var in AnonFields
+	const doc = `{"Skips": {"a": {"Kept": "k", "Skipped": 5}, "b": null}, "Embeds": [{"Other": "o", "N": 7}, null],
+		"Private": [{"A": 1}, {"A": 2}]}`
+	if err := json.Unmarshal([]byte(doc), &in); err != nil {
+		t.Fatal(err)
+	}
+	in.Private[1].local = 3 // the unexported field is not serialized
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out AnonFields
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	// The skipped field is not serialized.
+	in.Skips["a"].Skipped = 0
+	in.Private[1].local = 0
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v from %+v", out, in)
+	}
+}
+
+func TestTimestampFields(t *testing.T) {
+	when := time.Unix(1500000000, 123456789)
+	b := msgp.AppendArrayHeader(nil, 6)
+	b = msgp.AppendString(b, "Ann")
+	b = msgp.AppendTimeExt(b, when, msgp.TimeFormatSpec) // as other implementations write times
+	b = msgp.AppendString(b, "555")
+	b = msgp.AppendInt(b, 2)
+	b = msgp.AppendBool(b, true)
+	b = msgp.AppendFloat64(b, 1.5)
+
+	var u TestBench
+	if _, err := u.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	var d TestBench
+	if err := msgp.Decode(bytes.NewReader(b), &d); err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []time.Time{u.BirthDay, d.BirthDay} {
+		if !got.Equal(when) {
+			t.Errorf("read the time %v; want %v", got, when)
+		}
+	}
+
+	// The values of maps of times are read by the msgp map functions.
+	b = msgp.AppendMapHeader(b[:0], 1)
+	b = msgp.AppendString(b, "Times")
+	b = msgp.AppendMapHeader(b, 1)
+	b = msgp.AppendString(b, "a")
+	b = msgp.AppendTimeExt(b, when, msgp.TimeFormatSpec)
+
+	var um StrMaps
+	if _, err := um.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	var dm StrMaps
+	if err := msgp.Decode(bytes.NewReader(b), &dm); err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []time.Time{um.Times["a"], dm.Times["a"]} {
+		if !got.Equal(when) {
+			t.Errorf("read the map value %v; want %v", got, when)
+		}
+	}
+}
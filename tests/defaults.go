@@ -0,0 +1,25 @@
+package tests
+
+//go:generate msgp
+
+//msgp:tuple-compat DefaultsTuple
+
+// Level is a named type given a default value.
+type Level int8
+
+// Defaults has fields set to default values when they are missing from the encoding.
+type Defaults struct {
+	Count   int     `msgp:"count,default=42"`
+	Name    string  `msgp:"name,default=anonymous"`
+	Enabled bool    `msgp:",default=true"`
+	Ratio   float64 `msgp:",default=0.5"`
+	Mask    uint16  `msgp:",default=0xff"`
+	Level   Level   `msgp:",default=-3"`
+	Plain   int
+}
+
+// DefaultsTuple is a tuple with a field that has a default value.
+type DefaultsTuple struct {
+	A int
+	B string `msgp:",default=b"`
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestDefaultValues(t *testing.T) {
+	defaults := Defaults{
+		Count:   42,
+		Name:    "anonymous",
+		Enabled: true,
+		Ratio:   0.5,
+		Mask:    0xff,
+		Level:   -3,
+		Plain:   7,
+	}
+
+	b := msgp.AppendMapHeader(nil, 2)
+	b = msgp.AppendString(b, "count")
+	b = msgp.AppendInt(b, 1)
+	b = msgp.AppendString(b, "Plain")
+	b = msgp.AppendInt(b, 7)
+	want := defaults
+	want.Count = 1
+
+	var out Defaults
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, want)
+	}
+	out = Defaults{}
+	if err := msgp.Decode(bytes.NewReader(b), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, want)
+	}
+
+	// Values that are present are kept even if they are zero values.
+	in := Defaults{}
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = defaults
+	if _, err = out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+}
+
+func TestDefaultTupleValues(t *testing.T) {
+	b := msgp.AppendArrayHeader(nil, 1)
+	b = msgp.AppendInt(b, 5)
+	want := DefaultsTuple{A: 5, B: "b"}
+
+	var out DefaultsTuple
+	if _, err := out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", out, want)
+	}
+	out = DefaultsTuple{}
+	if err := msgp.Decode(bytes.NewReader(b), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, want)
+	}
+}
@@ -0,0 +1,115 @@
+package diagnostics
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dchenk/msgp/gen"
+)
+
+const src = "./warnings.gosrc"
+
+// The warnings about warnings.gosrc, ordered by line.
+var wantWarnings = []gen.Diagnostic{
+	{File: src, Line: 6, Column: 1, Reason: "tuple: Count: only structs can be tuples"},
+	{File: src, Line: 7, Column: 1, Reason: "inline: Missing: type not found"},
+	{File: src, Line: 8, Column: 1, Reason: `reserved: Fine: the key "Name" is reserved but used by the field Name`},
+	{File: src, Line: 9, Column: 1, Reason: `intkeys: Fine: the key "Name" is not an integer; keeping string keys`},
+	{File: src, Line: 15, Column: 2, Type: "Event", Reason: "Kind: non-local identifier: Unknown"},
+	{File: src, Line: 15, Column: 10, Type: "Event", Reason: "Unresolved identifier: Unknown"},
+	{File: src, Line: 16, Column: 2, Type: "Event", Reason: "Total: Couldn't cast to extension."},
+	{File: src, Line: 16, Column: 2, Type: "Event", Reason: "Total: ignored."},
+	{File: src, Line: 17, Column: 10, Type: "Event", Reason: "Seen: ignoring omitzero: the field is required"},
+	{File: src, Line: 18, Column: 2, Type: "Event", Reason: "Key: Couldn't read a field that is not a byte slice as raw MessagePack."},
+	{File: src, Line: 18, Column: 2, Type: "Event", Reason: "Key: ignored."},
+	{File: src, Line: 19, Column: 2, Type: "Event", Reason: "Size: Couldn't write a field that is not a byte slice or array as a string."},
+	{File: src, Line: 19, Column: 2, Type: "Event", Reason: "Size: ignored."},
+}
+
+func TestWarnings(t *testing.T) {
+	var got []gen.Diagnostic
+	_, _, err := gen.RunData(src, gen.Encode|gen.Decode, false,
+		gen.HandleWarnings(func(d gen.Diagnostic) { got = append(got, d) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The types are processed in no particular order.
+	sort.SliceStable(got, func(i, j int) bool { return got[i].Line < got[j].Line })
+	if !reflect.DeepEqual(got, wantWarnings) {
+		t.Errorf("got the warnings\n%v\nwant\n%v", got, wantWarnings)
+	}
+
+	if s := wantWarnings[6].String(); s != src+":16:2: Event: Total: Couldn't cast to extension." {
+		t.Errorf("String() = %q", s)
+	}
+	if s := (gen.Diagnostic{Reason: "no position"}).String(); s != "no position" {
+		t.Errorf("String() = %q for a warning without a position", s)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	ignore := gen.HandleWarnings(func(gen.Diagnostic) {})
+	main, tests, err := gen.RunData(src, gen.Encode|gen.Decode|gen.Test, false, gen.Strict(), ignore)
+	if werr, ok := err.(gen.WarningsError); !ok || len(werr) != len(wantWarnings) {
+		t.Fatalf("got the error %v; want a WarningsError with %d warnings", err, len(wantWarnings))
+	}
+	if main != nil || tests != nil {
+		t.Error("code was returned along with the WarningsError")
+	}
+
+	// Without Strict, the warnings do not stop the generation.
+	main, _, err = gen.RunData(src, gen.Encode|gen.Decode, false, ignore)
+	if err != nil || main == nil {
+		t.Errorf("got the error %v without Strict", err)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	const src = "./imported.gosrc"
+	_, _, err := gen.RunData(src, gen.Encode|gen.Decode, false)
+	if err == nil {
+		t.Fatal("no error for a field of a type without the methods")
+	}
+	if want := src + ":10:9: Log.Buffer: bytes.Buffer"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got the error %q; want it to begin with %q", err, want)
+	}
+	if want := src + ":11:9: Log.Block: bytes.ErrTooLarge, in the length of an array, is not a constant"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got the error %q; want it to contain %q", err, want)
+	}
+}
+
+func TestUnionNames(t *testing.T) {
+	const src = "./union.gosrc"
+	var got []gen.Diagnostic
+	_, _, err := gen.RunData(src, gen.Encode|gen.Decode, true,
+		gen.HandleWarnings(func(d gen.Diagnostic) { got = append(got, d) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gen.Diagnostic{{File: src, Line: 5, Column: 1, Reason: "union: Choice: the fields Amount and amount would both be set with SetAmount"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got the warnings\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestFreestanding(t *testing.T) {
+	const src = "./freestanding.gosrc"
+	var got []gen.Diagnostic
+	main, _, err := gen.RunData(src, gen.Marshal|gen.Unmarshal, false, gen.Freestanding(), gen.LenientFloats(),
+		gen.HandleWarnings(func(d gen.Diagnostic) { got = append(got, d) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gen.Diagnostic{
+		{Reason: "the freestanding methods read floats and bools strictly; the lenient options are ignored"},
+		{File: src, Line: 5, Column: 13, Type: "Sample", Reason: "no freestanding methods: the struct is a tuple decoded from arrays of any length"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got the warnings\n%v\nwant\n%v", got, want)
+	}
+	if !strings.Contains(main.String(), "func (z *Reading) UnmarshalMsg") {
+		t.Error("no freestanding methods for Reading")
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+//go:generate msgp -equal
+
+//msgp:shim EqualCode as:string using:(EqualCode).String/EqualCode
+//msgp:shim EqualHex as:string using:lowerEqualHex/parseEqualHex mode:convert
+
+// EqualCode is written as a string with a cast, and its letters are compared ignoring case.
+type EqualCode string
+
+// String returns the code in lower case.
+func (c EqualCode) String() string { return strings.ToLower(string(c)) }
+
+// EqualHex is a hexadecimal number written in lower case with a conversion.
+type EqualHex string
+
+func lowerEqualHex(h EqualHex) (string, error) {
+	if _, err := hex.DecodeString(string(h)); err != nil {
+		return "", err
+	}
+	return strings.ToLower(string(h)), nil
+}
+
+func parseEqualHex(s string) (EqualHex, error) { return EqualHex(s), nil }
+
+// Compared has elements of every kind that Equal methods compare.
+type Compared struct {
+	Name   string
+	When   time.Time
+	Bin    []byte
+	Float  float64
+	Strs   []string
+	Nums   [3]int
+	Times  [2]time.Time
+	Matrix [][]float32
+	ByName map[string][]byte
+	Ptr    *int
+	Child  *Compared
+	Items  map[string]*ComparedItem
+	List   []ComparedItem
+	Inner  struct {
+		Tags []string
+		When *time.Time
+	}
+	Raw     msgp.Raw
+	Number  msgp.Number
+	Blob    ComparedBlob
+	Any     interface{}
+	Code    EqualCode
+	Hex     EqualHex
+	Skipped []int `msgp:"-"`
+}
+
+// ComparedItem is referred to from Compared.
+type ComparedItem struct {
+	ID   int
+	Data []byte
+}
+
+// ComparedBlob is a named byte slice.
+type ComparedBlob []byte
+
+// ComparedGrid is an array of arrays of values compared with the == operator.
+type ComparedGrid [2][2]float64
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func newCompared() Compared {
+	n := 5
+	var num msgp.Number
+	num.AsFloat64(1.5)
+	when := time.Unix(1500000000, 0)
+	c := Compared{
+		Name:    "c",
+		When:    when,
+		Bin:     []byte{1, 2},
+		Float:   2.5,
+		Strs:    []string{"a", "b"},
+		Nums:    [3]int{1, 2, 3},
+		Times:   [2]time.Time{when, when.Add(time.Hour)},
+		Matrix:  [][]float32{{1, 2}, nil},
+		ByName:  map[string][]byte{"x": {9}},
+		Ptr:     &n,
+		Child:   &Compared{Name: "child", Bin: []byte{3}},
+		Items:   map[string]*ComparedItem{"i": {ID: 1, Data: []byte{4}}, "nil": nil},
+		List:    []ComparedItem{{ID: 2, Data: []byte{5}}},
+		Raw:     msgp.Raw{0xc0},
+		Number:  num,
+		Blob:    ComparedBlob{7},
+		Any:     map[string]interface{}{"k": []byte("v")},
+		Code:    "Code",
+		Hex:     "0a0b",
+		Skipped: []int{1},
+	}
+	c.Inner.Tags = []string{"t"}
+	c.Inner.When = &when
+	return c
+}
+
+func TestEqual(t *testing.T) {
+	a := newCompared()
+	if !a.Equal(newCompared()) {
+		t.Fatal("equal values are not equal")
+	}
+	if !a.Equal(a) {
+		t.Fatal("a value is not equal to itself")
+	}
+
+	// Each change makes b differ from a, except for the changes to what is not encoded
+	// or what is encoded the same.
+	changes := []struct {
+		name  string
+		equal bool
+		edit  func(b *Compared)
+	}{
+		{"name", false, func(b *Compared) { b.Name = "d" }},
+		{"time zone", true, func(b *Compared) { b.When = b.When.In(time.FixedZone("z", 3600)) }},
+		{"time", false, func(b *Compared) { b.When = b.When.Add(time.Nanosecond) }},
+		{"bytes", false, func(b *Compared) { b.Bin[1] = 3 }},
+		{"float", false, func(b *Compared) { b.Float = math.NaN() }},
+		{"slice length", false, func(b *Compared) { b.Strs = b.Strs[:1] }},
+		{"slice element", false, func(b *Compared) { b.Strs[1] = "c" }},
+		{"array", false, func(b *Compared) { b.Nums[2] = 4 }},
+		{"array of times", false, func(b *Compared) { b.Times[1] = b.Times[0] }},
+		{"nested slice", false, func(b *Compared) { b.Matrix[1] = []float32{0} }},
+		{"nil and empty slices", true, func(b *Compared) { b.Matrix[1] = []float32{} }},
+		{"map value", false, func(b *Compared) { b.ByName["x"] = []byte{8} }},
+		{"map key", false, func(b *Compared) { b.ByName = map[string][]byte{"y": {9}} }},
+		{"pointer", false, func(b *Compared) { n := 6; b.Ptr = &n }},
+		{"pointer to equal value", true, func(b *Compared) { n := 5; b.Ptr = &n }},
+		{"nil pointer", false, func(b *Compared) { b.Child = nil }},
+		{"child", false, func(b *Compared) { b.Child.Bin = nil }},
+		{"map of pointers", false, func(b *Compared) { b.Items["nil"] = &ComparedItem{} }},
+		{"slice of structs", false, func(b *Compared) { b.List[0].ID = 3 }},
+		{"anonymous struct", false, func(b *Compared) { b.Inner.Tags = nil }},
+		{"pointer to time", false, func(b *Compared) { b.Inner.When = nil }},
+		{"raw", false, func(b *Compared) { b.Raw = msgp.Raw{0xc2} }},
+		{"number", false, func(b *Compared) { b.Number.AsFloat32(1.5) }},
+		{"named bytes", false, func(b *Compared) { b.Blob = nil }},
+		{"interface", false, func(b *Compared) { b.Any = map[string]interface{}{"k": "v"} }},
+		{"cast shim", true, func(b *Compared) { b.Code = "CODE" }},
+		{"converted shim", true, func(b *Compared) { b.Hex = "0A0B" }},
+		{"converted shim value", false, func(b *Compared) { b.Hex = "0a0c" }},
+		{"skipped field", true, func(b *Compared) { b.Skipped = nil }},
+	}
+	for _, c := range changes {
+		b := newCompared()
+		c.edit(&b)
+		if got := a.Equal(b); got != c.equal {
+			t.Errorf("%s: Equal returned %t", c.name, got)
+		}
+		if got := b.Equal(a); got != c.equal {
+			t.Errorf("%s: Equal returned %t comparing the other way", c.name, got)
+		}
+	}
+}
+
+func TestEqualArrays(t *testing.T) {
+	a := ComparedGrid{{1, 2}, {3, 4}}
+	b := a
+	if !a.Equal(b) {
+		t.Error("equal arrays are not equal")
+	}
+	b[1][1] = math.NaN()
+	if a.Equal(b) {
+		t.Error("different arrays are equal")
+	}
+	if b.Equal(b) {
+		t.Error("an array holding NaN is equal to itself")
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+//go:generate msgp -exactsize
+
+//msgp:shim Counter as:int64 using:int64/Counter
+//msgp:shim Quantity as:string using:formatQuantity/parseQuantity mode:convert
+
+// Counter is written as an int64 with a cast.
+type Counter int64
+
+// Quantity is written as a decimal string with a conversion.
+type Quantity int
+
+func formatQuantity(q Quantity) (string, error) { return strconv.Itoa(int(q)), nil }
+
+func parseQuantity(s string) (Quantity, error) {
+	n, err := strconv.Atoi(s)
+	return Quantity(n), err
+}
+
+// ExactSized has elements of every kind whose encoded size depends on the value.
+type ExactSized struct {
+	Int      int
+	Int8     int8
+	Uint16   uint16
+	Byte     byte
+	Str      string
+	Bin      []byte
+	Fixed    [3]byte
+	Float    float64
+	When     time.Time
+	Ints     []int64
+	Floats   []float32
+	Grid     [2][2]float64
+	Counts   [4]uint32
+	Names    map[string]string
+	Ptr      *int
+	Any      interface{}
+	Ext      msgp.RawExtension `msgp:"ext,extension"`
+	Counter  Counter
+	Quantity Quantity
+	Items    []ExactItem
+	Tuple    ExactTuple
+	Lookup   map[string]*ExactItem
+}
+
+// ExactItem is referred to from ExactSized.
+type ExactItem struct {
+	ID   uint64
+	Tags []string
+}
+
+//msgp:tuple ExactTuple
+
+// ExactTuple is written as an array.
+type ExactTuple struct {
+	A int32
+	B string
+}
+
+// ExactFixed has slices and arrays of structs whose values all have the same encoded size.
+type ExactFixed struct {
+	Points []struct{ X, Y float64 }
+	Pairs  [2]ExactPair
+}
+
+//msgp:tuple ExactPair
+
+// ExactPair is written as an array of fixed-size values.
+type ExactPair struct {
+	On    bool
+	Ratio float32
+}
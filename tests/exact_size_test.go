@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestMsgsizeExact(t *testing.T) {
+	ptr := 70000
+	vals := []ExactSized{
+		{},
+		{
+			Int:      -1 << 40,
+			Int8:     -100,
+			Uint16:   math.MaxUint16,
+			Byte:     200,
+			Str:      strings.Repeat("s", 300),
+			Bin:      make([]byte, 70000),
+			Fixed:    [3]byte{1, 2, 3},
+			Float:    1.5,
+			When:     time.Unix(1500000000, 5),
+			Ints:     []int64{1, -1000, math.MaxInt64},
+			Floats:   make([]float32, 20),
+			Counts:   [4]uint32{0, 200, 70000, math.MaxUint32},
+			Names:    map[string]string{"a": "b", strings.Repeat("k", 40): ""},
+			Ptr:      &ptr,
+			Any:      map[string]interface{}{"x": []interface{}{1, "two", 3.0}},
+			Ext:      msgp.RawExtension{Type: 9, Data: []byte{1, 2, 3}},
+			Counter:  math.MinInt32,
+			Quantity: 12345,
+			Items:    []ExactItem{{ID: 1}, {ID: 1 << 50, Tags: []string{"a", "bb"}}},
+			Tuple:    ExactTuple{A: -70000, B: "tuple"},
+			Lookup:   map[string]*ExactItem{"nil": nil, "item": {ID: 300}},
+		},
+	}
+	for i, v := range vals {
+		bts, err := v.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := v.MsgsizeExact(); n != len(bts) {
+			t.Errorf("value %d: MsgsizeExact is %d but the encoding is %d bytes", i, n, len(bts))
+		}
+		if n := v.Msgsize(); n < len(bts) {
+			t.Errorf("value %d: Msgsize is %d but the encoding is %d bytes", i, n, len(bts))
+		}
+	}
+}
+
+func TestMsgsizeExactFixed(t *testing.T) {
+	v := ExactFixed{Points: make([]struct{ X, Y float64 }, 3)}
+	bts, err := v.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := v.MsgsizeExact(); n != len(bts) {
+		t.Errorf("MsgsizeExact is %d but the encoding is %d bytes", n, len(bts))
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"math"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+//go:generate msgp
+
+//msgp:ignore Point
+
+// Point is a local type encoded as an extension.
+type Point struct {
+	X, Y float32
+}
+
+// ExtensionType implements msgp.Extension.
+func (p *Point) ExtensionType() int8 { return 42 }
+
+// Len implements msgp.Extension.
+func (p *Point) Len() int { return 8 }
+
+// MarshalBinaryTo implements msgp.Extension.
+func (p *Point) MarshalBinaryTo(b []byte) error {
+	putFloat32(b, p.X)
+	putFloat32(b[4:], p.Y)
+	return nil
+}
+
+// UnmarshalBinary implements msgp.Extension.
+func (p *Point) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return msgp.ErrShortBytes
+	}
+	p.X = getFloat32(b)
+	p.Y = getFloat32(b[4:])
+	return nil
+}
+
+func putFloat32(b []byte, f float32) {
+	u := math.Float32bits(f)
+	b[0], b[1], b[2], b[3] = byte(u>>24), byte(u>>16), byte(u>>8), byte(u)
+}
+
+func getFloat32(b []byte) float32 {
+	return math.Float32frombits(uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]))
+}
+
+// Extensions has extension fields of many kinds.
+type Extensions struct {
+	Point    Point             `msgp:"point,extension"`
+	PointPtr *Point            `msgp:"point_ptr,extension"`
+	Points   []Point           `msgp:"points,extension"`
+	PtrSlice []*Point          `msgp:"ptr_slice,extension"`
+	Array    [2]Point          `msgp:"array,extension"`
+	Map      map[string]Point  `msgp:"map,extension"`
+	PtrMap   map[string]*Point `msgp:"ptr_map,extension"`
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestExtensionFields(t *testing.T) {
+	in := Extensions{
+		Point:    Point{1, 2},
+		PointPtr: &Point{3, 4},
+		Points:   []Point{{5, 6}, {7, 8}},
+		PtrSlice: []*Point{{9, 10}},
+		Array:    [2]Point{{11, 12}, {13, 14}},
+		Map:      map[string]Point{"a": {15, 16}},
+		PtrMap:   map[string]*Point{"b": {17, 18}},
+	}
+
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bts) > in.Msgsize() {
+		t.Errorf("Msgsize is %d but the encoding is %d bytes", in.Msgsize(), len(bts))
+	}
+
+	var out Extensions
+	left, err := out.UnmarshalMsg(bts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) > 0 {
+		t.Errorf("%d bytes left over after UnmarshalMsg(): %q", len(left), left)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v from %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), bts) {
+		t.Error("EncodeMsg and MarshalMsg give different encodings")
+	}
+	out = Extensions{}
+	if err = msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decoded %+v from %+v", out, in)
+	}
+}
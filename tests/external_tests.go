@@ -0,0 +1,20 @@
+package tests
+
+//go:generate msgp -xtests -tests=marshal-only -bench=false
+
+// The tests for the types in this file are generated in the external test package,
+// so tests for unexported types are skipped.
+
+type ExternalTested struct {
+	Name   string
+	Values []int
+	inner  externalTestedInner
+}
+
+type ExternalTestedSlice []ExternalTested
+
+type ExternalTestedMap map[string]ExternalTested
+
+type externalTestedInner struct {
+	Flag bool
+}
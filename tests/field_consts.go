@@ -0,0 +1,20 @@
+package tests
+
+//go:generate msgp -fieldconsts
+
+//msgp:intkeys Stamp
+
+// Parcel has fields with and without tags.
+type Parcel struct {
+	Weight   float64 `msgp:"weight"`
+	Dest     string  `msgp:"dest,omitempty"`
+	Tracking string
+	Skipped  int `msgp:"-"`
+	Stamp
+}
+
+// Stamp is written with integer keys.
+type Stamp struct {
+	Value  int    `msgp:"1"`
+	Origin string `msgp:"2"`
+}
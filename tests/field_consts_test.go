@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestFieldConsts(t *testing.T) {
+	if ParcelFieldWeight != "weight" || ParcelFieldDest != "dest" || ParcelFieldTracking != "Tracking" || ParcelFieldStamp != "Stamp" {
+		t.Errorf("the keys of Parcel are %q, %q, %q, and %q", ParcelFieldWeight, ParcelFieldDest, ParcelFieldTracking, ParcelFieldStamp)
+	}
+	var value, origin int = StampFieldValue, StampFieldOrigin
+	if value != 1 || origin != 2 {
+		t.Errorf("the keys of Stamp are %d and %d", value, origin)
+	}
+
+	bts, err := (&Parcel{Weight: 2.5, Tracking: "AB12"}).MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	field, err := msgp.LocateField(bts, ParcelFieldTracking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _, err := msgp.ReadStringBytes(field); err != nil || s != "AB12" {
+		t.Errorf("located the tracking number %q, %v; want AB12", s, err)
+	}
+}
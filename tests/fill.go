@@ -0,0 +1,28 @@
+package tests
+
+import "time"
+
+//go:generate msgp -fillsize 3
+
+// Filled is what the fill functions printed with the benchmarks are tested with.
+type Filled struct {
+	Name   string
+	Tags   []string
+	Counts map[string]uint16
+	Blob   []byte
+	When   time.Time
+	Level  FilledLevel
+	Inner  FilledInner
+	Pairs  [2]FilledInner
+	Next   *Filled
+	Items  []FilledInner
+}
+
+// FilledInner is large enough not to be inlined into Filled.
+type FilledInner struct {
+	A, B, C, D, E int32
+	Ok            bool
+}
+
+// FilledLevel is converted to and from its underlying type.
+type FilledLevel uint8
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestFill(t *testing.T) {
+	v := NewFilledFilled(rand.New(rand.NewSource(1)))
+	if len(v.Name) != 3 || len(v.Blob) != 3 || len(v.Tags) != 3 || len(v.Counts) != 3 || len(v.Items) != 3 {
+		t.Errorf("the sizes of the values are not 3: %+v", v)
+	}
+	for i, tag := range v.Tags {
+		if len(tag) != 3 {
+			t.Errorf("Tags[%d] = %q", i, tag)
+		}
+	}
+	if v.When.IsZero() {
+		t.Error("When is zero")
+	}
+	if v.Inner == (FilledInner{}) || v.Pairs[0] == (FilledInner{}) || v.Pairs[1] == (FilledInner{}) {
+		t.Errorf("the named struct values are not filled: %+v", v)
+	}
+
+	// The values of named types behind pointers and slices are left zero.
+	if v.Next == nil || !reflect.DeepEqual(*v.Next, Filled{}) {
+		t.Errorf("Next = %+v; want a pointer to a zero value", v.Next)
+	}
+	for i := range v.Items {
+		if v.Items[i] != (FilledInner{}) {
+			t.Errorf("Items[%d] = %+v", i, v.Items[i])
+		}
+	}
+
+	if w := NewFilledFilled(rand.New(rand.NewSource(1))); !reflect.DeepEqual(v, w) {
+		t.Error("the values filled with the same seed differ")
+	}
+
+	bts, err := v.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Filled
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != v.Name || !bytes.Equal(out.Blob, v.Blob) || !out.When.Equal(v.When) || out.Level != v.Level ||
+		out.Inner != v.Inner || out.Pairs != v.Pairs || !reflect.DeepEqual(out.Tags, v.Tags) ||
+		!reflect.DeepEqual(out.Counts, v.Counts) {
+		t.Errorf("%+v in; %+v out", v, out)
+	}
+}
@@ -0,0 +1,26 @@
+package tests
+
+//go:generate msgp -exactsize -equal
+
+//msgp:fixedints SlotTable SlotCount
+
+// A SlotID is written in the fewest bytes, even in a SlotTable.
+type SlotID uint16
+
+// SlotCount is written in 9 bytes.
+type SlotCount int32
+
+// A SlotTable has the kinds of integers written in 9 bytes with the fixedints directive.
+type SlotTable struct {
+	ID      SlotID           `msgp:"id"`
+	Level   int8             `msgp:"level"`
+	Flags   byte             `msgp:"flags"`
+	Offset  int              `msgp:"offset"`
+	Total   uint64           `msgp:"total"`
+	Used    []int32          `msgp:"used"`
+	Parent  *uint32          `msgp:"parent"`
+	Weights map[string]int16 `msgp:"weights"`
+	Hash    [4]byte          `msgp:"hash"`
+	Count   SlotCount        `msgp:"count"`
+	Name    string           `msgp:"name"`
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestFixedInts(t *testing.T) {
+	parent := uint32(7)
+	small := SlotTable{ID: 2000, Used: []int32{0, 1}, Parent: &parent, Weights: map[string]int16{"a": 1}}
+	large := SlotTable{
+		ID:      1000,
+		Level:   -100,
+		Flags:   0xff,
+		Offset:  math.MinInt64,
+		Total:   math.MaxUint64,
+		Used:    []int32{math.MaxInt32, -1},
+		Parent:  &parent,
+		Weights: map[string]int16{"a": math.MinInt16},
+		Hash:    [4]byte{1, 2, 3, 4},
+		Count:   -5,
+	}
+	var size int
+	for i, v := range []SlotTable{small, large} {
+		bts, err := v.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			size = len(bts)
+		} else if len(bts) != size {
+			t.Errorf("messages of %d and %d bytes; want the same sizes", size, len(bts))
+		}
+		if n := v.MsgsizeExact(); n != len(bts) {
+			t.Errorf("MsgsizeExact() = %d for a message of %d bytes", n, len(bts))
+		}
+		if n := v.Msgsize(); n < len(bts) {
+			t.Errorf("Msgsize() = %d for a message of %d bytes", n, len(bts))
+		}
+		var buf bytes.Buffer
+		if err = msgp.Encode(&buf, &v); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), bts) {
+			t.Errorf("EncodeMsg wrote %x; MarshalMsg appended %x", buf.Bytes(), bts)
+		}
+		var out SlotTable
+		if _, err = out.UnmarshalMsg(bts); err != nil {
+			t.Fatal(err)
+		}
+		if !v.Equal(out) {
+			t.Errorf("unmarshaled %+v; want %+v", out, v)
+		}
+	}
+
+	// The total can be overwritten in place.
+	bts, _ := small.MarshalMsg(nil)
+	i := bytes.Index(bts, msgp.AppendString(nil, "total")) + len("total") + 1
+	copy(bts[i:], msgp.AppendFixedUint64(nil, 1<<40))
+	var out SlotTable
+	if _, err := out.UnmarshalMsg(bts); err != nil || out.Total != 1<<40 {
+		t.Errorf("read the total %d (%v) after patching it; want %d", out.Total, err, uint64(1<<40))
+	}
+
+	c := SlotCount(3)
+	if bts, _ := c.MarshalMsg(nil); !bytes.Equal(bts, msgp.AppendFixedInt64(nil, 3)) {
+		t.Errorf("marshaled a SlotCount as %x", bts)
+	}
+}
@@ -0,0 +1,13 @@
+package tests
+
+import "github.com/dchenk/msgp/msgp"
+
+//go:generate msgp -equal
+
+// Samples holds half-precision floats.
+type Samples struct {
+	Gain    msgp.Float16
+	Values  []msgp.Float16
+	Peak    *msgp.Float16
+	Precise float32
+}
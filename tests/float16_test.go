@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestFloat16Fields(t *testing.T) {
+	peak := msgp.Float16(65504)
+	in := Samples{
+		Gain:    0.5,
+		Values:  []msgp.Float16{1, -2.5, 0.1},
+		Peak:    &peak,
+		Precise: 0.1,
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.5 is written as the half-precision float 0x3800.
+	if gain := []byte{0xd5, msgp.Float16Extension, 0x38, 0x00}; !bytes.Contains(bts, gain) {
+		t.Errorf("message %x does not hold the float16 %x", bts, gain)
+	}
+
+	var out Samples
+	if _, err := out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if out.Gain != 0.5 || *out.Peak != peak || out.Precise != 0.1 {
+		t.Errorf("got %+v", out)
+	}
+	// 0.1 is not exact in half precision.
+	if want := []msgp.Float16{1, -2.5, 0.099975586}; len(out.Values) != 3 || out.Values[0] != want[0] ||
+		out.Values[1] != want[1] || out.Values[2] != want[2] {
+		t.Errorf("got the values %v; want %v", out.Values, want)
+	}
+	if !in.Equal(out) {
+		t.Error("the values are not equal after a round trip")
+	}
+}
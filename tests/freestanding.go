@@ -0,0 +1,32 @@
+package tests
+
+//go:generate msgp -freestanding
+
+// FreeLevel is a named type written as its base type.
+type FreeLevel int16
+
+// FreeSample has fields of all of the kinds of the freestanding methods.
+type FreeSample struct {
+	ID      uint64    `msgp:"id"`
+	Delta   int       `msgp:"delta"`
+	Small   int8      `msgp:"small"`
+	Port    uint16    `msgp:"port"`
+	Code    byte      `msgp:"code"`
+	Ratio   float32   `msgp:"ratio"`
+	Value   float64   `msgp:"value"`
+	Enabled bool      `msgp:"enabled"`
+	Level   FreeLevel `msgp:"level"`
+}
+
+//msgp:tuple FreePair
+
+// FreePair is written as an array.
+type FreePair struct {
+	A int32
+	B uint32
+}
+
+// FreeNamed has a string field, so it does not get freestanding methods.
+type FreeNamed struct {
+	Name string
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"math"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestFreestanding(t *testing.T) {
+	in := FreeSample{
+		ID: math.MaxUint64, Delta: -70000, Small: -100, Port: 8080, Code: 200,
+		Ratio: 0.25, Value: -1.5, Enabled: true, Level: -3,
+	}
+	want := msgp.AppendMapHeader(nil, 9)
+	want = msgp.AppendUint64(msgp.AppendString(want, "id"), math.MaxUint64)
+	want = msgp.AppendInt(msgp.AppendString(want, "delta"), -70000)
+	want = msgp.AppendInt8(msgp.AppendString(want, "small"), -100)
+	want = msgp.AppendUint16(msgp.AppendString(want, "port"), 8080)
+	want = msgp.AppendByte(msgp.AppendString(want, "code"), 200)
+	want = msgp.AppendFloat32(msgp.AppendString(want, "ratio"), 0.25)
+	want = msgp.AppendFloat64(msgp.AppendString(want, "value"), -1.5)
+	want = msgp.AppendBool(msgp.AppendString(want, "enabled"), true)
+	want = msgp.AppendInt16(msgp.AppendString(want, "level"), -3)
+
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bts, want) {
+		t.Errorf("marshaled %x; want %x", bts, want)
+	}
+	if len(bts) > in.Msgsize() {
+		t.Errorf("marshaled %d bytes; Msgsize is %d", len(bts), in.Msgsize())
+	}
+
+	// The fields are read in any order, with unknown fields skipped and a float32 read as a float64.
+	msg := msgp.AppendMapHeader(nil, 4)
+	msg = msgp.AppendBool(msgp.AppendString(msg, "enabled"), true)
+	msg, err = msgp.AppendMapStrIntf(msgp.AppendString(msg, "other"), map[string]interface{}{"a": []interface{}{"b", 1.5, nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = msgp.AppendFloat32(msgp.AppendString(msg, "value"), 2.5)
+	msg = msgp.AppendUint8(msgp.AppendString(msg, "delta"), 7)
+	msg = append(msg, 0xc0) // the next object
+	var out FreeSample
+	left, err := out.UnmarshalMsg(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 {
+		t.Errorf("%d bytes left; want 1", len(left))
+	}
+	if exp := (FreeSample{Enabled: true, Value: 2.5, Delta: 7}); out != exp {
+		t.Errorf("unmarshaled %+v; want %+v", out, exp)
+	}
+
+	out = FreeSample{}
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+	for i := range bts {
+		if _, err = out.UnmarshalMsg(bts[:i]); err == nil {
+			t.Errorf("unmarshaled the first %d bytes of the message", i)
+		}
+	}
+
+	msg = msgp.AppendMapHeader(nil, 1)
+	msg = msgp.AppendInt(msgp.AppendString(msg, "small"), 128)
+	if _, err = out.UnmarshalMsg(msg); err == nil {
+		t.Error("unmarshaled 128 into an int8")
+	}
+	msg = msgp.AppendMapHeader(nil, 1)
+	msg = msgp.AppendInt(msgp.AppendString(msg, "id"), -1)
+	if _, err = out.UnmarshalMsg(msg); err == nil {
+		t.Error("unmarshaled -1 into a uint64")
+	}
+	msg = msgp.AppendMapHeader(nil, 1)
+	msg = msgp.AppendString(msgp.AppendString(msg, "port"), "80")
+	if _, err = out.UnmarshalMsg(msg); err == nil {
+		t.Error("unmarshaled a string into a uint16")
+	}
+}
+
+func TestFreestandingTuple(t *testing.T) {
+	in := FreePair{A: math.MinInt32, B: math.MaxUint32}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := msgp.AppendArrayHeader(nil, 2)
+	want = msgp.AppendUint32(msgp.AppendInt32(want, math.MinInt32), math.MaxUint32)
+	if !bytes.Equal(bts, want) {
+		t.Errorf("marshaled %x; want %x", bts, want)
+	}
+	if len(bts) != FreePairMsgMaxSize {
+		t.Errorf("marshaled %d bytes; want %d", len(bts), FreePairMsgMaxSize)
+	}
+	var out FreePair
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+	if _, err = out.UnmarshalMsg(msgp.AppendArrayHeader(nil, 3)); err == nil {
+		t.Error("unmarshaled an array of 3 elements")
+	}
+}
+
+// TestFreestandingFile checks that the generated file does not import msgp and that the struct
+// with a string field got no methods.
+func TestFreestandingFile(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "freestanding_gen.go", nil, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, imp := range f.Imports {
+		if imp.Path.Value == `"github.com/dchenk/msgp/msgp"` {
+			t.Error("the file imports msgp")
+		}
+	}
+	if _, ok := interface{}(&FreeNamed{}).(msgp.Marshaler); ok {
+		t.Error("FreeNamed has a MarshalMsg method")
+	}
+}
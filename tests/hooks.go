@@ -0,0 +1,58 @@
+package tests
+
+import "errors"
+
+// The values filled at random by the generated tests would fail the checks of the hooks.
+//go:generate msgp -tests=false
+
+//msgp:hooks Bill pre:fillTotal post:checkTotal
+//msgp:hooks BillLine post:checkQuantity
+
+// An Bill has a Total derived from its Lines before it is encoded and checked against them
+// after it is decoded.
+type Bill struct {
+	Lines []BillLine
+	Total int64
+}
+
+type BillLine struct {
+	Quantity int64
+	Price    int64
+}
+
+// An BillBatch holds Bills, whose hooks are called as the batch is encoded and decoded.
+type BillBatch struct {
+	Bills []Bill
+	Last  *Bill
+}
+
+var (
+	errBillTotal    = errors.New("the total of the bill does not match its lines")
+	errBillQuantity = errors.New("the quantity of a bill line is not positive")
+)
+
+func (bl *Bill) sum() (total int64) {
+	for _, l := range bl.Lines {
+		total += l.Quantity * l.Price
+	}
+	return
+}
+
+func (bl *Bill) fillTotal() error {
+	bl.Total = bl.sum()
+	return nil
+}
+
+func (bl *Bill) checkTotal() error {
+	if bl.Total != bl.sum() {
+		return errBillTotal
+	}
+	return nil
+}
+
+func (l *BillLine) checkQuantity() error {
+	if l.Quantity <= 0 {
+		return errBillQuantity
+	}
+	return nil
+}
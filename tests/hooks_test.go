@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestHooks(t *testing.T) {
+	bl := Bill{Lines: []BillLine{{Quantity: 2, Price: 15}, {Quantity: 1, Price: 70}}}
+	bts, err := bl.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Bill
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if out.Total != 100 {
+		t.Errorf("unmarshaled a total of %d; want 100", out.Total)
+	}
+
+	var buf bytes.Buffer
+	batch := BillBatch{Bills: []Bill{bl}, Last: &bl}
+	if err = msgp.Encode(&buf, &batch); err != nil {
+		t.Fatal(err)
+	}
+	var batchOut BillBatch
+	if err = msgp.Decode(&buf, &batchOut); err != nil {
+		t.Fatal(err)
+	}
+	if batchOut.Bills[0].Total != 100 || batchOut.Last.Total != 100 {
+		t.Errorf("decoded the totals %d and %d; want 100", batchOut.Bills[0].Total, batchOut.Last.Total)
+	}
+
+	// The post hooks reject the values written without calling the pre hooks.
+	bad, err := msgp.AppendIntf(nil, map[string]interface{}{"Lines": []interface{}{}, "Total": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = out.UnmarshalMsg(bad); err != errBillTotal {
+		t.Errorf("unmarshaled with the error %v; want %v", err, errBillTotal)
+	}
+	if err = out.DecodeMsg(msgp.NewReader(bytes.NewReader(bad))); err != errBillTotal {
+		t.Errorf("decoded with the error %v; want %v", err, errBillTotal)
+	}
+	bad, err = msgp.AppendIntf(nil, map[string]interface{}{
+		"Lines": []interface{}{map[string]interface{}{"Quantity": 0, "Price": 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = out.UnmarshalMsg(bad); err != errBillQuantity {
+		t.Errorf("unmarshaled with the error %v; want %v", err, errBillQuantity)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"go/token"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/dchenk/msgp/gen"
@@ -215,3 +216,121 @@ func (v *varVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	}
 	return v
 }
+
+func TestStableIdentNames(t *testing.T) {
+
+	// Each of the changes adds a field, so every variable declared for the original file should
+	// still be declared, with the same name, for the changed file.
+	srcs := []string{"./structs_small_changed.gosrc", "./structs_big_changed.gosrc", "./structs_both_changed.gosrc"}
+	mode := gen.Decode | gen.Encode | gen.Size | gen.Marshal | gen.Unmarshal
+
+	buf1, _, err := gen.RunData("./structs.go", mode, false, gen.StableIdents())
+	if err != nil {
+		t.Fatalf("error running gen; %s", err)
+	}
+	vars1, err := extractVars(buf1.Bytes())
+	if err != nil {
+		t.Fatalf("could not extract vars1: %v", err)
+	}
+
+	for indx, src := range srcs {
+		buf2, _, err := gen.RunData(src, mode, false, gen.StableIdents())
+		if err != nil {
+			t.Fatalf("error running gen; %s", err)
+		}
+		vars2, err := extractVars(buf2.Bytes())
+		if err != nil {
+			t.Fatalf("(indx %d): could not extract vars2: %v", indx, err)
+		}
+		for methodName, names := range vars1 {
+			declared := make(map[string]bool)
+			for _, v := range vars2.Value(methodName) {
+				declared[v] = true
+			}
+			for _, v := range names {
+				if !declared[v] {
+					t.Errorf("(indx %d): %s no longer declares %s", indx, methodName, v)
+				}
+			}
+		}
+	}
+
+}
+
+func TestIdentPrefix(t *testing.T) {
+
+	mode := gen.Decode | gen.Encode | gen.Size | gen.Marshal | gen.Unmarshal
+	for _, stable := range []bool{false, true} {
+		opts := []gen.Option{gen.IdentPrefix("msgpVar")}
+		if stable {
+			opts = append(opts, gen.StableIdents())
+		}
+		buf, _, err := gen.RunData("./structs.go", mode, false, opts...)
+		if err != nil {
+			t.Fatalf("error running gen; %s", err)
+		}
+		vars, err := extractVars(buf.Bytes())
+		if err != nil {
+			t.Fatalf("could not extract vars: %v", err)
+		}
+		var count int
+		for methodName, methodVars := range vars {
+			for _, v := range methodVars {
+				if v == "field" || v == "o" {
+					continue // the fixed names of a map key being read and of reserved bytes
+				}
+				if !strings.HasPrefix(v, "msgpVar") {
+					t.Errorf("(stable %t): variable %s in %s does not have the prefix", stable, v, methodName)
+				}
+				count++
+			}
+		}
+		if count == 0 {
+			t.Fatalf("(stable %t): no generated identifiers found", stable)
+		}
+	}
+
+	if _, _, err := gen.RunData("./structs.go", mode, false, gen.IdentPrefix("1x")); err == nil {
+		t.Error("no error for an invalid prefix")
+	}
+
+}
+
+func TestRunConfig(t *testing.T) {
+
+	mode := gen.Decode | gen.Encode | gen.Size | gen.Marshal | gen.Unmarshal
+	want, _, err := gen.RunData("./structs.go", mode, false, gen.IdentPrefix("msgpVar"), gen.StableIdents(),
+		gen.Receiver("v"), gen.KeySwitch(0))
+	if err != nil {
+		t.Fatalf("error running gen; %s", err)
+	}
+	got, _, err := gen.RunConfigData(gen.Config{
+		Src:          "./structs.go",
+		Mode:         mode,
+		IdentPrefix:  "msgpVar",
+		StableIdents: true,
+		Receiver:     "v",
+		KeySwitch:    -1,
+	})
+	if err != nil {
+		t.Fatalf("error running gen; %s", err)
+	}
+	if got.String() != want.String() {
+		t.Error("RunConfigData generated different code than RunData with the same options")
+	}
+
+	// The Options override the other settings.
+	got, _, err = gen.RunConfigData(gen.Config{Src: "./structs.go", Mode: mode, Receiver: "x",
+		Options: []gen.Option{gen.IdentPrefix("msgpVar"), gen.StableIdents(), gen.Receiver("v"), gen.KeySwitch(0)}})
+	if err != nil {
+		t.Fatalf("error running gen; %s", err)
+	}
+	if got.String() != want.String() {
+		t.Error("RunConfigData did not apply the Options")
+	}
+
+	if _, _, err := gen.RunConfigData(gen.Config{Src: "./structs.go"}); err == nil {
+		t.Error("no error for a Config without methods to generate")
+	}
+
+}
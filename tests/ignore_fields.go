@@ -0,0 +1,14 @@
+package tests
+
+//go:generate msgp -equal
+
+//msgp:ignorefields WireRecord Scratch reg=^XXX_
+
+// A WireRecord is like the structs of generated code, whose fields cannot be tagged.
+type WireRecord struct {
+	ID               int64
+	Name             string
+	Scratch          []byte
+	XXX_unrecognized []byte
+	XXX_sizecache    int32
+}
@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestIgnoreFields(t *testing.T) {
+	in := WireRecord{ID: 7, Name: "seven", Scratch: []byte{1}, XXX_unrecognized: []byte{2}, XXX_sizecache: 3}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := msgp.ReadMapStrIntfBytes(bts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m["ID"] != int64(7) || m["Name"] != "seven" {
+		t.Errorf("marshaled %v; want only the ID and the Name", m)
+	}
+	var out WireRecord
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if want := (WireRecord{ID: 7, Name: "seven"}); out.ID != want.ID || out.Name != want.Name || out.Scratch != nil || out.XXX_sizecache != 0 {
+		t.Errorf("unmarshaled %+v; want %+v", out, want)
+	}
+}
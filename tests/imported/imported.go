@@ -0,0 +1,77 @@
+// Package imported declares types used by the types in package tests to test the code generated
+// for types from other packages.
+package imported
+
+import "github.com/dchenk/msgp/msgp"
+
+// Level has a primitive underlying type and no methods, so it is converted to and from an int8.
+type Level int8
+
+// Point implements the msgp interfaces itself, as an array of its coordinates.
+type Point struct {
+	X, Y int
+}
+
+// DecodeMsg implements msgp.Decoder.
+func (p *Point) DecodeMsg(dc *msgp.Reader) (err error) {
+	var sz uint32
+	if sz, err = dc.ReadArrayHeader(); err != nil {
+		return
+	}
+	if sz != 2 {
+		return msgp.ArrayError{Wanted: 2, Got: sz}
+	}
+	if p.X, err = dc.ReadInt(); err != nil {
+		return
+	}
+	p.Y, err = dc.ReadInt()
+	return
+}
+
+// EncodeMsg implements msgp.Encoder.
+func (p *Point) EncodeMsg(en *msgp.Writer) (err error) {
+	if err = en.WriteArrayHeader(2); err != nil {
+		return
+	}
+	if err = en.WriteInt(p.X); err != nil {
+		return
+	}
+	return en.WriteInt(p.Y)
+}
+
+// MarshalMsg implements msgp.Marshaler.
+func (p *Point) MarshalMsg(b []byte) ([]byte, error) {
+	b = msgp.AppendArrayHeader(b, 2)
+	b = msgp.AppendInt(b, p.X)
+	return msgp.AppendInt(b, p.Y), nil
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler.
+func (p *Point) UnmarshalMsg(b []byte) ([]byte, error) {
+	sz, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return b, err
+	}
+	if sz != 2 {
+		return b, msgp.ArrayError{Wanted: 2, Got: sz}
+	}
+	if p.X, b, err = msgp.ReadIntBytes(b); err != nil {
+		return b, err
+	}
+	p.Y, b, err = msgp.ReadIntBytes(b)
+	return b, err
+}
+
+// Msgsize implements msgp.Sizer.
+func (p *Point) Msgsize() int { return msgp.ArrayHeaderSize + 2*msgp.IntSize }
+
+// IsZero says if p is the origin.
+func (p Point) IsZero() bool { return p.X == 0 && p.Y == 0 }
+
+// The sizes of arrays in package tests.
+const (
+	KeySize       = 4
+	Slots   uint8 = 200
+	Lanes   int64 = 3
+	Depth         = 2.0
+)
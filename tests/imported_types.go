@@ -0,0 +1,30 @@
+package tests
+
+import (
+	"time"
+
+	"github.com/dchenk/msgp/tests/imported"
+)
+
+//go:generate msgp
+
+// ImportedTypes has fields of named types from other packages. The types with methods have the
+// methods called, and the others are converted to and from their underlying types.
+type ImportedTypes struct {
+	Month    time.Month
+	Level    imported.Level
+	Levels   map[string]imported.Level
+	Point    imported.Point
+	PointPtr *imported.Point
+	Points   []imported.Point
+	Origin   imported.Point `msgp:",omitzero"`
+}
+
+// ImportedSizes has arrays sized by constants from another package.
+type ImportedSizes struct {
+	Key   [imported.KeySize]byte
+	Slots [imported.Slots]uint16
+	Lanes [imported.Lanes][imported.KeySize]int8
+	Twice [2 * imported.KeySize]bool
+	Deep  [imported.Depth]int32
+}
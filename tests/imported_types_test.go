@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+	"github.com/dchenk/msgp/tests/imported"
+)
+
+func TestImportedTypes(t *testing.T) {
+	in := ImportedTypes{
+		Month:    time.March,
+		Level:    -3,
+		Levels:   map[string]imported.Level{"a": 1},
+		Point:    imported.Point{X: 1, Y: 2},
+		PointPtr: &imported.Point{X: 3, Y: 4},
+		Points:   []imported.Point{{X: 5, Y: 6}},
+	}
+
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ImportedTypes
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	out = ImportedTypes{}
+	if err = msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decoded %+v; want %+v", out, in)
+	}
+
+	// The converted types are written as their underlying types.
+	m, _, err := msgp.ReadMapStrIntfBytes(bts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["Month"] != int64(time.March) || m["Level"] != int64(-3) {
+		t.Errorf("expected Month and Level to be ints; got %#v and %#v", m["Month"], m["Level"])
+	}
+
+	// The Origin field is left out while the point is the origin, as its IsZero method says.
+	if _, ok := m["Origin"]; ok {
+		t.Error("expected the zero Origin to be left out")
+	}
+	in.Origin = imported.Point{X: 1}
+	if bts, err = in.MarshalMsg(nil); err != nil {
+		t.Fatal(err)
+	}
+	if m, _, err = msgp.ReadMapStrIntfBytes(bts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["Origin"]; !ok {
+		t.Error("expected the nonzero Origin to be written")
+	}
+}
+
+func TestImportedArraySizes(t *testing.T) {
+	var in ImportedSizes
+	in.Key[0] = 1
+	in.Slots[imported.Slots-1] = 2
+	in.Lanes[imported.Lanes-1][0] = -3
+	in.Twice[2*imported.KeySize-1] = true
+	in.Deep[1] = 4
+
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bts) > in.Msgsize() || in.Msgsize() != ImportedSizesMsgMaxSize {
+		t.Errorf("encoded %d bytes with Msgsize %d and max size %d", len(bts), in.Msgsize(), ImportedSizesMsgMaxSize)
+	}
+	var out ImportedSizes
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("unmarshaled %v; want %v", out, in)
+	}
+
+	// An array of the wrong length is rejected.
+	bts = msgp.AppendMapHeader(nil, 1)
+	bts = msgp.AppendString(bts, "Twice")
+	bts = msgp.AppendArrayHeader(bts, imported.KeySize)
+	for i := 0; i < imported.KeySize; i++ {
+		bts = msgp.AppendBool(bts, false)
+	}
+	want := msgp.ArrayError{Wanted: 2 * imported.KeySize, Got: imported.KeySize}
+	if _, err = out.UnmarshalMsg(bts); err != want {
+		t.Errorf("got the error %v; want %v", err, want)
+	}
+	if err = msgp.Decode(bytes.NewReader(bts), &out); err != want {
+		t.Errorf("decoding: got the error %v; want %v", err, want)
+	}
+}
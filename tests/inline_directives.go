@@ -0,0 +1,27 @@
+package tests
+
+//go:generate msgp
+
+// The following "go generate" directive will generate an error after the code is
+// generated if the methods of InlineSmall are not called from the methods of InlineHolder.
+
+//go:generate ./time_shim_search.sh $GOFILE Small.DecodeMsg
+
+//msgp:inline InlineBig
+//msgp:noinline InlineSmall
+
+// InlineBig is too complex to be inlined by default.
+type InlineBig struct {
+	A, B, C, D, E, F int
+	G                string
+}
+
+// InlineSmall would be inlined by default.
+type InlineSmall [2]int
+
+// InlineHolder refers to types with inlining directives.
+type InlineHolder struct {
+	Big   InlineBig
+	Bigs  []InlineBig
+	Small InlineSmall
+}
@@ -0,0 +1,22 @@
+package tests
+
+//go:generate msgp -exactsize
+
+//msgp:intkeys Telemetry Compact
+//msgp:reserved Telemetry 3
+
+// Telemetry is written as a map keyed by small integers.
+type Telemetry struct {
+	Device  string            `msgp:"1"`
+	Battery uint8             `msgp:"2"`
+	Samples []float32         `msgp:"4,omitzero"`
+	Labels  map[string]string `msgp:"200"`
+	Offset  int32             `msgp:"-5"`
+	Nested  Compact           `msgp:"07"`
+}
+
+// Compact has keys of a fixed size.
+type Compact struct {
+	A int16 `msgp:"0"`
+	B bool  `msgp:"1,required"`
+}
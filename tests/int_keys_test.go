@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestIntKeys(t *testing.T) {
+	in := Telemetry{
+		Device:  "d",
+		Battery: 80,
+		Samples: []float32{1.5},
+		Labels:  map[string]string{"k": "v"},
+		Offset:  -1,
+		Nested:  Compact{A: 2, B: true},
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bts) != in.MsgsizeExact() || len(bts) > in.Msgsize() {
+		t.Errorf("encoded %d bytes; MsgsizeExact is %d and Msgsize %d", len(bts), in.MsgsizeExact(), in.Msgsize())
+	}
+
+	// The keys are integers.
+	sz, rest, err := msgp.ReadMapHeaderBytes(bts)
+	if err != nil || sz != 6 {
+		t.Fatalf("got a map of %d entries (%v)", sz, err)
+	}
+	var keys []int64
+	for i := uint32(0); i < sz; i++ {
+		var k int64
+		if k, rest, err = msgp.ReadInt64Bytes(rest); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+		if rest, err = msgp.Skip(rest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []int64{1, 2, 4, 200, -5, 7}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got the keys %v; want %v", keys, want)
+	}
+
+	var out Telemetry
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+	out = Telemetry{}
+	if err = msgp.Decode(bytes.NewReader(bts), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("decoded %+v; want %+v", out, in)
+	}
+}
+
+func TestIntKeysDecoding(t *testing.T) {
+	// The keys may be encoded as unsigned integers, and the unknown and reserved keys are skipped.
+	b := msgp.AppendMapHeader(nil, 3)
+	b = msgp.AppendUint64(b, 1)
+	b = msgp.AppendString(b, "d")
+	b = msgp.AppendInt(b, 3)
+	b = msgp.AppendString(b, "removed")
+	b = msgp.AppendInt(b, 99)
+	b = msgp.AppendBool(b, true)
+	var out Telemetry
+	if _, err := out.UnmarshalMsg(b); err != nil || out.Device != "d" {
+		t.Errorf("unmarshaled %+v, %v", out, err)
+	}
+
+	// A string key is an error.
+	b = msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendString(b, "1")
+	b = msgp.AppendString(b, "d")
+	if _, err := out.UnmarshalMsg(b); err == nil {
+		t.Error("no error for a string key")
+	}
+
+	b = msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendInt(b, 0)
+	b = msgp.AppendInt(b, 1)
+	var c Compact
+	if _, err := c.UnmarshalMsg(b); err != (msgp.ErrMissingField{Type: "Compact", Field: "1"}) {
+		t.Errorf("got the error %v for a missing required field", err)
+	}
+}
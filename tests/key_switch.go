@@ -0,0 +1,27 @@
+package tests
+
+//go:generate msgp
+
+// WideStruct has enough fields for its decoders to match the keys by length and bytes.
+type WideStruct struct {
+	ID       int64    `msgp:"id"`
+	Name     string   `msgp:"name"`
+	Email    string   `msgp:"email"`
+	Phone    string   `msgp:"phone"`
+	City     string   `msgp:"city"`
+	State    string   `msgp:"state"`
+	Zip      string   `msgp:"zip"`
+	Country  string   `msgp:"country"`
+	Created  int64    `msgp:"created"`
+	Updated  int64    `msgp:"updated"`
+	Status   string   `msgp:"status"`
+	Role     string   `msgp:"role,required"`
+	Score    float64  `msgp:"score"`
+	Level    int      `msgp:"level,default=1"`
+	Active   bool     `msgp:"active"`
+	Verified bool     `msgp:"verified"`
+	Tags     []string `msgp:"tags"`
+	Notes    string   `msgp:"notes"`
+	Owner    string   `msgp:"owner"`
+	Group    string   `msgp:"group"`
+}
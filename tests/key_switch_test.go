@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+var wideValue = WideStruct{
+	ID: 7, Name: "name", Email: "a@b.c", Phone: "555", City: "city", State: "st", Zip: "00000",
+	Country: "country", Created: 1, Updated: 2, Status: "ok", Role: "admin", Score: 1.5, Level: 3,
+	Active: true, Verified: true, Tags: []string{"a", "b"}, Notes: "notes", Owner: "o", Group: "g",
+}
+
+// wideMap returns wideValue written as a map in a different order than the fields are declared
+// in, with keys that match no field but have the lengths and bytes of the fields' keys.
+func wideMap() []byte {
+	b := msgp.AppendMapHeader(nil, 24)
+	b = msgp.AppendString(b, "tags")
+	b = msgp.AppendArrayHeader(b, 2)
+	b = msgp.AppendString(b, "a")
+	b = msgp.AppendString(b, "b")
+	for _, kv := range [][2]string{
+		{"group", "g"}, {"owner", "o"}, {"notes", "notes"}, {"zip", "00000"}, {"state", "st"},
+		{"city", "city"}, {"phone", "555"}, {"email", "a@b.c"}, {"name", "name"}, {"role", "admin"},
+		{"status", "ok"}, {"country", "country"}, {"grope", "-"}, {"cats", "-"}, {"idx", "-"},
+	} {
+		b = msgp.AppendString(b, kv[0])
+		b = msgp.AppendString(b, kv[1])
+	}
+	b = msgp.AppendBytes(msgp.AppendString(b, "statue"), []byte("-"))
+	b = msgp.AppendInt64(msgp.AppendString(b, "updated"), 2)
+	b = msgp.AppendInt64(msgp.AppendString(b, "created"), 1)
+	b = msgp.AppendFloat64(msgp.AppendString(b, "score"), 1.5)
+	b = msgp.AppendInt(msgp.AppendString(b, "level"), 3)
+	b = msgp.AppendBool(msgp.AppendString(b, "verified"), true)
+	b = msgp.AppendBool(msgp.AppendString(b, "active"), true)
+	b = msgp.AppendInt64(msgp.AppendBytes(b, []byte("id")), 7)
+	return b
+}
+
+func TestKeySwitch(t *testing.T) {
+	b := wideMap()
+
+	var w WideStruct
+	if _, err := w.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, wideValue) {
+		t.Errorf("unmarshaled %+v; want %+v", w, wideValue)
+	}
+	w = WideStruct{}
+	if err := msgp.Decode(bytes.NewReader(b), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, wideValue) {
+		t.Errorf("decoded %+v; want %+v", w, wideValue)
+	}
+
+	var ws WideStructStrings
+	if _, err := ws.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(WideStruct(ws), wideValue) {
+		t.Errorf("unmarshaled %+v with the string switch; want %+v", ws, wideValue)
+	}
+}
+
+func TestKeySwitchTrackedFields(t *testing.T) {
+	var w WideStruct
+	b := msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendString(b, "role")
+	b = msgp.AppendString(b, "user")
+	if _, err := w.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if w.Role != "user" || w.Level != 1 {
+		t.Errorf("unmarshaled role %q and level %d; want %q and 1", w.Role, w.Level, "user")
+	}
+
+	b = msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendString(b, "rule")
+	b = msgp.AppendString(b, "user")
+	if _, err := w.UnmarshalMsg(b); err == nil {
+		t.Error("unmarshaled a map without the required role")
+	}
+}
+
+func BenchmarkUnmarshalWideKeySwitch(b *testing.B) {
+	data := wideMap()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	var w WideStruct
+	for i := 0; i < b.N; i++ {
+		if _, err := w.UnmarshalMsg(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalWideStringSwitch(b *testing.B) {
+	data := wideMap()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	var w WideStructStrings
+	for i := 0; i < b.N; i++ {
+		if _, err := w.UnmarshalMsg(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
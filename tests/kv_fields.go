@@ -0,0 +1,35 @@
+package tests
+
+import "time"
+
+//go:generate msgp -kvfields 8 -equal
+
+//msgp:noinline KVPoint
+
+// KVPoint has too few fields to be written with a table.
+type KVPoint struct {
+	X, Y int
+}
+
+// KVLevel is a named type written as its base type.
+type KVLevel uint8
+
+// KVRecord has enough fields to be written with a table.
+type KVRecord struct {
+	ID      int64       `msgp:"id"`
+	Name    string      `msgp:"name"`
+	Score   float32     `msgp:"score"`
+	Active  bool        `msgp:"active"`
+	Level   KVLevel     `msgp:"level"`
+	At      time.Time   `msgp:"at"`
+	Data    []byte      `msgp:"data"`
+	Any     interface{} `msgp:"any"`
+	Point   KVPoint     `msgp:"point"`
+	Counter uint16      `msgp:"counter"`
+}
+
+// KVTagged would be written with a table but for its slice.
+type KVTagged struct {
+	A, B, C, D, E, F, G int
+	Tags                []string
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+	"github.com/dchenk/msgp/msgp/msgptest"
+)
+
+func TestKVFields(t *testing.T) {
+	in := KVRecord{
+		ID: -4, Name: "n", Score: 0.5, Active: true, Level: 3, At: time.Unix(8, 9),
+		Data: []byte{1}, Any: "x", Point: KVPoint{X: 1, Y: 2}, Counter: 300,
+	}
+	want := msgp.AppendMapHeader(nil, 10)
+	want = msgp.AppendInt64(msgp.AppendString(want, "id"), -4)
+	want = msgp.AppendString(msgp.AppendString(want, "name"), "n")
+	want = msgp.AppendFloat32(msgp.AppendString(want, "score"), 0.5)
+	want = msgp.AppendBool(msgp.AppendString(want, "active"), true)
+	want = msgp.AppendUint8(msgp.AppendString(want, "level"), 3)
+	want = msgp.AppendTime(msgp.AppendString(want, "at"), time.Unix(8, 9))
+	want = msgp.AppendBytes(msgp.AppendString(want, "data"), []byte{1})
+	want = msgp.AppendString(msgp.AppendString(want, "any"), "x")
+	want, _ = in.Point.MarshalMsg(msgp.AppendString(want, "point"))
+	want = msgp.AppendUint16(msgp.AppendString(want, "counter"), 300)
+
+	var out KVRecord
+	if bts := msgptest.RoundTrip(t, &in, &out); !bytes.Equal(bts, want) {
+		t.Errorf("marshaled %x; want %x", bts, want)
+	}
+	if !in.Equal(out) {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+	if bts := msgptest.EncodeDecode(t, &in, &out); !bytes.Equal(bts, want) {
+		t.Errorf("encoded %x; want %x", bts, want)
+	}
+	if !in.Equal(out) {
+		t.Errorf("decoded %+v; want %+v", out, in)
+	}
+
+	in.Any = make(chan int)
+	if _, err := in.MarshalMsg(nil); err == nil {
+		t.Error("marshaled a channel")
+	}
+}
+
+// TestKVFieldsGenerated checks that only the struct with enough fields that can be written with a
+// table is.
+func TestKVFieldsGenerated(t *testing.T) {
+	src, err := ioutil.ReadFile("kv_fields_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each of EncodeMsg and MarshalMsg calls a table function once.
+	if n := strings.Count(string(src), "msgp.KV{Key: \"id\""); n != 2 {
+		t.Errorf("KVRecord is written with %d tables; want 2", n)
+	}
+	if strings.Contains(string(src), "msgp.KV{Key: \"A\"") || strings.Contains(string(src), "msgp.KV{Key: \"X\"") {
+		t.Error("KVTagged or KVPoint is written with a table")
+	}
+}
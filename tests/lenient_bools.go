@@ -0,0 +1,13 @@
+package tests
+
+//go:generate msgp -lenientbools
+
+// A Relay is written by producers in C, which write bools as the integers 0 and 1.
+type Relay struct {
+	Name     string  `msgp:"name"`
+	Enabled  bool    `msgp:"enabled"`
+	Channels [4]bool `msgp:"channels"`
+	Fault    *bool   `msgp:"fault"`
+	Level    float64 `msgp:"level"`
+	History  []bool  `msgp:"history"`
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestLenientBools(t *testing.T) {
+	bts, err := msgp.AppendIntf(nil, map[string]interface{}{
+		"name":     "north",
+		"enabled":  1,
+		"channels": []interface{}{0, true, uint8(1), false},
+		"fault":    0,
+		"level":    0.5,
+		"history":  []interface{}{1, 1, 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(method string, r Relay) {
+		t.Helper()
+		if r.Name != "north" || !r.Enabled || r.Channels != [4]bool{false, true, true, false} || r.Fault == nil || *r.Fault ||
+			r.Level != 0.5 || len(r.History) != 3 || !r.History[0] || !r.History[1] || r.History[2] {
+			t.Errorf("%s read %+v", method, r)
+		}
+	}
+
+	var r Relay
+	if _, err = r.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	check("UnmarshalMsg", r)
+
+	r = Relay{}
+	if err = r.DecodeMsg(msgp.NewReader(bytes.NewReader(bts))); err != nil {
+		t.Fatal(err)
+	}
+	check("DecodeMsg", r)
+
+	// Integers other than 0 and 1 are refused.
+	bad, _ := msgp.AppendIntf(nil, map[string]interface{}{"enabled": 2})
+	if _, err = r.UnmarshalMsg(bad); err == nil {
+		t.Error("unmarshaled 2 into a bool")
+	}
+	if err = r.DecodeMsg(msgp.NewReader(bytes.NewReader(bad))); err == nil {
+		t.Error("decoded 2 into a bool")
+	}
+}
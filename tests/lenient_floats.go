@@ -0,0 +1,12 @@
+package tests
+
+//go:generate msgp -lenientfloats -views
+
+// A Quote is written by producers that may write whole float64s as integers.
+type Quote struct {
+	Price   float64            `msgp:"price"`
+	History []float64          `msgp:"history"`
+	Ratios  map[string]float64 `msgp:"ratios"`
+	Best    *float64           `msgp:"best"`
+	Volume  int64              `msgp:"volume"`
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestLenientFloats(t *testing.T) {
+	// The message of a producer writing 1.0, 2.0, and 40.0 as integers.
+	bts, err := msgp.AppendIntf(nil, map[string]interface{}{
+		"price":   1,
+		"history": []interface{}{2, 2.5, uint64(3)},
+		"ratios":  map[string]interface{}{"a": -4, "b": 0.25},
+		"best":    40,
+		"volume":  9,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(method string, q Quote) {
+		t.Helper()
+		if q.Price != 1 || len(q.History) != 3 || q.History[0] != 2 || q.History[1] != 2.5 || q.History[2] != 3 ||
+			q.Ratios["a"] != -4 || q.Ratios["b"] != 0.25 || q.Best == nil || *q.Best != 40 || q.Volume != 9 {
+			t.Errorf("%s read %+v", method, q)
+		}
+	}
+
+	var q Quote
+	if _, err = q.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	check("UnmarshalMsg", q)
+
+	q = Quote{}
+	if err = q.DecodeMsg(msgp.NewReader(bytes.NewReader(bts))); err != nil {
+		t.Fatal(err)
+	}
+	check("DecodeMsg", q)
+
+	price, err := QuoteView(bts).Price()
+	if err != nil || price != 1 {
+		t.Errorf("QuoteView.Price() = %g, %v; want 1", price, err)
+	}
+
+	// Integers are still refused where integers cannot be held.
+	bad, _ := msgp.AppendIntf(nil, map[string]interface{}{"volume": 1.5})
+	if _, err = q.UnmarshalMsg(bad); err == nil {
+		t.Error("unmarshaled a float into an int64")
+	}
+}
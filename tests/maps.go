@@ -0,0 +1,23 @@
+package tests
+
+import "time"
+
+//go:generate msgp
+
+// The following "go generate" directives will generate errors after the code is generated if
+// the generated methods do not read and write the maps using the msgp map functions.
+
+//go:generate ./time_shim_search.sh $GOFILE ReadMapStrBytes
+//go:generate ./time_shim_search.sh $GOFILE AppendMapStrTime
+
+// BytesMap is a named map of byte slices.
+type BytesMap map[string][]byte
+
+// StrMaps has maps read and written as a whole by the msgp map functions.
+type StrMaps struct {
+	Bytes map[string][]byte
+	Times map[string]time.Time
+	Named BytesMap
+	Ptr   *map[string]time.Time
+	List  []map[string][]byte
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestStrMaps(t *testing.T) {
+	times := map[string]time.Time{"c": time.Unix(1500000000, 5)}
+	in := StrMaps{
+		Bytes: map[string][]byte{"a": []byte("one"), "b": []byte("two")},
+		Times: map[string]time.Time{"a": time.Unix(1000000000, 0), "b": time.Unix(0, 0)},
+		Named: BytesMap{"x": []byte{1, 2, 3}},
+		Ptr:   &times,
+		List:  []map[string][]byte{{"y": []byte("z")}, nil},
+	}
+
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bts) > in.Msgsize() {
+		t.Errorf("Msgsize is %d but the encoding is %d bytes", in.Msgsize(), len(bts))
+	}
+
+	// The maps in out are reused.
+	out := StrMaps{Bytes: map[string][]byte{"old": nil}, Times: map[string]time.Time{}}
+	left, err := out.UnmarshalMsg(bts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) > 0 {
+		t.Errorf("%d bytes left over after UnmarshalMsg(): %q", len(left), left)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v from %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	// The encodings are not compared because the order of map elements is random.
+	if buf.Len() != len(bts) {
+		t.Errorf("EncodeMsg gives %d bytes but MarshalMsg gives %d bytes", buf.Len(), len(bts))
+	}
+	out = StrMaps{}
+	if err = msgp.Decode(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decoded %+v from %+v", out, in)
+	}
+}
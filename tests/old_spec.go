@@ -0,0 +1,12 @@
+package tests
+
+//go:generate msgp -compat=old-spec
+
+// Legacy is written by producers following the old MessagePack spec, which write byte slices as
+// strings.
+type Legacy struct {
+	Name   string
+	Data   []byte
+	Digest [4]byte
+	Blobs  map[string][]byte
+}
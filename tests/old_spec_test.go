@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestOldSpecCompat(t *testing.T) {
+	want := Legacy{
+		Name:   "name",
+		Data:   []byte("data"),
+		Digest: [4]byte{'a', 'b', 'c', 'd'},
+		Blobs:  map[string][]byte{"k": []byte("blob")},
+	}
+	// Every string is written as a 'bin' object and every byte slice as a 'str' object.
+	b := msgp.AppendMapHeader(nil, 4)
+	b = msgp.AppendString(b, "Name")
+	b = msgp.AppendBytes(b, []byte("name"))
+	b = msgp.AppendString(b, "Data")
+	b = msgp.AppendString(b, "data")
+	b = msgp.AppendString(b, "Digest")
+	b = msgp.AppendString(b, "abcd")
+	b = msgp.AppendString(b, "Blobs")
+	b = msgp.AppendMapHeader(b, 1)
+	b = msgp.AppendBytes(b, []byte("k"))
+	b = msgp.AppendString(b, "blob")
+
+	var got Legacy
+	if _, err := got.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalMsg: got %+v; want %+v", got, want)
+	}
+
+	got = Legacy{}
+	if err := msgp.Decode(bytes.NewReader(b), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", got, want)
+	}
+
+	// The types are written as the current spec has them.
+	out, err := want.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out, msgp.AppendBytes(nil, []byte("data"))) {
+		t.Errorf("the byte slice is not written as 'bin' in %x", out)
+	}
+}
@@ -0,0 +1,35 @@
+package tests
+
+import "time"
+
+//go:generate msgp -exactsize
+
+// OmitZero has fields that are left out of the encoding when they are zero.
+type OmitZero struct {
+	Count   int               `msgp:"count,omitzero"`
+	Name    string            `msgp:"name,omitzero"`
+	On      bool              `msgp:"on,omitzero"`
+	Data    []byte            `msgp:"data,omitzero"`
+	Tags    []string          `msgp:"tags,omitzero"`
+	Attrs   map[string]string `msgp:"attrs,omitzero"`
+	Next    *int              `msgp:"next,omitzero"`
+	Digest  [4]byte           `msgp:"digest,omitzero"`
+	At      time.Time         `msgp:"at,omitzero"`
+	Price   Money             `msgp:"price,omitzero"`
+	Range   Span              `msgp:"range,omitzero"`
+	Written int               // always written
+}
+
+// Money is zero if its amount is, whatever the currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// IsZero says if m has no amount.
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+// Span is zero if both of its ends are.
+type Span struct {
+	From, To int
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestOmitZero(t *testing.T) {
+	next := 0
+	full := OmitZero{
+		Count:   1,
+		Name:    "n",
+		On:      true,
+		Data:    []byte{},
+		Tags:    []string{},
+		Attrs:   map[string]string{},
+		Next:    &next,
+		Digest:  [4]byte{1},
+		At:      time.Unix(1, 0).UTC(),
+		Price:   Money{Amount: 5, Currency: "EUR"},
+		Range:   Span{To: 1},
+		Written: 0,
+	}
+	cases := []struct {
+		in   OmitZero
+		keys int
+	}{
+		{OmitZero{}, 1},
+		{OmitZero{Price: Money{Currency: "EUR"}, Range: Span{From: 2}}, 2},
+		{full, 12},
+	}
+	for i, tc := range cases {
+		bts, err := tc.in.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sz, _, err := msgp.ReadMapHeaderBytes(bts); err != nil || sz != uint32(tc.keys) {
+			t.Errorf("case %d: expected %d keys; got %d (error %v)", i, tc.keys, sz, err)
+		}
+		if n := tc.in.MsgsizeExact(); n != len(bts) {
+			t.Errorf("case %d: MsgsizeExact returned %d; the encoding has %d bytes", i, n, len(bts))
+		}
+		if n := tc.in.Msgsize(); n < len(bts) {
+			t.Errorf("case %d: Msgsize returned %d; the encoding has %d bytes", i, n, len(bts))
+		}
+		var buf bytes.Buffer
+		if err := msgp.Encode(&buf, &tc.in); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), bts) {
+			t.Errorf("case %d: EncodeMsg and MarshalMsg differ", i)
+		}
+
+		// The fields left out are zeroed when decoding into a value holding others.
+		want := tc.in
+		if want.Price.IsZero() {
+			want.Price = Money{}
+		}
+		out := full
+		if _, err := out.UnmarshalMsg(bts); err != nil {
+			t.Fatal(err)
+		}
+		if !out.At.IsZero() {
+			out.At = out.At.UTC()
+		}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("case %d: unmarshaled %+v; want %+v", i, out, want)
+		}
+		out = full
+		if err := msgp.Decode(&buf, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !out.At.IsZero() {
+			out.At = out.At.UTC()
+		}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("case %d: decoded %+v; want %+v", i, out, want)
+		}
+	}
+}
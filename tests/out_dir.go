@@ -0,0 +1,10 @@
+package tests
+
+//go:generate msgp -outdir codec -crosstests
+
+// Relocated has its tests written to the codec directory, in a package of their own.
+type Relocated struct {
+	Name  string
+	Count int
+	Ratio float64
+}
@@ -0,0 +1,20 @@
+package tests
+
+//go:generate msgp -copy -equal -exactsize -views
+
+// A DeviceRecord is a record of an embedded device, whose strings are fixed-width or zero-terminated
+// fields of 'bin' objects.
+type DeviceRecord struct {
+	Station string            `msgp:"station,width=8,pad=0x20"`
+	Unit    string            `msgp:"unit,zeroterm"`
+	Label   string            `msgp:"label,width=6,zeroterm"`
+	Tags    []string          `msgp:"tags,width=4"`
+	Note    *string           `msgp:"note,zeroterm"`
+	Codes   map[string]string `msgp:"codes,width=3,pad=0x30"`
+	Name    string            `msgp:"name"`
+}
+
+// A CString has only zero-terminated strings, which any string fits, so it gets an AppendMsg method.
+type CString struct {
+	Value string `msgp:"value,zeroterm"`
+}
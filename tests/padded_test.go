@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestPaddedStrings(t *testing.T) {
+	note := "calibrated"
+	in := DeviceRecord{
+		Station: "north",
+		Unit:    "kPa",
+		Label:   "abc",
+		Tags:    []string{"a", "bcd"},
+		Note:    &note,
+		Codes:   map[string]string{"x": "7"},
+		Name:    "gauge",
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Msgsize() < len(bts) || in.MsgsizeExact() != len(bts) {
+		t.Errorf("Msgsize is %d and MsgsizeExact is %d but the encoding is %d bytes", in.Msgsize(), in.MsgsizeExact(), len(bts))
+	}
+
+	// The fields are laid out as a C program would write them.
+	for key, want := range map[string][]byte{
+		"station": []byte("north   "),
+		"unit":    []byte("kPa\x00"),
+		"label":   []byte("abc\x00\x00\x00"),
+	} {
+		field, err := msgp.LocateField(bts, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _, err := msgp.ReadBytesZC(field); err != nil || !bytes.Equal(data, want) {
+			t.Errorf("field %q is %q (%v); want %q", key, data, err, want)
+		}
+	}
+
+	var out DeviceRecord
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v from %+v", out, in)
+	}
+	var dec DeviceRecord
+	if err = msgp.Decode(bytes.NewReader(bts), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !in.Equal(dec) {
+		t.Errorf("decoded %+v from %+v", dec, in)
+	}
+	if unit, err := DeviceRecordView(bts).Unit(); err != nil || unit != "kPa" {
+		t.Errorf("the view reads the unit %q (%v)", unit, err)
+	}
+
+	in.Station = "too long a name"
+	var wide msgp.StringWidthError
+	if _, err = in.MarshalMsg(nil); !errors.As(err, &wide) || wide.Width != 8 {
+		t.Errorf("marshaling a string wider than its field returned %v", err)
+	}
+}
+
+func TestZeroTerminatedAppend(t *testing.T) {
+	in := CString{Value: "/dev/ttyS0"}
+	var _ msgp.Appender = &in
+	bts := in.AppendMsg(nil)
+	var out CString
+	if _, err := out.UnmarshalMsg(bts); err != nil || out != in {
+		t.Errorf("unmarshaled %+v (%v) from %+v", out, err, in)
+	}
+}
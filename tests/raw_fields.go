@@ -0,0 +1,19 @@
+package tests
+
+//go:generate msgp -copy -equal
+
+// Envelope is passed on by a proxy, which copies its payloads without decoding them.
+type Envelope struct {
+	Kind    string
+	Payload []byte            `msgp:"payload,raw"`
+	Parts   [][]byte          `msgp:"parts,raw"`
+	ByName  map[string][]byte `msgp:",raw"`
+	Trailer []byte            `msgp:"trailer,raw"`
+	Sum     []byte            `msgp:"sum"`
+}
+
+// EnvelopeView is decoded from an Envelope, reading its payload.
+type EnvelopeView struct {
+	Kind    string
+	Payload map[string]string `msgp:"payload"`
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+	"github.com/dchenk/msgp/msgp/msgptest"
+)
+
+func TestRawFields(t *testing.T) {
+	payload := msgp.AppendMapStrStr(nil, map[string]string{"a": "b"})
+	trailer := msgp.AppendInt(nil, -7)
+	in := Envelope{
+		Kind:    "k",
+		Payload: payload,
+		Parts:   [][]byte{msgp.AppendString(nil, "p"), nil},
+		ByName:  map[string][]byte{"n": msgp.AppendBool(nil, true)},
+		Trailer: trailer,
+		Sum:     []byte{1, 2},
+	}
+	var out Envelope
+	bts := msgptest.RoundTrip(t, &in, &out)
+	if !in.Equal(out) {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+	msgptest.EncodeDecode(t, &in, &out)
+	if !in.Equal(out) {
+		t.Errorf("decoded %+v; want %+v", out, in)
+	}
+
+	// The payload is spliced in as it is, and the empty part is written as nil.
+	if got, err := msgp.LocateField(bts, "payload"); err != nil || !bytes.Equal(got, payload) {
+		t.Errorf("the payload was written as %x (%v); want %x", got, err, payload)
+	}
+	var view EnvelopeView
+	if _, err := view.UnmarshalMsg(bts); err != nil || view.Payload["a"] != "b" {
+		t.Errorf("read the payload as %v (%v)", view.Payload, err)
+	}
+	if len(out.Parts[1]) != 0 {
+		t.Errorf("the nil part was read as %x", out.Parts[1])
+	}
+
+	in.Payload = payload[:len(payload)-1]
+	if _, err := in.MarshalMsg(nil); err != msgp.ErrShortBytes {
+		t.Errorf("marshaling a cut payload gave the error %v", err)
+	}
+	if err := msgp.Encode(msgp.Nowhere, &in); err != msgp.ErrShortBytes {
+		t.Errorf("encoding a cut payload gave the error %v", err)
+	}
+}
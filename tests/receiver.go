@@ -0,0 +1,18 @@
+package tests
+
+//go:generate msgp -receiver r -copy -equal
+
+//msgp:receiver Readings rs
+
+// Reading has a field named like the default receiver.
+type Reading struct {
+	Z     float64
+	Label string
+	Tags  map[string]string
+}
+
+// Readings is a named slice, whose decoders dereference the receiver.
+type Readings []Reading
+
+// ReadingIndex is a named map.
+type ReadingIndex map[string]Readings
@@ -0,0 +1,19 @@
+package tests
+
+//go:generate msgp -copy -equal
+
+//msgp:pointer-receiver Pair
+//msgp:value-receiver Record
+
+// Pair would have value receivers by its fields.
+type Pair struct {
+	A, B int
+}
+
+// Record would have pointer receivers by its number of fields.
+type Record struct {
+	ID    int64
+	Name  string
+	Score float64
+	Tags  []string
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// The methods of Record not changing it have value receivers.
+var (
+	_ msgp.Encoder   = Record{}
+	_ msgp.Marshaler = Record{}
+	_ msgp.Sizer     = Record{}
+)
+
+func TestReceiverKinds(t *testing.T) {
+	var pair interface{} = Pair{}
+	if _, ok := pair.(msgp.Marshaler); ok {
+		t.Error("Pair has a MarshalMsg method with a value receiver")
+	}
+	if _, ok := pair.(msgp.Sizer); ok {
+		t.Error("Pair has a Msgsize method with a value receiver")
+	}
+
+	in := Record{ID: 1, Name: "r", Score: 0.5, Tags: []string{"t"}}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Record
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(in) || !in.Clone().Equal(in) {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+}
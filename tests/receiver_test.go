@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestReceiverNames(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "receiver_gen.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Reading": "r", "Readings": "rs", "ReadingIndex": "r"}
+	for _, d := range f.Decls {
+		fn, ok := d.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil {
+			continue
+		}
+		field := fn.Recv.List[0]
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		name := typ.(*ast.Ident).Name
+		if got := field.Names[0].Name; got != want[name] {
+			t.Errorf("the receiver of %s.%s is named %q; want %q", name, fn.Name.Name, got, want[name])
+		}
+	}
+}
+
+func TestReceiverRoundTrip(t *testing.T) {
+	in := ReadingIndex{
+		"a": {{Z: 1.5, Label: "one", Tags: map[string]string{"k": "v"}}, {Z: -2}},
+		"b": nil,
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ReadingIndex
+	if _, err := out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !in.Equal(out) || !in.Clone().Equal(in) {
+		t.Errorf("got %v; want %v", out, in)
+	}
+}
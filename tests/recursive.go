@@ -0,0 +1,42 @@
+package tests
+
+//go:generate msgp
+
+// The following types refer to each other in cycles, so they cannot all be inlined into
+// each other's methods.
+
+type RecursiveA struct {
+	Name string
+	Bs   map[string]RecursiveB
+}
+
+type RecursiveB struct {
+	As []RecursiveA
+}
+
+type RecursivePtrA struct {
+	B *RecursivePtrB
+	N int
+}
+
+type RecursivePtrB struct {
+	A *RecursivePtrA
+}
+
+type RecursiveMap map[string]RecursiveMap
+
+type RecursiveSlice []RecursiveSliceMap
+
+type RecursiveSliceMap map[string]RecursiveSlice
+
+type RecursiveTriA struct {
+	B []RecursiveTriB
+}
+
+type RecursiveTriB struct {
+	C map[string]RecursiveTriC
+}
+
+type RecursiveTriC struct {
+	A *RecursiveTriA
+}
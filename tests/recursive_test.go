@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+type recursiveType interface {
+	msgp.Encoder
+	msgp.Decoder
+	msgp.Marshaler
+	msgp.Unmarshaler
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	vals := []struct {
+		in, out recursiveType
+	}{
+		{
+			&RecursiveA{Name: "a", Bs: map[string]RecursiveB{
+				"b": {As: []RecursiveA{{Name: "aa", Bs: map[string]RecursiveB{"bb": {}}}}},
+			}},
+			new(RecursiveA),
+		},
+		{
+			&RecursivePtrA{N: 1, B: &RecursivePtrB{A: &RecursivePtrA{N: 2, B: &RecursivePtrB{}}}},
+			new(RecursivePtrA),
+		},
+		{
+			&RecursiveMap{"x": RecursiveMap{"y": RecursiveMap{"z": RecursiveMap{}}}},
+			new(RecursiveMap),
+		},
+		{
+			&RecursiveSlice{RecursiveSliceMap{"a": RecursiveSlice{RecursiveSliceMap{"b": RecursiveSlice{}}}}},
+			new(RecursiveSlice),
+		},
+		{
+			&RecursiveTriA{B: []RecursiveTriB{{C: map[string]RecursiveTriC{
+				"c": {A: &RecursiveTriA{B: []RecursiveTriB{{C: map[string]RecursiveTriC{}}}}},
+			}}}},
+			new(RecursiveTriA),
+		},
+	}
+
+	for _, v := range vals {
+		bts, err := v.in.MarshalMsg(nil)
+		if err != nil {
+			t.Fatalf("%T: %s", v.in, err)
+		}
+		left, err := v.out.UnmarshalMsg(bts)
+		if err != nil {
+			t.Fatalf("%T: %s", v.in, err)
+		}
+		if len(left) > 0 {
+			t.Errorf("%T: %d bytes left over after UnmarshalMsg()", v.in, len(left))
+		}
+		// Empty maps and slices are decoded as nil, so compare the encodings.
+		if out, _ := v.out.MarshalMsg(nil); !bytes.Equal(out, bts) {
+			t.Errorf("%T: unmarshaled %v; want %v", v.in, v.out, v.in)
+		}
+
+		var buf bytes.Buffer
+		if err = msgp.Encode(&buf, v.in); err != nil {
+			t.Fatalf("%T: %s", v.in, err)
+		}
+		if !bytes.Equal(buf.Bytes(), bts) {
+			t.Errorf("%T: EncodeMsg and MarshalMsg gave different bytes", v.in)
+		}
+		reflect.ValueOf(v.out).Elem().Set(reflect.Zero(reflect.TypeOf(v.out).Elem()))
+		if err = msgp.Decode(&buf, v.out); err != nil {
+			t.Fatalf("%T: %s", v.in, err)
+		}
+		if out, _ := v.out.MarshalMsg(nil); !bytes.Equal(out, bts) {
+			t.Errorf("%T: decoded %v; want %v", v.in, v.out, v.in)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package tests
+
+import "time"
+
+//go:generate msgp -registry
+
+// RegKind is a named type written as its base type.
+type RegKind uint8
+
+// RegOrder is written as a map with string keys.
+type RegOrder struct {
+	ID     int64              `msgp:"id"`
+	Kind   RegKind            `msgp:"kind"`
+	Placed time.Time          `msgp:"placed"`
+	Lines  []RegLine          `msgp:"lines"`
+	Extra  map[string]float64 `msgp:"extra"`
+	Note   *string            `msgp:"note"`
+	Any    interface{}        `msgp:"any"`
+}
+
+//msgp:tuple RegLine
+
+// RegLine is written as an array.
+type RegLine struct {
+	SKU   string
+	Count uint32
+	Hash  [4]byte
+}
+
+//msgp:intkeys RegCoded
+
+// RegCoded is written as a map keyed by integers.
+type RegCoded struct {
+	Name string `msgp:"3"`
+	Raw  []byte `msgp:"9"`
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+// registered returns the registered schema of the type of v.
+func registered(t *testing.T, v interface{}) msgp.TypeSchema {
+	t.Helper()
+	typ := reflect.TypeOf(v)
+	s, ok := msgp.LookupSchema(typ.PkgPath() + "." + typ.Name())
+	if !ok {
+		t.Fatalf("no schema is registered for %s", typ)
+	}
+	return s
+}
+
+func TestRegistry(t *testing.T) {
+	if s := registered(t, RegKind(0)); s.Type != msgp.UintType || len(s.Fields) != 0 {
+		t.Errorf("got the schema %+v for RegKind", s)
+	}
+
+	line := registered(t, RegLine{})
+	want := msgp.TypeSchema{Name: line.Name, Type: msgp.ArrayType, Fields: []msgp.FieldSchema{
+		{Name: "SKU", Num: 0, Type: msgp.StrType},
+		{Name: "Count", Num: 1, Type: msgp.UintType},
+		{Name: "Hash", Num: 2, Type: msgp.BinType},
+	}}
+	if !reflect.DeepEqual(line, want) {
+		t.Errorf("got the schema %+v for RegLine; want %+v", line, want)
+	}
+
+	order := registered(t, RegOrder{})
+	want = msgp.TypeSchema{Name: order.Name, Type: msgp.MapType, Fields: []msgp.FieldSchema{
+		{Name: "ID", Key: "id", Num: 0, Type: msgp.IntType},
+		{Name: "Kind", Key: "kind", Num: 1, Type: msgp.UintType, Schema: registered(t, RegKind(0)).Name},
+		{Name: "Placed", Key: "placed", Num: 2, Type: msgp.TimeType},
+		{Name: "Lines", Key: "lines", Num: 3, Type: msgp.ArrayType, Schema: line.Name},
+		{Name: "Extra", Key: "extra", Num: 4, Type: msgp.MapType},
+		{Name: "Note", Key: "note", Num: 5, Type: msgp.StrType},
+		{Name: "Any", Key: "any", Num: 6, Type: msgp.InvalidType},
+	}}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("got the schema %+v for RegOrder; want %+v", order, want)
+	}
+
+	coded := registered(t, RegCoded{})
+	want = msgp.TypeSchema{Name: coded.Name, Type: msgp.MapType, Fields: []msgp.FieldSchema{
+		{Name: "Name", Key: "3", Num: 3, Type: msgp.StrType},
+		{Name: "Raw", Key: "9", Num: 9, Type: msgp.BinType},
+	}}
+	if !reflect.DeepEqual(coded, want) {
+		t.Errorf("got the schema %+v for RegCoded; want %+v", coded, want)
+	}
+}
@@ -0,0 +1,20 @@
+package tests
+
+//go:generate msgp
+
+//msgp:tuple-compat RequiredTuple
+
+// Required has fields that must be present when it is decoded.
+type Required struct {
+	A    int    `msgp:",required"`
+	B    string `msgp:"b,required"`
+	C    int
+	D, E bool `msgp:",required"`
+}
+
+// RequiredTuple is decoded from arrays of at least two elements.
+type RequiredTuple struct {
+	A int `msgp:",required"`
+	B int `msgp:",required"`
+	C int
+}
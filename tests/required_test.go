@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestRequiredFields(t *testing.T) {
+	full, err := (&Required{A: 1, B: "b", C: 3, D: true, E: true}).MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only C may be left out.
+	b := msgp.AppendMapHeader(nil, 4)
+	b = msgp.AppendString(b, "A")
+	b = msgp.AppendInt(b, 1)
+	b = msgp.AppendString(b, "b")
+	b = msgp.AppendString(b, "b")
+	b = msgp.AppendString(b, "D")
+	b = msgp.AppendBool(b, true)
+	b = msgp.AppendString(b, "E")
+	b = msgp.AppendBool(b, true)
+
+	missingB := msgp.AppendMapHeader(nil, 3)
+	missingB = msgp.AppendString(missingB, "A")
+	missingB = msgp.AppendInt(missingB, 1)
+	missingB = msgp.AppendString(missingB, "D")
+	missingB = msgp.AppendBool(missingB, true)
+	missingB = msgp.AppendString(missingB, "E")
+	missingB = msgp.AppendBool(missingB, true)
+
+	cases := []struct {
+		data []byte
+		err  error
+	}{
+		{full, nil},
+		{b, nil},
+		{missingB, msgp.ErrMissingField{Type: "Required", Field: "b"}},
+		{msgp.AppendMapHeader(nil, 0), msgp.ErrMissingField{Type: "Required", Field: "A"}},
+	}
+	for i, tc := range cases {
+		var r Required
+		if _, err := r.UnmarshalMsg(tc.data); err != tc.err {
+			t.Errorf("case %d: UnmarshalMsg: expected error %v; got %v", i, tc.err, err)
+		}
+		if err := msgp.Decode(bytes.NewReader(tc.data), &r); err != tc.err {
+			t.Errorf("case %d: DecodeMsg: expected error %v; got %v", i, tc.err, err)
+		}
+	}
+}
+
+func TestRequiredTupleFields(t *testing.T) {
+	for n := 0; n <= 3; n++ {
+		b := msgp.AppendArrayHeader(nil, uint32(n))
+		for i := 0; i < n; i++ {
+			b = msgp.AppendInt(b, i)
+		}
+		var want error
+		switch n {
+		case 0:
+			want = msgp.ErrMissingField{Type: "RequiredTuple", Field: "A"}
+		case 1:
+			want = msgp.ErrMissingField{Type: "RequiredTuple", Field: "B"}
+		}
+		var r RequiredTuple
+		if _, err := r.UnmarshalMsg(b); err != want {
+			t.Errorf("%d elements: UnmarshalMsg: expected error %v; got %v", n, want, err)
+		}
+		if err := msgp.Decode(bytes.NewReader(b), &r); err != want {
+			t.Errorf("%d elements: DecodeMsg: expected error %v; got %v", n, want, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package tests
+
+//go:generate msgp -keyswitch 2
+
+//msgp:reserved Account email phone
+//msgp:reserved Tiny old
+//msgp:union Contact
+//msgp:reserved Contact fax
+
+// Account had email and phone fields, whose keys are reserved. Its keys are matched by length
+// and bytes.
+type Account struct {
+	ID   int64  `msgp:"id"`
+	Name string `msgp:"name"`
+	Plan string `msgp:"plan"`
+}
+
+// Tiny had an "old" field. Its keys are matched as strings.
+type Tiny struct {
+	V int `msgp:"v"`
+}
+
+// Contact is a union that had a fax variant.
+type Contact struct {
+	Email *string `msgp:"email"`
+	Phone *string `msgp:"phone"`
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestReservedKeys(t *testing.T) {
+	b := msgp.AppendMapHeader(nil, 5)
+	b = msgp.AppendString(b, "email")
+	b = msgp.AppendMapStrStr(b, map[string]string{"a": "b"})
+	b = msgp.AppendString(b, "id")
+	b = msgp.AppendInt64(b, 3)
+	b = msgp.AppendString(b, "phone")
+	b = msgp.AppendArrayHeader(b, 1)
+	b = msgp.AppendString(b, "555")
+	b = msgp.AppendString(b, "name")
+	b = msgp.AppendString(b, "n")
+	b = msgp.AppendString(b, "plan")
+	b = msgp.AppendString(b, "p")
+	want := Account{ID: 3, Name: "n", Plan: "p"}
+	var a Account
+	if _, err := a.UnmarshalMsg(b); err != nil || a != want {
+		t.Errorf("unmarshaled %+v, %v; want %+v", a, err, want)
+	}
+	a = Account{}
+	if err := msgp.Decode(bytes.NewReader(b), &a); err != nil || a != want {
+		t.Errorf("decoded %+v, %v; want %+v", a, err, want)
+	}
+
+	b = msgp.AppendMapHeader(nil, 2)
+	b = msgp.AppendString(b, "old")
+	b = msgp.AppendBool(b, true)
+	b = msgp.AppendString(b, "v")
+	b = msgp.AppendInt(b, 4)
+	var tiny Tiny
+	if _, err := tiny.UnmarshalMsg(b); err != nil || tiny.V != 4 {
+		t.Errorf("unmarshaled %+v, %v", tiny, err)
+	}
+}
+
+func TestReservedUnionKeys(t *testing.T) {
+	b := msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendString(b, "fax")
+	b = msgp.AppendString(b, "555")
+	c := Contact{Email: new(string)}
+	if _, err := c.UnmarshalMsg(b); err != nil || c != (Contact{}) {
+		t.Errorf("unmarshaled %+v, %v; want no fields set", c, err)
+	}
+	c = Contact{Email: new(string)}
+	if err := msgp.Decode(bytes.NewReader(b), &c); err != nil || c != (Contact{}) {
+		t.Errorf("decoded %+v, %v; want no fields set", c, err)
+	}
+
+	b = msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendString(b, "pager")
+	b = msgp.AppendString(b, "555")
+	want := msgp.UnionError{Type: "Contact", Key: "pager"}
+	if _, err := c.UnmarshalMsg(b); err != want {
+		t.Errorf("got the error %v; want %v", err, want)
+	}
+}
@@ -0,0 +1,37 @@
+package tests
+
+import "time"
+
+//go:generate msgp -schema
+
+// SchemaItem is referred to by SchemaOrder.
+type SchemaItem struct {
+	SKU   string  `msgp:"sku,required"`
+	Count uint16  `msgp:"count"`
+	Price float64 `msgp:"price"`
+}
+
+// SchemaOrder has fields of most kinds of types.
+type SchemaOrder struct {
+	ID      int64                 `msgp:"id"`
+	Placed  time.Time             `msgp:"placed"`
+	Items   []SchemaItem          `msgp:"items"`
+	Notes   map[string]string     `msgp:"notes"`
+	Gift    *SchemaItem           `msgp:"gift"`
+	Digest  [4]byte               `msgp:"digest"`
+	Data    []byte                `msgp:"data"`
+	Extra   interface{}           `msgp:"extra"`
+	Coords  [2]float32            `msgp:"coords"`
+	Flags   map[string]SchemaFlag `msgp:"flags"`
+	Comment string                `msgp:"comment,omitzero"`
+}
+
+// SchemaFlag is written as its base type.
+type SchemaFlag bool
+
+//msgp:tuple SchemaPoint
+
+// SchemaPoint is written as an array.
+type SchemaPoint struct {
+	X, Y int8
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	data, err := ioutil.ReadFile("schema_gen_schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Schema string                            `json:"$schema"`
+		Defs   map[string]map[string]interface{} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Schema != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("got the dialect %q", doc.Schema)
+	}
+	var names []string
+	for name := range doc.Defs {
+		names = append(names, name)
+	}
+	if len(names) != 4 {
+		t.Errorf("got the definitions %v; want those of SchemaFlag, SchemaItem, SchemaOrder, and SchemaPoint", names)
+	}
+
+	// The properties are compared as JSON, in which the numbers are float64s.
+	prop := func(def, key string) string {
+		props, _ := doc.Defs[def]["properties"].(map[string]interface{})
+		b, _ := json.Marshal(props[key])
+		return string(b)
+	}
+	for _, c := range []struct{ def, key, want string }{
+		{"SchemaItem", "sku", `{"type":"string"}`},
+		{"SchemaItem", "count", `{"maximum":65535,"minimum":0,"type":"integer"}`},
+		{"SchemaOrder", "placed", `{"format":"date-time","type":"string"}`},
+		{"SchemaOrder", "items", `{"items":{"$ref":"#/$defs/SchemaItem"},"type":"array"}`},
+		{"SchemaOrder", "notes", `{"additionalProperties":{"type":"string"},"type":"object"}`},
+		{"SchemaOrder", "gift", `{"anyOf":[{"type":"null"},{"$ref":"#/$defs/SchemaItem"}]}`},
+		{"SchemaOrder", "digest", `{"contentEncoding":"base64","type":"string"}`},
+		{"SchemaOrder", "data", `{"contentEncoding":"base64","type":"string"}`},
+		{"SchemaOrder", "extra", `{}`},
+		{"SchemaOrder", "coords", `{"items":{"type":"number"},"maxItems":2,"minItems":2,"type":"array"}`},
+		{"SchemaOrder", "flags", `{"additionalProperties":{"$ref":"#/$defs/SchemaFlag"},"type":"object"}`},
+	} {
+		if got := prop(c.def, c.key); got != c.want {
+			t.Errorf("%s.%s: got %s; want %s", c.def, c.key, got, c.want)
+		}
+	}
+	if got := doc.Defs["SchemaItem"]["required"]; !reflect.DeepEqual(got, []interface{}{"sku"}) {
+		t.Errorf("SchemaItem requires %v; want only sku", got)
+	}
+	if _, ok := doc.Defs["SchemaOrder"]["required"]; ok {
+		t.Error("SchemaOrder has required fields")
+	}
+	if got := doc.Defs["SchemaFlag"]["type"]; got != "boolean" {
+		t.Errorf("SchemaFlag has the type %v; want boolean", got)
+	}
+	b, _ := json.Marshal(doc.Defs["SchemaPoint"])
+	want := `{"maxItems":2,"minItems":2,"prefixItems":[{"maximum":127,"minimum":-128,"type":"integer"},` +
+		`{"maximum":127,"minimum":-128,"type":"integer"}],"title":"SchemaPoint","type":"array"}`
+	if string(b) != want {
+		t.Errorf("got the definition of SchemaPoint %s; want %s", b, want)
+	}
+}
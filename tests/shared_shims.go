@@ -0,0 +1,18 @@
+package tests
+
+import "strconv"
+
+// The shims in this file are loaded with the -shims flag when generating code for other files.
+
+//msgp:shim SharedID as:string using:formatSharedID/parseSharedID mode:convert
+//msgp:shim net.IP as:string using:net.IP.String/net.ParseIP
+
+// SharedID is shimmed as a string by a shared shim.
+type SharedID uint32
+
+func formatSharedID(id SharedID) (string, error) { return strconv.FormatUint(uint64(id), 16), nil }
+
+func parseSharedID(s string) (SharedID, error) {
+	id, err := strconv.ParseUint(s, 16, 32)
+	return SharedID(id), err
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestSharedShims(t *testing.T) {
+	in := SharedShims{
+		ID:   0xbeef,
+		IDs:  []SharedID{1, 0xabc},
+		Addr: net.ParseIP("192.0.2.1"),
+	}
+	b, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fields are encoded as strings.
+	var m map[string]interface{}
+	if m, _, err = msgp.ReadMapStrIntfBytes(b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if m["ID"] != "beef" || m["Addr"] != "192.0.2.1" {
+		t.Errorf("unexpected encoding %v", m)
+	}
+
+	var out SharedShims
+	if _, err = out.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v from %+v", out, in)
+	}
+}
@@ -0,0 +1,12 @@
+package tests
+
+import "net"
+
+//go:generate msgp -shims shared_shims.go
+
+// SharedShims has fields of types shimmed by the shims in shared_shims.go.
+type SharedShims struct {
+	ID   SharedID
+	IDs  []SharedID
+	Addr net.IP
+}
@@ -0,0 +1,15 @@
+package tests
+
+//go:generate msgp -sql -equal
+
+//msgp:receiver SQLTags tags
+
+// SQLRecord is stored in a database column as its MessagePack encoding.
+type SQLRecord struct {
+	Name  string            `msgp:"name"`
+	Attrs map[string]string `msgp:"attrs"`
+	Score float64           `msgp:"score"`
+}
+
+// SQLTags is a named slice stored as MessagePack.
+type SQLTags []string
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+var (
+	_ driver.Valuer = SQLRecord{}
+	_ sql.Scanner   = (*SQLRecord)(nil)
+	_ driver.Valuer = SQLTags{}
+	_ sql.Scanner   = (*SQLTags)(nil)
+)
+
+func TestSQLMethods(t *testing.T) {
+	in := SQLRecord{Name: "meter", Attrs: map[string]string{"unit": "kWh"}, Score: 0.5}
+	v, err := in.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bts, ok := v.([]byte)
+	if !ok || !driver.IsValue(v) {
+		t.Fatalf("Value returned a %T", v)
+	}
+
+	var out SQLRecord
+	if err := out.Scan(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(in) {
+		t.Errorf("scanned %+v from []byte; want %+v", out, in)
+	}
+	out = SQLRecord{}
+	if err := out.Scan(string(bts)); err != nil || !out.Equal(in) {
+		t.Errorf("scanned %+v from a string (%v); want %+v", out, err, in)
+	}
+	if err := out.Scan(nil); err != nil || !out.Equal(SQLRecord{}) {
+		t.Errorf("scanned %+v from NULL (%v); want the zero value", out, err)
+	}
+	var unsupported *msgp.ErrUnsupportedType
+	if err := out.Scan(int64(4)); !errors.As(err, &unsupported) {
+		t.Errorf("scanning an int64 returned %v", err)
+	}
+
+	tags := SQLTags{"a", "b"}
+	v, err = tags.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tagsOut SQLTags
+	if err := tagsOut.Scan(v); err != nil || !tagsOut.Equal(tags) {
+		t.Errorf("scanned %v (%v); want %v", tagsOut, err, tags)
+	}
+}
@@ -0,0 +1,29 @@
+package tests
+
+//go:generate msgp -copy -equal
+
+// Label has byte slices and arrays holding text, which are written as strings.
+type Label struct {
+	ID    [8]byte            `msgp:"id,str"`
+	Name  []byte             `msgp:"name,str"`
+	Alias *[]byte            `msgp:"alias,str"`
+	Tags  [][]byte           `msgp:"tags,str"`
+	Codes map[string][2]byte `msgp:"codes,str"`
+	Sum   [2]byte            `msgp:"sum"`
+}
+
+// LabelView is decoded from a Label, reading its strings.
+type LabelView struct {
+	ID    string            `msgp:"id"`
+	Name  string            `msgp:"name"`
+	Alias string            `msgp:"alias"`
+	Tags  []string          `msgp:"tags"`
+	Codes map[string]string `msgp:"codes"`
+}
+
+// LabelBin has the fields of a Label written as bin data, which a Label reads too.
+type LabelBin struct {
+	ID   [8]byte  `msgp:"id"`
+	Name []byte   `msgp:"name"`
+	Tags [][]byte `msgp:"tags"`
+}
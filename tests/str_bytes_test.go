@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+	"github.com/dchenk/msgp/msgp/msgptest"
+)
+
+func TestStrBytes(t *testing.T) {
+	alias := []byte("tok")
+	in := Label{
+		ID:    [8]byte{'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h'},
+		Name:  []byte("name"),
+		Alias: &alias,
+		Tags:  [][]byte{[]byte("x"), []byte("yz")},
+		Codes: map[string][2]byte{"c": {'o', 'k'}},
+		Sum:   [2]byte{1, 2},
+	}
+	var out Label
+	bts := msgptest.RoundTrip(t, &in, &out)
+	if !in.Equal(out) {
+		t.Errorf("unmarshaled %+v; want %+v", out, in)
+	}
+	msgptest.EncodeDecode(t, &in, &out)
+	if !in.Equal(out) {
+		t.Errorf("decoded %+v; want %+v", out, in)
+	}
+
+	for _, f := range []string{"id", "name", "alias"} {
+		if got, err := msgp.LocateField(bts, f); err != nil || msgp.NextType(got) != msgp.StrType {
+			t.Errorf("the field %q was written as %x (%v); want a string", f, got, err)
+		}
+	}
+	if got, err := msgp.LocateField(bts, "sum"); err != nil || msgp.NextType(got) != msgp.BinType {
+		t.Errorf("the field %q was written as %x (%v); want bin data", "sum", got, err)
+	}
+	var view LabelView
+	if _, err := view.UnmarshalMsg(bts); err != nil {
+		t.Fatalf("reading the strings: %s", err)
+	}
+	want := LabelView{ID: "abcdefgh", Name: "name", Alias: "tok", Tags: []string{"x", "yz"}, Codes: map[string]string{"c": "ok"}}
+	if !msgptest.Equal(view, want) {
+		t.Errorf("read the strings as %+v; want %+v", view, want)
+	}
+
+	// Bin data is accepted as well.
+	bin := LabelBin{ID: in.ID, Name: in.Name, Tags: in.Tags}
+	bts, err := bin.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = Label{}
+	if _, err := out.UnmarshalMsg(bts); err != nil {
+		t.Fatalf("unmarshaling bin data: %s", err)
+	}
+	if out.ID != in.ID || !bytes.Equal(out.Name, in.Name) || !msgptest.Equal(out.Tags, in.Tags) {
+		t.Errorf("unmarshaled the bin data as %+v", out)
+	}
+	out = Label{}
+	if err := msgp.Decode(bytes.NewReader(bts), &out); err != nil {
+		t.Fatalf("decoding bin data: %s", err)
+	}
+	if out.ID != in.ID || !bytes.Equal(out.Name, in.Name) || !msgptest.Equal(out.Tags, in.Tags) {
+		t.Errorf("decoded the bin data as %+v", out)
+	}
+}
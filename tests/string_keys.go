@@ -0,0 +1,20 @@
+package tests
+
+//go:generate msgp
+
+// Color is a string type used as the type of map keys.
+type Color string
+
+// Shade is declared as another string type.
+type Shade Color
+
+// Palette has maps keyed by named string types, whose keys are written as strings.
+type Palette struct {
+	Counts  map[Color]int           `msg:"counts"`
+	Blobs   map[Shade][]byte        `msg:"blobs"`
+	Nested  map[Color]map[Shade]int `msg:"nested"`
+	Default Color                   `msg:"default"`
+}
+
+// PaletteSet is a named map keyed by a named string type.
+type PaletteSet map[Color]*Palette
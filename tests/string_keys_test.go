@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestStringKindedKeys(t *testing.T) {
+	in := PaletteSet{
+		"warm": {
+			Counts:  map[Color]int{"red": 3, "orange": 1},
+			Blobs:   map[Shade][]byte{"dark": []byte("xyz")},
+			Nested:  map[Color]map[Shade]int{"red": {"light": 2}},
+			Default: "red",
+		},
+		"none": nil,
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bts) > in.Msgsize() {
+		t.Errorf("Msgsize is %d but the encoding is %d bytes", in.Msgsize(), len(bts))
+	}
+
+	// The keys are written as plain strings.
+	generic, _, err := msgp.ReadMapStrIntfBytes(bts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic["warm"].(map[string]interface{}); !ok || generic["none"] != nil {
+		t.Errorf("read %+v as a map of strings", generic)
+	}
+	var out PaletteSet
+	if _, err = out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unmarshaled %+v from %+v", out, in)
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, &in); err != nil {
+		t.Fatal(err)
+	}
+	var dec PaletteSet
+	if err = msgp.Decode(&buf, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, dec) {
+		t.Errorf("decoded %+v from %+v", dec, in)
+	}
+}
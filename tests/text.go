@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+//go:generate msgp -copy -equal -exactsize
+
+//msgp:ignore Severity
+
+// A Severity is written by its name.
+type Severity int
+
+// The severities.
+const (
+	Debug Severity = iota
+	Info
+	Warn
+)
+
+var severityNames = [...]string{"debug", "info", "warn"}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(severityNames) {
+		return nil, fmt.Errorf("invalid severity %d", int(s))
+	}
+	return []byte(severityNames[s]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severityNames {
+		if name == string(text) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid severity %q", text)
+}
+
+// LogRule has fields of types written in their text forms.
+type LogRule struct {
+	Severity Severity            `msgp:"severity,text"`
+	Sources  []netip.Addr        `msgp:"sources,text"`
+	Dest     *netip.AddrPort     `msgp:"dest,text"`
+	Per      map[string]Severity `msgp:"per,text"`
+}
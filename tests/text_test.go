@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestTextFields(t *testing.T) {
+	dest := netip.MustParseAddrPort("[2001:db8::1]:514")
+	in := LogRule{
+		Severity: Warn,
+		Sources:  []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")},
+		Dest:     &dest,
+		Per:      map[string]Severity{"db": Debug},
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The values are written in their text forms.
+	for _, want := range [][]byte{
+		msgp.AppendString(msgp.AppendString(nil, "severity"), "warn"),
+		msgp.AppendString(msgp.AppendString(nil, "dest"), "[2001:db8::1]:514"),
+		msgp.AppendString(nil, "192.0.2.2"),
+	} {
+		if !bytes.Contains(bts, want) {
+			t.Errorf("the message %x does not hold %x", bts, want)
+		}
+	}
+	if n := in.MsgsizeExact(); n != len(bts) {
+		t.Errorf("MsgsizeExact() = %d; the message has %d bytes", n, len(bts))
+	}
+
+	var out LogRule
+	if _, err := out.UnmarshalMsg(bts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) || !in.Equal(out) {
+		t.Errorf("got %+v; want %+v", out, in)
+	}
+	out = LogRule{}
+	if err := msgp.Decode(bytes.NewReader(bts), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("DecodeMsg: got %+v; want %+v", out, in)
+	}
+
+	c := in.Clone()
+	if !in.Equal(c) {
+		t.Errorf("the clone %+v is not equal to %+v", c, in)
+	}
+	c.Per["db"] = Info
+	if in.Equal(c) {
+		t.Error("Equal does not compare the severities")
+	}
+
+	// Values that cannot be written or read in their text forms fail.
+	if _, err := (&LogRule{Severity: 7}).MarshalMsg(nil); err == nil {
+		t.Error("an invalid severity was marshaled")
+	}
+	bad := msgp.AppendString(msgp.AppendString(msgp.AppendMapHeader(nil, 1), "severity"), "loud")
+	if _, err := out.UnmarshalMsg(bad); err == nil {
+		t.Error("an invalid severity was unmarshaled")
+	}
+}
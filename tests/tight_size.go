@@ -0,0 +1,22 @@
+package tests
+
+//go:generate msgp -tightsize -exactsize
+
+// Inventory has the kinds of fields whose sizes Msgsize measures with -tightsize.
+type Inventory struct {
+	Labels   map[string]string  `msgp:"labels"`
+	Groups   [][]string         `msgp:"groups"`
+	Counts   []int              `msgp:"counts"`
+	Buckets  map[string][]int64 `msgp:"buckets"`
+	Codes    [4]uint16          `msgp:"codes"`
+	Sum      [8]byte            `msgp:"sum"`
+	Parts    map[string]Part    `msgp:"parts"`
+	Extra    interface{}        `msgp:"extra"`
+	Location *string            `msgp:"location"`
+}
+
+// A Part is sized by its own methods.
+type Part struct {
+	Name  string `msgp:"name"`
+	Count uint32 `msgp:"count"`
+}
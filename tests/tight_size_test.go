@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestTightSizes(t *testing.T) {
+	loc := "shelf"
+	full := Inventory{
+		Labels:   map[string]string{"a": "b", "color": "blue", "long": strings.Repeat("x", 300)},
+		Groups:   [][]string{{"x", "y"}, nil, {"z"}},
+		Counts:   []int{1, -1, 200, 1 << 40},
+		Buckets:  map[string][]int64{"low": {1, 2, 3}, "high": {1 << 20}},
+		Codes:    [4]uint16{1, 2, 300, 65535},
+		Parts:    map[string]Part{"p": {Name: "bolt", Count: 12}},
+		Extra:    "extra",
+		Location: &loc,
+	}
+	for _, v := range []Inventory{{}, {Labels: full.Labels, Counts: full.Counts}, full} {
+		bts, err := v.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only the sizes of the interface and of the Part values are not measured.
+		max := v.MsgsizeExact() + msgp.GuessSize(v.Extra) - msgp.SizeOfIntf(v.Extra)
+		for _, p := range v.Parts {
+			max += p.Msgsize() - p.MsgsizeExact()
+		}
+		if n := v.Msgsize(); n < len(bts) || n > max {
+			t.Errorf("Msgsize() = %d for a message of %d bytes; want at most %d", n, len(bts), max)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package tests
+
+import "time"
+
+//go:generate msgp
+
+//msgp:tuple-compat TupleCompatV1 TupleCompatV2 TupleCompatNested
+
+// TupleCompatV1 and TupleCompatV2 are two versions of the same tuple-encoded type,
+// the second of which has more fields.
+
+type TupleCompatV1 struct {
+	ID   int
+	Name string
+}
+
+type TupleCompatV2 struct {
+	ID      int
+	Name    string
+	Tags    []string
+	Created time.Time
+	Inner   struct{ X, Y float64 }
+	Level   CustomInt
+	Ptr     *int
+}
+
+type TupleCompatNested struct {
+	A struct{ X int }
+	B struct{ Y int }
+}
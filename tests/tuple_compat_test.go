@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestTupleCompatLonger(t *testing.T) {
+	level := 7
+	v2 := TupleCompatV2{
+		ID:      1,
+		Name:    "one",
+		Tags:    []string{"a", "b"},
+		Created: time.Unix(1500000000, 0),
+		Level:   3,
+		Ptr:     &level,
+	}
+	v2.Inner.X = 2.5
+
+	bts, err := v2.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v1 TupleCompatV1
+	left, err := v1.UnmarshalMsg(bts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) > 0 {
+		t.Errorf("%d bytes left over after UnmarshalMsg(): %q", len(left), left)
+	}
+	if v1.ID != v2.ID || v1.Name != v2.Name {
+		t.Errorf("decoded %+v from %+v", v1, v2)
+	}
+
+	v1 = TupleCompatV1{}
+	if err = msgp.Decode(bytes.NewReader(bts), &v1); err != nil {
+		t.Fatal(err)
+	}
+	if v1.ID != v2.ID || v1.Name != v2.Name {
+		t.Errorf("decoded %+v from %+v", v1, v2)
+	}
+}
+
+func TestTupleCompatShorter(t *testing.T) {
+	v1 := TupleCompatV1{ID: 2, Name: "two"}
+	bts, err := v1.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// All of the fields missing from the encoding must be zeroed.
+	level := 7
+	filled := TupleCompatV2{
+		ID:      5,
+		Name:    "five",
+		Tags:    []string{"c"},
+		Created: time.Unix(1500000000, 0),
+		Level:   3,
+		Ptr:     &level,
+	}
+	filled.Inner.Y = 1
+	want := TupleCompatV2{ID: 2, Name: "two"}
+
+	v2 := filled
+	left, err := v2.UnmarshalMsg(bts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) > 0 {
+		t.Errorf("%d bytes left over after UnmarshalMsg(): %q", len(left), left)
+	}
+	if !reflect.DeepEqual(v2, want) {
+		t.Errorf("unmarshaled %+v; want %+v", v2, want)
+	}
+
+	v2 = filled
+	if err = msgp.Decode(bytes.NewReader(bts), &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v2, want) {
+		t.Errorf("decoded %+v; want %+v", v2, want)
+	}
+}
@@ -0,0 +1,28 @@
+package tests
+
+//go:generate msgp
+
+//msgp:union Shape
+//msgp:receiver Shape v
+
+// Shape holds one of the shapes, written as a map of one entry.
+type Shape struct {
+	Circle *Circle   `msgp:"circle"`
+	Rect   *Rect     `msgp:"rect"`
+	Path   *[]Vertex `msgp:"path"`
+}
+
+// Circle is one of the variants of Shape.
+type Circle struct {
+	Radius float64
+}
+
+// Rect is one of the variants of Shape.
+type Rect struct {
+	W, H float64
+}
+
+// Vertex is a point of a path.
+type Vertex struct {
+	X, Y int
+}
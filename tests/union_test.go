@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestUnion(t *testing.T) {
+	var s Shape
+	s.SetRect(&Rect{W: 2, H: 3})
+	s.SetCircle(&Circle{Radius: 1})
+	if s.GetRect() != nil || s.GetCircle() == nil || s.GetCircle().Radius != 1 {
+		t.Fatalf("after setting the circle: %+v", s)
+	}
+
+	for _, in := range []Shape{s, {}, {Path: &[]Vertex{{1, 2}}}} {
+		bts, err := in.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		if in != (Shape{}) {
+			want = 1
+		}
+		if sz, _, err := msgp.ReadMapHeaderBytes(bts); err != nil || sz != uint32(want) {
+			t.Fatalf("got a map of %d entries (%v); want %d", sz, err, want)
+		}
+
+		out := Shape{Rect: &Rect{W: 1}}
+		if _, err = out.UnmarshalMsg(bts); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("unmarshaled %+v; want %+v", out, in)
+		}
+		out = Shape{Rect: &Rect{W: 1}}
+		if err = msgp.Decode(bytes.NewReader(bts), &out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("decoded %+v; want %+v", out, in)
+		}
+	}
+}
+
+func TestUnionErrors(t *testing.T) {
+	two := Shape{Circle: &Circle{}, Rect: &Rect{}}
+	if _, err := two.MarshalMsg(nil); err != (msgp.UnionError{Type: "Shape", Fields: 2}) {
+		t.Errorf("marshaling two fields: got error %v", err)
+	}
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, &two); err != (msgp.UnionError{Type: "Shape", Fields: 2}) {
+		t.Errorf("encoding two fields: got error %v", err)
+	}
+
+	bts := msgp.AppendMapHeader(nil, 2)
+	bts = msgp.AppendString(bts, "circle")
+	bts = msgp.AppendMapHeader(bts, 0)
+	bts = msgp.AppendString(bts, "rect")
+	bts = msgp.AppendMapHeader(bts, 0)
+	var s Shape
+	if _, err := s.UnmarshalMsg(bts); err != (msgp.UnionError{Type: "Shape", Fields: 2}) {
+		t.Errorf("unmarshaling two entries: got error %v", err)
+	}
+	if err := msgp.Decode(bytes.NewReader(bts), &s); err != (msgp.UnionError{Type: "Shape", Fields: 2}) {
+		t.Errorf("decoding two entries: got error %v", err)
+	}
+
+	bts = msgp.AppendMapHeader(nil, 1)
+	bts = msgp.AppendString(bts, "square")
+	bts = msgp.AppendInt(bts, 1)
+	want := msgp.UnionError{Type: "Shape", Key: "square"}
+	if _, err := s.UnmarshalMsg(bts); err != want {
+		t.Errorf("unmarshaling an unknown key: got error %v", err)
+	}
+	if err := msgp.Decode(bytes.NewReader(bts), &s); err != want {
+		t.Errorf("decoding an unknown key: got error %v", err)
+	}
+	if want.Error() != `msgp: union Shape has no field "square"` {
+		t.Errorf("unexpected message %q", want.Error())
+	}
+}
@@ -0,0 +1,20 @@
+package tests
+
+//go:generate msgp -views
+
+// Profile is read through a ProfileView.
+type Profile struct {
+	Name    string            `msgp:"name,required"`
+	Age     int               `msgp:"age"`
+	Country string            `msgp:"country,default=NZ"`
+	Emails  []string          `msgp:"emails"`
+	Prefs   map[string]string `msgp:"prefs"`
+	Home    *Address          `msgp:"home"`
+	Work    Address           `msgp:"work"`
+}
+
+// Address is a struct within a Profile.
+type Address struct {
+	Street string
+	Zip    int
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestView(t *testing.T) {
+	in := Profile{
+		Name:    "n",
+		Age:     30,
+		Country: "FR",
+		Emails:  []string{"a@b.c"},
+		Prefs:   map[string]string{"k": "v"},
+		Home:    &Address{Street: "s", Zip: 1},
+		Work:    Address{Street: "w", Zip: 2},
+	}
+	bts, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := ProfileView(bts)
+	if name, err := v.Name(); err != nil || name != in.Name {
+		t.Errorf("Name() = %q, %v", name, err)
+	}
+	if age, err := v.Age(); err != nil || age != in.Age {
+		t.Errorf("Age() = %d, %v", age, err)
+	}
+	if c, err := v.Country(); err != nil || c != in.Country {
+		t.Errorf("Country() = %q, %v", c, err)
+	}
+	if e, err := v.Emails(); err != nil || !reflect.DeepEqual(e, in.Emails) {
+		t.Errorf("Emails() = %v, %v", e, err)
+	}
+	if p, err := v.Prefs(); err != nil || !reflect.DeepEqual(p, in.Prefs) {
+		t.Errorf("Prefs() = %v, %v", p, err)
+	}
+	if h, err := v.Home(); err != nil || !reflect.DeepEqual(h, in.Home) {
+		t.Errorf("Home() = %v, %v", h, err)
+	}
+	if w, err := v.Work(); err != nil || w != in.Work {
+		t.Errorf("Work() = %v, %v", w, err)
+	}
+}
+
+func TestViewMissingFields(t *testing.T) {
+	b := msgp.AppendMapHeader(nil, 1)
+	b = msgp.AppendString(b, "age")
+	b = msgp.AppendInt(b, 5)
+	v := ProfileView(b)
+	if _, err := v.Name(); err != (msgp.ErrMissingField{Type: "Profile", Field: "name"}) {
+		t.Errorf("got the error %v for the missing required field", err)
+	}
+	if c, err := v.Country(); err != nil || c != "NZ" {
+		t.Errorf("Country() = %q, %v; want the default", c, err)
+	}
+	if h, err := v.Home(); err != nil || h != nil {
+		t.Errorf("Home() = %v, %v; want nil", h, err)
+	}
+
+	if _, err := ProfileView(msgp.AppendInt(nil, 1)).Age(); err == nil {
+		t.Error("no error for a view of an int")
+	}
+}
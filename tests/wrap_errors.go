@@ -0,0 +1,21 @@
+package tests
+
+//go:generate msgp -wraperrors
+
+//msgp:noinline WrapItem
+
+// WrapItem is decoded by its own methods, whose errors are wrapped again by the callers.
+type WrapItem struct {
+	ID int8 `msgp:"id"`
+}
+
+// WrapOuter has values at the paths of all of the kinds.
+type WrapOuter struct {
+	Name  string              `msgp:"name"`
+	Items []WrapItem          `msgp:"items"`
+	Tags  map[string]WrapItem `msgp:"tags"`
+	Inner struct {
+		Count uint8 `msgp:"count"`
+	} `msgp:"inner"`
+	Fixed [2]int16 `msgp:"fixed"`
+}
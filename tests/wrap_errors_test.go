@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dchenk/msgp/msgp"
+)
+
+func TestWrapErrors(t *testing.T) {
+	item := func(b []byte, id int64) []byte {
+		b = msgp.AppendMapHeader(b, 1)
+		return msgp.AppendInt64(msgp.AppendString(b, "id"), id)
+	}
+	cases := []struct {
+		field string
+		value func([]byte) []byte
+		path  []interface{}
+		cause error
+	}{
+		{"name", func(b []byte) []byte { return msgp.AppendInt(b, 1) }, []interface{}{"Name"}, msgp.TypeError{}},
+		{"items", func(b []byte) []byte {
+			return item(item(msgp.AppendArrayHeader(b, 2), 1), 300)
+		}, []interface{}{"Items", 1, "ID"}, msgp.IntOverflow{}},
+		{"tags", func(b []byte) []byte {
+			return item(msgp.AppendString(msgp.AppendMapHeader(b, 1), "k"), -200)
+		}, []interface{}{"Tags", "k", "ID"}, msgp.IntOverflow{}},
+		{"inner", func(b []byte) []byte {
+			b = msgp.AppendMapHeader(b, 1)
+			return msgp.AppendInt(msgp.AppendString(b, "count"), -1)
+		}, []interface{}{"Inner", "Count"}, msgp.TypeError{}},
+		{"fixed", func(b []byte) []byte { return msgp.AppendArrayHeader(b, 3) }, []interface{}{"Fixed"}, msgp.ArrayError{}},
+	}
+	for _, c := range cases {
+		msg := msgp.AppendMapHeader(nil, 1)
+		msg = c.value(msgp.AppendString(msg, c.field))
+
+		var out WrapOuter
+		_, uerr := out.UnmarshalMsg(msg)
+		derr := out.DecodeMsg(msgp.NewReader(bytes.NewReader(msg)))
+		for _, err := range []error{uerr, derr} {
+			pe, ok := err.(*msgp.PathError)
+			if !ok {
+				t.Errorf("%s: got the error %v (%T); want a *msgp.PathError", c.field, err, err)
+				continue
+			}
+			if !reflect.DeepEqual(pe.Path, c.path) {
+				t.Errorf("%s: got the path %v; want %v", c.field, pe.Path, c.path)
+			}
+			if reflect.TypeOf(msgp.Cause(err)) != reflect.TypeOf(c.cause) {
+				t.Errorf("%s: got the cause %T; want %T", c.field, msgp.Cause(err), c.cause)
+			}
+			if !pe.Resumable() {
+				t.Errorf("%s: the error is not resumable", c.field)
+			}
+		}
+	}
+
+	// The errors of a truncated message can still be compared with those of msgp.
+	in := WrapOuter{Name: "n", Items: []WrapItem{{ID: 1}, {ID: 2}}}
+	msg, err := in.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out WrapOuter
+	_, err = out.UnmarshalMsg(msg[:len(msg)-1])
+	if msgp.Cause(err) != msgp.ErrShortBytes {
+		t.Errorf("got the error %v; want msgp.ErrShortBytes", err)
+	}
+	if err == nil || err.Error() != "msgp: too few bytes left to read object (at Fixed/1)" {
+		t.Errorf("got the error message %q", err)
+	}
+}